@@ -0,0 +1,302 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/stripe/memlink/client"
+	"github.com/stripe/memlink/pools"
+)
+
+const (
+	defaultBusPrefix       = "memlink:inval:"
+	defaultBusPollInterval = 100 * time.Millisecond
+	defaultBusEventTTL     = 60 // seconds
+	// if a poller falls further behind than this many events, it's cheaper to drop every local entry than to
+	// replay the events one by one.
+	maxBusBacklog = 1000
+	// number of polls an event is allowed to be missing for before it's considered lost. An event can be missing
+	// for a short while as the publisher bumps the sequence before writing the event itself.
+	maxBusMissingPolls = 3
+)
+
+// Invalidator is implemented by caches holding process local copies of memcached items.
+type Invalidator interface {
+	// Invalidate drops the local copy of key.
+	Invalidate(key string)
+	// InvalidateAll drops every local copy, used when invalidation events may have been lost.
+	InvalidateAll()
+}
+
+// InvalidationBus propagates the keys written or deleted by one process to every other process sharing the same
+// memcached cluster, so their Invalidators can drop stale local copies.
+type InvalidationBus interface {
+	// Publish announces that key was written or deleted by this process.
+	Publish(ctx context.Context, key string) error
+	// Subscribe registers inv to receive the keys published by other processes.
+	Subscribe(inv Invalidator)
+}
+
+// PollingBus is an InvalidationBus which uses memcached itself as the transport. Every published key bumps a shared
+// sequence counter and is stored under a version-stamped event key (<prefix><sequence>) with a short TTL. Each
+// process polls the counter and replays the events it hasn't seen yet. Events which can't be replayed, because they
+// expired or the counter was evicted, cause every local copy to be dropped.
+type PollingBus struct {
+	mc           client.MemcachedClient
+	id           string
+	prefix       string
+	pollInterval time.Duration
+	eventTTL     int32
+	logger       *zap.Logger
+
+	mu             sync.Mutex
+	subscribers    []Invalidator // protected by mu
+	lastSeq        uint64        // only accessed by the poll routine
+	missingPolls   int           // only accessed by the poll routine
+	seqInitialized bool          // only accessed by the poll routine
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+var _ InvalidationBus = (*PollingBus)(nil)
+
+type PollingBusOption func(b *PollingBus)
+
+// WithBusPrefix sets the prefix of the keys used to store the sequence counter and the events.
+func WithBusPrefix(prefix string) PollingBusOption {
+	return func(b *PollingBus) {
+		b.prefix = prefix
+	}
+}
+
+// WithBusPollInterval sets how often memcached is polled for new events.
+func WithBusPollInterval(interval time.Duration) PollingBusOption {
+	return func(b *PollingBus) {
+		b.pollInterval = interval
+	}
+}
+
+// WithBusEventTTL sets how long, in seconds, events are kept around for slow pollers.
+func WithBusEventTTL(ttlSeconds int32) PollingBusOption {
+	return func(b *PollingBus) {
+		b.eventTTL = ttlSeconds
+	}
+}
+
+func WithBusLogger(logger *zap.Logger) PollingBusOption {
+	return func(b *PollingBus) {
+		b.logger = logger
+	}
+}
+
+// NewPollingBus creates a PollingBus and starts polling memcached in a background routine until Close is called.
+func NewPollingBus(mc client.MemcachedClient, opts ...PollingBusOption) *PollingBus {
+	b := &PollingBus{
+		mc:           mc,
+		id:           uuid.NewString(),
+		prefix:       defaultBusPrefix,
+		pollInterval: defaultBusPollInterval,
+		eventTTL:     defaultBusEventTTL,
+		done:         make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	if b.logger == nil {
+		b.logger = zap.NewNop()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+	go b.poll(ctx)
+	return b
+}
+
+func (b *PollingBus) Subscribe(inv Invalidator) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, inv)
+}
+
+func (b *PollingBus) Publish(ctx context.Context, key string) error {
+	arithEncoder := arithEncoderPool.Get()
+	arithDecoder := arithDecoderPool.Get()
+	defer pools.Release(ctx, arithEncoderPool, arithEncoder, arithDecoderPool, arithDecoder)
+
+	arithEncoder.Key = b.seqKey()
+	arithEncoder.Delta = 1
+	// auto create the counter without an expiry. memcached doesn't apply the delta when creating the item, so
+	// start at 1 as pollers treat a missing counter as 0.
+	arithEncoder.BlockTTL = 0
+	arithEncoder.InitialValue = 1
+	arithEncoder.FetchValue = true
+
+	if err := b.mc.MetaIncrement(ctx, arithEncoder, arithDecoder); err != nil {
+		return err
+	}
+	if !arithDecoder.Status.IsStored() {
+		return fmt.Errorf("unable to bump invalidation sequence, status=%s", arithDecoder.Status)
+	}
+
+	setEncoder := setEncoderPool.Get()
+	setDecoder := setDecoderPool.Get()
+	defer pools.Release(ctx, setEncoderPool, setEncoder, setDecoderPool, setDecoder)
+
+	setEncoder.Key = b.eventKey(arithDecoder.ValueUInt64)
+	setEncoder.Value = append([]byte(b.id+" "), key...)
+	setEncoder.TTL = b.eventTTL
+
+	if err := b.mc.MetaSet(ctx, setEncoder, setDecoder); err != nil {
+		return err
+	}
+	if !setDecoder.Status.IsStored() {
+		return fmt.Errorf("unable to store invalidation event, status=%s", setDecoder.Status)
+	}
+	return nil
+}
+
+// Close stops the poll routine and waits for it to exit.
+func (b *PollingBus) Close() {
+	b.cancel()
+	<-b.done
+}
+
+func (b *PollingBus) seqKey() string {
+	return b.prefix + "seq"
+}
+
+func (b *PollingBus) eventKey(seq uint64) string {
+	return b.prefix + strconv.FormatUint(seq, 10)
+}
+
+func (b *PollingBus) poll(ctx context.Context) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := b.pollOnce(ctx); err != nil && ctx.Err() == nil {
+			b.logger.Warn("failed to poll for invalidation events", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *PollingBus) pollOnce(ctx context.Context) error {
+	seq, err := b.currentSeq(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !b.seqInitialized {
+		// don't replay events published before this process started.
+		b.lastSeq = seq
+		b.seqInitialized = true
+		return nil
+	}
+
+	if seq < b.lastSeq || seq-b.lastSeq > maxBusBacklog {
+		// the counter was evicted or this process fell too far behind.
+		b.invalidateAll()
+		b.lastSeq = seq
+		return nil
+	}
+
+	for next := b.lastSeq + 1; next <= seq; next++ {
+		found, err := b.replay(ctx, next)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			b.missingPolls++
+			if b.missingPolls < maxBusMissingPolls {
+				// try again on the next poll, the publisher may not have written the event yet.
+				return nil
+			}
+			b.invalidateAll()
+			b.lastSeq = seq
+			b.missingPolls = 0
+			return nil
+		}
+
+		b.missingPolls = 0
+		b.lastSeq = next
+	}
+	return nil
+}
+
+func (b *PollingBus) currentSeq(ctx context.Context) (uint64, error) {
+	encoder := getEncoderPool.Get()
+	decoder := getDecoderPool.Get()
+	defer pools.Release(ctx, getEncoderPool, encoder, getDecoderPool, decoder)
+
+	encoder.Key = b.seqKey()
+	encoder.FetchValue = true
+
+	if err := b.mc.MetaGet(ctx, encoder, decoder); err != nil {
+		return 0, err
+	}
+	if !decoder.Status.IsHit() {
+		return 0, nil
+	}
+	return strconv.ParseUint(string(decoder.Value), 10, 64)
+}
+
+// replay fetches the event with the given sequence and dispatches it to the subscribers unless this process
+// published it. It returns false if the event couldn't be found.
+func (b *PollingBus) replay(ctx context.Context, seq uint64) (bool, error) {
+	encoder := getEncoderPool.Get()
+	decoder := getDecoderPool.Get()
+	defer pools.Release(ctx, getEncoderPool, encoder, getDecoderPool, decoder)
+
+	encoder.Key = b.eventKey(seq)
+	encoder.FetchValue = true
+
+	if err := b.mc.MetaGet(ctx, encoder, decoder); err != nil {
+		return false, err
+	}
+	if !decoder.Status.IsHit() {
+		return false, nil
+	}
+
+	publisher, key, ok := bytes.Cut(decoder.Value, []byte(" "))
+	if !ok {
+		return false, fmt.Errorf("malformed invalidation event %q", decoder.Value)
+	}
+
+	if string(publisher) == b.id {
+		return true, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, inv := range b.subscribers {
+		inv.Invalidate(string(key))
+	}
+	return true, nil
+}
+
+func (b *PollingBus) invalidateAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, inv := range b.subscribers {
+		inv.InvalidateAll()
+	}
+}
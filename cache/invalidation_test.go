@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/client"
+)
+
+type recordingInvalidator struct {
+	keys chan string
+	all  chan struct{}
+}
+
+func (r *recordingInvalidator) Invalidate(key string) {
+	r.keys <- key
+}
+
+func (r *recordingInvalidator) InvalidateAll() {
+	r.all <- struct{}{}
+}
+
+func TestPollingBusInvalidatesOtherProcesses(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+
+	other, err := client.NewClient([]string{srv.Addr().String()}, 1)
+	require.NoError(t, err)
+	defer other.Close() //nolint: errcheck
+
+	writerBus := NewPollingBus(mc, WithBusPollInterval(time.Millisecond))
+	defer writerBus.Close()
+	readerBus := NewPollingBus(other, WithBusPollInterval(time.Millisecond))
+	defer readerBus.Close()
+
+	writer := NewTiered(mc, WithInvalidationBus(writerBus))
+	reader := NewTiered(other, WithInvalidationBus(readerBus))
+
+	require.NoError(t, writer.Set(ctx, "k", []byte("v1"), 60))
+	value, ok, err := reader.Get(ctx, "k")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []byte("v1"), value)
+
+	require.NoError(t, writer.Set(ctx, "k", []byte("v2"), 60))
+	assert.Eventually(t, func() bool {
+		value, _, err := reader.Get(ctx, "k")
+		return err == nil && string(value) == "v2"
+	}, time.Second, time.Millisecond)
+
+	// the writer skips its own events and keeps serving its local copy.
+	hitsBefore := writer.Stats().L1Hits
+	time.Sleep(10 * time.Millisecond)
+	_, _, err = writer.Get(ctx, "k")
+	assert.NoError(t, err)
+	assert.Equal(t, hitsBefore+1, writer.Stats().L1Hits)
+}
+
+func TestPollingBusDropsEverythingOnLostEvents(t *testing.T) {
+	mc, srv := newTestClient(t)
+
+	bus := NewPollingBus(mc, WithBusPollInterval(time.Millisecond), WithBusPrefix("p:"))
+	defer bus.Close()
+	inv := &recordingInvalidator{keys: make(chan string, 10), all: make(chan struct{}, 10)}
+	bus.Subscribe(inv)
+
+	// wait for the poller to observe the initial (missing) counter, then bump it without writing an event.
+	time.Sleep(10 * time.Millisecond)
+	srv.Set("p:seq", []byte("1"), 0)
+
+	select {
+	case <-inv.all:
+	case <-time.After(time.Second):
+		t.Fatal("expected every local copy to be invalidated")
+	}
+	assert.Empty(t, inv.keys)
+}
+
+func TestPollingBusReplaysEvents(t *testing.T) {
+	mc, srv := newTestClient(t)
+
+	bus := NewPollingBus(mc, WithBusPollInterval(time.Millisecond), WithBusPrefix("p:"))
+	defer bus.Close()
+	inv := &recordingInvalidator{keys: make(chan string, 10), all: make(chan struct{}, 10)}
+	bus.Subscribe(inv)
+
+	time.Sleep(10 * time.Millisecond)
+	srv.Set("p:1", []byte("another-process some-key"), 60)
+	srv.Set("p:seq", []byte("1"), 0)
+
+	select {
+	case key := <-inv.keys:
+		assert.Equal(t, "some-key", key)
+	case <-time.After(time.Second):
+		t.Fatal("expected the event to be replayed")
+	}
+}
@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type localEntry struct {
+	key      string
+	value    []byte
+	expireAt time.Time // zero value means the entry never expires
+}
+
+// LocalCache is a size bounded, in-process LRU cache with per entry expiry. It is meant to be used as the L1 tier in
+// front of memcached and is safe for concurrent use.
+type LocalCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List               // protected by mu, front is the most recently used entry
+	items      map[string]*list.Element // protected by mu
+	retention  time.Duration
+
+	now func() time.Time
+}
+
+type LocalCacheOption func(c *LocalCache)
+
+// WithStaleRetention keeps expired entries for up to d after their expiry, so that GetStale can still return them.
+// Get never returns an expired entry.
+func WithStaleRetention(d time.Duration) LocalCacheOption {
+	return func(c *LocalCache) {
+		c.retention = d
+	}
+}
+
+// NewLocalCache creates a LocalCache holding at most maxEntries entries. If less than 1 entry is requested, it
+// defaults to 1.
+func NewLocalCache(maxEntries int, opts ...LocalCacheOption) *LocalCache {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+
+	c := &LocalCache{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element, maxEntries),
+		now:        time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Get returns the value for key if it's present and not expired.
+func (c *LocalCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*localEntry)
+	if now := c.now(); !entry.expireAt.IsZero() && !now.Before(entry.expireAt) {
+		if !now.Before(entry.expireAt.Add(c.retention)) {
+			c.removeElement(elem)
+		}
+		return nil, false
+	}
+
+	c.ll.MoveToFront(elem)
+	return entry.value, true
+}
+
+// GetStale returns the value for key if it's present and either not expired or expired for less than the stale
+// retention, see WithStaleRetention, and reports whether it's expired.
+func (c *LocalCache) GetStale(key string) (value []byte, expired bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false, false
+	}
+
+	entry := elem.Value.(*localEntry)
+	if now := c.now(); !entry.expireAt.IsZero() && !now.Before(entry.expireAt) {
+		if !now.Before(entry.expireAt.Add(c.retention)) {
+			c.removeElement(elem)
+			return nil, false, false
+		}
+		return entry.value, true, true
+	}
+
+	c.ll.MoveToFront(elem)
+	return entry.value, false, true
+}
+
+// Set stores value for key. A non-positive ttl means the entry never expires, though it can still be evicted when
+// the cache is full.
+func (c *LocalCache) Set(key string, value []byte, ttl time.Duration) {
+	c.setIf(key, value, ttl, nil)
+}
+
+// setIf is like Set, but only stores value if cond, checked under mu so that it's atomic with the store, returns true,
+// or is nil.
+func (c *LocalCache) setIf(key string, value []byte, ttl time.Duration, cond func() bool) {
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = c.now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cond != nil && !cond() {
+		return
+	}
+
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*localEntry)
+		entry.value = value
+		entry.expireAt = expireAt
+		c.ll.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&localEntry{key: key, value: value, expireAt: expireAt})
+	for c.ll.Len() > c.maxEntries {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// Delete removes key from the cache. It's a no-op if the key isn't present.
+func (c *LocalCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// Purge removes every entry from the cache.
+func (c *LocalCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	clear(c.items)
+}
+
+// Len returns the number of entries in the cache, including expired entries which haven't been evicted yet.
+func (c *LocalCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// removeElement must be called with mu held.
+func (c *LocalCache) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*localEntry).key)
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalCacheGetSetDelete(t *testing.T) {
+	c := NewLocalCache(10)
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+
+	c.Set("a", []byte("1"), 0)
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), v)
+
+	c.Set("a", []byte("2"), 0)
+	v, ok = c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("2"), v)
+	assert.Equal(t, 1, c.Len())
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Zero(t, c.Len())
+}
+
+func TestLocalCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLocalCache(2)
+	c.Set("a", []byte("1"), 0)
+	c.Set("b", []byte("2"), 0)
+
+	// touch a so that b becomes the least recently used entry.
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	c.Set("c", []byte("3"), 0)
+	assert.Equal(t, 2, c.Len())
+
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+}
+
+func TestLocalCacheExpiry(t *testing.T) {
+	now := time.Now()
+	c := NewLocalCache(10)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", []byte("1"), time.Second)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Zero(t, c.Len())
+}
+
+func TestNewLocalCacheWithZeroEntries(t *testing.T) {
+	c := NewLocalCache(0)
+	c.Set("a", []byte("1"), 0)
+	c.Set("b", []byte("2"), 0)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestLocalCacheStaleRetention(t *testing.T) {
+	now := time.Now()
+	c := NewLocalCache(10, WithStaleRetention(time.Minute))
+	c.now = func() time.Time { return now }
+
+	c.Set("a", []byte("1"), time.Second)
+	value, expired, ok := c.GetStale("a")
+	assert.True(t, ok)
+	assert.False(t, expired)
+	assert.Equal(t, []byte("1"), value)
+
+	now = now.Add(time.Second)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	value, expired, ok = c.GetStale("a")
+	assert.True(t, ok)
+	assert.True(t, expired)
+	assert.Equal(t, []byte("1"), value)
+
+	now = now.Add(time.Minute)
+	_, _, ok = c.GetStale("a")
+	assert.False(t, ok)
+	assert.Zero(t, c.Len())
+}
@@ -0,0 +1,17 @@
+package cache
+
+import (
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/pools"
+)
+
+var (
+	getEncoderPool    = pools.NewResettablePool(memcache.CreateMetaGetEncoder)
+	getDecoderPool    = pools.NewResettablePool(memcache.CreateMetaGetDecoder)
+	setEncoderPool    = pools.NewResettablePool(memcache.CreateMetaSetEncoder)
+	setDecoderPool    = pools.NewResettablePool(memcache.CreateMetaSetDecoder)
+	deleteEncoderPool = pools.NewResettablePool(memcache.CreateMetaDeleteEncoder)
+	deleteDecoderPool = pools.NewResettablePool(memcache.CreateMetaDeleteDecoder)
+	arithEncoderPool  = pools.NewResettablePool(memcache.CreateArithmeticEncoder)
+	arithDecoderPool  = pools.NewResettablePool(memcache.CreateArithmeticDecoder)
+)
@@ -0,0 +1,299 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"sync/atomic"
+	"time"
+
+	"github.com/stripe/memlink/client"
+	"github.com/stripe/memlink/pools"
+)
+
+const (
+	defaultL1MaxEntries = 10_000
+	defaultL1MaxTTL     = 10 * time.Second
+	// l1Generations is the number of write generations the keys are spread over, see Tiered.generation.
+	l1Generations = 1024
+)
+
+// ErrNotStored is returned when memcached refused to store a value written through the Tiered cache.
+var ErrNotStored = errors.New("cache: memcached did not store the item")
+
+// TieredStats holds the per-tier hit counters of a Tiered cache.
+type TieredStats struct {
+	L1Hits   uint64
+	L1Misses uint64
+	L2Hits   uint64
+	L2Misses uint64
+	// StaleServed counts the reads answered with an expired in-process copy because memcached failed, see
+	// WithServeStale.
+	StaleServed uint64
+}
+
+// TieredResult is the outcome of Tiered.Fetch.
+type TieredResult struct {
+	Value []byte
+	Found bool
+	// Stale reports whether Value is past its freshness: either memcached returned a value older than its soft TTL
+	// (see client.WithSoftTTL), or memcached failed and Value is an in-process copy served within the grace period.
+	Stale bool
+	// BackendErr is the error memcached failed with when Value was served from the in-process tier instead.
+	BackendErr error
+}
+
+// Tiered is a two level cache: an in-process LocalCache (L1) in front of memcached (L2).
+//
+// Reads are served from L1 when possible and fall back to memcached, populating L1 on a hit. An L1 entry never
+// outlives the memcached item it was copied from: its TTL is the smaller of the L1 max TTL and the remaining TTL of
+// the memcached item. Writes and deletes from this process invalidate the L1 entry before touching memcached, so a
+// process always reads its own writes. Writes from other processes are only observed once the L1 entry expires, unless
+// an InvalidationBus is configured with WithInvalidationBus. With WithServeStale, expired L1 entries are still served
+// when memcached fails. The values returned by reads are shared with the in-process tier and must not be modified.
+type Tiered struct {
+	l1           *LocalCache
+	l2           client.MemcachedClient
+	l1MaxEntries int
+	l1MaxTTL     time.Duration
+	staleGrace   time.Duration
+	bus          InvalidationBus
+
+	// generations are bumped whenever the keys hashed to them are written, deleted or invalidated, so that a read
+	// doesn't copy into L1 a value memcached returned before a concurrent write. Keys sharing a generation only skip
+	// some copies.
+	seed        maphash.Seed
+	generations [l1Generations]atomic.Uint64
+
+	l1Hits      atomic.Uint64
+	l1Misses    atomic.Uint64
+	l2Hits      atomic.Uint64
+	l2Misses    atomic.Uint64
+	staleServed atomic.Uint64
+}
+
+type TieredOption func(t *Tiered)
+
+// WithL1MaxEntries sets the maximum number of entries held in the in-process tier.
+func WithL1MaxEntries(n int) TieredOption {
+	return func(t *Tiered) {
+		t.l1MaxEntries = n
+	}
+}
+
+// WithL1MaxTTL caps how long a value is served from the in-process tier without going back to memcached.
+func WithL1MaxTTL(ttl time.Duration) TieredOption {
+	return func(t *Tiered) {
+		t.l1MaxTTL = ttl
+	}
+}
+
+// WithServeStale keeps the in-process copies of the values for up to grace after their expiry, and serves them, marked
+// as stale, when memcached fails to answer a read. It trades consistency for availability during cache tier incidents.
+func WithServeStale(grace time.Duration) TieredOption {
+	return func(t *Tiered) {
+		t.staleGrace = grace
+	}
+}
+
+// WithInvalidationBus publishes every key written or deleted through the Tiered cache on bus, and drops the local
+// copies of the keys published by other processes.
+func WithInvalidationBus(bus InvalidationBus) TieredOption {
+	return func(t *Tiered) {
+		t.bus = bus
+	}
+}
+
+// NewTiered creates a Tiered cache using mc as the L2 tier.
+func NewTiered(mc client.MemcachedClient, opts ...TieredOption) *Tiered {
+	t := &Tiered{
+		l2:           mc,
+		l1MaxEntries: defaultL1MaxEntries,
+		l1MaxTTL:     defaultL1MaxTTL,
+		seed:         maphash.MakeSeed(),
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	t.l1 = NewLocalCache(t.l1MaxEntries, WithStaleRetention(t.staleGrace))
+
+	if t.bus != nil {
+		t.bus.Subscribe(t)
+	}
+
+	return t
+}
+
+// Get returns the value for key, checking the in-process tier before memcached. The boolean is false on a miss in
+// both tiers.
+func (t *Tiered) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	result, err := t.Fetch(ctx, key)
+	return result.Value, result.Found, err
+}
+
+// Fetch is like Get, but also reports whether the value is stale. When WithServeStale is set and memcached fails, the
+// in-process copy of the value is returned instead of the error if it expired less than the grace period ago, unless
+// ctx was canceled.
+func (t *Tiered) Fetch(ctx context.Context, key string) (TieredResult, error) {
+	if value, ok := t.l1.Get(key); ok {
+		t.l1Hits.Add(1)
+		return TieredResult{Value: value, Found: true}, nil
+	}
+	t.l1Misses.Add(1)
+
+	generation := t.generation(key)
+	before := generation.Load()
+	result, err := t.l2.GetWithTTL(ctx, key)
+	if err != nil {
+		return t.serveStale(ctx, key, err)
+	}
+
+	if !result.Found {
+		t.l2Misses.Add(1)
+		return TieredResult{}, nil
+	}
+	t.l2Hits.Add(1)
+
+	// memcached reports -1 for items without an expiry and 0 for items about to expire, which are not worth
+	// copying into the in-process tier. Values past their soft TTL aren't either, so that the next read checks whether
+	// they were refreshed, nor the values of the keys written since the read started, which may predate the write.
+	if result.RemainingTTLSeconds != 0 && !result.Stale {
+		t.l1.setIf(key, result.Value, t.l1TTL(result.RemainingTTLSeconds), func() bool {
+			return generation.Load() == before
+		})
+	}
+	return TieredResult{Value: result.Value, Found: true, Stale: result.Stale}, nil
+}
+
+func (t *Tiered) serveStale(ctx context.Context, key string, err error) (TieredResult, error) {
+	if t.staleGrace <= 0 || errors.Is(ctx.Err(), context.Canceled) {
+		return TieredResult{}, err
+	}
+
+	value, _, ok := t.l1.GetStale(key)
+	if !ok {
+		return TieredResult{}, err
+	}
+	t.staleServed.Add(1)
+	return TieredResult{Value: value, Found: true, Stale: true, BackendErr: err}, nil
+}
+
+// Set writes value to memcached with the given TTL in seconds (0 means no expiry) and, on success, a copy of it to the
+// in-process tier.
+func (t *Tiered) Set(ctx context.Context, key string, value []byte, ttlSeconds int32) error {
+	t.invalidate(key)
+	generation := t.generation(key)
+	before := generation.Load()
+
+	encoder := setEncoderPool.Get()
+	decoder := setDecoderPool.Get()
+	defer pools.Release(ctx, setEncoderPool, encoder, setDecoderPool, decoder)
+
+	encoder.Key = key
+	encoder.Value = value
+	encoder.TTL = ttlSeconds
+
+	err := t.l2.MetaSet(ctx, encoder, decoder)
+	// the reads which started before memcached stored value must not copy what they read into L1 anymore, nor when it
+	// failed, since the value may have been stored anyway, e.g. on a timeout.
+	t.invalidate(key)
+	after := generation.Load()
+	if err != nil {
+		return err
+	}
+
+	if !decoder.Status.IsStored() {
+		return fmt.Errorf("key=%q status=%s: %w", key, decoder.Status, ErrNotStored)
+	}
+
+	// a concurrent write may have stored its value after this one, so value is only copied into L1 if no other write
+	// invalidated key between the two invalidations of this one, nor does until the copy.
+	if after == before+1 {
+		t.l1.setIf(key, bytes.Clone(value), t.l1TTL(ttlSeconds), func() bool { return generation.Load() == after })
+	}
+	return t.publish(ctx, key)
+}
+
+// Delete removes key from both tiers. Deleting a key which doesn't exist in memcached is not an error.
+func (t *Tiered) Delete(ctx context.Context, key string) error {
+	t.invalidate(key)
+
+	encoder := deleteEncoderPool.Get()
+	decoder := deleteDecoderPool.Get()
+	defer pools.Release(ctx, deleteEncoderPool, encoder, deleteDecoderPool, decoder)
+
+	encoder.Key = key
+
+	err := t.l2.MetaDelete(ctx, encoder, decoder)
+	// the reads which started before memcached deleted the key may have copied the previous value into L1.
+	t.invalidate(key)
+	if err != nil {
+		return err
+	}
+
+	if !decoder.Status.IsDeleted() && !decoder.Status.IsMiss() {
+		return fmt.Errorf("unexpected status while deleting key=%q: %s", key, decoder.Status)
+	}
+	return t.publish(ctx, key)
+}
+
+// Invalidate drops key from the in-process tier only.
+func (t *Tiered) Invalidate(key string) {
+	t.invalidate(key)
+}
+
+// InvalidateAll drops every entry from the in-process tier.
+func (t *Tiered) InvalidateAll() {
+	for i := range t.generations {
+		t.generations[i].Add(1)
+	}
+	t.l1.Purge()
+}
+
+// invalidate drops key from the in-process tier, and keeps the reads in flight from copying it back.
+func (t *Tiered) invalidate(key string) {
+	t.generation(key).Add(1)
+	t.l1.Delete(key)
+}
+
+// generation returns the write generation of key.
+func (t *Tiered) generation(key string) *atomic.Uint64 {
+	return &t.generations[maphash.String(t.seed, key)%l1Generations]
+}
+
+var _ Invalidator = (*Tiered)(nil)
+
+func (t *Tiered) publish(ctx context.Context, key string) error {
+	if t.bus == nil {
+		return nil
+	}
+
+	if err := t.bus.Publish(ctx, key); err != nil {
+		return fmt.Errorf("key=%q was written to memcached but the invalidation could not be published: %w", key, err)
+	}
+	return nil
+}
+
+// Stats returns a snapshot of the per-tier hit counters.
+func (t *Tiered) Stats() TieredStats {
+	return TieredStats{
+		L1Hits:      t.l1Hits.Load(),
+		L1Misses:    t.l1Misses.Load(),
+		L2Hits:      t.l2Hits.Load(),
+		L2Misses:    t.l2Misses.Load(),
+		StaleServed: t.staleServed.Load(),
+	}
+}
+
+// l1TTL returns the TTL for an in-process entry mirroring a memcached item with the given TTL in seconds, where
+// non-positive values mean the item doesn't expire.
+func (t *Tiered) l1TTL(ttlSeconds int32) time.Duration {
+	if ttlSeconds <= 0 {
+		return t.l1MaxTTL
+	}
+	return min(t.l1MaxTTL, time.Duration(ttlSeconds)*time.Second)
+}
@@ -0,0 +1,232 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/client"
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+func newTestClient(t *testing.T) (client.MemcachedClient, *fakeserver.Server) {
+	srv, err := fakeserver.Start()
+	require.NoError(t, err)
+
+	mc, err := client.NewClient([]string{srv.Addr().String()}, 1)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = mc.Close()
+		_ = srv.Close()
+	})
+	return mc, srv
+}
+
+func TestTieredReadThrough(t *testing.T) {
+	mc, srv := newTestClient(t)
+	tiered := NewTiered(mc)
+	ctx := context.Background()
+
+	srv.Set("k", []byte("v"), 60)
+
+	value, ok, err := tiered.Get(ctx, "k")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("v"), value)
+
+	value, ok, err = tiered.Get(ctx, "k")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("v"), value)
+
+	assert.Equal(t, 1, srv.CommandCount("mg"))
+	assert.Equal(t, TieredStats{L1Hits: 1, L1Misses: 1, L2Hits: 1}, tiered.Stats())
+}
+
+func TestTieredMiss(t *testing.T) {
+	mc, _ := newTestClient(t)
+	tiered := NewTiered(mc)
+
+	value, ok, err := tiered.Get(context.Background(), "missing")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, value)
+	assert.Equal(t, TieredStats{L1Misses: 1, L2Misses: 1}, tiered.Stats())
+}
+
+func TestTieredWriteInvalidatesL1(t *testing.T) {
+	mc, srv := newTestClient(t)
+	tiered := NewTiered(mc)
+	ctx := context.Background()
+
+	require.NoError(t, tiered.Set(ctx, "k", []byte("v1"), 60))
+	value, ok, err := tiered.Get(ctx, "k")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("v1"), value)
+
+	require.NoError(t, tiered.Set(ctx, "k", []byte("v2"), 60))
+	value, _, _ = tiered.Get(ctx, "k")
+	assert.Equal(t, []byte("v2"), value)
+	stored, _ := srv.Get("k")
+	assert.Equal(t, []byte("v2"), stored)
+
+	require.NoError(t, tiered.Delete(ctx, "k"))
+	_, ok, err = tiered.Get(ctx, "k")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// deleting a missing key is not an error.
+	assert.NoError(t, tiered.Delete(ctx, "k"))
+}
+
+func TestTieredSetCopiesValue(t *testing.T) {
+	mc, _ := newTestClient(t)
+	tiered := NewTiered(mc)
+	ctx := context.Background()
+
+	value := []byte("v1")
+	require.NoError(t, tiered.Set(ctx, "k", value, 60))
+	copy(value, "xx")
+
+	cached, ok, err := tiered.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("v1"), cached)
+}
+
+// racingClient calls race once a read or a write got its response from memcached, before the Tiered cache copies the
+// value into L1.
+type racingClient struct {
+	client.MemcachedClient
+	race func()
+}
+
+func (c *racingClient) MetaSet(ctx context.Context, encoder *memcache.MetaSetEncoder, decoder *memcache.MetaSetDecoder) error {
+	err := c.MemcachedClient.MetaSet(ctx, encoder, decoder)
+	if race := c.race; race != nil {
+		c.race = nil
+		race()
+	}
+	return err
+}
+
+func (c *racingClient) GetWithTTL(ctx context.Context, key string) (client.GetResult, error) {
+	result, err := c.MemcachedClient.GetWithTTL(ctx, key)
+	if race := c.race; race != nil {
+		c.race = nil
+		race()
+	}
+	return result, err
+}
+
+func TestTieredReadRacingWrite(t *testing.T) {
+	for name, write := range map[string]func(tiered *Tiered) error{
+		"set":        func(tiered *Tiered) error { return tiered.Set(context.Background(), "k", []byte("v2"), 60) },
+		"delete":     func(tiered *Tiered) error { return tiered.Delete(context.Background(), "k") },
+		"invalidate": func(tiered *Tiered) error { tiered.Invalidate("k"); return nil },
+	} {
+		t.Run(name, func(t *testing.T) {
+			mc, srv := newTestClient(t)
+			racing := &racingClient{MemcachedClient: mc}
+			tiered := NewTiered(racing)
+			ctx := context.Background()
+			srv.Set("k", []byte("v1"), 60)
+
+			racing.race = func() { require.NoError(t, write(tiered)) }
+			value, ok, err := tiered.Get(ctx, "k")
+			require.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, []byte("v1"), value)
+
+			// the value read before the write isn't served from L1 afterwards.
+			value, ok, err = tiered.Get(ctx, "k")
+			require.NoError(t, err)
+			stored, found := srv.Get("k")
+			assert.Equal(t, found, ok)
+			assert.Equal(t, stored, value)
+			if name == "invalidate" {
+				assert.Equal(t, 2, srv.CommandCount("mg"))
+			}
+		})
+	}
+}
+
+func TestTieredWritesRacing(t *testing.T) {
+	mc, srv := newTestClient(t)
+	racing := &racingClient{MemcachedClient: mc}
+	tiered := NewTiered(racing)
+	ctx := context.Background()
+
+	// the second write stores its value after the first one, which mustn't copy its own into L1 afterwards.
+	racing.race = func() { require.NoError(t, tiered.Set(ctx, "k", []byte("v2"), 60)) }
+	require.NoError(t, tiered.Set(ctx, "k", []byte("v1"), 60))
+
+	stored, found := srv.Get("k")
+	require.True(t, found)
+	require.Equal(t, []byte("v2"), stored)
+	value, ok, err := tiered.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, stored, value)
+}
+
+func TestTieredL1TTLNeverOutlivesL2(t *testing.T) {
+	tiered := NewTiered(nil, WithL1MaxTTL(time.Minute))
+	assert.Equal(t, 5*time.Second, tiered.l1TTL(5))
+	assert.Equal(t, time.Minute, tiered.l1TTL(3600))
+	assert.Equal(t, time.Minute, tiered.l1TTL(0))
+	assert.Equal(t, time.Minute, tiered.l1TTL(-1))
+}
+
+func TestTieredServeStale(t *testing.T) {
+	mc, srv := newTestClient(t)
+	tiered := NewTiered(mc, WithL1MaxTTL(time.Millisecond), WithServeStale(time.Minute))
+	ctx := context.Background()
+
+	srv.Set("k", []byte("v"), 60)
+	result, err := tiered.Fetch(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, TieredResult{Value: []byte("v"), Found: true}, result)
+
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, srv.Close())
+
+	result, err = tiered.Fetch(ctx, "k")
+	require.NoError(t, err)
+	assert.True(t, result.Found)
+	assert.True(t, result.Stale)
+	assert.Equal(t, []byte("v"), result.Value)
+	assert.Error(t, result.BackendErr)
+	assert.Equal(t, uint64(1), tiered.Stats().StaleServed)
+
+	// keys without an in-process copy still fail.
+	_, err = tiered.Fetch(ctx, "other")
+	assert.Error(t, err)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = tiered.Fetch(canceled, "k")
+	assert.Error(t, err)
+}
+
+func TestTieredWithoutServeStale(t *testing.T) {
+	mc, srv := newTestClient(t)
+	tiered := NewTiered(mc, WithL1MaxTTL(time.Millisecond))
+	ctx := context.Background()
+
+	srv.Set("k", []byte("v"), 60)
+	_, _, err := tiered.Get(ctx, "k")
+	require.NoError(t, err)
+
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, srv.Close())
+
+	_, _, err = tiered.Get(ctx, "k")
+	assert.Error(t, err)
+}
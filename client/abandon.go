@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// AbandonedStats are the counters of the requests whose caller stopped waiting for them because its context ended.
+// The connections still send them and read their response, so the completions are work wasted on cancelled requests.
+type AbandonedStats struct {
+	// Canceled is the number of requests abandoned because their context was cancelled.
+	Canceled uint64
+	// DeadlineExceeded is the number of requests abandoned because the deadline of their context passed.
+	DeadlineExceeded uint64
+	// Completions is the number of abandoned requests which completed afterwards, successfully or not.
+	Completions uint64
+	// FailedCompletions is the number of the completions which failed, e.g. because their connection was lost.
+	FailedCompletions uint64
+}
+
+type abandonCounters struct {
+	canceled          atomic.Uint64
+	deadlineExceeded  atomic.Uint64
+	completions       atomic.Uint64
+	failedCompletions atomic.Uint64
+}
+
+func (a *abandonCounters) snapshot() AbandonedStats {
+	return AbandonedStats{
+		Canceled:          a.canceled.Load(),
+		DeadlineExceeded:  a.deadlineExceeded.Load(),
+		Completions:       a.completions.Load(),
+		FailedCompletions: a.failedCompletions.Load(),
+	}
+}
+
+func (a *abandonCounters) completed(err error) {
+	a.completions.Add(1)
+	if err != nil {
+		a.failedCompletions.Add(1)
+	}
+}
+
+// wait waits for link to complete and returns its error, or stops waiting once ctx ends. The link is then abandoned
+// with the cause of ctx, which the returned error wraps along with ctx.Err() when they differ.
+func (c *memcachedClient) wait(ctx context.Context, link codec.Link) error {
+	select {
+	case <-ctx.Done():
+	case <-link.Done():
+		return link.Err()
+	}
+
+	err, cause := ctx.Err(), context.Cause(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		c.abandoned.deadlineExceeded.Add(1)
+	} else {
+		c.abandoned.canceled.Add(1)
+	}
+	if abandoner, ok := link.(codec.Abandoner); ok {
+		abandoner.Abandon(cause, c.abandoned.completed)
+	}
+	if cause == nil || cause == err {
+		return err
+	}
+	return fmt.Errorf("%w: %w", err, cause)
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+func TestAbandonedRequests(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+	require.NoError(t, mc.Set(ctx, "key", []byte("value"), 0))
+	srv.SetLatency("mg", 50*time.Millisecond)
+
+	errShutdown := errors.New("shutting down")
+	canceled, cancel := context.WithCancelCause(ctx)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel(errShutdown)
+	}()
+	_, _, err := mc.Get(canceled, "key")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, err, errShutdown)
+
+	errSlow := errors.New("request budget spent")
+	timedOut, cancelTimeout := context.WithTimeoutCause(ctx, 10*time.Millisecond, errSlow)
+	defer cancelTimeout()
+	_, _, err = mc.Get(timedOut, "key")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, err, errSlow)
+
+	plain, cancelPlain := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancelPlain()
+	_, _, err = mc.Get(plain, "key")
+	// without a cause of its own, the error is left as it was.
+	assert.EqualError(t, err, "GetWithTTL operation failed: context deadline exceeded")
+
+	// the responses still arrive, and are discarded.
+	assert.Eventually(t, func() bool {
+		return mc.Stats().Abandoned.Completions == 3
+	}, time.Second, 5*time.Millisecond)
+	stats := mc.Stats()
+	assert.Equal(t, AbandonedStats{Canceled: 1, DeadlineExceeded: 2, Completions: 3}, stats.Abandoned)
+
+	var b strings.Builder
+	require.NoError(t, stats.WritePrometheus(&b))
+	assert.Contains(t, b.String(), "memlink_abandoned_requests_total{reason=\"deadline_exceeded\"} 2\n")
+	assert.Contains(t, b.String(), "memlink_abandoned_completions_total{result=\"ok\"} 3\n")
+}
+
+func TestGenericLinkAbandon(t *testing.T) {
+	errCause := errors.New("cause")
+	errLink := errors.New("link failed")
+
+	t.Run("before completion", func(t *testing.T) {
+		link := codec.NewGenericLink(memcache.CreateMetaNoOpEncoder(), memcache.CreateMetaNoOpDecoder())
+		abandoner := link.(codec.Abandoner)
+		var completed []error
+		abandoner.Abandon(errCause, func(err error) { completed = append(completed, err) })
+		abandoner.Abandon(errors.New("ignored"), func(err error) { completed = append(completed, err) })
+		assert.Equal(t, errCause, abandoner.AbandonCause())
+		assert.Empty(t, completed)
+
+		link.Complete(errLink)
+		assert.Equal(t, []error{errLink}, completed)
+	})
+
+	t.Run("after completion", func(t *testing.T) {
+		link := codec.NewGenericLink(memcache.CreateMetaNoOpEncoder(), memcache.CreateMetaNoOpDecoder())
+		abandoner := link.(codec.Abandoner)
+		assert.NoError(t, abandoner.AbandonCause())
+		link.Complete(nil)
+
+		var completed []error
+		abandoner.Abandon(errCause, func(err error) { completed = append(completed, err) })
+		assert.Equal(t, []error{nil}, completed)
+	})
+}
@@ -0,0 +1,202 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+	netpkg "github.com/stripe/memlink/internal/net"
+	"github.com/stripe/memlink/pools"
+)
+
+// errMetadumpAbandoned stops the entries of a dump its caller stopped waiting for from being passed on.
+var errMetadumpAbandoned = errors.New("the dump was abandoned")
+
+// DeleteByPrefix walks the items of every backend with lru_crawler metadump and deletes the keys starting with prefix
+// from the backend holding them. At most rate deletes are issued per second across all the backends; a non-positive
+// rate disables the limit. It returns the number of keys which were deleted, which may be non-zero on error.
+//
+// This is meant for operational cleanups: a metadump crawls the entire cache and streams it over the connection it
+// was issued on, delaying the requests queued behind it.
+func (c *memcachedClient) DeleteByPrefix(ctx context.Context, prefix string, rate int) (int, error) {
+	if prefix == "" {
+		return 0, errors.New("DeleteByPrefix operation failed: refusing to delete every key with an empty prefix")
+	}
+
+	var tick <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	deleted := 0
+	for _, be := range c.pool.Backends() {
+		keys, err := c.metadumpKeys(ctx, be, prefix)
+		if err != nil {
+			return deleted, fmt.Errorf("DeleteByPrefix operation failed: %w", err)
+		}
+
+		for _, key := range keys {
+			if tick != nil {
+				select {
+				case <-ctx.Done():
+					return deleted, ctx.Err()
+				case <-tick:
+				}
+			}
+
+			status, err := c.deleteFrom(ctx, be, key)
+			if err != nil {
+				return deleted, fmt.Errorf("DeleteByPrefix operation failed: %w", err)
+			}
+			if status.IsDeleted() {
+				deleted++
+			}
+		}
+	}
+
+	return deleted, nil
+}
+
+// metadumpKeys returns the keys starting with prefix stored on the given backend, filtered as they're dumped.
+func (c *memcachedClient) metadumpKeys(ctx context.Context, be *netpkg.Backend, prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	err := c.metadump(ctx, be, func(entry memcache.MetadumpEntry) error {
+		// keys stored with the base64 flag are dumped decoded and can't be sent back as is, skip them.
+		if strings.HasPrefix(entry.Key, prefix) && memcache.ValidateKey(entry.Key) == nil {
+			keys = append(keys, entry.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// metadump calls fn with the metadata of every item stored on the given backend as it's dumped, until fn fails, in
+// which case it returns the error of fn. fn isn't called anymore once metadump returned, ctx having ended.
+func (c *memcachedClient) metadump(ctx context.Context, be *netpkg.Backend, fn func(entry memcache.MetadumpEntry) error) error {
+	encoder := memcache.CreateLruCrawlerMetadumpEncoder()
+	decoder := memcache.CreateLruCrawlerMetadumpDecoder()
+
+	var mu sync.Mutex
+	returned := false // protected by mu
+	decoder.OnEntry = func(entry memcache.MetadumpEntry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if returned {
+			return errMetadumpAbandoned
+		}
+		return fn(entry)
+	}
+	err := c.appendAdmin(ctx, be, encoder, decoder)
+	mu.Lock()
+	returned = true
+	mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if decoder.HdrLine != "" {
+		return fmt.Errorf("backend %s refused to dump its keys: %q", be.String(), decoder.HdrLine)
+	}
+	return decoder.Err
+}
+
+// ScanItems calls fn with the metadata of every item stored on every backend, as reported by lru_crawler metadump.
+// It stops at the first error returned by fn. Like DeleteByPrefix, it's meant for operational tooling. The dumps are
+// sent on the admin connections of the backends, so a long walk doesn't delay the data requests. fn is called as the
+// items are dumped, so the dumps aren't held in memory, and the reads of the admin connection wait for it: it must not
+// send commands on the admin connections itself, e.g. ServerStats, which would only be answered after the dump.
+func (c *memcachedClient) ScanItems(ctx context.Context, fn func(backend string, entry memcache.MetadumpEntry) error) error {
+	for _, be := range c.pool.Backends() {
+		var fnErr error
+		err := c.metadump(ctx, be, func(entry memcache.MetadumpEntry) error {
+			fnErr = fn(be.String(), entry)
+			return fnErr
+		})
+		if fnErr != nil {
+			return fnErr
+		}
+		if err != nil {
+			return fmt.Errorf("ScanItems operation failed: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *memcachedClient) deleteFrom(ctx context.Context, be *netpkg.Backend, key string) (memcache.MetadataStatus, error) {
+	encoder := deleteEncoderPool.Get()
+	decoder := deleteDecoderPool.Get()
+	defer pools.Release(ctx, deleteEncoderPool, encoder, deleteDecoderPool, decoder)
+
+	encoder.Key = key
+	c.assignOpaque(ctx, &encoder.Opaque)
+	if err := c.appendTo(ctx, be, encoder, decoder); err != nil {
+		return memcache.MetadataStatusInvalid, err
+	}
+	if c.invalidations != nil {
+		c.invalidations.observe(encoder, decoder)
+	}
+	return decoder.Status, nil
+}
+
+// ServerStats returns the statistics of the given group ("" for the general purpose ones, "slabs", "items", ...)
+// reported by every backend, keyed by backend address. They're requested on the admin connections of the backends.
+func (c *memcachedClient) ServerStats(ctx context.Context, group string) (map[string]map[string]string, error) {
+	stats := make(map[string]map[string]string)
+	for _, be := range c.pool.Backends() {
+		encoder := memcache.CreateStatsEncoder()
+		decoder := memcache.CreateStatsDecoder()
+		encoder.Group = group
+
+		if err := c.appendAdmin(ctx, be, encoder, decoder); err != nil {
+			return nil, fmt.Errorf("ServerStats operation failed: %w", err)
+		}
+		if decoder.HdrLine != "" {
+			return nil, fmt.Errorf("ServerStats operation failed: backend %s refused stats %q: %q", be.String(), group, decoder.HdrLine)
+		}
+		stats[be.String()] = decoder.Stats
+	}
+	return stats, nil
+}
+
+// Raw sends request, one or more complete commands of the text protocol along with their data blocks, to the given
+// backend as is and returns its response, for the commands without a codec of their own. The request is followed by
+// an mn command marking the end of the response, which is everything the backend answered before it, errors such as
+// "ERROR\r\n" included; it requires the meta protocol. It's sent on a connection opened for it alone and closed
+// afterwards, so a command answered in an unexpected way can't corrupt the pipelined connections.
+func (c *memcachedClient) Raw(ctx context.Context, backend string, request []byte) ([]byte, error) {
+	var be *netpkg.Backend
+	for _, candidate := range c.pool.Backends() {
+		if candidate.String() == backend {
+			be = candidate
+			break
+		}
+	}
+	if be == nil {
+		return nil, fmt.Errorf("Raw operation failed: unknown backend %q", backend)
+	}
+
+	encoder := memcache.CreateRawEncoder()
+	decoder := memcache.CreateRawDecoder()
+	encoder.Request = request
+	if err := encoder.Validate(); err != nil {
+		return nil, fmt.Errorf("Raw operation failed: %w", err)
+	}
+
+	appendIsolated := func(be *netpkg.Backend, link codec.Link) error {
+		return c.pool.AppendIsolated(ctx, be, link)
+	}
+	if err := c.appendVia(ctx, appendIsolated, be, encoder, decoder); err != nil {
+		return nil, fmt.Errorf("Raw operation failed: %w", err)
+	}
+	return decoder.Response, nil
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+func TestDeleteByPrefix(t *testing.T) {
+	srv1, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv1.Close() //nolint: errcheck
+	srv2, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv2.Close() //nolint: errcheck
+
+	mc, err := NewClient([]string{srv1.Addr().String(), srv2.Addr().String()}, 1)
+	require.NoError(t, err)
+	defer mc.Close() //nolint: errcheck
+
+	srv1.Set("tenant:a", []byte("1"), 0)
+	srv1.Set("other:a", []byte("1"), 0)
+	srv2.Set("tenant:b", []byte("1"), 0)
+	srv2.Set("tenant:c", []byte("1"), 0)
+
+	start := time.Now()
+	deleted, err := mc.DeleteByPrefix(context.Background(), "tenant:", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	_, ok := srv1.Get("tenant:a")
+	assert.False(t, ok)
+	_, ok = srv2.Get("tenant:b")
+	assert.False(t, ok)
+	_, ok = srv2.Get("tenant:c")
+	assert.False(t, ok)
+	_, ok = srv1.Get("other:a")
+	assert.True(t, ok)
+}
+
+func TestDeleteByPrefixRejectsEmptyPrefix(t *testing.T) {
+	mc, srv := newTestClient(t)
+
+	_, err := mc.DeleteByPrefix(context.Background(), "", 0)
+	assert.Error(t, err)
+	assert.Zero(t, srv.CommandCount("lru_crawler"))
+}
+
+func TestScanItemsStopsAtTheFirstError(t *testing.T) {
+	mc, srv := newTestClient(t)
+	srv.Set("a", []byte("1"), 0)
+	srv.Set("b", []byte("1"), 0)
+
+	calls := 0
+	err := mc.ScanItems(context.Background(), func(_ string, _ memcache.MetadumpEntry) error {
+		calls++
+		return assert.AnError
+	})
+	assert.Equal(t, assert.AnError, err)
+	assert.Equal(t, 1, calls)
+
+	// the rest of the dump was read, the admin connection answers the next command.
+	keys := 0
+	require.NoError(t, mc.ScanItems(context.Background(), func(_ string, _ memcache.MetadumpEntry) error {
+		keys++
+		return nil
+	}))
+	assert.Equal(t, 2, keys)
+}
+
+func TestServerStatsDoesNotBlockDataRequests(t *testing.T) {
+	mc, srv := newTestClient(t)
+	srv.SetStats("", map[string]string{"pid": "1"})
+	srv.Set("k", []byte("v"), 0)
+	srv.SetLatency("stats", 500*time.Millisecond)
+	// the capability probe may have sent stats settings already.
+	probes := srv.CommandCount("stats")
+
+	done := make(chan error, 1)
+	go func() {
+		stats, err := mc.ServerStats(context.Background(), "")
+		if err == nil && stats[srv.Addr().String()]["pid"] != "1" {
+			err = assert.AnError
+		}
+		done <- err
+	}()
+
+	// wait for the stats request to be in flight on the admin connection.
+	require.Eventually(t, func() bool { return srv.CommandCount("stats") > probes }, time.Second, time.Millisecond)
+
+	start := time.Now()
+	result, err := mc.GetWithTTL(context.Background(), "k")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v"), result.Value)
+	assert.Less(t, time.Since(start), 250*time.Millisecond)
+
+	require.NoError(t, <-done)
+}
+
+func TestRaw(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+	backend := srv.Addr().String()
+
+	response, err := mc.Raw(ctx, backend, []byte("ms k 2\r\nhi\r\nmg k v\r\nbogus\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "HD\r\nVA 2\r\nhi\r\nERROR\r\n", string(response))
+
+	// a request whose data block is shorter than announced swallows the mn, only its own connection is left waiting.
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = mc.Raw(short, backend, []byte("ms other 10\r\nhi\r\n"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	value, _, err := mc.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hi"), value)
+
+	_, err = mc.Raw(ctx, backend, []byte("version"))
+	assert.ErrorIs(t, err, memcache.ErrInvalidRequest)
+	_, err = mc.Raw(ctx, "127.0.0.1:1", []byte("version\r\n"))
+	assert.Error(t, err)
+}
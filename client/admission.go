@@ -0,0 +1,151 @@
+package client
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// AdmissionPriority decides which requests WithAdmissionControl sheds while the client is overloaded.
+type AdmissionPriority int
+
+const (
+	// PrioritizeReads sheds the writes, i.e. sets, deletes and arithmetic requests, keeping the cache serving.
+	PrioritizeReads AdmissionPriority = iota
+	// PrioritizeWrites sheds the reads, keeping the cache from going stale.
+	PrioritizeWrites
+)
+
+// AdmissionConfig configures WithAdmissionControl.
+type AdmissionConfig struct {
+	// MaxInFlight is the number of requests waiting for their response above which the client is overloaded, without
+	// limit if 0.
+	MaxInFlight int
+	// MaxLatency is the moving average of the latency of the requests above which the client is overloaded, without
+	// limit if 0.
+	MaxLatency time.Duration
+	// ShedFraction is the fraction of the requests of the lower priority which are shed while the client is
+	// overloaded, from 0 to 1.
+	ShedFraction float64
+	Prioritize   AdmissionPriority
+}
+
+// OverloadError fails the requests shed by WithAdmissionControl, and wraps ErrOverloaded.
+type OverloadError struct {
+	Class OperationClass
+	// InFlight is the number of requests which were waiting for their response.
+	InFlight int64
+	// Latency is the moving average of the latency of the requests.
+	Latency time.Duration
+}
+
+func (e *OverloadError) Error() string {
+	return fmt.Sprintf("%s request shed [in_flight=%d] [latency=%s]: %s", e.Class, e.InFlight, e.Latency, ErrOverloaded)
+}
+
+func (e *OverloadError) Unwrap() error {
+	return ErrOverloaded
+}
+
+// WithAdmissionControl sheds config.ShedFraction of the requests of the lower priority while the client is overloaded,
+// i.e. while more than config.MaxInFlight requests are waiting for their response or while their average latency
+// exceeds config.MaxLatency, failing them with an OverloadError without sending them, so that the latency of the
+// others stays bounded instead of growing with the queues. The requests which are neither reads nor writes, e.g.
+// admin commands, aren't shed.
+func WithAdmissionControl(config AdmissionConfig) ClientOption {
+	return func(c *memcachedClient) {
+		c.admission = &admissionController{config: config}
+	}
+}
+
+// AdmissionStats are the counters of WithAdmissionControl.
+type AdmissionStats struct {
+	// InFlight is the number of requests waiting for their response.
+	InFlight int64
+	// Latency is the moving average of the latency of the requests.
+	Latency time.Duration
+	// OverloadedRequests is the number of requests which found the client overloaded.
+	OverloadedRequests uint64
+	// Shed is the number of requests shed.
+	Shed uint64
+}
+
+// latencyWeight is the weight of the latest request in the moving average of the latency.
+const latencyWeight = 0.1
+
+type admissionController struct {
+	config AdmissionConfig
+
+	inFlight atomic.Int64
+	// latency is the moving average of the latency of the requests, in nanoseconds.
+	latency atomic.Int64
+	// lowPriority counts the requests of the lower priority which found the client overloaded, to shed the fraction
+	// of them.
+	lowPriority atomic.Uint64
+
+	overloaded atomic.Uint64
+	shed       atomic.Uint64
+}
+
+func (a *admissionController) snapshot() *AdmissionStats {
+	return &AdmissionStats{
+		InFlight:           a.inFlight.Load(),
+		Latency:            time.Duration(a.latency.Load()),
+		OverloadedRequests: a.overloaded.Load(),
+		Shed:               a.shed.Load(),
+	}
+}
+
+// admit returns an OverloadError if the request e is to be shed, otherwise it counts it in flight until done is
+// called with its latency.
+func (a *admissionController) admit(e codec.LinkEncoder) error {
+	inFlight, latency := a.inFlight.Load(), time.Duration(a.latency.Load())
+	overloaded := (a.config.MaxInFlight > 0 && inFlight >= int64(a.config.MaxInFlight)) ||
+		(a.config.MaxLatency > 0 && latency > a.config.MaxLatency)
+	if overloaded {
+		a.overloaded.Add(1)
+		class := classify(e)
+		if a.lowerPriority(class) && a.shedNext() {
+			a.shed.Add(1)
+			return &OverloadError{Class: class, InFlight: inFlight, Latency: latency}
+		}
+	}
+	a.inFlight.Add(1)
+	return nil
+}
+
+// lowerPriority reports whether the requests of class are the ones shed.
+func (a *admissionController) lowerPriority(class OperationClass) bool {
+	switch class {
+	case GetClass:
+		return a.config.Prioritize == PrioritizeWrites
+	case SetClass, DeleteClass, ArithmeticClass:
+		return a.config.Prioritize == PrioritizeReads
+	default:
+		return false
+	}
+}
+
+// shedNext reports whether the next request of the lower priority is shed, spreading ShedFraction of them evenly.
+func (a *admissionController) shedNext() bool {
+	n := a.lowPriority.Add(1)
+	fraction := min(max(a.config.ShedFraction, 0), 1)
+	return uint64(float64(n)*fraction) != uint64(float64(n-1)*fraction)
+}
+
+// done records the latency of a request admitted by admit.
+func (a *admissionController) done(latency time.Duration) {
+	a.inFlight.Add(-1)
+	for {
+		old := a.latency.Load()
+		next := int64(latency)
+		if old != 0 {
+			next = old + int64(latencyWeight*float64(int64(latency)-old))
+		}
+		if a.latency.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+func TestAdmissionController(t *testing.T) {
+	get, set, version := &memcache.MetaGetEncoder{}, &memcache.MetaSetEncoder{}, memcache.CreateVersionEncoder()
+
+	for _, tt := range []struct {
+		name   string
+		config AdmissionConfig
+		shed   []codec.LinkEncoder
+		kept   []codec.LinkEncoder
+	}{
+		{
+			name:   "writes",
+			config: AdmissionConfig{MaxInFlight: 2, ShedFraction: 1, Prioritize: PrioritizeReads},
+			shed:   []codec.LinkEncoder{set, &memcache.MetaDeleteEncoder{}, &memcache.MetaArithmeticEncoder{}},
+			kept:   []codec.LinkEncoder{get, version},
+		},
+		{
+			name:   "reads",
+			config: AdmissionConfig{MaxInFlight: 2, ShedFraction: 1, Prioritize: PrioritizeWrites},
+			shed:   []codec.LinkEncoder{get, &memcache.BulkEncoder[*memcache.MetaGetEncoder]{}},
+			kept:   []codec.LinkEncoder{set, version},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &admissionController{config: tt.config}
+			require.NoError(t, a.admit(set))
+			require.NoError(t, a.admit(get))
+
+			for _, e := range tt.shed {
+				err := a.admit(e)
+				var overload *OverloadError
+				require.ErrorAs(t, err, &overload)
+				assert.ErrorIs(t, err, ErrOverloaded)
+				assert.Equal(t, int64(2), overload.InFlight)
+			}
+			for _, e := range tt.kept {
+				assert.NoError(t, a.admit(e))
+			}
+			for range 2 + len(tt.kept) {
+				a.done(time.Millisecond)
+			}
+
+			// the requests are admitted again once the load went down.
+			for _, e := range tt.shed {
+				require.NoError(t, a.admit(e))
+				a.done(time.Millisecond)
+			}
+			stats := a.snapshot()
+			assert.Equal(t, uint64(len(tt.shed)), stats.Shed)
+			assert.Equal(t, uint64(len(tt.shed)+len(tt.kept)), stats.OverloadedRequests)
+			assert.Zero(t, stats.InFlight)
+			assert.Equal(t, time.Millisecond, stats.Latency)
+		})
+	}
+}
+
+func TestAdmissionShedFraction(t *testing.T) {
+	a := &admissionController{config: AdmissionConfig{MaxLatency: time.Millisecond, ShedFraction: 0.25}}
+	a.latency.Store(int64(time.Second))
+
+	shed := 0
+	for range 100 {
+		if err := a.admit(&memcache.MetaSetEncoder{}); err != nil {
+			shed++
+		}
+	}
+	assert.Equal(t, 25, shed)
+}
+
+func TestAdmissionControl(t *testing.T) {
+	mc, srv := newTestClient(t, WithAdmissionControl(AdmissionConfig{
+		MaxLatency:   5 * time.Millisecond,
+		ShedFraction: 1,
+		Prioritize:   PrioritizeReads,
+	}))
+	ctx := context.Background()
+
+	require.NoError(t, mc.Set(ctx, "k", []byte("v"), 0))
+	srv.SetLatency("mg", 100*time.Millisecond)
+	_, _, err := mc.Get(ctx, "k")
+	require.NoError(t, err)
+
+	// the reads made the latency go over the limit, the writes are shed.
+	err = mc.Set(ctx, "k", []byte("w"), 0)
+	var overload *OverloadError
+	require.True(t, errors.As(err, &overload))
+	assert.Equal(t, SetClass, overload.Class)
+	assert.Greater(t, overload.Latency, 5*time.Millisecond)
+	value, _, err := mc.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v"), value)
+
+	stats := mc.Stats()
+	assert.Equal(t, uint64(1), stats.Admission.Shed)
+	var b strings.Builder
+	require.NoError(t, stats.WritePrometheus(&b))
+	assert.Contains(t, b.String(), "memlink_admission_shed_total 1\n")
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/pools"
+)
+
+// NoVivify can be passed as the vivifyTTL of AppendValue and PrependValue to fail with ErrNotStored instead of
+// creating a missing item.
+const NoVivify int32 = -1
+
+// AppendValue appends value to the item stored under key. When the item doesn't exist, it's created with value and a
+// TTL of vivifyTTL seconds (0 means no expiry), unless vivifyTTL is NoVivify.
+func (c *memcachedClient) AppendValue(ctx context.Context, key string, value []byte, vivifyTTL int32) error {
+	if err := c.concat(ctx, memcache.Append, key, value, vivifyTTL); err != nil {
+		return fmt.Errorf("AppendValue operation failed: %w", err)
+	}
+	return nil
+}
+
+// PrependValue is like AppendValue but puts value in front of the stored item.
+func (c *memcachedClient) PrependValue(ctx context.Context, key string, value []byte, vivifyTTL int32) error {
+	if c.softTTL > 0 {
+		return fmt.Errorf("PrependValue operation failed: %w", ErrSoftTTLPrepend)
+	}
+	if err := c.concat(ctx, memcache.Prepend, key, value, vivifyTTL); err != nil {
+		return fmt.Errorf("PrependValue operation failed: %w", err)
+	}
+	return nil
+}
+
+func (c *memcachedClient) concat(ctx context.Context, mode memcache.MetaSetMode, key string, value []byte, vivifyTTL int32) error {
+	if len(value) > c.maxValueSize {
+		return fmt.Errorf("key=%q size=%d max=%d: %w", key, len(value), c.maxValueSize, ErrValueTooLarge)
+	}
+
+	encoder := setEncoderPool.Get()
+	decoder := setDecoderPool.Get()
+	defer pools.Release(ctx, setEncoderPool, encoder, setDecoderPool, decoder)
+
+	encoder.Key = key
+	encoder.Value = value
+	encoder.Mode = mode
+	c.assignOpaque(ctx, &encoder.Opaque)
+	// the item TTL is only used when vivifying and N takes precedence, so leave it unset.
+	if vivifyTTL != NoVivify {
+		encoder.BlockTTL = vivifyTTL
+	}
+	c.prepareSet(encoder)
+
+	if err := c.append(ctx, encoder, decoder); err != nil {
+		return err
+	}
+
+	return statusErr(key, mode, decoder.Status, decoder.HdrLine)
+}
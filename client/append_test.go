@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendPrependValue(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+
+	require.NoError(t, mc.AppendValue(ctx, "list", []byte("b"), 0))
+	require.NoError(t, mc.AppendValue(ctx, "list", []byte("c"), 0))
+	require.NoError(t, mc.PrependValue(ctx, "list", []byte("a"), 0))
+
+	value, ok := srv.Get("list")
+	assert.True(t, ok)
+	assert.Equal(t, "abc", string(value))
+}
+
+func TestAppendValueNoVivify(t *testing.T) {
+	mc, srv := newTestClient(t)
+
+	err := mc.AppendValue(context.Background(), "missing", []byte("a"), NoVivify)
+	assert.ErrorIs(t, err, ErrNotStored)
+
+	err = mc.PrependValue(context.Background(), "missing", []byte("a"), NoVivify)
+	assert.ErrorIs(t, err, ErrNotStored)
+
+	_, ok := srv.Get("missing")
+	assert.False(t, ok)
+}
+
+func TestAppendValueTooLarge(t *testing.T) {
+	mc, srv := newTestClient(t, WithMaxValueSize(4))
+
+	err := mc.AppendValue(context.Background(), "key", []byte("abcde"), 0)
+	assert.ErrorIs(t, err, ErrValueTooLarge)
+	assert.Zero(t, srv.CommandCount("ms"))
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/pools"
+)
+
+// ArithmeticOptions configures Incr and Decr. The zero value fails with ErrNotFound when the counter doesn't exist.
+type ArithmeticOptions struct {
+	// Vivify creates a missing counter with InitialValue, instead of failing with ErrNotFound. The delta isn't applied
+	// to a created counter.
+	Vivify       bool
+	InitialValue uint64
+	// VivifyTTL is the TTL in seconds of a created counter, 0 meaning it never expires.
+	VivifyTTL int32
+}
+
+// Incr adds delta to the counter stored under key and returns its new value. memcached wraps counters around at
+// 2^64.
+func (c *memcachedClient) Incr(ctx context.Context, key string, delta uint64, opts ArithmeticOptions) (uint64, error) {
+	value, err := c.arithmetic(ctx, key, delta, false, opts)
+	if err != nil {
+		return 0, fmt.Errorf("Incr operation failed: %w", err)
+	}
+	return value, nil
+}
+
+// Decr subtracts delta from the counter stored under key and returns its new value. memcached doesn't decrement
+// counters below 0.
+func (c *memcachedClient) Decr(ctx context.Context, key string, delta uint64, opts ArithmeticOptions) (uint64, error) {
+	value, err := c.arithmetic(ctx, key, delta, true, opts)
+	if err != nil {
+		return 0, fmt.Errorf("Decr operation failed: %w", err)
+	}
+	return value, nil
+}
+
+func (c *memcachedClient) arithmetic(ctx context.Context, key string, delta uint64, decrement bool, opts ArithmeticOptions) (uint64, error) {
+	encoder := arithmeticEncoderPool.Get()
+	decoder := arithmeticDecoderPool.Get()
+	defer pools.Release(ctx, arithmeticEncoderPool, encoder, arithmeticDecoderPool, decoder)
+
+	encoder.Key = key
+	encoder.Delta = delta
+	encoder.Decrement = decrement
+	encoder.FetchValue = true
+	if opts.Vivify {
+		encoder.InitialValue = opts.InitialValue
+		encoder.BlockTTL = max(opts.VivifyTTL, 0)
+		if c.ttlPolicy != nil {
+			encoder.BlockTTL = c.ttlPolicy(key, encoder.BlockTTL)
+		}
+	}
+	c.assignOpaque(ctx, &encoder.Opaque)
+
+	if err := c.append(ctx, encoder, decoder); err != nil {
+		return 0, err
+	}
+
+	switch decoder.Status {
+	case memcache.Stored:
+		return decoder.ValueUInt64, nil
+	case memcache.NotFound:
+		return 0, fmt.Errorf("key=%q: %w", key, ErrNotFound)
+	default:
+		return 0, fmt.Errorf("unexpected status for key=%q: %s %q", key, decoder.Status, decoder.HdrLine)
+	}
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncrDecr(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := mc.Incr(ctx, "counter", 1, ArithmeticOptions{})
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = mc.Decr(ctx, "counter", 1, ArithmeticOptions{})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// a created counter holds the initial value, without the delta.
+	value, err := mc.Incr(ctx, "counter", 5, ArithmeticOptions{Vivify: true, InitialValue: 10, VivifyTTL: 60})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(10), value)
+	_, item, err := mc.Get(ctx, "counter")
+	require.NoError(t, err)
+	assert.InDelta(t, 60, item.TTL, 1)
+
+	value, err = mc.Incr(ctx, "counter", 5, ArithmeticOptions{Vivify: true, InitialValue: 10})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(15), value)
+
+	value, err = mc.Decr(ctx, "counter", 3, ArithmeticOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(12), value)
+
+	// counters aren't decremented below 0.
+	value, err = mc.Decr(ctx, "counter", 100, ArithmeticOptions{})
+	require.NoError(t, err)
+	assert.Zero(t, value)
+
+	stored, ok := srv.Get("counter")
+	require.True(t, ok)
+	assert.Equal(t, []byte("0"), stored)
+}
+
+func TestNamespacedIncr(t *testing.T) {
+	mc, srv := newTestClient(t)
+	billing := mc.WithNamespace("billing", WithNamespaceTTLPolicy(ClampTTL(1, 30)))
+	ctx := context.Background()
+
+	value, err := billing.Incr(ctx, "counter", 1, ArithmeticOptions{Vivify: true, InitialValue: 1})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), value)
+	_, ok := srv.Get("billing:counter")
+	assert.True(t, ok)
+
+	// the TTL policy of the namespace applies to the created counter.
+	_, item, err := billing.Get(ctx, "counter")
+	require.NoError(t, err)
+	assert.InDelta(t, 30, item.TTL, 1)
+}
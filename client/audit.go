@@ -0,0 +1,342 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/andrew-d/csmrand"
+	"go.uber.org/zap"
+
+	"github.com/stripe/memlink/codec"
+	netpkg "github.com/stripe/memlink/internal/net"
+)
+
+const (
+	// auditQueueSize bounds the records waiting to be written, the records beyond are dropped.
+	auditQueueSize = 10000
+	// maxAuditBatch bounds the number of records handed to the sink at once.
+	maxAuditBatch = 100
+)
+
+// AuditStatus is the outcome of an audited request.
+type AuditStatus string
+
+const (
+	AuditOK       AuditStatus = "ok"
+	AuditError    AuditStatus = "error"
+	AuditCanceled AuditStatus = "canceled"
+	AuditTimeout  AuditStatus = "timeout"
+)
+
+// AuditRecord describes a request sent by the client. It never carries a key or a value.
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"op"`
+	// KeyHash identifies the key of the request by its length and hash, empty if the request doesn't target a single
+	// key.
+	KeyHash string        `json:"key_hash,omitempty"`
+	Status  AuditStatus   `json:"status"`
+	Latency time.Duration `json:"latency_ns"`
+	// Backend is the address of the backend the request was sent to, empty if it wasn't sent.
+	Backend string `json:"backend,omitempty"`
+}
+
+// AuditSink stores audit records, e.g. in a file or a log pipeline.
+type AuditSink interface {
+	// Write stores records, in the order the requests completed. It's called by a single routine of the client, the
+	// records failing to be written are counted and not retried.
+	Write(records []AuditRecord) error
+}
+
+// AuditSinkFunc adapts a function to an AuditSink.
+type AuditSinkFunc func(records []AuditRecord) error
+
+func (f AuditSinkFunc) Write(records []AuditRecord) error {
+	return f(records)
+}
+
+// WithAuditLog records a sampleRate fraction of the requests sent by the client to sink: their operation, the hash of
+// their key, their outcome, latency and backend. Records are written in the background so the sink doesn't add to the
+// latency of the requests: when it falls behind by more than 10000 records, the records beyond are dropped and counted
+// in Stats. The records still queued are written when the client is closed, and sink is closed then if it's an
+// io.Closer.
+func WithAuditLog(sink AuditSink, sampleRate float64) ClientOption {
+	return func(c *memcachedClient) {
+		c.audit = &auditLogger{
+			sink:       sink,
+			sampleRate: sampleRate,
+			records:    make(chan AuditRecord, auditQueueSize),
+			done:       make(chan struct{}),
+		}
+	}
+}
+
+// AuditStats are the counters of the audit log of a client.
+type AuditStats struct {
+	// Written is the number of records the sink stored.
+	Written uint64
+	// Dropped is the number of records dropped because the sink fell behind.
+	Dropped uint64
+	// Errors is the number of records the sink failed to store.
+	Errors uint64
+}
+
+type auditLogger struct {
+	sink       AuditSink
+	sampleRate float64
+	records    chan AuditRecord
+	done       chan struct{}
+	logger     *zap.Logger
+
+	mu     sync.RWMutex
+	closed bool // protected by mu, records is closed once set
+
+	written atomic.Uint64
+	dropped atomic.Uint64
+	errors  atomic.Uint64
+}
+
+func (a *auditLogger) start(logger *zap.Logger) {
+	a.logger = logger
+	go a.run()
+}
+
+func (a *auditLogger) run() {
+	defer close(a.done)
+
+	batch := make([]AuditRecord, 0, maxAuditBatch)
+	for record := range a.records {
+		batch = append(batch[:0], record)
+	drain:
+		for len(batch) < maxAuditBatch {
+			select {
+			case record, ok := <-a.records:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, record)
+			default:
+				break drain
+			}
+		}
+		a.write(batch)
+	}
+}
+
+func (a *auditLogger) write(batch []AuditRecord) {
+	if err := a.sink.Write(batch); err != nil {
+		a.errors.Add(uint64(len(batch)))
+		a.logger.Warn("failed to write audit records", zap.Int("records", len(batch)), zap.Error(err))
+		return
+	}
+	a.written.Add(uint64(len(batch)))
+}
+
+// close writes the records still queued, stops the writing routine and closes the sink.
+func (a *auditLogger) close() {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.records)
+	}
+	a.mu.Unlock()
+	<-a.done
+
+	if closer, ok := a.sink.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			a.logger.Warn("failed to close the audit sink", zap.Error(err))
+		}
+	}
+}
+
+// observe queues the record of a sampled request.
+func (a *auditLogger) observe(e codec.LinkEncoder, backend string, start time.Time, latency time.Duration, err error) {
+	if a.sampleRate < 1 && csmrand.Float64() >= a.sampleRate {
+		return
+	}
+
+	record := AuditRecord{
+		Time:      start,
+		Operation: operationName(e),
+		Status:    auditStatus(err),
+		Latency:   latency,
+		Backend:   backend,
+	}
+	if keyer, ok := e.(codec.RoutingKeyer); ok {
+		record.KeyHash = netpkg.RedactKey(keyer.RoutingKey())
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		a.dropped.Add(1)
+		return
+	}
+	select {
+	case a.records <- record:
+	default:
+		a.dropped.Add(1)
+	}
+}
+
+func auditStatus(err error) AuditStatus {
+	switch {
+	case err == nil:
+		return AuditOK
+	case errors.Is(err, context.DeadlineExceeded):
+		return AuditTimeout
+	case errors.Is(err, context.Canceled):
+		return AuditCanceled
+	default:
+		return AuditError
+	}
+}
+
+func (a *auditLogger) snapshot() *AuditStats {
+	return &AuditStats{
+		Written: a.written.Load(),
+		Dropped: a.dropped.Load(),
+		Errors:  a.errors.Load(),
+	}
+}
+
+// NewAuditWriterSink writes the records to w as JSON lines.
+func NewAuditWriterSink(w io.Writer) AuditSink {
+	return &auditWriterSink{w: w}
+}
+
+type auditWriterSink struct {
+	w io.Writer
+}
+
+func (s *auditWriterSink) Write(records []AuditRecord) error {
+	return writeAuditRecords(s.w, records)
+}
+
+func writeAuditRecords(w io.Writer, records []AuditRecord) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// AuditFileSink writes the records to a file as JSON lines, rotating it once it exceeds a size: the file is renamed
+// with a ".1" suffix, the previous rotations shifted to ".2" and so on, and the oldest removed.
+type AuditFileSink struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File // protected by mu
+	size int64    // protected by mu
+}
+
+var _ AuditSink = (*AuditFileSink)(nil)
+
+// NewAuditFileSink appends the records to the file at path, rotating it once it exceeds maxBytes and keeping
+// maxBackups rotated files. Non-positive sizes don't rotate the file.
+func NewAuditFileSink(path string, maxBytes int64, maxBackups int) (*AuditFileSink, error) {
+	s := &AuditFileSink{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *AuditFileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log %s: %w", s.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat audit log %s: %w", s.path, err)
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+func (s *AuditFileSink) Write(records []AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	if s.maxBytes > 0 && s.size >= s.maxBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	w := &countingWriter{w: s.file}
+	err := writeAuditRecords(w, records)
+	s.size += w.n
+	return err
+}
+
+// rotate shifts the rotated files by one and moves the current file in their place.
+func (s *AuditFileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+
+	if s.maxBackups <= 0 {
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return s.open()
+	}
+	for i := s.maxBackups - 1; i > 0; i-- {
+		if err := os.Rename(s.backup(i), s.backup(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(s.path, s.backup(1)); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *AuditFileSink) backup(i int) string {
+	return fmt.Sprintf("%s.%d", s.path, i)
+}
+
+// Close closes the file, the records written afterwards fail.
+func (s *AuditFileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+func TestAuditLog(t *testing.T) {
+	var mu sync.Mutex
+	var records []AuditRecord
+	sink := AuditSinkFunc(func(batch []AuditRecord) error {
+		mu.Lock()
+		defer mu.Unlock()
+		records = append(records, batch...)
+		return nil
+	})
+	mc, srv := newTestClient(t, WithAuditLog(sink, 1))
+	srv.Set("secret", []byte("1"), 0)
+
+	get := memcache.CreateMetaGetEncoder()
+	get.Reset()
+	get.Key = "secret"
+	require.NoError(t, mc.MetaGet(context.Background(), get, memcache.CreateMetaGetDecoder()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, mc.MetaGet(ctx, get, memcache.CreateMetaGetDecoder()))
+
+	require.NoError(t, mc.Close())
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, records, 2)
+	assert.Equal(t, "mg", records[0].Operation)
+	assert.Equal(t, AuditOK, records[0].Status)
+	assert.Equal(t, srv.Addr().String(), records[0].Backend)
+	assert.NotEmpty(t, records[0].KeyHash)
+	assert.NotContains(t, records[0].KeyHash, "secret")
+	assert.Equal(t, AuditCanceled, records[1].Status)
+
+	stats := mc.Stats()
+	require.NotNil(t, stats.Audit)
+	assert.Equal(t, uint64(2), stats.Audit.Written)
+	var b strings.Builder
+	require.NoError(t, stats.WritePrometheus(&b))
+	assert.Contains(t, b.String(), `memlink_audit_records_total{outcome="written"} 2`)
+}
+
+func TestAuditLogSampling(t *testing.T) {
+	sink := AuditSinkFunc(func(batch []AuditRecord) error {
+		return errors.New("unreachable sink")
+	})
+	mc, _ := newTestClient(t, WithAuditLog(sink, 0))
+
+	get := memcache.CreateMetaGetEncoder()
+	get.Reset()
+	get.Key = "a"
+	require.NoError(t, mc.MetaGet(context.Background(), get, memcache.CreateMetaGetDecoder()))
+
+	require.NoError(t, mc.Close())
+	assert.Equal(t, &AuditStats{}, mc.Stats().Audit)
+}
+
+func TestAuditWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewAuditWriterSink(&buf)
+	record := AuditRecord{
+		Time:      time.Unix(1, 0).UTC(),
+		Operation: "ms",
+		KeyHash:   "len=1 fnv=af63bd4c",
+		Status:    AuditError,
+		Latency:   time.Millisecond,
+	}
+	require.NoError(t, sink.Write([]AuditRecord{record, record}))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var decoded AuditRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &decoded))
+	assert.Equal(t, record, decoded)
+	assert.NotContains(t, lines[0], "backend")
+}
+
+func TestAuditFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewAuditFileSink(path, 1, 2)
+	require.NoError(t, err)
+
+	for _, op := range []string{"mg", "ms", "md", "ma"} {
+		require.NoError(t, sink.Write([]AuditRecord{{Operation: op}}))
+	}
+	require.NoError(t, sink.Close())
+	assert.ErrorIs(t, sink.Write([]AuditRecord{{Operation: "mn"}}), os.ErrClosed)
+
+	for file, op := range map[string]string{path: "ma", path + ".1": "md", path + ".2": "ms"} {
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), `"op":"`+op+`"`, file)
+	}
+	_, err = os.Stat(path + ".3")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
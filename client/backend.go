@@ -0,0 +1,50 @@
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	netpkg "github.com/stripe/memlink/internal/net"
+)
+
+// BackendConfig configures the connections to a memcached server.
+type BackendConfig struct {
+	// Addr is the host:port address of the server.
+	Addr string
+	// NumConns is the number of connections opened to the server, at least 1.
+	NumConns int
+	// Weight is the share of the keys placed on the server relative to the other ones, at least 1: a server of weight 2
+	// is given twice as many keys as a server of weight 1. The placement of the keys can be checked with the
+	// placement package.
+	Weight int
+	// TLS enables TLS on the connections when set.
+	TLS *tls.Config
+}
+
+// NewClientFromBackends creates a client connected to the given backends, which are placed in the given order.
+// Unlike NewClient, every backend has its own number of connections and TLS configuration.
+func NewClientFromBackends(configs []BackendConfig, opts ...ClientOption) (MemcachedClient, error) {
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("at least one backend must be provided")
+	}
+
+	backends, err := resolveBackends(configs)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(backends, opts...)
+}
+
+// resolveBackends returns the backends of configs, in the same order.
+func resolveBackends(configs []BackendConfig) ([]*netpkg.Backend, error) {
+	backends := make([]*netpkg.Backend, 0, len(configs))
+	for _, config := range configs {
+		tcpAddr, err := net.ResolveTCPAddr("tcp", config.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %s: %w", config.Addr, err)
+		}
+		backends = append(backends, netpkg.NewWeightedBackend(tcpAddr, max(config.NumConns, 1), config.Weight, config.TLS))
+	}
+	return backends, nil
+}
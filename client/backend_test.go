@@ -0,0 +1,39 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+func TestNewClientFromBackends(t *testing.T) {
+	var configs []BackendConfig
+	for _, numConns := range []int{2, 0} {
+		srv, err := fakeserver.Start()
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = srv.Close() })
+		configs = append(configs, BackendConfig{Addr: srv.Addr().String(), NumConns: numConns})
+	}
+
+	mc, err := NewClientFromBackends(configs)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mc.Close() })
+
+	topology := mc.Topology()
+	require.Len(t, topology.Backends, 2)
+	assert.Equal(t, configs[0].Addr, topology.Backends[0].Addr)
+	assert.Equal(t, 2, topology.Backends[0].NumConns)
+	assert.Equal(t, configs[1].Addr, topology.Backends[1].Addr)
+	assert.Equal(t, 1, topology.Backends[1].NumConns)
+}
+
+func TestNewClientFromBackendsErrors(t *testing.T) {
+	_, err := NewClientFromBackends(nil)
+	assert.Error(t, err)
+
+	_, err = NewClientFromBackends([]BackendConfig{{Addr: "not an address"}})
+	assert.ErrorContains(t, err, "invalid address")
+}
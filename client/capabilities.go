@@ -0,0 +1,197 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+	netpkg "github.com/stripe/memlink/internal/net"
+	"go.uber.org/zap"
+)
+
+// capabilityDetectionTimeout bounds the probes sent to every backend when the client is created.
+const capabilityDetectionTimeout = 5 * time.Second
+
+// ErrMetaProtocolUnsupported is returned by NewClient when WithMetaProtocolRequired is set and a backend doesn't
+// understand the meta commands, e.g. a memcached older than 1.6 or a proxy which doesn't forward them.
+var ErrMetaProtocolUnsupported = errors.New("memcached: backend doesn't support the meta protocol")
+
+// Capabilities are the features supported by a backend, detected when the client is created.
+type Capabilities = netpkg.Capabilities
+
+// WithoutCapabilityDetection skips probing the backends when the client is created. The client then assumes every
+// backend speaks the meta protocol and only enforces the max value size set with WithMaxValueSize.
+func WithoutCapabilityDetection() ClientOption {
+	return func(c *memcachedClient) {
+		c.skipCapabilityDetection = true
+	}
+}
+
+// WithMetaProtocolRequired makes NewClient fail with ErrMetaProtocolUnsupported when a backend doesn't support the
+// meta protocol, instead of falling back to the classic protocol.
+func WithMetaProtocolRequired() ClientOption {
+	return func(c *memcachedClient) {
+		c.requireMetaProtocol = true
+	}
+}
+
+// WithStrictMetaFlags fails the requests using meta flags which the backend they're routed to doesn't support, e.g. E
+// on a memcached older than 1.6.13 which would ignore it and generate a CAS value, with an UnsupportedMetaFlagError
+// instead of sending them. The versions are the ones recorded on the backends with their capabilities, so it has no
+// effect with WithoutCapabilityDetection, and the backends which don't report a memcached version, e.g. proxies, or
+// whose capabilities weren't detected, aren't gated.
+func WithStrictMetaFlags() ClientOption {
+	return func(c *memcachedClient) {
+		c.strictMetaFlags = true
+	}
+}
+
+// UnsupportedMetaFlagError fails the requests rejected by WithStrictMetaFlags, and wraps ErrUnsupportedMetaFlag.
+type UnsupportedMetaFlagError struct {
+	Flags []memcache.MetaFlag
+	// Backend is the address of the backend the request was routed to, and Version its version.
+	Backend string
+	Version memcache.ServerVersion
+}
+
+func (e *UnsupportedMetaFlagError) Error() string {
+	flags := make([]string, len(e.Flags))
+	for i, flag := range e.Flags {
+		flags[i] = flag.String()
+	}
+	return fmt.Sprintf("%s [backend=%s version=%s]: %s", strings.Join(flags, ", "), e.Backend, e.Version, ErrUnsupportedMetaFlag)
+}
+
+func (e *UnsupportedMetaFlagError) Unwrap() error {
+	return ErrUnsupportedMetaFlag
+}
+
+// BackendCapabilities returns the capabilities detected for every backend, keyed by backend address. Backends which
+// weren't probed are missing.
+func (c *memcachedClient) BackendCapabilities() map[string]Capabilities {
+	capabilities := make(map[string]Capabilities)
+	for _, be := range c.pool.Backends() {
+		if caps, ok := be.Capabilities(); ok {
+			capabilities[be.String()] = caps
+		}
+	}
+	return capabilities
+}
+
+// BackendCompression returns the stream compression negotiated with every backend, keyed by backend address.
+// Backends which refused the compression set with WithCompression map to CompressionNone.
+func (c *memcachedClient) BackendCompression() map[string]Compression {
+	compression := make(map[string]Compression)
+	for _, be := range c.pool.Backends() {
+		compression[be.String()] = be.Compression()
+	}
+	return compression
+}
+
+// detectCapabilities probes every backend and records its capabilities on the backend. When a backend doesn't support
+// the meta protocol, every request is translated to the classic protocol, see memcache.ClassicCodec. Unless configured
+// explicitly, the max value size is lowered to the smallest item size limit of the backends.
+func (c *memcachedClient) detectCapabilities(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, capabilityDetectionTimeout)
+	defer cancel()
+
+	for _, be := range c.pool.Backends() {
+		caps, err := c.probe(ctx, be)
+		if err != nil {
+			return fmt.Errorf("failed to detect the capabilities of backend %s: %w", be.String(), err)
+		}
+		be.SetCapabilities(caps)
+
+		if !caps.MetaProtocol {
+			if c.requireMetaProtocol {
+				return fmt.Errorf("backend=%s version=%q: %w", be.String(), caps.Version, ErrMetaProtocolUnsupported)
+			}
+
+			// requests can be sent to any backend, so the whole client falls back rather than tracking the protocol per
+			// backend.
+			if !c.classic {
+				c.logger.Warn("backend doesn't support the meta protocol, falling back to the classic protocol",
+					zap.String("backend", be.String()), zap.String("version", caps.Version))
+			}
+			c.classic = true
+		}
+
+		if !c.maxValueSizeSet && caps.MaxItemSize > 0 {
+			c.maxValueSize = min(c.maxValueSize, caps.MaxItemSize)
+		}
+	}
+	return nil
+}
+
+func (c *memcachedClient) probe(ctx context.Context, be *netpkg.Backend) (Capabilities, error) {
+	caps := Capabilities{}
+
+	versionDecoder := memcache.CreateVersionDecoder()
+	if err := c.appendTo(ctx, be, memcache.CreateVersionEncoder(), versionDecoder); err != nil {
+		return caps, err
+	}
+	caps.Version = strings.TrimSpace(strings.TrimPrefix(versionDecoder.HdrLine, "VERSION"))
+
+	noOpDecoder := memcache.CreateMetaNoOpDecoder()
+	if err := c.appendTo(ctx, be, memcache.CreateMetaNoOpEncoder(), noOpDecoder); err != nil {
+		return caps, err
+	}
+	caps.MetaProtocol = noOpDecoder.HdrLine == ""
+	// base64 keys shipped with the meta protocol in 1.6, proxies reporting other versions are assumed not to
+	// support them.
+	caps.Base64Keys = caps.MetaProtocol && versionAtLeast(caps.Version, 1, 6)
+
+	statsEncoder := memcache.CreateStatsEncoder()
+	statsEncoder.Group = "settings"
+	statsDecoder := memcache.CreateStatsDecoder()
+	if err := c.appendTo(ctx, be, statsEncoder, statsDecoder); err != nil {
+		return caps, err
+	}
+	// some proxies don't implement stats settings, the item size limit stays unknown then.
+	if size, err := strconv.Atoi(statsDecoder.Stats["item_size_max"]); err == nil {
+		caps.MaxItemSize = size
+	}
+
+	return caps, nil
+}
+
+// gateMetaFlags returns an UnsupportedMetaFlagError if the request of link uses meta flags which be doesn't support.
+// It's the netpkg.LinkGate of the connections when WithStrictMetaFlags is set, so that every link is gated against the
+// backend it's routed to, including the backends added after the client was created.
+func (c *memcachedClient) gateMetaFlags(be *netpkg.Backend, link codec.Link) error {
+	// the classic protocol has no flags, the requests which can't be translated fail in memcache.ClassicCodec.
+	if c.classic {
+		return nil
+	}
+	version, ok := be.ServerVersion()
+	if !ok {
+		return nil
+	}
+	if flags := memcache.UnsupportedFlags(link.Encoder(), version); len(flags) > 0 {
+		return &UnsupportedMetaFlagError{Flags: flags, Backend: be.String(), Version: version}
+	}
+	return nil
+}
+
+// versionAtLeast reports whether a "major.minor.patch" version is at least major.minor.
+func versionAtLeast(version string, major, minor int) bool {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+
+	maj, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	mnr, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	return maj > major || (maj == major && mnr >= minor)
+}
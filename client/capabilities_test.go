@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/internal/fakeserver"
+	netpkg "github.com/stripe/memlink/internal/net"
+)
+
+func TestCapabilityDetection(t *testing.T) {
+	srv, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv.Close() //nolint: errcheck
+	srv.SetStats("settings", map[string]string{"item_size_max": "2048"})
+
+	mc, err := NewClient([]string{srv.Addr().String()}, 1)
+	require.NoError(t, err)
+	defer mc.Close() //nolint: errcheck
+
+	assert.Equal(t, map[string]Capabilities{
+		srv.Addr().String(): {Version: fakeserver.ServerVersion, MetaProtocol: true, Base64Keys: true, MaxItemSize: 2048},
+	}, mc.BackendCapabilities())
+	assert.Equal(t, 2048, mc.(*memcachedClient).maxValueSize)
+}
+
+func TestCapabilityDetectionKeepsExplicitMaxValueSize(t *testing.T) {
+	mc, _ := newTestClient(t, WithMaxValueSize(10*1024*1024))
+	assert.Equal(t, 10*1024*1024, mc.(*memcachedClient).maxValueSize)
+}
+
+func TestCapabilityDetectionFailsWithoutMetaProtocol(t *testing.T) {
+	srv, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv.Close() //nolint: errcheck
+	srv.DisableMetaProtocol()
+
+	_, err = NewClient([]string{srv.Addr().String()}, 1, WithMetaProtocolRequired())
+	assert.ErrorIs(t, err, ErrMetaProtocolUnsupported)
+
+	mc, err := NewClient([]string{srv.Addr().String()}, 1, WithoutCapabilityDetection())
+	require.NoError(t, err)
+	defer mc.Close() //nolint: errcheck
+	assert.Empty(t, mc.BackendCapabilities())
+}
+
+func TestCapabilityDetectionFailureLeaksNoRoutine(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	srv, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv.Close() //nolint: errcheck
+	srv.DisableMetaProtocol()
+
+	_, err = NewClient([]string{srv.Addr().String()}, 1, WithMetaProtocolRequired(),
+		WithInvalidationSink(&recordingSink{}),
+		WithAuditLog(AuditSinkFunc(func([]AuditRecord) error { return nil }), 1),
+		WithLatencyHistograms(time.Minute),
+		WithWriteReplay(10, time.Minute),
+		WithValueCompression(ValueCompressionZstd, 0),
+	)
+	assert.ErrorIs(t, err, ErrMetaProtocolUnsupported)
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected bool
+	}{
+		{version: "1.6.21", expected: true},
+		{version: "1.6", expected: true},
+		{version: "2.0.0", expected: true},
+		{version: "1.5.22", expected: false},
+		{version: "mcrouter", expected: false},
+		{version: "", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.version, func(t *testing.T) {
+			assert.Equal(t, test.expected, versionAtLeast(test.version, 1, 6))
+		})
+	}
+}
+
+func TestStrictMetaFlags(t *testing.T) {
+	srv, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv.Close() //nolint: errcheck
+	srv.SetVersion("1.6.9")
+
+	mc, err := NewClient([]string{srv.Addr().String()}, 1, WithStrictMetaFlags())
+	require.NoError(t, err)
+	defer mc.Close() //nolint: errcheck
+
+	encoder := memcache.CreateMetaSetEncoder()
+	encoder.Reset()
+	encoder.Key = "key"
+	encoder.Value = []byte("value")
+	encoder.CasOverride = 42
+	err = mc.MetaSet(context.Background(), encoder, memcache.CreateMetaSetDecoder())
+	require.ErrorIs(t, err, ErrUnsupportedMetaFlag)
+	var flagErr *UnsupportedMetaFlagError
+	require.ErrorAs(t, err, &flagErr)
+	assert.Equal(t, memcache.ServerVersion{Major: 1, Minor: 6, Patch: 9}, flagErr.Version)
+	assert.Equal(t, srv.Addr().String(), flagErr.Backend)
+	assert.Equal(t, []memcache.MetaFlag{{Command: "ms", Flag: 'E', Since: memcache.ServerVersion{Major: 1, Minor: 6, Patch: 13}}}, flagErr.Flags)
+	assert.Equal(t, 0, srv.CommandCount("ms"))
+
+	require.NoError(t, mc.Set(context.Background(), "key", []byte("value"), 0))
+	assert.Equal(t, 1, srv.CommandCount("ms"))
+}
+
+func TestStrictMetaFlagsAllowsSupportedVersions(t *testing.T) {
+	srv, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv.Close() //nolint: errcheck
+
+	for _, opts := range [][]ClientOption{
+		{WithStrictMetaFlags()},
+		{WithStrictMetaFlags(), WithoutCapabilityDetection()},
+	} {
+		mc, err := NewClient([]string{srv.Addr().String()}, 1, opts...)
+		require.NoError(t, err)
+		be := mc.(*memcachedClient).pool.Backends()[0]
+		link := codec.NewGenericLink(&memcache.MetaSetEncoder{Key: "key", CasOverride: 42}, nil)
+		assert.Nil(t, mc.(*memcachedClient).gateMetaFlags(be, link))
+		require.NoError(t, mc.Close())
+	}
+}
+
+func TestStrictMetaFlagsGatesEveryBackend(t *testing.T) {
+	recent, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer recent.Close() //nolint: errcheck
+	old, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer old.Close() //nolint: errcheck
+
+	mc, err := NewClient([]string{recent.Addr().String()}, 1, WithStrictMetaFlags())
+	require.NoError(t, err)
+	defer mc.Close() //nolint: errcheck
+
+	// a backend added later is gated by its own version.
+	be := netpkg.NewBackend(old.Addr(), 1, nil)
+	be.SetCapabilities(Capabilities{Version: "1.6.9", MetaProtocol: true})
+	require.NoError(t, mc.(*memcachedClient).pool.Add(be))
+
+	set := func(backend string) error {
+		encoder := memcache.CreateMetaSetEncoder()
+		encoder.Reset()
+		encoder.Key = "key"
+		encoder.Value = []byte("value")
+		encoder.CasOverride = 42
+		ctx := ContextWithRoutingHint(context.Background(), codec.RoutingHint{Backend: backend})
+		return mc.MetaSet(ctx, encoder, memcache.CreateMetaSetDecoder())
+	}
+	require.NoError(t, set(recent.Addr().String()))
+	assert.Equal(t, 1, recent.CommandCount("ms"))
+
+	err = set(old.Addr().String())
+	var flagErr *UnsupportedMetaFlagError
+	require.ErrorAs(t, err, &flagErr)
+	assert.Equal(t, old.Addr().String(), flagErr.Backend)
+	assert.Equal(t, memcache.ServerVersion{Major: 1, Minor: 6, Patch: 9}, flagErr.Version)
+	assert.Equal(t, 0, old.CommandCount("ms"))
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+func newClassicTestClient(t *testing.T) (MemcachedClient, *fakeserver.Server) {
+	srv, err := fakeserver.Start()
+	require.NoError(t, err)
+	srv.DisableMetaProtocol()
+
+	mc, err := NewClient([]string{srv.Addr().String()}, 1)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = mc.Close()
+		_ = srv.Close()
+	})
+	return mc, srv
+}
+
+func TestClassicFallback(t *testing.T) {
+	mc, srv := newClassicTestClient(t)
+	ctx := context.Background()
+	assert.True(t, mc.(*memcachedClient).classic)
+
+	require.NoError(t, mc.Add(ctx, Item{Key: "a", Value: []byte("1"), TTL: 60}))
+	assert.ErrorIs(t, mc.Add(ctx, Item{Key: "a", Value: []byte("2")}), ErrAlreadyExists)
+	assert.ErrorIs(t, mc.Replace(ctx, Item{Key: "b", Value: []byte("2")}), ErrNotFound)
+	require.NoError(t, mc.AppendValue(ctx, "a", []byte("0"), NoVivify))
+
+	getEncoder := memcache.CreateMetaGetEncoder()
+	getEncoder.Reset()
+	getEncoder.Key = "a"
+	getEncoder.FetchValue = true
+	getDecoder := memcache.CreateMetaGetDecoder()
+	require.NoError(t, mc.MetaGet(ctx, getEncoder, getDecoder))
+	assert.Equal(t, memcache.CacheHit, getDecoder.Status)
+	assert.Equal(t, []byte("10"), getDecoder.Value)
+
+	incrEncoder := memcache.CreateArithmeticEncoder()
+	incrEncoder.Reset()
+	incrEncoder.Key = "a"
+	incrEncoder.Delta = 5
+	incrEncoder.FetchValue = true
+	incrDecoder := memcache.CreateArithmeticDecoder()
+	require.NoError(t, mc.MetaIncrement(ctx, incrEncoder, incrDecoder))
+	assert.Equal(t, uint64(15), incrDecoder.ValueUInt64)
+
+	statuses, err := mc.SetMulti(ctx, []Item{{Key: "b", Value: []byte("2")}, {Key: "c", Value: []byte("3")}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]memcache.MetadataStatus{"b": memcache.Stored, "c": memcache.Stored}, statuses)
+
+	values, err := mc.GetMulti(ctx, []string{"a", "b", "c", "d"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("15"), "b": []byte("2"), "c": []byte("3")}, values)
+
+	require.NoError(t, mc.Touch(ctx, "b", 60))
+	assert.ErrorIs(t, mc.Touch(ctx, "d", 60), ErrNotFound)
+	assert.Equal(t, 2, srv.CommandCount("touch"))
+
+	statuses, err = mc.DeleteMulti(ctx, []string{"a", "d"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]memcache.MetadataStatus{"a": memcache.Deleted, "d": memcache.NotFound}, statuses)
+	_, ok := srv.Get("a")
+	assert.False(t, ok)
+
+	// the meta commands are only sent by the capability probe.
+	assert.Zero(t, srv.CommandCount("ms"))
+	assert.Zero(t, srv.CommandCount("mg"))
+	assert.Equal(t, 1, srv.CommandCount("mn"))
+}
+
+func TestClassicFallbackRejectsMetaOnlyRequests(t *testing.T) {
+	mc, srv := newClassicTestClient(t)
+	srv.Set("a", []byte("1"), 60)
+
+	_, err := mc.GetWithTTL(context.Background(), "a")
+	assert.ErrorIs(t, err, memcache.ErrUnsupportedByClassicProtocol)
+
+	err = mc.AppendValue(context.Background(), "b", []byte("1"), 60)
+	assert.ErrorIs(t, err, memcache.ErrUnsupportedByClassicProtocol)
+	assert.Zero(t, srv.CommandCount("append"))
+}
@@ -0,0 +1,811 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/internal/debugcheck"
+	netpkg "github.com/stripe/memlink/internal/net"
+	"go.uber.org/zap"
+)
+
+// MemcachedClient provides a high-level interface for interacting with memcached instances
+type MemcachedClient interface {
+	// MetaSet takes a MetaSetEncoder and MetaSetDecoder as pointers
+	MetaSet(ctx context.Context, encoder *memcache.MetaSetEncoder, decoder *memcache.MetaSetDecoder) error
+
+	// MetaGet takes a MetaGetEncoder and MetaGetDecoder as pointers
+	MetaGet(ctx context.Context, encoder *memcache.MetaGetEncoder, decoder *memcache.MetaGetDecoder) error
+
+	// MetaDelete takes a MetaDeleteEncoder and MetaDeleteDecoder as pointers
+	MetaDelete(ctx context.Context, encoder *memcache.MetaDeleteEncoder, decoder *memcache.MetaDeleteDecoder) error
+
+	// MetaIncrement takes a MetaArithmeticEncoder and MetaArithmeticDecoder as pointers
+	MetaIncrement(ctx context.Context, encoder *memcache.MetaArithmeticEncoder, decoder *memcache.MetaArithmeticDecoder) error
+
+	// MetaDecrement takes a MetaArithmeticEncoder and MetaArithmeticDecoder as pointers
+	MetaDecrement(ctx context.Context, encoder *memcache.MetaArithmeticEncoder, decoder *memcache.MetaArithmeticDecoder) error
+
+	// BulkGet takes a BulkEncoder and BulkDecoder as pointers
+	BulkGet(ctx context.Context, encoder *memcache.BulkEncoder[*memcache.MetaGetEncoder], decoder *memcache.BulkDecoder[*memcache.MetaGetDecoder]) error
+
+	// Pipeline sends groups of requests on a single connection, each followed by an mn barrier, and waits for every group
+	Pipeline(ctx context.Context, groups ...*memcache.BarrierGroup) error
+
+	// Get fetches the value of a key along with its item, failing with ErrNotFound on a miss
+	Get(ctx context.Context, key string) ([]byte, Item, error)
+
+	// Set stores value under key with a TTL in seconds, 0 meaning it never expires
+	Set(ctx context.Context, key string, value []byte, ttl int32) error
+
+	// GetOrSet returns the value of a key, or else loads it with loader and stores it with a TTL in seconds
+	GetOrSet(ctx context.Context, key string, ttl int32, loader func(ctx context.Context) ([]byte, error)) ([]byte, error)
+
+	// GetOrCompute is like GetOrSet, but concurrent calls missing the same key share a single load and set
+	GetOrCompute(ctx context.Context, key string, ttl int32, loader func(ctx context.Context) ([]byte, error)) ([]byte, error)
+
+	// Delete removes a key, failing with ErrNotFound if it doesn't exist
+	Delete(ctx context.Context, key string) error
+
+	// Touch sets the TTL of a key in seconds without fetching its value, failing with ErrNotFound if it doesn't exist
+	Touch(ctx context.Context, key string, ttl int32) error
+
+	// TouchUntil sets a key to expire at a given time without fetching its value, failing with ErrNotFound if it
+	// doesn't exist
+	TouchUntil(ctx context.Context, key string, expireAt time.Time) error
+
+	// Incr adds delta to a counter and returns its new value, failing with ErrNotFound if it doesn't exist unless
+	// opts vivifies it
+	Incr(ctx context.Context, key string, delta uint64, opts ArithmeticOptions) (uint64, error)
+
+	// Decr subtracts delta from a counter and returns its new value, failing with ErrNotFound if it doesn't exist
+	// unless opts vivifies it
+	Decr(ctx context.Context, key string, delta uint64, opts ArithmeticOptions) (uint64, error)
+
+	// GetWithTTL fetches a key along with its remaining TTL and CAS id
+	GetWithTTL(ctx context.Context, key string) (GetResult, error)
+
+	// GetAndTouch fetches a key and sets its TTL in seconds in a single request, returning its TTL before and after
+	GetAndTouch(ctx context.Context, key string, ttl int32) (GetAndTouchResult, error)
+
+	// GetMulti fetches multiple keys at once and returns the values of the keys which were found
+	GetMulti(ctx context.Context, keys []string) (map[string][]byte, error)
+
+	// SetMulti stores multiple items at once and returns the status of every key
+	SetMulti(ctx context.Context, items []Item) (map[string]memcache.MetadataStatus, error)
+
+	// DeleteMulti deletes multiple keys at once and returns the status of every key
+	DeleteMulti(ctx context.Context, keys []string) (map[string]memcache.MetadataStatus, error)
+
+	// Add stores item only if its key doesn't exist, returning ErrAlreadyExists otherwise
+	Add(ctx context.Context, item Item) error
+
+	// Replace stores item only if its key exists, returning ErrNotFound otherwise
+	Replace(ctx context.Context, item Item) error
+
+	// AppendValue appends value to the item stored under key, creating it with a TTL of vivifyTTL seconds if it's missing
+	AppendValue(ctx context.Context, key string, value []byte, vivifyTTL int32) error
+
+	// PrependValue prepends value to the item stored under key, creating it with a TTL of vivifyTTL seconds if it's missing
+	PrependValue(ctx context.Context, key string, value []byte, vivifyTTL int32) error
+
+	// DeleteByPrefix deletes every key starting with prefix from every backend, issuing at most rate deletes per second
+	DeleteByPrefix(ctx context.Context, prefix string, rate int) (int, error)
+
+	// ScanItems calls fn with the metadata of every item stored on every backend
+	ScanItems(ctx context.Context, fn func(backend string, entry memcache.MetadumpEntry) error) error
+
+	// BackendCapabilities returns the capabilities detected for every backend, keyed by backend address
+	BackendCapabilities() map[string]Capabilities
+
+	// BackendCompression returns the stream compression negotiated with every backend, keyed by backend address
+	BackendCompression() map[string]Compression
+
+	// Topology returns a snapshot of the backends of the pool, their weight and the health of their connections
+	Topology() Topology
+
+	// WarmUp waits for every connection to be established and to answer a version request, until ctx is done, and
+	// returns the readiness of every backend
+	WarmUp(ctx context.Context) ([]BackendReadiness, error)
+
+	// Healthy reports whether enough backends have an established connection, without sending any request
+	Healthy() bool
+
+	// Ready sends a version request to every backend and returns an error unless enough of them answered
+	Ready(ctx context.Context) error
+
+	// SetDegraded takes the cache out of the path of the requests for d: reads miss and writes are dropped
+	SetDegraded(d time.Duration)
+
+	// ServerStats returns the statistics of the given group reported by every backend, keyed by backend address
+	ServerStats(ctx context.Context, group string) (map[string]map[string]string, error)
+
+	// McrouterGet reads the mcrouter special key "__mcrouter__.<name>" on every backend, keyed by backend address
+	McrouterGet(ctx context.Context, name string) (map[string][]byte, error)
+
+	// McrouterRoute returns where every backend, an mcrouter proxy, would route an operation on key
+	McrouterRoute(ctx context.Context, operation string, key string) (map[string][]string, error)
+
+	// Raw sends complete text protocol commands to the given backend on a connection of their own and returns the
+	// response
+	Raw(ctx context.Context, backend string, request []byte) ([]byte, error)
+
+	// Stats returns a snapshot of the telemetry recorded by the client
+	Stats() ClientStats
+
+	// WithNamespace returns a view of the client prefixing every key with the namespace and applying its policies
+	WithNamespace(namespace string, opts ...NamespaceOption) MemcachedClient
+
+	// Close closes all connections
+	Close() error
+
+	// Done is closed once the client is closed and every routine it started, down to the ones of its connections,
+	// exited
+	Done() <-chan struct{}
+}
+
+// memcachedClient implements MemcachedClient
+type memcachedClient struct {
+	pool         netpkg.TCPConnPool
+	logger       *zap.Logger
+	maxValueSize int
+	// whether maxValueSize was set with WithMaxValueSize, or can be lowered to the backends' item size limit.
+	maxValueSizeSet         bool
+	skipCapabilityDetection bool
+	connOpts                []netpkg.ConnOption
+	requireMetaProtocol     bool
+	strictMetaFlags         bool
+	// whether requests are translated to the classic protocol, set when a backend doesn't support the meta protocol.
+	classic    bool
+	ttlPolicy  TTLPolicy
+	valueSizes *valueSizeRecorder
+	mirror     *getMirror
+	slo        *sloTracker
+	tenants    *tenantTracker
+	operations *operationRecorder
+	poolWarmUp int
+	// hashFn picks the backend of the requests, the pool picks one at random when nil.
+	hashFn HasherFn
+	// canaryFn is the candidate HasherFn the placements are compared with, nil unless WithRoutingCanary is set.
+	canaryFn HasherFn
+	// canary wraps the pool when canaryFn is set.
+	canary *netpkg.CanaryPool
+	// standby is the cluster the requests fail over to, nil unless WithStandbyCluster is set.
+	standby *standbyCluster
+	// failover wraps the pool and the one of the standby cluster when standby is set.
+	failover *netpkg.FailoverPool
+	// invalidations publishes the keys changed by the client, nil unless WithInvalidationSink is set.
+	invalidations *invalidationPublisher
+	// audit records a sample of the requests, nil unless WithAuditLog is set.
+	audit *auditLogger
+	// latencies records the latency of the requests, nil unless WithLatencyHistograms is set.
+	latencies *latencyRecorder
+	// replay queues the writes which failed to reach memcached, nil unless WithWriteReplay is set.
+	replay *writeReplayer
+	// collapser shares the keys fetched by concurrent GetMulti calls, nil unless WithGetMultiCollapsing is set.
+	collapser *getCollapser
+	// valueCompressor compresses the values written and decompresses the ones read, nil unless WithValueCompression is
+	// set.
+	valueCompressor *valueCompressor
+	// admission sheds the requests of the lower priority while the client is overloaded, nil unless
+	// WithAdmissionControl is set.
+	admission *admissionController
+	// encryptor encrypts the values written and decrypts the ones read, nil unless WithValueEncryption is set.
+	encryptor *valueEncryptor
+	// checksummer checksums the values written and verifies the ones read, nil unless WithValueChecksums is set.
+	checksummer *valueChecksummer
+	// chunker stores the values exceeding the max value size in chunks, nil unless WithValueChunking is set.
+	chunker *valueChunker
+	// loads shares the values loaded by concurrent GetOrCompute calls.
+	loads loadGroup
+	// softTTL is the default soft TTL of the values wrapped in an envelope, 0 unless WithSoftTTL is set.
+	softTTL time.Duration
+	// absoluteTTLs sends the TTLs longer than memcache.MaxRelativeTTL as is rather than as unix timestamps, false
+	// unless WithAbsoluteTTLs is set.
+	absoluteTTLs bool
+	// interner interns the keys and status lines decoded from the responses, nil unless WithInterner is set.
+	interner *memcache.Interner
+	// handover is called with the state of the pool when the client is closed, nil unless WithHandover is set.
+	handover func(Handover)
+	// readinessQuorum is the fraction of the backends which must be healthy for the client to be, 1 when unset.
+	readinessQuorum float64
+
+	namespacesMu sync.Mutex
+	namespaces   map[string]*namespaceCounters // protected by namespacesMu
+
+	requestIDOpaques bool
+	opaqueObserver   OpaqueObserver
+	requestOpaqueSeq atomic.Uint64
+
+	// degradedUntil is the end of the window set by SetDegraded, in nanoseconds since the epoch, 0 when unset.
+	degradedUntil    atomic.Int64
+	degradedReads    atomic.Uint64
+	droppedWrites    atomic.Uint64
+	degradedFailures atomic.Uint64
+
+	abandoned abandonCounters
+}
+
+// defaultMaxValueSize matches memcached's default item_size_max.
+const defaultMaxValueSize = 1024 * 1024
+
+// NewClient creates a new memcached client connected to the specified addresses, with numConnsPerBackend plain TCP
+// connections to each. See NewClientFromBackends to configure the backends one by one, e.g. with TLS.
+func NewClient(addresses []string, numConnsPerBackend int, opts ...ClientOption) (MemcachedClient, error) {
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("at least one address must be provided")
+	}
+
+	configs := make([]BackendConfig, 0, len(addresses))
+	for _, addr := range addresses {
+		configs = append(configs, BackendConfig{Addr: addr, NumConns: numConnsPerBackend})
+	}
+	return NewClientFromBackends(configs, opts...)
+}
+
+func newClient(backends []*netpkg.Backend, opts ...ClientOption) (_ MemcachedClient, err error) {
+	client := &memcachedClient{
+		logger:       zap.NewNop(),
+		maxValueSize: defaultMaxValueSize,
+	}
+
+	// Apply client options
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	if client.mirror != nil {
+		client.mirror.logger = client.logger
+	}
+	if client.valueCompressor != nil {
+		if err := client.valueCompressor.start(client.maxValueSize); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				client.valueCompressor.close()
+			}
+		}()
+	}
+	if client.poolWarmUp > 0 {
+		warmPools(client.poolWarmUp)
+	}
+
+	if client.strictMetaFlags {
+		client.connOpts = append(client.connOpts, netpkg.WithLinkGate(client.gateMetaFlags))
+	}
+
+	// Create connection pool
+	poolOpts := []netpkg.ConnPoolOptions{
+		netpkg.WithConnPoolLogger(zap.NewNop()),
+		netpkg.WithConnPoolConnOptions(client.connOpts...),
+	}
+	if client.hashFn != nil {
+		poolOpts = append(poolOpts, netpkg.WithConnPoolHashFn(client.hashFn))
+	}
+
+	pool, err := netpkg.NewConnPool(backends, poolOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	}
+	client.pool = pool
+	if client.standby != nil {
+		standby, err := newStandbyPool(client.standby.configs, poolOpts)
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		client.failover = netpkg.NewFailoverPool(pool, standby, client.standby.config, netpkg.SystemClock, failoverInvalidator{c: client})
+		client.pool = client.failover
+	}
+	if client.canaryFn != nil {
+		client.canary = netpkg.NewCanaryPool(client.pool, client.canaryFn, isCanaryRead)
+		client.pool = client.canary
+	}
+
+	if !client.skipCapabilityDetection {
+		if err := client.detectCapabilities(context.Background()); err != nil {
+			client.pool.Close()
+			return nil, err
+		}
+	}
+
+	// the routines of the client are only started once it can't fail anymore, Close stops them.
+	if client.invalidations != nil {
+		client.invalidations.start(client.logger)
+	}
+	if client.audit != nil {
+		client.audit.start(client.logger)
+	}
+	if client.latencies != nil {
+		client.latencies.start(client.logger)
+	}
+	if client.replay != nil {
+		client.replay.start(client.replayWrite)
+	}
+
+	return client, nil
+}
+
+// ClientOption configures a memcached client
+type ClientOption func(*memcachedClient)
+
+// WithLogger sets a custom logger for the client
+func WithLogger(logger *zap.Logger) ClientOption {
+	return func(c *memcachedClient) {
+		c.logger = logger
+	}
+}
+
+// WithMaxValueSize sets the size, in bytes, above which values are rejected with ErrValueTooLarge before being sent.
+// It should match the item_size_max of the memcached servers.
+func WithMaxValueSize(size int) ClientOption {
+	return func(c *memcachedClient) {
+		c.maxValueSize = size
+		c.maxValueSizeSet = true
+	}
+}
+
+// WithInterner makes the client intern the item keys and the status lines it decodes from the responses with i, so
+// that the workloads fetching the same keys over and over don't allocate them again for each response. An Interner
+// may be shared by several clients, e.g. to bound the strings held by the process.
+func WithInterner(i *memcache.Interner) ClientOption {
+	return func(c *memcachedClient) {
+		c.interner = i
+	}
+}
+
+// InboundOverflowPolicy decides what happens to a request about to be written while the responses of too many other
+// requests of the same connection haven't been read yet.
+type InboundOverflowPolicy = netpkg.InboundOverflowPolicy
+
+const (
+	// InboundOverflowBlock delays the request, and the ones queued behind it, until a response is read.
+	InboundOverflowBlock = netpkg.InboundOverflowBlock
+	// InboundOverflowFail fails the request without sending it.
+	InboundOverflowFail = netpkg.InboundOverflowFail
+)
+
+// WithQueueSizes sets, per connection, how many requests can wait to be written and how many can wait for their
+// response. Non-positive sizes keep the defaults of 1000 and twice the outbound size respectively.
+func WithQueueSizes(outbound int, inbound int) ClientOption {
+	return func(c *memcachedClient) {
+		c.connOpts = append(c.connOpts, netpkg.WithQueueSizes(outbound, inbound))
+	}
+}
+
+// WithInboundOverflowPolicy sets what happens to a request when too many responses of its connection haven't been
+// read yet. It defaults to InboundOverflowBlock.
+func WithInboundOverflowPolicy(policy InboundOverflowPolicy) ClientOption {
+	return func(c *memcachedClient) {
+		c.connOpts = append(c.connOpts, netpkg.WithInboundOverflowPolicy(policy))
+	}
+}
+
+// WithMaxInFlight bounds, per connection, how many requests can be sent without their response having been read.
+// The requests beyond wait in the outbound queue, which bounds the latency added behind a slow response. Non-positive
+// values don't bound it, besides the inbound queue size.
+func WithMaxInFlight(n int) ClientOption {
+	return func(c *memcachedClient) {
+		c.connOpts = append(c.connOpts, netpkg.WithMaxInFlight(n))
+	}
+}
+
+// WithBandwidthLimit throttles the requests written to every connection to bytesPerSecond, allowing bursts of burst
+// bytes, so that backfills and cache warms can't saturate shared NICs or cross zone links. Non-positive rates don't
+// throttle.
+func WithBandwidthLimit(bytesPerSecond int, burst int) ClientOption {
+	return func(c *memcachedClient) {
+		c.connOpts = append(c.connOpts, netpkg.WithBandwidthLimit(bytesPerSecond, burst))
+	}
+}
+
+// ResponseTimeoutError is the error of a request whose response didn't start arriving within the response timeout.
+type ResponseTimeoutError = netpkg.ResponseTimeoutError
+
+// WithResponseTimeout fails a request when no byte of its response, nor of the responses before it, arrived within
+// timeout after it was sent, and reconnects its connection. It bounds the wait of every request on its own, while the
+// socket deadline is extended by every request written. Non-positive timeouts disable it.
+func WithResponseTimeout(timeout time.Duration) ClientOption {
+	return func(c *memcachedClient) {
+		c.connOpts = append(c.connOpts, netpkg.WithResponseTimeout(timeout))
+	}
+}
+
+// DefaultMaxResponseSize is the max response size of the connections to the backends whose item size limit wasn't
+// detected, see WithMaxResponseSize.
+const DefaultMaxResponseSize = netpkg.DefaultMaxResponseSize
+
+// ResponseTooLargeError is the error of a request whose response announced a value larger than the maximum response
+// size.
+type ResponseTooLargeError = netpkg.ResponseTooLargeError
+
+// WithMaxResponseSize fails a request whose response announces a value larger than size bytes with a
+// ResponseTooLargeError, without reading the value, and reconnects its connection, failing the requests pipelined
+// behind it too. It protects the client from the allocations a misconfigured server or a corrupted stream would cause.
+// By default, the max response size is the item size limit detected for each backend, or DefaultMaxResponseSize when
+// it wasn't detected; non-positive sizes keep it.
+func WithMaxResponseSize(size int) ClientOption {
+	return func(c *memcachedClient) {
+		c.connOpts = append(c.connOpts, netpkg.WithMaxResponseSize(size))
+	}
+}
+
+// WithoutMaxResponseSize opts out of the max response size, e.g. for backends storing items larger than the limit
+// they report. The values are still bounded by memcached's largest item size limit, 1 GiB.
+func WithoutMaxResponseSize() ClientOption {
+	return func(c *memcachedClient) {
+		c.connOpts = append(c.connOpts, netpkg.WithoutMaxResponseSize())
+	}
+}
+
+// Compression is an algorithm compressing the whole stream of the connections to a backend, supported by some
+// memcached proxies.
+type Compression = netpkg.Compression
+
+const (
+	// CompressionNone leaves the streams uncompressed.
+	CompressionNone = netpkg.CompressionNone
+	// CompressionSnappy frames the streams in the snappy framing format.
+	CompressionSnappy = netpkg.CompressionSnappy
+	// CompressionZstd compresses the streams with zstd.
+	CompressionZstd = netpkg.CompressionZstd
+)
+
+// WithCompression offers every backend to compress the streams of its connections, which reduces the bandwidth of
+// cross datacenter traffic, bulk requests in particular. Backends not supporting it, e.g. a plain memcached, are
+// served uncompressed. The compression negotiated is reported by BackendCompression.
+func WithCompression(compression Compression) ClientOption {
+	return func(c *memcachedClient) {
+		c.connOpts = append(c.connOpts, netpkg.WithCompression(compression))
+	}
+}
+
+// ChecksumPolicy decides how the checksum annotation of the responses is handled by WithTolerantFraming.
+type ChecksumPolicy = netpkg.ChecksumPolicy
+
+const (
+	// ChecksumIgnore strips the checksum annotation like any other.
+	ChecksumIgnore = netpkg.ChecksumIgnore
+	// ChecksumVerify checks the values of the responses carrying the checksum annotation against it.
+	ChecksumVerify = netpkg.ChecksumVerify
+	// ChecksumRequire checks every value against its checksum annotation, and fails the ones without it.
+	ChecksumRequire = netpkg.ChecksumRequire
+)
+
+// ErrChecksumMismatch is the error of the requests whose response failed its checksum annotation.
+var ErrChecksumMismatch = netpkg.ErrChecksumMismatch
+
+// WithTolerantFraming serves backends behind proxies appending `name=value` annotations to the response headers: the
+// annotations trailing a header are stripped before it's decoded. The `crc32c` annotation, the CRC-32C of the value in
+// hexadecimal, is checked according to checksum, a mismatch failing the request and re-establishing its connection.
+// Keys looking like annotations, e.g. "trace=1", can't be fetched back with their k flag in this mode.
+func WithTolerantFraming(checksum ChecksumPolicy) ClientOption {
+	return func(c *memcachedClient) {
+		c.connOpts = append(c.connOpts, netpkg.WithTolerantFraming(checksum))
+	}
+}
+
+// ZombieLinkError is the error of the requests still queued on a connection when it's lost or closed.
+type ZombieLinkError = netpkg.ZombieLinkError
+
+// WithZombieLinkHook calls hook for every request failed with a ZombieLinkError, e.g. to count the requests dying in
+// queue during reconnects. The hook is called by the connection's routine and must not block.
+func WithZombieLinkHook(hook func(err ZombieLinkError)) ClientOption {
+	return func(c *memcachedClient) {
+		c.connOpts = append(c.connOpts, netpkg.WithZombieLinkHook(hook))
+	}
+}
+
+// WithZombieLinkRetry appends the idempotent requests still queued on a lost connection to another connection to the
+// same backend instead of failing them: plain gets, sets and deletes, version and stats. A request is retried once at
+// most, and only when the backend has more than one connection, see numConnsPerBackend.
+func WithZombieLinkRetry() ClientOption {
+	return func(c *memcachedClient) {
+		c.connOpts = append(c.connOpts, netpkg.WithZombieLinkPolicy(netpkg.ZombieLinkRetryIdempotent))
+	}
+}
+
+// append is a helper method that abstracts the common pattern of creating a link,
+// appending it to the pool, and waiting for completion
+func (c *memcachedClient) append(ctx context.Context, e codec.LinkEncoder, d codec.LinkDecoder) error {
+	return c.appendThen(ctx, e, d, nil)
+}
+
+// appendThen is like append, and calls then with the error of the request once it's complete, even when ctx is done
+// first, unless it's nil.
+func (c *memcachedClient) appendThen(ctx context.Context, e codec.LinkEncoder, d codec.LinkDecoder, then func(err error)) error {
+	if c.tenants != nil {
+		if err := c.tenants.admit(ctx, e); err != nil {
+			if then != nil {
+				then(err)
+			}
+			return err
+		}
+	}
+
+	start := time.Now()
+	backend, err := c.appendLink(ctx, e, d, then)
+	latency := time.Since(start)
+	if c.collapser != nil {
+		c.collapser.forget(e)
+	}
+	if c.slo != nil {
+		c.slo.observe(e, latency, err)
+	}
+	if c.operations != nil {
+		c.operations.observe(e, latency, err)
+	}
+	if c.audit != nil {
+		c.audit.observe(e, backend, start, latency, err)
+	}
+	if c.latencies != nil {
+		c.latencies.observe(e, backend, latency)
+	}
+	if err == nil && c.invalidations != nil {
+		c.invalidations.observe(e, d)
+	}
+	return err
+}
+
+// appendLink sends the request and waits for its response, and returns the address of the backend it was sent to, empty
+// if it wasn't sent.
+func (c *memcachedClient) appendLink(ctx context.Context, e codec.LinkEncoder, d codec.LinkDecoder, then func(err error)) (string, error) {
+	if degraded, err := c.shortCircuit(e, d); degraded {
+		if then != nil {
+			then(err)
+		}
+		return "", err
+	}
+	if c.admission != nil {
+		if err := c.admission.admit(e); err != nil {
+			if then != nil {
+				then(err)
+			}
+			return "", err
+		}
+		start := time.Now()
+		defer func() { c.admission.done(time.Since(start)) }()
+	}
+	link, err := c.newLink(e, d, RoutingHintFromContext(ctx))
+	if err != nil {
+		if then != nil {
+			then(err)
+		}
+		return "", err
+	}
+	// only the requests which reached the pool are replayed, the others would fail again.
+	if c.replay != nil && !replaying(ctx) {
+		then = c.replay.observeThen(e, then)
+	}
+	if err := c.pool.Append(link); err != nil {
+		debugcheck.Release(e, d, nil)
+		err = fmt.Errorf("failed to append request: %w", err)
+		if then != nil {
+			then(err)
+		}
+		return "", err
+	}
+	var backend string
+	if recorder, ok := link.(codec.BackendRecorder); ok {
+		backend = recorder.Backend()
+	}
+
+	err = c.wait(ctx, link)
+	if err == nil && c.checksummer != nil {
+		err = c.checksummer.verifyResponse(d)
+	}
+	if err == nil && c.encryptor != nil {
+		err = c.encryptor.decryptResponse(d)
+	}
+	if err == nil && c.valueCompressor != nil {
+		err = c.valueCompressor.decompressResponse(d)
+	}
+	debugcheck.Release(e, d, link.Done())
+	if then != nil {
+		select {
+		case <-link.Done():
+			then(link.Err())
+		default:
+			go func() {
+				<-link.Done()
+				then(link.Err())
+			}()
+		}
+	}
+	return backend, err
+}
+
+// appendTo is like append, but sends the request to the given backend instead of letting the pool pick one.
+func (c *memcachedClient) appendTo(ctx context.Context, be *netpkg.Backend, e codec.LinkEncoder, d codec.LinkDecoder) error {
+	return c.appendVia(ctx, c.pool.AppendTo, be, e, d)
+}
+
+// appendAdmin is like appendTo, but sends the request on the admin connection of the backend. It's meant for the
+// commands which can take long to answer, e.g. stats or lru_crawler, so that they don't delay the data requests.
+func (c *memcachedClient) appendAdmin(ctx context.Context, be *netpkg.Backend, e codec.LinkEncoder, d codec.LinkDecoder) error {
+	return c.appendVia(ctx, c.pool.AppendAdmin, be, e, d)
+}
+
+func (c *memcachedClient) appendVia(ctx context.Context, appendFn func(*netpkg.Backend, codec.Link) error, be *netpkg.Backend, e codec.LinkEncoder, d codec.LinkDecoder) error {
+	link, err := c.newLink(e, d, codec.RoutingHint{})
+	if err != nil {
+		return err
+	}
+	if err := appendFn(be, link); err != nil {
+		debugcheck.Release(e, d, nil)
+		return fmt.Errorf("failed to append request to backend %s: %w", be.String(), err)
+	}
+
+	err = c.wait(ctx, link)
+	debugcheck.Release(e, d, link.Done())
+	return err
+}
+
+// newLink creates the link of a request, translated to the classic protocol if needed, and routed according to hint.
+// In debug builds, the fields of the encoder are validated, and the encoder and decoder of the caller are checked for
+// misuses until they're released.
+func (c *memcachedClient) newLink(e codec.LinkEncoder, d codec.LinkDecoder, hint codec.RoutingHint) (codec.Link, error) {
+	if debugcheck.Enabled {
+		if validator, ok := e.(interface{ Validate() error }); ok {
+			if err := validator.Validate(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if c.classic && hint.Broadcast != nil {
+		// the decoders of the backends would be given classic responses.
+		return nil, fmt.Errorf("broadcast: %w", memcache.ErrUnsupportedByClassicProtocol)
+	}
+	if !c.absoluteTTLs {
+		memcache.ConvertLongTTLs(e)
+	}
+	if c.interner != nil {
+		memcache.InternStrings(d, c.interner)
+	}
+	ce := e
+	if c.valueCompressor != nil {
+		var err error
+		if ce, err = c.valueCompressor.rewriteRequest(ce); err != nil {
+			return nil, err
+		}
+	}
+	if c.encryptor != nil {
+		var err error
+		if ce, err = c.encryptor.rewriteRequest(ce); err != nil {
+			return nil, err
+		}
+	}
+	if c.checksummer != nil {
+		var err error
+		if ce, err = c.checksummer.rewriteRequest(ce); err != nil {
+			return nil, err
+		}
+	}
+	te, td, err := c.translate(ce, d)
+	if err != nil {
+		return nil, err
+	}
+
+	link := codec.NewRoutedLink(te, td, hint)
+	debugcheck.Acquire(e, d)
+	return link, nil
+}
+
+// translate converts meta requests to the classic protocol when the client fell back to it.
+func (c *memcachedClient) translate(e codec.LinkEncoder, d codec.LinkDecoder) (codec.LinkEncoder, codec.LinkDecoder, error) {
+	if !c.classic {
+		return e, d, nil
+	}
+	return memcache.ClassicCodec(e, d)
+}
+
+// prepareSet applies the client wide policies to an item about to be written.
+func (c *memcachedClient) prepareSet(encoder *memcache.MetaSetEncoder) {
+	c.applyTTLPolicy(encoder)
+
+	if c.valueSizes != nil {
+		c.valueSizes.record(setKey(encoder), len(encoder.Value))
+	}
+}
+
+// MetaSet takes a MetaSetEncoder and MetaSetDecoder as pointers
+func (c *memcachedClient) MetaSet(ctx context.Context, encoder *memcache.MetaSetEncoder, decoder *memcache.MetaSetDecoder) error {
+	c.assignOpaque(ctx, &encoder.Opaque)
+	c.prepareSet(encoder)
+	if err := c.append(ctx, encoder, decoder); err != nil {
+		return fmt.Errorf("MetaSet operation failed: %w", err)
+	}
+
+	return nil
+}
+
+// MetaGet takes a MetaGetEncoder and MetaGetDecoder as pointers
+func (c *memcachedClient) MetaGet(ctx context.Context, encoder *memcache.MetaGetEncoder, decoder *memcache.MetaGetDecoder) error {
+	c.assignOpaque(ctx, &encoder.Opaque)
+	if err := c.append(ctx, encoder, decoder); err != nil {
+		return fmt.Errorf("MetaGet operation failed: %w", err)
+	}
+	if c.mirror != nil {
+		c.mirror.observe(ctx, encoder, decoder)
+	}
+
+	return nil
+}
+
+// MetaDelete takes a MetaDeleteEncoder and MetaDeleteDecoder as pointers
+func (c *memcachedClient) MetaDelete(ctx context.Context, encoder *memcache.MetaDeleteEncoder, decoder *memcache.MetaDeleteDecoder) error {
+	c.assignOpaque(ctx, &encoder.Opaque)
+	if err := c.append(ctx, encoder, decoder); err != nil {
+		return fmt.Errorf("MetaDelete operation failed: %w", err)
+	}
+
+	return nil
+}
+
+// MetaIncrement takes a MetaArithmeticEncoder and MetaArithmeticDecoder as pointers
+func (c *memcachedClient) MetaIncrement(ctx context.Context, encoder *memcache.MetaArithmeticEncoder, decoder *memcache.MetaArithmeticDecoder) error {
+	c.assignOpaque(ctx, &encoder.Opaque)
+	if err := c.append(ctx, encoder, decoder); err != nil {
+		return fmt.Errorf("MetaIncrement operation failed: %w", err)
+	}
+
+	return nil
+}
+
+// MetaDecrement takes a MetaArithmeticEncoder and MetaArithmeticDecoder as pointers
+func (c *memcachedClient) MetaDecrement(ctx context.Context, encoder *memcache.MetaArithmeticEncoder, decoder *memcache.MetaArithmeticDecoder) error {
+	c.assignOpaque(ctx, &encoder.Opaque)
+	if err := c.append(ctx, encoder, decoder); err != nil {
+		return fmt.Errorf("MetaDecrement operation failed: %w", err)
+	}
+
+	return nil
+}
+
+// BulkGet takes a BulkEncoder and BulkDecoder as pointers
+func (c *memcachedClient) BulkGet(ctx context.Context, encoder *memcache.BulkEncoder[*memcache.MetaGetEncoder], decoder *memcache.BulkDecoder[*memcache.MetaGetDecoder]) error {
+	if err := c.append(ctx, encoder, decoder); err != nil {
+		return fmt.Errorf("BulkGet operation failed: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes all connections
+func (c *memcachedClient) Close() error {
+	if c.mirror != nil {
+		c.mirror.wait()
+	}
+	if c.invalidations != nil {
+		c.invalidations.close()
+	}
+	if c.audit != nil {
+		c.audit.close()
+	}
+	if c.latencies != nil {
+		c.latencies.close()
+	}
+	if c.replay != nil {
+		c.replay.close()
+	}
+	if c.valueCompressor != nil {
+		c.valueCompressor.close()
+	}
+	if c.handover != nil {
+		c.handover(c.pool.Handover())
+	}
+	c.pool.Close()
+	return nil
+}
+
+// Done is closed once the client is closed and the routines of its connections exited, the other routines of the
+// client are waited for by Close itself.
+func (c *memcachedClient) Done() <-chan struct{} {
+	return c.pool.Done()
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// WithGetMultiCollapsing collapses the keys requested by concurrent GetMulti calls: a key already being fetched by
+// another call isn't requested again, the call waits for the fetch in flight and is given a copy of its value. A write
+// sent by the client keeps the calls starting once it's complete from joining the fetches of its keys started before,
+// so a caller always observes its own writes. Only values are shared: GetMulti neither bumps the TTL of the items nor
+// returns their CAS, so the items are left as if every call had fetched them itself.
+//
+// A fetch keeps going when the call which started it returns early, e.g. because its context is done, for the calls
+// which joined it. The keys served by the fetches of other calls are counted in Stats.
+func WithGetMultiCollapsing() ClientOption {
+	return func(c *memcachedClient) {
+		c.collapser = &getCollapser{inflight: make(map[string]*collapsedFetch)}
+	}
+}
+
+// collapsedFetch is the fetch of a key shared by concurrent GetMulti calls. Its fields are set before done is closed.
+type collapsedFetch struct {
+	done  chan struct{}
+	value []byte
+	found bool
+	err   error
+}
+
+// fetchFn fetches the values of keys, like GetMulti.
+type fetchFn func(ctx context.Context, keys []string) (map[string][]byte, error)
+
+type getCollapser struct {
+	mu       sync.Mutex
+	inflight map[string]*collapsedFetch // protected by mu, the fetches new calls can join
+
+	collapsed atomic.Uint64
+}
+
+// getMulti fetches the keys not in flight yet with fetch, and waits for them and for the ones in flight.
+func (g *getCollapser) getMulti(ctx context.Context, keys []string, fetch fetchFn) (map[string][]byte, error) {
+	fetches := make(map[string]*collapsedFetch, len(keys))
+	var owned []string
+	var ownedFetches []*collapsedFetch
+
+	g.mu.Lock()
+	for _, key := range keys {
+		if _, ok := fetches[key]; ok {
+			continue
+		}
+		f, ok := g.inflight[key]
+		if ok {
+			g.collapsed.Add(1)
+		} else {
+			f = &collapsedFetch{done: make(chan struct{})}
+			g.inflight[key] = f
+			owned = append(owned, key)
+			ownedFetches = append(ownedFetches, f)
+		}
+		fetches[key] = f
+	}
+	g.mu.Unlock()
+
+	if len(owned) > 0 {
+		go g.run(context.WithoutCancel(ctx), owned, ownedFetches, fetch)
+	}
+
+	values := make(map[string][]byte, len(fetches))
+	for key, f := range fetches {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-f.done:
+		}
+		if f.err != nil {
+			return nil, f.err
+		}
+		if f.found {
+			values[key] = bytes.Clone(f.value)
+		}
+	}
+	return values, nil
+}
+
+func (g *getCollapser) run(ctx context.Context, keys []string, fetches []*collapsedFetch, fetch fetchFn) {
+	values, err := fetch(ctx, keys)
+
+	g.mu.Lock()
+	for i, key := range keys {
+		f := fetches[i]
+		f.value, f.found = values[key]
+		f.err = err
+		if g.inflight[key] == f {
+			delete(g.inflight, key)
+		}
+	}
+	g.mu.Unlock()
+
+	for _, f := range fetches {
+		close(f.done)
+	}
+}
+
+// forget keeps the calls starting from now from joining the fetches in flight of the keys written by e, which may have
+// been read before the write.
+func (g *getCollapser) forget(e codec.LinkEncoder) {
+	keys := writtenKeys(e, nil)
+	if len(keys) == 0 {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, key := range keys {
+		delete(g.inflight, key)
+	}
+}
+
+// writtenKeys appends the keys whose value may be changed by e to keys.
+func writtenKeys(e codec.LinkEncoder, keys []string) []string {
+	switch e := e.(type) {
+	case *memcache.MetaSetEncoder, *memcache.MetaDeleteEncoder, *memcache.MetaArithmeticEncoder:
+		keys = append(keys, e.(codec.RoutingKeyer).RoutingKey())
+	case *memcache.BulkEncoder[*memcache.MetaSetEncoder]:
+		for _, encoder := range e.Encoders {
+			keys = append(keys, encoder.RoutingKey())
+		}
+	case *memcache.BulkEncoder[*memcache.MetaDeleteEncoder]:
+		for _, encoder := range e.Encoders {
+			keys = append(keys, encoder.RoutingKey())
+		}
+	case *memcache.BarrierEncoder:
+		for _, group := range e.Groups {
+			for _, encoder := range group.Encoders {
+				keys = writtenKeys(encoder, keys)
+			}
+		}
+	}
+	return keys
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+// startGetMulti calls GetMulti in the background and returns once its first get reached srv.
+func startGetMulti(t *testing.T, ctx context.Context, mc MemcachedClient, srv *fakeserver.Server, keys ...string) <-chan map[string][]byte {
+	gets := srv.CommandCount("mg")
+	result := make(chan map[string][]byte, 1)
+	go func() {
+		values, err := mc.GetMulti(ctx, keys)
+		if ctx.Err() == nil {
+			assert.NoError(t, err)
+		}
+		result <- values
+	}()
+	require.Eventually(t, func() bool { return srv.CommandCount("mg") > gets }, time.Second, time.Millisecond)
+	return result
+}
+
+func TestGetMultiCollapsing(t *testing.T) {
+	mc, srv := newTestClient(t, WithGetMultiCollapsing())
+	srv.Set("a", []byte("1"), 0)
+	srv.Set("b", []byte("2"), 0)
+	srv.SetLatency("mg", 50*time.Millisecond)
+
+	first := startGetMulti(t, context.Background(), mc, srv, "a", "b")
+	second, err := mc.GetMulti(context.Background(), []string{"b", "c", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"b": []byte("2")}, second)
+
+	// every caller is given its own copy of the shared values.
+	second["b"][0] = 'x'
+	assert.Equal(t, map[string][]byte{"a": []byte("1"), "b": []byte("2")}, <-first)
+	assert.Equal(t, 3, srv.CommandCount("mg"))
+	assert.Equal(t, uint64(1), mc.Stats().CollapsedKeys)
+}
+
+func TestGetMultiCollapsingOwnWrites(t *testing.T) {
+	srv, err := fakeserver.Start()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = srv.Close() })
+	mc, err := NewClient([]string{srv.Addr().String()}, 2, WithGetMultiCollapsing())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mc.Close() })
+
+	srv.Set("a", []byte("old"), 0)
+	srv.SetLatency("mg", 50*time.Millisecond)
+	first := startGetMulti(t, context.Background(), mc, srv, "a")
+
+	statuses, err := mc.SetMulti(context.Background(), []Item{{Key: "a", Value: []byte("new")}})
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+
+	// the fetch in flight may have read the key before the write, it's not joined anymore.
+	values, err := mc.GetMulti(context.Background(), []string{"a"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("new")}, values)
+	<-first
+	assert.Equal(t, 2, srv.CommandCount("mg"))
+	assert.Equal(t, uint64(0), mc.Stats().CollapsedKeys)
+}
+
+func TestGetMultiCollapsingOwnerCanceled(t *testing.T) {
+	mc, srv := newTestClient(t, WithGetMultiCollapsing())
+	srv.Set("a", []byte("1"), 0)
+	srv.SetLatency("mg", 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := startGetMulti(t, ctx, mc, srv, "a")
+	cancel()
+	assert.Nil(t, <-first)
+
+	// the fetch outlives the call which started it.
+	values, err := mc.GetMulti(context.Background(), []string{"a"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("1")}, values)
+	assert.Equal(t, 1, srv.CommandCount("mg"))
+}
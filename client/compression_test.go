@@ -0,0 +1,80 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+func TestCompression(t *testing.T) {
+	tests := []struct {
+		name        string
+		accepted    []Compression
+		compression Compression
+		expected    Compression
+	}{
+		{name: "snappy", accepted: []Compression{CompressionSnappy}, compression: CompressionSnappy, expected: CompressionSnappy},
+		{name: "zstd", accepted: []Compression{CompressionSnappy, CompressionZstd}, compression: CompressionZstd, expected: CompressionZstd},
+		{name: "refused", compression: CompressionZstd, expected: CompressionNone},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, err := fakeserver.Start()
+			require.NoError(t, err)
+			defer srv.Close() //nolint: errcheck
+			srv.EnableCompression(test.accepted...)
+
+			mc, err := NewClient([]string{srv.Addr().String()}, 2, WithCompression(test.compression))
+			require.NoError(t, err)
+			defer mc.Close() //nolint: errcheck
+
+			assert.Equal(t, map[string]Compression{srv.Addr().String(): test.expected}, mc.BackendCompression())
+			if test.expected == CompressionNone {
+				// the refusal is remembered for the backend, the second connection doesn't offer it again.
+				assert.Equal(t, 1, srv.CommandCount("compress"))
+			} else {
+				assert.Equal(t, 2, srv.CommandCount("compress"))
+			}
+
+			ctx := context.Background()
+			value := bytes.Repeat([]byte("compressible "), 1000)
+			items := []Item{{Key: "a", Value: value}, {Key: "b", Value: []byte("2")}}
+			_, err = mc.SetMulti(ctx, items)
+			require.NoError(t, err)
+			values, err := mc.GetMulti(ctx, []string{"a", "b", "c"})
+			require.NoError(t, err)
+			assert.Equal(t, map[string][]byte{"a": value, "b": []byte("2")}, values)
+		})
+	}
+}
+
+func TestDoneAfterClose(t *testing.T) {
+	for name, compression := range map[string]Compression{"none": CompressionNone, "snappy": CompressionSnappy, "zstd": CompressionZstd} {
+		t.Run(name, func(t *testing.T) {
+			defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+			srv, err := fakeserver.Start()
+			require.NoError(t, err)
+			defer srv.Close() //nolint: errcheck
+			srv.EnableCompression(CompressionSnappy, CompressionZstd)
+
+			mc, err := NewClient([]string{srv.Addr().String()}, 2, WithCompression(compression))
+			require.NoError(t, err)
+			require.NoError(t, mc.Set(context.Background(), "key", []byte("value"), 0))
+
+			select {
+			case <-mc.Done():
+				t.Fatal("Done is closed before the client")
+			default:
+			}
+			require.NoError(t, mc.Close())
+			// the routines of the client are gone once Done is closed, only the ones of the server may be left.
+			<-mc.WithNamespace("ns").Done()
+		})
+	}
+}
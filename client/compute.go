@@ -0,0 +1,87 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// GetOrCompute is like GetOrSet, but the concurrent calls of the client missing the same key share a single call to
+// loader and a single set: the first one loads and stores the value, the others wait for it and are given a copy of
+// the value, or its error. The load runs with the context of the call which started it, so it fails for every waiting
+// call when that context is done, while a waiting call whose own context is done returns right away. The calls served
+// by the load of another call are counted in Stats.
+func (c *memcachedClient) GetOrCompute(ctx context.Context, key string, ttl int32, loader func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	return getOrCompute(ctx, c, &c.loads, key, key, ttl, loader)
+}
+
+// getOrCompute implements GetOrCompute with the Get and Set of mc, sharing the loads of groupKey, the key once scoped
+// to its namespaces, in group.
+func getOrCompute(ctx context.Context, mc MemcachedClient, group *loadGroup, groupKey string, key string, ttl int32, loader func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	value, _, err := mc.Get(ctx, key)
+	if err == nil {
+		return value, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("GetOrCompute operation failed: %w", err)
+	}
+
+	return group.do(ctx, groupKey, func() ([]byte, error) {
+		value, err := loader(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := mc.Set(ctx, key, value, ttl); err != nil {
+			return value, fmt.Errorf("GetOrCompute operation failed: %w", err)
+		}
+		return value, nil
+	})
+}
+
+// sharedLoad is the load of a key shared by concurrent GetOrCompute calls. Its fields are set before done is closed.
+type sharedLoad struct {
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+type loadGroup struct {
+	mu       sync.Mutex
+	inflight map[string]*sharedLoad // protected by mu
+
+	shared atomic.Uint64
+}
+
+// do calls load unless a load of key is in flight, in which case it waits for it instead.
+func (g *loadGroup) do(ctx context.Context, key string, load func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if l, ok := g.inflight[key]; ok {
+		g.mu.Unlock()
+		g.shared.Add(1)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("GetOrCompute operation failed: %w", ctx.Err())
+		case <-l.done:
+		}
+		return bytes.Clone(l.value), l.err
+	}
+	if g.inflight == nil {
+		g.inflight = make(map[string]*sharedLoad)
+	}
+	// the waiting calls fail if load panics.
+	l := &sharedLoad{done: make(chan struct{}), err: errors.New("GetOrCompute operation failed: the loader panicked")}
+	g.inflight[key] = l
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.inflight, key)
+		g.mu.Unlock()
+		close(l.done)
+	}()
+	l.value, l.err = load()
+	return l.value, l.err
+}
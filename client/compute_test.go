@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingLoader returns a loader counting its calls and returning value once release is closed.
+func blockingLoader(value string, release <-chan struct{}, loads *atomic.Int64) func(ctx context.Context) ([]byte, error) {
+	return func(ctx context.Context) ([]byte, error) {
+		loads.Add(1)
+		<-release
+		return []byte(value), nil
+	}
+}
+
+func TestGetOrCompute(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+	sets := srv.CommandCount("ms")
+
+	const calls = 8
+	release := make(chan struct{})
+	var loads atomic.Int64
+	loader := blockingLoader("loaded", release, &loads)
+
+	values := make([][]byte, calls)
+	errs := make([]error, calls)
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], errs[i] = mc.GetOrCompute(ctx, "k", 60, loader)
+		}(i)
+	}
+	require.Eventually(t, func() bool {
+		return mc.Stats().SharedLoads == calls-1
+	}, 5*time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i := 0; i < calls; i++ {
+		require.NoError(t, errs[i])
+		assert.Equal(t, []byte("loaded"), values[i])
+	}
+	// the values are copies.
+	values[0][0] = 'X'
+	assert.Equal(t, []byte("loaded"), values[1])
+	assert.Equal(t, int64(1), loads.Load())
+	assert.Equal(t, sets+1, srv.CommandCount("ms"))
+
+	// the calls starting once the load is done read the stored value.
+	value, err := mc.GetOrCompute(ctx, "k", 60, loader)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("loaded"), value)
+	assert.Equal(t, int64(1), loads.Load())
+
+	var b strings.Builder
+	require.NoError(t, mc.Stats().WritePrometheus(&b))
+	assert.Contains(t, b.String(), "memlink_getorcompute_shared_loads_total 7\n")
+}
+
+func TestGetOrComputeSharesErrors(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+
+	errOrigin := errors.New("origin is down")
+	release := make(chan struct{})
+	leader := make(chan error, 1)
+	go func() {
+		_, err := mc.GetOrCompute(ctx, "k", 60, func(context.Context) ([]byte, error) {
+			<-release
+			return nil, errOrigin
+		})
+		leader <- err
+	}()
+	loads := &mc.(*memcachedClient).loads
+	require.Eventually(t, func() bool {
+		loads.mu.Lock()
+		defer loads.mu.Unlock()
+		return len(loads.inflight) == 1
+	}, 5*time.Second, time.Millisecond)
+
+	// a waiting call whose context is done returns right away.
+	cancelled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err := mc.GetOrCompute(cancelled, "k", 60, func(context.Context) ([]byte, error) {
+		t.Fatal("the load in flight was not shared")
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	waiter := make(chan error, 1)
+	go func() {
+		_, err := mc.GetOrCompute(ctx, "k", 60, func(context.Context) ([]byte, error) {
+			return []byte("unused"), nil
+		})
+		waiter <- err
+	}()
+	require.Eventually(t, func() bool {
+		return mc.Stats().SharedLoads == 2
+	}, 5*time.Second, time.Millisecond)
+	close(release)
+
+	assert.ErrorIs(t, <-leader, errOrigin)
+	assert.ErrorIs(t, <-waiter, errOrigin)
+	_, ok := srv.Get("k")
+	assert.False(t, ok)
+}
+
+func TestNamespacedGetOrCompute(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	var loads atomic.Int64
+	var wg sync.WaitGroup
+	for _, namespace := range []string{"billing", "search"} {
+		wg.Add(1)
+		go func(namespace string) {
+			defer wg.Done()
+			value, err := mc.WithNamespace(namespace).GetOrCompute(ctx, "k", 0, blockingLoader(namespace, release, &loads))
+			assert.NoError(t, err)
+			assert.Equal(t, []byte(namespace), value)
+		}(namespace)
+	}
+	// the same key in different namespaces isn't shared.
+	require.Eventually(t, func() bool {
+		return loads.Load() == 2
+	}, 5*time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	stored, ok := srv.Get("billing:k")
+	require.True(t, ok)
+	assert.Equal(t, []byte("billing"), stored)
+	assert.Zero(t, mc.Stats().SharedLoads)
+}
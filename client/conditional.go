@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/pools"
+)
+
+// Add stores item only if its key doesn't exist yet, failing with ErrAlreadyExists otherwise.
+func (c *memcachedClient) Add(ctx context.Context, item Item) error {
+	if err := c.conditionalSet(ctx, memcache.Add, item); err != nil {
+		return fmt.Errorf("Add operation failed: %w", err)
+	}
+	return nil
+}
+
+// Replace stores item only if its key already exists, failing with ErrNotFound otherwise.
+func (c *memcachedClient) Replace(ctx context.Context, item Item) error {
+	if err := c.conditionalSet(ctx, memcache.Replace, item); err != nil {
+		return fmt.Errorf("Replace operation failed: %w", err)
+	}
+	return nil
+}
+
+func (c *memcachedClient) conditionalSet(ctx context.Context, mode memcache.MetaSetMode, item Item) error {
+	value := c.wrapValue(item)
+	if len(value) > c.maxValueSize {
+		if c.chunker != nil {
+			return c.setChunked(ctx, mode, item, value)
+		}
+		return fmt.Errorf("key=%q size=%d max=%d: %w", item.Key, len(value), c.maxValueSize, ErrValueTooLarge)
+	}
+	return c.storeValue(ctx, mode, item, value, item.ClientFlags)
+}
+
+// storeValue stores value, already wrapped, under the key of item with mode.
+func (c *memcachedClient) storeValue(ctx context.Context, mode memcache.MetaSetMode, item Item, value []byte, clientFlags uint64) error {
+	encoder := setEncoderPool.Get()
+	decoder := setDecoderPool.Get()
+	defer pools.Release(ctx, setEncoderPool, encoder, setDecoderPool, decoder)
+
+	encoder.Key = item.Key
+	encoder.Value = value
+	encoder.TTL = item.TTL
+	encoder.ExpireAt = item.ExpireAt
+	encoder.ClientFlags = clientFlags
+	encoder.Mode = mode
+	c.assignOpaque(ctx, &encoder.Opaque)
+	c.prepareSet(encoder)
+
+	if err := c.append(ctx, encoder, decoder); err != nil {
+		return err
+	}
+
+	return statusErr(item.Key, mode, decoder.Status, decoder.HdrLine)
+}
+
+// statusErr maps the status of a conditional write to the typed errors of this package.
+func statusErr(key string, mode memcache.MetaSetMode, status memcache.MetadataStatus, hdrLine string) error {
+	switch status {
+	case memcache.Stored:
+		return nil
+	case memcache.NotStored:
+		// NS means the condition of the mode didn't hold.
+		switch mode {
+		case memcache.Add:
+			return fmt.Errorf("key=%q: %w", key, ErrAlreadyExists)
+		case memcache.Replace:
+			return fmt.Errorf("key=%q: %w", key, ErrNotFound)
+		default:
+			// appends fail on a missing key without auto-vivify, or when the item would outgrow the max item size.
+			return fmt.Errorf("key=%q: %w", key, ErrNotStored)
+		}
+	case memcache.Exists:
+		return fmt.Errorf("key=%q: %w", key, ErrAlreadyExists)
+	case memcache.NotFound:
+		return fmt.Errorf("key=%q: %w", key, ErrNotFound)
+	default:
+		return fmt.Errorf("unexpected status for key=%q: %s %q", key, status, hdrLine)
+	}
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdd(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+
+	require.NoError(t, mc.Add(ctx, Item{Key: "key", Value: []byte("first")}))
+
+	err := mc.Add(ctx, Item{Key: "key", Value: []byte("second")})
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+
+	value, ok := srv.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, "first", string(value))
+}
+
+func TestReplace(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+
+	err := mc.Replace(ctx, Item{Key: "key", Value: []byte("first")})
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, ok := srv.Get("key")
+	assert.False(t, ok)
+
+	srv.Set("key", []byte("first"), 0)
+	require.NoError(t, mc.Replace(ctx, Item{Key: "key", Value: []byte("second")}))
+
+	value, ok := srv.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, "second", string(value))
+}
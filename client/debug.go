@@ -0,0 +1,20 @@
+package client
+
+import (
+	"time"
+
+	"github.com/stripe/memlink/internal/debugcheck"
+)
+
+// PoolLeak is an encoder or decoder taken from its pool and not put back, along with the stack trace of the call which
+// took it.
+type PoolLeak = debugcheck.Leak
+
+// PoolLeaks returns the pooled encoders and decoders taken from their pool more than threshold ago and not put back,
+// the oldest first, whether by the client or by its users. They're only tracked in builds with the memlink_debug tag,
+// PoolLeaks returns nil otherwise. Tests can check it once their requests are done:
+//
+//	assert.Empty(t, client.PoolLeaks(time.Second))
+func PoolLeaks(threshold time.Duration) []PoolLeak {
+	return debugcheck.Leaks(threshold)
+}
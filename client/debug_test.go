@@ -0,0 +1,55 @@
+//go:build memlink_debug
+
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+func TestPoolLeaks(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+
+	srv.Set("a", []byte("1"), 0)
+	_, err := mc.GetMulti(ctx, []string{"a", "b"})
+	require.NoError(t, err)
+	require.NoError(t, mc.AppendValue(ctx, "a", []byte("2"), 0))
+	_, err = mc.DeleteByPrefix(ctx, "a", 10)
+	require.NoError(t, err)
+	assert.Empty(t, PoolLeaks(0))
+
+	leaked := getEncoderPool.Get()
+	leaks := PoolLeaks(0)
+	require.Len(t, leaks, 1)
+	assert.Equal(t, "*memcache.MetaGetEncoder", leaks[0].Type)
+	assert.Contains(t, leaks[0].Stack, "client.TestPoolLeaks")
+	getEncoderPool.Put(leaked)
+}
+
+func TestPoolDoublePut(t *testing.T) {
+	encoder := getEncoderPool.Get()
+	getEncoderPool.Put(encoder)
+	assert.Panics(t, func() { getEncoderPool.Put(encoder) })
+}
+
+func TestValidateRequests(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+
+	encoder := memcache.CreateMetaDeleteEncoder()
+	encoder.Reset()
+	encoder.Key = "k"
+	encoder.Invalidate = true
+	err := mc.MetaDelete(ctx, encoder, memcache.CreateMetaDeleteDecoder())
+	assert.ErrorIs(t, err, memcache.ErrInvalidRequest)
+	assert.Zero(t, srv.CommandCount("md"))
+
+	encoder.CasId = 1
+	assert.NoError(t, mc.MetaDelete(ctx, encoder, memcache.CreateMetaDeleteDecoder()))
+}
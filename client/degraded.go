@@ -0,0 +1,112 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// SetDegraded takes the cache out of the path of the requests for d, without contacting memcached: reads miss, writes
+// are dropped as if they succeeded, and the requests which can be neither, e.g. pipelines, fail with ErrDegraded. It's
+// meant for incident response, the cache can be stale once the window is over since the dropped deletes didn't apply.
+// A d of 0 or less ends the window.
+func (c *memcachedClient) SetDegraded(d time.Duration) {
+	if d <= 0 {
+		c.degradedUntil.Store(0)
+		return
+	}
+	c.degradedUntil.Store(time.Now().Add(d).UnixNano())
+}
+
+// degradedUntilTime returns the end of the degraded window, the zero time if the client isn't degraded.
+func (c *memcachedClient) degradedUntilTime() time.Time {
+	until := c.degradedUntil.Load()
+	if until == 0 || time.Now().UnixNano() >= until {
+		return time.Time{}
+	}
+	return time.Unix(0, until)
+}
+
+// shortCircuit answers the request without sending it when the client is degraded, and reports whether it did.
+func (c *memcachedClient) shortCircuit(e codec.LinkEncoder, d codec.LinkDecoder) (bool, error) {
+	if c.degradedUntilTime().IsZero() {
+		return false, nil
+	}
+
+	if !c.answerDegraded(e, d) {
+		c.degradedFailures.Add(1)
+		return true, fmt.Errorf("%T: %w", e, ErrDegraded)
+	}
+	return true, nil
+}
+
+// answerDegraded fills d with a miss for reads, or a success for writes, and reports whether the request is one of
+// them.
+func (c *memcachedClient) answerDegraded(e codec.LinkEncoder, d codec.LinkDecoder) bool {
+	switch encoder := e.(type) {
+	case *memcache.MetaGetEncoder:
+		decoder, ok := d.(*memcache.MetaGetDecoder)
+		if !ok {
+			return false
+		}
+		decoder.Status = memcache.CacheMiss
+		decoder.Opaque = encoder.Opaque
+		c.degradedReads.Add(1)
+	case *memcache.BulkEncoder[*memcache.MetaGetEncoder]:
+		decoder, ok := d.(*memcache.BulkDecoder[*memcache.MetaGetDecoder])
+		if !ok || len(decoder.Decoders) != len(encoder.Encoders) {
+			return false
+		}
+		for i, get := range decoder.Decoders {
+			get.Status = memcache.CacheMiss
+			get.Opaque = encoder.Encoders[i].Opaque
+		}
+		c.degradedReads.Add(uint64(len(encoder.Encoders)))
+	case *memcache.MetaSetEncoder:
+		decoder, ok := d.(*memcache.MetaSetDecoder)
+		if !ok {
+			return false
+		}
+		decoder.Status = memcache.Stored
+		decoder.Opaque = encoder.Opaque
+		c.droppedWrites.Add(1)
+	case *memcache.BulkEncoder[*memcache.MetaSetEncoder]:
+		// quiet sets only get a response when they fail.
+		if _, ok := d.(*memcache.QuietBulkDecoder[*memcache.MetaSetDecoder]); !ok {
+			return false
+		}
+		c.droppedWrites.Add(uint64(len(encoder.Encoders)))
+	case *memcache.MetaDeleteEncoder:
+		decoder, ok := d.(*memcache.MetaDeleteDecoder)
+		if !ok {
+			return false
+		}
+		decoder.Status = memcache.Deleted
+		decoder.Opaque = encoder.Opaque
+		c.droppedWrites.Add(1)
+	case *memcache.BulkEncoder[*memcache.MetaDeleteEncoder]:
+		decoder, ok := d.(*memcache.BulkDecoder[*memcache.MetaDeleteDecoder])
+		if !ok || len(decoder.Decoders) != len(encoder.Encoders) {
+			return false
+		}
+		for i, del := range decoder.Decoders {
+			del.Status = memcache.Deleted
+			del.Opaque = encoder.Encoders[i].Opaque
+		}
+		c.droppedWrites.Add(uint64(len(encoder.Encoders)))
+	case *memcache.MetaArithmeticEncoder:
+		// the counter can't be read, so it's reported missing.
+		decoder, ok := d.(*memcache.MetaArithmeticDecoder)
+		if !ok {
+			return false
+		}
+		decoder.Status = memcache.NotFound
+		decoder.Opaque = encoder.Opaque
+		c.droppedWrites.Add(1)
+	default:
+		return false
+	}
+	return true
+}
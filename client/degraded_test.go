@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+func TestSetDegraded(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+	srv.Set("k", []byte("v"), 0)
+	before := srv.CommandCount("mg") + srv.CommandCount("ms") + srv.CommandCount("md")
+
+	mc.SetDegraded(time.Minute)
+	assert.False(t, mc.Stats().DegradedUntil.IsZero())
+
+	_, _, err := mc.Get(ctx, "k")
+	assert.ErrorIs(t, err, ErrNotFound)
+	values, err := mc.GetMulti(ctx, []string{"k", "other"})
+	require.NoError(t, err)
+	assert.Empty(t, values)
+
+	require.NoError(t, mc.Set(ctx, "k", []byte("dropped"), 0))
+	statuses, err := mc.SetMulti(ctx, []Item{{Key: "a", Value: []byte("1")}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]memcache.MetadataStatus{"a": memcache.Stored}, statuses)
+	require.NoError(t, mc.Delete(ctx, "k"))
+	_, err = mc.Incr(ctx, "counter", 1, ArithmeticOptions{Vivify: true})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	group := memcache.NewBarrierGroup()
+	group.Add(memcache.CreateMetaNoOpEncoder(), memcache.CreateMetaNoOpDecoder())
+	assert.ErrorIs(t, mc.Pipeline(ctx, group), ErrDegraded)
+
+	// nothing reached memcached.
+	assert.Equal(t, before, srv.CommandCount("mg")+srv.CommandCount("ms")+srv.CommandCount("md"))
+	stored, ok := srv.Get("k")
+	require.True(t, ok)
+	assert.Equal(t, []byte("v"), stored)
+
+	stats := mc.Stats()
+	assert.Equal(t, uint64(3), stats.DegradedReads)
+	assert.Equal(t, uint64(4), stats.DroppedWrites)
+	assert.Equal(t, uint64(1), stats.DegradedFailures)
+	var b strings.Builder
+	require.NoError(t, stats.WritePrometheus(&b))
+	assert.Contains(t, b.String(), "memlink_degraded 1\n")
+	assert.Contains(t, b.String(), "memlink_degraded_requests_total{outcome=\"dropped\"} 4\n")
+
+	mc.SetDegraded(0)
+	value, _, err := mc.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v"), value)
+	assert.True(t, mc.Stats().DegradedUntil.IsZero())
+}
+
+func TestSetDegradedExpires(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+	srv.Set("k", []byte("v"), 0)
+
+	mc.SetDegraded(50 * time.Millisecond)
+	_, _, err := mc.Get(ctx, "k")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.Eventually(t, func() bool {
+		_, _, err := mc.Get(ctx, "k")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
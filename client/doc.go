@@ -0,0 +1,12 @@
+// Package client is the memcached client of memlink.
+//
+// A client is created with NewClient, NewClientFromBackends to configure every backend, e.g. with TLS, or
+// NewClientFromHandover to take over the placement of a client being replaced, and configured with ClientOption
+// functions. Requests are placed on the backends at random unless WithKeyHasher is set; ContextWithRoutingHint routes
+// a single request, and WithRoutingCanary evaluates another placement on live traffic. The encoders and decoders of
+// the requests are in the codec/memcache package and can be reused with the pools package. Cache stores typed values
+// through a client, serialized by a Marshaler, e.g. the JSON, msgpack and protobuf ones of the codec/values package.
+//
+// The types of the connection layer which are part of the client's surface, e.g. Topology or Handover, are aliased
+// in this package, so that users never need the internal packages.
+package client
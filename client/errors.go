@@ -0,0 +1,41 @@
+package client
+
+import "errors"
+
+var (
+	// ErrNotStored is returned when memcached refused to store an item, e.g. appending to a missing key without
+	// auto-vivify.
+	ErrNotStored = errors.New("memcached: item not stored")
+	// ErrAlreadyExists is returned when adding a key which already exists.
+	ErrAlreadyExists = errors.New("memcached: item already exists")
+	// ErrNotFound is returned when getting, replacing or deleting a key which doesn't exist.
+	ErrNotFound = errors.New("memcached: item not found")
+	// ErrValueTooLarge is returned, without contacting memcached, when a value exceeds the client's max value size.
+	ErrValueTooLarge = errors.New("memcached: value exceeds the max value size")
+	// ErrRateLimited is returned, without contacting memcached, when a namespaced view or a tenant exceeds its rate
+	// limit.
+	ErrRateLimited = errors.New("memcached: rate limit exceeded")
+	// ErrDegraded is returned, without contacting memcached, for the requests which are neither reads nor writes, e.g.
+	// pipelines, while the client is degraded, see SetDegraded.
+	ErrDegraded = errors.New("memcached: client is degraded")
+	// ErrFormatMismatch is returned by a Cache reading an item whose client flags tell it was written in another format
+	// than the one of its Marshaler.
+	ErrFormatMismatch = errors.New("memcached: item was written in another format")
+	// ErrCorruptCompressedValue is returned when reading a value flagged with CompressedValueFlag which can't be
+	// decompressed.
+	ErrCorruptCompressedValue = errors.New("memcached: compressed value can't be decompressed")
+	// ErrCorruptValue is returned when reading a value flagged with ChecksummedValueFlag which doesn't match its
+	// checksum.
+	ErrCorruptValue = errors.New("memcached: value doesn't match its checksum")
+	// ErrOverloaded is wrapped by the OverloadError of the requests shed by WithAdmissionControl.
+	ErrOverloaded = errors.New("memcached: client overloaded")
+	// ErrUndecryptableValue is returned when reading a value encrypted with a key version which can't be decrypted,
+	// e.g. because the key of that version isn't provided anymore.
+	ErrUndecryptableValue = errors.New("memcached: value can't be decrypted")
+	// ErrCorruptChunkedValue is returned when reading a value stored in chunks whose manifest can't be parsed, or
+	// which doesn't match its manifest once reassembled.
+	ErrCorruptChunkedValue = errors.New("memcached: chunked value doesn't match its manifest")
+	// ErrUnsupportedMetaFlag is wrapped by the UnsupportedMetaFlagError of the requests rejected by
+	// WithStrictMetaFlags.
+	ErrUnsupportedMetaFlag = errors.New("memcached: meta flag unsupported by the backend version")
+)
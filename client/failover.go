@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/internal/debugcheck"
+	netpkg "github.com/stripe/memlink/internal/net"
+)
+
+// failoverInvalidationTimeout bounds the invalidation of the keys written to the standby cluster, the cutback being
+// postponed to the next health check past it.
+const failoverInvalidationTimeout = 5 * time.Second
+
+// FailoverConfig decides when the client cuts over to the standby cluster of WithStandbyCluster, and back.
+type FailoverConfig = netpkg.FailoverConfig
+
+// FailoverEvent reports that the client sends the requests to another cluster.
+type FailoverEvent = netpkg.FailoverEvent
+
+// FailoverCluster is the primary or the standby cluster of WithStandbyCluster.
+type FailoverCluster = netpkg.FailoverCluster
+
+// FailoverStats are the counters of the cutovers to the standby cluster.
+type FailoverStats = netpkg.FailoverStats
+
+const (
+	FailoverPrimary = netpkg.FailoverPrimary
+	FailoverStandby = netpkg.FailoverStandby
+)
+
+type standbyCluster struct {
+	configs []BackendConfig
+	config  FailoverConfig
+}
+
+// WithStandbyCluster connects to the backends of a standby cluster along with the primary ones, and sends the requests
+// to the standby cluster while fewer than config.CutoverBelow of the primary backends have an established connection.
+// The requests go back to the primary cluster once config.CutBackAt of its backends stayed connected for
+// config.CutBackAfter. The two clusters don't share their keys: the standby cluster misses the keys written before the
+// cutover. The keys written to the standby cluster are deleted from the primary one before the cutback, which is
+// postponed until they are, so that it doesn't serve the values they had before the cutover; the whole primary cluster
+// is flushed instead when too many keys were written, or written to a given backend. Backends and the topology are
+// the ones of the active cluster, while the capabilities are only detected on the primary one. The cutovers are
+// reported to config.OnEvent, and counted by Stats.
+func WithStandbyCluster(backends []BackendConfig, config FailoverConfig) ClientOption {
+	return func(c *memcachedClient) {
+		c.standby = &standbyCluster{configs: backends, config: config}
+	}
+}
+
+// newStandbyPool creates the pool of the standby cluster with the options of the primary one.
+func newStandbyPool(configs []BackendConfig, poolOpts []netpkg.ConnPoolOptions) (netpkg.TCPConnPool, error) {
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("the standby cluster has no backend")
+	}
+	backends, err := resolveBackends(configs)
+	if err != nil {
+		return nil, fmt.Errorf("standby cluster: %w", err)
+	}
+	pool, err := netpkg.NewConnPool(backends, poolOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the connection pool of the standby cluster: %w", err)
+	}
+	return pool, nil
+}
+
+// failoverInvalidator deletes from the primary cluster the keys written to the standby one.
+type failoverInvalidator struct {
+	c *memcachedClient
+}
+
+var _ netpkg.FailoverInvalidator = failoverInvalidator{}
+
+// Invalidate pipelines the deletes of keys, and waits for all of them. The keys which don't exist are invalidated as
+// well.
+func (i failoverInvalidator) Invalidate(primary netpkg.TCPConnPool, keys []memcache.WrittenKey) error {
+	ctx, cancel := context.WithTimeout(context.Background(), failoverInvalidationTimeout)
+	defer cancel()
+
+	type pending struct {
+		encoder *memcache.MetaDeleteEncoder
+		decoder *memcache.MetaDeleteDecoder
+		link    codec.Link
+	}
+	sent := make([]pending, 0, len(keys))
+	var errs []error
+	for _, key := range keys {
+		encoder := memcache.CreateMetaDeleteEncoder()
+		decoder := memcache.CreateMetaDeleteDecoder()
+		encoder.Reset()
+		encoder.Key = key.Key
+		encoder.Base64EncodedKey = key.Base64
+		link, err := i.c.newLink(encoder, decoder, codec.RouteByHashKey(key.RoutingKey))
+		if err == nil {
+			if err = primary.Append(link); err != nil {
+				debugcheck.Release(encoder, decoder, nil)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key=%q: %w", key.Key, err))
+			continue
+		}
+		sent = append(sent, pending{encoder: encoder, decoder: decoder, link: link})
+	}
+
+	for _, p := range sent {
+		err := i.c.wait(ctx, p.link)
+		debugcheck.Release(p.encoder, p.decoder, p.link.Done())
+		if err == nil && !p.decoder.Status.IsDeleted() && !p.decoder.Status.IsMiss() {
+			err = fmt.Errorf("unexpected status %s", p.decoder.Status)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key=%q: %w", p.encoder.Key, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to invalidate the keys written to the standby cluster: %w", err)
+	}
+	return nil
+}
+
+// Flush sends flush_all to every backend of the primary cluster, on their admin connections.
+func (i failoverInvalidator) Flush(primary netpkg.TCPConnPool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), failoverInvalidationTimeout)
+	defer cancel()
+
+	for _, be := range primary.Backends() {
+		encoder := memcache.CreateFlushAllEncoder()
+		decoder := memcache.CreateFlushAllDecoder()
+		if err := i.c.appendVia(ctx, primary.AppendAdmin, be, encoder, decoder); err != nil {
+			return fmt.Errorf("failed to flush the primary cluster: %w", err)
+		}
+		if decoder.HdrLine != "" {
+			return fmt.Errorf("failed to flush the primary cluster: backend %s refused flush_all: %q", be.String(), decoder.HdrLine)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+func TestStandbyCluster(t *testing.T) {
+	standby, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer standby.Close() //nolint: errcheck
+
+	var mu sync.Mutex
+	var events []FailoverCluster
+	mc, primary := newTestClient(t, WithStandbyCluster([]BackendConfig{{Addr: standby.Addr().String()}}, FailoverConfig{
+		CutoverBelow:  1,
+		CutBackAfter:  20 * time.Millisecond,
+		CheckInterval: 5 * time.Millisecond,
+		OnEvent: func(e FailoverEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, e.Active)
+		},
+	}))
+	ctx := context.Background()
+
+	require.NoError(t, mc.Set(ctx, "k", []byte("primary"), 0))
+	_, ok := standby.Get("k")
+	assert.False(t, ok)
+
+	// the primary cluster is down, the requests go to the standby one.
+	addr := primary.Addr().String()
+	require.NoError(t, primary.Close())
+	require.Eventually(t, func() bool {
+		return mc.Stats().Failover.Active == FailoverStandby
+	}, 5*time.Second, 5*time.Millisecond)
+	require.NoError(t, mc.Set(ctx, "k", []byte("standby"), 0))
+	value, ok := standby.Get("k")
+	require.True(t, ok)
+	assert.Equal(t, []byte("standby"), value)
+
+	// it's back with the items it had, and the requests with it. The key written to the standby cluster is deleted
+	// from it first, rather than served with its previous value.
+	restarted, err := fakeserver.StartAt(addr)
+	require.NoError(t, err)
+	defer restarted.Close() //nolint: errcheck
+	restarted.Set("k", []byte("primary"), 0)
+	restarted.Set("other", []byte("primary"), 0)
+	require.Eventually(t, func() bool {
+		return mc.Stats().Failover.Active == FailoverPrimary
+	}, 10*time.Second, 5*time.Millisecond)
+	_, _, err = mc.Get(ctx, "k")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, ok = restarted.Get("other")
+	assert.True(t, ok)
+	assert.Zero(t, restarted.CommandCount("flush_all"))
+	require.NoError(t, mc.Set(ctx, "k", []byte("primary again"), 0))
+	value, ok = restarted.Get("k")
+	require.True(t, ok)
+	assert.Equal(t, []byte("primary again"), value)
+
+	mu.Lock()
+	assert.Equal(t, []FailoverCluster{FailoverStandby, FailoverPrimary}, events)
+	mu.Unlock()
+
+	stats := mc.Stats()
+	assert.Equal(t, uint64(1), stats.Failover.Cutovers)
+	assert.Equal(t, uint64(1), stats.Failover.Cutbacks)
+	var b strings.Builder
+	require.NoError(t, stats.WritePrometheus(&b))
+	assert.Contains(t, b.String(), "memlink_failover_active{cluster=\"primary\"} 1\n")
+	assert.Contains(t, b.String(), "memlink_failover_switches_total{to=\"standby\"} 1\n")
+}
+
+func TestStandbyClusterFlush(t *testing.T) {
+	standby, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer standby.Close() //nolint: errcheck
+
+	mc, primary := newTestClient(t, WithStandbyCluster([]BackendConfig{{Addr: standby.Addr().String()}}, FailoverConfig{
+		CutoverBelow:  1,
+		CutBackAfter:  20 * time.Millisecond,
+		CheckInterval: 5 * time.Millisecond,
+	}))
+	ctx := context.Background()
+
+	addr := primary.Addr().String()
+	require.NoError(t, primary.Close())
+	require.Eventually(t, func() bool {
+		return mc.Stats().Failover.Active == FailoverStandby
+	}, 5*time.Second, 5*time.Millisecond)
+	// the key written to a given backend of the standby cluster can't be placed on the primary one.
+	routed := ContextWithRoutingHint(ctx, codec.RouteToBackend(standby.Addr().String()))
+	require.NoError(t, mc.Set(routed, "k", []byte("standby"), 0))
+
+	restarted, err := fakeserver.StartAt(addr)
+	require.NoError(t, err)
+	defer restarted.Close() //nolint: errcheck
+	restarted.Set("k", []byte("primary"), 0)
+	require.Eventually(t, func() bool {
+		return mc.Stats().Failover.Active == FailoverPrimary
+	}, 10*time.Second, 5*time.Millisecond)
+	assert.Equal(t, 1, restarted.CommandCount("flush_all"))
+	_, ok := restarted.Get("k")
+	assert.False(t, ok)
+}
+
+func TestStandbyClusterInvalid(t *testing.T) {
+	srv, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv.Close() //nolint: errcheck
+
+	_, err = NewClient([]string{srv.Addr().String()}, 1, WithStandbyCluster(nil, FailoverConfig{}))
+	assert.ErrorContains(t, err, "the standby cluster has no backend")
+}
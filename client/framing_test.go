@@ -0,0 +1,122 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+func TestTolerantFraming(t *testing.T) {
+	srv, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv.Close() //nolint: errcheck
+	srv.Set("k", []byte("value"), 0)
+	ctx := context.Background()
+
+	crc := func(value []byte) string {
+		return fmt.Sprintf("crc32c=%08x", crc32.Checksum(value, crc32.MakeTable(crc32.Castagnoli)))
+	}
+	srv.Annotate(func(value []byte) string {
+		return "trace=abc " + crc(value) + " via=proxy-1"
+	})
+
+	// the annotations are mistaken for flags otherwise.
+	strict, err := NewClient([]string{srv.Addr().String()}, 1)
+	require.NoError(t, err)
+	defer strict.Close() //nolint: errcheck
+	_, _, err = strict.Get(ctx, "k")
+	assert.Error(t, err)
+
+	mc, err := NewClient([]string{srv.Addr().String()}, 1, WithTolerantFraming(ChecksumVerify))
+	require.NoError(t, err)
+	defer mc.Close() //nolint: errcheck
+	value, _, err := mc.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+
+	srv.Annotate(func([]byte) string {
+		return "crc32c=00000000"
+	})
+	_, _, err = mc.Get(ctx, "k")
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	// the connection is re-established.
+	srv.Annotate(nil)
+	require.Eventually(t, func() bool {
+		value, _, err = mc.Get(ctx, "k")
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []byte("value"), value)
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	srv, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv.Close() //nolint: errcheck
+	srv.Set("small", []byte("value"), 0)
+	srv.Set("large", bytes.Repeat([]byte("x"), 100), 0)
+	ctx := context.Background()
+
+	mc, err := NewClient([]string{srv.Addr().String()}, 1, WithMaxResponseSize(64))
+	require.NoError(t, err)
+	defer mc.Close() //nolint: errcheck
+
+	_, _, err = mc.Get(ctx, "large")
+	var tooLarge *ResponseTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, srv.Addr().String(), tooLarge.Backend)
+	assert.Equal(t, 100, tooLarge.Size)
+	assert.Equal(t, 64, tooLarge.Limit)
+
+	// the connection is re-established.
+	var value []byte
+	require.Eventually(t, func() bool {
+		value, _, err = mc.Get(ctx, "small")
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []byte("value"), value)
+}
+
+func TestMaxResponseSize_Default(t *testing.T) {
+	srv, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv.Close() //nolint: errcheck
+	srv.SetStats("settings", map[string]string{"item_size_max": "64"})
+	srv.Set("large", bytes.Repeat([]byte("x"), 100), 0)
+	srv.Set("huge", bytes.Repeat([]byte("x"), DefaultMaxResponseSize+1), 0)
+	ctx := context.Background()
+
+	// the limit is the item size limit detected for the backend.
+	mc, err := NewClient([]string{srv.Addr().String()}, 1)
+	require.NoError(t, err)
+	defer mc.Close() //nolint: errcheck
+	_, _, err = mc.Get(ctx, "large")
+	var tooLarge *ResponseTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, 64, tooLarge.Limit)
+
+	// or the default one when it wasn't detected.
+	undetected, err := NewClient([]string{srv.Addr().String()}, 1, WithoutCapabilityDetection())
+	require.NoError(t, err)
+	defer undetected.Close() //nolint: errcheck
+	_, _, err = undetected.Get(ctx, "large")
+	require.NoError(t, err)
+	_, _, err = undetected.Get(ctx, "huge")
+	require.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, DefaultMaxResponseSize, tooLarge.Limit)
+
+	unlimited, err := NewClient([]string{srv.Addr().String()}, 1, WithoutMaxResponseSize())
+	require.NoError(t, err)
+	defer unlimited.Close() //nolint: errcheck
+	value, _, err := unlimited.Get(ctx, "huge")
+	require.NoError(t, err)
+	assert.Len(t, value, DefaultMaxResponseSize+1)
+}
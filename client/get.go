@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/pools"
+)
+
+// GetResult is the outcome of GetWithTTL.
+type GetResult struct {
+	Found               bool
+	Value               []byte
+	RemainingTTLSeconds int32 // -1 if the item never expires.
+	CasId               uint64
+	ClientFlags         uint64
+	// Stale reports whether the value is older than its soft TTL, see WithSoftTTL.
+	Stale bool
+	// WrittenAt is when the value was written, zero unless it was wrapped in a soft TTL envelope.
+	WrittenAt time.Time
+}
+
+// GetWithTTL fetches key along with its remaining TTL and CAS id. A miss is not an error, it is reported with
+// Found set to false.
+func (c *memcachedClient) GetWithTTL(ctx context.Context, key string) (GetResult, error) {
+	encoder := getEncoderPool.Get()
+	decoder := getDecoderPool.Get()
+	defer pools.Release(ctx, getEncoderPool, encoder, getDecoderPool, decoder)
+
+	encoder.Key = key
+	encoder.FetchValue = true
+	encoder.FetchRemainingTTL = true
+	encoder.FetchCasId = true
+	encoder.FetchClientFlags = true
+	c.assignOpaque(ctx, &encoder.Opaque)
+
+	if err := c.append(ctx, encoder, decoder); err != nil {
+		return GetResult{}, fmt.Errorf("GetWithTTL operation failed: %w", err)
+	}
+	if c.mirror != nil {
+		c.mirror.observe(ctx, encoder, decoder)
+	}
+
+	switch decoder.Status {
+	case memcache.CacheHit:
+		value, clientFlags := decoder.Value, decoder.ClientFlags
+		if c.chunker != nil && ChunkedValueFlag.IsSet(clientFlags) {
+			var found bool
+			var err error
+			value, found, err = c.assembleChunked(ctx, key, value)
+			if err != nil {
+				return GetResult{}, fmt.Errorf("GetWithTTL operation failed: %w", err)
+			}
+			if !found {
+				return GetResult{}, nil
+			}
+			clientFlags = ChunkedValueFlag.Clear(clientFlags)
+		}
+		value, envelope := c.unwrapValue(value)
+		return GetResult{
+			Found:               true,
+			Value:               value,
+			RemainingTTLSeconds: decoder.RemainingTTLSeconds,
+			CasId:               decoder.CasId,
+			ClientFlags:         clientFlags,
+			Stale:               envelope.stale(time.Now()),
+			WrittenAt:           envelope.writtenAt,
+		}, nil
+	case memcache.CacheMiss:
+		return GetResult{}, nil
+	default:
+		return GetResult{}, fmt.Errorf("GetWithTTL operation failed: unexpected status for key=%q: %s %q", key, decoder.Status, decoder.HdrLine)
+	}
+}
+
+// GetAndTouchResult is the outcome of GetAndTouch.
+type GetAndTouchResult struct {
+	Found       bool
+	Value       []byte
+	ClientFlags uint64
+	// PreviousTTLSeconds is the remaining TTL of the item before the touch, -1 if it never expired.
+	PreviousTTLSeconds int32
+	// RemainingTTLSeconds is the TTL set by the touch, -1 if the item never expires.
+	RemainingTTLSeconds int32
+}
+
+// GetAndTouch fetches the value of key and sets its TTL to ttl seconds, 0 meaning it never expires, in a single
+// request. A miss is not an error, it is reported with Found set to false.
+func (c *memcachedClient) GetAndTouch(ctx context.Context, key string, ttl int32) (GetAndTouchResult, error) {
+	encoder := getEncoderPool.Get()
+	decoder := getDecoderPool.Get()
+	defer pools.Release(ctx, getEncoderPool, encoder, getDecoderPool, decoder)
+
+	if c.ttlPolicy != nil {
+		ttl = c.ttlPolicy(key, max(ttl, 0))
+	}
+	encoder.Key = key
+	encoder.FetchValue = true
+	encoder.FetchClientFlags = true
+	encoder.FetchRemainingTTL = true
+	encoder.TTLBeforeUpdate = true
+	encoder.UpdateTTL = max(ttl, 0)
+	c.assignOpaque(ctx, &encoder.Opaque)
+
+	if err := c.append(ctx, encoder, decoder); err != nil {
+		return GetAndTouchResult{}, fmt.Errorf("GetAndTouch operation failed: %w", err)
+	}
+
+	switch decoder.Status {
+	case memcache.CacheHit:
+		value, _ := c.unwrapValue(decoder.Value)
+		remaining := encoder.UpdateTTL
+		if remaining == 0 {
+			remaining = -1
+		}
+		return GetAndTouchResult{
+			Found:               true,
+			Value:               value,
+			ClientFlags:         decoder.ClientFlags,
+			PreviousTTLSeconds:  decoder.RemainingTTLSeconds,
+			RemainingTTLSeconds: remaining,
+		}, nil
+	case memcache.CacheMiss:
+		return GetAndTouchResult{}, nil
+	default:
+		return GetAndTouchResult{}, fmt.Errorf("GetAndTouch operation failed: unexpected status for key=%q: %s %q", key, decoder.Status, decoder.HdrLine)
+	}
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+func TestGetWithTTL(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+
+	srv.Set("expiring", []byte("a"), 100)
+	srv.Set("forever", []byte("b"), 0)
+
+	result, err := mc.GetWithTTL(ctx, "expiring")
+	require.NoError(t, err)
+	assert.True(t, result.Found)
+	assert.Equal(t, "a", string(result.Value))
+	assert.InDelta(t, 100, result.RemainingTTLSeconds, 1)
+	assert.NotZero(t, result.CasId)
+
+	result, err = mc.GetWithTTL(ctx, "forever")
+	require.NoError(t, err)
+	assert.True(t, result.Found)
+	assert.Equal(t, int32(-1), result.RemainingTTLSeconds)
+
+	result, err = mc.GetWithTTL(ctx, "missing")
+	require.NoError(t, err)
+	assert.Equal(t, GetResult{}, result)
+}
+
+func TestGetAndTouch(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+
+	srv.Set("session", []byte("a"), 100)
+
+	result, err := mc.GetAndTouch(ctx, "session", 300)
+	require.NoError(t, err)
+	assert.True(t, result.Found)
+	assert.Equal(t, "a", string(result.Value))
+	assert.InDelta(t, 100, result.PreviousTTLSeconds, 1)
+	assert.Equal(t, int32(300), result.RemainingTTLSeconds)
+
+	ttl, err := mc.GetWithTTL(ctx, "session")
+	require.NoError(t, err)
+	assert.InDelta(t, 300, ttl.RemainingTTLSeconds, 1)
+
+	result, err = mc.GetAndTouch(ctx, "session", 0)
+	require.NoError(t, err)
+	assert.InDelta(t, 300, result.PreviousTTLSeconds, 1)
+	assert.Equal(t, int32(-1), result.RemainingTTLSeconds)
+
+	result, err = mc.GetAndTouch(ctx, "missing", 300)
+	require.NoError(t, err)
+	assert.Equal(t, GetAndTouchResult{}, result)
+}
+
+func TestWithInterner(t *testing.T) {
+	interner := memcache.NewInterner(16)
+	mc, srv := newTestClient(t, WithInterner(interner))
+	other, otherSrv := newTestClient(t)
+	ctx := context.Background()
+
+	srv.Set("key", []byte("a"), 0)
+	otherSrv.Set("key", []byte("a"), 0)
+
+	get := func(mc MemcachedClient) string {
+		encoder := memcache.CreateMetaGetEncoder()
+		encoder.Reset()
+		encoder.Key = "key"
+		encoder.FetchKey = true
+		decoder := memcache.CreateMetaGetDecoder()
+		require.NoError(t, mc.MetaGet(ctx, encoder, decoder))
+		require.Equal(t, memcache.CacheHit, decoder.Status)
+		return decoder.ItemKey
+	}
+	first, second := get(mc), get(mc)
+	assert.Equal(t, "key", first)
+	assert.Same(t, unsafe.StringData(first), unsafe.StringData(second))
+	assert.Equal(t, memcache.InternerStats{Hits: 1, Misses: 1, Entries: 1}, interner.Stats())
+
+	// the other clients don't intern.
+	assert.NotSame(t, unsafe.StringData(first), unsafe.StringData(get(other)))
+	assert.Equal(t, uint64(1), interner.Stats().Misses)
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"fmt"
+
+	netpkg "github.com/stripe/memlink/internal/net"
+)
+
+// Handover is the state of the client's pool exported on Close: its backends in placement order, with their detected
+// capabilities and the load counters of their connections.
+type Handover = netpkg.Handover
+
+// HandoverBackend is the state of a backend in a Handover.
+type HandoverBackend = netpkg.HandoverBackend
+
+// WithHandover calls fn with the state of the pool when the client is closed, before its connections are. It's meant
+// for blue/green deploys: the replacement process builds its client with NewClientFromHandover, so that keys map to the
+// same backends and the deploy doesn't cause a miss storm. fn is called once the mirrored requests and the
+// invalidation events are flushed, so the counters are final.
+func WithHandover(fn func(Handover)) ClientOption {
+	return func(c *memcachedClient) {
+		c.handover = fn
+	}
+}
+
+// NewClientFromHandover creates a client with the backends of a handover, in the same placement order and with the
+// same weight and number of connections each.
+func NewClientFromHandover(h Handover, opts ...ClientOption) (MemcachedClient, error) {
+	if len(h.Backends) == 0 {
+		return nil, fmt.Errorf("the handover has no backend")
+	}
+
+	configs := make([]BackendConfig, 0, len(h.Backends))
+	for _, hb := range h.Backends {
+		configs = append(configs, BackendConfig{Addr: hb.Addr, NumConns: hb.NumConns, Weight: hb.Weight})
+	}
+	return NewClientFromBackends(configs, opts...)
+}
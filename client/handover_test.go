@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+func TestHandover(t *testing.T) {
+	srv1, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv1.Close() //nolint: errcheck
+	srv2, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv2.Close() //nolint: errcheck
+
+	var handover Handover
+	addrs := []string{srv2.Addr().String(), srv1.Addr().String()}
+	mc, err := NewClient(addrs, 2, WithHandover(func(h Handover) {
+		handover = h
+	}))
+	require.NoError(t, err)
+	_, err = mc.SetMulti(context.Background(), []Item{{Key: "a", Value: []byte("1")}})
+	require.NoError(t, err)
+	require.NoError(t, mc.Close())
+
+	assert.Equal(t, addrs, handover.Addresses())
+	var appends uint64
+	for _, be := range handover.Backends {
+		assert.Equal(t, 2, be.NumConns)
+		assert.True(t, be.HasCapabilities)
+		assert.True(t, be.Capabilities.MetaProtocol)
+		appends += be.Stats.Appends
+	}
+	// the capability probes and the set.
+	assert.GreaterOrEqual(t, appends, uint64(3))
+
+	replacement, err := NewClientFromHandover(handover)
+	require.NoError(t, err)
+	defer replacement.Close() //nolint: errcheck
+	assert.Equal(t, addrs, replacement.(*memcachedClient).pool.Handover().Addresses())
+
+	_, err = NewClientFromHandover(Handover{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/stripe/memlink/codec"
+	netpkg "github.com/stripe/memlink/internal/net"
+)
+
+// ErrNotReady is returned by Ready when fewer backends than the readiness quorum answered the probes.
+var ErrNotReady = errors.New("memcached: not enough backends are ready")
+
+// WithReadinessQuorum sets the fraction of the backends which must be healthy for Healthy and Ready to succeed, all of
+// them by default. A quorum below 1 lets a pod keep serving with a cache backend down, the keys of the missing backends
+// being misses.
+func WithReadinessQuorum(quorum float64) ClientOption {
+	return func(c *memcachedClient) {
+		c.readinessQuorum = quorum
+	}
+}
+
+// quorumOf returns the number of backends out of n which must be healthy.
+func (c *memcachedClient) quorumOf(n int) int {
+	quorum := c.readinessQuorum
+	if quorum <= 0 || quorum > 1 {
+		quorum = 1
+	}
+	return int(math.Ceil(quorum * float64(n)))
+}
+
+// Healthy reports whether at least the readiness quorum of the backends has an established connection. It only reads
+// the state of the connections, without sending any request, so it's cheap enough for a liveness probe.
+func (c *memcachedClient) Healthy() bool {
+	topology := c.pool.Topology()
+	healthy := 0
+	for _, be := range topology.Backends {
+		if be.Healthy() {
+			healthy++
+		}
+	}
+	return len(topology.Backends) > 0 && healthy >= c.quorumOf(len(topology.Backends))
+}
+
+// Ready sends a version request to every backend and returns an error wrapping ErrNotReady, and the failures of the
+// backends, unless at least the readiness quorum of them answered before ctx is done.
+func (c *memcachedClient) Ready(ctx context.Context) error {
+	backends := c.pool.Backends()
+	errs := make([]error, len(backends))
+
+	var wg sync.WaitGroup
+	for i, be := range backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.probeVersion(ctx, backendChain{pool: c.pool, be: be}); err != nil {
+				errs[i] = fmt.Errorf("backend=%s: %w", be.String(), err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	ready := 0
+	for _, err := range errs {
+		if err == nil {
+			ready++
+		}
+	}
+	if len(backends) > 0 && ready >= c.quorumOf(len(backends)) {
+		return nil
+	}
+	return fmt.Errorf("%w: %d of %d backends: %w", ErrNotReady, ready, len(backends), errors.Join(errs...))
+}
+
+// backendChain appends the links to a backend of the pool.
+type backendChain struct {
+	pool netpkg.TCPConnPool
+	be   *netpkg.Backend
+}
+
+func (b backendChain) Append(link codec.Link) error {
+	return b.pool.AppendTo(b.be, link)
+}
+
+// LivenessHandler serves 200 while mc is Healthy, and 503 otherwise, for an HTTP liveness probe.
+func LivenessHandler(mc MemcachedClient) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !mc.Healthy() {
+			http.Error(w, "memcached backends unreachable", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// ReadinessHandler serves 200 once mc is Ready, and 503 with the failures otherwise, for an HTTP readiness probe. The
+// probes are bounded by timeout, on top of the deadline of the request.
+func ReadinessHandler(mc MemcachedClient, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		if err := mc.Ready(ctx); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
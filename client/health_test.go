@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReady(t *testing.T) {
+	mc, servers := newTwoServerClient(t)
+	half, err := NewClient([]string{servers[0].Addr().String(), servers[1].Addr().String()}, 1,
+		WithReadinessQuorum(0.5), WithoutCapabilityDetection())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = half.Close() })
+
+	require.NoError(t, mc.Ready(context.Background()))
+
+	servers[1].SetLatency("version", time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = mc.WithNamespace("billing").Ready(ctx)
+	assert.ErrorIs(t, err, ErrNotReady)
+	assert.ErrorContains(t, err, "1 of 2 backends")
+	assert.ErrorContains(t, err, "backend="+servers[1].Addr().String())
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, half.Ready(ctx))
+}
+
+func TestHealthy(t *testing.T) {
+	mc, servers := newTwoServerClient(t)
+	half, err := NewClient([]string{servers[0].Addr().String(), servers[1].Addr().String()}, 1,
+		WithReadinessQuorum(0.5))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = half.Close() })
+
+	assert.True(t, mc.Healthy())
+	require.NoError(t, servers[1].Close())
+	assert.Eventually(t, func() bool { return !mc.Healthy() }, time.Second, 10*time.Millisecond)
+	assert.True(t, half.Healthy())
+
+	require.NoError(t, half.Close())
+	assert.False(t, half.Healthy())
+}
+
+func TestProbeHandlers(t *testing.T) {
+	mc, servers := newTwoServerClient(t)
+
+	rec := httptest.NewRecorder()
+	ReadinessHandler(mc, time.Second).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	require.NoError(t, servers[0].Close())
+	assert.Eventually(t, func() bool {
+		rec := httptest.NewRecorder()
+		LivenessHandler(mc).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/live", nil))
+		return rec.Code == http.StatusServiceUnavailable
+	}, time.Second, 10*time.Millisecond)
+
+	rec = httptest.NewRecorder()
+	ReadinessHandler(mc, 100*time.Millisecond).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrNotReady.Error())
+}
@@ -0,0 +1,228 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+const (
+	// invalidationQueueSize bounds the events waiting to be published, the events beyond are dropped.
+	invalidationQueueSize = 10000
+	// maxInvalidationBatch bounds the number of events handed to the sink at once.
+	maxInvalidationBatch = 100
+	// invalidationPublishTimeout bounds a call to the sink.
+	invalidationPublishTimeout = 5 * time.Second
+)
+
+// InvalidationOp is the operation which changed a key.
+type InvalidationOp string
+
+const (
+	// InvalidationSet is a successful set, including adds, replaces, appends and prepends.
+	InvalidationSet InvalidationOp = "set"
+	// InvalidationDelete is a successful delete, including the ones marking the item stale.
+	InvalidationDelete InvalidationOp = "delete"
+)
+
+// InvalidationEvent tells that a key was changed by the client.
+type InvalidationEvent struct {
+	Op  InvalidationOp
+	Key string
+	// Base64 reports whether Key is base64 encoded, as it was sent to memcached.
+	Base64 bool
+	Time   time.Time
+}
+
+// InvalidationSink publishes invalidation events to a broker, e.g. a Kafka topic or an SNS topic, so that downstream
+// caches can drop their copies. memlink doesn't depend on any broker, the sink adapts to one.
+type InvalidationSink interface {
+	// Publish publishes events, in the order the keys were changed. It's called by a single routine of the client,
+	// the events failing to be published are counted and not retried.
+	Publish(ctx context.Context, events []InvalidationEvent) error
+}
+
+// WithInvalidationSink publishes an event to sink for every key successfully set or deleted through the client,
+// including the deletes of DeleteByPrefix. Events are published in the background, in batches, so the sink doesn't
+// add to the latency of the requests: when it falls behind by more than 10000 events, the events beyond are dropped
+// and counted in Stats. The events still queued are published when the client is closed.
+func WithInvalidationSink(sink InvalidationSink) ClientOption {
+	return func(c *memcachedClient) {
+		c.invalidations = &invalidationPublisher{
+			sink:   sink,
+			events: make(chan InvalidationEvent, invalidationQueueSize),
+			done:   make(chan struct{}),
+		}
+	}
+}
+
+// InvalidationStats are the counters of the invalidation events of a client.
+type InvalidationStats struct {
+	// Published is the number of events the sink published.
+	Published uint64
+	// Dropped is the number of events dropped because the sink fell behind.
+	Dropped uint64
+	// Errors is the number of events the sink failed to publish.
+	Errors uint64
+}
+
+type invalidationPublisher struct {
+	sink   InvalidationSink
+	events chan InvalidationEvent
+	done   chan struct{}
+	logger *zap.Logger
+
+	mu     sync.RWMutex
+	closed bool // protected by mu, events is closed once set
+
+	published atomic.Uint64
+	dropped   atomic.Uint64
+	errors    atomic.Uint64
+}
+
+func (p *invalidationPublisher) start(logger *zap.Logger) {
+	p.logger = logger
+	go p.run()
+}
+
+func (p *invalidationPublisher) run() {
+	defer close(p.done)
+
+	batch := make([]InvalidationEvent, 0, maxInvalidationBatch)
+	for event := range p.events {
+		batch = append(batch[:0], event)
+	drain:
+		for len(batch) < maxInvalidationBatch {
+			select {
+			case event, ok := <-p.events:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, event)
+			default:
+				break drain
+			}
+		}
+		p.publish(batch)
+	}
+}
+
+func (p *invalidationPublisher) publish(batch []InvalidationEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), invalidationPublishTimeout)
+	defer cancel()
+
+	if err := p.sink.Publish(ctx, batch); err != nil {
+		p.errors.Add(uint64(len(batch)))
+		p.logger.Warn("failed to publish invalidation events", zap.Int("events", len(batch)), zap.Error(err))
+		return
+	}
+	p.published.Add(uint64(len(batch)))
+}
+
+// close publishes the events still queued and stops the publishing routine.
+func (p *invalidationPublisher) close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.events)
+	}
+	p.mu.Unlock()
+	<-p.done
+}
+
+func (p *invalidationPublisher) emit(op InvalidationOp, key string, base64 bool, now time.Time) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		p.dropped.Add(1)
+		return
+	}
+
+	select {
+	case p.events <- InvalidationEvent{Op: op, Key: key, Base64: base64, Time: now}:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+// observe emits the events of the keys changed by a successful request.
+func (p *invalidationPublisher) observe(e codec.LinkEncoder, d codec.LinkDecoder) {
+	now := time.Now()
+	switch e := e.(type) {
+	case *memcache.MetaSetEncoder:
+		if d, ok := d.(*memcache.MetaSetDecoder); ok && d.Status.IsStored() {
+			p.emit(InvalidationSet, setKey(e), e.Base64EncodedKey, now)
+		}
+	case *memcache.MetaDeleteEncoder:
+		if d, ok := d.(*memcache.MetaDeleteDecoder); ok && d.Status.IsDeleted() {
+			p.emit(InvalidationDelete, deleteKey(e), e.Base64EncodedKey, now)
+		}
+	case *memcache.BulkEncoder[*memcache.MetaSetEncoder]:
+		var decoders []*memcache.MetaSetDecoder
+		switch d := d.(type) {
+		case *memcache.BulkDecoder[*memcache.MetaSetDecoder]:
+			decoders = d.Decoders
+		case *memcache.QuietBulkDecoder[*memcache.MetaSetDecoder]:
+			decoders = d.Decoders
+		default:
+			return
+		}
+		statuses := make(map[uint64]memcache.MetadataStatus, len(decoders))
+		for _, decoder := range decoders {
+			statuses[decoder.Opaque] = decoder.Status
+		}
+		for _, encoder := range e.Encoders {
+			if succeeded(statuses, encoder.Opaque, encoder.Quiet, memcache.Stored) {
+				p.emit(InvalidationSet, setKey(encoder), encoder.Base64EncodedKey, now)
+			}
+		}
+	case *memcache.BulkEncoder[*memcache.MetaDeleteEncoder]:
+		d, ok := d.(*memcache.BulkDecoder[*memcache.MetaDeleteDecoder])
+		if !ok {
+			return
+		}
+		statuses := make(map[uint64]memcache.MetadataStatus, len(d.Decoders))
+		for _, decoder := range d.Decoders {
+			statuses[decoder.Opaque] = decoder.Status
+		}
+		for _, encoder := range e.Encoders {
+			if succeeded(statuses, encoder.Opaque, false, memcache.Deleted) {
+				p.emit(InvalidationDelete, deleteKey(encoder), encoder.Base64EncodedKey, now)
+			}
+		}
+	}
+}
+
+// succeeded reports whether the request of a bulk request with the given opaque succeeded: quiet requests only get a
+// response when they fail.
+func succeeded(statuses map[uint64]memcache.MetadataStatus, opaque uint64, quiet bool, success memcache.MetadataStatus) bool {
+	status, ok := statuses[opaque]
+	if !ok {
+		return quiet
+	}
+	return status == success
+}
+
+func (p *invalidationPublisher) snapshot() *InvalidationStats {
+	return &InvalidationStats{
+		Published: p.published.Load(),
+		Dropped:   p.dropped.Load(),
+		Errors:    p.errors.Load(),
+	}
+}
+
+func deleteKey(encoder *memcache.MetaDeleteEncoder) string {
+	if !encoder.ValidatedKey.IsZero() {
+		return encoder.ValidatedKey.String()
+	}
+	if encoder.BinaryKey != nil {
+		return string(encoder.BinaryKey)
+	}
+	return encoder.Key
+}
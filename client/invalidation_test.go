@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+type recordingSink struct {
+	mu     sync.Mutex
+	events []InvalidationEvent
+	err    error
+}
+
+func (s *recordingSink) Publish(_ context.Context, events []InvalidationEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *recordingSink) published() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	published := make([]string, 0, len(s.events))
+	for _, event := range s.events {
+		published = append(published, string(event.Op)+" "+event.Key)
+	}
+	return published
+}
+
+func TestInvalidationSink(t *testing.T) {
+	srv, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv.Close() //nolint: errcheck
+	sink := &recordingSink{}
+	mc, err := NewClient([]string{srv.Addr().String()}, 1, WithInvalidationSink(sink))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	srv.Set("existing", []byte("1"), 0)
+	_, err = mc.SetMulti(ctx, []Item{{Key: "a", Value: []byte("1")}, {Key: "b", Value: []byte("2")}})
+	require.NoError(t, err)
+	// neither the failed add nor the delete of a missing key changed anything.
+	assert.ErrorIs(t, mc.Add(ctx, Item{Key: "existing", Value: []byte("2")}), ErrAlreadyExists)
+	_, err = mc.DeleteMulti(ctx, []string{"a", "missing"})
+	require.NoError(t, err)
+	_, err = mc.WithNamespace("ns").DeleteMulti(ctx, []string{"b"})
+	require.NoError(t, err)
+	_, err = mc.DeleteByPrefix(ctx, "exist", 100)
+	require.NoError(t, err)
+
+	// closing the client publishes the events still queued.
+	require.NoError(t, mc.Close())
+	assert.Equal(t, []string{"set a", "set b", "delete a", "delete existing"}, sink.published())
+	stats := mc.Stats().Invalidations
+	require.NotNil(t, stats)
+	assert.Equal(t, InvalidationStats{Published: 4}, *stats)
+}
+
+func TestInvalidationSinkErrors(t *testing.T) {
+	sink := &recordingSink{err: errors.New("broker unavailable")}
+	mc, _ := newTestClient(t, WithInvalidationSink(sink))
+
+	_, err := mc.SetMulti(context.Background(), []Item{{Key: "a", Value: []byte("1")}})
+	require.NoError(t, err)
+	require.NoError(t, mc.Close())
+
+	assert.Equal(t, InvalidationStats{Errors: 1}, *mc.Stats().Invalidations)
+	assert.Empty(t, sink.published())
+}
@@ -0,0 +1,15 @@
+package client
+
+import "time"
+
+// Item is a key and the value stored for it in memcached, along with its metadata.
+type Item struct {
+	Key         string
+	Value       []byte
+	TTL         int32  // in seconds, 0 means the item never expires, the longer ones are relative unless WithAbsoluteTTLs is set.
+	ClientFlags uint64 // opaque to memcached, stored and returned along with the value.
+	// ExpireAt takes precedence over TTL when not zero, the item then expires at that time.
+	ExpireAt time.Time
+	// SoftTTL overrides the soft TTL of the client for this item, see WithSoftTTL.
+	SoftTTL time.Duration
+}
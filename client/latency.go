@@ -0,0 +1,272 @@
+package client
+
+import (
+	"context"
+	"math/bits"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/stripe/memlink/codec"
+)
+
+const (
+	// latencySubBucketBits sets the precision of the latency histograms: every power of two is split in 64 buckets, so
+	// a latency is recorded with a relative error below 1/64.
+	latencySubBucketBits  = 7
+	latencySubBucketCount = 1 << latencySubBucketBits
+	latencySubBucketHalf  = latencySubBucketCount / 2
+	// latencyBucketCount covers every positive int64 latency in nanoseconds.
+	latencyBucketCount = latencySubBucketCount + (63-latencySubBucketBits)*latencySubBucketHalf
+)
+
+// WithLatencyHistograms records the latency of the requests sent by the client in HDR histograms, one per operation
+// and backend, which keep their percentiles accurate up to the p99.9 and beyond. The histograms are reported by Stats.
+// When logInterval is positive, their percentiles are logged at that interval as well.
+func WithLatencyHistograms(logInterval time.Duration) ClientOption {
+	return func(c *memcachedClient) {
+		c.latencies = &latencyRecorder{
+			logInterval: logInterval,
+			histograms:  make(map[string]*latencyHistogram),
+		}
+	}
+}
+
+// LatencyBucket is a bucket of a latency histogram.
+type LatencyBucket struct {
+	// UpperBound is the inclusive upper bound of the bucket, the lower bound being the upper bound of the previous one.
+	UpperBound time.Duration
+	Count      uint64
+}
+
+// LatencyHistogram is a snapshot of the latencies of the requests of an operation sent to a backend.
+type LatencyHistogram struct {
+	Operation string
+	Backend   string
+	Count     uint64
+	Min       time.Duration
+	Max       time.Duration
+	Sum       time.Duration
+	// Buckets are the buckets holding at least one request, by increasing latency.
+	Buckets []LatencyBucket
+}
+
+// Mean returns the mean latency, 0 when no request was recorded.
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Percentile returns the latency under which p percent of the requests completed, e.g. Percentile(99.9), 0 when no
+// request was recorded.
+func (h LatencyHistogram) Percentile(p float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := uint64(p / 100 * float64(h.Count))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for _, bucket := range h.Buckets {
+		seen += bucket.Count
+		if seen >= rank {
+			return min(max(bucket.UpperBound, h.Min), h.Max)
+		}
+	}
+	return h.Max
+}
+
+type latencyHistogram struct {
+	operation string
+	backend   string
+
+	count  atomic.Uint64
+	sum    atomic.Int64
+	min    atomic.Int64
+	max    atomic.Int64
+	counts [latencyBucketCount]atomic.Uint64
+}
+
+func newLatencyHistogram(operation, backend string) *latencyHistogram {
+	h := &latencyHistogram{operation: operation, backend: backend}
+	h.min.Store(-1)
+	return h
+}
+
+func (h *latencyHistogram) observe(latency time.Duration) {
+	v := max(int64(latency), 0)
+	h.counts[latencyBucket(uint64(v))].Add(1)
+	h.count.Add(1)
+	h.sum.Add(v)
+	for {
+		current := h.min.Load()
+		if (current >= 0 && current <= v) || h.min.CompareAndSwap(current, v) {
+			break
+		}
+	}
+	for {
+		current := h.max.Load()
+		if current >= v || h.max.CompareAndSwap(current, v) {
+			break
+		}
+	}
+}
+
+func (h *latencyHistogram) snapshot() LatencyHistogram {
+	snapshot := LatencyHistogram{
+		Operation: h.operation,
+		Backend:   h.backend,
+		Min:       time.Duration(max(h.min.Load(), 0)),
+		Max:       time.Duration(h.max.Load()),
+		Sum:       time.Duration(h.sum.Load()),
+	}
+	for i := range h.counts {
+		if count := h.counts[i].Load(); count > 0 {
+			snapshot.Count += count
+			snapshot.Buckets = append(snapshot.Buckets, LatencyBucket{
+				UpperBound: time.Duration(latencyBucketUpperBound(i)),
+				Count:      count,
+			})
+		}
+	}
+	return snapshot
+}
+
+// latencyBucket returns the index of the bucket of a latency in nanoseconds: the latencies below
+// latencySubBucketCount have a bucket of their own, the larger ones share a bucket with the latencies having the same
+// latencySubBucketBits most significant bits.
+func latencyBucket(v uint64) int {
+	if v < latencySubBucketCount {
+		return int(v)
+	}
+	shift := bits.Len64(v) - latencySubBucketBits
+	return latencySubBucketCount + (shift-1)*latencySubBucketHalf + int(v>>shift) - latencySubBucketHalf
+}
+
+func latencyBucketUpperBound(idx int) uint64 {
+	if idx < latencySubBucketCount {
+		return uint64(idx)
+	}
+	shift := (idx-latencySubBucketCount)/latencySubBucketHalf + 1
+	sub := uint64((idx-latencySubBucketCount)%latencySubBucketHalf + latencySubBucketHalf)
+	return (sub+1)<<shift - 1
+}
+
+type latencyRecorder struct {
+	logInterval time.Duration
+	logger      *zap.Logger
+	cancel      context.CancelFunc
+	done        chan struct{}
+
+	mu         sync.RWMutex
+	histograms map[string]*latencyHistogram // protected by mu, keyed by operation and backend
+}
+
+// start logs the histograms periodically when a log interval is set.
+func (r *latencyRecorder) start(logger *zap.Logger) {
+	if r.logInterval <= 0 {
+		return
+	}
+	r.logger = logger
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go r.logPeriodically(ctx)
+}
+
+func (r *latencyRecorder) close() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	<-r.done
+}
+
+func (r *latencyRecorder) logPeriodically(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.logInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, h := range r.snapshot() {
+			r.logger.Info("request latencies",
+				zap.String("operation", h.Operation),
+				zap.String("backend", h.Backend),
+				zap.Uint64("count", h.Count),
+				zap.Duration("p50", h.Percentile(50)),
+				zap.Duration("p99", h.Percentile(99)),
+				zap.Duration("p99.9", h.Percentile(99.9)),
+				zap.Duration("max", h.Max),
+			)
+		}
+	}
+}
+
+// observe records the latency of a request sent to backend, the requests which weren't sent aren't recorded.
+func (r *latencyRecorder) observe(encoder codec.LinkEncoder, backend string, latency time.Duration) {
+	if backend == "" {
+		return
+	}
+	r.histogramOf(operationName(encoder), backend).observe(latency)
+}
+
+func (r *latencyRecorder) histogramOf(operation, backend string) *latencyHistogram {
+	id := operation + "\x00" + backend
+
+	r.mu.RLock()
+	h, ok := r.histograms[id]
+	r.mu.RUnlock()
+	if ok {
+		return h
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if h, ok := r.histograms[id]; ok {
+		return h
+	}
+	h = newLatencyHistogram(operation, backend)
+	r.histograms[id] = h
+	return h
+}
+
+// snapshot returns the histograms ordered by operation and backend.
+func (r *latencyRecorder) snapshot() []LatencyHistogram {
+	r.mu.RLock()
+	histograms := make([]LatencyHistogram, 0, len(r.histograms))
+	for _, h := range r.histograms {
+		histograms = append(histograms, h.snapshot())
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(histograms, func(a, b LatencyHistogram) int {
+		if c := strings.Compare(a.Operation, b.Operation); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Backend, b.Backend)
+	})
+	return histograms
+}
+
+// operationName names the operation of a request, by its command when the encoder describes it.
+func operationName(encoder codec.LinkEncoder) string {
+	if describer, ok := encoder.(codec.RequestDescriber); ok {
+		if operation, _ := describer.Describe(); operation != "" {
+			return operation
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+func TestLatencyBuckets(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 129, 255, 256, 1000, 1_000_000, 123_456_789, math.MaxInt64} {
+		idx := latencyBucket(v)
+		require.Less(t, idx, latencyBucketCount, v)
+		upper := latencyBucketUpperBound(idx)
+		assert.GreaterOrEqual(t, upper, v, v)
+		if idx > 0 {
+			assert.Less(t, latencyBucketUpperBound(idx-1), v, v)
+		}
+		// the bucket is at most 1/64 of its values wide.
+		assert.LessOrEqual(t, float64(upper-v), float64(v)/64, v)
+	}
+}
+
+func TestLatencyHistogramPercentiles(t *testing.T) {
+	h := newLatencyHistogram("mg", "backend")
+	for i := 1; i <= 1000; i++ {
+		h.observe(time.Duration(i) * time.Millisecond)
+	}
+	snapshot := h.snapshot()
+
+	assert.Equal(t, uint64(1000), snapshot.Count)
+	assert.Equal(t, time.Millisecond, snapshot.Min)
+	assert.Equal(t, time.Second, snapshot.Max)
+	assert.InDelta(t, 500.5*float64(time.Millisecond), float64(snapshot.Mean()), float64(time.Microsecond))
+	for p, expected := range map[float64]time.Duration{50: 500 * time.Millisecond, 99: 990 * time.Millisecond, 99.9: 999 * time.Millisecond, 100: time.Second} {
+		assert.InEpsilon(t, float64(expected), float64(snapshot.Percentile(p)), 1.0/64, p)
+	}
+	assert.Equal(t, time.Duration(0), LatencyHistogram{}.Percentile(99))
+}
+
+func TestLatencyHistograms(t *testing.T) {
+	mc, srv := newTestClient(t, WithLatencyHistograms(0))
+
+	get := memcache.CreateMetaGetEncoder()
+	get.Reset()
+	get.Key = "a"
+	for i := 0; i < 3; i++ {
+		require.NoError(t, mc.MetaGet(context.Background(), get, memcache.CreateMetaGetDecoder()))
+	}
+
+	stats := mc.Stats()
+	require.Len(t, stats.Latencies, 1)
+	h := stats.Latencies[0]
+	assert.Equal(t, "mg", h.Operation)
+	assert.Equal(t, srv.Addr().String(), h.Backend)
+	assert.Equal(t, uint64(3), h.Count)
+	assert.Positive(t, h.Percentile(99.9))
+
+	var b strings.Builder
+	require.NoError(t, stats.WritePrometheus(&b))
+	assert.Contains(t, b.String(), `memlink_request_latency_seconds_count{operation="mg",backend="`+srv.Addr().String()+`"} 3`)
+	assert.Contains(t, b.String(), `quantile="0.999"`)
+}
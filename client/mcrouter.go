@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// mcrouterKeyPrefix is the prefix of the keys mcrouter answers itself instead of routing them.
+const mcrouterKeyPrefix = "__mcrouter__."
+
+// Names of the mcrouter special keys which can be read with McrouterGet.
+const (
+	// McrouterVersion is the version of mcrouter, the version command is answered by the destinations instead.
+	McrouterVersion = "version"
+	// McrouterConfigFile is the path of the configuration file.
+	McrouterConfigFile = "config_file"
+	// McrouterConfigDigest is the md5 digest of the configuration, useful to check the proxies agree on it.
+	McrouterConfigDigest = "config_md5_digest"
+	// McrouterConfigAge is the number of seconds since the configuration was last loaded.
+	McrouterConfigAge = "config_age"
+	// McrouterOptions are the command line options mcrouter was started with.
+	McrouterOptions = "options"
+)
+
+// ErrNotMcrouter is returned by the mcrouter helpers when a backend doesn't answer the mcrouter special keys, i.e. it
+// isn't an mcrouter proxy.
+var ErrNotMcrouter = errors.New("memcached: backend isn't an mcrouter proxy")
+
+// McrouterGet reads the mcrouter special key "__mcrouter__.<name>" on every backend and returns the values keyed by
+// backend address.
+func (c *memcachedClient) McrouterGet(ctx context.Context, name string) (map[string][]byte, error) {
+	values, err := c.mcrouterGet(ctx, mcrouterKeyPrefix+name)
+	if err != nil {
+		return nil, fmt.Errorf("McrouterGet operation failed: %w", err)
+	}
+	return values, nil
+}
+
+// McrouterRoute asks every backend where mcrouter would send a request of the given operation, e.g. "get" or "set",
+// for key. It returns the destinations keyed by backend address.
+func (c *memcachedClient) McrouterRoute(ctx context.Context, operation string, key string) (map[string][]string, error) {
+	if err := memcache.ValidateKey(key); err != nil {
+		return nil, fmt.Errorf("McrouterRoute operation failed: %w", err)
+	}
+
+	values, err := c.mcrouterGet(ctx, fmt.Sprintf("%sroute(%s,%s)", mcrouterKeyPrefix, operation, key))
+	if err != nil {
+		return nil, fmt.Errorf("McrouterRoute operation failed: %w", err)
+	}
+
+	routes := make(map[string][]string, len(values))
+	for backend, value := range values {
+		// destinations are separated by CRLF.
+		destinations := []string{}
+		for _, destination := range strings.Split(string(value), "\r\n") {
+			if destination = strings.TrimSpace(destination); destination != "" {
+				destinations = append(destinations, destination)
+			}
+		}
+		routes[backend] = destinations
+	}
+	return routes, nil
+}
+
+// mcrouterGet reads key on every backend. mcrouter only answers its special keys to classic get requests, so the
+// request is always translated to the classic protocol.
+func (c *memcachedClient) mcrouterGet(ctx context.Context, key string) (map[string][]byte, error) {
+	values := make(map[string][]byte)
+	for _, be := range c.pool.Backends() {
+		encoder := memcache.CreateMetaGetEncoder()
+		decoder := memcache.CreateMetaGetDecoder()
+		encoder.Reset()
+		encoder.Key = key
+		encoder.FetchValue = true
+
+		e, d, err := memcache.ClassicCodec(encoder, decoder)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.appendTo(ctx, be, e, d); err != nil {
+			return nil, err
+		}
+
+		switch decoder.Status {
+		case memcache.CacheHit:
+			values[be.String()] = decoder.Value
+		case memcache.CacheMiss:
+			return nil, fmt.Errorf("backend=%s key=%q: %w", be.String(), key, ErrNotMcrouter)
+		default:
+			return nil, fmt.Errorf("backend %s refused %q: %q", be.String(), key, decoder.HdrLine)
+		}
+	}
+	return values, nil
+}
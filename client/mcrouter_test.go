@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMcrouterRoute(t *testing.T) {
+	mc, srv := newTestClient(t)
+	// the fake server plays mcrouter by storing the answers under the special keys.
+	srv.Set("__mcrouter__.route(get,foo)", []byte("10.0.0.1:11211\r\n10.0.0.2:11211"), 0)
+
+	routes, err := mc.McrouterRoute(context.Background(), "get", "foo")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		srv.Addr().String(): {"10.0.0.1:11211", "10.0.0.2:11211"},
+	}, routes)
+	assert.Equal(t, 1, srv.CommandCount("get"))
+	assert.Zero(t, srv.CommandCount("mg"))
+}
+
+func TestMcrouterGet(t *testing.T) {
+	mc, srv := newTestClient(t)
+	srv.Set("__mcrouter__.version", []byte("mcrouter 42.0.0"), 0)
+
+	values, err := mc.McrouterGet(context.Background(), McrouterVersion)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{srv.Addr().String(): []byte("mcrouter 42.0.0")}, values)
+}
+
+func TestMcrouterHelpersRequireMcrouter(t *testing.T) {
+	mc, _ := newTestClient(t)
+
+	_, err := mc.McrouterGet(context.Background(), McrouterConfigDigest)
+	assert.ErrorIs(t, err, ErrNotMcrouter)
+
+	_, err = mc.McrouterRoute(context.Background(), "get", "foo bar")
+	assert.Error(t, err)
+}
@@ -0,0 +1,193 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/andrew-d/csmrand"
+	"go.uber.org/zap"
+
+	"github.com/stripe/memlink/codec/memcache"
+	netpkg "github.com/stripe/memlink/internal/net"
+)
+
+// Kinds of divergence between the primary and the mirror pool, counted in MirrorStats.Divergences.
+const (
+	// MirrorStatusDivergence is a get hitting on one pool and missing on the other.
+	MirrorStatusDivergence = "status"
+	// MirrorValueDivergence is a get hitting on both pools with different values.
+	MirrorValueDivergence = "value"
+	// MirrorFlagsDivergence is a get hitting on both pools with different client flags.
+	MirrorFlagsDivergence = "flags"
+	// MirrorCasDivergence is a get returning a CAS id on one pool only. CAS ids are local to a server, so only their
+	// presence is compared.
+	MirrorCasDivergence = "cas"
+)
+
+const (
+	// mirrorTimeout bounds a mirrored get, which doesn't inherit the deadline of the primary one.
+	mirrorTimeout = time.Second
+	// maxInflightMirrors bounds the number of mirrored gets in flight, the samples beyond are skipped.
+	maxInflightMirrors = 64
+)
+
+// WithMirror sends a sampleRate fraction of the gets answered by the client to mirror too, and compares the
+// responses. Divergences are counted by kind in Stats, they're expected while the mirror is warming up. Only gets
+// without side effects are mirrored, without their TTL update, and the mirror is queried in the background so it
+// doesn't add to the latency of the primary get. The mirror isn't closed by the client.
+func WithMirror(mirror MemcachedClient, sampleRate float64) ClientOption {
+	return func(c *memcachedClient) {
+		c.mirror = &getMirror{
+			client:      mirror,
+			sampleRate:  sampleRate,
+			inflight:    make(chan struct{}, maxInflightMirrors),
+			divergences: make(map[string]uint64),
+		}
+	}
+}
+
+// MirrorStats are the counters of the gets mirrored by a client.
+type MirrorStats struct {
+	// SampleRate is the fraction of the gets mirrored.
+	SampleRate float64
+	// Compared is the number of gets answered by both pools.
+	Compared uint64
+	// Divergences is the number of compared gets whose responses differ, per kind of divergence.
+	Divergences map[string]uint64
+	// Errors is the number of mirrored gets which failed on the mirror.
+	Errors uint64
+	// Skipped is the number of sampled gets not mirrored because too many were in flight.
+	Skipped uint64
+}
+
+type getMirror struct {
+	client     MemcachedClient
+	sampleRate float64
+	inflight   chan struct{}
+	wg         sync.WaitGroup
+	logger     *zap.Logger
+
+	compared atomic.Uint64
+	errors   atomic.Uint64
+	skipped  atomic.Uint64
+
+	mu          sync.Mutex
+	divergences map[string]uint64 // protected by mu
+}
+
+// mirroredGet is what's compared of a get response, copied out of the decoder which is reused once the primary get
+// returns.
+type mirroredGet struct {
+	status      memcache.MetadataStatus
+	value       []byte
+	casId       uint64
+	clientFlags uint64
+}
+
+func newMirroredGet(decoder *memcache.MetaGetDecoder) mirroredGet {
+	return mirroredGet{
+		status:      decoder.Status,
+		value:       bytes.Clone(decoder.Value),
+		casId:       decoder.CasId,
+		clientFlags: decoder.ClientFlags,
+	}
+}
+
+// observe mirrors a get the primary pool answered with decoder, if it's sampled and has no side effects.
+func (m *getMirror) observe(ctx context.Context, encoder *memcache.MetaGetEncoder, decoder *memcache.MetaGetDecoder) {
+	if m.sampleRate < 1 && csmrand.Float64() >= m.sampleRate {
+		return
+	}
+	if !encoder.Idempotent() {
+		return
+	}
+
+	select {
+	case m.inflight <- struct{}{}:
+	default:
+		m.skipped.Add(1)
+		return
+	}
+
+	mirrored := *encoder
+	mirrored.Opaque = 0
+	mirrored.UpdateTTL = -1
+	mirrored.TTLBeforeUpdate = false
+	primary := newMirroredGet(decoder)
+
+	m.wg.Add(1)
+	go func() {
+		defer func() {
+			<-m.inflight
+			m.wg.Done()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), mirrorTimeout)
+		defer cancel()
+		m.compare(ctx, &mirrored, primary)
+	}()
+}
+
+func (m *getMirror) compare(ctx context.Context, encoder *memcache.MetaGetEncoder, primary mirroredGet) {
+	decoder := memcache.CreateMetaGetDecoder()
+	decoder.Reset()
+	if err := m.client.MetaGet(ctx, encoder, decoder); err != nil {
+		m.errors.Add(1)
+		return
+	}
+	m.compared.Add(1)
+
+	secondary := newMirroredGet(decoder)
+	kind := ""
+	switch {
+	case primary.status != secondary.status:
+		kind = MirrorStatusDivergence
+	case !primary.status.IsHit():
+	case encoder.FetchValue && !bytes.Equal(primary.value, secondary.value):
+		kind = MirrorValueDivergence
+	case encoder.FetchClientFlags && primary.clientFlags != secondary.clientFlags:
+		kind = MirrorFlagsDivergence
+	case encoder.FetchCasId && (primary.casId == 0) != (secondary.casId == 0):
+		kind = MirrorCasDivergence
+	}
+	if kind == "" {
+		return
+	}
+
+	m.mu.Lock()
+	m.divergences[kind]++
+	m.mu.Unlock()
+
+	_, key := encoder.Describe()
+	m.logger.Debug("Mirrored get diverged",
+		zap.String("kind", kind),
+		zap.String("key", netpkg.RedactKey(key)),
+		zap.String("primary_status", string(primary.status)),
+		zap.String("mirror_status", string(secondary.status)),
+	)
+}
+
+func (m *getMirror) snapshot() *MirrorStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	divergences := make(map[string]uint64, len(m.divergences))
+	for kind, count := range m.divergences {
+		divergences[kind] = count
+	}
+	return &MirrorStats{
+		SampleRate:  m.sampleRate,
+		Compared:    m.compared.Load(),
+		Divergences: divergences,
+		Errors:      m.errors.Load(),
+		Skipped:     m.skipped.Load(),
+	}
+}
+
+// wait returns once the mirrored gets in flight are compared.
+func (m *getMirror) wait() {
+	m.wg.Wait()
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+func TestMirror(t *testing.T) {
+	mirror, mirrorSrv := newTestClient(t)
+	mc, srv := newTestClient(t, WithMirror(mirror, 1))
+	ctx := context.Background()
+
+	srv.Set("same", []byte("1"), 0)
+	mirrorSrv.Set("same", []byte("1"), 0)
+	srv.Set("stale", []byte("2"), 0)
+	mirrorSrv.Set("stale", []byte("old"), 0)
+	srv.Set("cold", []byte("3"), 0)
+
+	for _, key := range []string{"same", "stale", "cold", "missing"} {
+		_, err := mc.GetWithTTL(ctx, key)
+		require.NoError(t, err)
+	}
+
+	// vivifying gets aren't mirrored, they would hand out a win token on the mirror.
+	encoder := memcache.CreateMetaGetEncoder()
+	decoder := memcache.CreateMetaGetDecoder()
+	encoder.Reset()
+	decoder.Reset()
+	encoder.Key = "vivified"
+	encoder.BlockTTL = 30
+	require.NoError(t, mc.MetaGet(ctx, encoder, decoder))
+
+	mc.(*memcachedClient).mirror.wait()
+
+	stats := mc.Stats()
+	require.NotNil(t, stats.Mirror)
+	assert.Equal(t, float64(1), stats.Mirror.SampleRate)
+	assert.Equal(t, uint64(4), stats.Mirror.Compared)
+	assert.Equal(t, map[string]uint64{MirrorValueDivergence: 1, MirrorStatusDivergence: 1}, stats.Mirror.Divergences)
+	assert.Zero(t, stats.Mirror.Errors)
+	assert.Zero(t, stats.Mirror.Skipped)
+
+	var b bytes.Buffer
+	require.NoError(t, stats.WritePrometheus(&b))
+	assert.Contains(t, b.String(), "memlink_mirror_compared_total 4\n")
+	assert.Contains(t, b.String(), `memlink_mirror_divergences_total{kind="value"} 1`+"\n")
+	assert.Contains(t, b.String(), `memlink_mirror_divergences_total{kind="cas"} 0`+"\n")
+}
+
+func TestMirrorDisabled(t *testing.T) {
+	mc, _ := newTestClient(t)
+	assert.Nil(t, mc.Stats().Mirror)
+}
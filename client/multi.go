@@ -0,0 +1,310 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+	netpkg "github.com/stripe/memlink/internal/net"
+	"github.com/stripe/memlink/pools"
+)
+
+// GetMulti fetches the values of keys in a single pipelined request per backend and returns the ones which were found.
+// Keys which are missing from memcached are missing from the returned map.
+func (c *memcachedClient) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	if len(keys) == 0 {
+		return map[string][]byte{}, nil
+	}
+
+	for _, key := range keys {
+		if err := memcache.ValidateKey(key); err != nil {
+			return nil, fmt.Errorf("GetMulti operation failed: %w", err)
+		}
+	}
+
+	if c.collapser != nil {
+		return c.collapser.getMulti(ctx, keys, c.fetchMulti)
+	}
+	return c.fetchMulti(ctx, keys)
+}
+
+// fetchMulti fetches the values of valid keys, in a request per backend.
+func (c *memcachedClient) fetchMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	return perBackend(ctx, c, keys, func(key string) string { return key }, c.fetchBulk)
+}
+
+// perBackend calls send with the elements of every backend concurrently and merges the maps it returns. The elements
+// are partitioned by the backend the HasherFn of WithKeyHasher places their key on. They're sent in a single call,
+// placed by the pool, without a HasherFn, which places the requests at random, or when ctx carries a routing hint.
+func perBackend[T, V any](ctx context.Context, c *memcachedClient, elems []T, key func(T) string, send func(context.Context, []T) (map[string]V, error)) (map[string]V, error) {
+	shards := shardByBackend(ctx, c, elems, key)
+	if shards == nil {
+		return send(ctx, elems)
+	}
+	// the pool hashes the first key of a shard, which places the request along with the other keys.
+	if len(shards) == 1 {
+		return send(ContextWithRoutingHint(ctx, codec.RouteByHashKey(key(elems[0]))), elems)
+	}
+
+	results := make([]map[string]V, len(shards))
+	errs := make([]error, len(shards))
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = send(ContextWithRoutingHint(ctx, codec.RouteByHashKey(key(shard[0]))), shard)
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	merged := make(map[string]V, len(elems))
+	for _, result := range results {
+		maps.Copy(merged, result)
+	}
+	return merged, nil
+}
+
+// shardByBackend partitions elems by the backend the HasherFn places their key on, in the order of their first
+// element, or returns nil if they aren't to be partitioned, see perBackend.
+func shardByBackend[T any](ctx context.Context, c *memcachedClient, elems []T, key func(T) string) [][]T {
+	hint := RoutingHintFromContext(ctx)
+	if c.hashFn == nil || hint.HashKey != "" || hint.Backend != "" || hint.Broadcast != nil {
+		return nil
+	}
+	backends := c.pool.Backends()
+	n := len(backends)
+	if n <= 1 {
+		return nil
+	}
+	slots := netpkg.BackendSlots(backends)
+
+	var shards [][]T
+	shardOf := make(map[int]int, n)
+	for _, elem := range elems {
+		idx, err := netpkg.PlaceKey(c.hashFn, key(elem), n, slots)
+		if err != nil {
+			// the keys the HasherFn can't place share a shard, whose request fails like theirs would.
+			idx = -1
+		}
+		i, ok := shardOf[idx]
+		if !ok {
+			i = len(shards)
+			shardOf[idx] = i
+			shards = append(shards, nil)
+		}
+		shards[i] = append(shards[i], elem)
+	}
+	return shards
+}
+
+// fetchBulk fetches the values of valid keys in a single pipelined request.
+func (c *memcachedClient) fetchBulk(ctx context.Context, keys []string) (map[string][]byte, error) {
+	if c.chunker != nil {
+		items, err := c.fetchChunkedItems(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[string][]byte, len(items))
+		for key, item := range items {
+			values[key] = item.Value
+		}
+		return values, nil
+	}
+	return fetchBulkWith(ctx, c, keys, false, func(_ string, decoder *memcache.MetaGetDecoder) []byte {
+		value, _ := c.unwrapValue(decoder.Value)
+		return value
+	})
+}
+
+// fetchItems fetches valid keys along with their client flags, in a request per backend, and returns the items of the
+// ones which were found. Their TTL isn't fetched.
+func (c *memcachedClient) fetchItems(ctx context.Context, keys []string) (map[string]Item, error) {
+	return perBackend(ctx, c, keys, func(key string) string { return key }, func(ctx context.Context, keys []string) (map[string]Item, error) {
+		if c.chunker != nil {
+			return c.fetchChunkedItems(ctx, keys)
+		}
+		return fetchBulkWith(ctx, c, keys, true, func(key string, decoder *memcache.MetaGetDecoder) Item {
+			value, _ := c.unwrapValue(decoder.Value)
+			return Item{Key: key, Value: value, ClientFlags: decoder.ClientFlags}
+		})
+	})
+}
+
+// fetchBulkWith fetches valid keys in a single pipelined request, along with their client flags if fetchFlags is set,
+// and returns what found makes of the response of every key which was found.
+func fetchBulkWith[V any](ctx context.Context, c *memcachedClient, keys []string, fetchFlags bool, found func(key string, decoder *memcache.MetaGetDecoder) V) (map[string]V, error) {
+	bulkEncoder := bulkGetEncoderPool.Get()
+	bulkDecoder := bulkGetDecoderPool.Get()
+	defer pools.Release(ctx, bulkGetEncoderPool, bulkEncoder, bulkGetDecoderPool, bulkDecoder)
+
+	bulkEncoder.Opaque = c.nextOpaques(ctx, uint64(len(keys)))
+	for i, key := range keys {
+		encoder := getEncoderPool.Get()
+		encoder.Key = key
+		encoder.FetchValue = true
+		encoder.FetchClientFlags = fetchFlags
+		encoder.Opaque = bulkEncoder.Opaque + uint64(i)
+		bulkEncoder.Encoders = append(bulkEncoder.Encoders, encoder)
+
+		bulkDecoder.Decoders = append(bulkDecoder.Decoders, getDecoderPool.Get())
+		bulkDecoder.OpaqueToKey[encoder.Opaque] = key
+	}
+	defer func() {
+		if ctx.Err() == nil {
+			bulkEncoder.Encoders = getEncoderPool.PutAll(bulkEncoder.Encoders)
+			bulkDecoder.Decoders = getDecoderPool.PutAll(bulkDecoder.Decoders)
+		}
+		clear(bulkDecoder.OpaqueToKey)
+	}()
+
+	if err := c.BulkGet(ctx, bulkEncoder, bulkDecoder); err != nil {
+		return nil, fmt.Errorf("GetMulti operation failed: %w", err)
+	}
+
+	values := make(map[string]V, len(keys))
+	for i, decoder := range bulkDecoder.Decoders {
+		expectedOpaque := bulkEncoder.Opaque + uint64(i)
+		if decoder.Opaque != expectedOpaque {
+			return nil, fmt.Errorf("GetMulti operation failed: %w", memcache.NewOpaqueMismatchErr(expectedOpaque, decoder.Opaque, "GetMulti"))
+		}
+
+		if decoder.Status.IsHit() {
+			key := bulkDecoder.OpaqueToKey[decoder.Opaque]
+			values[key] = found(key, decoder)
+		}
+	}
+
+	return values, nil
+}
+
+// SetMulti stores items in a single pipelined request per backend and returns the status of every key. The requests
+// use quiet mode, so memcached only responds to the ones which were not stored, followed by a single response to the
+// trailing no-op request.
+func (c *memcachedClient) SetMulti(ctx context.Context, items []Item) (map[string]memcache.MetadataStatus, error) {
+	if len(items) == 0 {
+		return map[string]memcache.MetadataStatus{}, nil
+	}
+
+	for _, item := range items {
+		if err := memcache.ValidateKey(item.Key); err != nil {
+			return nil, fmt.Errorf("SetMulti operation failed: %w", err)
+		}
+	}
+
+	return perBackend(ctx, c, items, func(item Item) string { return item.Key }, c.setBulk)
+}
+
+// setBulk stores items with valid keys in a single pipelined request.
+func (c *memcachedClient) setBulk(ctx context.Context, items []Item) (map[string]memcache.MetadataStatus, error) {
+	return c.storeBulk(ctx, items, c.wrapValue)
+}
+
+// storeBulk stores items with valid keys in a single pipelined request, with the values wrap returns.
+func (c *memcachedClient) storeBulk(ctx context.Context, items []Item, wrap func(Item) []byte) (map[string]memcache.MetadataStatus, error) {
+	bulkEncoder := bulkSetEncoderPool.Get()
+	bulkDecoder := quietBulkSetDecoderPool.Get()
+	defer pools.Release(ctx, bulkSetEncoderPool, bulkEncoder, quietBulkSetDecoderPool, bulkDecoder)
+
+	bulkEncoder.Opaque = c.nextOpaques(ctx, uint64(len(items)))
+	for i, item := range items {
+		encoder := setEncoderPool.Get()
+		encoder.Key = item.Key
+		encoder.Value = wrap(item)
+		encoder.TTL = item.TTL
+		encoder.ExpireAt = item.ExpireAt
+		encoder.ClientFlags = item.ClientFlags
+		encoder.Quiet = true
+		encoder.Opaque = bulkEncoder.Opaque + uint64(i)
+		c.prepareSet(encoder)
+		bulkEncoder.Encoders = append(bulkEncoder.Encoders, encoder)
+	}
+	defer func() {
+		if ctx.Err() == nil {
+			bulkEncoder.Encoders = setEncoderPool.PutAll(bulkEncoder.Encoders)
+			bulkDecoder.Decoders = setDecoderPool.PutAll(bulkDecoder.Decoders)
+		}
+	}()
+
+	if err := c.append(ctx, bulkEncoder, bulkDecoder); err != nil {
+		return nil, fmt.Errorf("SetMulti operation failed: %w", err)
+	}
+
+	statuses := make(map[string]memcache.MetadataStatus, len(items))
+	for _, item := range items {
+		statuses[item.Key] = memcache.Stored
+	}
+
+	for _, decoder := range bulkDecoder.Decoders {
+		idx := decoder.Opaque - bulkEncoder.Opaque
+		if decoder.Opaque < bulkEncoder.Opaque || idx >= uint64(len(items)) {
+			return nil, fmt.Errorf("SetMulti operation failed: response doesn't match any request [Opaque=%d] [Status=%s] [HdrLine=%q]", decoder.Opaque, decoder.Status, decoder.HdrLine)
+		}
+		statuses[items[idx].Key] = decoder.Status
+	}
+
+	return statuses, nil
+}
+
+// DeleteMulti deletes keys in a single pipelined request per backend and returns the status of every key, i.e. Deleted
+// or NotFound.
+func (c *memcachedClient) DeleteMulti(ctx context.Context, keys []string) (map[string]memcache.MetadataStatus, error) {
+	if len(keys) == 0 {
+		return map[string]memcache.MetadataStatus{}, nil
+	}
+
+	for _, key := range keys {
+		if err := memcache.ValidateKey(key); err != nil {
+			return nil, fmt.Errorf("DeleteMulti operation failed: %w", err)
+		}
+	}
+
+	return perBackend(ctx, c, keys, func(key string) string { return key }, c.deleteBulk)
+}
+
+// deleteBulk deletes valid keys in a single pipelined request.
+func (c *memcachedClient) deleteBulk(ctx context.Context, keys []string) (map[string]memcache.MetadataStatus, error) {
+	bulkEncoder := bulkDeleteEncoderPool.Get()
+	bulkDecoder := bulkDeleteDecoderPool.Get()
+	defer pools.Release(ctx, bulkDeleteEncoderPool, bulkEncoder, bulkDeleteDecoderPool, bulkDecoder)
+
+	bulkEncoder.Opaque = c.nextOpaques(ctx, uint64(len(keys)))
+	for i, key := range keys {
+		encoder := deleteEncoderPool.Get()
+		encoder.Key = key
+		encoder.Opaque = bulkEncoder.Opaque + uint64(i)
+		bulkEncoder.Encoders = append(bulkEncoder.Encoders, encoder)
+
+		bulkDecoder.Decoders = append(bulkDecoder.Decoders, deleteDecoderPool.Get())
+		bulkDecoder.OpaqueToKey[encoder.Opaque] = key
+	}
+	defer func() {
+		if ctx.Err() == nil {
+			bulkEncoder.Encoders = deleteEncoderPool.PutAll(bulkEncoder.Encoders)
+			bulkDecoder.Decoders = deleteDecoderPool.PutAll(bulkDecoder.Decoders)
+		}
+		clear(bulkDecoder.OpaqueToKey)
+	}()
+
+	if err := c.append(ctx, bulkEncoder, bulkDecoder); err != nil {
+		return nil, fmt.Errorf("DeleteMulti operation failed: %w", err)
+	}
+
+	statuses := make(map[string]memcache.MetadataStatus, len(keys))
+	for i, decoder := range bulkDecoder.Decoders {
+		expectedOpaque := bulkEncoder.Opaque + uint64(i)
+		if decoder.Opaque != expectedOpaque {
+			return nil, fmt.Errorf("DeleteMulti operation failed: %w", memcache.NewOpaqueMismatchErr(expectedOpaque, decoder.Opaque, "DeleteMulti"))
+		}
+		statuses[bulkDecoder.OpaqueToKey[decoder.Opaque]] = decoder.Status
+	}
+
+	return statuses, nil
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+func newTestClient(t *testing.T, opts ...ClientOption) (MemcachedClient, *fakeserver.Server) {
+	srv, err := fakeserver.Start()
+	require.NoError(t, err)
+
+	mc, err := NewClient([]string{srv.Addr().String()}, 1, opts...)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = mc.Close()
+		_ = srv.Close()
+	})
+	return mc, srv
+}
+
+func TestGetMulti(t *testing.T) {
+	mc, srv := newTestClient(t)
+	srv.Set("a", []byte("1"), 0)
+	srv.Set("c", []byte("3"), 0)
+	// the capability probe sends a lone mn as well.
+	noOps := srv.CommandCount("mn")
+
+	values, err := mc.GetMulti(context.Background(), []string{"a", "b", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("1"), "c": []byte("3")}, values)
+	assert.Equal(t, noOps+1, srv.CommandCount("mn"))
+}
+
+func TestGetMultiWithWarmPools(t *testing.T) {
+	mc, srv := newTestClient(t, WithPoolWarmUp(4))
+	srv.Set("a", []byte("1"), 0)
+
+	values, err := mc.GetMulti(context.Background(), []string{"a", "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("1")}, values)
+}
+
+func TestGetMultiWithoutKeys(t *testing.T) {
+	mc, srv := newTestClient(t)
+
+	values, err := mc.GetMulti(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, values)
+	assert.Zero(t, srv.CommandCount("mg"))
+}
+
+func TestGetMultiWithIllegalKey(t *testing.T) {
+	mc, srv := newTestClient(t)
+
+	_, err := mc.GetMulti(context.Background(), []string{"a", "b c"})
+	var illegalKeyErr *memcache.IllegaleMemcacheKey
+	assert.ErrorAs(t, err, &illegalKeyErr)
+	assert.Zero(t, srv.CommandCount("mg"))
+}
+
+func TestSetMulti(t *testing.T) {
+	mc, srv := newTestClient(t)
+	noOps := srv.CommandCount("mn")
+
+	statuses, err := mc.SetMulti(context.Background(), []Item{
+		{Key: "a", Value: []byte("1"), TTL: 60},
+		{Key: "b", Value: []byte("2")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]memcache.MetadataStatus{"a": memcache.Stored, "b": memcache.Stored}, statuses)
+
+	value, ok := srv.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+	value, ok = srv.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("2"), value)
+	assert.Equal(t, noOps+1, srv.CommandCount("mn"))
+
+	values, err := mc.GetMulti(context.Background(), []string{"a", "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("1"), "b": []byte("2")}, values)
+}
+
+func TestSetMultiWithIllegalKey(t *testing.T) {
+	mc, srv := newTestClient(t)
+
+	_, err := mc.SetMulti(context.Background(), []Item{{Key: "a b", Value: []byte("1")}})
+	var illegalKeyErr *memcache.IllegaleMemcacheKey
+	assert.ErrorAs(t, err, &illegalKeyErr)
+	assert.Zero(t, srv.CommandCount("ms"))
+}
+
+func TestDeleteMulti(t *testing.T) {
+	mc, srv := newTestClient(t)
+	srv.Set("a", []byte("1"), 0)
+
+	statuses, err := mc.DeleteMulti(context.Background(), []string{"a", "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]memcache.MetadataStatus{"a": memcache.Deleted, "b": memcache.NotFound}, statuses)
+
+	_, ok := srv.Get("a")
+	assert.False(t, ok)
+}
@@ -0,0 +1,661 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// namespaceSeparator separates the namespace from the keys of a namespaced view, so that PrefixNamespace(":") labels
+// the stats of the view with its namespace.
+const namespaceSeparator = ":"
+
+// NamespaceOption configures a namespaced view of a client.
+type NamespaceOption func(n *namespacedClient)
+
+// WithNamespaceTTLPolicy applies policy to the TTL of every item written through the view, before the policy of the
+// client if any. The policy is given the keys without the namespace.
+func WithNamespaceTTLPolicy(policy TTLPolicy) NamespaceOption {
+	return func(n *namespacedClient) {
+		n.ttlPolicy = policy
+	}
+}
+
+// WithNamespaceRateLimit limits the view to perSecond keys per second, allowing bursts of burst keys. The requests
+// beyond fail with ErrRateLimited without contacting memcached, bulk requests of more than burst keys always do.
+func WithNamespaceRateLimit(perSecond float64, burst int) NamespaceOption {
+	return func(n *namespacedClient) {
+		n.limiter = newRateLimiter(perSecond, burst)
+	}
+}
+
+// NamespaceStats are the counters of the requests made through the views of a namespace.
+type NamespaceStats struct {
+	// Keys is the number of keys requested, a bulk request counting for each of its keys.
+	Keys uint64
+	// RateLimited is the number of keys refused by the rate limit of the view.
+	RateLimited uint64
+}
+
+type namespaceCounters struct {
+	keys        atomic.Uint64
+	rateLimited atomic.Uint64
+}
+
+// WithNamespace returns a view of the client prefixing every key with "<namespace>:", and applying the policies set
+// by opts, so that a shared client can be handed to tenants. The view shares the connections of the client and
+// closing it is a no-op.
+func (c *memcachedClient) WithNamespace(namespace string, opts ...NamespaceOption) MemcachedClient {
+	return newNamespacedClient(c, c, namespace, namespace+namespaceSeparator, opts)
+}
+
+// namespaceCounters returns the counters of namespace, shared by all its views.
+func (c *memcachedClient) namespaceCounters(namespace string) *namespaceCounters {
+	c.namespacesMu.Lock()
+	defer c.namespacesMu.Unlock()
+
+	if c.namespaces == nil {
+		c.namespaces = make(map[string]*namespaceCounters)
+	}
+	counters, ok := c.namespaces[namespace]
+	if !ok {
+		counters = &namespaceCounters{}
+		c.namespaces[namespace] = counters
+	}
+	return counters
+}
+
+func (c *memcachedClient) namespaceStats() map[string]NamespaceStats {
+	c.namespacesMu.Lock()
+	defer c.namespacesMu.Unlock()
+
+	if len(c.namespaces) == 0 {
+		return nil
+	}
+	stats := make(map[string]NamespaceStats, len(c.namespaces))
+	for namespace, counters := range c.namespaces {
+		stats[namespace] = NamespaceStats{
+			Keys:        counters.keys.Load(),
+			RateLimited: counters.rateLimited.Load(),
+		}
+	}
+	return stats
+}
+
+// namespacedClient prefixes the keys of the requests it forwards to parent, which is the client or the view of an
+// enclosing namespace.
+type namespacedClient struct {
+	root   *memcachedClient
+	parent MemcachedClient
+	// name is the namespace including the enclosing ones, e.g. "billing:invoices", while prefix is the part added
+	// by this view, e.g. "invoices:".
+	name      string
+	prefix    string
+	ttlPolicy TTLPolicy
+	limiter   *rateLimiter
+	counters  *namespaceCounters
+}
+
+func newNamespacedClient(root *memcachedClient, parent MemcachedClient, name string, prefix string, opts []NamespaceOption) *namespacedClient {
+	n := &namespacedClient{
+		root:     root,
+		parent:   parent,
+		name:     name,
+		prefix:   prefix,
+		counters: root.namespaceCounters(name),
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// WithNamespace returns a view nested in this one, prefixing the keys with both namespaces.
+func (n *namespacedClient) WithNamespace(namespace string, opts ...NamespaceOption) MemcachedClient {
+	return newNamespacedClient(n.root, n, n.name+namespaceSeparator+namespace, namespace+namespaceSeparator, opts)
+}
+
+// admit counts the keys of a request and checks them against the rate limit.
+func (n *namespacedClient) admit(keys int) error {
+	n.counters.keys.Add(uint64(keys))
+	if n.limiter != nil && !n.limiter.allow(keys) {
+		n.counters.rateLimited.Add(uint64(keys))
+		return fmt.Errorf("namespace=%s keys=%d: %w", n.name, keys, ErrRateLimited)
+	}
+	return nil
+}
+
+// scopeKey prefixes the key of an encoder, in whichever field it's set, and returns a func restoring the key of the
+// caller. The key isn't restored once ctx is done, the request may still be waiting to be encoded.
+func (n *namespacedClient) scopeKey(ctx context.Context, key *string, base64Key bool, binary *[]byte, validated *memcache.Key) (func(), error) {
+	original, originalBinary, originalValidated := *key, *binary, *validated
+	restore := func() {
+		if ctx.Err() == nil {
+			*key, *binary, *validated = original, originalBinary, originalValidated
+		}
+	}
+
+	if !validated.IsZero() {
+		var scoped memcache.Key
+		var err error
+		if validated.Base64() {
+			scoped, err = memcache.NewBinaryKey([]byte(n.prefix + validated.String()))
+		} else {
+			scoped, err = memcache.NewKey(n.prefix + validated.String())
+		}
+		if err != nil {
+			return restore, err
+		}
+		*validated = scoped
+		return restore, nil
+	}
+
+	if *binary != nil {
+		// the key of the caller isn't modified, it may be shared.
+		*binary = append([]byte(n.prefix), *binary...)
+		return restore, nil
+	}
+
+	if base64Key {
+		decoded, err := base64.StdEncoding.DecodeString(*key)
+		if err != nil {
+			return restore, &memcache.IllegaleMemcacheKey{IllegalKey: *key}
+		}
+		*key = base64.StdEncoding.EncodeToString(append([]byte(n.prefix), decoded...))
+		return restore, nil
+	}
+
+	*key = n.prefix + *key
+	return restore, nil
+}
+
+func (n *namespacedClient) unscope(key string) string {
+	return strings.TrimPrefix(key, n.prefix)
+}
+
+func (n *namespacedClient) scopeItem(item Item) Item {
+	if n.ttlPolicy != nil {
+		item.TTL, item.ExpireAt = policyTTL(n.ttlPolicy, item.Key, item.TTL, item.ExpireAt)
+	}
+	item.Key = n.prefix + item.Key
+	return item
+}
+
+func (n *namespacedClient) MetaSet(ctx context.Context, encoder *memcache.MetaSetEncoder, decoder *memcache.MetaSetDecoder) error {
+	if err := n.admit(1); err != nil {
+		return fmt.Errorf("MetaSet operation failed: %w", err)
+	}
+	applyTTL(n.ttlPolicy, encoder)
+
+	restore, err := n.scopeKey(ctx, &encoder.Key, encoder.Base64EncodedKey, &encoder.BinaryKey, &encoder.ValidatedKey)
+	defer restore()
+	if err != nil {
+		return fmt.Errorf("MetaSet operation failed: %w", err)
+	}
+	return n.parent.MetaSet(ctx, encoder, decoder)
+}
+
+func (n *namespacedClient) MetaGet(ctx context.Context, encoder *memcache.MetaGetEncoder, decoder *memcache.MetaGetDecoder) error {
+	if err := n.admit(1); err != nil {
+		return fmt.Errorf("MetaGet operation failed: %w", err)
+	}
+
+	restore, err := n.scopeKey(ctx, &encoder.Key, encoder.Base64EncodedKey, &encoder.BinaryKey, &encoder.ValidatedKey)
+	defer restore()
+	if err != nil {
+		return fmt.Errorf("MetaGet operation failed: %w", err)
+	}
+	if err := n.parent.MetaGet(ctx, encoder, decoder); err != nil {
+		return err
+	}
+	decoder.ItemKey = n.unscope(decoder.ItemKey)
+	return nil
+}
+
+func (n *namespacedClient) MetaDelete(ctx context.Context, encoder *memcache.MetaDeleteEncoder, decoder *memcache.MetaDeleteDecoder) error {
+	if err := n.admit(1); err != nil {
+		return fmt.Errorf("MetaDelete operation failed: %w", err)
+	}
+
+	restore, err := n.scopeKey(ctx, &encoder.Key, encoder.Base64EncodedKey, &encoder.BinaryKey, &encoder.ValidatedKey)
+	defer restore()
+	if err != nil {
+		return fmt.Errorf("MetaDelete operation failed: %w", err)
+	}
+	return n.parent.MetaDelete(ctx, encoder, decoder)
+}
+
+func (n *namespacedClient) MetaIncrement(ctx context.Context, encoder *memcache.MetaArithmeticEncoder, decoder *memcache.MetaArithmeticDecoder) error {
+	if err := n.admit(1); err != nil {
+		return fmt.Errorf("MetaIncrement operation failed: %w", err)
+	}
+
+	restore, err := n.scopeKey(ctx, &encoder.Key, encoder.Base64EncodedKey, &encoder.BinaryKey, &encoder.ValidatedKey)
+	defer restore()
+	if err != nil {
+		return fmt.Errorf("MetaIncrement operation failed: %w", err)
+	}
+	return n.parent.MetaIncrement(ctx, encoder, decoder)
+}
+
+func (n *namespacedClient) MetaDecrement(ctx context.Context, encoder *memcache.MetaArithmeticEncoder, decoder *memcache.MetaArithmeticDecoder) error {
+	if err := n.admit(1); err != nil {
+		return fmt.Errorf("MetaDecrement operation failed: %w", err)
+	}
+
+	restore, err := n.scopeKey(ctx, &encoder.Key, encoder.Base64EncodedKey, &encoder.BinaryKey, &encoder.ValidatedKey)
+	defer restore()
+	if err != nil {
+		return fmt.Errorf("MetaDecrement operation failed: %w", err)
+	}
+	return n.parent.MetaDecrement(ctx, encoder, decoder)
+}
+
+func (n *namespacedClient) BulkGet(ctx context.Context, encoder *memcache.BulkEncoder[*memcache.MetaGetEncoder], decoder *memcache.BulkDecoder[*memcache.MetaGetDecoder]) error {
+	if err := n.admit(len(encoder.Encoders)); err != nil {
+		return fmt.Errorf("BulkGet operation failed: %w", err)
+	}
+
+	for _, e := range encoder.Encoders {
+		restore, err := n.scopeKey(ctx, &e.Key, e.Base64EncodedKey, &e.BinaryKey, &e.ValidatedKey)
+		defer restore()
+		if err != nil {
+			return fmt.Errorf("BulkGet operation failed: %w", err)
+		}
+	}
+	if err := n.parent.BulkGet(ctx, encoder, decoder); err != nil {
+		return err
+	}
+	for _, d := range decoder.Decoders {
+		d.ItemKey = n.unscope(d.ItemKey)
+	}
+	return nil
+}
+
+// Pipeline scopes the keys of the meta requests of the groups. Other requests targeting a key can't be scoped and fail
+// the pipeline before anything is sent. The item keys returned by gets are unscoped once Pipeline returns.
+func (n *namespacedClient) Pipeline(ctx context.Context, groups ...*memcache.BarrierGroup) error {
+	keys := 0
+	for _, group := range groups {
+		keys += len(group.Encoders)
+	}
+	if err := n.admit(keys); err != nil {
+		return fmt.Errorf("Pipeline operation failed: %w", err)
+	}
+
+	for _, group := range groups {
+		for _, e := range group.Encoders {
+			restore, err := n.scopeRequest(ctx, e)
+			defer restore()
+			if err != nil {
+				return fmt.Errorf("Pipeline operation failed: %w", err)
+			}
+		}
+	}
+	if err := n.parent.Pipeline(ctx, groups...); err != nil {
+		return err
+	}
+	for _, group := range groups {
+		for _, d := range group.Decoders {
+			if get, ok := d.(*memcache.MetaGetDecoder); ok {
+				get.ItemKey = n.unscope(get.ItemKey)
+			}
+		}
+	}
+	return nil
+}
+
+// scopeRequest prefixes the key of a meta request and applies the TTL policy of the namespace to sets.
+func (n *namespacedClient) scopeRequest(ctx context.Context, e codec.LinkEncoder) (func(), error) {
+	switch e := e.(type) {
+	case *memcache.MetaGetEncoder:
+		return n.scopeKey(ctx, &e.Key, e.Base64EncodedKey, &e.BinaryKey, &e.ValidatedKey)
+	case *memcache.MetaSetEncoder:
+		applyTTL(n.ttlPolicy, e)
+		return n.scopeKey(ctx, &e.Key, e.Base64EncodedKey, &e.BinaryKey, &e.ValidatedKey)
+	case *memcache.MetaDeleteEncoder:
+		return n.scopeKey(ctx, &e.Key, e.Base64EncodedKey, &e.BinaryKey, &e.ValidatedKey)
+	case *memcache.MetaArithmeticEncoder:
+		return n.scopeKey(ctx, &e.Key, e.Base64EncodedKey, &e.BinaryKey, &e.ValidatedKey)
+	}
+
+	if describer, ok := e.(codec.RequestDescriber); ok {
+		if operation, key := describer.Describe(); key != "" {
+			return func() {}, fmt.Errorf("%s request can't be scoped to namespace %s", operation, n.name)
+		}
+	}
+	return func() {}, nil
+}
+
+func (n *namespacedClient) Get(ctx context.Context, key string) ([]byte, Item, error) {
+	if err := n.admit(1); err != nil {
+		return nil, Item{}, fmt.Errorf("Get operation failed: %w", err)
+	}
+	value, item, err := n.parent.Get(ctx, n.prefix+key)
+	if err != nil {
+		return nil, Item{}, err
+	}
+	item.Key = key
+	return value, item, nil
+}
+
+func (n *namespacedClient) Set(ctx context.Context, key string, value []byte, ttl int32) error {
+	if err := n.admit(1); err != nil {
+		return fmt.Errorf("Set operation failed: %w", err)
+	}
+	item := n.scopeItem(Item{Key: key, Value: value, TTL: ttl})
+	return n.parent.Set(ctx, item.Key, item.Value, item.TTL)
+}
+
+func (n *namespacedClient) GetOrSet(ctx context.Context, key string, ttl int32, loader func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	return getOrSet(ctx, n, key, ttl, loader)
+}
+
+func (n *namespacedClient) GetOrCompute(ctx context.Context, key string, ttl int32, loader func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	return getOrCompute(ctx, n, &n.root.loads, n.name+namespaceSeparator+key, key, ttl, loader)
+}
+
+func (n *namespacedClient) Delete(ctx context.Context, key string) error {
+	if err := n.admit(1); err != nil {
+		return fmt.Errorf("Delete operation failed: %w", err)
+	}
+	return n.parent.Delete(ctx, n.prefix+key)
+}
+
+func (n *namespacedClient) Touch(ctx context.Context, key string, ttl int32) error {
+	if err := n.admit(1); err != nil {
+		return fmt.Errorf("Touch operation failed: %w", err)
+	}
+	if n.ttlPolicy != nil {
+		ttl = n.ttlPolicy(key, max(ttl, 0))
+	}
+	return n.parent.Touch(ctx, n.prefix+key, ttl)
+}
+
+func (n *namespacedClient) TouchUntil(ctx context.Context, key string, expireAt time.Time) error {
+	if err := n.admit(1); err != nil {
+		return fmt.Errorf("TouchUntil operation failed: %w", err)
+	}
+	if n.ttlPolicy != nil {
+		if ttl, until := policyTTL(n.ttlPolicy, key, 0, expireAt); until.IsZero() {
+			return n.parent.Touch(ctx, n.prefix+key, ttl)
+		}
+	}
+	return n.parent.TouchUntil(ctx, n.prefix+key, expireAt)
+}
+
+func (n *namespacedClient) Incr(ctx context.Context, key string, delta uint64, opts ArithmeticOptions) (uint64, error) {
+	if err := n.admit(1); err != nil {
+		return 0, fmt.Errorf("Incr operation failed: %w", err)
+	}
+	return n.parent.Incr(ctx, n.prefix+key, delta, n.scopeArithmetic(key, opts))
+}
+
+func (n *namespacedClient) Decr(ctx context.Context, key string, delta uint64, opts ArithmeticOptions) (uint64, error) {
+	if err := n.admit(1); err != nil {
+		return 0, fmt.Errorf("Decr operation failed: %w", err)
+	}
+	return n.parent.Decr(ctx, n.prefix+key, delta, n.scopeArithmetic(key, opts))
+}
+
+func (n *namespacedClient) scopeArithmetic(key string, opts ArithmeticOptions) ArithmeticOptions {
+	if opts.Vivify && n.ttlPolicy != nil {
+		opts.VivifyTTL = n.ttlPolicy(key, max(opts.VivifyTTL, 0))
+	}
+	return opts
+}
+
+func (n *namespacedClient) GetWithTTL(ctx context.Context, key string) (GetResult, error) {
+	if err := n.admit(1); err != nil {
+		return GetResult{}, fmt.Errorf("GetWithTTL operation failed: %w", err)
+	}
+	return n.parent.GetWithTTL(ctx, n.prefix+key)
+}
+
+func (n *namespacedClient) GetAndTouch(ctx context.Context, key string, ttl int32) (GetAndTouchResult, error) {
+	if err := n.admit(1); err != nil {
+		return GetAndTouchResult{}, fmt.Errorf("GetAndTouch operation failed: %w", err)
+	}
+	if n.ttlPolicy != nil {
+		ttl = n.ttlPolicy(key, max(ttl, 0))
+	}
+	return n.parent.GetAndTouch(ctx, n.prefix+key, ttl)
+}
+
+func (n *namespacedClient) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	if err := n.admit(len(keys)); err != nil {
+		return nil, fmt.Errorf("GetMulti operation failed: %w", err)
+	}
+
+	scoped := make([]string, len(keys))
+	for i, key := range keys {
+		scoped[i] = n.prefix + key
+	}
+	values, err := n.parent.GetMulti(ctx, scoped)
+	if err != nil {
+		return nil, err
+	}
+
+	unscoped := make(map[string][]byte, len(values))
+	for key, value := range values {
+		unscoped[n.unscope(key)] = value
+	}
+	return unscoped, nil
+}
+
+func (n *namespacedClient) fetchItems(ctx context.Context, keys []string) (map[string]Item, error) {
+	if err := n.admit(len(keys)); err != nil {
+		return nil, fmt.Errorf("GetMulti operation failed: %w", err)
+	}
+
+	scoped := make([]string, len(keys))
+	for i, key := range keys {
+		scoped[i] = n.prefix + key
+	}
+	items, err := fetchItems(ctx, n.parent, scoped)
+	if err != nil {
+		return nil, err
+	}
+
+	unscoped := make(map[string]Item, len(items))
+	for key, item := range items {
+		item.Key = n.unscope(key)
+		unscoped[item.Key] = item
+	}
+	return unscoped, nil
+}
+
+func (n *namespacedClient) SetMulti(ctx context.Context, items []Item) (map[string]memcache.MetadataStatus, error) {
+	if err := n.admit(len(items)); err != nil {
+		return nil, fmt.Errorf("SetMulti operation failed: %w", err)
+	}
+
+	scoped := make([]Item, len(items))
+	for i, item := range items {
+		scoped[i] = n.scopeItem(item)
+	}
+	statuses, err := n.parent.SetMulti(ctx, scoped)
+	return n.unscopeStatuses(statuses), err
+}
+
+func (n *namespacedClient) DeleteMulti(ctx context.Context, keys []string) (map[string]memcache.MetadataStatus, error) {
+	if err := n.admit(len(keys)); err != nil {
+		return nil, fmt.Errorf("DeleteMulti operation failed: %w", err)
+	}
+
+	scoped := make([]string, len(keys))
+	for i, key := range keys {
+		scoped[i] = n.prefix + key
+	}
+	statuses, err := n.parent.DeleteMulti(ctx, scoped)
+	return n.unscopeStatuses(statuses), err
+}
+
+func (n *namespacedClient) unscopeStatuses(statuses map[string]memcache.MetadataStatus) map[string]memcache.MetadataStatus {
+	if statuses == nil {
+		return nil
+	}
+	unscoped := make(map[string]memcache.MetadataStatus, len(statuses))
+	for key, status := range statuses {
+		unscoped[n.unscope(key)] = status
+	}
+	return unscoped
+}
+
+func (n *namespacedClient) Add(ctx context.Context, item Item) error {
+	if err := n.admit(1); err != nil {
+		return fmt.Errorf("Add operation failed: %w", err)
+	}
+	return n.parent.Add(ctx, n.scopeItem(item))
+}
+
+func (n *namespacedClient) Replace(ctx context.Context, item Item) error {
+	if err := n.admit(1); err != nil {
+		return fmt.Errorf("Replace operation failed: %w", err)
+	}
+	return n.parent.Replace(ctx, n.scopeItem(item))
+}
+
+func (n *namespacedClient) AppendValue(ctx context.Context, key string, value []byte, vivifyTTL int32) error {
+	if err := n.admit(1); err != nil {
+		return fmt.Errorf("AppendValue operation failed: %w", err)
+	}
+	return n.parent.AppendValue(ctx, n.prefix+key, value, n.vivifyTTL(key, vivifyTTL))
+}
+
+func (n *namespacedClient) PrependValue(ctx context.Context, key string, value []byte, vivifyTTL int32) error {
+	if err := n.admit(1); err != nil {
+		return fmt.Errorf("PrependValue operation failed: %w", err)
+	}
+	return n.parent.PrependValue(ctx, n.prefix+key, value, n.vivifyTTL(key, vivifyTTL))
+}
+
+func (n *namespacedClient) vivifyTTL(key string, vivifyTTL int32) int32 {
+	if n.ttlPolicy == nil || vivifyTTL == NoVivify {
+		return vivifyTTL
+	}
+	return n.ttlPolicy(key, vivifyTTL)
+}
+
+// DeleteByPrefix deletes the keys of the namespace starting with prefix, counting as a single key for the rate limit.
+func (n *namespacedClient) DeleteByPrefix(ctx context.Context, prefix string, rate int) (int, error) {
+	if err := n.admit(1); err != nil {
+		return 0, fmt.Errorf("DeleteByPrefix operation failed: %w", err)
+	}
+	return n.parent.DeleteByPrefix(ctx, n.prefix+prefix, rate)
+}
+
+// ScanItems calls fn with the items of the namespace only, their keys without the namespace.
+func (n *namespacedClient) ScanItems(ctx context.Context, fn func(backend string, entry memcache.MetadumpEntry) error) error {
+	return n.parent.ScanItems(ctx, func(backend string, entry memcache.MetadumpEntry) error {
+		if !strings.HasPrefix(entry.Key, n.prefix) {
+			return nil
+		}
+		entry.Key = n.unscope(entry.Key)
+		return fn(backend, entry)
+	})
+}
+
+func (n *namespacedClient) BackendCapabilities() map[string]Capabilities {
+	return n.parent.BackendCapabilities()
+}
+
+func (n *namespacedClient) BackendCompression() map[string]Compression {
+	return n.parent.BackendCompression()
+}
+
+func (n *namespacedClient) Topology() Topology {
+	return n.parent.Topology()
+}
+
+func (n *namespacedClient) WarmUp(ctx context.Context) ([]BackendReadiness, error) {
+	return n.parent.WarmUp(ctx)
+}
+
+func (n *namespacedClient) Healthy() bool {
+	return n.parent.Healthy()
+}
+
+func (n *namespacedClient) Ready(ctx context.Context) error {
+	return n.parent.Ready(ctx)
+}
+
+func (n *namespacedClient) SetDegraded(d time.Duration) {
+	n.parent.SetDegraded(d)
+}
+
+func (n *namespacedClient) ServerStats(ctx context.Context, group string) (map[string]map[string]string, error) {
+	return n.parent.ServerStats(ctx, group)
+}
+
+// Raw sends request as is, its keys aren't scoped to the namespace.
+func (n *namespacedClient) Raw(ctx context.Context, backend string, request []byte) ([]byte, error) {
+	return n.parent.Raw(ctx, backend, request)
+}
+
+func (n *namespacedClient) McrouterGet(ctx context.Context, name string) (map[string][]byte, error) {
+	return n.parent.McrouterGet(ctx, name)
+}
+
+func (n *namespacedClient) McrouterRoute(ctx context.Context, operation string, key string) (map[string][]string, error) {
+	return n.parent.McrouterRoute(ctx, operation, n.prefix+key)
+}
+
+// Stats returns the stats of the client, the ones of the namespace being in ClientStats.Namespaces.
+func (n *namespacedClient) Stats() ClientStats {
+	return n.parent.Stats()
+}
+
+// Close is a no-op, the connections belong to the client the view was created from.
+func (n *namespacedClient) Close() error {
+	return nil
+}
+
+// Done is the one of the client the view was created from.
+func (n *namespacedClient) Done() <-chan struct{} {
+	return n.parent.Done()
+}
+
+var _ MemcachedClient = (*namespacedClient)(nil)
+
+// rateLimiter is a token bucket refilled at perSecond tokens per second, holding up to burst tokens.
+type rateLimiter struct {
+	perSecond float64
+	burst     float64
+
+	mu     sync.Mutex
+	tokens float64   // protected by mu
+	last   time.Time // protected by mu
+}
+
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		perSecond: perSecond,
+		burst:     float64(max(burst, 1)),
+		tokens:    float64(max(burst, 1)),
+		last:      time.Now(),
+	}
+}
+
+// allow takes n tokens if available.
+func (l *rateLimiter) allow(n int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.perSecond)
+	l.last = now
+
+	if l.tokens < float64(n) {
+		return false
+	}
+	l.tokens -= float64(n)
+	return true
+}
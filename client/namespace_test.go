@@ -0,0 +1,171 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+func TestNamespace(t *testing.T) {
+	mc, srv := newTestClient(t)
+	billing := mc.WithNamespace("billing")
+	ctx := context.Background()
+
+	statuses, err := billing.SetMulti(ctx, []Item{{Key: "a", Value: []byte("1")}, {Key: "b", Value: []byte("2")}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]memcache.MetadataStatus{"a": memcache.Stored, "b": memcache.Stored}, statuses)
+	require.NoError(t, billing.Add(ctx, Item{Key: "c", Value: []byte("3")}))
+
+	value, ok := srv.Get("billing:a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+	_, ok = srv.Get("a")
+	assert.False(t, ok)
+
+	values, err := billing.GetMulti(ctx, []string{"a", "c", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("1"), "c": []byte("3")}, values)
+
+	encoder := memcache.CreateMetaGetEncoder()
+	decoder := memcache.CreateMetaGetDecoder()
+	encoder.Reset()
+	decoder.Reset()
+	encoder.Key = "b"
+	encoder.FetchValue = true
+	encoder.FetchKey = true
+	require.NoError(t, billing.MetaGet(ctx, encoder, decoder))
+	assert.Equal(t, memcache.CacheHit, decoder.Status)
+	assert.Equal(t, "b", decoder.ItemKey)
+	assert.Equal(t, "b", encoder.Key)
+
+	// the client itself isn't scoped.
+	result, err := mc.GetWithTTL(ctx, "billing:b")
+	require.NoError(t, err)
+	assert.True(t, result.Found)
+
+	invoices := billing.WithNamespace("invoices")
+	require.NoError(t, invoices.Add(ctx, Item{Key: "1", Value: []byte("paid")}))
+	_, ok = srv.Get("billing:invoices:1")
+	assert.True(t, ok)
+
+	var scanned []string
+	require.NoError(t, invoices.ScanItems(ctx, func(_ string, entry memcache.MetadumpEntry) error {
+		scanned = append(scanned, entry.Key)
+		return nil
+	}))
+	assert.Equal(t, []string{"1"}, scanned)
+
+	require.NoError(t, billing.Close())
+	_, err = mc.GetWithTTL(ctx, "billing:b")
+	require.NoError(t, err)
+
+	stats := mc.Stats()
+	assert.Equal(t, map[string]NamespaceStats{
+		// the keys of a nested namespace count for the enclosing one too.
+		"billing":          {Keys: 8},
+		"billing:invoices": {Keys: 1},
+	}, stats.Namespaces)
+}
+
+func TestNamespaceValidatedKey(t *testing.T) {
+	mc, srv := newTestClient(t)
+	tenant := mc.WithNamespace("tenant")
+	ctx := context.Background()
+
+	key, err := memcache.NewKey("k")
+	require.NoError(t, err)
+	binaryKey, err := memcache.NewBinaryKey([]byte("with space"))
+	require.NoError(t, err)
+
+	for _, validated := range []memcache.Key{key, binaryKey} {
+		encoder := memcache.CreateMetaSetEncoder()
+		decoder := memcache.CreateMetaSetDecoder()
+		encoder.Reset()
+		decoder.Reset()
+		encoder.ValidatedKey = validated
+		encoder.Value = []byte("v")
+		require.NoError(t, tenant.MetaSet(ctx, encoder, decoder))
+		assert.Equal(t, memcache.Stored, decoder.Status)
+		assert.Equal(t, validated, encoder.ValidatedKey)
+	}
+
+	_, ok := srv.Get("tenant:k")
+	assert.True(t, ok)
+}
+
+func TestNamespaceBinaryKey(t *testing.T) {
+	mc, _ := newTestClient(t)
+	tenant := mc.WithNamespace("tenant")
+	ctx := context.Background()
+	binary := []byte{0xde, 0xad, 0xbe, 0xef}
+
+	encoder := memcache.CreateMetaSetEncoder()
+	decoder := memcache.CreateMetaSetDecoder()
+	encoder.Reset()
+	decoder.Reset()
+	encoder.BinaryKey = binary
+	encoder.Value = []byte("v")
+	require.NoError(t, tenant.MetaSet(ctx, encoder, decoder))
+	assert.Equal(t, memcache.Stored, decoder.Status)
+	assert.Equal(t, []byte{0xde, 0xad, 0xbe, 0xef}, encoder.BinaryKey)
+
+	scoped, err := memcache.NewBinaryKey(append([]byte("tenant:"), binary...))
+	require.NoError(t, err)
+	get := memcache.CreateMetaGetEncoder()
+	getDecoder := memcache.CreateMetaGetDecoder()
+	get.Reset()
+	getDecoder.Reset()
+	get.ValidatedKey = scoped
+	get.FetchValue = true
+	require.NoError(t, mc.MetaGet(ctx, get, getDecoder))
+	assert.Equal(t, []byte("v"), getDecoder.Value)
+}
+
+func TestNamespaceTTLPolicy(t *testing.T) {
+	mc, _ := newTestClient(t)
+	short := mc.WithNamespace("short", WithNamespaceTTLPolicy(ClampTTL(1, 60)))
+	ctx := context.Background()
+
+	require.NoError(t, short.Add(ctx, Item{Key: "forever", Value: []byte("v")}))
+	result, err := short.GetWithTTL(ctx, "forever")
+	require.NoError(t, err)
+	assert.Equal(t, int32(60), result.RemainingTTLSeconds)
+
+	require.NoError(t, short.TouchUntil(ctx, "forever", time.Now().Add(time.Hour)))
+	result, err = short.GetWithTTL(ctx, "forever")
+	require.NoError(t, err)
+	assert.Equal(t, int32(60), result.RemainingTTLSeconds)
+
+	require.NoError(t, short.TouchUntil(ctx, "forever", time.Now().Add(30*time.Second)))
+	result, err = short.GetWithTTL(ctx, "forever")
+	require.NoError(t, err)
+	assert.InDelta(t, 30, result.RemainingTTLSeconds, 1)
+}
+
+func TestNamespaceRateLimit(t *testing.T) {
+	mc, srv := newTestClient(t)
+	limited := mc.WithNamespace("limited", WithNamespaceRateLimit(0.001, 2))
+	ctx := context.Background()
+
+	_, err := limited.GetMulti(ctx, []string{"a", "b"})
+	require.NoError(t, err)
+	gets := srv.CommandCount("mg")
+
+	_, err = limited.GetWithTTL(ctx, "a")
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, gets, srv.CommandCount("mg"))
+
+	stats := mc.Stats()
+	assert.Equal(t, NamespaceStats{Keys: 3, RateLimited: 1}, stats.Namespaces["limited"])
+
+	var b bytes.Buffer
+	require.NoError(t, stats.WritePrometheus(&b))
+	assert.Contains(t, b.String(), `memlink_namespace_keys_total{namespace="limited"} 3`+"\n")
+	assert.Contains(t, b.String(), `memlink_namespace_rate_limited_total{namespace="limited"} 1`+"\n")
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// NegativeCacheFlag is the client flag field, of a single bit, marking the markers stored by a NegativeCache for the
+// keys its loaders didn't find.
+var NegativeCacheFlag = memcache.DefaultFlagRegistry.MustRegister("negative-cache", 31, 1)
+
+// negativeCacheMarker is the value of the markers, so that they can be told apart when read by other means.
+var negativeCacheMarker = []byte("memlink-not-found/1")
+
+// NegativeCache loads the values missing from memcached through a client, and remembers for a while the keys their
+// loader didn't find either, so that the backing store isn't asked for a key which doesn't exist again on every read.
+// It stores a marker flagged with NegativeCacheFlag under such keys, which the client's own Get returns as is; writing
+// the key, e.g. once it exists in the backing store, replaces the marker.
+type NegativeCache struct {
+	mc      MemcachedClient
+	missTTL int32
+}
+
+// NewNegativeCache returns a NegativeCache loading values through mc, and remembering the keys their loader didn't
+// find for missTTL seconds. missTTL should be short, 0 would remember them until the markers are evicted.
+func NewNegativeCache(mc MemcachedClient, missTTL int32) *NegativeCache {
+	return &NegativeCache{mc: mc, missTTL: missTTL}
+}
+
+// Get returns the value stored under key. A miss, or a key which was remembered as not found, fails with ErrNotFound.
+func (n *NegativeCache) Get(ctx context.Context, key string) ([]byte, error) {
+	value, negative, err := n.get(ctx, key)
+	if err == nil && negative {
+		return nil, fmt.Errorf("key=%q: negatively cached: %w", key, ErrNotFound)
+	}
+	return value, err
+}
+
+// get returns the value stored under key, and whether it's a marker.
+func (n *NegativeCache) get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, item, err := n.mc.Get(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, NegativeCacheFlag.IsSet(item.ClientFlags), nil
+}
+
+// GetOrLoad is like GetOrSet, except that loader returns an error wrapping ErrNotFound when the backing store doesn't
+// have key either. A marker is then stored under key for the miss TTL, and GetOrLoad fails with ErrNotFound without
+// calling loader until the marker expires.
+func (n *NegativeCache) GetOrLoad(ctx context.Context, key string, ttl int32, loader func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	value, negative, err := n.get(ctx, key)
+	switch {
+	case err == nil && negative:
+		return nil, fmt.Errorf("key=%q: negatively cached: %w", key, ErrNotFound)
+	case err == nil:
+		return value, nil
+	case !errors.Is(err, ErrNotFound):
+		return nil, fmt.Errorf("GetOrLoad operation failed: %w", err)
+	}
+
+	value, err = loader(ctx)
+	if errors.Is(err, ErrNotFound) {
+		return nil, n.storeMarker(ctx, key, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := n.mc.Set(ctx, key, value, ttl); err != nil {
+		return value, fmt.Errorf("GetOrLoad operation failed: %w", err)
+	}
+	return value, nil
+}
+
+// storeMarker remembers that key wasn't found by a loader which failed with loaderErr, and returns loaderErr, joined
+// with the reason the marker couldn't be stored if it couldn't.
+func (n *NegativeCache) storeMarker(ctx context.Context, key string, loaderErr error) error {
+	marker := Item{Key: key, Value: negativeCacheMarker, TTL: n.missTTL, ClientFlags: setFlag(0, NegativeCacheFlag)}
+	statuses, err := n.mc.SetMulti(ctx, []Item{marker})
+	if err == nil && !statuses[key].IsStored() {
+		err = fmt.Errorf("key=%q status=%s: %w", key, statuses[key], ErrNotStored)
+	}
+	if err != nil {
+		return errors.Join(loaderErr, fmt.Errorf("GetOrLoad operation failed to store the negative cache marker: %w", err))
+	}
+	return loaderErr
+}
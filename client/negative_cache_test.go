@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegativeCache(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+	cache := NewNegativeCache(mc, 30)
+
+	loads := 0
+	missing := func(context.Context) ([]byte, error) {
+		loads++
+		return nil, fmt.Errorf("no row: %w", ErrNotFound)
+	}
+
+	for range 3 {
+		_, err := cache.GetOrLoad(ctx, "missing", 300, missing)
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+	assert.Equal(t, 1, loads)
+	_, err := cache.Get(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	value, item, err := mc.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.Equal(t, negativeCacheMarker, value)
+	assert.True(t, NegativeCacheFlag.IsSet(item.ClientFlags))
+	assert.InDelta(t, 30, item.TTL, 1)
+
+	// the marker is replaced once the key is written.
+	require.NoError(t, mc.Set(ctx, "missing", []byte("found"), 0))
+	value, err = cache.GetOrLoad(ctx, "missing", 300, missing)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("found"), value)
+	assert.Equal(t, 1, loads)
+
+	value, err = cache.GetOrLoad(ctx, "present", 300, func(context.Context) ([]byte, error) { return []byte("loaded"), nil })
+	require.NoError(t, err)
+	assert.Equal(t, []byte("loaded"), value)
+	stored, ok := srv.Get("present")
+	require.True(t, ok)
+	assert.Equal(t, []byte("loaded"), stored)
+}
+
+func TestNegativeCacheLoaderFailure(t *testing.T) {
+	mc, srv := newTestClient(t)
+	cache := NewNegativeCache(mc, 30)
+
+	failure := errors.New("backing store unavailable")
+	_, err := cache.GetOrLoad(context.Background(), "key", 300, func(context.Context) ([]byte, error) { return nil, failure })
+	assert.Equal(t, failure, err)
+
+	// only the misses of the loader are remembered.
+	_, ok := srv.Get("key")
+	assert.False(t, ok)
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/pools"
+)
+
+var (
+	getEncoderPool     = pools.NewResettablePool(memcache.CreateMetaGetEncoder)
+	getDecoderPool     = pools.NewResettablePool(memcache.CreateMetaGetDecoder)
+	setEncoderPool     = pools.NewResettablePool(memcache.CreateMetaSetEncoder)
+	setDecoderPool     = pools.NewResettablePool(memcache.CreateMetaSetDecoder)
+	deleteEncoderPool  = pools.NewResettablePool(memcache.CreateMetaDeleteEncoder)
+	deleteDecoderPool  = pools.NewResettablePool(memcache.CreateMetaDeleteDecoder)
+	bulkGetEncoderPool = pools.NewResettablePool(func() *memcache.BulkEncoder[*memcache.MetaGetEncoder] {
+		return memcache.CreateBulkEncoder[*memcache.MetaGetEncoder](10)
+	})
+	bulkGetDecoderPool = pools.NewResettablePool(func() *memcache.BulkDecoder[*memcache.MetaGetDecoder] {
+		return memcache.CreateBulkDecoder[*memcache.MetaGetDecoder](10)
+	})
+	bulkSetEncoderPool = pools.NewResettablePool(func() *memcache.BulkEncoder[*memcache.MetaSetEncoder] {
+		return memcache.CreateBulkEncoder[*memcache.MetaSetEncoder](10)
+	})
+	quietBulkSetDecoderPool = pools.NewResettablePool(func() *memcache.QuietBulkDecoder[*memcache.MetaSetDecoder] {
+		return memcache.CreateQuietBulkDecoder(setDecoderPool.Get)
+	})
+	bulkDeleteEncoderPool = pools.NewResettablePool(func() *memcache.BulkEncoder[*memcache.MetaDeleteEncoder] {
+		return memcache.CreateBulkEncoder[*memcache.MetaDeleteEncoder](10)
+	})
+	bulkDeleteDecoderPool = pools.NewResettablePool(func() *memcache.BulkDecoder[*memcache.MetaDeleteDecoder] {
+		return memcache.CreateBulkDecoder[*memcache.MetaDeleteDecoder](10)
+	})
+	arithmeticEncoderPool = pools.NewResettablePool(memcache.CreateArithmeticEncoder)
+	arithmeticDecoderPool = pools.NewResettablePool(memcache.CreateArithmeticDecoder)
+)
+
+// WithPoolWarmUp allocates n encoders and decoders of every kind when the client is created, so that the first burst of
+// requests doesn't allocate them. The pools are shared by the clients of the process and, like any sync.Pool, release
+// idle objects over garbage collections: the warm-up is meant for the traffic arriving right after startup.
+func WithPoolWarmUp(n int) ClientOption {
+	return func(c *memcachedClient) {
+		c.poolWarmUp = n
+	}
+}
+
+func warmPools(n int) {
+	getEncoderPool.Warm(n)
+	getDecoderPool.Warm(n)
+	setEncoderPool.Warm(n)
+	setDecoderPool.Warm(n)
+	deleteEncoderPool.Warm(n)
+	deleteDecoderPool.Warm(n)
+	bulkGetEncoderPool.Warm(n)
+	bulkGetDecoderPool.Warm(n)
+	bulkSetEncoderPool.Warm(n)
+	quietBulkSetDecoderPool.Warm(n)
+	bulkDeleteEncoderPool.Warm(n)
+	bulkDeleteDecoderPool.Warm(n)
+	arithmeticEncoderPool.Warm(n)
+	arithmeticDecoderPool.Warm(n)
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// namespaceLabel is the label added to the operation metrics by the NamespaceFn of WithOperationMetrics.
+const namespaceLabel = "namespace"
+
+// WithOperationMetrics counts the requests, their failures and their latency per operation, as named and labeled by
+// the encoders implementing codec.OperationLabeler, so that the requests of a new codec are measured without changes
+// to the client. When namespace is set, the namespace of the key is added as a label: it must map the keys to a
+// bounded set of namespaces. The counters are reported by Stats.
+func WithOperationMetrics(namespace NamespaceFn) ClientOption {
+	return func(c *memcachedClient) {
+		c.operations = &operationRecorder{
+			namespace: namespace,
+			counters:  make(map[string]*operationCounters),
+		}
+	}
+}
+
+// OperationStats are the counters of the requests of an operation sharing the same labels.
+type OperationStats struct {
+	Operation string
+	// Labels are the labels of the requests, ordered by name.
+	Labels []codec.MetricLabel
+	// Requests is the number of requests.
+	Requests uint64
+	// Failed is the number of requests which failed to get a response, e.g. because of a timeout. A response telling
+	// the request wasn't applied, e.g. NOT_STORED, isn't a failure.
+	Failed uint64
+	// Latency is the total latency of the requests.
+	Latency time.Duration
+}
+
+type operationRecorder struct {
+	namespace NamespaceFn
+
+	mu       sync.RWMutex
+	counters map[string]*operationCounters // protected by mu, keyed by operation and labels
+}
+
+type operationCounters struct {
+	operation string
+	labels    []codec.MetricLabel
+
+	requests atomic.Uint64
+	failed   atomic.Uint64
+	latency  atomic.Int64
+}
+
+func (r *operationRecorder) observe(encoder codec.LinkEncoder, latency time.Duration, err error) {
+	labeler, ok := encoder.(codec.OperationLabeler)
+	if !ok {
+		return
+	}
+	operation, labels := labeler.OperationLabels()
+	if r.namespace != nil {
+		if describer, ok := encoder.(codec.RequestDescriber); ok {
+			if _, key := describer.Describe(); key != "" {
+				labels = append(slices.Clone(labels), codec.MetricLabel{Name: namespaceLabel, Value: r.namespace(key)})
+				slices.SortFunc(labels, func(a, b codec.MetricLabel) int { return strings.Compare(a.Name, b.Name) })
+			}
+		}
+	}
+
+	counters := r.countersOf(operation, labels)
+	counters.requests.Add(1)
+	if err != nil {
+		counters.failed.Add(1)
+	}
+	counters.latency.Add(int64(latency))
+}
+
+func (r *operationRecorder) countersOf(operation string, labels []codec.MetricLabel) *operationCounters {
+	var b strings.Builder
+	b.WriteString(operation)
+	for _, label := range labels {
+		b.WriteString("\x00" + label.Name + "=" + label.Value)
+	}
+	id := b.String()
+
+	r.mu.RLock()
+	counters, ok := r.counters[id]
+	r.mu.RUnlock()
+	if ok {
+		return counters
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if counters, ok := r.counters[id]; ok {
+		return counters
+	}
+	counters = &operationCounters{operation: operation, labels: slices.Clone(labels)}
+	r.counters[id] = counters
+	return counters
+}
+
+// snapshot returns the counters ordered by operation and labels.
+func (r *operationRecorder) snapshot() []OperationStats {
+	r.mu.RLock()
+	ids := make([]string, 0, len(r.counters))
+	for id := range r.counters {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	stats := make([]OperationStats, 0, len(ids))
+	for _, id := range ids {
+		counters := r.counters[id]
+		stats = append(stats, OperationStats{
+			Operation: counters.operation,
+			Labels:    slices.Clone(counters.labels),
+			Requests:  counters.requests.Load(),
+			Failed:    counters.failed.Load(),
+			Latency:   time.Duration(counters.latency.Load()),
+		})
+	}
+	r.mu.RUnlock()
+	return stats
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec"
+)
+
+func TestOperationMetrics(t *testing.T) {
+	mc, srv := newTestClient(t, WithOperationMetrics(PrefixNamespace(":")))
+	ctx := context.Background()
+
+	srv.Set("user:1", []byte("1"), 0)
+	require.NoError(t, mc.Add(ctx, Item{Key: "user:2", Value: make([]byte, 300)}))
+	assert.ErrorIs(t, mc.Add(ctx, Item{Key: "user:1", Value: []byte("2")}), ErrAlreadyExists)
+	_, err := mc.GetMulti(ctx, []string{"user:1", "user:2"})
+	require.NoError(t, err)
+
+	stats := mc.Stats()
+	require.Len(t, stats.Operations, 3)
+
+	bulk := stats.Operations[0]
+	assert.Equal(t, "bulk_mg", bulk.Operation)
+	assert.Equal(t, []codec.MetricLabel{{Name: "batch_size_bucket", Value: "10"}, {Name: "namespace", Value: "user"}}, bulk.Labels)
+	assert.Equal(t, uint64(1), bulk.Requests)
+
+	add := stats.Operations[1]
+	assert.Equal(t, "ms", add.Operation)
+	assert.Equal(t, []codec.MetricLabel{
+		{Name: "mode", Value: "add"}, {Name: "namespace", Value: "user"}, {Name: "size_bucket", Value: "1024"},
+	}, add.Labels)
+	assert.Equal(t, uint64(1), add.Requests)
+	assert.Greater(t, add.Latency, time.Duration(0))
+	// the refused add got a response, it didn't fail.
+	assert.Equal(t, "64", stats.Operations[2].Labels[2].Value)
+	assert.Zero(t, stats.Operations[2].Failed)
+
+	var b bytes.Buffer
+	require.NoError(t, stats.WritePrometheus(&b))
+	assert.Contains(t, b.String(), "# TYPE memlink_operation_requests_total counter\n")
+	assert.Contains(t, b.String(),
+		`memlink_operation_requests_total{operation="bulk_mg",batch_size_bucket="10",namespace="user"} 1`+"\n")
+}
+
+func TestOperationMetricsDisabled(t *testing.T) {
+	mc, _ := newTestClient(t)
+
+	require.NoError(t, mc.Add(context.Background(), Item{Key: "key", Value: []byte("value")}))
+	assert.Nil(t, mc.Stats().Operations)
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// Pipeline sends the requests of groups on a single connection, in order, each group followed by an mn barrier, and
+// returns once every group is complete. A group completes as soon as the response to its barrier is read, so callers
+// can wait for it with BarrierGroup.Wait and use its responses while the next groups are still being answered. When
+// the pipeline fails, the groups not complete yet fail with the same error.
+//
+// The sets of the groups are subject to the TTL policy of the client, like the ones sent with MetaSet.
+func (c *memcachedClient) Pipeline(ctx context.Context, groups ...*memcache.BarrierGroup) error {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	encoder, decoder := memcache.CreateBarrierPipeline(groups...)
+	if err := encoder.Validate(); err != nil {
+		decoder.Fail(err)
+		return fmt.Errorf("Pipeline operation failed: %w", err)
+	}
+	for _, group := range groups {
+		for _, e := range group.Encoders {
+			if set, ok := e.(*memcache.MetaSetEncoder); ok {
+				c.prepareSet(set)
+			}
+		}
+	}
+
+	// the groups left incomplete by the decoder, e.g. because the request was never written, are failed once the
+	// request is over, whether the caller still waits or not.
+	if err := c.appendThen(ctx, encoder, decoder, decoder.Fail); err != nil {
+		return fmt.Errorf("Pipeline operation failed: %w", err)
+	}
+	return nil
+}
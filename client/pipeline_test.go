@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+func TestPipeline(t *testing.T) {
+	mc, srv := newTestClient(t)
+	noOps := srv.CommandCount("mn")
+
+	set := memcache.CreateMetaSetEncoder()
+	set.Reset()
+	set.Key = "a"
+	set.Value = []byte("1")
+	writes := memcache.NewBarrierGroup()
+	writes.Add(set, memcache.CreateMetaSetDecoder())
+
+	get := memcache.CreateMetaGetEncoder()
+	get.Reset()
+	get.Key = "a"
+	get.FetchValue = true
+	getDecoder := memcache.CreateMetaGetDecoder()
+	reads := memcache.NewBarrierGroup()
+	reads.Add(get, getDecoder)
+
+	require.NoError(t, mc.Pipeline(context.Background(), writes, reads))
+	assert.NoError(t, writes.Wait(context.Background()))
+	assert.NoError(t, reads.Wait(context.Background()))
+	assert.Equal(t, []byte("1"), getDecoder.Value)
+	assert.Equal(t, noOps+2, srv.CommandCount("mn"))
+}
+
+func TestPipelineFailsGroups(t *testing.T) {
+	mc, _ := newTestClient(t)
+
+	group := memcache.NewBarrierGroup()
+	group.Encoders = append(group.Encoders, memcache.CreateMetaNoOpEncoder())
+	assert.Error(t, mc.Pipeline(context.Background(), group))
+	assert.Error(t, group.Wait(context.Background()))
+}
+
+func TestNamespacedPipeline(t *testing.T) {
+	mc, srv := newTestClient(t)
+	srv.Set("billing:a", []byte("1"), 0)
+
+	get := memcache.CreateMetaGetEncoder()
+	get.Reset()
+	get.Key = "a"
+	get.FetchValue = true
+	getDecoder := memcache.CreateMetaGetDecoder()
+	group := memcache.NewBarrierGroup()
+	group.Add(get, getDecoder)
+
+	require.NoError(t, mc.WithNamespace("billing").Pipeline(context.Background(), group))
+	assert.Equal(t, []byte("1"), getDecoder.Value)
+	assert.Equal(t, "a", get.Key)
+}
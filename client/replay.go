@@ -0,0 +1,290 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+const (
+	// replayInterval is the period of the rounds replaying the queued writes.
+	replayInterval = time.Second
+	// replayTimeout bounds a replayed write.
+	replayTimeout = time.Second
+)
+
+// WithWriteReplay queues the plain sets and deletes which fail to reach memcached, e.g. because their backend is
+// unreachable, and replays them in the background every second until they're applied, smoothing over short backend
+// blips for loss-tolerant data. A single write is queued per key: a later write of the key which succeeds, or fails and
+// is queued in turn, supersedes the queued one. The writes beyond capacity are dropped, and the ones which failed more
+// than maxAge ago are dropped instead of being replayed, both are counted in Stats. The writes still queued are dropped
+// when the client is closed.
+func WithWriteReplay(capacity int, maxAge time.Duration) ClientOption {
+	return func(c *memcachedClient) {
+		c.replay = &writeReplayer{
+			capacity: capacity,
+			maxAge:   maxAge,
+			entries:  make(map[replayKey]replayEntry),
+			stop:     make(chan struct{}),
+			done:     make(chan struct{}),
+		}
+	}
+}
+
+// ReplayStats are the counters of the writes queued for replay by a client.
+type ReplayStats struct {
+	// Queued is the number of writes currently queued.
+	Queued int
+	// Replayed is the number of queued writes applied by a replay.
+	Replayed uint64
+	// Dropped is the number of failed writes not queued because the queue was full, or still queued when the client
+	// was closed.
+	Dropped uint64
+	// Expired is the number of queued writes dropped because they failed more than maxAge ago.
+	Expired uint64
+}
+
+type replayKey struct {
+	key    string
+	base64 bool
+}
+
+// replayEntry is a queued write, a copy of either a set or a delete request.
+type replayEntry struct {
+	set      *memcache.MetaSetEncoder
+	del      *memcache.MetaDeleteEncoder
+	failedAt time.Time
+}
+
+type writeReplayer struct {
+	capacity int
+	maxAge   time.Duration
+	send     func(ctx context.Context, entry replayEntry) error
+	stop     chan struct{}
+	done     chan struct{}
+
+	mu      sync.Mutex
+	entries map[replayKey]replayEntry // protected by mu
+	// queued is len(entries), read by the requests without taking mu.
+	queued atomic.Int64
+
+	replayed atomic.Uint64
+	dropped  atomic.Uint64
+	expired  atomic.Uint64
+}
+
+type replayingKey struct{}
+
+// replaying reports whether ctx is the one of a replayed write, whose outcome is handled by the replay round.
+func replaying(ctx context.Context) bool {
+	return ctx.Value(replayingKey{}) != nil
+}
+
+func (r *writeReplayer) start(send func(ctx context.Context, entry replayEntry) error) {
+	r.send = send
+	go r.run()
+}
+
+func (r *writeReplayer) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(replayInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.replay()
+		}
+	}
+}
+
+// replay sends the queued writes, oldest first. The ones failing again are queued back unless they were superseded in
+// the meantime.
+func (r *writeReplayer) replay() {
+	r.mu.Lock()
+	entries := make([]replayEntry, 0, len(r.entries))
+	keys := make([]replayKey, 0, len(r.entries))
+	for key, entry := range r.entries {
+		keys = append(keys, key)
+		entries = append(entries, entry)
+	}
+	clear(r.entries)
+	r.queued.Store(0)
+	r.mu.Unlock()
+
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int { return entries[a].failedAt.Compare(entries[b].failedAt) })
+
+	ctx := context.WithValue(context.Background(), replayingKey{}, true)
+	for _, i := range order {
+		select {
+		case <-r.stop:
+			r.dropped.Add(1)
+			continue
+		default:
+		}
+
+		if time.Since(entries[i].failedAt) > r.maxAge {
+			r.expired.Add(1)
+			continue
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, replayTimeout)
+		err := r.send(sendCtx, entries[i])
+		cancel()
+		if err != nil {
+			r.requeue(keys[i], entries[i])
+			continue
+		}
+		r.replayed.Add(1)
+	}
+}
+
+// requeue queues back a write which failed to be replayed, unless a later write of its key was queued meanwhile.
+func (r *writeReplayer) requeue(key replayKey, entry replayEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entries[key]; ok {
+		return
+	}
+	if len(r.entries) >= r.capacity {
+		r.dropped.Add(1)
+		return
+	}
+	r.entries[key] = entry
+	r.queued.Store(int64(len(r.entries)))
+}
+
+// close stops the replay rounds, dropping the writes still queued.
+func (r *writeReplayer) close() {
+	close(r.stop)
+	<-r.done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dropped.Add(uint64(len(r.entries)))
+	clear(r.entries)
+	r.queued.Store(0)
+}
+
+// observeThen wraps then, the callback of a request, to queue the writes of the request if it fails, or drop the ones
+// it supersedes if it succeeds.
+func (r *writeReplayer) observeThen(e codec.LinkEncoder, then func(err error)) func(err error) {
+	return func(err error) {
+		r.observe(e, err)
+		if then != nil {
+			then(err)
+		}
+	}
+}
+
+func (r *writeReplayer) observe(e codec.LinkEncoder, err error) {
+	now := time.Now()
+	switch e := e.(type) {
+	case *memcache.MetaSetEncoder:
+		r.recordSet(e, err, now)
+	case *memcache.MetaDeleteEncoder:
+		r.recordDelete(e, err, now)
+	case *memcache.BulkEncoder[*memcache.MetaSetEncoder]:
+		for _, encoder := range e.Encoders {
+			r.recordSet(encoder, err, now)
+		}
+	case *memcache.BulkEncoder[*memcache.MetaDeleteEncoder]:
+		for _, encoder := range e.Encoders {
+			r.recordDelete(encoder, err, now)
+		}
+	}
+}
+
+func (r *writeReplayer) recordSet(e *memcache.MetaSetEncoder, err error, now time.Time) {
+	key := replayKey{key: setKey(e), base64: e.Base64EncodedKey || e.BinaryKey != nil}
+	if err == nil || !e.Idempotent() {
+		r.supersede(key, err)
+		return
+	}
+
+	set := *e
+	set.Value = bytes.Clone(e.Value)
+	set.BinaryKey = bytes.Clone(e.BinaryKey)
+	set.Opaque = 0
+	// the replayed set is sent on its own, and waits for its response.
+	set.Quiet = false
+	r.record(key, replayEntry{set: &set, failedAt: now})
+}
+
+func (r *writeReplayer) recordDelete(e *memcache.MetaDeleteEncoder, err error, now time.Time) {
+	key := replayKey{key: deleteKey(e), base64: e.Base64EncodedKey || e.BinaryKey != nil}
+	if err == nil || !e.Idempotent() {
+		r.supersede(key, err)
+		return
+	}
+
+	del := *e
+	del.BinaryKey = bytes.Clone(e.BinaryKey)
+	del.Opaque = 0
+	r.record(key, replayEntry{del: &del, failedAt: now})
+}
+
+// supersede drops the write queued for key once a later write of the key succeeded.
+func (r *writeReplayer) supersede(key replayKey, err error) {
+	if err != nil || r.queued.Load() == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, key)
+	r.queued.Store(int64(len(r.entries)))
+}
+
+func (r *writeReplayer) record(key replayKey, entry replayEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entries[key]; !ok && len(r.entries) >= r.capacity {
+		r.dropped.Add(1)
+		return
+	}
+	r.entries[key] = entry
+	r.queued.Store(int64(len(r.entries)))
+}
+
+func (r *writeReplayer) snapshot() *ReplayStats {
+	return &ReplayStats{
+		Queued:   int(r.queued.Load()),
+		Replayed: r.replayed.Load(),
+		Dropped:  r.dropped.Load(),
+		Expired:  r.expired.Load(),
+	}
+}
+
+// replayWrite sends a queued write, a miss of a delete counting as applied.
+func (c *memcachedClient) replayWrite(ctx context.Context, entry replayEntry) error {
+	if entry.set != nil {
+		encoder := *entry.set
+		decoder := setDecoderPool.Get()
+		c.assignOpaque(ctx, &encoder.Opaque)
+		err := c.append(ctx, &encoder, decoder)
+		if ctx.Err() == nil {
+			setDecoderPool.Put(decoder)
+		}
+		return err
+	}
+
+	encoder := *entry.del
+	decoder := deleteDecoderPool.Get()
+	c.assignOpaque(ctx, &encoder.Opaque)
+	err := c.append(ctx, &encoder, decoder)
+	if ctx.Err() == nil {
+		deleteDecoderPool.Put(decoder)
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+func newTestReplayer(capacity int, maxAge time.Duration, send func(ctx context.Context, entry replayEntry) error) *writeReplayer {
+	var c memcachedClient
+	WithWriteReplay(capacity, maxAge)(&c)
+	// the rounds are run by the tests.
+	c.replay.send = send
+	close(c.replay.done)
+	return c.replay
+}
+
+func TestWriteReplayer(t *testing.T) {
+	errUnreachable := errors.New("unreachable")
+	var sent []string
+	fail := true
+	r := newTestReplayer(2, time.Minute, func(ctx context.Context, entry replayEntry) error {
+		assert.True(t, replaying(ctx))
+		if entry.set != nil {
+			sent = append(sent, "set "+entry.set.Key+"="+string(entry.set.Value))
+		} else {
+			sent = append(sent, "delete "+entry.del.Key)
+		}
+		if fail {
+			return errUnreachable
+		}
+		return nil
+	})
+
+	value := []byte("1")
+	r.observe(&memcache.MetaSetEncoder{Key: "a", Value: value, Quiet: true, Opaque: 7}, errUnreachable)
+	// the queued set doesn't share the value of the request.
+	value[0] = '9'
+	r.observe(&memcache.MetaDeleteEncoder{Key: "b"}, errUnreachable)
+	// the queue is full.
+	r.observe(&memcache.MetaSetEncoder{Key: "c", Value: []byte("3")}, errUnreachable)
+	// a later failed write of a queued key replaces it.
+	r.observe(&memcache.MetaSetEncoder{Key: "a", Value: []byte("2")}, errUnreachable)
+	// non idempotent writes aren't queued.
+	r.observe(&memcache.MetaSetEncoder{Key: "d", Value: []byte("4"), Mode: memcache.Append}, errUnreachable)
+	assert.Equal(t, &ReplayStats{Queued: 2, Dropped: 1}, r.snapshot())
+
+	// the writes failing again are queued back.
+	r.replay()
+	assert.Equal(t, []string{"delete b", "set a=2"}, sent)
+	assert.Equal(t, &ReplayStats{Queued: 2, Dropped: 1}, r.snapshot())
+	r.mu.Lock()
+	assert.False(t, r.entries[replayKey{key: "a"}].set.Quiet)
+	assert.Zero(t, r.entries[replayKey{key: "a"}].set.Opaque)
+	r.mu.Unlock()
+
+	// a later successful write of a queued key supersedes it.
+	r.observe(&memcache.MetaDeleteEncoder{Key: "b"}, nil)
+	assert.Equal(t, 1, r.snapshot().Queued)
+
+	sent = nil
+	fail = false
+	r.replay()
+	assert.Equal(t, []string{"set a=2"}, sent)
+	assert.Equal(t, &ReplayStats{Queued: 0, Replayed: 1, Dropped: 1}, r.snapshot())
+}
+
+func TestWriteReplayerExpiry(t *testing.T) {
+	r := newTestReplayer(10, time.Millisecond, func(context.Context, replayEntry) error {
+		t.Fatal("an expired write was replayed")
+		return nil
+	})
+
+	r.observe(&memcache.BulkEncoder[*memcache.MetaSetEncoder]{Encoders: []*memcache.MetaSetEncoder{
+		{Key: "a", Value: []byte("1")},
+		{Key: "b", Value: []byte("2")},
+	}}, errors.New("unreachable"))
+	assert.Equal(t, 2, r.snapshot().Queued)
+
+	time.Sleep(5 * time.Millisecond)
+	r.replay()
+	assert.Equal(t, &ReplayStats{Expired: 2}, r.snapshot())
+}
+
+func TestWriteReplay(t *testing.T) {
+	mc, srv := newTestClient(t, WithWriteReplay(16, time.Minute))
+	ctx := context.Background()
+	addr := srv.Addr().String()
+	require.NoError(t, srv.Close())
+
+	require.Error(t, mc.Set(ctx, "k", []byte("v"), 0))
+	assert.Equal(t, &ReplayStats{Queued: 1}, mc.Stats().Replay)
+
+	restarted, err := fakeserver.StartAt(addr)
+	require.NoError(t, err)
+	defer restarted.Close() //nolint: errcheck
+	require.Eventually(t, func() bool {
+		return mc.Stats().Replay.Replayed == 1
+	}, 10*time.Second, 10*time.Millisecond)
+	value, ok := restarted.Get("k")
+	require.True(t, ok)
+	assert.Equal(t, []byte("v"), value)
+
+	var b strings.Builder
+	require.NoError(t, mc.Stats().WritePrometheus(&b))
+	assert.Contains(t, b.String(), "memlink_replay_queued 0\n")
+	assert.Contains(t, b.String(), "memlink_replay_writes_total{outcome=\"replayed\"} 1\n")
+}
+
+func TestWriteReplayDisabled(t *testing.T) {
+	mc, _ := newTestClient(t)
+	assert.Nil(t, mc.Stats().Replay)
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"hash/fnv"
+
+	"go.uber.org/zap"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// requestOpaqueSeqBits is the number of low bits of a request derived opaque holding a sequence number, so that the
+// operations issued for the same request ID get distinct opaques. The high bits identify the request.
+const requestOpaqueSeqBits = 24
+
+const requestOpaqueSeqMask = 1<<requestOpaqueSeqBits - 1
+
+type requestIDKey struct{}
+
+// ContextWithRequestID attaches an application request (or trace) ID to ctx. Clients created with
+// WithRequestIDOpaques derive the opaque tokens of the operations issued with ctx from it.
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID attached by ContextWithRequestID.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey{}).(string)
+	return requestID, ok && requestID != ""
+}
+
+// RequestOpaquePrefix returns the high bits shared by every opaque derived from requestID.
+func RequestOpaquePrefix(requestID string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(requestID))
+	return h.Sum64() &^ requestOpaqueSeqMask
+}
+
+// OpaqueMatchesRequestID reports whether opaque, e.g. read from a memcached wire log, was derived from requestID.
+func OpaqueMatchesRequestID(opaque uint64, requestID string) bool {
+	return opaque&^requestOpaqueSeqMask == RequestOpaquePrefix(requestID)
+}
+
+// OpaqueObserver is notified of the opaque tokens derived from a request ID.
+type OpaqueObserver func(requestID string, opaque uint64)
+
+// WithRequestIDOpaques derives the opaque tokens of the operations issued with a context carrying a request ID (see
+// ContextWithRequestID) from that ID, instead of the global opaque counter. Every derived opaque is logged at debug
+// level along with the request ID and passed to the optional observer, so that wire captures can be correlated with
+// application traces.
+//
+// It only applies to the encoders which don't have an opaque set yet.
+func WithRequestIDOpaques(observer OpaqueObserver) ClientOption {
+	return func(c *memcachedClient) {
+		c.requestIDOpaques = true
+		c.opaqueObserver = observer
+	}
+}
+
+// nextOpaques reserves n consecutive opaque tokens and returns the first one.
+func (c *memcachedClient) nextOpaques(ctx context.Context, n uint64) uint64 {
+	requestID, ok := RequestIDFromContext(ctx)
+	if !c.requestIDOpaques || !ok || n > requestOpaqueSeqMask {
+		return memcache.NextNOpaques(n)
+	}
+
+	start := (c.requestOpaqueSeq.Add(n) - n + 1) & requestOpaqueSeqMask
+	if start == 0 || start+n-1 > requestOpaqueSeqMask {
+		// the range would spill into the request bits, restart the sequence.
+		start = 1
+	}
+	first := RequestOpaquePrefix(requestID) | start
+
+	c.logger.Debug("derived opaques from request id",
+		zap.String("request_id", requestID),
+		zap.Uint64("opaque", first),
+		zap.Uint64("count", n))
+	if c.opaqueObserver != nil {
+		for i := uint64(0); i < n; i++ {
+			c.opaqueObserver(requestID, first+i)
+		}
+	}
+	return first
+}
+
+// assignOpaque sets a request derived opaque on encoders without one.
+func (c *memcachedClient) assignOpaque(ctx context.Context, opaque *uint64) {
+	if *opaque != 0 || !c.requestIDOpaques {
+		return
+	}
+	if _, ok := RequestIDFromContext(ctx); ok {
+		*opaque = c.nextOpaques(ctx, 1)
+	}
+}
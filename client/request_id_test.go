@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+func TestRequestIDOpaques(t *testing.T) {
+	var mu sync.Mutex
+	observed := make(map[uint64]string)
+	mc, _ := newTestClient(t, WithRequestIDOpaques(func(requestID string, opaque uint64) {
+		mu.Lock()
+		defer mu.Unlock()
+		observed[opaque] = requestID
+	}))
+	ctx := ContextWithRequestID(context.Background(), "trace-1234")
+
+	encoder := memcache.CreateMetaGetEncoder()
+	decoder := memcache.CreateMetaGetDecoder()
+	encoder.Reset()
+	encoder.Key = "key"
+	require.NoError(t, mc.MetaGet(ctx, encoder, decoder))
+	assert.True(t, OpaqueMatchesRequestID(decoder.Opaque, "trace-1234"))
+	assert.False(t, OpaqueMatchesRequestID(decoder.Opaque, "trace-5678"))
+
+	_, err := mc.SetMulti(ctx, []Item{{Key: "a", Value: []byte("a")}, {Key: "b", Value: []byte("b")}})
+	require.NoError(t, err)
+	_, err = mc.GetMulti(ctx, []string{"a", "b"})
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, observed, 5)
+	for opaque, requestID := range observed {
+		assert.Equal(t, "trace-1234", requestID)
+		assert.True(t, OpaqueMatchesRequestID(opaque, requestID))
+	}
+}
+
+func TestRequestIDOpaquesKeepsExplicitOpaque(t *testing.T) {
+	mc, _ := newTestClient(t, WithRequestIDOpaques(nil))
+	ctx := ContextWithRequestID(context.Background(), "trace-1234")
+
+	encoder := memcache.CreateMetaGetEncoder()
+	decoder := memcache.CreateMetaGetDecoder()
+	encoder.Reset()
+	encoder.Key = "key"
+	encoder.Opaque = 42
+	require.NoError(t, mc.MetaGet(ctx, encoder, decoder))
+	assert.Equal(t, uint64(42), decoder.Opaque)
+}
+
+func TestRequestIDOpaquesDisabled(t *testing.T) {
+	mc, _ := newTestClient(t)
+	ctx := ContextWithRequestID(context.Background(), "trace-1234")
+
+	encoder := memcache.CreateMetaGetEncoder()
+	decoder := memcache.CreateMetaGetDecoder()
+	encoder.Reset()
+	encoder.Key = "key"
+	require.NoError(t, mc.MetaGet(ctx, encoder, decoder))
+	assert.Zero(t, decoder.Opaque)
+}
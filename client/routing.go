@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"strings"
+
+	"github.com/stripe/memlink/codec"
+	netpkg "github.com/stripe/memlink/internal/net"
+)
+
+// HasherFn returns the index, in [0, n), of the backend serving key. Backends are indexed in the order their
+// addresses were given to NewClient. When the backends have different weights, see BackendConfig, n is the sum of
+// the weights and each backend is picked by as many consecutive indexes as its weight.
+type HasherFn = netpkg.HasherFn
+
+// WithKeyHasher places the requests on the backend fn picks for their key, instead of a random one. GetMulti, SetMulti
+// and DeleteMulti send a request per backend with the keys fn places on it. Other requests without a single key, e.g.
+// bulk requests and pipelines, are hashed with an empty key unless their context carries a routing hint, see
+// ContextWithRoutingHint.
+func WithKeyHasher(fn HasherFn) ClientOption {
+	return func(c *memcachedClient) {
+		c.hashFn = fn
+	}
+}
+
+// CanaryStats counts the requests whose placement was compared with the one of the candidate HasherFn of
+// WithRoutingCanary.
+type CanaryStats = netpkg.CanaryStats
+
+// WithRoutingCanary compares the backend every request is sent to with the one candidate picks for its key, without
+// changing where requests go, to evaluate a new routing strategy, e.g. moving from random placement to WithKeyHasher,
+// on live traffic. The divergences, and the misses the reads which diverge would cause right after switching, are
+// reported by Stats.
+func WithRoutingCanary(candidate HasherFn) ClientOption {
+	return func(c *memcachedClient) {
+		c.canaryFn = candidate
+	}
+}
+
+// isCanaryRead reports whether the request is a read, whose divergence would cause a miss.
+func isCanaryRead(e codec.LinkEncoder) bool {
+	if classify(e) == GetClass {
+		return true
+	}
+	// the requests translated to the classic protocol are only described by their command.
+	describer, ok := e.(codec.RequestDescriber)
+	if !ok {
+		return false
+	}
+	operation, _ := describer.Describe()
+	operation = strings.TrimPrefix(operation, "bulk ")
+	return strings.HasPrefix(operation, "get") || strings.HasPrefix(operation, "gat") || strings.HasPrefix(operation, "touch")
+}
+
+type routingHintKey struct{}
+
+// ContextWithRoutingHint attaches a routing hint to ctx, which places the requests issued with it: on a given backend
+// (codec.RouteToBackend), as if they targeted another key (codec.RouteByHashKey), or on every backend
+// (codec.RouteBroadcast). Broadcasts aren't supported once the client fell back to the classic protocol.
+func ContextWithRoutingHint(ctx context.Context, hint codec.RoutingHint) context.Context {
+	return context.WithValue(ctx, routingHintKey{}, hint)
+}
+
+// RoutingHintFromContext returns the routing hint attached by ContextWithRoutingHint, the zero hint if there is none.
+func RoutingHintFromContext(ctx context.Context) codec.RoutingHint {
+	hint, _ := ctx.Value(routingHintKey{}).(codec.RoutingHint)
+	return hint
+}
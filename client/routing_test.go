@@ -0,0 +1,184 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+func newTwoServerClient(t *testing.T, opts ...ClientOption) (MemcachedClient, []*fakeserver.Server) {
+	var servers []*fakeserver.Server
+	var addrs []string
+	for i := 0; i < 2; i++ {
+		srv, err := fakeserver.Start()
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = srv.Close() })
+		servers = append(servers, srv)
+		addrs = append(addrs, srv.Addr().String())
+	}
+
+	mc, err := NewClient(addrs, 1, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mc.Close() })
+	return mc, servers
+}
+
+func TestKeyHasher(t *testing.T) {
+	mc, servers := newTwoServerClient(t, WithKeyHasher(func(key string, n int) int {
+		if key == "second" {
+			return 1
+		}
+		return 0
+	}))
+	servers[1].Set("second", []byte("2"), 0)
+
+	values, err := mc.GetMulti(context.Background(), []string{"second"})
+	require.NoError(t, err)
+	// GetMulti places its keys with the hasher as well.
+	assert.Equal(t, map[string][]byte{"second": []byte("2")}, values)
+
+	get := memcache.CreateMetaGetEncoder()
+	get.Reset()
+	get.Key = "second"
+	get.FetchValue = true
+	decoder := memcache.CreateMetaGetDecoder()
+	require.NoError(t, mc.MetaGet(context.Background(), get, decoder))
+	assert.Equal(t, []byte("2"), decoder.Value)
+
+	// a routing hint places the whole request, whatever its keys.
+	ctx := ContextWithRoutingHint(context.Background(), codec.RouteByHashKey("first"))
+	values, err = mc.GetMulti(ctx, []string{"second"})
+	require.NoError(t, err)
+	assert.Empty(t, values)
+}
+
+func TestGetMultiShardsKeys(t *testing.T) {
+	mc, servers := newTwoServerClient(t, WithKeyHasher(func(key string, n int) int {
+		if strings.HasPrefix(key, "b") {
+			return 1
+		}
+		return 0
+	}))
+	servers[0].Set("a1", []byte("1"), 0)
+	servers[0].Set("a2", []byte("2"), 0)
+	servers[1].Set("b1", []byte("3"), 0)
+	// the keys placed on the other backend aren't looked up there.
+	servers[1].Set("a2", []byte("wrong"), 0)
+	before := []int{servers[0].CommandCount("mg"), servers[1].CommandCount("mg")}
+
+	values, err := mc.GetMulti(context.Background(), []string{"a1", "b1", "a2", "b2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a1": []byte("1"), "a2": []byte("2"), "b1": []byte("3")}, values)
+	assert.Equal(t, before[0]+2, servers[0].CommandCount("mg"))
+	assert.Equal(t, before[1]+2, servers[1].CommandCount("mg"))
+}
+
+func TestRoutingHintBroadcast(t *testing.T) {
+	mc, servers := newTwoServerClient(t)
+	before := []int{servers[0].CommandCount("md"), servers[1].CommandCount("md")}
+
+	decoders := make(chan *memcache.MetaDeleteDecoder, 2)
+	ctx := ContextWithRoutingHint(context.Background(), codec.RouteBroadcast(func(string) codec.LinkDecoder {
+		d := memcache.CreateMetaDeleteDecoder()
+		decoders <- d
+		return d
+	}))
+	del := memcache.CreateMetaDeleteEncoder()
+	del.Reset()
+	del.Key = "k"
+	require.NoError(t, mc.MetaDelete(ctx, del, memcache.CreateMetaDeleteDecoder()))
+
+	assert.Equal(t, before[0]+1, servers[0].CommandCount("md"))
+	assert.Equal(t, before[1]+1, servers[1].CommandCount("md"))
+	assert.Len(t, decoders, 2)
+}
+
+func TestSetMultiShardsItems(t *testing.T) {
+	mc, servers := newTwoServerClient(t, WithKeyHasher(func(key string, n int) int {
+		if strings.HasPrefix(key, "b") {
+			return 1
+		}
+		return 0
+	}))
+	before := []int{servers[0].CommandCount("mn"), servers[1].CommandCount("mn")}
+
+	items := []Item{{Key: "a1", Value: []byte("1")}, {Key: "b1", Value: []byte("2")}, {Key: "a2", Value: []byte("3")}}
+	statuses, err := mc.SetMulti(context.Background(), items)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]memcache.MetadataStatus{"a1": memcache.Stored, "b1": memcache.Stored, "a2": memcache.Stored}, statuses)
+
+	for i, keys := range [][]string{{"a1", "a2"}, {"b1"}} {
+		for _, key := range keys {
+			_, ok := servers[i].Get(key)
+			assert.True(t, ok, key)
+			_, ok = servers[1-i].Get(key)
+			assert.False(t, ok, key)
+		}
+		// every backend is sent a single pipeline, ending with a no-op.
+		assert.Equal(t, before[i]+1, servers[i].CommandCount("mn"))
+	}
+}
+
+func TestDeleteMultiShardsKeys(t *testing.T) {
+	mc, servers := newTwoServerClient(t, WithKeyHasher(func(key string, n int) int {
+		if strings.HasPrefix(key, "b") {
+			return 1
+		}
+		return 0
+	}))
+	servers[0].Set("a1", []byte("1"), 0)
+	servers[1].Set("b1", []byte("2"), 0)
+	// the keys placed on the other backend aren't deleted there.
+	servers[1].Set("a1", []byte("kept"), 0)
+
+	statuses, err := mc.DeleteMulti(context.Background(), []string{"a1", "b1", "b2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]memcache.MetadataStatus{"a1": memcache.Deleted, "b1": memcache.Deleted, "b2": memcache.NotFound}, statuses)
+	_, ok := servers[0].Get("a1")
+	assert.False(t, ok)
+	value, ok := servers[1].Get("a1")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("kept"), value)
+}
+
+func TestRoutingCanary(t *testing.T) {
+	// requests go to the first backend, the candidate places the keys starting with b on the second one.
+	mc, servers := newTwoServerClient(t,
+		WithKeyHasher(func(string, int) int { return 0 }),
+		WithRoutingCanary(func(key string, n int) int {
+			if strings.HasPrefix(key, "b") {
+				return 1
+			}
+			return 0
+		}),
+	)
+	ctx := context.Background()
+
+	require.NoError(t, mc.Set(ctx, "a", []byte("1"), 0))
+	require.NoError(t, mc.Set(ctx, "b", []byte("2"), 0))
+	_, _, err := mc.Get(ctx, "a")
+	require.NoError(t, err)
+	_, _, err = mc.Get(ctx, "b")
+	require.NoError(t, err)
+	// the routing is left untouched.
+	_, ok := servers[1].Get("b")
+	assert.False(t, ok)
+
+	stats := mc.Stats().Canary
+	require.NotNil(t, stats)
+	assert.Equal(t, uint64(2), stats.Reads)
+	assert.Equal(t, uint64(1), stats.DivergedReads)
+	assert.InDelta(t, 0.5, stats.EstimatedMissRatio(), 1e-9)
+	assert.GreaterOrEqual(t, stats.Routed, uint64(4))
+
+	var b strings.Builder
+	require.NoError(t, mc.Stats().WritePrometheus(&b))
+	assert.Contains(t, b.String(), "memlink_canary_diverged_total{kind=\"read\"} 1\n")
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/pools"
+)
+
+// Get returns the value stored under key along with its item, whose TTL is the remaining one. A miss fails with
+// ErrNotFound.
+func (c *memcachedClient) Get(ctx context.Context, key string) ([]byte, Item, error) {
+	result, err := c.GetWithTTL(ctx, key)
+	if err != nil {
+		return nil, Item{}, err
+	}
+	if !result.Found {
+		return nil, Item{}, fmt.Errorf("Get operation failed: key=%q: %w", key, ErrNotFound)
+	}
+	return result.Value, itemOf(key, result), nil
+}
+
+// itemOf returns the item of a GetWithTTL hit, memcached reports -1 for items which never expire.
+func itemOf(key string, result GetResult) Item {
+	return Item{
+		Key:         key,
+		Value:       result.Value,
+		TTL:         max(result.RemainingTTLSeconds, 0),
+		ClientFlags: result.ClientFlags,
+	}
+}
+
+// Set stores value under key, whether it exists or not, with a TTL of ttl seconds, 0 meaning it never expires.
+func (c *memcachedClient) Set(ctx context.Context, key string, value []byte, ttl int32) error {
+	// the empty mode is a plain set.
+	if err := c.conditionalSet(ctx, "", Item{Key: key, Value: value, TTL: ttl}); err != nil {
+		return fmt.Errorf("Set operation failed: %w", err)
+	}
+	return nil
+}
+
+// GetOrSet returns the value stored under key, or else the value returned by loader, which it stores with a TTL of ttl
+// seconds. A loader failure is returned as is and nothing is stored, a failure to read key fails without calling
+// loader. When the loaded value can't be stored, it's returned along with the error.
+func (c *memcachedClient) GetOrSet(ctx context.Context, key string, ttl int32, loader func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	return getOrSet(ctx, c, key, ttl, loader)
+}
+
+// getOrSet implements GetOrSet with the Get and Set of mc.
+func getOrSet(ctx context.Context, mc MemcachedClient, key string, ttl int32, loader func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	value, _, err := mc.Get(ctx, key)
+	if err == nil {
+		return value, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("GetOrSet operation failed: %w", err)
+	}
+
+	value, err = loader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := mc.Set(ctx, key, value, ttl); err != nil {
+		return value, fmt.Errorf("GetOrSet operation failed: %w", err)
+	}
+	return value, nil
+}
+
+// Delete removes key, failing with ErrNotFound if it doesn't exist.
+func (c *memcachedClient) Delete(ctx context.Context, key string) error {
+	encoder := deleteEncoderPool.Get()
+	decoder := deleteDecoderPool.Get()
+	defer pools.Release(ctx, deleteEncoderPool, encoder, deleteDecoderPool, decoder)
+
+	encoder.Key = key
+	c.assignOpaque(ctx, &encoder.Opaque)
+
+	if err := c.append(ctx, encoder, decoder); err != nil {
+		return fmt.Errorf("Delete operation failed: %w", err)
+	}
+
+	switch decoder.Status {
+	case memcache.Deleted:
+		return nil
+	case memcache.NotFound:
+		return fmt.Errorf("Delete operation failed: key=%q: %w", key, ErrNotFound)
+	default:
+		return fmt.Errorf("Delete operation failed: unexpected status for key=%q: %s %q", key, decoder.Status, decoder.HdrLine)
+	}
+}
+
+// Touch sets the TTL of key to ttl seconds, 0 meaning it never expires, without fetching its value. It fails with
+// ErrNotFound if the key doesn't exist.
+func (c *memcachedClient) Touch(ctx context.Context, key string, ttl int32) error {
+	if err := c.touch(ctx, key, ttl, time.Time{}); err != nil {
+		return fmt.Errorf("Touch operation failed: %w", err)
+	}
+	return nil
+}
+
+// TouchUntil sets key to expire at expireAt, a zero one meaning it never expires, without fetching its value. It fails
+// with ErrNotFound if the key doesn't exist.
+func (c *memcachedClient) TouchUntil(ctx context.Context, key string, expireAt time.Time) error {
+	if err := c.touch(ctx, key, 0, expireAt); err != nil {
+		return fmt.Errorf("TouchUntil operation failed: %w", err)
+	}
+	return nil
+}
+
+// touch sets key to expire at expireAt if it's set, or else in ttl seconds.
+func (c *memcachedClient) touch(ctx context.Context, key string, ttl int32, expireAt time.Time) error {
+	encoder := getEncoderPool.Get()
+	decoder := getDecoderPool.Get()
+	defer pools.Release(ctx, getEncoderPool, encoder, getDecoderPool, decoder)
+
+	if c.ttlPolicy != nil {
+		ttl, expireAt = policyTTL(c.ttlPolicy, key, ttl, expireAt)
+	}
+	encoder.Key = key
+	if expireAt.IsZero() {
+		encoder.UpdateTTL = max(ttl, 0)
+	} else {
+		encoder.UpdateExpireAt = expireAt
+	}
+	c.assignOpaque(ctx, &encoder.Opaque)
+
+	if err := c.append(ctx, encoder, decoder); err != nil {
+		return err
+	}
+
+	switch decoder.Status {
+	case memcache.CacheHit:
+		return nil
+	case memcache.CacheMiss:
+		return fmt.Errorf("key=%q: %w", key, ErrNotFound)
+	default:
+		return fmt.Errorf("unexpected status for key=%q: %s %q", key, decoder.Status, decoder.HdrLine)
+	}
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSetDelete(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+
+	_, _, err := mc.Get(ctx, "k")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, mc.Set(ctx, "k", []byte("v1"), 60))
+	require.NoError(t, mc.Set(ctx, "k", []byte("v2"), 0))
+	stored, ok := srv.Get("k")
+	require.True(t, ok)
+	assert.Equal(t, []byte("v2"), stored)
+
+	value, item, err := mc.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v2"), value)
+	assert.Equal(t, Item{Key: "k", Value: []byte("v2")}, item)
+
+	require.NoError(t, mc.Delete(ctx, "k"))
+	assert.ErrorIs(t, mc.Delete(ctx, "k"), ErrNotFound)
+	_, _, err = mc.Get(ctx, "k")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestGetReportsRemainingTTL(t *testing.T) {
+	mc, _ := newTestClient(t)
+	ctx := context.Background()
+
+	require.NoError(t, mc.Set(ctx, "k", []byte("v"), 60))
+	_, item, err := mc.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.InDelta(t, 60, item.TTL, 1)
+}
+
+func TestTouch(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, mc.Touch(ctx, "k", 60), ErrNotFound)
+
+	require.NoError(t, mc.Set(ctx, "k", []byte("v"), 0))
+	require.NoError(t, mc.Touch(ctx, "k", 60))
+	value, item, err := mc.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v"), value)
+	assert.InDelta(t, 60, item.TTL, 1)
+	assert.Equal(t, 3, srv.CommandCount("mg"))
+}
+
+func TestGetOrSet(t *testing.T) {
+	mc, srv := newTestClient(t)
+	ctx := context.Background()
+	loads := 0
+	loader := func(context.Context) ([]byte, error) {
+		loads++
+		return []byte("loaded"), nil
+	}
+
+	value, err := mc.GetOrSet(ctx, "k", 60, loader)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("loaded"), value)
+	_, item, err := mc.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.InDelta(t, 60, item.TTL, 1)
+
+	// a hit doesn't call the loader.
+	srv.Set("k", []byte("cached"), 0)
+	value, err = mc.GetOrSet(ctx, "k", 60, loader)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("cached"), value)
+	assert.Equal(t, 1, loads)
+
+	// nothing is stored when the loader fails.
+	errOrigin := errors.New("origin is down")
+	_, err = mc.GetOrSet(ctx, "other", 60, func(context.Context) ([]byte, error) {
+		return nil, errOrigin
+	})
+	assert.ErrorIs(t, err, errOrigin)
+	_, ok := srv.Get("other")
+	assert.False(t, ok)
+
+	// the loader isn't called when the key can't be read.
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = mc.GetOrSet(cancelled, "other", 60, loader)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, loads)
+
+	billing := mc.WithNamespace("billing")
+	value, err = billing.GetOrSet(ctx, "k", 0, loader)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("loaded"), value)
+	stored, ok := srv.Get("billing:k")
+	require.True(t, ok)
+	assert.Equal(t, []byte("loaded"), stored)
+}
+
+func TestNamespacedGetSetDelete(t *testing.T) {
+	mc, srv := newTestClient(t)
+	billing := mc.WithNamespace("billing")
+	ctx := context.Background()
+
+	require.NoError(t, billing.Set(ctx, "k", []byte("v"), 0))
+	_, ok := srv.Get("billing:k")
+	assert.True(t, ok)
+
+	value, item, err := billing.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v"), value)
+	assert.Equal(t, "k", item.Key)
+	_, _, err = mc.Get(ctx, "k")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, billing.Delete(ctx, "k"))
+	_, ok = srv.Get("billing:k")
+	assert.False(t, ok)
+}
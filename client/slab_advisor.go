@@ -0,0 +1,270 @@
+package client
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultSlabAdvisorInterval = time.Minute
+
+// SlabClassReport describes the memory usage and eviction pressure of a single slab class of a backend.
+type SlabClassReport struct {
+	Class     int
+	ChunkSize uint64
+	Items     uint64
+	// Evictions is the number of items evicted from the class since the backend started.
+	Evictions uint64
+	// EvictionsPerSecond is the eviction rate since the previous poll, 0 on the first one.
+	EvictionsPerSecond float64
+	// Fragmentation is the fraction of the memory of the used chunks which isn't used by the items stored in them.
+	Fragmentation float64
+}
+
+// BackendSlabReport holds the slab classes of a backend, ordered by class id.
+type BackendSlabReport struct {
+	Backend string
+	Classes []SlabClassReport
+}
+
+// SlabReport is the outcome of a SlabAdvisor poll.
+type SlabReport struct {
+	At       time.Time
+	Backends []BackendSlabReport
+}
+
+// SlabAdvisor periodically polls the slab and item statistics of every backend to compute per slab class eviction
+// rates and memory fragmentation. A high eviction rate in a class usually means items of that size don't get enough
+// memory, which can be fixed by tuning the slab growth factor or enabling slab reassignment.
+type SlabAdvisor struct {
+	mc                 MemcachedClient
+	interval           time.Duration
+	evictionsThreshold float64
+	logger             *zap.Logger
+
+	mu     sync.Mutex
+	report SlabReport // protected by mu
+
+	// only accessed by the poll routine
+	prevEvictions map[string]map[int]uint64
+	prevAt        time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type SlabAdvisorOption func(a *SlabAdvisor)
+
+// WithSlabAdvisorInterval sets how often the backends are polled.
+func WithSlabAdvisorInterval(interval time.Duration) SlabAdvisorOption {
+	return func(a *SlabAdvisor) {
+		a.interval = interval
+	}
+}
+
+// WithEvictionWarningThreshold logs a warning for every slab class evicting more than perSecond items per second.
+func WithEvictionWarningThreshold(perSecond float64) SlabAdvisorOption {
+	return func(a *SlabAdvisor) {
+		a.evictionsThreshold = perSecond
+	}
+}
+
+func WithSlabAdvisorLogger(logger *zap.Logger) SlabAdvisorOption {
+	return func(a *SlabAdvisor) {
+		a.logger = logger
+	}
+}
+
+// NewSlabAdvisor creates a SlabAdvisor and starts polling the backends of mc in a background routine until Close is
+// called.
+func NewSlabAdvisor(mc MemcachedClient, opts ...SlabAdvisorOption) *SlabAdvisor {
+	a := &SlabAdvisor{
+		mc:            mc,
+		interval:      defaultSlabAdvisorInterval,
+		prevEvictions: make(map[string]map[int]uint64),
+		done:          make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	if a.logger == nil {
+		a.logger = zap.NewNop()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	go a.poll(ctx)
+	return a
+}
+
+// Report returns the outcome of the latest successful poll, which is empty until the first one completes.
+func (a *SlabAdvisor) Report() SlabReport {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.report
+}
+
+// Close stops the poll routine and waits for it to exit.
+func (a *SlabAdvisor) Close() {
+	a.cancel()
+	<-a.done
+}
+
+func (a *SlabAdvisor) poll(ctx context.Context) {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.pollOnce(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			a.logger.Warn("failed to poll slab statistics", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *SlabAdvisor) pollOnce(ctx context.Context, now time.Time) error {
+	slabs, err := a.mc.ServerStats(ctx, "slabs")
+	if err != nil {
+		return err
+	}
+	items, err := a.mc.ServerStats(ctx, "items")
+	if err != nil {
+		return err
+	}
+
+	elapsed := now.Sub(a.prevAt).Seconds()
+	report := SlabReport{At: now}
+	for backend, stats := range slabs {
+		classes := slabClassReports(stats, items[backend])
+
+		prev := a.prevEvictions[backend]
+		evictions := make(map[int]uint64, len(classes))
+		for i := range classes {
+			class := &classes[i]
+			evictions[class.Class] = class.Evictions
+
+			// a restarted backend resets its counters, don't report a rate until the next poll.
+			if before, ok := prev[class.Class]; ok && class.Evictions >= before && elapsed > 0 {
+				class.EvictionsPerSecond = float64(class.Evictions-before) / elapsed
+			}
+
+			if a.evictionsThreshold > 0 && class.EvictionsPerSecond > a.evictionsThreshold {
+				a.logger.Warn("slab class is under eviction pressure",
+					zap.String("backend", backend),
+					zap.Int("class", class.Class),
+					zap.Uint64("chunk_size", class.ChunkSize),
+					zap.Float64("evictions_per_second", class.EvictionsPerSecond))
+			}
+		}
+		a.prevEvictions[backend] = evictions
+
+		report.Backends = append(report.Backends, BackendSlabReport{Backend: backend, Classes: classes})
+	}
+	a.prevAt = now
+
+	slices.SortFunc(report.Backends, func(x, y BackendSlabReport) int {
+		return strings.Compare(x.Backend, y.Backend)
+	})
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.report = report
+	return nil
+}
+
+// slabClassReports combines the "<class>:<stat>" entries of stats slabs with the "items:<class>:<stat>" entries of
+// stats items.
+func slabClassReports(slabs map[string]string, items map[string]string) []SlabClassReport {
+	classes := make(map[int]*SlabClassReport)
+	class := func(id int) *SlabClassReport {
+		c, ok := classes[id]
+		if !ok {
+			c = &SlabClassReport{Class: id}
+			classes[id] = c
+		}
+		return c
+	}
+
+	memRequested := make(map[int]uint64)
+	usedChunks := make(map[int]uint64)
+	for name, value := range slabs {
+		id, stat, ok := parseClassStat(name)
+		if !ok {
+			continue
+		}
+
+		v, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		switch stat {
+		case "chunk_size":
+			class(id).ChunkSize = v
+		case "mem_requested":
+			memRequested[id] = v
+		case "used_chunks":
+			usedChunks[id] = v
+		}
+	}
+
+	for name, value := range items {
+		id, stat, ok := parseClassStat(strings.TrimPrefix(name, "items:"))
+		if !ok {
+			continue
+		}
+
+		v, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		switch stat {
+		case "number":
+			class(id).Items = v
+		case "evicted":
+			class(id).Evictions = v
+		}
+	}
+
+	reports := make([]SlabClassReport, 0, len(classes))
+	for id, c := range classes {
+		if allocated := usedChunks[id] * c.ChunkSize; allocated > 0 {
+			c.Fragmentation = 1 - float64(memRequested[id])/float64(allocated)
+		}
+		reports = append(reports, *c)
+	}
+
+	slices.SortFunc(reports, func(x, y SlabClassReport) int {
+		return x.Class - y.Class
+	})
+	return reports
+}
+
+// parseClassStat splits a "<class>:<stat>" statistic name, the global statistics don't have a class.
+func parseClassStat(name string) (int, string, bool) {
+	idStr, stat, ok := strings.Cut(name, ":")
+	if !ok {
+		return 0, "", false
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, "", false
+	}
+	return id, stat, true
+}
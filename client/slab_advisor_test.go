@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+func setSlabStats(srv *fakeserver.Server, evicted string) {
+	srv.SetStats("slabs", map[string]string{
+		"1:chunk_size":    "96",
+		"1:used_chunks":   "10",
+		"1:mem_requested": "720",
+		"2:chunk_size":    "120",
+		"active_slabs":    "2",
+	})
+	srv.SetStats("items", map[string]string{
+		"items:1:number":  "10",
+		"items:1:evicted": evicted,
+	})
+}
+
+func TestSlabAdvisorPollOnce(t *testing.T) {
+	mc, srv := newTestClient(t)
+	core, logs := observer.New(zapcore.WarnLevel)
+	advisor := &SlabAdvisor{
+		mc:                 mc,
+		evictionsThreshold: 5,
+		logger:             zap.New(core),
+		prevEvictions:      make(map[string]map[int]uint64),
+	}
+
+	start := time.Now()
+	setSlabStats(srv, "100")
+	require.NoError(t, advisor.pollOnce(context.Background(), start))
+
+	report := advisor.Report()
+	require.Len(t, report.Backends, 1)
+	assert.Equal(t, srv.Addr().String(), report.Backends[0].Backend)
+	assert.Equal(t, []SlabClassReport{
+		{Class: 1, ChunkSize: 96, Items: 10, Evictions: 100, Fragmentation: 0.25},
+		{Class: 2, ChunkSize: 120},
+	}, report.Backends[0].Classes)
+	assert.Zero(t, logs.Len())
+
+	setSlabStats(srv, "200")
+	require.NoError(t, advisor.pollOnce(context.Background(), start.Add(10*time.Second)))
+
+	report = advisor.Report()
+	assert.InDelta(t, 10.0, report.Backends[0].Classes[0].EvictionsPerSecond, 0.001)
+	assert.Equal(t, 1, logs.FilterMessage("slab class is under eviction pressure").Len())
+}
+
+func TestSlabAdvisor(t *testing.T) {
+	mc, srv := newTestClient(t)
+	setSlabStats(srv, "0")
+
+	advisor := NewSlabAdvisor(mc, WithSlabAdvisorInterval(10*time.Millisecond))
+	defer advisor.Close()
+
+	assert.Eventually(t, func() bool {
+		return len(advisor.Report().Backends) == 1
+	}, time.Second, 10*time.Millisecond)
+}
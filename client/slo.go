@@ -0,0 +1,133 @@
+package client
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// OperationClass groups the requests sharing a latency target.
+type OperationClass string
+
+const (
+	// GetClass covers the gets, single and bulk.
+	GetClass OperationClass = "get"
+	// SetClass covers the sets, adds, replaces, appends and prepends, single and bulk.
+	SetClass OperationClass = "set"
+	// DeleteClass covers the deletes, single and bulk.
+	DeleteClass OperationClass = "delete"
+	// ArithmeticClass covers the increments and decrements.
+	ArithmeticClass OperationClass = "arithmetic"
+)
+
+// SLOHook is called for every request of a class with a target which burns its budget, i.e. took longer than the
+// target or failed, in which case err is set. It's called by the goroutine of the request and must not block.
+type SLOHook func(class OperationClass, latency time.Duration, err error)
+
+// WithSLOTarget sets the latency target of the requests of class. The requests exceeding it, or failing, burn the
+// budget of the class, which is reported by Stats and to the hook set with WithSLOHook.
+func WithSLOTarget(class OperationClass, target time.Duration) ClientOption {
+	return func(c *memcachedClient) {
+		c.ensureSLO().classes[class] = &sloClass{target: target}
+	}
+}
+
+// WithSLOHook calls hook for every request burning the budget of its class, letting the caller trip its own
+// degradation logic.
+func WithSLOHook(hook SLOHook) ClientOption {
+	return func(c *memcachedClient) {
+		c.ensureSLO().hook = hook
+	}
+}
+
+func (c *memcachedClient) ensureSLO() *sloTracker {
+	if c.slo == nil {
+		c.slo = &sloTracker{classes: make(map[OperationClass]*sloClass)}
+	}
+	return c.slo
+}
+
+// SLOStats are the budget counters of an operation class.
+type SLOStats struct {
+	Target time.Duration
+	// Requests is the number of requests of the class.
+	Requests uint64
+	// Slow is the number of requests which succeeded after the target.
+	Slow uint64
+	// Failed is the number of requests which failed, whatever their latency.
+	Failed uint64
+}
+
+// BurnRate is the fraction of the requests which burned the budget, slow or failed.
+func (s SLOStats) BurnRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Slow+s.Failed) / float64(s.Requests)
+}
+
+// sloTracker counts the requests exceeding the target of their class. classes is only written by the options, so
+// it's read without locking.
+type sloTracker struct {
+	classes map[OperationClass]*sloClass
+	hook    SLOHook
+}
+
+type sloClass struct {
+	target   time.Duration
+	requests atomic.Uint64
+	slow     atomic.Uint64
+	failed   atomic.Uint64
+}
+
+func (t *sloTracker) observe(encoder codec.LinkEncoder, latency time.Duration, err error) {
+	class := classify(encoder)
+	counters, ok := t.classes[class]
+	if !ok {
+		return
+	}
+
+	counters.requests.Add(1)
+	switch {
+	case err != nil:
+		counters.failed.Add(1)
+	case latency > counters.target:
+		counters.slow.Add(1)
+	default:
+		return
+	}
+
+	if t.hook != nil {
+		t.hook(class, latency, err)
+	}
+}
+
+func (t *sloTracker) snapshot() map[OperationClass]SLOStats {
+	stats := make(map[OperationClass]SLOStats, len(t.classes))
+	for class, counters := range t.classes {
+		stats[class] = SLOStats{
+			Target:   counters.target,
+			Requests: counters.requests.Load(),
+			Slow:     counters.slow.Load(),
+			Failed:   counters.failed.Load(),
+		}
+	}
+	return stats
+}
+
+func classify(encoder codec.LinkEncoder) OperationClass {
+	switch encoder.(type) {
+	case *memcache.MetaGetEncoder, *memcache.BulkEncoder[*memcache.MetaGetEncoder]:
+		return GetClass
+	case *memcache.MetaSetEncoder, *memcache.BulkEncoder[*memcache.MetaSetEncoder]:
+		return SetClass
+	case *memcache.MetaDeleteEncoder, *memcache.BulkEncoder[*memcache.MetaDeleteEncoder]:
+		return DeleteClass
+	case *memcache.MetaArithmeticEncoder:
+		return ArithmeticClass
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+func TestSLOBudget(t *testing.T) {
+	var mu sync.Mutex
+	var burned []OperationClass
+	mc, srv := newTestClient(t,
+		WithSLOTarget(GetClass, 20*time.Millisecond),
+		WithSLOTarget(SetClass, time.Second),
+		WithSLOHook(func(class OperationClass, latency time.Duration, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			burned = append(burned, class)
+		}),
+	)
+	ctx := context.Background()
+
+	require.NoError(t, mc.Add(ctx, Item{Key: "key", Value: []byte("value")}))
+	_, err := mc.GetWithTTL(ctx, "key")
+	require.NoError(t, err)
+
+	srv.SetLatency("mg", 50*time.Millisecond)
+	_, err = mc.GetWithTTL(ctx, "key")
+	require.NoError(t, err)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = mc.GetWithTTL(canceled, "key")
+	require.ErrorIs(t, err, context.Canceled)
+
+	// deletes have no target.
+	_, err = mc.DeleteMulti(ctx, []string{"key"})
+	require.NoError(t, err)
+
+	stats := mc.Stats()
+	assert.Equal(t, map[OperationClass]SLOStats{
+		GetClass: {Target: 20 * time.Millisecond, Requests: 3, Slow: 1, Failed: 1},
+		SetClass: {Target: time.Second, Requests: 1},
+	}, stats.SLO)
+	assert.InDelta(t, 2.0/3, stats.SLO[GetClass].BurnRate(), 0.001)
+	assert.Zero(t, stats.SLO[SetClass].BurnRate())
+
+	mu.Lock()
+	assert.Equal(t, []OperationClass{GetClass, GetClass}, burned)
+	mu.Unlock()
+
+	var b bytes.Buffer
+	require.NoError(t, stats.WritePrometheus(&b))
+	assert.Contains(t, b.String(), `memlink_slo_target_seconds{class="get"} 0.02`+"\n")
+	assert.Contains(t, b.String(), `memlink_slo_requests_total{class="get"} 3`+"\n")
+	assert.Contains(t, b.String(), `memlink_slo_budget_burned_total{class="get",reason="slow"} 1`+"\n")
+	assert.Contains(t, b.String(), `memlink_slo_budget_burned_total{class="set",reason="failed"} 0`+"\n")
+}
+
+func TestClassify(t *testing.T) {
+	assert.Equal(t, GetClass, classify(memcache.CreateBulkEncoder[*memcache.MetaGetEncoder](0)))
+	assert.Equal(t, SetClass, classify(memcache.CreateMetaSetEncoder()))
+	assert.Equal(t, DeleteClass, classify(&memcache.MetaDeleteEncoder{}))
+	assert.Equal(t, ArithmeticClass, classify(memcache.CreateArithmeticEncoder()))
+	assert.Equal(t, OperationClass(""), classify(&memcache.VersionEncoder{}))
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// softTTLMagic starts the values wrapped in a soft TTL envelope, followed by the version of the envelope.
+var softTTLMagic = []byte{0xfe, 'S', 'T', 1}
+
+// softTTLHeaderSize is the size of the envelope: the magic, the write time in unix milliseconds and the soft TTL in
+// milliseconds.
+const softTTLHeaderSize = 4 + 8 + 4
+
+// ErrSoftTTLPrepend is returned by PrependValue when a soft TTL is set, the prepended bytes would hide the envelope.
+var ErrSoftTTLPrepend = errors.New("memcached: values can't be prepended to with a soft TTL")
+
+// WithSoftTTL wraps the values written by SetMulti, Add and Replace in a 16 bytes envelope holding their write time
+// and a soft TTL, softTTL unless the item sets its own. GetWithTTL unwraps them and reports the values older than
+// their soft TTL as stale, so that they can still be served, e.g. while the origin is down, though memcached's hard
+// TTL hasn't expired them. GetMulti unwraps them as well.
+//
+// The values written otherwise, e.g. by MetaSet or by other clients, are returned as is and never stale. Bytes
+// appended with AppendValue are part of the value, PrependValue fails with ErrSoftTTLPrepend.
+func WithSoftTTL(softTTL time.Duration) ClientOption {
+	return func(c *memcachedClient) {
+		c.softTTL = softTTL
+	}
+}
+
+// wrapValue wraps the value of item in a soft TTL envelope, unless the client has no soft TTL.
+func (c *memcachedClient) wrapValue(item Item) []byte {
+	if c.softTTL <= 0 {
+		return item.Value
+	}
+	softTTL := item.SoftTTL
+	if softTTL <= 0 {
+		softTTL = c.softTTL
+	}
+
+	value := make([]byte, softTTLHeaderSize, softTTLHeaderSize+len(item.Value))
+	copy(value, softTTLMagic)
+	binary.BigEndian.PutUint64(value[4:], uint64(time.Now().UnixMilli()))
+	binary.BigEndian.PutUint32(value[12:], uint32(min(softTTL.Milliseconds(), 1<<32-1)))
+	return append(value, item.Value...)
+}
+
+// softTTLEnvelope is the metadata of a value wrapped by wrapValue.
+type softTTLEnvelope struct {
+	writtenAt time.Time
+	softTTL   time.Duration
+}
+
+func (e softTTLEnvelope) stale(now time.Time) bool {
+	return !e.writtenAt.IsZero() && now.After(e.writtenAt.Add(e.softTTL))
+}
+
+// unwrapValue returns the value wrapped in a soft TTL envelope and its metadata, or value as is when the client has no
+// soft TTL or value isn't wrapped.
+func (c *memcachedClient) unwrapValue(value []byte) ([]byte, softTTLEnvelope) {
+	if c.softTTL <= 0 || len(value) < softTTLHeaderSize || !bytes.HasPrefix(value, softTTLMagic) {
+		return value, softTTLEnvelope{}
+	}
+	envelope := softTTLEnvelope{
+		writtenAt: time.UnixMilli(int64(binary.BigEndian.Uint64(value[4:]))),
+		softTTL:   time.Duration(binary.BigEndian.Uint32(value[12:])) * time.Millisecond,
+	}
+	return value[softTTLHeaderSize:], envelope
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// envelope wraps value like a client with a soft TTL would have at writtenAt.
+func envelope(value string, writtenAt time.Time, softTTL time.Duration) []byte {
+	wrapped := append([]byte{}, softTTLMagic...)
+	wrapped = binary.BigEndian.AppendUint64(wrapped, uint64(writtenAt.UnixMilli()))
+	wrapped = binary.BigEndian.AppendUint32(wrapped, uint32(softTTL.Milliseconds()))
+	return append(wrapped, value...)
+}
+
+func TestSoftTTL(t *testing.T) {
+	mc, srv := newTestClient(t, WithSoftTTL(time.Hour))
+	ctx := context.Background()
+
+	before := time.Now().Truncate(time.Millisecond)
+	_, err := mc.SetMulti(ctx, []Item{{Key: "fresh", Value: []byte("1")}})
+	require.NoError(t, err)
+	require.NoError(t, mc.Add(ctx, Item{Key: "short", Value: []byte("2"), SoftTTL: time.Millisecond}))
+	srv.Set("stale", envelope("3", time.Now().Add(-2*time.Hour), time.Hour), 0)
+	srv.Set("plain", []byte("4"), 0)
+
+	raw, ok := srv.Get("fresh")
+	require.True(t, ok)
+	assert.Len(t, raw, softTTLHeaderSize+1)
+
+	tests := []struct {
+		key   string
+		value string
+		stale bool
+	}{
+		{key: "fresh", value: "1"},
+		{key: "stale", value: "3", stale: true},
+		{key: "plain", value: "4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			result, err := mc.GetWithTTL(ctx, tt.key)
+			require.NoError(t, err)
+			assert.Equal(t, tt.value, string(result.Value))
+			assert.Equal(t, tt.stale, result.Stale)
+		})
+	}
+
+	result, err := mc.GetWithTTL(ctx, "fresh")
+	require.NoError(t, err)
+	assert.False(t, result.WrittenAt.Before(before))
+	result, err = mc.GetWithTTL(ctx, "plain")
+	require.NoError(t, err)
+	assert.True(t, result.WrittenAt.IsZero())
+
+	assert.Eventually(t, func() bool {
+		result, err := mc.GetWithTTL(ctx, "short")
+		return err == nil && result.Stale
+	}, time.Second, 5*time.Millisecond)
+
+	values, err := mc.GetMulti(ctx, []string{"fresh", "stale", "plain"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"fresh": []byte("1"), "stale": []byte("3"), "plain": []byte("4")}, values)
+
+	assert.ErrorIs(t, mc.PrependValue(ctx, "fresh", []byte("0"), 0), ErrSoftTTLPrepend)
+	require.NoError(t, mc.AppendValue(ctx, "fresh", []byte("5"), 0))
+	result, err = mc.GetWithTTL(ctx, "fresh")
+	require.NoError(t, err)
+	assert.Equal(t, "15", string(result.Value))
+}
+
+func TestSoftTTLDisabled(t *testing.T) {
+	mc, srv := newTestClient(t)
+	wrapped := envelope("1", time.Now(), time.Hour)
+	srv.Set("a", wrapped, 0)
+
+	result, err := mc.GetWithTTL(context.Background(), "a")
+	require.NoError(t, err)
+	assert.Equal(t, wrapped, result.Value)
+	assert.False(t, result.Stale)
+}
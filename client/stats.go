@@ -0,0 +1,700 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrew-d/csmrand"
+)
+
+// valueSizeBuckets are the upper bounds, in bytes, of the value size histograms. They grow by 4x, coarser than the
+// 1.25 growth factor of memcached slab classes but enough to tell which items land in the large classes.
+var valueSizeBuckets = []uint64{64, 256, 1024, 4 * 1024, 16 * 1024, 64 * 1024, 256 * 1024, 1024 * 1024, math.MaxUint64}
+
+// NamespaceFn maps a key to the namespace its stats are aggregated under.
+type NamespaceFn func(key string) string
+
+// PrefixNamespace is a NamespaceFn using the part of the key before the first sep, or the whole key if sep is absent.
+func PrefixNamespace(sep string) NamespaceFn {
+	return func(key string) string {
+		namespace, _, _ := strings.Cut(key, sep)
+		return namespace
+	}
+}
+
+// WithValueSizeStats records the size of a sampleRate fraction of the values written by the client, aggregated per
+// namespace. The histograms are reported by Stats.
+func WithValueSizeStats(sampleRate float64, namespace NamespaceFn) ClientOption {
+	return func(c *memcachedClient) {
+		c.valueSizes = &valueSizeRecorder{
+			sampleRate: sampleRate,
+			namespace:  namespace,
+			histograms: make(map[string]*SizeHistogram),
+		}
+	}
+}
+
+// ClientStats is a snapshot of the telemetry recorded by a client.
+type ClientStats struct {
+	// ValueSizeSampleRate is the fraction of the writes recorded in ValueSizes.
+	ValueSizeSampleRate float64
+	// ValueSizes holds the histogram of the sampled value sizes per namespace.
+	ValueSizes map[string]SizeHistogram
+	// UnclaimedResponses is the number of responses dropped, per backend, because no pending request claimed their
+	// opaque token.
+	UnclaimedResponses map[string]uint64
+	// Mirror holds the counters of the mirrored gets, nil unless WithMirror is set.
+	Mirror *MirrorStats
+	// SLO holds the budget counters of every operation class with a target set with WithSLOTarget.
+	SLO map[OperationClass]SLOStats
+	// Namespaces holds the counters of every namespace a view was created for with WithNamespace.
+	Namespaces map[string]NamespaceStats
+	// Tenants holds the counters of every tenant, nil unless WithTenantExtractor is set.
+	Tenants map[string]TenantStats
+	// Invalidations holds the counters of the invalidation events, nil unless WithInvalidationSink is set.
+	Invalidations *InvalidationStats
+	// Operations holds the counters of every operation and set of labels, nil unless WithOperationMetrics is set.
+	Operations []OperationStats
+	// Audit holds the counters of the audit log, nil unless WithAuditLog is set.
+	Audit *AuditStats
+	// Latencies holds the latency histogram of every operation and backend, nil unless WithLatencyHistograms is set.
+	Latencies []LatencyHistogram
+	// CollapsedKeys is the number of keys requested by GetMulti calls which were served by the fetch of another call,
+	// see WithGetMultiCollapsing.
+	CollapsedKeys uint64
+	// SharedLoads is the number of GetOrCompute calls which were served by the load of another call.
+	SharedLoads uint64
+	// Replay holds the counters of the writes queued for replay, nil unless WithWriteReplay is set.
+	Replay *ReplayStats
+	// ValueCompression holds the counters of the values compressed, nil unless WithValueCompression is set.
+	ValueCompression *ValueCompressionStats
+	// ValueEncryption holds the counters of the values encrypted, nil unless WithValueEncryption is set.
+	ValueEncryption *ValueEncryptionStats
+	// ValueChecksums holds the counters of the checksums of the values, nil unless WithValueChecksums is set.
+	ValueChecksums *ValueChecksumStats
+	// ValueChunking holds the counters of the values stored in chunks, nil unless WithValueChunking is set.
+	ValueChunking *ValueChunkingStats
+	// DegradedUntil is the end of the window set by SetDegraded, the zero time if the client isn't degraded.
+	DegradedUntil time.Time
+	// DegradedReads is the number of reads answered with a miss while the client was degraded.
+	DegradedReads uint64
+	// DroppedWrites is the number of writes dropped while the client was degraded.
+	DroppedWrites uint64
+	// DegradedFailures is the number of requests failed with ErrDegraded.
+	DegradedFailures uint64
+	// Admission holds the counters of the requests shed, nil unless WithAdmissionControl is set.
+	Admission *AdmissionStats
+	// Failover holds the counters of the cutovers to the standby cluster, nil unless WithStandbyCluster is set.
+	Failover *FailoverStats
+	// Abandoned holds the counters of the requests abandoned by their caller because its context ended.
+	Abandoned AbandonedStats
+	// Canary holds the counters of the placements compared with the candidate HasherFn, nil unless WithRoutingCanary
+	// is set.
+	Canary *CanaryStats
+}
+
+// SizeHistogram is a histogram of sizes in bytes.
+type SizeHistogram struct {
+	// UpperBounds are the inclusive upper bounds of the buckets, the last one being math.MaxUint64.
+	UpperBounds []uint64
+	// Counts holds the number of observations in every bucket, it's not cumulative.
+	Counts []uint64
+	Count  uint64
+	Sum    uint64
+}
+
+func newSizeHistogram() *SizeHistogram {
+	return &SizeHistogram{
+		UpperBounds: valueSizeBuckets,
+		Counts:      make([]uint64, len(valueSizeBuckets)),
+	}
+}
+
+func (h *SizeHistogram) observe(size uint64) {
+	idx, _ := slices.BinarySearch(h.UpperBounds, size)
+	h.Counts[idx]++
+	h.Count++
+	h.Sum += size
+}
+
+type valueSizeRecorder struct {
+	sampleRate float64
+	namespace  NamespaceFn
+
+	mu         sync.Mutex
+	histograms map[string]*SizeHistogram // protected by mu
+}
+
+func (r *valueSizeRecorder) record(key string, size int) {
+	if r.sampleRate < 1 && csmrand.Float64() >= r.sampleRate {
+		return
+	}
+
+	namespace := ""
+	if r.namespace != nil {
+		namespace = r.namespace(key)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h, ok := r.histograms[namespace]
+	if !ok {
+		h = newSizeHistogram()
+		r.histograms[namespace] = h
+	}
+	h.observe(uint64(size))
+}
+
+func (r *valueSizeRecorder) snapshot() map[string]SizeHistogram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	histograms := make(map[string]SizeHistogram, len(r.histograms))
+	for namespace, h := range r.histograms {
+		snapshot := *h
+		snapshot.Counts = slices.Clone(h.Counts)
+		histograms[namespace] = snapshot
+	}
+	return histograms
+}
+
+// Stats returns a snapshot of the telemetry recorded by the client.
+func (c *memcachedClient) Stats() ClientStats {
+	stats := ClientStats{
+		UnclaimedResponses: make(map[string]uint64),
+	}
+	for backend, connStats := range c.pool.Stats() {
+		stats.UnclaimedResponses[backend] = connStats.UnclaimedResponses
+	}
+
+	if c.valueSizes != nil {
+		stats.ValueSizeSampleRate = c.valueSizes.sampleRate
+		stats.ValueSizes = c.valueSizes.snapshot()
+	}
+	if c.mirror != nil {
+		stats.Mirror = c.mirror.snapshot()
+	}
+	if c.slo != nil {
+		stats.SLO = c.slo.snapshot()
+	}
+	stats.Namespaces = c.namespaceStats()
+	if c.tenants != nil && c.tenants.extractor != nil {
+		stats.Tenants = c.tenants.snapshot()
+	}
+	if c.invalidations != nil {
+		stats.Invalidations = c.invalidations.snapshot()
+	}
+	if c.operations != nil {
+		stats.Operations = c.operations.snapshot()
+	}
+	if c.audit != nil {
+		stats.Audit = c.audit.snapshot()
+	}
+	if c.latencies != nil {
+		stats.Latencies = c.latencies.snapshot()
+	}
+	if c.collapser != nil {
+		stats.CollapsedKeys = c.collapser.collapsed.Load()
+	}
+	stats.SharedLoads = c.loads.shared.Load()
+	if c.replay != nil {
+		stats.Replay = c.replay.snapshot()
+	}
+	if c.valueCompressor != nil {
+		stats.ValueCompression = c.valueCompressor.snapshot()
+	}
+	if c.encryptor != nil {
+		stats.ValueEncryption = c.encryptor.snapshot()
+	}
+	if c.checksummer != nil {
+		stats.ValueChecksums = c.checksummer.snapshot()
+	}
+	if c.chunker != nil {
+		stats.ValueChunking = c.chunker.snapshot()
+	}
+	stats.DegradedUntil = c.degradedUntilTime()
+	stats.DegradedReads = c.degradedReads.Load()
+	stats.DroppedWrites = c.droppedWrites.Load()
+	stats.DegradedFailures = c.degradedFailures.Load()
+	if c.admission != nil {
+		stats.Admission = c.admission.snapshot()
+	}
+	if c.failover != nil {
+		failover := c.failover.FailoverStats()
+		stats.Failover = &failover
+	}
+	stats.Abandoned = c.abandoned.snapshot()
+	if c.canary != nil {
+		canary := c.canary.CanaryStats()
+		stats.Canary = &canary
+	}
+	return stats
+}
+
+// WritePrometheus writes the stats in the Prometheus text exposition format, so they can be served from an existing
+// metrics endpoint without depending on a Prometheus client library.
+func (s ClientStats) WritePrometheus(w io.Writer) error {
+	var b strings.Builder
+	writeUnclaimedResponses(&b, s.UnclaimedResponses)
+	writeValueSizes(&b, s.ValueSizes)
+	writeMirror(&b, s.Mirror)
+	writeSLO(&b, s.SLO)
+	writeNamespaces(&b, s.Namespaces)
+	writeTenants(&b, s.Tenants)
+	writeInvalidations(&b, s.Invalidations)
+	writeOperations(&b, s.Operations)
+	writeAudit(&b, s.Audit)
+	writeLatencies(&b, s.Latencies)
+	writeCollapsedKeys(&b, s.CollapsedKeys)
+	writeSharedLoads(&b, s.SharedLoads)
+	writeCanary(&b, s.Canary)
+	writeDegraded(&b, s)
+	writeReplay(&b, s.Replay)
+	writeValueCompression(&b, s.ValueCompression)
+	writeValueEncryption(&b, s.ValueEncryption)
+	writeValueChecksums(&b, s.ValueChecksums)
+	writeValueChunking(&b, s.ValueChunking)
+	writeAdmission(&b, s.Admission)
+	writeFailover(&b, s.Failover)
+	writeAbandoned(&b, s.Abandoned)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeUnclaimedResponses(b *strings.Builder, unclaimed map[string]uint64) {
+	if len(unclaimed) == 0 {
+		return
+	}
+
+	b.WriteString("# HELP memlink_unclaimed_responses_total Responses dropped because no pending request claimed them.\n")
+	b.WriteString("# TYPE memlink_unclaimed_responses_total counter\n")
+
+	backends := make([]string, 0, len(unclaimed))
+	for backend := range unclaimed {
+		backends = append(backends, backend)
+	}
+	slices.Sort(backends)
+
+	for _, backend := range backends {
+		fmt.Fprintf(b, "memlink_unclaimed_responses_total{backend=%q} %d\n", backend, unclaimed[backend])
+	}
+}
+
+func writeValueSizes(b *strings.Builder, valueSizes map[string]SizeHistogram) {
+	if len(valueSizes) == 0 {
+		return
+	}
+
+	b.WriteString("# HELP memlink_value_size_bytes Size of the sampled values written to memcached.\n")
+	b.WriteString("# TYPE memlink_value_size_bytes histogram\n")
+
+	namespaces := make([]string, 0, len(valueSizes))
+	for namespace := range valueSizes {
+		namespaces = append(namespaces, namespace)
+	}
+	slices.Sort(namespaces)
+
+	for _, namespace := range namespaces {
+		h := valueSizes[namespace]
+		label := strconv.Quote(namespace)
+
+		cumulative := uint64(0)
+		for i, upperBound := range h.UpperBounds {
+			cumulative += h.Counts[i]
+			le := "+Inf"
+			if upperBound != math.MaxUint64 {
+				le = strconv.FormatUint(upperBound, 10)
+			}
+			fmt.Fprintf(b, "memlink_value_size_bytes_bucket{namespace=%s,le=%q} %d\n", label, le, cumulative)
+		}
+		fmt.Fprintf(b, "memlink_value_size_bytes_sum{namespace=%s} %d\n", label, h.Sum)
+		fmt.Fprintf(b, "memlink_value_size_bytes_count{namespace=%s} %d\n", label, h.Count)
+	}
+}
+
+func writeMirror(b *strings.Builder, mirror *MirrorStats) {
+	if mirror == nil {
+		return
+	}
+
+	b.WriteString("# HELP memlink_mirror_compared_total Gets answered by both the primary and the mirror pool.\n")
+	b.WriteString("# TYPE memlink_mirror_compared_total counter\n")
+	fmt.Fprintf(b, "memlink_mirror_compared_total %d\n", mirror.Compared)
+
+	b.WriteString("# HELP memlink_mirror_divergences_total Mirrored gets whose responses differ between the pools.\n")
+	b.WriteString("# TYPE memlink_mirror_divergences_total counter\n")
+	for _, kind := range []string{MirrorStatusDivergence, MirrorValueDivergence, MirrorFlagsDivergence, MirrorCasDivergence} {
+		fmt.Fprintf(b, "memlink_mirror_divergences_total{kind=%q} %d\n", kind, mirror.Divergences[kind])
+	}
+
+	b.WriteString("# HELP memlink_mirror_errors_total Mirrored gets which failed on the mirror pool.\n")
+	b.WriteString("# TYPE memlink_mirror_errors_total counter\n")
+	fmt.Fprintf(b, "memlink_mirror_errors_total %d\n", mirror.Errors)
+
+	b.WriteString("# HELP memlink_mirror_skipped_total Sampled gets not mirrored because too many were in flight.\n")
+	b.WriteString("# TYPE memlink_mirror_skipped_total counter\n")
+	fmt.Fprintf(b, "memlink_mirror_skipped_total %d\n", mirror.Skipped)
+}
+
+func writeCanary(b *strings.Builder, canary *CanaryStats) {
+	if canary == nil {
+		return
+	}
+
+	b.WriteString("# HELP memlink_canary_routed_total Requests whose placement was compared with the candidate routing.\n")
+	b.WriteString("# TYPE memlink_canary_routed_total counter\n")
+	fmt.Fprintf(b, "memlink_canary_routed_total{kind=\"all\"} %d\n", canary.Routed)
+	fmt.Fprintf(b, "memlink_canary_routed_total{kind=\"read\"} %d\n", canary.Reads)
+
+	b.WriteString("# HELP memlink_canary_diverged_total Compared requests the candidate routing places on another backend.\n")
+	b.WriteString("# TYPE memlink_canary_diverged_total counter\n")
+	fmt.Fprintf(b, "memlink_canary_diverged_total{kind=\"all\"} %d\n", canary.Diverged)
+	fmt.Fprintf(b, "memlink_canary_diverged_total{kind=\"read\"} %d\n", canary.DivergedReads)
+}
+
+func writeDegraded(b *strings.Builder, s ClientStats) {
+	if s.DegradedUntil.IsZero() && s.DegradedReads == 0 && s.DroppedWrites == 0 && s.DegradedFailures == 0 {
+		return
+	}
+
+	degraded := 0
+	if !s.DegradedUntil.IsZero() {
+		degraded = 1
+	}
+	b.WriteString("# HELP memlink_degraded Whether the client is degraded, see SetDegraded.\n")
+	b.WriteString("# TYPE memlink_degraded gauge\n")
+	fmt.Fprintf(b, "memlink_degraded %d\n", degraded)
+
+	b.WriteString("# HELP memlink_degraded_requests_total Requests short-circuited while the client was degraded.\n")
+	b.WriteString("# TYPE memlink_degraded_requests_total counter\n")
+	fmt.Fprintf(b, "memlink_degraded_requests_total{outcome=\"miss\"} %d\n", s.DegradedReads)
+	fmt.Fprintf(b, "memlink_degraded_requests_total{outcome=\"dropped\"} %d\n", s.DroppedWrites)
+	fmt.Fprintf(b, "memlink_degraded_requests_total{outcome=\"failed\"} %d\n", s.DegradedFailures)
+}
+
+func writeReplay(b *strings.Builder, replay *ReplayStats) {
+	if replay == nil {
+		return
+	}
+
+	b.WriteString("# HELP memlink_replay_queued Writes queued to be replayed.\n")
+	b.WriteString("# TYPE memlink_replay_queued gauge\n")
+	fmt.Fprintf(b, "memlink_replay_queued %d\n", replay.Queued)
+
+	b.WriteString("# HELP memlink_replay_writes_total Failed writes handled by the replay queue, by outcome.\n")
+	b.WriteString("# TYPE memlink_replay_writes_total counter\n")
+	fmt.Fprintf(b, "memlink_replay_writes_total{outcome=\"replayed\"} %d\n", replay.Replayed)
+	fmt.Fprintf(b, "memlink_replay_writes_total{outcome=\"dropped\"} %d\n", replay.Dropped)
+	fmt.Fprintf(b, "memlink_replay_writes_total{outcome=\"expired\"} %d\n", replay.Expired)
+}
+
+func writeValueCompression(b *strings.Builder, compression *ValueCompressionStats) {
+	if compression == nil {
+		return
+	}
+
+	b.WriteString("# HELP memlink_value_compression_values_total Values compressed before being written or decompressed after being read.\n")
+	b.WriteString("# TYPE memlink_value_compression_values_total counter\n")
+	fmt.Fprintf(b, "memlink_value_compression_values_total{direction=\"compressed\"} %d\n", compression.Compressed)
+	fmt.Fprintf(b, "memlink_value_compression_values_total{direction=\"decompressed\"} %d\n", compression.Decompressed)
+
+	b.WriteString("# HELP memlink_value_compression_saved_bytes_total Bytes saved by compressing the values written.\n")
+	b.WriteString("# TYPE memlink_value_compression_saved_bytes_total counter\n")
+	fmt.Fprintf(b, "memlink_value_compression_saved_bytes_total %d\n", compression.SavedBytes)
+}
+
+func writeValueEncryption(b *strings.Builder, encryption *ValueEncryptionStats) {
+	if encryption == nil {
+		return
+	}
+
+	b.WriteString("# HELP memlink_value_encryption_values_total Values encrypted before being written or decrypted after being read.\n")
+	b.WriteString("# TYPE memlink_value_encryption_values_total counter\n")
+	fmt.Fprintf(b, "memlink_value_encryption_values_total{direction=\"encrypted\"} %d\n", encryption.Encrypted)
+	fmt.Fprintf(b, "memlink_value_encryption_values_total{direction=\"decrypted\"} %d\n", encryption.Decrypted)
+
+	b.WriteString("# HELP memlink_value_encryption_failures_total Values read which couldn't be decrypted.\n")
+	b.WriteString("# TYPE memlink_value_encryption_failures_total counter\n")
+	fmt.Fprintf(b, "memlink_value_encryption_failures_total %d\n", encryption.Failures)
+}
+
+func writeValueChecksums(b *strings.Builder, checksums *ValueChecksumStats) {
+	if checksums == nil {
+		return
+	}
+
+	b.WriteString("# HELP memlink_value_checksums_written_total Values written along with their checksum.\n")
+	b.WriteString("# TYPE memlink_value_checksums_written_total counter\n")
+	fmt.Fprintf(b, "memlink_value_checksums_written_total %d\n", checksums.Written)
+
+	b.WriteString("# HELP memlink_value_checksums_verified_total Values read whose checksum was verified.\n")
+	b.WriteString("# TYPE memlink_value_checksums_verified_total counter\n")
+	fmt.Fprintf(b, "memlink_value_checksums_verified_total{result=\"ok\"} %d\n", checksums.Verified)
+	fmt.Fprintf(b, "memlink_value_checksums_verified_total{result=\"corrupt\"} %d\n", checksums.Corrupt)
+}
+
+func writeValueChunking(b *strings.Builder, chunking *ValueChunkingStats) {
+	if chunking == nil {
+		return
+	}
+
+	b.WriteString("# HELP memlink_chunked_values_total Values written in chunks or reassembled from their chunks.\n")
+	b.WriteString("# TYPE memlink_chunked_values_total counter\n")
+	fmt.Fprintf(b, "memlink_chunked_values_total{operation=\"write\"} %d\n", chunking.Writes)
+	fmt.Fprintf(b, "memlink_chunked_values_total{operation=\"read\"} %d\n", chunking.Reads)
+
+	b.WriteString("# HELP memlink_value_chunks_written_total Chunks written for the values exceeding the max value size.\n")
+	b.WriteString("# TYPE memlink_value_chunks_written_total counter\n")
+	fmt.Fprintf(b, "memlink_value_chunks_written_total %d\n", chunking.Chunks)
+
+	b.WriteString("# HELP memlink_chunked_read_failures_total Chunked values which couldn't be reassembled.\n")
+	b.WriteString("# TYPE memlink_chunked_read_failures_total counter\n")
+	fmt.Fprintf(b, "memlink_chunked_read_failures_total{reason=\"incomplete\"} %d\n", chunking.IncompleteReads)
+	fmt.Fprintf(b, "memlink_chunked_read_failures_total{reason=\"corrupt\"} %d\n", chunking.CorruptReads)
+}
+
+func writeAdmission(b *strings.Builder, admission *AdmissionStats) {
+	if admission == nil {
+		return
+	}
+
+	b.WriteString("# HELP memlink_admission_in_flight Requests waiting for their response.\n")
+	b.WriteString("# TYPE memlink_admission_in_flight gauge\n")
+	fmt.Fprintf(b, "memlink_admission_in_flight %d\n", admission.InFlight)
+
+	b.WriteString("# HELP memlink_admission_latency_seconds Moving average of the latency of the requests.\n")
+	b.WriteString("# TYPE memlink_admission_latency_seconds gauge\n")
+	fmt.Fprintf(b, "memlink_admission_latency_seconds %g\n", admission.Latency.Seconds())
+
+	b.WriteString("# HELP memlink_admission_overloaded_requests_total Requests which found the client overloaded.\n")
+	b.WriteString("# TYPE memlink_admission_overloaded_requests_total counter\n")
+	fmt.Fprintf(b, "memlink_admission_overloaded_requests_total %d\n", admission.OverloadedRequests)
+
+	b.WriteString("# HELP memlink_admission_shed_total Requests shed while the client was overloaded.\n")
+	b.WriteString("# TYPE memlink_admission_shed_total counter\n")
+	fmt.Fprintf(b, "memlink_admission_shed_total %d\n", admission.Shed)
+}
+
+func writeFailover(b *strings.Builder, failover *FailoverStats) {
+	if failover == nil {
+		return
+	}
+
+	b.WriteString("# HELP memlink_failover_active Whether the requests are sent to the cluster.\n")
+	b.WriteString("# TYPE memlink_failover_active gauge\n")
+	for _, cluster := range []FailoverCluster{FailoverPrimary, FailoverStandby} {
+		active := 0
+		if failover.Active == cluster {
+			active = 1
+		}
+		fmt.Fprintf(b, "memlink_failover_active{cluster=%q} %d\n", cluster, active)
+	}
+
+	b.WriteString("# HELP memlink_failover_switches_total Times the requests were sent to another cluster.\n")
+	b.WriteString("# TYPE memlink_failover_switches_total counter\n")
+	fmt.Fprintf(b, "memlink_failover_switches_total{to=\"standby\"} %d\n", failover.Cutovers)
+	fmt.Fprintf(b, "memlink_failover_switches_total{to=\"primary\"} %d\n", failover.Cutbacks)
+
+	b.WriteString("# HELP memlink_failover_standby_requests_total Requests sent to the standby cluster.\n")
+	b.WriteString("# TYPE memlink_failover_standby_requests_total counter\n")
+	fmt.Fprintf(b, "memlink_failover_standby_requests_total %d\n", failover.StandbyAppends)
+
+	b.WriteString("# HELP memlink_failover_postponed_cutbacks_total Cutbacks postponed because the keys written to the standby cluster couldn't be invalidated on the primary one.\n")
+	b.WriteString("# TYPE memlink_failover_postponed_cutbacks_total counter\n")
+	fmt.Fprintf(b, "memlink_failover_postponed_cutbacks_total %d\n", failover.PostponedCutbacks)
+}
+
+func writeAbandoned(b *strings.Builder, abandoned AbandonedStats) {
+	b.WriteString("# HELP memlink_abandoned_requests_total Requests abandoned by their caller because its context ended.\n")
+	b.WriteString("# TYPE memlink_abandoned_requests_total counter\n")
+	fmt.Fprintf(b, "memlink_abandoned_requests_total{reason=\"canceled\"} %d\n", abandoned.Canceled)
+	fmt.Fprintf(b, "memlink_abandoned_requests_total{reason=\"deadline_exceeded\"} %d\n", abandoned.DeadlineExceeded)
+
+	b.WriteString("# HELP memlink_abandoned_completions_total Abandoned requests which completed afterwards.\n")
+	b.WriteString("# TYPE memlink_abandoned_completions_total counter\n")
+	fmt.Fprintf(b, "memlink_abandoned_completions_total{result=\"ok\"} %d\n", abandoned.Completions-abandoned.FailedCompletions)
+	fmt.Fprintf(b, "memlink_abandoned_completions_total{result=\"error\"} %d\n", abandoned.FailedCompletions)
+}
+
+func writeSLO(b *strings.Builder, slo map[OperationClass]SLOStats) {
+	if len(slo) == 0 {
+		return
+	}
+
+	classes := make([]OperationClass, 0, len(slo))
+	for class := range slo {
+		classes = append(classes, class)
+	}
+	slices.Sort(classes)
+
+	b.WriteString("# HELP memlink_slo_target_seconds Latency target of the operation class.\n")
+	b.WriteString("# TYPE memlink_slo_target_seconds gauge\n")
+	for _, class := range classes {
+		fmt.Fprintf(b, "memlink_slo_target_seconds{class=%q} %g\n", class, slo[class].Target.Seconds())
+	}
+
+	b.WriteString("# HELP memlink_slo_requests_total Requests of the operation class.\n")
+	b.WriteString("# TYPE memlink_slo_requests_total counter\n")
+	for _, class := range classes {
+		fmt.Fprintf(b, "memlink_slo_requests_total{class=%q} %d\n", class, slo[class].Requests)
+	}
+
+	b.WriteString("# HELP memlink_slo_budget_burned_total Requests of the operation class which were slower than the target, or failed.\n")
+	b.WriteString("# TYPE memlink_slo_budget_burned_total counter\n")
+	for _, class := range classes {
+		fmt.Fprintf(b, "memlink_slo_budget_burned_total{class=%q,reason=\"slow\"} %d\n", class, slo[class].Slow)
+		fmt.Fprintf(b, "memlink_slo_budget_burned_total{class=%q,reason=\"failed\"} %d\n", class, slo[class].Failed)
+	}
+}
+
+func writeNamespaces(b *strings.Builder, namespaces map[string]NamespaceStats) {
+	if len(namespaces) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(namespaces))
+	for name := range namespaces {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	b.WriteString("# HELP memlink_namespace_keys_total Keys requested through the views of the namespace.\n")
+	b.WriteString("# TYPE memlink_namespace_keys_total counter\n")
+	for _, name := range names {
+		fmt.Fprintf(b, "memlink_namespace_keys_total{namespace=%q} %d\n", name, namespaces[name].Keys)
+	}
+
+	b.WriteString("# HELP memlink_namespace_rate_limited_total Keys refused by the rate limit of the namespace.\n")
+	b.WriteString("# TYPE memlink_namespace_rate_limited_total counter\n")
+	for _, name := range names {
+		fmt.Fprintf(b, "memlink_namespace_rate_limited_total{namespace=%q} %d\n", name, namespaces[name].RateLimited)
+	}
+}
+
+func writeTenants(b *strings.Builder, tenants map[string]TenantStats) {
+	if len(tenants) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(tenants))
+	for name := range tenants {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	b.WriteString("# HELP memlink_tenant_keys_total Keys requested for the tenant.\n")
+	b.WriteString("# TYPE memlink_tenant_keys_total counter\n")
+	for _, name := range names {
+		fmt.Fprintf(b, "memlink_tenant_keys_total{tenant=%q} %d\n", name, tenants[name].Keys)
+	}
+
+	b.WriteString("# HELP memlink_tenant_rate_limited_total Keys refused by the quota of the tenant.\n")
+	b.WriteString("# TYPE memlink_tenant_rate_limited_total counter\n")
+	for _, name := range names {
+		fmt.Fprintf(b, "memlink_tenant_rate_limited_total{tenant=%q} %d\n", name, tenants[name].RateLimited)
+	}
+}
+
+func writeInvalidations(b *strings.Builder, invalidations *InvalidationStats) {
+	if invalidations == nil {
+		return
+	}
+
+	b.WriteString("# HELP memlink_invalidation_events_total Invalidation events by outcome.\n")
+	b.WriteString("# TYPE memlink_invalidation_events_total counter\n")
+	fmt.Fprintf(b, "memlink_invalidation_events_total{outcome=\"published\"} %d\n", invalidations.Published)
+	fmt.Fprintf(b, "memlink_invalidation_events_total{outcome=\"dropped\"} %d\n", invalidations.Dropped)
+	fmt.Fprintf(b, "memlink_invalidation_events_total{outcome=\"error\"} %d\n", invalidations.Errors)
+}
+
+func writeAudit(b *strings.Builder, audit *AuditStats) {
+	if audit == nil {
+		return
+	}
+
+	b.WriteString("# HELP memlink_audit_records_total Audit records by outcome.\n")
+	b.WriteString("# TYPE memlink_audit_records_total counter\n")
+	fmt.Fprintf(b, "memlink_audit_records_total{outcome=\"written\"} %d\n", audit.Written)
+	fmt.Fprintf(b, "memlink_audit_records_total{outcome=\"dropped\"} %d\n", audit.Dropped)
+	fmt.Fprintf(b, "memlink_audit_records_total{outcome=\"error\"} %d\n", audit.Errors)
+}
+
+func writeSharedLoads(b *strings.Builder, shared uint64) {
+	if shared == 0 {
+		return
+	}
+
+	b.WriteString("# HELP memlink_getorcompute_shared_loads_total GetOrCompute calls served by the load of another call.\n")
+	b.WriteString("# TYPE memlink_getorcompute_shared_loads_total counter\n")
+	fmt.Fprintf(b, "memlink_getorcompute_shared_loads_total %d\n", shared)
+}
+
+func writeCollapsedKeys(b *strings.Builder, collapsed uint64) {
+	if collapsed == 0 {
+		return
+	}
+
+	b.WriteString("# HELP memlink_getmulti_collapsed_keys_total Keys requested by GetMulti served by the fetch of another call.\n")
+	b.WriteString("# TYPE memlink_getmulti_collapsed_keys_total counter\n")
+	fmt.Fprintf(b, "memlink_getmulti_collapsed_keys_total %d\n", collapsed)
+}
+
+// latencyQuantiles are the quantiles of the latency histograms exported to Prometheus.
+var latencyQuantiles = []float64{0.5, 0.9, 0.99, 0.999}
+
+func writeLatencies(b *strings.Builder, latencies []LatencyHistogram) {
+	if len(latencies) == 0 {
+		return
+	}
+
+	b.WriteString("# HELP memlink_request_latency_seconds Latency of the requests by operation and backend.\n")
+	b.WriteString("# TYPE memlink_request_latency_seconds summary\n")
+	for _, h := range latencies {
+		labels := fmt.Sprintf("operation=%q,backend=%q", h.Operation, h.Backend)
+		for _, q := range latencyQuantiles {
+			fmt.Fprintf(b, "memlink_request_latency_seconds{%s,quantile=\"%g\"} %g\n", labels, q, h.Percentile(q*100).Seconds())
+		}
+		fmt.Fprintf(b, "memlink_request_latency_seconds_sum{%s} %g\n", labels, h.Sum.Seconds())
+		fmt.Fprintf(b, "memlink_request_latency_seconds_count{%s} %d\n", labels, h.Count)
+	}
+}
+
+func writeOperations(b *strings.Builder, operations []OperationStats) {
+	if len(operations) == 0 {
+		return
+	}
+
+	b.WriteString("# HELP memlink_operation_requests_total Requests of the operation.\n")
+	b.WriteString("# TYPE memlink_operation_requests_total counter\n")
+	for _, op := range operations {
+		fmt.Fprintf(b, "memlink_operation_requests_total{%s} %d\n", operationLabels(op), op.Requests)
+	}
+
+	b.WriteString("# HELP memlink_operation_failed_total Requests of the operation which failed.\n")
+	b.WriteString("# TYPE memlink_operation_failed_total counter\n")
+	for _, op := range operations {
+		fmt.Fprintf(b, "memlink_operation_failed_total{%s} %d\n", operationLabels(op), op.Failed)
+	}
+
+	b.WriteString("# HELP memlink_operation_latency_seconds_total Total latency of the requests of the operation.\n")
+	b.WriteString("# TYPE memlink_operation_latency_seconds_total counter\n")
+	for _, op := range operations {
+		fmt.Fprintf(b, "memlink_operation_latency_seconds_total{%s} %g\n", operationLabels(op), op.Latency.Seconds())
+	}
+}
+
+func operationLabels(op OperationStats) string {
+	labels := fmt.Sprintf("operation=%q", op.Operation)
+	for _, label := range op.Labels {
+		labels += fmt.Sprintf(",%s=%q", label.Name, label.Value)
+	}
+	return labels
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueSizeStats(t *testing.T) {
+	mc, _ := newTestClient(t, WithValueSizeStats(1, PrefixNamespace(":")))
+	ctx := context.Background()
+
+	_, err := mc.SetMulti(ctx, []Item{
+		{Key: "user:1", Value: make([]byte, 10)},
+		{Key: "user:2", Value: make([]byte, 300)},
+		{Key: "blob:1", Value: make([]byte, 100_000)},
+	})
+	require.NoError(t, err)
+	require.NoError(t, mc.AppendValue(ctx, "user:3", make([]byte, 64), 0))
+
+	stats := mc.Stats()
+	require.Len(t, stats.ValueSizes, 2)
+
+	users := stats.ValueSizes["user"]
+	assert.Equal(t, uint64(3), users.Count)
+	assert.Equal(t, uint64(374), users.Sum)
+	assert.Equal(t, []uint64{2, 0, 1, 0, 0, 0, 0, 0, 0}, users.Counts)
+
+	blobs := stats.ValueSizes["blob"]
+	assert.Equal(t, []uint64{0, 0, 0, 0, 0, 0, 1, 0, 0}, blobs.Counts)
+
+	var b bytes.Buffer
+	require.NoError(t, stats.WritePrometheus(&b))
+	assert.Contains(t, b.String(), "# TYPE memlink_value_size_bytes histogram\n")
+	assert.Contains(t, b.String(), `memlink_value_size_bytes_bucket{namespace="user",le="256"} 2`+"\n")
+	assert.Contains(t, b.String(), `memlink_value_size_bytes_bucket{namespace="user",le="+Inf"} 3`+"\n")
+	assert.Contains(t, b.String(), `memlink_value_size_bytes_count{namespace="blob"} 1`+"\n")
+}
+
+func TestValueSizeStatsDisabled(t *testing.T) {
+	mc, _ := newTestClient(t)
+
+	require.NoError(t, mc.Add(context.Background(), Item{Key: "key", Value: []byte("value")}))
+	stats := mc.Stats()
+	assert.Zero(t, stats.ValueSizeSampleRate)
+	assert.Empty(t, stats.ValueSizes)
+}
+
+func TestValueSizeStatsSampling(t *testing.T) {
+	recorder := &valueSizeRecorder{sampleRate: 0, histograms: make(map[string]*SizeHistogram)}
+	for i := 0; i < 100; i++ {
+		recorder.record("key", 10)
+	}
+	assert.Empty(t, recorder.snapshot())
+}
+
+func TestUnclaimedResponsesStats(t *testing.T) {
+	mc, srv := newTestClient(t)
+
+	stats := mc.Stats()
+	assert.Equal(t, map[string]uint64{srv.Addr().String(): 0}, stats.UnclaimedResponses)
+
+	var b bytes.Buffer
+	require.NoError(t, stats.WritePrometheus(&b))
+	assert.Contains(t, b.String(), "# TYPE memlink_unclaimed_responses_total counter\n")
+	assert.Contains(t, b.String(), fmt.Sprintf("memlink_unclaimed_responses_total{backend=%q} 0\n", srv.Addr().String()))
+}
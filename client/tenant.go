@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// maxTrackedTenants bounds the number of tenants with their own counters and quota, the tenants beyond share the ones
+// of otherTenants.
+const maxTrackedTenants = 1000
+
+// otherTenants labels the tenants beyond maxTrackedTenants.
+const otherTenants = "_other"
+
+// TenantExtractor returns the tenant a request is made for, ok being false for requests made for no tenant.
+type TenantExtractor func(ctx context.Context) (tenant string, ok bool)
+
+// WithTenantExtractor attributes every request to the tenant extracted from its context, counting its keys in
+// ClientStats.Tenants and applying the quota set with WithTenantQuota. Admin requests, e.g. ServerStats, aren't
+// attributed.
+func WithTenantExtractor(extractor TenantExtractor) ClientOption {
+	return func(c *memcachedClient) {
+		c.ensureTenants().extractor = extractor
+	}
+}
+
+// WithTenantQuota limits every tenant to perSecond keys per second, allowing bursts of burst keys. The requests
+// beyond fail with ErrRateLimited without contacting memcached. It has no effect without WithTenantExtractor.
+func WithTenantQuota(perSecond float64, burst int) ClientOption {
+	return func(c *memcachedClient) {
+		tenants := c.ensureTenants()
+		tenants.perSecond = perSecond
+		tenants.burst = burst
+	}
+}
+
+func (c *memcachedClient) ensureTenants() *tenantTracker {
+	if c.tenants == nil {
+		c.tenants = &tenantTracker{tenants: make(map[string]*tenant)}
+	}
+	return c.tenants
+}
+
+// TenantStats are the counters of the requests made for a tenant.
+type TenantStats struct {
+	// Keys is the number of keys requested, a bulk request counting for each of its keys.
+	Keys uint64
+	// RateLimited is the number of keys refused by the quota of the tenant.
+	RateLimited uint64
+}
+
+type tenantTracker struct {
+	extractor TenantExtractor
+	perSecond float64
+	burst     int
+
+	mu      sync.Mutex
+	tenants map[string]*tenant // protected by mu
+}
+
+type tenant struct {
+	counters namespaceCounters
+	limiter  *rateLimiter
+}
+
+// admit counts the keys of the request encoded by e for the tenant of ctx, and checks them against its quota.
+func (t *tenantTracker) admit(ctx context.Context, e codec.LinkEncoder) error {
+	if t.extractor == nil {
+		return nil
+	}
+	name, ok := t.extractor(ctx)
+	if !ok {
+		return nil
+	}
+
+	keys := requestKeys(e)
+	tenant := t.tenant(name)
+	tenant.counters.keys.Add(uint64(keys))
+	if tenant.limiter != nil && !tenant.limiter.allow(keys) {
+		tenant.counters.rateLimited.Add(uint64(keys))
+		return fmt.Errorf("tenant=%s keys=%d: %w", name, keys, ErrRateLimited)
+	}
+	return nil
+}
+
+func (t *tenantTracker) tenant(name string) *tenant {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if tn, ok := t.tenants[name]; ok {
+		return tn
+	}
+	if len(t.tenants) >= maxTrackedTenants {
+		name = otherTenants
+		if tn, ok := t.tenants[name]; ok {
+			return tn
+		}
+	}
+
+	tn := &tenant{}
+	if t.perSecond > 0 {
+		tn.limiter = newRateLimiter(t.perSecond, t.burst)
+	}
+	t.tenants[name] = tn
+	return tn
+}
+
+func (t *tenantTracker) snapshot() map[string]TenantStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := make(map[string]TenantStats, len(t.tenants))
+	for name, tn := range t.tenants {
+		stats[name] = TenantStats{
+			Keys:        tn.counters.keys.Load(),
+			RateLimited: tn.counters.rateLimited.Load(),
+		}
+	}
+	return stats
+}
+
+// requestKeys returns the number of keys a request is made for.
+func requestKeys(e codec.LinkEncoder) int {
+	switch e := e.(type) {
+	case *memcache.BulkEncoder[*memcache.MetaGetEncoder]:
+		return len(e.Encoders)
+	case *memcache.BulkEncoder[*memcache.MetaSetEncoder]:
+		return len(e.Encoders)
+	case *memcache.BulkEncoder[*memcache.MetaDeleteEncoder]:
+		return len(e.Encoders)
+	default:
+		return 1
+	}
+}
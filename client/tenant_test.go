@@ -0,0 +1,68 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tenantKey struct{}
+
+func tenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
+
+func TestTenantQuota(t *testing.T) {
+	mc, srv := newTestClient(t, WithTenantExtractor(tenantFromContext), WithTenantQuota(0.001, 3))
+	acme := context.WithValue(context.Background(), tenantKey{}, "acme")
+	globex := context.WithValue(context.Background(), tenantKey{}, "globex")
+
+	_, err := mc.GetMulti(acme, []string{"a", "b"})
+	require.NoError(t, err)
+	require.NoError(t, mc.Add(acme, Item{Key: "c", Value: []byte("v")}))
+
+	gets := srv.CommandCount("mg")
+	_, err = mc.GetWithTTL(acme, "a")
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, gets, srv.CommandCount("mg"))
+
+	// the quota is per tenant, and requests made for no tenant aren't limited.
+	_, err = mc.GetWithTTL(globex, "a")
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		_, err = mc.GetWithTTL(context.Background(), "a")
+		require.NoError(t, err)
+	}
+
+	stats := mc.Stats()
+	assert.Equal(t, map[string]TenantStats{
+		"acme":   {Keys: 4, RateLimited: 1},
+		"globex": {Keys: 1},
+	}, stats.Tenants)
+
+	var b bytes.Buffer
+	require.NoError(t, stats.WritePrometheus(&b))
+	assert.Contains(t, b.String(), `memlink_tenant_keys_total{tenant="acme"} 4`+"\n")
+	assert.Contains(t, b.String(), `memlink_tenant_rate_limited_total{tenant="globex"} 0`+"\n")
+}
+
+func TestTenantCardinality(t *testing.T) {
+	tracker := &tenantTracker{tenants: make(map[string]*tenant)}
+	for i := 0; i < maxTrackedTenants+10; i++ {
+		tracker.tenant(fmt.Sprint(i))
+	}
+
+	stats := tracker.snapshot()
+	assert.Len(t, stats, maxTrackedTenants+1)
+	assert.Contains(t, stats, otherTenants)
+}
+
+func TestTenantsDisabled(t *testing.T) {
+	mc, _ := newTestClient(t, WithTenantQuota(1, 1))
+	assert.Nil(t, mc.Stats().Tenants)
+}
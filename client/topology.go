@@ -0,0 +1,23 @@
+package client
+
+import (
+	netpkg "github.com/stripe/memlink/internal/net"
+)
+
+// Topology is a snapshot of the backends of the client's pool, in placement order, with their weight and the health
+// of their connections. Its Diff method compares it to a previous snapshot.
+type Topology = netpkg.Topology
+
+// TopologyBackend is the state of a backend in a Topology.
+type TopologyBackend = netpkg.TopologyBackend
+
+// TopologyDiff describes the backends added, removed and changed between two topologies.
+type TopologyDiff = netpkg.TopologyDiff
+
+// TopologyChange is a backend whose state differs between two topologies.
+type TopologyChange = netpkg.TopologyChange
+
+// Topology returns a snapshot of the backends of the pool and of their connections.
+func (c *memcachedClient) Topology() Topology {
+	return c.pool.Topology()
+}
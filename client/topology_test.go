@@ -0,0 +1,15 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopology(t *testing.T) {
+	mc, srv := newTestClient(t)
+
+	topology := mc.WithNamespace("billing").Topology()
+	assert.Equal(t, []TopologyBackend{{Addr: srv.Addr().String(), Weight: 1, NumConns: 1, HealthyConns: 1}}, topology.Backends)
+	assert.True(t, topology.Diff(mc.Topology()).Empty())
+}
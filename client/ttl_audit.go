@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// maxAuditOffenders caps the number of example keys kept per namespace by AuditTTLs.
+const maxAuditOffenders = 10
+
+// TTLAudit summarizes the TTLs of the items of a namespace.
+type TTLAudit struct {
+	Items    int
+	NoTTL    int
+	AboveMax int
+	// Offenders holds a few of the keys without a TTL or with a TTL above the bound, to help find the writers.
+	Offenders []string
+}
+
+// AuditTTLs walks every item of every backend with lru_crawler metadump and reports, per namespace, the items which
+// never expire or expire further than maxTTL from now. A non-positive maxTTL only reports the items without a TTL.
+func AuditTTLs(ctx context.Context, mc MemcachedClient, namespace NamespaceFn, maxTTL time.Duration) (map[string]TTLAudit, error) {
+	now := time.Now()
+	audits := make(map[string]TTLAudit)
+
+	err := mc.ScanItems(ctx, func(_ string, entry memcache.MetadumpEntry) error {
+		ns := ""
+		if namespace != nil {
+			ns = namespace(entry.Key)
+		}
+
+		audit := audits[ns]
+		audit.Items++
+
+		offending := false
+		switch {
+		case entry.Expiry < 0:
+			audit.NoTTL++
+			offending = true
+		case maxTTL > 0 && time.Unix(entry.Expiry, 0).Sub(now) > maxTTL:
+			audit.AboveMax++
+			offending = true
+		}
+
+		if offending && len(audit.Offenders) < maxAuditOffenders {
+			audit.Offenders = append(audit.Offenders, entry.Key)
+		}
+		audits[ns] = audit
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditTTLs(t *testing.T) {
+	mc, srv := newTestClient(t)
+	srv.Set("user:1", []byte("a"), 0)
+	srv.Set("user:2", []byte("a"), 60)
+	srv.Set("user:3", []byte("a"), 7200)
+	srv.Set("session:1", []byte("a"), 60)
+
+	audits, err := AuditTTLs(context.Background(), mc, PrefixNamespace(":"), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]TTLAudit{
+		"user":    {Items: 3, NoTTL: 1, AboveMax: 1, Offenders: audits["user"].Offenders},
+		"session": {Items: 1},
+	}, audits)
+	assert.ElementsMatch(t, []string{"user:1", "user:3"}, audits["user"].Offenders)
+
+	audits, err = AuditTTLs(context.Background(), mc, nil, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 4, audits[""].Items)
+	assert.Equal(t, 1, audits[""].NoTTL)
+	assert.Zero(t, audits[""].AboveMax)
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"math"
+	"time"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// TTLPolicy returns the TTL, in seconds, to store key with given the TTL proposed by the caller. 0 means the item
+// never expires.
+type TTLPolicy func(key string, proposed int32) int32
+
+// WithTTLPolicy applies policy to the TTL of every item written by the client, including the TTL of the items created
+// by AppendValue and PrependValue on a miss, and the TTLs set by Touch, TouchUntil and GetAndTouch. The policy is given
+// the TTL remaining until the expiry of the items written with an absolute one, which they keep unless the policy
+// returns another TTL.
+func WithTTLPolicy(policy TTLPolicy) ClientOption {
+	return func(c *memcachedClient) {
+		c.ttlPolicy = policy
+	}
+}
+
+// WithAbsoluteTTLs sends the TTLs as is, for the callers which set absolute unix timestamps themselves. By default,
+// the TTLs longer than memcache.MaxRelativeTTL, 30 days, which memcached reads as absolute unix timestamps, are sent as
+// the unix timestamp they're relative to, so that they don't expire the items immediately. It applies to the requests
+// built by the client as well as to the ones passed to MetaSet, MetaGet and the other raw methods.
+func WithAbsoluteTTLs() ClientOption {
+	return func(c *memcachedClient) {
+		c.absoluteTTLs = true
+	}
+}
+
+// ClampTTL is a TTLPolicy keeping TTLs within [minTTL, maxTTL]. Items without an expiry get maxTTL.
+func ClampTTL(minTTL, maxTTL int32) TTLPolicy {
+	return func(_ string, proposed int32) int32 {
+		if proposed == 0 {
+			return maxTTL
+		}
+		return min(max(proposed, minTTL), maxTTL)
+	}
+}
+
+// applyTTLPolicy rewrites the TTL of the item the encoder is about to write.
+func (c *memcachedClient) applyTTLPolicy(encoder *memcache.MetaSetEncoder) {
+	applyTTL(c.ttlPolicy, encoder)
+}
+
+// applyTTL rewrites the TTL of the item the encoder is about to write with policy, if any.
+func applyTTL(policy TTLPolicy, encoder *memcache.MetaSetEncoder) {
+	if policy == nil {
+		return
+	}
+
+	switch encoder.Mode {
+	case memcache.Append, memcache.Prepend:
+		// the TTL of an existing item is left untouched, only the one of an auto-vivified item applies.
+		if encoder.BlockTTL >= 0 {
+			encoder.BlockTTL = policy(setKey(encoder), encoder.BlockTTL)
+		}
+	default:
+		encoder.TTL, encoder.ExpireAt = policyTTL(policy, setKey(encoder), encoder.TTL, encoder.ExpireAt)
+	}
+}
+
+// policyTTL applies policy to the TTL of an item expiring in ttl seconds, or at expireAt if it's set. Given the TTL
+// remaining until expireAt, a policy returning another TTL overrides expireAt.
+func policyTTL(policy TTLPolicy, key string, ttl int32, expireAt time.Time) (int32, time.Time) {
+	if expireAt.IsZero() {
+		// memcached treats a missing TTL as no expiry.
+		return policy(key, max(ttl, 0)), expireAt
+	}
+	remaining := remainingTTL(expireAt)
+	if proposed := policy(key, remaining); proposed != remaining {
+		return proposed, time.Time{}
+	}
+	return ttl, expireAt
+}
+
+// remainingTTL returns the seconds until expireAt, rounded up, at least 1 since 0 would mean no expiry.
+func remainingTTL(expireAt time.Time) int32 {
+	remaining := (time.Until(expireAt) + time.Second - 1) / time.Second
+	return int32(min(max(remaining, 1), math.MaxInt32))
+}
+
+// setKey returns the key written by encoder.
+func setKey(encoder *memcache.MetaSetEncoder) string {
+	if !encoder.ValidatedKey.IsZero() {
+		return encoder.ValidatedKey.String()
+	}
+	if encoder.BinaryKey != nil {
+		return string(encoder.BinaryKey)
+	}
+	return encoder.Key
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+func TestClampTTL(t *testing.T) {
+	policy := ClampTTL(10, 100)
+
+	tests := []struct {
+		name     string
+		proposed int32
+		expected int32
+	}{
+		{name: "within bounds", proposed: 50, expected: 50},
+		{name: "below min", proposed: 1, expected: 10},
+		{name: "above max", proposed: 1000, expected: 100},
+		{name: "no expiry", proposed: 0, expected: 100},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, policy("key", test.proposed))
+		})
+	}
+}
+
+func TestTTLPolicyAppliedOnWrites(t *testing.T) {
+	policy := func(key string, proposed int32) int32 {
+		if strings.HasPrefix(key, "session:") {
+			return 500
+		}
+		return proposed
+	}
+	mc, _ := newTestClient(t, WithTTLPolicy(policy))
+	ctx := context.Background()
+
+	_, err := mc.SetMulti(ctx, []Item{{Key: "session:a", Value: []byte("a")}, {Key: "other", Value: []byte("b"), TTL: 50}})
+	require.NoError(t, err)
+	require.NoError(t, mc.Add(ctx, Item{Key: "session:b", Value: []byte("b")}))
+	require.NoError(t, mc.AppendValue(ctx, "session:c", []byte("c"), 0))
+
+	for key, expected := range map[string]int32{"session:a": 500, "other": 50, "session:b": 500, "session:c": 500} {
+		result, err := mc.GetWithTTL(ctx, key)
+		require.NoError(t, err)
+		assert.True(t, result.Found, key)
+		assert.InDelta(t, expected, result.RemainingTTLSeconds, 1, key)
+	}
+}
+
+func TestLongTTLsDontExpireImmediately(t *testing.T) {
+	mc, _ := newTestClient(t)
+	ctx := context.Background()
+
+	ttl := 2 * memcache.MaxRelativeTTL
+	require.NoError(t, mc.Set(ctx, "key", []byte("value"), ttl))
+
+	result, err := mc.GetWithTTL(ctx, "key")
+	require.NoError(t, err)
+	require.True(t, result.Found)
+	assert.InDelta(t, ttl, result.RemainingTTLSeconds, 2)
+
+	// so do the ones of the raw methods.
+	encoder := memcache.CreateMetaSetEncoder()
+	encoder.Reset()
+	encoder.Key = "raw"
+	encoder.Value = []byte("value")
+	encoder.TTL = ttl
+	require.NoError(t, mc.MetaSet(ctx, encoder, memcache.CreateMetaSetDecoder()))
+	result, err = mc.GetWithTTL(ctx, "raw")
+	require.NoError(t, err)
+	require.True(t, result.Found)
+	assert.InDelta(t, ttl, result.RemainingTTLSeconds, 2)
+}
+
+func TestAbsoluteTTLs(t *testing.T) {
+	mc, _ := newTestClient(t, WithAbsoluteTTLs())
+	ctx := context.Background()
+
+	// with WithAbsoluteTTLs, the long TTLs are absolute unix timestamps.
+	require.NoError(t, mc.Set(ctx, "key", []byte("value"), int32(time.Now().Add(time.Hour).Unix())))
+	result, err := mc.GetWithTTL(ctx, "key")
+	require.NoError(t, err)
+	require.True(t, result.Found)
+	assert.InDelta(t, 3600, result.RemainingTTLSeconds, 2)
+}
+
+func TestExpireAt(t *testing.T) {
+	mc, _ := newTestClient(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	statuses, err := mc.SetMulti(ctx, []Item{
+		{Key: "hour", Value: []byte("a"), TTL: 60, ExpireAt: now.Add(time.Hour)},
+		{Key: "months", Value: []byte("b"), ExpireAt: now.Add(90 * 24 * time.Hour)},
+		{Key: "past", Value: []byte("c"), ExpireAt: now.Add(-time.Minute)},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]memcache.MetadataStatus{"hour": memcache.Stored, "months": memcache.Stored, "past": memcache.Stored}, statuses)
+	require.NoError(t, mc.Add(ctx, Item{Key: "added", Value: []byte("d"), ExpireAt: now.Add(time.Hour)}))
+
+	for key, expected := range map[string]int32{"hour": 3600, "months": 90 * 24 * 3600, "added": 3600} {
+		result, err := mc.GetWithTTL(ctx, key)
+		require.NoError(t, err)
+		require.True(t, result.Found, key)
+		assert.InDelta(t, expected, result.RemainingTTLSeconds, 2, key)
+	}
+	result, err := mc.GetWithTTL(ctx, "past")
+	require.NoError(t, err)
+	assert.False(t, result.Found)
+
+	require.NoError(t, mc.TouchUntil(ctx, "hour", now.Add(60*24*time.Hour)))
+	result, err = mc.GetWithTTL(ctx, "hour")
+	require.NoError(t, err)
+	assert.InDelta(t, 60*24*3600, result.RemainingTTLSeconds, 2)
+
+	require.NoError(t, mc.TouchUntil(ctx, "hour", time.Time{}))
+	result, err = mc.GetWithTTL(ctx, "hour")
+	require.NoError(t, err)
+	assert.Equal(t, int32(-1), result.RemainingTTLSeconds)
+
+	assert.ErrorIs(t, mc.TouchUntil(ctx, "missing", now.Add(time.Hour)), ErrNotFound)
+}
+
+func TestTTLPolicyAppliedToExpireAt(t *testing.T) {
+	mc, _ := newTestClient(t, WithTTLPolicy(ClampTTL(10, 1800)))
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, mc.Add(ctx, Item{Key: "kept", Value: []byte("a"), ExpireAt: now.Add(10 * time.Minute)}))
+	require.NoError(t, mc.Add(ctx, Item{Key: "clamped", Value: []byte("b"), ExpireAt: now.Add(time.Hour)}))
+	require.NoError(t, mc.Set(ctx, "touched", []byte("c"), 60))
+	require.NoError(t, mc.TouchUntil(ctx, "touched", now.Add(time.Hour)))
+
+	for key, expected := range map[string]int32{"kept": 600, "clamped": 1800, "touched": 1800} {
+		result, err := mc.GetWithTTL(ctx, key)
+		require.NoError(t, err)
+		require.True(t, result.Found, key)
+		assert.InDelta(t, expected, result.RemainingTTLSeconds, 2, key)
+	}
+}
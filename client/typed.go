@@ -0,0 +1,148 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// FormatJSON is the format of the values serialized by JSONMarshaler, stored in the memcache.CodecFlag field.
+const FormatJSON uint64 = 1
+
+// Marshaler serializes the values stored by a Cache.
+type Marshaler interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+	// Format identifies the serialization. It's stored in the memcache.CodecFlag field of the client flags of the
+	// items, so that a Cache doesn't decode the values written in another format, and must fit it.
+	Format() uint64
+}
+
+// JSONMarshaler serializes values with encoding/json.
+type JSONMarshaler struct{}
+
+var _ Marshaler = JSONMarshaler{}
+
+func (JSONMarshaler) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONMarshaler) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (JSONMarshaler) Format() uint64 {
+	return FormatJSON
+}
+
+// itemFetcher is implemented by the clients of the package, which can fetch several items along with their client
+// flags.
+type itemFetcher interface {
+	fetchItems(ctx context.Context, keys []string) (map[string]Item, error)
+}
+
+// fetchItems fetches keys along with their client flags with mc, one at a time unless it's a client of the package.
+func fetchItems(ctx context.Context, mc MemcachedClient, keys []string) (map[string]Item, error) {
+	if fetcher, ok := mc.(itemFetcher); ok {
+		return fetcher.fetchItems(ctx, keys)
+	}
+
+	items := make(map[string]Item, len(keys))
+	for _, key := range keys {
+		result, err := mc.GetWithTTL(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if result.Found {
+			items[key] = Item{Key: key, Value: result.Value, ClientFlags: result.ClientFlags}
+		}
+	}
+	return items, nil
+}
+
+// Cache stores values of type T in memcached through a client, serialized by a Marshaler whose format is recorded in
+// the memcache.CodecFlag field of the client flags of the items, leaving their other bits to the client's features. It goes through the helpers of the client, so namespaces, TTL policies and soft TTLs
+// apply to it like to the client.
+type Cache[T any] struct {
+	mc        MemcachedClient
+	marshaler Marshaler
+}
+
+// NewCache returns a Cache of the values of type T stored through mc and serialized by marshaler.
+func NewCache[T any](mc MemcachedClient, marshaler Marshaler) *Cache[T] {
+	return &Cache[T]{mc: mc, marshaler: marshaler}
+}
+
+// Get returns the value stored under key. A miss fails with ErrNotFound, an item written in another format with
+// ErrFormatMismatch.
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	var value T
+	data, item, err := c.mc.Get(ctx, key)
+	if err != nil {
+		return value, err
+	}
+	return c.decode(key, data, item.ClientFlags)
+}
+
+// Set serializes value and stores it under key, whether it exists or not, with a TTL of ttl seconds, 0 meaning it never
+// expires.
+func (c *Cache[T]) Set(ctx context.Context, key string, value T, ttl int32) error {
+	data, err := c.marshaler.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("Set operation failed: key=%q: %w", key, err)
+	}
+	flags, err := memcache.CodecFlag.Set(0, c.marshaler.Format())
+	if err != nil {
+		return fmt.Errorf("Set operation failed: key=%q: %w", key, err)
+	}
+
+	statuses, err := c.mc.SetMulti(ctx, []Item{{Key: key, Value: data, TTL: ttl, ClientFlags: flags}})
+	if err != nil {
+		return fmt.Errorf("Set operation failed: %w", err)
+	}
+	if status := statuses[key]; !status.IsStored() {
+		return fmt.Errorf("Set operation failed: key=%q status=%s: %w", key, status, ErrNotStored)
+	}
+	return nil
+}
+
+// GetMulti returns the values of the keys which were found. It fails with ErrFormatMismatch if one of them was written
+// in another format.
+func (c *Cache[T]) GetMulti(ctx context.Context, keys []string) (map[string]T, error) {
+	if len(keys) == 0 {
+		return map[string]T{}, nil
+	}
+	for _, key := range keys {
+		if err := memcache.ValidateKey(key); err != nil {
+			return nil, fmt.Errorf("GetMulti operation failed: %w", err)
+		}
+	}
+
+	items, err := fetchItems(ctx, c.mc, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]T, len(items))
+	for key, item := range items {
+		value, err := c.decode(key, item.Value, item.ClientFlags)
+		if err != nil {
+			return nil, fmt.Errorf("GetMulti operation failed: %w", err)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+func (c *Cache[T]) decode(key string, data []byte, flags uint64) (T, error) {
+	var value T
+	if format := memcache.CodecFlag.Get(flags); format != c.marshaler.Format() {
+		return value, fmt.Errorf("key=%q format=%d: %w", key, format, ErrFormatMismatch)
+	}
+	if err := c.marshaler.Unmarshal(data, &value); err != nil {
+		return value, fmt.Errorf("key=%q: %w", key, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+type invoice struct {
+	ID     string `json:"id"`
+	Amount int    `json:"amount"`
+}
+
+func TestCache(t *testing.T) {
+	mc, srv := newTestClient(t)
+	invoices := NewCache[invoice](mc, JSONMarshaler{})
+	ctx := context.Background()
+
+	_, err := invoices.Get(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, invoices.Set(ctx, "a", invoice{ID: "a", Amount: 10}, 60))
+	require.NoError(t, invoices.Set(ctx, "b", invoice{ID: "b", Amount: 20}, 0))
+	stored, ok := srv.Get("a")
+	require.True(t, ok)
+	assert.JSONEq(t, `{"id":"a","amount":10}`, string(stored))
+
+	value, err := invoices.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, invoice{ID: "a", Amount: 10}, value)
+	// the format is recorded in the codec field of the client flags.
+	result, err := mc.GetWithTTL(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSON, memcache.CodecFlag.Get(result.ClientFlags))
+	assert.Zero(t, memcache.CodecFlag.Clear(result.ClientFlags))
+
+	values, err := invoices.GetMulti(ctx, []string{"a", "b", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]invoice{"a": {ID: "a", Amount: 10}, "b": {ID: "b", Amount: 20}}, values)
+	values, err = invoices.GetMulti(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, values)
+
+	// the values written in another format aren't decoded.
+	require.NoError(t, mc.Set(ctx, "raw", []byte(`{"id":"raw"}`), 0))
+	_, err = invoices.Get(ctx, "raw")
+	assert.ErrorIs(t, err, ErrFormatMismatch)
+	_, err = invoices.GetMulti(ctx, []string{"a", "raw"})
+	assert.ErrorIs(t, err, ErrFormatMismatch)
+
+	// the bits outside the codec field don't matter.
+	flags, err := memcache.CodecFlag.Set(1<<24|3, FormatJSON)
+	require.NoError(t, err)
+	require.NoError(t, mc.Add(ctx, Item{Key: "flagged", Value: []byte(`{"id":"flagged"}`), ClientFlags: flags}))
+	value, err = invoices.Get(ctx, "flagged")
+	require.NoError(t, err)
+	assert.Equal(t, invoice{ID: "flagged"}, value)
+}
+
+type wideFormatMarshaler struct{ JSONMarshaler }
+
+func (wideFormatMarshaler) Format() uint64 {
+	return 1 << 8
+}
+
+func TestCache_FormatOverflow(t *testing.T) {
+	mc, _ := newTestClient(t)
+	err := NewCache[invoice](mc, wideFormatMarshaler{}).Set(context.Background(), "a", invoice{ID: "a"}, 0)
+	assert.ErrorIs(t, err, memcache.ErrFlagValueOverflow)
+}
+
+func TestNamespacedCache(t *testing.T) {
+	mc, srv := newTestClient(t)
+	billing := NewCache[invoice](mc.WithNamespace("billing"), JSONMarshaler{})
+	ctx := context.Background()
+
+	require.NoError(t, billing.Set(ctx, "a", invoice{ID: "a", Amount: 10}, 0))
+	_, ok := srv.Get("billing:a")
+	assert.True(t, ok)
+
+	values, err := billing.GetMulti(ctx, []string{"a"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]invoice{"a": {ID: "a", Amount: 10}}, values)
+	_, err = NewCache[invoice](mc, JSONMarshaler{}).Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
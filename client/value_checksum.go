@@ -0,0 +1,100 @@
+package client
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"sync/atomic"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// ChecksummedValueFlag is the client flag field, of a single bit, marking the values followed by their checksum, see
+// WithValueChecksums.
+var ChecksummedValueFlag = memcache.DefaultFlagRegistry.MustRegister("checksummed", 28, 1)
+
+// valueChecksumSize is the size of the CRC-32C following the checksummed values, in big endian.
+const valueChecksumSize = 4
+
+var valueChecksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// WithValueChecksums follows the values written by MetaSet, Set, SetMulti and the other helpers with their CRC-32C,
+// and marks them with ChecksummedValueFlag. The checksum of the values read with the flag set is verified and removed,
+// and the flag cleared, before they're returned, whichever client wrote them: a value which doesn't match its checksum,
+// e.g. corrupted in memcached or in transit, fails the read with ErrCorruptValue. The values compressed by
+// WithValueCompression are checksummed once compressed. The values appended or prepended to are written without
+// checksum, so the checksum of an item written with one no longer matches once it's appended or prepended to, and the
+// max value size applies to the values without their checksum.
+func WithValueChecksums() ClientOption {
+	return func(c *memcachedClient) {
+		c.checksummer = &valueChecksummer{}
+	}
+}
+
+// ValueChecksumStats are the counters of the checksums of the values.
+type ValueChecksumStats struct {
+	// Written is the number of values written along with their checksum.
+	Written uint64
+	// Verified is the number of values read which matched their checksum.
+	Verified uint64
+	// Corrupt is the number of values read which didn't match their checksum.
+	Corrupt uint64
+}
+
+type valueChecksummer struct {
+	written  atomic.Uint64
+	verified atomic.Uint64
+	corrupt  atomic.Uint64
+}
+
+func (v *valueChecksummer) snapshot() *ValueChecksumStats {
+	return &ValueChecksumStats{
+		Written:  v.written.Load(),
+		Verified: v.verified.Load(),
+		Corrupt:  v.corrupt.Load(),
+	}
+}
+
+// rewriteRequest returns the encoder to send in place of e, with its values followed by their checksum.
+func (v *valueChecksummer) rewriteRequest(e codec.LinkEncoder) (codec.LinkEncoder, error) {
+	return rewriteSets(e, v.checksumSet)
+}
+
+// checksumSet returns a copy of e with its value followed by its checksum, or nil if it's left as is.
+func (v *valueChecksummer) checksumSet(e *memcache.MetaSetEncoder) (*memcache.MetaSetEncoder, error) {
+	if e.Mode == memcache.Append || e.Mode == memcache.Prepend {
+		return nil, nil
+	}
+	v.written.Add(1)
+	checksummed := *e
+	checksummed.Value = binary.BigEndian.AppendUint32(append(make([]byte, 0, len(e.Value)+valueChecksumSize), e.Value...), crc32.Checksum(e.Value, valueChecksumTable))
+	checksummed.ClientFlags = setFlag(e.ClientFlags, ChecksummedValueFlag)
+	return &checksummed, nil
+}
+
+// verifyResponse verifies and removes the checksum of the values of the responses decoded by d which are flagged as
+// checksummed.
+func (v *valueChecksummer) verifyResponse(d codec.LinkDecoder) error {
+	return rewriteGets(d, v.verifyGet)
+}
+
+func (v *valueChecksummer) verifyGet(d *memcache.MetaGetDecoder) error {
+	if !ChecksummedValueFlag.IsSet(d.ClientFlags) || d.Value == nil {
+		return nil
+	}
+	if len(d.Value) < valueChecksumSize {
+		v.corrupt.Add(1)
+		return fmt.Errorf("%w: %d bytes can't hold a checksum", ErrCorruptValue, len(d.Value))
+	}
+	value, checksum := d.Value[:len(d.Value)-valueChecksumSize], d.Value[len(d.Value)-valueChecksumSize:]
+	want, got := binary.BigEndian.Uint32(checksum), crc32.Checksum(value, valueChecksumTable)
+	if got != want {
+		v.corrupt.Add(1)
+		return fmt.Errorf("%w: checksum %08x, got %08x", ErrCorruptValue, want, got)
+	}
+	v.verified.Add(1)
+	d.Value = value
+	d.ClientFlags = ChecksummedValueFlag.Clear(d.ClientFlags)
+	return nil
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+func TestValueChecksums(t *testing.T) {
+	mc, srv := newTestClient(t, WithValueChecksums())
+	ctx := context.Background()
+
+	require.NoError(t, mc.Add(ctx, Item{Key: "k", Value: []byte("value"), ClientFlags: 3}))
+	stored, ok := srv.Get("k")
+	require.True(t, ok)
+	assert.Len(t, stored, len("value")+valueChecksumSize)
+
+	value, item, err := mc.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+	assert.Equal(t, uint64(3), item.ClientFlags)
+	values, err := mc.GetMulti(ctx, []string{"k"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"k": []byte("value")}, values)
+
+	// the values which aren't checksummed are read as is.
+	srv.Set("plain", []byte("plain"), 0)
+	value, _, err = mc.Get(ctx, "plain")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("plain"), value)
+
+	// a value which doesn't match its checksum fails the read alone.
+	plain, err := NewClient([]string{srv.Addr().String()}, 1)
+	require.NoError(t, err)
+	defer plain.Close() //nolint: errcheck
+	for key, corrupt := range map[string][]byte{"corrupt": append([]byte("valve"), stored[len(stored)-valueChecksumSize:]...), "short": []byte("ab")} {
+		encoder := memcache.CreateMetaSetEncoder()
+		encoder.Reset()
+		encoder.Key = key
+		encoder.Value = corrupt
+		encoder.ClientFlags = setFlag(0, ChecksummedValueFlag)
+		require.NoError(t, plain.MetaSet(ctx, encoder, memcache.CreateMetaSetDecoder()))
+		_, _, err = mc.Get(ctx, key)
+		assert.ErrorIs(t, err, ErrCorruptValue, key)
+	}
+	_, _, err = mc.Get(ctx, "k")
+	require.NoError(t, err)
+
+	stats := mc.Stats()
+	assert.Equal(t, &ValueChecksumStats{Written: 1, Verified: 3, Corrupt: 2}, stats.ValueChecksums)
+	var b strings.Builder
+	require.NoError(t, stats.WritePrometheus(&b))
+	assert.Contains(t, b.String(), "memlink_value_checksums_verified_total{result=\"corrupt\"} 2\n")
+}
+
+func TestValueChecksumsWithCompression(t *testing.T) {
+	mc, srv := newTestClient(t, WithValueChecksums(), WithValueCompression(ValueCompressionZstd, 64))
+	ctx := context.Background()
+	large := bytes.Repeat([]byte("compressible "), 100)
+
+	require.NoError(t, mc.Set(ctx, "k", large, 0))
+	stored, _ := srv.Get("k")
+	// the checksum follows the compressed value.
+	assert.True(t, bytes.HasPrefix(stored, zstdMagic))
+	assert.Less(t, len(stored), len(large))
+
+	value, item, err := mc.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, large, value)
+	assert.Zero(t, item.ClientFlags)
+
+	// the values appended to aren't checksummed.
+	require.NoError(t, mc.AppendValue(ctx, "k", []byte("more"), 0))
+	stored, _ = srv.Get("k")
+	assert.True(t, bytes.HasSuffix(stored, []byte("more")))
+}
@@ -0,0 +1,247 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math/rand/v2"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// ChunkedValueFlag is the client flag field marking the manifests of the values stored in chunks, see
+// WithValueChunking. It's the well-known memcache.ChunkedFlag.
+var ChunkedValueFlag = memcache.ChunkedFlag
+
+// chunkManifestMagic starts the manifests, followed by the id of the chunks, their number, and the size and CRC-32C of
+// the value.
+var chunkManifestMagic = []byte("memlink-chunks/1")
+
+var chunkTable = crc32.MakeTable(crc32.Castagnoli)
+
+// chunkItemOverhead is the room left in the chunks for what's stored along with their value: memcached's item header
+// and the \r\n following the value, and the checksum of WithValueChecksums or the nonce and tag of WithValueEncryption.
+const chunkItemOverhead = 128
+
+// maxChunkIDLength is the length of the largest chunk id, a 64-bit number in hex.
+const maxChunkIDLength = 16
+
+// WithValueChunking stores the values exceeding the max value size written by Set, Add and Replace in at most
+// maxChunks chunks, under keys derived from theirs, followed by a manifest under their key flagged with
+// ChunkedValueFlag. The chunks leave room in the max value size for their key and item header, so that they fit the
+// item size limit of the backends. Get, GetWithTTL, GetOrSet and GetMulti fetch the chunks of the manifests they read
+// and check the size and CRC-32C of the value they reassemble: a value missing a chunk, e.g. because it was evicted,
+// is a miss, and one failing the checks fails with ErrCorruptChunkedValue. The other reads return the manifest as is.
+// The chunks of a value which is overwritten or deleted, or whose manifest isn't stored by Add or Replace, are left to
+// expire or be evicted. Larger values still fail with ErrValueTooLarge.
+func WithValueChunking(maxChunks int) ClientOption {
+	return func(c *memcachedClient) {
+		c.chunker = &valueChunker{maxChunks: maxChunks}
+	}
+}
+
+// ValueChunkingStats are the counters of the values stored in chunks.
+type ValueChunkingStats struct {
+	// Writes is the number of values written in chunks.
+	Writes uint64
+	// Chunks is the number of chunks written.
+	Chunks uint64
+	// Reads is the number of values reassembled from their chunks.
+	Reads uint64
+	// IncompleteReads is the number of manifests read whose value missed a chunk, reported as misses.
+	IncompleteReads uint64
+	// CorruptReads is the number of values failing their size or checksum once reassembled.
+	CorruptReads uint64
+}
+
+type valueChunker struct {
+	maxChunks int
+
+	writes          atomic.Uint64
+	chunks          atomic.Uint64
+	reads           atomic.Uint64
+	incompleteReads atomic.Uint64
+	corruptReads    atomic.Uint64
+}
+
+func (v *valueChunker) snapshot() *ValueChunkingStats {
+	return &ValueChunkingStats{
+		Writes:          v.writes.Load(),
+		Chunks:          v.chunks.Load(),
+		Reads:           v.reads.Load(),
+		IncompleteReads: v.incompleteReads.Load(),
+		CorruptReads:    v.corruptReads.Load(),
+	}
+}
+
+// chunkManifest describes a value stored in chunks.
+type chunkManifest struct {
+	id       string
+	count    int
+	size     int
+	checksum uint32
+}
+
+func (m chunkManifest) encode() []byte {
+	return fmt.Appendf(nil, "%s %s %d %d %08x", chunkManifestMagic, m.id, m.count, m.size, m.checksum)
+}
+
+func parseChunkManifest(value []byte) (chunkManifest, error) {
+	fields := bytes.Fields(value)
+	if len(fields) != 5 || !bytes.Equal(fields[0], chunkManifestMagic) {
+		return chunkManifest{}, fmt.Errorf("%w: invalid manifest %q", ErrCorruptChunkedValue, value)
+	}
+	count, err := strconv.Atoi(string(fields[2]))
+	if err != nil || count <= 0 {
+		return chunkManifest{}, fmt.Errorf("%w: invalid chunk count in manifest %q", ErrCorruptChunkedValue, value)
+	}
+	size, err := strconv.Atoi(string(fields[3]))
+	if err != nil || size < 0 {
+		return chunkManifest{}, fmt.Errorf("%w: invalid size in manifest %q", ErrCorruptChunkedValue, value)
+	}
+	checksum, err := strconv.ParseUint(string(fields[4]), 16, 32)
+	if err != nil {
+		return chunkManifest{}, fmt.Errorf("%w: invalid checksum in manifest %q", ErrCorruptChunkedValue, value)
+	}
+	return chunkManifest{id: string(fields[1]), count: count, size: size, checksum: uint32(checksum)}, nil
+}
+
+// chunkKeys returns the keys of the chunks of the value of key. They embed the id of the manifest, so that a reader
+// never mixes the chunks of two writes.
+func (m chunkManifest) chunkKeys(key string) []string {
+	keys := make([]string, m.count)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%s:chunk:%s:%d", key, m.id, i)
+	}
+	return keys
+}
+
+// setChunked writes value, too large to be stored under a single key, in chunks, then writes its manifest under the
+// key of item with mode.
+func (c *memcachedClient) setChunked(ctx context.Context, mode memcache.MetaSetMode, item Item, value []byte) error {
+	chunkSize := c.chunkSize(item.Key)
+	if chunkSize <= 0 {
+		return fmt.Errorf("key=%q size=%d max=%d: no room for chunks: %w", item.Key, len(value), c.maxValueSize, ErrValueTooLarge)
+	}
+	count := (len(value) + chunkSize - 1) / chunkSize
+	if count > c.chunker.maxChunks {
+		return fmt.Errorf("key=%q size=%d max=%d: %w", item.Key, len(value), c.chunker.maxChunks*chunkSize, ErrValueTooLarge)
+	}
+
+	manifest := chunkManifest{
+		id:       strconv.FormatUint(rand.Uint64(), 16),
+		count:    count,
+		size:     len(value),
+		checksum: crc32.Checksum(value, chunkTable),
+	}
+	chunks := make([]Item, count)
+	for i, key := range manifest.chunkKeys(item.Key) {
+		if err := memcache.ValidateKey(key); err != nil {
+			return fmt.Errorf("key=%q: chunk key: %w", item.Key, err)
+		}
+		chunks[i] = Item{Key: key, Value: value[i*chunkSize : min((i+1)*chunkSize, len(value))], TTL: item.TTL, ExpireAt: item.ExpireAt}
+	}
+
+	statuses, err := perBackend(ctx, c, chunks, func(item Item) string { return item.Key }, func(ctx context.Context, items []Item) (map[string]memcache.MetadataStatus, error) {
+		return c.storeBulk(ctx, items, func(item Item) []byte { return item.Value })
+	})
+	if err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		if status := statuses[chunk.Key]; !status.IsStored() {
+			return fmt.Errorf("key=%q: chunk %q not stored: %s", item.Key, chunk.Key, status)
+		}
+	}
+	c.chunker.chunks.Add(uint64(count))
+
+	if err := c.storeValue(ctx, mode, item, manifest.encode(), setFlag(item.ClientFlags, ChunkedValueFlag)); err != nil {
+		return err
+	}
+	c.chunker.writes.Add(1)
+	return nil
+}
+
+// chunkSize returns the size of the chunks of the values stored under key, so that a full chunk, its key and item
+// header fit the max value size.
+func (c *memcachedClient) chunkSize(key string) int {
+	maxChunkKeyLength := len(key) + len(":chunk:") + maxChunkIDLength + len(":") + len(strconv.Itoa(c.chunker.maxChunks-1))
+	return c.maxValueSize - maxChunkKeyLength - chunkItemOverhead
+}
+
+// assembleChunked returns the value whose manifest is stored under key, still wrapped, and whether all its chunks
+// were found.
+func (c *memcachedClient) assembleChunked(ctx context.Context, key string, manifestValue []byte) ([]byte, bool, error) {
+	manifest, err := parseChunkManifest(manifestValue)
+	if err != nil {
+		c.chunker.corruptReads.Add(1)
+		return nil, false, fmt.Errorf("key=%q: %w", key, err)
+	}
+
+	if manifest.count > c.chunker.maxChunks {
+		c.chunker.corruptReads.Add(1)
+		return nil, false, fmt.Errorf("key=%q: %w: %d chunks, over the %d allowed", key, ErrCorruptChunkedValue, manifest.count, c.chunker.maxChunks)
+	}
+
+	keys := manifest.chunkKeys(key)
+	chunks, err := perBackend(ctx, c, keys, func(key string) string { return key }, func(ctx context.Context, keys []string) (map[string][]byte, error) {
+		return fetchBulkWith(ctx, c, keys, false, func(_ string, decoder *memcache.MetaGetDecoder) []byte {
+			return decoder.Value
+		})
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	// the size is only trusted up to what the chunks can hold, it's checked once they're reassembled.
+	value := make([]byte, 0, min(manifest.size, c.chunker.maxChunks*max(c.chunkSize(key), 0)))
+	for _, chunkKey := range keys {
+		chunk, ok := chunks[chunkKey]
+		if !ok {
+			c.chunker.incompleteReads.Add(1)
+			return nil, false, nil
+		}
+		value = append(value, chunk...)
+	}
+	if len(value) != manifest.size || crc32.Checksum(value, chunkTable) != manifest.checksum {
+		c.chunker.corruptReads.Add(1)
+		return nil, false, fmt.Errorf("key=%q: %w: reassembled %d bytes don't match the manifest", key, ErrCorruptChunkedValue, len(value))
+	}
+	c.chunker.reads.Add(1)
+	return value, true, nil
+}
+
+// fetchChunkedItems fetches valid keys along with their client flags in a single pipelined request, reassembles the
+// values stored in chunks, and returns the items of the ones which were found.
+func (c *memcachedClient) fetchChunkedItems(ctx context.Context, keys []string) (map[string]Item, error) {
+	items, err := fetchBulkWith(ctx, c, keys, true, func(key string, decoder *memcache.MetaGetDecoder) Item {
+		return Item{Key: key, Value: decoder.Value, ClientFlags: decoder.ClientFlags}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for key, item := range items {
+		if ChunkedValueFlag.IsSet(item.ClientFlags) {
+			value, found, err := c.assembleChunked(ctx, key, item.Value)
+			if err != nil || !found {
+				errs = append(errs, err)
+				delete(items, key)
+				continue
+			}
+			item.Value = value
+			item.ClientFlags = ChunkedValueFlag.Clear(item.ClientFlags)
+		}
+		item.Value, _ = c.unwrapValue(item.Value)
+		items[key] = item
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
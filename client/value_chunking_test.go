@@ -0,0 +1,142 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+func TestValueChunking(t *testing.T) {
+	mc, srv := newTestClient(t, WithMaxValueSize(1024), WithValueChunking(4))
+	ctx := context.Background()
+	large := bytes.Repeat([]byte("0123456789"), 240)
+
+	require.NoError(t, mc.Add(ctx, Item{Key: "large", Value: large, ClientFlags: 7}))
+	manifestValue, ok := srv.Get("large")
+	require.True(t, ok)
+	manifest, err := parseChunkManifest(manifestValue)
+	require.NoError(t, err)
+	assert.Equal(t, 3, manifest.count)
+	chunkKeys := manifest.chunkKeys("large")
+	for _, key := range chunkKeys {
+		chunk, ok := srv.Get(key)
+		require.True(t, ok)
+		assert.LessOrEqual(t, len(chunk)+len(key)+chunkItemOverhead, 1024)
+	}
+
+	value, item, err := mc.Get(ctx, "large")
+	require.NoError(t, err)
+	assert.Equal(t, large, value)
+	assert.Equal(t, uint64(7), item.ClientFlags)
+
+	require.NoError(t, mc.Set(ctx, "small", []byte("small"), 0))
+	values, err := mc.GetMulti(ctx, []string{"large", "small", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"large": large, "small": []byte("small")}, values)
+
+	// the values larger than the chunks allowed are still rejected.
+	err = mc.Set(ctx, "huge", bytes.Repeat([]byte("x"), 5*1024), 0)
+	assert.ErrorIs(t, err, ErrValueTooLarge)
+
+	// a corrupt chunk fails the read.
+	srv.Set(chunkKeys[1], bytes.Repeat([]byte("x"), 1024), 0)
+	_, _, err = mc.Get(ctx, "large")
+	assert.ErrorIs(t, err, ErrCorruptChunkedValue)
+
+	// a missing chunk makes the value a miss.
+	require.NoError(t, mc.Delete(ctx, chunkKeys[2]))
+	_, _, err = mc.Get(ctx, "large")
+	assert.ErrorIs(t, err, ErrNotFound)
+	values, err = mc.GetMulti(ctx, []string{"large", "small"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"small": []byte("small")}, values)
+
+	stats := mc.Stats()
+	assert.Equal(t, &ValueChunkingStats{Writes: 1, Chunks: 3, Reads: 2, IncompleteReads: 2, CorruptReads: 1}, stats.ValueChunking)
+
+	var b strings.Builder
+	require.NoError(t, stats.WritePrometheus(&b))
+	assert.Contains(t, b.String(), "memlink_chunked_read_failures_total{reason=\"incomplete\"} 2\n")
+}
+
+func TestValueChunking_ItemSizeLimit(t *testing.T) {
+	srv, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv.Close() //nolint: errcheck
+	srv.SetStats("settings", map[string]string{"item_size_max": "2048"})
+	// the max value size is lowered to the item size limit, which the full chunks fit along with their key and header.
+	mc, err := NewClient([]string{srv.Addr().String()}, 1, WithValueChunking(8), WithValueChecksums())
+	require.NoError(t, err)
+	defer mc.Close() //nolint: errcheck
+	ctx := context.Background()
+
+	large := bytes.Repeat([]byte("0123456789"), 1000)
+	require.NoError(t, mc.Set(ctx, "large", large, 0))
+	value, _, err := mc.Get(ctx, "large")
+	require.NoError(t, err)
+	assert.Equal(t, large, value)
+
+	// a manifest announcing more chunks than allowed isn't trusted.
+	encoder := memcache.CreateMetaSetEncoder()
+	encoder.Reset()
+	encoder.Key = "forged"
+	encoder.Value = chunkManifest{id: "ab12", count: 9, size: 1 << 40}.encode()
+	encoder.ClientFlags = setFlag(0, ChunkedValueFlag)
+	require.NoError(t, mc.MetaSet(ctx, encoder, memcache.CreateMetaSetDecoder()))
+	_, _, err = mc.Get(ctx, "forged")
+	assert.ErrorIs(t, err, ErrCorruptChunkedValue)
+}
+
+func TestValueChunkingOverwrite(t *testing.T) {
+	mc, srv := newTestClient(t, WithMaxValueSize(1024), WithValueChunking(4), WithSoftTTL(time.Minute))
+	ctx := context.Background()
+
+	first := bytes.Repeat([]byte("a"), 2000)
+	second := bytes.Repeat([]byte("b"), 1500)
+	require.NoError(t, mc.Set(ctx, "key", first, 0))
+	firstManifest, _ := srv.Get("key")
+	require.NoError(t, mc.Set(ctx, "key", second, 0))
+	secondManifest, _ := srv.Get("key")
+	assert.NotEqual(t, firstManifest, secondManifest)
+
+	// the soft TTL envelope is reassembled along with the value.
+	result, err := mc.GetWithTTL(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, result.Found)
+	assert.Equal(t, second, result.Value)
+	assert.False(t, result.WrittenAt.IsZero())
+
+	// values fitting in a single item are stored as is.
+	require.NoError(t, mc.Set(ctx, "key", []byte("small"), 0))
+	result, err = mc.GetWithTTL(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("small"), result.Value)
+	assert.Zero(t, result.ClientFlags)
+}
+
+func TestParseChunkManifest(t *testing.T) {
+	for _, manifest := range []string{
+		"",
+		"memlink-chunks/1 id 2 10",
+		"memlink-chunks/2 id 2 10 0000abcd",
+		"memlink-chunks/1 id 0 10 0000abcd",
+		"memlink-chunks/1 id 2 -1 0000abcd",
+		"memlink-chunks/1 id 2 10 nothex",
+	} {
+		_, err := parseChunkManifest([]byte(manifest))
+		assert.ErrorIs(t, err, ErrCorruptChunkedValue, manifest)
+	}
+
+	want := chunkManifest{id: "ab12", count: 2, size: 10, checksum: 0xabcd}
+	got, err := parseChunkManifest(want.encode())
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
@@ -0,0 +1,208 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// ValueCompression is an algorithm compressing the values stored by the client, see WithValueCompression.
+type ValueCompression string
+
+const (
+	// ValueCompressionSnappy compresses the values in the snappy framing format, fast but compressing the least.
+	ValueCompressionSnappy ValueCompression = "snappy"
+	// ValueCompressionZstd compresses the values as zstd frames.
+	ValueCompressionZstd ValueCompression = "zstd"
+	// ValueCompressionGzip compresses the values as gzip streams, for the services of other languages lacking the
+	// other two.
+	ValueCompressionGzip ValueCompression = "gzip"
+)
+
+// CompressedValueFlag is the client flag field, of a single bit, marking the compressed values. The algorithm is told
+// by the header of the value, so a client decompresses the values of every algorithm, whichever it compresses with.
+var CompressedValueFlag = memcache.DefaultFlagRegistry.MustRegister("compressed", 30, 1)
+
+var (
+	snappyMagic = []byte("\xff\x06\x00\x00sNaPpY")
+	zstdMagic   = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	gzipMagic   = []byte{0x1f, 0x8b}
+)
+
+// WithValueCompression compresses the values of at least threshold bytes written by MetaSet, Set, SetMulti and the
+// other helpers with algorithm, unless compressing doesn't make them smaller, and marks them with
+// CompressedValueFlag. The values read with the flag set are decompressed, and the flag cleared, before being
+// returned, whichever client wrote them. The values appended or prepended to are left uncompressed, as are the ones
+// sent through Pipeline, and the max value size applies to the uncompressed values.
+func WithValueCompression(algorithm ValueCompression, threshold int) ClientOption {
+	return func(c *memcachedClient) {
+		c.valueCompressor = &valueCompressor{algorithm: algorithm, threshold: threshold}
+	}
+}
+
+// ValueCompressionStats are the counters of the values compressed by the client.
+type ValueCompressionStats struct {
+	// Compressed is the number of values compressed before being written.
+	Compressed uint64
+	// Decompressed is the number of values decompressed after being read.
+	Decompressed uint64
+	// SavedBytes is the number of bytes compression saved on the values written.
+	SavedBytes uint64
+}
+
+type valueCompressor struct {
+	algorithm ValueCompression
+	threshold int
+	// maxSize bounds the size of the values decompressed, so that a corrupted value can't exhaust the memory.
+	maxSize int
+
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+
+	compressed   atomic.Uint64
+	decompressed atomic.Uint64
+	savedBytes   atomic.Uint64
+}
+
+// start validates the algorithm and creates the codecs shared by the requests.
+func (v *valueCompressor) start(maxSize int) error {
+	v.maxSize = maxSize
+	switch v.algorithm {
+	case ValueCompressionSnappy, ValueCompressionZstd, ValueCompressionGzip:
+	default:
+		return fmt.Errorf("unknown value compression %q", v.algorithm)
+	}
+
+	var err error
+	v.zstdEncoder, err = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return err
+	}
+	v.zstdDecoder, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(uint64(maxSize)))
+	if err != nil {
+		_ = v.zstdEncoder.Close()
+		return err
+	}
+	return nil
+}
+
+func (v *valueCompressor) close() {
+	_ = v.zstdEncoder.Close()
+	v.zstdDecoder.Close()
+}
+
+func (v *valueCompressor) snapshot() *ValueCompressionStats {
+	return &ValueCompressionStats{
+		Compressed:   v.compressed.Load(),
+		Decompressed: v.decompressed.Load(),
+		SavedBytes:   v.savedBytes.Load(),
+	}
+}
+
+// rewriteRequest returns the encoder to send in place of e, with its values compressed.
+func (v *valueCompressor) rewriteRequest(e codec.LinkEncoder) (codec.LinkEncoder, error) {
+	return rewriteSets(e, v.compressSet)
+}
+
+// compressSet returns a copy of e with its value compressed, or nil if it's left as is.
+func (v *valueCompressor) compressSet(e *memcache.MetaSetEncoder) (*memcache.MetaSetEncoder, error) {
+	if len(e.Value) < v.threshold || e.Mode == memcache.Append || e.Mode == memcache.Prepend {
+		return nil, nil
+	}
+	value, err := v.compress(e.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress value: %w", err)
+	}
+	if len(value) >= len(e.Value) {
+		return nil, nil
+	}
+
+	v.compressed.Add(1)
+	v.savedBytes.Add(uint64(len(e.Value) - len(value)))
+	compressed := *e
+	compressed.Value = value
+	compressed.ClientFlags = setFlag(e.ClientFlags, CompressedValueFlag)
+	return &compressed, nil
+}
+
+func (v *valueCompressor) compress(value []byte) ([]byte, error) {
+	switch v.algorithm {
+	case ValueCompressionZstd:
+		return v.zstdEncoder.EncodeAll(value, nil), nil
+	case ValueCompressionSnappy:
+		var b bytes.Buffer
+		w := s2.NewWriter(&b, s2.WriterSnappyCompat(), s2.WriterConcurrency(1))
+		if _, err := w.Write(value); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	default:
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		if _, err := w.Write(value); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+}
+
+// decompressResponse decompresses the values of the responses decoded by d which are flagged as compressed.
+func (v *valueCompressor) decompressResponse(d codec.LinkDecoder) error {
+	return rewriteGets(d, v.decompressGet)
+}
+
+func (v *valueCompressor) decompressGet(d *memcache.MetaGetDecoder) error {
+	if !CompressedValueFlag.IsSet(d.ClientFlags) || d.Value == nil {
+		return nil
+	}
+	value, err := v.decompress(d.Value)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCorruptCompressedValue, err)
+	}
+	v.decompressed.Add(1)
+	d.Value = value
+	d.ClientFlags = CompressedValueFlag.Clear(d.ClientFlags)
+	return nil
+}
+
+func (v *valueCompressor) decompress(value []byte) ([]byte, error) {
+	var r io.Reader
+	switch {
+	case bytes.HasPrefix(value, zstdMagic):
+		return v.zstdDecoder.DecodeAll(value, nil)
+	case bytes.HasPrefix(value, snappyMagic):
+		r = s2.NewReader(bytes.NewReader(value))
+	case bytes.HasPrefix(value, gzipMagic):
+		gz, err := gzip.NewReader(bytes.NewReader(value))
+		if err != nil {
+			return nil, err
+		}
+		r = gz
+	default:
+		return nil, errors.New("unknown compression format")
+	}
+
+	decompressed, err := io.ReadAll(io.LimitReader(r, int64(v.maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(decompressed) > v.maxSize {
+		return nil, fmt.Errorf("decompressed value exceeds %d bytes", v.maxSize)
+	}
+	return decompressed, nil
+}
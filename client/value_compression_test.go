@@ -0,0 +1,98 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+func TestValueCompression(t *testing.T) {
+	large := bytes.Repeat([]byte("compressible "), 100)
+	for _, algorithm := range []ValueCompression{ValueCompressionSnappy, ValueCompressionZstd, ValueCompressionGzip} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			mc, srv := newTestClient(t, WithValueCompression(algorithm, 64))
+			ctx := context.Background()
+
+			require.NoError(t, mc.Set(ctx, "large", large, 0))
+			require.NoError(t, mc.Set(ctx, "small", []byte("tiny"), 0))
+			_, err := mc.SetMulti(ctx, []Item{{Key: "multi", Value: large, ClientFlags: 1}})
+			require.NoError(t, err)
+
+			compressed, ok := srv.Get("large")
+			require.True(t, ok)
+			assert.Less(t, len(compressed), len(large))
+			stored, ok := srv.Get("small")
+			require.True(t, ok)
+			assert.Equal(t, []byte("tiny"), stored)
+
+			value, item, err := mc.Get(ctx, "large")
+			require.NoError(t, err)
+			assert.Equal(t, large, value)
+			assert.Zero(t, item.ClientFlags)
+			values, err := mc.GetMulti(ctx, []string{"large", "small", "multi"})
+			require.NoError(t, err)
+			assert.Equal(t, map[string][]byte{"large": large, "small": []byte("tiny"), "multi": large}, values)
+			result, err := mc.GetWithTTL(ctx, "multi")
+			require.NoError(t, err)
+			assert.Equal(t, uint64(1), result.ClientFlags)
+
+			stats := mc.Stats().ValueCompression
+			require.NotNil(t, stats)
+			assert.Equal(t, uint64(2), stats.Compressed)
+			assert.Equal(t, uint64(4), stats.Decompressed)
+			assert.Equal(t, uint64(2*(len(large)-len(compressed))), stats.SavedBytes)
+		})
+	}
+}
+
+func TestValueCompressionInterop(t *testing.T) {
+	large := bytes.Repeat([]byte("compressible "), 100)
+	writer, srv := newTestClient(t, WithValueCompression(ValueCompressionZstd, 64))
+	ctx := context.Background()
+	require.NoError(t, writer.Set(ctx, "k", large, 0))
+
+	// a client decompresses the values of every algorithm.
+	reader, err := NewClient([]string{srv.Addr().String()}, 1, WithValueCompression(ValueCompressionGzip, 64))
+	require.NoError(t, err)
+	defer reader.Close() //nolint: errcheck
+	value, _, err := reader.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, large, value)
+
+	// the values appended to aren't compressed, so that they can be concatenated.
+	require.NoError(t, reader.Set(ctx, "log", []byte("start "), 0))
+	require.NoError(t, reader.AppendValue(ctx, "log", large, 0))
+	stored, _ := srv.Get("log")
+	assert.Equal(t, append([]byte("start "), large...), stored)
+
+	// a value flagged as compressed by another client which can't be decompressed fails the read alone.
+	plain, err := NewClient([]string{srv.Addr().String()}, 1)
+	require.NoError(t, err)
+	defer plain.Close() //nolint: errcheck
+	encoder := memcache.CreateMetaSetEncoder()
+	encoder.Reset()
+	encoder.Key = "corrupt"
+	encoder.Value = []byte("not compressed")
+	encoder.ClientFlags = setFlag(0, CompressedValueFlag)
+	require.NoError(t, plain.MetaSet(ctx, encoder, memcache.CreateMetaSetDecoder()))
+	_, _, err = reader.Get(ctx, "corrupt")
+	assert.ErrorIs(t, err, ErrCorruptCompressedValue)
+	value, _, err = reader.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, large, value)
+
+	var b strings.Builder
+	require.NoError(t, reader.Stats().WritePrometheus(&b))
+	assert.Contains(t, b.String(), "memlink_value_compression_values_total{direction=\"decompressed\"} 2\n")
+}
+
+func TestValueCompressionUnknownAlgorithm(t *testing.T) {
+	_, err := NewClient([]string{"127.0.0.1:1"}, 1, WithValueCompression("lz4", 64), WithoutCapabilityDetection())
+	assert.ErrorContains(t, err, "unknown value compression")
+}
@@ -0,0 +1,213 @@
+package client
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// maxEncryptionKeyVersion is the highest key version the memcache.EncryptionFlag field of the client flags can record.
+var maxEncryptionKeyVersion = uint8(1<<memcache.EncryptionFlag.Width - 1)
+
+// EncryptionKeyProvider provides the AES keys of WithValueEncryption, of 16, 24 or 32 bytes. A version must always
+// name the same key, since the values encrypted with it are read back with the key of their version.
+type EncryptionKeyProvider interface {
+	// CurrentKey returns the key the values are encrypted with, and its version, from 1 to 15.
+	CurrentKey() (version uint8, key []byte, err error)
+	// Key returns the key of version, e.g. the previous one while the values encrypted with it expire.
+	Key(version uint8) ([]byte, error)
+}
+
+// StaticEncryptionKeys is an EncryptionKeyProvider holding a fixed set of keys by version.
+type StaticEncryptionKeys struct {
+	// Current is the version of the key the values are encrypted with.
+	Current uint8
+	Keys    map[uint8][]byte
+}
+
+var _ EncryptionKeyProvider = StaticEncryptionKeys{}
+
+func (k StaticEncryptionKeys) CurrentKey() (uint8, []byte, error) {
+	key, err := k.Key(k.Current)
+	return k.Current, key, err
+}
+
+func (k StaticEncryptionKeys) Key(version uint8) ([]byte, error) {
+	key, ok := k.Keys[version]
+	if !ok {
+		return nil, fmt.Errorf("no encryption key of version %d", version)
+	}
+	return key, nil
+}
+
+// WithValueEncryption encrypts the values written by MetaSet, Set, SetMulti and the other helpers with AES-GCM, under
+// the current key of keys, whose version is recorded in the memcache.EncryptionFlag field of their client flags. The
+// values read with a key version are decrypted with the key of that version, and the field cleared, before being
+// returned, so keys can be rotated while the values encrypted with the previous ones are still read. A value which
+// can't be decrypted fails the read with ErrUndecryptableValue. The values are compressed by WithValueCompression
+// before being encrypted, and checksummed by WithValueChecksums once encrypted. The values appended or prepended to
+// are left unencrypted, and so can't be appended or prepended to a value which is encrypted. The values are bound to
+// the key they're stored under, which is authenticated along with them: a value copied under another key fails to
+// decrypt.
+func WithValueEncryption(keys EncryptionKeyProvider) ClientOption {
+	return func(c *memcachedClient) {
+		c.encryptor = &valueEncryptor{keys: keys, aeads: make(map[uint8]cipher.AEAD)}
+	}
+}
+
+// ValueEncryptionStats are the counters of the values encrypted by the client.
+type ValueEncryptionStats struct {
+	// Encrypted is the number of values encrypted before being written.
+	Encrypted uint64
+	// Decrypted is the number of values decrypted after being read.
+	Decrypted uint64
+	// Failures is the number of values read which couldn't be decrypted.
+	Failures uint64
+}
+
+type valueEncryptor struct {
+	keys EncryptionKeyProvider
+
+	mu    sync.Mutex
+	aeads map[uint8]cipher.AEAD // protected by mu
+
+	encrypted atomic.Uint64
+	decrypted atomic.Uint64
+	failures  atomic.Uint64
+}
+
+func (v *valueEncryptor) snapshot() *ValueEncryptionStats {
+	return &ValueEncryptionStats{
+		Encrypted: v.encrypted.Load(),
+		Decrypted: v.decrypted.Load(),
+		Failures:  v.failures.Load(),
+	}
+}
+
+// aead returns the cipher of the key of version, key itself if it's not nil, which is cached since a version always
+// names the same key.
+func (v *valueEncryptor) aead(version uint8, key []byte) (cipher.AEAD, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if aead, ok := v.aeads[version]; ok {
+		return aead, nil
+	}
+
+	if key == nil {
+		var err error
+		if key, err = v.keys.Key(version); err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key of version %d: %w", version, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	v.aeads[version] = aead
+	return aead, nil
+}
+
+// rewriteRequest returns the encoder to send in place of e, with its values encrypted, and whose gets fetch the keys
+// the values are bound to.
+func (v *valueEncryptor) rewriteRequest(e codec.LinkEncoder) (codec.LinkEncoder, error) {
+	e, err := rewriteSets(e, v.encryptSet)
+	if err != nil {
+		return nil, err
+	}
+	return rewriteGetRequests(e, fetchKey)
+}
+
+// encryptSet returns a copy of e with its value encrypted, or nil if it's left as is.
+func (v *valueEncryptor) encryptSet(e *memcache.MetaSetEncoder) (*memcache.MetaSetEncoder, error) {
+	if e.Mode == memcache.Append || e.Mode == memcache.Prepend {
+		return nil, nil
+	}
+	version, key, err := v.keys.CurrentKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the current encryption key: %w", err)
+	}
+	if version == 0 || version > maxEncryptionKeyVersion {
+		return nil, fmt.Errorf("invalid encryption key version %d, must be from 1 to %d", version, maxEncryptionKeyVersion)
+	}
+	aead, err := v.aead(version, key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(e.Value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	v.encrypted.Add(1)
+	encrypted := *e
+	encrypted.Value = aead.Seal(nonce, nonce, e.Value, []byte(setWireKey(e)))
+	encrypted.ClientFlags, _ = memcache.EncryptionFlag.Set(e.ClientFlags, uint64(version)) // the version fits.
+	return &encrypted, nil
+}
+
+// decryptResponse decrypts the values of the responses decoded by d which carry a key version.
+func (v *valueEncryptor) decryptResponse(d codec.LinkDecoder) error {
+	return rewriteGets(d, v.decryptGet)
+}
+
+func (v *valueEncryptor) decryptGet(d *memcache.MetaGetDecoder) error {
+	version := uint8(memcache.EncryptionFlag.Get(d.ClientFlags))
+	if version == 0 || d.Value == nil {
+		return nil
+	}
+	value, err := v.decrypt(version, d.Value, []byte(d.ItemKey))
+	if err != nil {
+		v.failures.Add(1)
+		return fmt.Errorf("%w: key version %d: %w", ErrUndecryptableValue, version, err)
+	}
+	v.decrypted.Add(1)
+	d.Value = value
+	d.ClientFlags = memcache.EncryptionFlag.Clear(d.ClientFlags)
+	return nil
+}
+
+// decrypt returns value decrypted with the key of version, provided it was encrypted for the item key wireKey.
+func (v *valueEncryptor) decrypt(version uint8, value []byte, wireKey []byte) ([]byte, error) {
+	aead, err := v.aead(version, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(value) < aead.NonceSize() {
+		return nil, fmt.Errorf("%d bytes can't hold a nonce", len(value))
+	}
+	return aead.Open(nil, value[:aead.NonceSize()], value[aead.NonceSize():], wireKey)
+}
+
+// setWireKey returns the key e stores its value under as sent to memcached, i.e. as returned along with the values
+// read with the k flag.
+func setWireKey(e *memcache.MetaSetEncoder) string {
+	if !e.ValidatedKey.IsZero() {
+		return e.ValidatedKey.Wire()
+	}
+	if e.BinaryKey != nil {
+		return base64.StdEncoding.EncodeToString(e.BinaryKey)
+	}
+	return e.Key
+}
+
+// fetchKey returns a copy of e fetching the key along with the value, or nil if e already does or doesn't fetch the
+// value.
+func fetchKey(e *memcache.MetaGetEncoder) *memcache.MetaGetEncoder {
+	if !e.FetchValue || e.FetchKey {
+		return nil
+	}
+	get := *e
+	get.FetchKey = true
+	return &get
+}
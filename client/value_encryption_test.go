@@ -0,0 +1,99 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+func TestValueEncryption(t *testing.T) {
+	keyV1, keyV2 := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 16)
+	mc, srv := newTestClient(t, WithValueEncryption(StaticEncryptionKeys{Current: 1, Keys: map[uint8][]byte{1: keyV1}}))
+	ctx := context.Background()
+
+	require.NoError(t, mc.Add(ctx, Item{Key: "k", Value: []byte("secret"), ClientFlags: 5}))
+	stored, ok := srv.Get("k")
+	require.True(t, ok)
+	assert.NotContains(t, string(stored), "secret")
+	value, item, err := mc.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), value)
+	assert.Equal(t, uint64(5), item.ClientFlags)
+
+	// the values encrypted with a previous key are still read after a rotation.
+	rotated, err := NewClient([]string{srv.Addr().String()}, 1,
+		WithValueEncryption(StaticEncryptionKeys{Current: 2, Keys: map[uint8][]byte{1: keyV1, 2: keyV2}}))
+	require.NoError(t, err)
+	defer rotated.Close() //nolint: errcheck
+	values, err := rotated.GetMulti(ctx, []string{"k"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"k": []byte("secret")}, values)
+
+	// the typed caches go through the encryption of their client.
+	cache := NewCache[map[string]int](rotated, JSONMarshaler{})
+	require.NoError(t, cache.Set(ctx, "typed", map[string]int{"a": 1}, 0))
+	typed, err := cache.Get(ctx, "typed")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 1}, typed)
+
+	// the key of a version which isn't provided can't decrypt its values.
+	_, _, err = mc.Get(ctx, "typed")
+	assert.ErrorIs(t, err, ErrUndecryptableValue)
+
+	// neither can a value copied under another key, nor a tampered one.
+	plain, err := NewClient([]string{srv.Addr().String()}, 1)
+	require.NoError(t, err)
+	defer plain.Close() //nolint: errcheck
+	flags, err := memcache.EncryptionFlag.Set(0, 1)
+	require.NoError(t, err)
+	tampered := bytes.Clone(stored)
+	tampered[len(tampered)-1] ^= 1
+	for key, value := range map[string][]byte{"copied": stored, "tampered": tampered} {
+		encoder := memcache.CreateMetaSetEncoder()
+		encoder.Reset()
+		encoder.Key = key
+		encoder.Value = value
+		encoder.ClientFlags = flags
+		require.NoError(t, plain.MetaSet(ctx, encoder, memcache.CreateMetaSetDecoder()))
+		_, _, err = mc.Get(ctx, key)
+		assert.ErrorIs(t, err, ErrUndecryptableValue)
+	}
+
+	stats := mc.Stats()
+	assert.Equal(t, &ValueEncryptionStats{Encrypted: 1, Decrypted: 1, Failures: 3}, stats.ValueEncryption)
+	var b strings.Builder
+	require.NoError(t, stats.WritePrometheus(&b))
+	assert.Contains(t, b.String(), "memlink_value_encryption_failures_total 3\n")
+}
+
+func TestValueEncryption_KeyVersionOverflow(t *testing.T) {
+	mc, _ := newTestClient(t, WithValueEncryption(StaticEncryptionKeys{Current: 16, Keys: map[uint8][]byte{16: bytes.Repeat([]byte{1}, 32)}}))
+	err := mc.Set(context.Background(), "k", []byte("secret"), 0)
+	assert.ErrorContains(t, err, "invalid encryption key version 16")
+}
+
+func TestValueEncryptionWithCompression(t *testing.T) {
+	mc, srv := newTestClient(t,
+		WithValueEncryption(StaticEncryptionKeys{Current: 7, Keys: map[uint8][]byte{7: bytes.Repeat([]byte{7}, 32)}}),
+		WithValueCompression(ValueCompressionGzip, 64),
+		WithValueChecksums(),
+	)
+	ctx := context.Background()
+	large := bytes.Repeat([]byte("compressible "), 100)
+
+	// the values are compressed before being encrypted.
+	require.NoError(t, mc.Set(ctx, "k", large, 0))
+	stored, _ := srv.Get("k")
+	assert.Less(t, len(stored), len(large)/2)
+
+	value, item, err := mc.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, large, value)
+	assert.Zero(t, item.ClientFlags)
+}
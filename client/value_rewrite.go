@@ -0,0 +1,108 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// setFlag returns flags with the bit of the single bit field set.
+func setFlag(flags uint64, field memcache.FlagField) uint64 {
+	flags, _ = field.Set(flags, 1) // 1 always fits.
+	return flags
+}
+
+// rewriteSets returns the encoder to send in place of e: a copy of it whose sets are replaced by the ones rewrite
+// returns, and whose gets fetch the client flags along with the values, so that the responses can be rewritten
+// according to them. It returns e itself when it's fine as is. rewrite returns nil to leave a set as is.
+func rewriteSets(e codec.LinkEncoder, rewrite func(*memcache.MetaSetEncoder) (*memcache.MetaSetEncoder, error)) (codec.LinkEncoder, error) {
+	switch encoder := e.(type) {
+	case *memcache.MetaGetEncoder:
+		if get := fetchFlags(encoder); get != nil {
+			return get, nil
+		}
+	case *memcache.BulkEncoder[*memcache.MetaGetEncoder]:
+		return rewriteBulk(encoder, func(get *memcache.MetaGetEncoder) (*memcache.MetaGetEncoder, error) {
+			return fetchFlags(get), nil
+		})
+	case *memcache.MetaSetEncoder:
+		set, err := rewrite(encoder)
+		if err != nil || set == nil {
+			return e, err
+		}
+		return set, nil
+	case *memcache.BulkEncoder[*memcache.MetaSetEncoder]:
+		return rewriteBulk(encoder, rewrite)
+	}
+	return e, nil
+}
+
+// rewriteGetRequests returns the encoder to send in place of e: a copy of it whose gets are replaced by the ones
+// rewrite returns, or e itself when it's fine as is. rewrite returns nil to leave a get as is.
+func rewriteGetRequests(e codec.LinkEncoder, rewrite func(*memcache.MetaGetEncoder) *memcache.MetaGetEncoder) (codec.LinkEncoder, error) {
+	switch encoder := e.(type) {
+	case *memcache.MetaGetEncoder:
+		if get := rewrite(encoder); get != nil {
+			return get, nil
+		}
+	case *memcache.BulkEncoder[*memcache.MetaGetEncoder]:
+		return rewriteBulk(encoder, func(get *memcache.MetaGetEncoder) (*memcache.MetaGetEncoder, error) {
+			return rewrite(get), nil
+		})
+	}
+	return e, nil
+}
+
+// rewriteBulk returns a copy of e whose encoders are replaced by the ones rewrite returns, or e itself if rewrite
+// returns nil for all of them.
+func rewriteBulk[E codec.LinkEncoder](e *memcache.BulkEncoder[E], rewrite func(E) (E, error)) (codec.LinkEncoder, error) {
+	var bulk *memcache.BulkEncoder[E]
+	var unchanged E
+	for i, encoder := range e.Encoders {
+		rewritten, err := rewrite(encoder)
+		if err != nil {
+			return nil, err
+		}
+		if any(rewritten) == any(unchanged) {
+			continue
+		}
+		if bulk == nil {
+			bulk = &memcache.BulkEncoder[E]{
+				Encoders: append([]E(nil), e.Encoders...),
+				Opaque:   e.Opaque,
+			}
+		}
+		bulk.Encoders[i] = rewritten
+	}
+	if bulk == nil {
+		return e, nil
+	}
+	return bulk, nil
+}
+
+// fetchFlags returns a copy of e fetching the client flags along with the value, or nil if e already does or doesn't
+// fetch the value.
+func fetchFlags(e *memcache.MetaGetEncoder) *memcache.MetaGetEncoder {
+	if !e.FetchValue || e.FetchClientFlags {
+		return nil
+	}
+	get := *e
+	get.FetchClientFlags = true
+	return &get
+}
+
+// rewriteGets calls rewrite with the responses to the gets decoded by d.
+func rewriteGets(d codec.LinkDecoder, rewrite func(*memcache.MetaGetDecoder) error) error {
+	switch decoder := d.(type) {
+	case *memcache.MetaGetDecoder:
+		return rewrite(decoder)
+	case *memcache.BulkDecoder[*memcache.MetaGetDecoder]:
+		var errs []error
+		for _, get := range decoder.Decoders {
+			errs = append(errs, rewrite(get))
+		}
+		return errors.Join(errs...)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/internal/debugcheck"
+	netpkg "github.com/stripe/memlink/internal/net"
+)
+
+// BackendReadiness is the outcome of warming up the connections to a backend, see WarmUp.
+type BackendReadiness = netpkg.BackendReadiness
+
+// ConnReadiness is the outcome of warming up a connection, see WarmUp.
+type ConnReadiness = netpkg.ConnReadiness
+
+// WarmUp waits for every connection of the client to be established and to answer a version request, until ctx is
+// done, and returns the readiness of every backend in placement order. The error lists the connections which aren't
+// ready, it's nil once the client is ready to serve traffic at full capacity.
+func (c *memcachedClient) WarmUp(ctx context.Context) ([]BackendReadiness, error) {
+	readiness := c.pool.WarmUp(ctx, c.probeVersion)
+
+	var errs []error
+	for _, backend := range readiness {
+		for i, conn := range backend.Conns {
+			if !conn.Ready() {
+				errs = append(errs, fmt.Errorf("backend=%s conn=%d: %w", backend.Addr, i, conn.Err))
+			}
+		}
+	}
+	return readiness, errors.Join(errs...)
+}
+
+// probeVersion sends a version request on conn and checks its response.
+func (c *memcachedClient) probeVersion(ctx context.Context, conn codec.Chain) error {
+	encoder, decoder := memcache.CreateVersionEncoder(), memcache.CreateVersionDecoder()
+	link, err := c.newLink(encoder, decoder, codec.RoutingHint{})
+	if err != nil {
+		return err
+	}
+	if err := conn.Append(link); err != nil {
+		debugcheck.Release(encoder, decoder, nil)
+		return fmt.Errorf("failed to append version request: %w", err)
+	}
+
+	err = c.wait(ctx, link)
+	debugcheck.Release(encoder, decoder, link.Done())
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(decoder.HdrLine, "VERSION") {
+		return fmt.Errorf("unexpected response to version request: %q", strings.TrimSpace(decoder.HdrLine))
+	}
+	return nil
+}
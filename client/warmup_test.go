@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+func TestWarmUp(t *testing.T) {
+	srv, err := fakeserver.Start()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = srv.Close() })
+	mc, err := NewClient([]string{srv.Addr().String()}, 2)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mc.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	versions := srv.CommandCount("version")
+	readiness, err := mc.WithNamespace("billing").WarmUp(ctx)
+	require.NoError(t, err)
+
+	require.Len(t, readiness, 1)
+	assert.Equal(t, srv.Addr().String(), readiness[0].Addr)
+	assert.Len(t, readiness[0].Conns, 2)
+	assert.True(t, readiness[0].Ready())
+	// every connection answered a version request of its own.
+	assert.Equal(t, versions+2, srv.CommandCount("version"))
+}
@@ -0,0 +1,93 @@
+// Command memlinkctl bundles operational tools for memcached clusters accessed through memlink.
+//
+// Usage:
+//
+//	memlinkctl -servers host:port[,host:port...] <subcommand> [flags]
+//
+// Subcommands:
+//
+//	ttl-audit  report, per key prefix, the items without a TTL or with a TTL above a bound
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/stripe/memlink/client"
+)
+
+func main() {
+	servers := flag.String("servers", "localhost:11211", "comma separated list of memcached addresses")
+	timeout := flag.Duration("timeout", 5*time.Minute, "maximum duration of the subcommand")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	mc, err := client.NewClient(strings.Split(*servers, ","), 1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create client: %v\n", err)
+		os.Exit(1)
+	}
+	defer mc.Close() //nolint: errcheck
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "ttl-audit":
+		err = ttlAudit(ctx, mc, args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown subcommand %q\n", cmd)
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <subcommand> [subcommand flags]\n\nSubcommands:\n", os.Args[0])
+	fmt.Fprintf(flag.CommandLine.Output(), "  ttl-audit\treport the items without a TTL or with a TTL above a bound per key prefix\n\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func ttlAudit(ctx context.Context, mc client.MemcachedClient, args []string) error {
+	fs := flag.NewFlagSet("ttl-audit", flag.ExitOnError)
+	separator := fs.String("separator", ":", "separator between the key prefix and the rest of the key")
+	maxTTL := fs.Duration("max-ttl", 0, "report items expiring further than this from now, 0 to only report items without a TTL")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	audits, err := client.AuditTTLs(ctx, mc, client.PrefixNamespace(*separator), *maxTTL)
+	if err != nil {
+		return err
+	}
+
+	prefixes := make([]string, 0, len(audits))
+	for prefix := range audits {
+		prefixes = append(prefixes, prefix)
+	}
+	slices.Sort(prefixes)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PREFIX\tITEMS\tNO TTL\tABOVE MAX\tEXAMPLES")
+	for _, prefix := range prefixes {
+		audit := audits[prefix]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", prefix, audit.Items, audit.NoTTL, audit.AboveMax, strings.Join(audit.Offenders, ","))
+	}
+	return w.Flush()
+}
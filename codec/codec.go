@@ -1,26 +1,104 @@
 package codec
 
 import (
-	"bufio"
+	"bytes"
+	"io"
+	"sync"
 
 	"github.com/stripe/memlink/internal"
 )
 
+// Writer is where encoders write their requests. *bufio.Writer implements it, but transports which don't buffer
+// through bufio, e.g. an in-memory test server or a writer aligned on TLS records, can pass their own.
+type Writer interface {
+	io.Writer
+	io.StringWriter
+}
+
+// Reader is where decoders read their responses from. *bufio.Reader implements it, as can a transport reading
+// responses framed otherwise, e.g. out of UDP datagrams.
+type Reader interface {
+	io.Reader
+	io.ByteReader
+	// ReadSlice reads until the first occurrence of delim, like bufio.Reader.ReadSlice: the bytes returned are only
+	// valid until the next read.
+	ReadSlice(delim byte) ([]byte, error)
+	ReadString(delim byte) (string, error)
+	// Peek returns the next n bytes without consuming them, like bufio.Reader.Peek.
+	Peek(n int) ([]byte, error)
+	// Buffered returns the number of bytes which can be read without blocking.
+	Buffered() int
+}
+
 // LinkEncoder allows you to convert a request to a network request.
 // You can have multiple LinkEncoders chained together to perform a batch operation.
 // You can use any approach to chain together the requests and write it to the buffer.
 // For a bulk request, one can even create a temporary buffer and call into multiple requests
 // and then call into the provided buffer.
 type LinkEncoder interface {
-	Encode(writer *bufio.Writer) error
+	Encode(writer Writer) error
 	internal.Resettable
 }
 
 type LinkDecoder interface {
-	Decode(reader *bufio.Reader) error
+	Decode(reader Reader) error
 	internal.Resettable
 }
 
+// ScratchEncoder is implemented by encoders able to build their request in a scratch buffer owned by the caller, rather
+// than in one taken from a pool shared by every connection. Connections pass a buffer of their own, reused for every
+// request they write, which spares hot multi-core clients the contention on the shared pool.
+type ScratchEncoder interface {
+	// EncodeWithScratch is like Encode, building the request in scratch, which is empty. scratch is only used until
+	// it returns.
+	EncodeWithScratch(writer Writer, scratch *bytes.Buffer) error
+}
+
+// Encode writes the request of encoder to writer, building it in scratch when encoder is a ScratchEncoder.
+func Encode(encoder LinkEncoder, writer Writer, scratch *bytes.Buffer) error {
+	if se, ok := encoder.(ScratchEncoder); ok {
+		scratch.Reset()
+		return se.EncodeWithScratch(writer, scratch)
+	}
+	return encoder.Encode(writer)
+}
+
+// ResponseClaimer is implemented by encoders whose requests carry a token echoed back in their response, like the
+// memcached opaque. It lets the connection recognize responses which don't belong to any pending link, e.g. after a
+// race on reconnect, rather than decoding them for the next link.
+type ResponseClaimer interface {
+	// SkipUnclaimed discards the responses at the head of reader which don't belong to the request, and returns
+	// their header lines. It reads nothing when the next response belongs to the request.
+	SkipUnclaimed(reader Reader) ([]string, error)
+}
+
+// RequestDescriber is implemented by encoders able to describe their request, for diagnostics.
+type RequestDescriber interface {
+	// Describe returns the name of the operation and the key it targets, empty if it doesn't target a key. Keys may
+	// be sensitive and should be redacted before being logged.
+	Describe() (operation string, key string)
+}
+
+// IdempotentRequest is implemented by encoders which know whether sending their request more than once leaves the
+// server in the same state as sending it once.
+type IdempotentRequest interface {
+	Idempotent() bool
+}
+
+// MetricLabel is a label of the metrics of a request.
+type MetricLabel struct {
+	Name  string
+	Value string
+}
+
+// OperationLabeler is implemented by encoders naming their operation for the metrics, so that the requests of a new
+// codec are measured without changes to the client. The labels must have a bounded cardinality, e.g. a size bucket,
+// and never carry a key or a value.
+type OperationLabeler interface {
+	// OperationLabels returns the name of the operation and the labels of the request, ordered by name.
+	OperationLabels() (operation string, labels []MetricLabel)
+}
+
 type Link interface {
 	Encoder() LinkEncoder
 	Decoder() LinkDecoder
@@ -43,11 +121,37 @@ type Chain interface {
 	Append(link Link) error
 }
 
+// BackendRecorder is implemented by links remembering the backend their request was sent to, for diagnostics.
+type BackendRecorder interface {
+	// SetBackend is called with the address of the backend by the connection accepting the link, before queuing it.
+	SetBackend(addr string)
+	// Backend returns the address of the backend, empty if no connection accepted the link.
+	Backend() string
+}
+
+// Abandoner is implemented by links whose caller can stop waiting for them, e.g. because its context ended, while
+// the layers below still complete them.
+type Abandoner interface {
+	// Abandon records cause as the reason the caller stopped waiting for the link, and calls completed with the error
+	// of the link once it completes, right away if it already did. Only the first call has an effect.
+	Abandon(cause error, completed func(err error))
+	// AbandonCause returns the cause given to Abandon, nil if the link wasn't abandoned.
+	AbandonCause() error
+}
+
 type GenericLink struct {
-	e    LinkEncoder
-	d    LinkDecoder
-	err  error
-	done chan struct{}
+	e       LinkEncoder
+	d       LinkDecoder
+	hint    RoutingHint
+	backend string
+	err     error
+	done    chan struct{}
+
+	mu sync.Mutex
+	// protected by mu
+	abandonCause error
+	// protected by mu
+	onAbandonedCompletion func(err error)
 }
 
 func (g *GenericLink) Err() error {
@@ -67,11 +171,61 @@ func (g *GenericLink) Done() <-chan struct{} {
 }
 
 func (g *GenericLink) Complete(err error) {
+	g.mu.Lock()
 	g.err = err
 	close(g.done)
+	completed := g.onAbandonedCompletion
+	g.onAbandonedCompletion = nil
+	g.mu.Unlock()
+
+	if completed != nil {
+		completed(err)
+	}
+}
+
+func (g *GenericLink) Abandon(cause error, completed func(err error)) {
+	g.mu.Lock()
+	if g.abandonCause != nil {
+		g.mu.Unlock()
+		return
+	}
+	g.abandonCause = cause
+	select {
+	case <-g.done:
+	default:
+		g.onAbandonedCompletion = completed
+		g.mu.Unlock()
+		return
+	}
+	g.mu.Unlock()
+
+	if completed != nil {
+		completed(g.err)
+	}
+}
+
+func (g *GenericLink) AbandonCause() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.abandonCause
+}
+
+func (g *GenericLink) RoutingHint() RoutingHint {
+	return g.hint
+}
+
+func (g *GenericLink) SetBackend(addr string) {
+	g.backend = addr
+}
+
+func (g *GenericLink) Backend() string {
+	return g.backend
 }
 
 var _ Link = (*GenericLink)(nil)
+var _ RoutedLink = (*GenericLink)(nil)
+var _ BackendRecorder = (*GenericLink)(nil)
+var _ Abandoner = (*GenericLink)(nil)
 
 func NewGenericLink(e LinkEncoder, d LinkDecoder) Link {
 	return &GenericLink{
@@ -81,3 +235,23 @@ func NewGenericLink(e LinkEncoder, d LinkDecoder) Link {
 		done: make(chan struct{}),
 	}
 }
+
+// NewRoutedLink is like NewGenericLink, with a hint telling the pool where to send the link.
+func NewRoutedLink(e LinkEncoder, d LinkDecoder, hint RoutingHint) Link {
+	return &GenericLink{
+		e:    e,
+		d:    d,
+		hint: hint,
+		done: make(chan struct{}),
+	}
+}
+
+// EncodeToString returns the exact bytes e writes on the wire, e.g. to check what a configured encoder sends, or to
+// diff it across versions of the library.
+func EncodeToString(e LinkEncoder) (string, error) {
+	var b bytes.Buffer
+	if err := e.Encode(&b); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,206 @@
+package memcache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/stripe/memlink/codec"
+)
+
+var errBarrierGroupMismatch = errors.New("a barrier group needs one decoder per encoder")
+
+// BarrierGroup is a group of pipelined requests followed by an mn barrier. memcached answers the requests of a
+// connection in order, so once the response to the barrier is read, every request of the group was processed by the
+// server and its response, if any, was decoded.
+//
+// The decoders are read in order, one response each, so every request of a group must get a response: quiet
+// requests, only answered when they fail, can't be part of one.
+type BarrierGroup struct {
+	Encoders []codec.LinkEncoder
+	Decoders []codec.LinkDecoder
+
+	done chan struct{}
+	err  error
+}
+
+func NewBarrierGroup() *BarrierGroup {
+	return &BarrierGroup{done: make(chan struct{})}
+}
+
+// Add appends a request and the decoder of its response to the group.
+func (g *BarrierGroup) Add(encoder codec.LinkEncoder, decoder codec.LinkDecoder) {
+	g.Encoders = append(g.Encoders, encoder)
+	g.Decoders = append(g.Decoders, decoder)
+}
+
+// Done is closed once the response to the barrier of the group was read, or the group failed.
+func (g *BarrierGroup) Done() <-chan struct{} {
+	return g.done
+}
+
+// Err returns why the group failed, once Done is closed. The requests of a failed group may have been applied.
+func (g *BarrierGroup) Err() error {
+	return g.err
+}
+
+// Wait waits until the group is complete and returns its error, or the error of ctx if it's done first.
+func (g *BarrierGroup) Wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-g.done:
+		return g.err
+	}
+}
+
+func (g *BarrierGroup) complete(err error) {
+	g.err = err
+	close(g.done)
+}
+
+func (g *BarrierGroup) completed() bool {
+	select {
+	case <-g.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// BarrierEncoder pipelines groups of requests, writing an mn barrier after each of them.
+type BarrierEncoder struct {
+	Groups []*BarrierGroup
+}
+
+func (e *BarrierEncoder) Encode(writer codec.Writer) error {
+	for _, group := range e.Groups {
+		for _, encoder := range group.Encoders {
+			if err := encoder.Encode(writer); err != nil {
+				return err
+			}
+		}
+		if _, err := writer.Write(NoOpRequest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *BarrierEncoder) EncodeWithScratch(writer codec.Writer, scratch *bytes.Buffer) error {
+	for _, group := range e.Groups {
+		for _, encoder := range group.Encoders {
+			if err := codec.Encode(encoder, writer, scratch); err != nil {
+				return err
+			}
+		}
+		if _, err := writer.Write(NoOpRequest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *BarrierEncoder) Reset() {
+	if e == nil {
+		return
+	}
+	e.Groups = e.Groups[:0]
+}
+
+// Validate checks that every group has a decoder per request.
+func (e *BarrierEncoder) Validate() error {
+	for i, group := range e.Groups {
+		if len(group.Encoders) != len(group.Decoders) {
+			return fmt.Errorf("group %d has %d encoders and %d decoders: %w", i, len(group.Encoders), len(group.Decoders), errBarrierGroupMismatch)
+		}
+	}
+	return nil
+}
+
+func (e *BarrierEncoder) Describe() (string, string) {
+	return fmt.Sprintf("pipeline x%d", len(e.Groups)), ""
+}
+
+// OperationLabels names the operation "pipeline", the number of requests of all groups being bucketed.
+func (e *BarrierEncoder) OperationLabels() (string, []codec.MetricLabel) {
+	n := 0
+	for _, group := range e.Groups {
+		n += len(group.Encoders)
+	}
+	return "pipeline", []codec.MetricLabel{{Name: batchSizeLabel, Value: bucket(batchSizeBuckets, n)}}
+}
+
+// Idempotent reports whether every request of every group is idempotent.
+func (e *BarrierEncoder) Idempotent() bool {
+	for _, group := range e.Groups {
+		for _, encoder := range group.Encoders {
+			if !isIdempotent(encoder) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var _ codec.LinkEncoder = (*BarrierEncoder)(nil)
+var _ codec.ScratchEncoder = (*BarrierEncoder)(nil)
+var _ codec.RequestDescriber = (*BarrierEncoder)(nil)
+var _ codec.OperationLabeler = (*BarrierEncoder)(nil)
+var _ codec.IdempotentRequest = (*BarrierEncoder)(nil)
+
+// BarrierDecoder decodes the responses of a BarrierEncoder, completing each group as soon as the response to its
+// barrier is read, so that callers can use the responses of a group while the next ones are still being read.
+type BarrierDecoder struct {
+	Groups []*BarrierGroup
+}
+
+func (d *BarrierDecoder) Decode(reader codec.Reader) error {
+	for i, group := range d.Groups {
+		if err := decodeGroup(group, reader); err != nil {
+			// the responses of the next groups can't be told apart anymore.
+			d.fail(i, err)
+			return err
+		}
+		group.complete(nil)
+	}
+	return nil
+}
+
+func decodeGroup(group *BarrierGroup, reader codec.Reader) error {
+	for _, decoder := range group.Decoders {
+		if err := decoder.Decode(reader); err != nil {
+			return err
+		}
+	}
+	return ReadMNResp(reader)
+}
+
+// Fail completes the groups which aren't complete yet with err, e.g. when the request was never written. It must not
+// be called while Decode may run.
+func (d *BarrierDecoder) Fail(err error) {
+	d.fail(0, err)
+}
+
+func (d *BarrierDecoder) fail(from int, err error) {
+	for _, group := range d.Groups[from:] {
+		if !group.completed() {
+			group.complete(err)
+		}
+	}
+}
+
+func (d *BarrierDecoder) Reset() {
+	if d == nil {
+		return
+	}
+	d.Groups = d.Groups[:0]
+}
+
+var _ codec.LinkDecoder = (*BarrierDecoder)(nil)
+
+// CreateBarrierPipeline creates the encoder and the decoder of a pipeline of groups.
+func CreateBarrierPipeline(groups ...*BarrierGroup) (*BarrierEncoder, *BarrierDecoder) {
+	return &BarrierEncoder{Groups: groups}, &BarrierDecoder{Groups: groups}
+}
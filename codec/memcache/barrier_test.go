@@ -0,0 +1,84 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBarrierGetGroup(keys ...string) *BarrierGroup {
+	group := NewBarrierGroup()
+	for _, key := range keys {
+		encoder := CreateMetaGetEncoder()
+		encoder.Reset()
+		encoder.Key = key
+		encoder.FetchValue = true
+		group.Add(encoder, CreateMetaGetDecoder())
+	}
+	return group
+}
+
+func TestBarrierEncoder(t *testing.T) {
+	encoder, _ := CreateBarrierPipeline(newBarrierGetGroup("a", "b"), newBarrierGetGroup("c"))
+
+	var b bytes.Buffer
+	require.NoError(t, encoder.Encode(&b))
+	assert.Equal(t, "mg a v \r\nmg b v \r\nmn\r\nmg c v \r\nmn\r\n", b.String())
+
+	var scratched bytes.Buffer
+	require.NoError(t, encoder.EncodeWithScratch(&scratched, &bytes.Buffer{}))
+	assert.Equal(t, b.String(), scratched.String())
+
+	operation, _ := encoder.Describe()
+	assert.Equal(t, "pipeline x2", operation)
+	assert.True(t, encoder.Idempotent())
+}
+
+func TestBarrierEncoderValidate(t *testing.T) {
+	group := newBarrierGetGroup("a")
+	group.Decoders = nil
+	encoder, _ := CreateBarrierPipeline(group)
+	assert.ErrorIs(t, encoder.Validate(), errBarrierGroupMismatch)
+}
+
+func TestBarrierDecoderCompletesGroupsInOrder(t *testing.T) {
+	first, second := newBarrierGetGroup("a"), newBarrierGetGroup("b")
+	_, decoder := CreateBarrierPipeline(first, second)
+
+	// the response to the second group is still missing, only the first group completes.
+	reader := bufio.NewReader(strings.NewReader("VA 1 \r\n1\r\nMN\r\nEN\r\n"))
+	assert.Error(t, decoder.Decode(reader))
+
+	require.NoError(t, first.Wait(context.Background()))
+	assert.Equal(t, []byte("1"), first.Decoders[0].(*MetaGetDecoder).Value)
+	assert.Error(t, second.Wait(context.Background()))
+}
+
+func TestBarrierDecoderFail(t *testing.T) {
+	first, second := newBarrierGetGroup("a"), newBarrierGetGroup("b")
+	_, decoder := CreateBarrierPipeline(first, second)
+	require.NoError(t, decoder.Decode(bufio.NewReader(strings.NewReader("EN\r\nMN\r\nEN\r\nMN\r\n"))))
+
+	// completed groups keep their outcome.
+	decoder.Fail(errors.New("lost"))
+	assert.NoError(t, first.Err())
+	assert.NoError(t, second.Err())
+
+	pending := newBarrierGetGroup("c")
+	_, decoder = CreateBarrierPipeline(pending)
+	lost := errors.New("lost")
+	decoder.Fail(lost)
+	assert.ErrorIs(t, pending.Wait(context.Background()), lost)
+}
+
+func TestBarrierUnsupportedByClassicProtocol(t *testing.T) {
+	encoder, decoder := CreateBarrierPipeline(newBarrierGetGroup("a"))
+	_, _, err := ClassicCodec(encoder, decoder)
+	assert.ErrorIs(t, err, ErrUnsupportedByClassicProtocol)
+}
@@ -1,7 +1,7 @@
 package memcache
 
 import (
-	"bufio"
+	"bytes"
 
 	"github.com/stripe/memlink/codec"
 )
@@ -18,7 +18,7 @@ type BulkEncoder[T codec.LinkEncoder] struct {
 	Opaque uint64
 }
 
-func (e *BulkEncoder[T]) Encode(writer *bufio.Writer) error {
+func (e *BulkEncoder[T]) Encode(writer codec.Writer) error {
 	for _, encoder := range e.Encoders {
 		if err := encoder.Encode(writer); err != nil {
 			return err
@@ -30,6 +30,17 @@ func (e *BulkEncoder[T]) Encode(writer *bufio.Writer) error {
 	return err
 }
 
+func (e *BulkEncoder[T]) EncodeWithScratch(writer codec.Writer, scratch *bytes.Buffer) error {
+	for _, encoder := range e.Encoders {
+		if err := codec.Encode(encoder, writer, scratch); err != nil {
+			return err
+		}
+	}
+
+	_, err := writer.Write(NoOpRequest)
+	return err
+}
+
 func (e *BulkEncoder[T]) Reset() {
 	if e == nil {
 		return
@@ -38,6 +49,7 @@ func (e *BulkEncoder[T]) Reset() {
 }
 
 var _ codec.LinkEncoder = (*BulkEncoder[*MetaGetEncoder])(nil)
+var _ codec.ScratchEncoder = (*BulkEncoder[*MetaGetEncoder])(nil)
 
 // BulkDecoder wraps multiple Decoders of type codec.LinkDecoder to decode multiple responses
 type BulkDecoder[T codec.LinkDecoder] struct {
@@ -47,7 +59,7 @@ type BulkDecoder[T codec.LinkDecoder] struct {
 	OpaqueToKey map[uint64]string
 }
 
-func (d *BulkDecoder[T]) Decode(reader *bufio.Reader) error {
+func (d *BulkDecoder[T]) Decode(reader codec.Reader) error {
 	for _, decoder := range d.Decoders {
 		// TODO(hemal): based on a recent discovery we probably should read till the very end of the decoders
 		// Though - this might end up being a no-op from the bulk operation method if the underlying single
@@ -69,6 +81,50 @@ func (d *BulkDecoder[T]) Reset() {
 
 var _ codec.LinkDecoder = (*BulkDecoder[*MetaGetDecoder])(nil)
 
+// QuietBulkDecoder decodes the responses of a BulkEncoder whose requests use quiet mode. Quiet requests only get a
+// response when they fail, so the number of responses isn't known upfront: responses are decoded until the trailing
+// MN response, each into a new decoder obtained from NewDecoder and appended to Decoders. Callers should set opaque
+// tokens on the requests to map the responses back to them.
+type QuietBulkDecoder[T codec.LinkDecoder] struct {
+	Decoders   []T
+	NewDecoder func() T
+
+	// interner is the Interner of the decoders created while decoding, see InternStrings.
+	interner *Interner
+}
+
+func (d *QuietBulkDecoder[T]) Decode(reader codec.Reader) error {
+	for {
+		prefix, err := reader.Peek(len(NoOpResponse))
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(prefix, NoOpResponse) {
+			return ReadMNResp(reader)
+		}
+
+		decoder := d.NewDecoder()
+		if d.interner != nil {
+			InternStrings(decoder, d.interner)
+		}
+		d.Decoders = append(d.Decoders, decoder)
+		if err := decoder.Decode(reader); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *QuietBulkDecoder[T]) Reset() {
+	if d == nil {
+		return
+	}
+	d.Decoders = d.Decoders[:0]
+	d.interner = nil
+}
+
+var _ codec.LinkDecoder = (*QuietBulkDecoder[*MetaSetDecoder])(nil)
+
 type BulkTarget[T codec.LinkDecoder] func(decoder *BulkDecoder[T]) error
 
 func CreateBulkEncoder[T codec.LinkEncoder](size uint) *BulkEncoder[T] {
@@ -83,3 +139,9 @@ func CreateBulkDecoder[T codec.LinkDecoder](size uint) *BulkDecoder[T] {
 		OpaqueToKey: make(map[uint64]string, size),
 	}
 }
+
+func CreateQuietBulkDecoder[T codec.LinkDecoder](newDecoder func() T) *QuietBulkDecoder[T] {
+	return &QuietBulkDecoder[T]{
+		NewDecoder: newDecoder,
+	}
+}
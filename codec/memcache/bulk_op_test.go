@@ -46,3 +46,28 @@ func Test_BulkGetDecoder_ErrorPath(t *testing.T) {
 		})
 	}
 }
+
+func Test_QuietBulkDecoder_HappyPath(t *testing.T) {
+	data := bytes.NewBufferString("NS O2\r\nEX O4\r\nMN\r\n")
+
+	decoder := CreateQuietBulkDecoder(CreateMetaSetDecoder)
+	err := decoder.Decode(bufio.NewReader(data))
+	assert.NoError(t, err)
+	assert.Len(t, decoder.Decoders, 2)
+	assert.Equal(t, uint64(2), decoder.Decoders[0].Opaque)
+	assert.Equal(t, NotStored, decoder.Decoders[0].Status)
+	assert.Equal(t, uint64(4), decoder.Decoders[1].Opaque)
+	assert.Equal(t, Exists, decoder.Decoders[1].Status)
+	assert.Zero(t, data.Len())
+
+	decoder.Reset()
+	assert.Empty(t, decoder.Decoders)
+	assert.NoError(t, decoder.Decode(bufio.NewReader(bytes.NewBufferString("MN\r\n"))))
+	assert.Empty(t, decoder.Decoders)
+}
+
+func Test_QuietBulkDecoder_ErrorPath(t *testing.T) {
+	decoder := CreateQuietBulkDecoder(CreateMetaSetDecoder)
+	err := decoder.Decode(bufio.NewReader(bytes.NewBufferString("NS O2\r\n")))
+	assert.Error(t, err)
+}
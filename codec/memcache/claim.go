@@ -0,0 +1,128 @@
+package memcache
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// skipUnclaimed discards the meta responses at the head of reader whose opaque token isn't claimed. Responses without
+// an opaque token, e.g. errors or MN, can't be attributed and are left for the decoder.
+func skipUnclaimed(reader codec.Reader, claims func(opaque uint64) bool) ([]string, error) {
+	var skipped []string
+	for {
+		hdrLine, err := peekLine(reader)
+		if err != nil {
+			return skipped, err
+		}
+
+		opaque, ok := responseOpaque(hdrLine)
+		if !ok || claims(opaque) {
+			return skipped, nil
+		}
+
+		skipped = append(skipped, string(hdrLine))
+		if err := discardResponse(reader, hdrLine); err != nil {
+			return skipped, err
+		}
+	}
+}
+
+// peekLine returns the next line of reader, including the trailing \n, without consuming it.
+func peekLine(reader codec.Reader) ([]byte, error) {
+	n := max(reader.Buffered(), 1)
+	for {
+		b, err := reader.Peek(n)
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			return b[:i+1], nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		n = max(reader.Buffered(), n+1)
+	}
+}
+
+func responseOpaque(hdrLine []byte) (uint64, bool) {
+	for idx, elem := range bytes.Fields(hdrLine) {
+		if idx == 0 || len(elem) < 2 || elem[0] != 'O' {
+			continue
+		}
+
+		o, err := strconv.ParseUint(string(elem[1:]), 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return o, true
+	}
+	return 0, false
+}
+
+// discardResponse consumes the response starting with hdrLine, including the data block of VA responses.
+func discardResponse(reader codec.Reader, hdrLine []byte) error {
+	size := len(hdrLine)
+	fields := bytes.Fields(hdrLine)
+	if len(fields) > 1 && bytes.Equal(fields[0], ValueHeader) {
+		valueSize, err := strconv.Atoi(string(fields[1]))
+		if err != nil {
+			return err
+		}
+		size += valueSize + len(CRLF)
+	}
+
+	_, err := io.CopyN(io.Discard, reader, int64(size))
+	return err
+}
+
+func (e *MetaGetEncoder) SkipUnclaimed(reader codec.Reader) ([]string, error) {
+	return skipUnclaimedOpaques(reader, e.Opaque)
+}
+
+func (e *MetaSetEncoder) SkipUnclaimed(reader codec.Reader) ([]string, error) {
+	return skipUnclaimedOpaques(reader, e.Opaque)
+}
+
+func (e *MetaDeleteEncoder) SkipUnclaimed(reader codec.Reader) ([]string, error) {
+	return skipUnclaimedOpaques(reader, e.Opaque)
+}
+
+func (e *MetaArithmeticEncoder) SkipUnclaimed(reader codec.Reader) ([]string, error) {
+	return skipUnclaimedOpaques(reader, e.Opaque)
+}
+
+func (e *MetaGetEncoder) requestOpaque() uint64        { return e.Opaque }
+func (e *MetaSetEncoder) requestOpaque() uint64        { return e.Opaque }
+func (e *MetaDeleteEncoder) requestOpaque() uint64     { return e.Opaque }
+func (e *MetaArithmeticEncoder) requestOpaque() uint64 { return e.Opaque }
+
+// SkipUnclaimed skips the responses preceding the ones of the bulk request, i.e. whose opaque token doesn't match any
+// of the wrapped requests.
+func (e *BulkEncoder[T]) SkipUnclaimed(reader codec.Reader) ([]string, error) {
+	opaques := make([]uint64, 0, len(e.Encoders))
+	for _, encoder := range e.Encoders {
+		if o, ok := any(encoder).(interface{ requestOpaque() uint64 }); ok {
+			opaques = append(opaques, o.requestOpaque())
+		}
+	}
+	return skipUnclaimedOpaques(reader, opaques...)
+}
+
+// skipUnclaimedOpaques skips the responses whose opaque token isn't one of opaques. Requests without an opaque token
+// can't tell their responses apart, so nothing is skipped when one of them doesn't have one.
+func skipUnclaimedOpaques(reader codec.Reader, opaques ...uint64) ([]string, error) {
+	if len(opaques) == 0 || slices.Contains(opaques, 0) {
+		return nil, nil
+	}
+	return skipUnclaimed(reader, func(opaque uint64) bool {
+		return slices.Contains(opaques, opaque)
+	})
+}
+
+var _ codec.ResponseClaimer = (*MetaGetEncoder)(nil)
+var _ codec.ResponseClaimer = (*MetaSetEncoder)(nil)
+var _ codec.ResponseClaimer = (*MetaDeleteEncoder)(nil)
+var _ codec.ResponseClaimer = (*MetaArithmeticEncoder)(nil)
+var _ codec.ResponseClaimer = (*BulkEncoder[*MetaGetEncoder])(nil)
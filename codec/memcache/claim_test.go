@@ -0,0 +1,92 @@
+package memcache
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SkipUnclaimed(t *testing.T) {
+	targs := []struct {
+		name            string
+		opaque          uint64
+		response        string
+		expectedSkipped []string
+		expectedRest    string
+	}{
+		{
+			name:         "claimed response",
+			opaque:       7,
+			response:     "HD O7\r\n",
+			expectedRest: "HD O7\r\n",
+		},
+		{
+			name:            "stray responses with and without value",
+			opaque:          7,
+			response:        "VA 3 O5\r\nabc\r\nHD O6\r\nHD O7\r\n",
+			expectedSkipped: []string{"VA 3 O5\r\n", "HD O6\r\n"},
+			expectedRest:    "HD O7\r\n",
+		},
+		{
+			name:         "response without opaque can't be attributed",
+			opaque:       7,
+			response:     "SERVER_ERROR out of memory\r\n",
+			expectedRest: "SERVER_ERROR out of memory\r\n",
+		},
+		{
+			name:         "request without opaque",
+			opaque:       0,
+			response:     "HD O6\r\n",
+			expectedRest: "HD O6\r\n",
+		},
+	}
+
+	for _, tt := range targs {
+		t.Run(tt.name, func(t *testing.T) {
+			encoder := CreateMetaGetEncoder()
+			encoder.Reset()
+			encoder.Opaque = tt.opaque
+
+			reader := bufio.NewReader(strings.NewReader(tt.response))
+			skipped, err := encoder.SkipUnclaimed(reader)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedSkipped, skipped)
+
+			rest, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedRest, string(rest))
+		})
+	}
+}
+
+func Test_SkipUnclaimed_Bulk(t *testing.T) {
+	bulkEncoder := CreateBulkEncoder[*MetaGetEncoder](2)
+	for _, opaque := range []uint64{10, 11} {
+		encoder := CreateMetaGetEncoder()
+		encoder.Reset()
+		encoder.Opaque = opaque
+		bulkEncoder.Encoders = append(bulkEncoder.Encoders, encoder)
+	}
+
+	reader := bufio.NewReader(strings.NewReader("EN O3\r\nEN O11\r\nEN O10\r\nMN\r\n"))
+	skipped, err := bulkEncoder.SkipUnclaimed(reader)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"EN O3\r\n"}, skipped)
+
+	rest, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "EN O11\r\nEN O10\r\nMN\r\n", string(rest))
+}
+
+func Test_SkipUnclaimed_IncompleteResponse(t *testing.T) {
+	encoder := CreateMetaDeleteEncoder()
+	encoder.Reset()
+	encoder.Opaque = 7
+
+	_, err := encoder.SkipUnclaimed(bufio.NewReader(strings.NewReader("HD O7")))
+	assert.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,723 @@
+package memcache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// ErrUnsupportedByClassicProtocol is returned by ClassicCodec when a request uses a meta flag which has no
+// equivalent in the classic text protocol.
+var ErrUnsupportedByClassicProtocol = errors.New("request can't be expressed with the classic protocol")
+
+var (
+	classicValue    = []byte("VALUE")
+	classicEnd      = []byte("END")
+	classicStored   = []byte("STORED")
+	classicNotStore = []byte("NOT_STORED")
+	classicExists   = []byte("EXISTS")
+	classicNotFound = []byte("NOT_FOUND")
+	classicDeleted  = []byte("DELETED")
+	classicTouched  = []byte("TOUCHED")
+)
+
+// classicReplyStatus returns the status of a reply which isn't one the request expects: the classic protocol shares the
+// error replies of the meta one, anything else is MetadataStatusInvalid.
+func classicReplyStatus(trimmed []byte) MetadataStatus {
+	code, _, _ := bytes.Cut(trimmed, []byte{Space})
+	return replyStatusFromHeader(code)
+}
+
+/*
+ClassicCodec translates a meta protocol request to the classic text protocol understood by memcached versions older
+than 1.6 and by proxies which don't forward meta commands:
+
+- MetaGetEncoder: get, gets, gat or gats, or touch when the request only updates the TTL
+- MetaSetEncoder: set, add, replace, append, prepend or cas
+- MetaDeleteEncoder: delete
+- MetaArithmeticEncoder: incr or decr
+- BulkEncoder and QuietBulkDecoder of the above: the translated requests are pipelined without the trailing mn.
+- BarrierEncoder: fails with ErrUnsupportedByClassicProtocol, the classic protocol has no barrier to separate groups.
+
+The responses are decoded into the given meta decoders, so callers don't need to know which protocol was used. The
+opaque tokens are copied from the requests since the classic protocol doesn't echo them. Quiet sets are sent as
+regular sets, and only their failures are added to the QuietBulkDecoder.
+
+Requests of any other type are returned as is. Requests using meta flags without a classic equivalent, e.g. fetching
+the remaining TTL, fail with ErrUnsupportedByClassicProtocol before anything is sent.
+*/
+func ClassicCodec(e codec.LinkEncoder, d codec.LinkDecoder) (codec.LinkEncoder, codec.LinkDecoder, error) {
+	switch encoder := e.(type) {
+	case *MetaGetEncoder:
+		decoder, ok := d.(*MetaGetDecoder)
+		if !ok {
+			return nil, nil, fmt.Errorf("classic codec: unexpected decoder %T for %T", d, e)
+		}
+		return classicGet(encoder, decoder)
+	case *MetaSetEncoder:
+		decoder, ok := d.(*MetaSetDecoder)
+		if !ok {
+			return nil, nil, fmt.Errorf("classic codec: unexpected decoder %T for %T", d, e)
+		}
+		return classicSet(encoder, decoder)
+	case *MetaDeleteEncoder:
+		decoder, ok := d.(*MetaDeleteDecoder)
+		if !ok {
+			return nil, nil, fmt.Errorf("classic codec: unexpected decoder %T for %T", d, e)
+		}
+		return classicDelete(encoder, decoder)
+	case *MetaArithmeticEncoder:
+		decoder, ok := d.(*MetaArithmeticDecoder)
+		if !ok {
+			return nil, nil, fmt.Errorf("classic codec: unexpected decoder %T for %T", d, e)
+		}
+		return classicArithmetic(encoder, decoder)
+	case *BulkEncoder[*MetaGetEncoder]:
+		decoder, ok := d.(*BulkDecoder[*MetaGetDecoder])
+		if !ok {
+			return nil, nil, fmt.Errorf("classic codec: unexpected decoder %T for %T", d, e)
+		}
+		return classicBulk(encoder.Encoders, decoder.Decoders)
+	case *BulkEncoder[*MetaDeleteEncoder]:
+		decoder, ok := d.(*BulkDecoder[*MetaDeleteDecoder])
+		if !ok {
+			return nil, nil, fmt.Errorf("classic codec: unexpected decoder %T for %T", d, e)
+		}
+		return classicBulk(encoder.Encoders, decoder.Decoders)
+	case *BulkEncoder[*MetaSetEncoder]:
+		decoder, ok := d.(*QuietBulkDecoder[*MetaSetDecoder])
+		if !ok {
+			return nil, nil, fmt.Errorf("classic codec: unexpected decoder %T for %T", d, e)
+		}
+		return classicQuietBulkSet(encoder.Encoders, decoder)
+	case *BarrierEncoder:
+		return nil, nil, unsupportedByClassic("pipeline", "mn barriers")
+	case *RawEncoder:
+		return nil, nil, unsupportedByClassic("raw", "its mn terminator")
+	}
+	return e, d, nil
+}
+
+func unsupportedByClassic(command string, flag string) error {
+	return fmt.Errorf("%s with %s: %w", command, flag, ErrUnsupportedByClassicProtocol)
+}
+
+// classicKey returns the key to send in a classic request, binary keys can't be sent as the classic protocol doesn't
+// support base64 encoded keys.
+func classicKey(command string, key string, validated Key, base64Key bool) (string, error) {
+	if base64Key || (!validated.IsZero() && validated.Base64()) {
+		return "", unsupportedByClassic(command, "a base64 encoded key")
+	}
+
+	if !validated.IsZero() {
+		return validated.Wire(), nil
+	}
+
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// readClassicLine reads a response line and returns it without the trailing CRLF.
+func readClassicLine(reader codec.Reader) ([]byte, []byte, error) {
+	line, err := reader.ReadSlice('\n')
+	if err != nil {
+		return nil, nil, err
+	}
+	return line, bytes.TrimRight(line, "\r\n"), nil
+}
+
+type classicGetEncoder struct {
+	meta *MetaGetEncoder
+	key  string
+}
+
+func classicGet(e *MetaGetEncoder, d *MetaGetDecoder) (codec.LinkEncoder, codec.LinkDecoder, error) {
+	switch {
+	case e.FetchRemainingTTL:
+		return nil, nil, unsupportedByClassic("get", "t flag")
+	case e.FetchItemHitBefore:
+		return nil, nil, unsupportedByClassic("get", "h flag")
+	case e.FetchLastAccessedTime:
+		return nil, nil, unsupportedByClassic("get", "l flag")
+	case e.CasOverride != 0:
+		return nil, nil, unsupportedByClassic("get", "E flag")
+	case e.BlockTTL >= 0:
+		return nil, nil, unsupportedByClassic("get", "N flag")
+	case e.RecacheTTL >= 0:
+		return nil, nil, unsupportedByClassic("get", "R flag")
+	}
+
+	key, err := classicKey("get", e.Key, e.ValidatedKey, e.Base64EncodedKey || e.BinaryKey != nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &classicGetEncoder{meta: e, key: key}, &classicGetDecoder{encoder: e, meta: d}, nil
+}
+
+// Encode writes a get or gets request, or gat and gats when the request updates the TTL, or touch when it only updates
+// the TTL. The u flag is ignored since classic reads always bump the item in the LRU.
+func (e *classicGetEncoder) Encode(writer codec.Writer) error {
+	b := bytePool.Get()
+	defer bytePool.Put(b)
+	return e.EncodeWithScratch(writer, b)
+}
+
+func (e *classicGetEncoder) EncodeWithScratch(writer codec.Writer, b *bytes.Buffer) error {
+	if classicTouch(e.meta) {
+		b.WriteString("touch ")
+		b.WriteString(e.key)
+		b.WriteByte(Space)
+		b.Write(strconv.AppendInt(b.AvailableBuffer(), int64(wireExpiry(e.meta.UpdateTTL, e.meta.UpdateExpireAt, e.meta.ConvertLongTTLs)), 10))
+		b.Write(CRLF)
+
+		_, err := writer.Write(b.Bytes())
+		return err
+	}
+
+	if e.meta.updatesTTL() {
+		b.WriteString("gat")
+	} else {
+		b.WriteString("get")
+	}
+	if e.meta.FetchCasId {
+		b.WriteByte('s')
+	}
+	if e.meta.updatesTTL() {
+		b.WriteByte(Space)
+		b.Write(strconv.AppendInt(b.AvailableBuffer(), int64(wireExpiry(e.meta.UpdateTTL, e.meta.UpdateExpireAt, e.meta.ConvertLongTTLs)), 10))
+	}
+
+	b.WriteByte(Space)
+	b.WriteString(e.key)
+	b.Write(CRLF)
+
+	_, err := writer.Write(b.Bytes())
+	return err
+}
+
+func (e *classicGetEncoder) Reset() {
+	e.meta.Reset()
+}
+
+// classicTouch reports whether e only updates the TTL of the item, without fetching anything, which translates to a
+// touch request.
+func classicTouch(e *MetaGetEncoder) bool {
+	return e.updatesTTL() && !e.FetchValue && !e.FetchCasId && !e.FetchClientFlags && !e.FetchKey && !e.FetchItemSizeInBytes
+}
+
+type classicGetDecoder struct {
+	encoder *MetaGetEncoder
+	meta    *MetaGetDecoder
+}
+
+// Decode parses a "VALUE <key> <flags> <bytes> [<cas>]" response followed by the data block and END, or a lone END
+// on a miss, or TOUCHED and NOT_FOUND in response to a touch request.
+func (d *classicGetDecoder) Decode(reader codec.Reader) error {
+	line, trimmed, err := readClassicLine(reader)
+	if err != nil {
+		return err
+	}
+
+	d.meta.Opaque = d.encoder.Opaque
+	if classicTouch(d.encoder) {
+		switch {
+		case bytes.Equal(trimmed, classicTouched):
+			d.meta.Status = CacheHit
+		case bytes.Equal(trimmed, classicNotFound):
+			d.meta.Status = CacheMiss
+		default:
+			d.meta.Status = classicReplyStatus(trimmed)
+			d.meta.HdrLine = d.meta.Interner.Intern(line)
+		}
+		return nil
+	}
+	if bytes.Equal(trimmed, classicEnd) {
+		d.meta.Status = CacheMiss
+		return nil
+	}
+
+	fields := bytes.Fields(trimmed)
+	if len(fields) < 4 || !bytes.Equal(fields[0], classicValue) {
+		d.meta.Status = classicReplyStatus(trimmed)
+		d.meta.HdrLine = d.meta.Interner.Intern(line)
+		return nil
+	}
+
+	clientFlags, err := strconv.ParseUint(string(fields[2]), 10, 32)
+	if err != nil {
+		return fmt.Errorf("classic_get::decoder - unable to parse client flags as an uint32 as the token is %s: %w", fields[2], err)
+	}
+	valueSize, err := strconv.Atoi(string(fields[3]))
+	if err != nil {
+		return fmt.Errorf("classic_get::decoder - unable to parse value size as the token is %s: %w", fields[3], err)
+	}
+	var casId uint64
+	if len(fields) > 4 {
+		if casId, err = strconv.ParseUint(string(fields[4]), 10, 64); err != nil {
+			return fmt.Errorf("classic_get::decoder - unable to parse casid as an uint64 as the token is %s: %w", fields[4], err)
+		}
+	}
+	itemKey := d.meta.Interner.Intern(fields[1])
+
+	value := make([]byte, valueSize)
+	if _, err := io.ReadFull(reader, value); err != nil {
+		return err
+	}
+	if err := ReadCLRF(reader); err != nil {
+		return err
+	}
+
+	_, trimmed, err = readClassicLine(reader)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(trimmed, classicEnd) {
+		return fmt.Errorf("classic_get::decoder - expected END after the value but got %q", trimmed)
+	}
+
+	d.meta.Status = CacheHit
+	if d.encoder.FetchValue {
+		d.meta.Value = value
+	}
+	if d.encoder.FetchKey {
+		d.meta.ItemKey = itemKey
+	}
+	if d.encoder.FetchClientFlags {
+		d.meta.ClientFlags = clientFlags
+	}
+	if d.encoder.FetchCasId {
+		d.meta.CasId = casId
+	}
+	if d.encoder.FetchItemSizeInBytes {
+		d.meta.ItemSizeInBytes = uint64(valueSize)
+	}
+	return nil
+}
+
+func (d *classicGetDecoder) Reset() {
+	d.meta.Reset()
+}
+
+type classicSetEncoder struct {
+	meta    *MetaSetEncoder
+	command string
+	key     string
+}
+
+func classicSet(e *MetaSetEncoder, d *MetaSetDecoder) (codec.LinkEncoder, codec.LinkDecoder, error) {
+	switch {
+	case e.FetchCasId:
+		return nil, nil, unsupportedByClassic("set", "c flag")
+	case e.FetchItemSize:
+		return nil, nil, unsupportedByClassic("set", "s flag")
+	case e.CasOverride != 0:
+		return nil, nil, unsupportedByClassic("set", "E flag")
+	case e.Invalidate:
+		return nil, nil, unsupportedByClassic("set", "I flag")
+	case e.BlockTTL >= 0:
+		return nil, nil, unsupportedByClassic("set", "N flag")
+	case e.ClientFlags > 0xFFFFFFFF:
+		return nil, nil, unsupportedByClassic("set", "client flags above 32 bits")
+	}
+
+	command := "set"
+	switch e.Mode {
+	case Add:
+		command = "add"
+	case Replace:
+		command = "replace"
+	case Append:
+		command = "append"
+	case Prepend:
+		command = "prepend"
+	}
+
+	if e.CasId != 0 {
+		if command != "set" {
+			return nil, nil, unsupportedByClassic(command, "C flag")
+		}
+		command = "cas"
+	}
+
+	key, err := classicKey(command, e.Key, e.ValidatedKey, e.Base64EncodedKey || e.BinaryKey != nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &classicSetEncoder{meta: e, command: command, key: key}, &classicSetDecoder{encoder: e, meta: d}, nil
+}
+
+// Encode writes a "<command> <key> <flags> <exptime> <bytes> [<cas>]" request followed by the data block.
+func (e *classicSetEncoder) Encode(writer codec.Writer) error {
+	b := bytePool.Get()
+	defer bytePool.Put(b)
+	return e.EncodeWithScratch(writer, b)
+}
+
+func (e *classicSetEncoder) EncodeWithScratch(writer codec.Writer, b *bytes.Buffer) error {
+
+	b.WriteString(e.command)
+	b.WriteByte(Space)
+	b.WriteString(e.key)
+	b.WriteByte(Space)
+	b.Write(strconv.AppendUint(b.AvailableBuffer(), e.meta.ClientFlags, 10))
+	b.WriteByte(Space)
+	b.Write(strconv.AppendInt(b.AvailableBuffer(), int64(wireExpiry(max(e.meta.TTL, 0), e.meta.ExpireAt, e.meta.ConvertLongTTLs)), 10))
+	b.WriteByte(Space)
+	b.Write(strconv.AppendInt(b.AvailableBuffer(), int64(len(e.meta.Value)), 10))
+	if e.meta.CasId != 0 {
+		b.WriteByte(Space)
+		b.Write(strconv.AppendUint(b.AvailableBuffer(), e.meta.CasId, 10))
+	}
+	b.Write(CRLF)
+	b.Write(e.meta.Value)
+	b.Write(CRLF)
+
+	_, err := writer.Write(b.Bytes())
+	return err
+}
+
+func (e *classicSetEncoder) Reset() {
+	e.meta.Reset()
+}
+
+type classicSetDecoder struct {
+	encoder *MetaSetEncoder
+	meta    *MetaSetDecoder
+}
+
+// Decode parses a STORED, NOT_STORED, EXISTS or NOT_FOUND response.
+func (d *classicSetDecoder) Decode(reader codec.Reader) error {
+	line, trimmed, err := readClassicLine(reader)
+	if err != nil {
+		return err
+	}
+
+	d.meta.Opaque = d.encoder.Opaque
+	switch {
+	case bytes.Equal(trimmed, classicStored):
+		d.meta.Status = Stored
+	case bytes.Equal(trimmed, classicNotStore):
+		d.meta.Status = NotStored
+	case bytes.Equal(trimmed, classicExists):
+		d.meta.Status = Exists
+	case bytes.Equal(trimmed, classicNotFound):
+		d.meta.Status = NotFound
+	default:
+		d.meta.Status = classicReplyStatus(trimmed)
+		d.meta.HdrLine = d.meta.Interner.Intern(line)
+	}
+	return nil
+}
+
+func (d *classicSetDecoder) Reset() {
+	d.meta.Reset()
+}
+
+type classicDeleteEncoder struct {
+	meta *MetaDeleteEncoder
+	key  string
+}
+
+func classicDelete(e *MetaDeleteEncoder, d *MetaDeleteDecoder) (codec.LinkEncoder, codec.LinkDecoder, error) {
+	switch {
+	case e.CasId != 0:
+		return nil, nil, unsupportedByClassic("delete", "C flag")
+	case e.CasOverride != 0:
+		return nil, nil, unsupportedByClassic("delete", "E flag")
+	case e.Invalidate:
+		return nil, nil, unsupportedByClassic("delete", "I flag")
+	case e.RemoveValue:
+		return nil, nil, unsupportedByClassic("delete", "x flag")
+	case e.TTL >= 0:
+		return nil, nil, unsupportedByClassic("delete", "T flag")
+	case e.ClientFlags != 0:
+		return nil, nil, unsupportedByClassic("delete", "F flag")
+	}
+
+	key, err := classicKey("delete", e.Key, e.ValidatedKey, e.Base64EncodedKey || e.BinaryKey != nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &classicDeleteEncoder{meta: e, key: key}, &classicDeleteDecoder{encoder: e, meta: d, key: key}, nil
+}
+
+func (e *classicDeleteEncoder) Encode(writer codec.Writer) error {
+	b := bytePool.Get()
+	defer bytePool.Put(b)
+	return e.EncodeWithScratch(writer, b)
+}
+
+func (e *classicDeleteEncoder) EncodeWithScratch(writer codec.Writer, b *bytes.Buffer) error {
+
+	b.WriteString("delete ")
+	b.WriteString(e.key)
+	b.Write(CRLF)
+
+	_, err := writer.Write(b.Bytes())
+	return err
+}
+
+func (e *classicDeleteEncoder) Reset() {
+	e.meta.Reset()
+}
+
+type classicDeleteDecoder struct {
+	encoder *MetaDeleteEncoder
+	meta    *MetaDeleteDecoder
+	key     string
+}
+
+// Decode parses a DELETED or NOT_FOUND response.
+func (d *classicDeleteDecoder) Decode(reader codec.Reader) error {
+	line, trimmed, err := readClassicLine(reader)
+	if err != nil {
+		return err
+	}
+
+	d.meta.Opaque = d.encoder.Opaque
+	switch {
+	case bytes.Equal(trimmed, classicDeleted):
+		d.meta.Status = Deleted
+	case bytes.Equal(trimmed, classicNotFound):
+		d.meta.Status = NotFound
+	default:
+		d.meta.Status = classicReplyStatus(trimmed)
+		d.meta.HdrLine = d.meta.Interner.Intern(line)
+		return nil
+	}
+
+	if d.encoder.FetchKey {
+		d.meta.ItemKey = d.key
+	}
+	return nil
+}
+
+func (d *classicDeleteDecoder) Reset() {
+	d.meta.Reset()
+}
+
+type classicArithmeticEncoder struct {
+	meta *MetaArithmeticEncoder
+	key  string
+}
+
+func classicArithmetic(e *MetaArithmeticEncoder, d *MetaArithmeticDecoder) (codec.LinkEncoder, codec.LinkDecoder, error) {
+	switch {
+	case e.CasId != 0:
+		return nil, nil, unsupportedByClassic("incr", "C flag")
+	case e.CasOverride != 0:
+		return nil, nil, unsupportedByClassic("incr", "E flag")
+	case e.BlockTTL >= 0:
+		return nil, nil, unsupportedByClassic("incr", "N flag")
+	case e.InitialValue != 0:
+		return nil, nil, unsupportedByClassic("incr", "J flag")
+	case e.TTL >= 0:
+		return nil, nil, unsupportedByClassic("incr", "T flag")
+	case e.FetchRemainingTTL:
+		return nil, nil, unsupportedByClassic("incr", "t flag")
+	case e.FetchCasId:
+		return nil, nil, unsupportedByClassic("incr", "c flag")
+	}
+
+	key, err := classicKey("incr", e.Key, e.ValidatedKey, e.Base64EncodedKey || e.BinaryKey != nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &classicArithmeticEncoder{meta: e, key: key}, &classicArithmeticDecoder{encoder: e, meta: d, key: key}, nil
+}
+
+func (e *classicArithmeticEncoder) Encode(writer codec.Writer) error {
+	b := bytePool.Get()
+	defer bytePool.Put(b)
+	return e.EncodeWithScratch(writer, b)
+}
+
+func (e *classicArithmeticEncoder) EncodeWithScratch(writer codec.Writer, b *bytes.Buffer) error {
+
+	if e.meta.Decrement {
+		b.WriteString("decr ")
+	} else {
+		b.WriteString("incr ")
+	}
+	b.WriteString(e.key)
+	b.WriteByte(Space)
+	b.Write(strconv.AppendUint(b.AvailableBuffer(), e.meta.Delta, 10))
+	b.Write(CRLF)
+
+	_, err := writer.Write(b.Bytes())
+	return err
+}
+
+func (e *classicArithmeticEncoder) Reset() {
+	e.meta.Reset()
+}
+
+type classicArithmeticDecoder struct {
+	encoder *MetaArithmeticEncoder
+	meta    *MetaArithmeticDecoder
+	key     string
+}
+
+// Decode parses the new value of the counter, or a NOT_FOUND response.
+func (d *classicArithmeticDecoder) Decode(reader codec.Reader) error {
+	line, trimmed, err := readClassicLine(reader)
+	if err != nil {
+		return err
+	}
+
+	d.meta.Opaque = d.encoder.Opaque
+	if bytes.Equal(trimmed, classicNotFound) {
+		d.meta.Status = NotFound
+		return nil
+	}
+
+	value, pErr := strconv.ParseUint(string(trimmed), 10, 64)
+	if pErr != nil {
+		d.meta.Status = classicReplyStatus(trimmed)
+		d.meta.HdrLine = d.meta.Interner.Intern(line)
+		return nil
+	}
+
+	d.meta.Status = Stored
+	if d.encoder.FetchValue {
+		d.meta.Value = []byte(string(trimmed))
+		d.meta.ValueUInt64 = value
+	}
+	if d.encoder.FetchKey {
+		d.meta.ItemKey = d.key
+	}
+	return nil
+}
+
+func (d *classicArithmeticDecoder) Reset() {
+	d.meta.Reset()
+}
+
+// classicBulkEncoder pipelines the translated requests of a BulkEncoder. Every classic request gets a response, so
+// there is no need for a trailing no-op request.
+type classicBulkEncoder struct {
+	encoders []codec.LinkEncoder
+}
+
+func classicBulk[E codec.LinkEncoder, D codec.LinkDecoder](encoders []E, decoders []D) (codec.LinkEncoder, codec.LinkDecoder, error) {
+	if len(encoders) != len(decoders) {
+		return nil, nil, fmt.Errorf("classic codec: %d requests but %d decoders", len(encoders), len(decoders))
+	}
+
+	bulkEncoder := &classicBulkEncoder{encoders: make([]codec.LinkEncoder, 0, len(encoders))}
+	bulkDecoder := &classicBulkDecoder{decoders: make([]codec.LinkDecoder, 0, len(decoders))}
+	for i := range encoders {
+		e, d, err := ClassicCodec(encoders[i], decoders[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		bulkEncoder.encoders = append(bulkEncoder.encoders, e)
+		bulkDecoder.decoders = append(bulkDecoder.decoders, d)
+	}
+	return bulkEncoder, bulkDecoder, nil
+}
+
+func (e *classicBulkEncoder) Encode(writer codec.Writer) error {
+	for _, encoder := range e.encoders {
+		if err := encoder.Encode(writer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *classicBulkEncoder) EncodeWithScratch(writer codec.Writer, scratch *bytes.Buffer) error {
+	for _, encoder := range e.encoders {
+		if err := codec.Encode(encoder, writer, scratch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *classicBulkEncoder) Reset() {
+	e.encoders = e.encoders[:0]
+}
+
+type classicBulkDecoder struct {
+	decoders []codec.LinkDecoder
+}
+
+func (d *classicBulkDecoder) Decode(reader codec.Reader) error {
+	for _, decoder := range d.decoders {
+		if err := decoder.Decode(reader); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *classicBulkDecoder) Reset() {
+	d.decoders = d.decoders[:0]
+}
+
+// classicQuietBulkSetDecoder reads the response of every set, and like QuietBulkDecoder only keeps the failures.
+type classicQuietBulkSetDecoder struct {
+	encoders []*MetaSetEncoder
+	meta     *QuietBulkDecoder[*MetaSetDecoder]
+}
+
+func classicQuietBulkSet(encoders []*MetaSetEncoder, d *QuietBulkDecoder[*MetaSetDecoder]) (codec.LinkEncoder, codec.LinkDecoder, error) {
+	bulkEncoder := &classicBulkEncoder{encoders: make([]codec.LinkEncoder, 0, len(encoders))}
+	for _, encoder := range encoders {
+		e, _, err := classicSet(encoder, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		bulkEncoder.encoders = append(bulkEncoder.encoders, e)
+	}
+	return bulkEncoder, &classicQuietBulkSetDecoder{encoders: encoders, meta: d}, nil
+}
+
+func (d *classicQuietBulkSetDecoder) Decode(reader codec.Reader) error {
+	// the responses are decoded into a scratch decoder, so that only the failures take one from NewDecoder, which may
+	// hand out pooled decoders the caller puts back from Decoders.
+	var decoder MetaSetDecoder
+	for _, encoder := range d.encoders {
+		decoder.Reset()
+		decoder.Interner = d.meta.interner
+		classic := classicSetDecoder{encoder: encoder, meta: &decoder}
+		if err := classic.Decode(reader); err != nil {
+			return err
+		}
+
+		if decoder.Status != Stored {
+			failure := d.meta.NewDecoder()
+			*failure = decoder
+			d.meta.Decoders = append(d.meta.Decoders, failure)
+		}
+	}
+	return nil
+}
+
+func (d *classicQuietBulkSetDecoder) Reset() {
+	d.meta.Reset()
+}
+
+var _ codec.LinkEncoder = (*classicGetEncoder)(nil)
+var _ codec.ScratchEncoder = (*classicGetEncoder)(nil)
+var _ codec.LinkDecoder = (*classicGetDecoder)(nil)
+var _ codec.LinkEncoder = (*classicSetEncoder)(nil)
+var _ codec.ScratchEncoder = (*classicSetEncoder)(nil)
+var _ codec.LinkDecoder = (*classicSetDecoder)(nil)
+var _ codec.LinkEncoder = (*classicDeleteEncoder)(nil)
+var _ codec.ScratchEncoder = (*classicDeleteEncoder)(nil)
+var _ codec.LinkDecoder = (*classicDeleteDecoder)(nil)
+var _ codec.LinkEncoder = (*classicArithmeticEncoder)(nil)
+var _ codec.ScratchEncoder = (*classicArithmeticEncoder)(nil)
+var _ codec.LinkDecoder = (*classicArithmeticDecoder)(nil)
+var _ codec.LinkEncoder = (*classicBulkEncoder)(nil)
+var _ codec.ScratchEncoder = (*classicBulkEncoder)(nil)
+var _ codec.LinkDecoder = (*classicBulkDecoder)(nil)
+var _ codec.LinkDecoder = (*classicQuietBulkSetDecoder)(nil)
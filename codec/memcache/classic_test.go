@@ -0,0 +1,348 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec"
+)
+
+func encodeClassic(t *testing.T, e codec.LinkEncoder) string {
+	data := &bytes.Buffer{}
+	writer := bufio.NewWriter(data)
+	require.NoError(t, e.Encode(writer))
+	require.NoError(t, writer.Flush())
+	return data.String()
+}
+
+func decodeClassic(t *testing.T, d codec.LinkDecoder, response string) {
+	reader := bufio.NewReader(strings.NewReader(response))
+	require.NoError(t, d.Decode(reader))
+	assert.Zero(t, reader.Buffered(), "response not fully consumed")
+}
+
+func Test_ClassicCodec_Get(t *testing.T) {
+	targs := []struct {
+		name            string
+		configure       func(e *MetaGetEncoder)
+		expectedRequest string
+		response        string
+		expected        MetaGetDecoder
+	}{
+		{
+			name:            "hit",
+			configure:       func(e *MetaGetEncoder) { e.FetchValue = true; e.FetchClientFlags = true },
+			expectedRequest: "get foo\r\n",
+			response:        "VALUE foo 5 3\r\nbar\r\nEND\r\n",
+			expected:        MetaGetDecoder{Status: CacheHit, Value: []byte("bar"), ClientFlags: 5, Opaque: 7},
+		},
+		{
+			name:            "miss",
+			configure:       func(e *MetaGetEncoder) { e.FetchValue = true },
+			expectedRequest: "get foo\r\n",
+			response:        "END\r\n",
+			expected:        MetaGetDecoder{Status: CacheMiss, Opaque: 7},
+		},
+		{
+			name:            "gets",
+			configure:       func(e *MetaGetEncoder) { e.FetchCasId = true; e.FetchKey = true; e.FetchItemSizeInBytes = true },
+			expectedRequest: "gets foo\r\n",
+			response:        "VALUE foo 0 3 42\r\nbar\r\nEND\r\n",
+			expected:        MetaGetDecoder{Status: CacheHit, CasId: 42, ItemKey: "foo", ItemSizeInBytes: 3, Opaque: 7},
+		},
+		{
+			name:            "gat",
+			configure:       func(e *MetaGetEncoder) { e.FetchValue = true; e.UpdateTTL = 60 },
+			expectedRequest: "gat 60 foo\r\n",
+			response:        "VALUE foo 0 3\r\nbar\r\nEND\r\n",
+			expected:        MetaGetDecoder{Status: CacheHit, Value: []byte("bar"), Opaque: 7},
+		},
+		{
+			name:            "gats",
+			configure:       func(e *MetaGetEncoder) { e.FetchCasId = true; e.UpdateTTL = 0 },
+			expectedRequest: "gats 0 foo\r\n",
+			response:        "END\r\n",
+			expected:        MetaGetDecoder{Status: CacheMiss, Opaque: 7},
+		},
+		{
+			name:            "touch",
+			configure:       func(e *MetaGetEncoder) { e.UpdateTTL = 60 },
+			expectedRequest: "touch foo 60\r\n",
+			response:        "TOUCHED\r\n",
+			expected:        MetaGetDecoder{Status: CacheHit, Opaque: 7},
+		},
+		{
+			name:            "touch miss",
+			configure:       func(e *MetaGetEncoder) { e.UpdateTTL = 0 },
+			expectedRequest: "touch foo 0\r\n",
+			response:        "NOT_FOUND\r\n",
+			expected:        MetaGetDecoder{Status: CacheMiss, Opaque: 7},
+		},
+		{
+			name:            "server error",
+			configure:       func(e *MetaGetEncoder) {},
+			expectedRequest: "get foo\r\n",
+			response:        "SERVER_ERROR out of memory\r\n",
+			expected:        MetaGetDecoder{Status: ServerError, Opaque: 7, HdrLine: "SERVER_ERROR out of memory\r\n"},
+		},
+	}
+
+	for _, tt := range targs {
+		t.Run(tt.name, func(t *testing.T) {
+			encoder := CreateMetaGetEncoder()
+			encoder.Reset()
+			encoder.Key = "foo"
+			encoder.Opaque = 7
+			tt.configure(encoder)
+			decoder := CreateMetaGetDecoder()
+
+			e, d, err := ClassicCodec(encoder, decoder)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedRequest, encodeClassic(t, e))
+
+			decodeClassic(t, d, tt.response)
+			assert.Equal(t, tt.expected, *decoder)
+		})
+	}
+}
+
+func Test_ClassicCodec_Set(t *testing.T) {
+	targs := []struct {
+		name            string
+		configure       func(e *MetaSetEncoder)
+		expectedRequest string
+		response        string
+		expectedStatus  MetadataStatus
+	}{
+		{
+			name:            "set",
+			configure:       func(e *MetaSetEncoder) { e.TTL = 60; e.ClientFlags = 3 },
+			expectedRequest: "set foo 3 60 3\r\nbar\r\n",
+			response:        "STORED\r\n",
+			expectedStatus:  Stored,
+		},
+		{
+			name:            "set without ttl",
+			configure:       func(e *MetaSetEncoder) {},
+			expectedRequest: "set foo 0 0 3\r\nbar\r\n",
+			response:        "STORED\r\n",
+			expectedStatus:  Stored,
+		},
+		{
+			name:            "add",
+			configure:       func(e *MetaSetEncoder) { e.Mode = Add },
+			expectedRequest: "add foo 0 0 3\r\nbar\r\n",
+			response:        "NOT_STORED\r\n",
+			expectedStatus:  NotStored,
+		},
+		{
+			name:            "replace",
+			configure:       func(e *MetaSetEncoder) { e.Mode = Replace },
+			expectedRequest: "replace foo 0 0 3\r\nbar\r\n",
+			response:        "STORED\r\n",
+			expectedStatus:  Stored,
+		},
+		{
+			name:            "append",
+			configure:       func(e *MetaSetEncoder) { e.Mode = Append },
+			expectedRequest: "append foo 0 0 3\r\nbar\r\n",
+			response:        "STORED\r\n",
+			expectedStatus:  Stored,
+		},
+		{
+			name:            "prepend",
+			configure:       func(e *MetaSetEncoder) { e.Mode = Prepend },
+			expectedRequest: "prepend foo 0 0 3\r\nbar\r\n",
+			response:        "STORED\r\n",
+			expectedStatus:  Stored,
+		},
+		{
+			name:            "cas mismatch",
+			configure:       func(e *MetaSetEncoder) { e.CasId = 42 },
+			expectedRequest: "cas foo 0 0 3 42\r\nbar\r\n",
+			response:        "EXISTS\r\n",
+			expectedStatus:  Exists,
+		},
+		{
+			name:            "cas miss",
+			configure:       func(e *MetaSetEncoder) { e.CasId = 42 },
+			expectedRequest: "cas foo 0 0 3 42\r\nbar\r\n",
+			response:        "NOT_FOUND\r\n",
+			expectedStatus:  NotFound,
+		},
+	}
+
+	for _, tt := range targs {
+		t.Run(tt.name, func(t *testing.T) {
+			encoder := CreateMetaSetEncoder()
+			encoder.Reset()
+			encoder.Key = "foo"
+			encoder.Value = []byte("bar")
+			encoder.Opaque = 7
+			tt.configure(encoder)
+			decoder := CreateMetaSetDecoder()
+
+			e, d, err := ClassicCodec(encoder, decoder)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedRequest, encodeClassic(t, e))
+
+			decodeClassic(t, d, tt.response)
+			assert.Equal(t, tt.expectedStatus, decoder.Status)
+			assert.Equal(t, uint64(7), decoder.Opaque)
+		})
+	}
+}
+
+func Test_ClassicCodec_Delete(t *testing.T) {
+	encoder := CreateMetaDeleteEncoder()
+	encoder.Reset()
+	encoder.Key = "foo"
+	encoder.Opaque = 7
+	decoder := CreateMetaDeleteDecoder()
+
+	e, d, err := ClassicCodec(encoder, decoder)
+	require.NoError(t, err)
+	assert.Equal(t, "delete foo\r\n", encodeClassic(t, e))
+
+	decodeClassic(t, d, "DELETED\r\n")
+	assert.Equal(t, Deleted, decoder.Status)
+	assert.Equal(t, uint64(7), decoder.Opaque)
+
+	decoder.Reset()
+	decodeClassic(t, d, "NOT_FOUND\r\n")
+	assert.Equal(t, NotFound, decoder.Status)
+}
+
+func Test_ClassicCodec_Arithmetic(t *testing.T) {
+	encoder := CreateArithmeticEncoder()
+	encoder.Reset()
+	encoder.Key = "foo"
+	encoder.Delta = 3
+	encoder.Decrement = true
+	encoder.FetchValue = true
+	decoder := CreateArithmeticDecoder()
+
+	e, d, err := ClassicCodec(encoder, decoder)
+	require.NoError(t, err)
+	assert.Equal(t, "decr foo 3\r\n", encodeClassic(t, e))
+
+	decodeClassic(t, d, "39\r\n")
+	assert.Equal(t, Stored, decoder.Status)
+	assert.Equal(t, []byte("39"), decoder.Value)
+	assert.Equal(t, uint64(39), decoder.ValueUInt64)
+
+	decoder.Reset()
+	decodeClassic(t, d, "NOT_FOUND\r\n")
+	assert.Equal(t, NotFound, decoder.Status)
+}
+
+func Test_ClassicCodec_BulkGet(t *testing.T) {
+	bulkEncoder := CreateBulkEncoder[*MetaGetEncoder](2)
+	bulkDecoder := CreateBulkDecoder[*MetaGetDecoder](2)
+	for i, key := range []string{"a", "b"} {
+		encoder := CreateMetaGetEncoder()
+		encoder.Reset()
+		encoder.Key = key
+		encoder.FetchValue = true
+		encoder.Opaque = uint64(i + 1)
+		bulkEncoder.Encoders = append(bulkEncoder.Encoders, encoder)
+		bulkDecoder.Decoders = append(bulkDecoder.Decoders, CreateMetaGetDecoder())
+	}
+
+	e, d, err := ClassicCodec(bulkEncoder, bulkDecoder)
+	require.NoError(t, err)
+	assert.Equal(t, "get a\r\nget b\r\n", encodeClassic(t, e))
+
+	decodeClassic(t, d, "VALUE a 0 1\r\n1\r\nEND\r\nEND\r\n")
+	assert.Equal(t, CacheHit, bulkDecoder.Decoders[0].Status)
+	assert.Equal(t, []byte("1"), bulkDecoder.Decoders[0].Value)
+	assert.Equal(t, uint64(1), bulkDecoder.Decoders[0].Opaque)
+	assert.Equal(t, CacheMiss, bulkDecoder.Decoders[1].Status)
+	assert.Equal(t, uint64(2), bulkDecoder.Decoders[1].Opaque)
+}
+
+func Test_ClassicCodec_QuietBulkSet(t *testing.T) {
+	bulkEncoder := CreateBulkEncoder[*MetaSetEncoder](3)
+	for i, key := range []string{"a", "b", "c"} {
+		encoder := CreateMetaSetEncoder()
+		encoder.Reset()
+		encoder.Key = key
+		encoder.Value = []byte("v")
+		encoder.Quiet = true
+		encoder.Opaque = uint64(i + 1)
+		bulkEncoder.Encoders = append(bulkEncoder.Encoders, encoder)
+	}
+	bulkDecoder := CreateQuietBulkDecoder(CreateMetaSetDecoder)
+
+	e, d, err := ClassicCodec(bulkEncoder, bulkDecoder)
+	require.NoError(t, err)
+	assert.Equal(t, "set a 0 0 1\r\nv\r\nset b 0 0 1\r\nv\r\nset c 0 0 1\r\nv\r\n", encodeClassic(t, e))
+
+	decodeClassic(t, d, "STORED\r\nSERVER_ERROR out of memory storing object\r\nSTORED\r\n")
+	require.Len(t, bulkDecoder.Decoders, 1)
+	assert.Equal(t, ServerError, bulkDecoder.Decoders[0].Status)
+	assert.Equal(t, uint64(2), bulkDecoder.Decoders[0].Opaque)
+	assert.Equal(t, "SERVER_ERROR out of memory storing object\r\n", bulkDecoder.Decoders[0].HdrLine)
+}
+
+func Test_ClassicCodec_Unsupported(t *testing.T) {
+	getWithTTL := CreateMetaGetEncoder()
+	getWithTTL.Reset()
+	getWithTTL.Key = "foo"
+	getWithTTL.FetchRemainingTTL = true
+
+	vivifyingAppend := CreateMetaSetEncoder()
+	vivifyingAppend.Reset()
+	vivifyingAppend.Key = "foo"
+	vivifyingAppend.Mode = Append
+	vivifyingAppend.BlockTTL = 60
+
+	binaryKey, err := NewBinaryKey([]byte{0x00, 0xff})
+	require.NoError(t, err)
+	binaryDelete := CreateMetaDeleteEncoder()
+	binaryDelete.Reset()
+	binaryDelete.ValidatedKey = binaryKey
+
+	binaryGet := CreateMetaGetEncoder()
+	binaryGet.Reset()
+	binaryGet.BinaryKey = []byte{0x00, 0xff}
+
+	autoCreate := CreateArithmeticEncoder()
+	autoCreate.Reset()
+	autoCreate.Key = "foo"
+	autoCreate.BlockTTL = 0
+
+	targs := []struct {
+		name    string
+		encoder codec.LinkEncoder
+		decoder codec.LinkDecoder
+	}{
+		{name: "remaining ttl", encoder: getWithTTL, decoder: CreateMetaGetDecoder()},
+		{name: "vivify on append", encoder: vivifyingAppend, decoder: CreateMetaSetDecoder()},
+		{name: "binary key", encoder: binaryDelete, decoder: CreateMetaDeleteDecoder()},
+		{name: "binary key bytes", encoder: binaryGet, decoder: CreateMetaGetDecoder()},
+		{name: "auto create counter", encoder: autoCreate, decoder: CreateArithmeticDecoder()},
+	}
+
+	for _, tt := range targs {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ClassicCodec(tt.encoder, tt.decoder)
+			assert.ErrorIs(t, err, ErrUnsupportedByClassicProtocol)
+		})
+	}
+}
+
+func Test_ClassicCodec_PassesThroughOtherRequests(t *testing.T) {
+	encoder := CreateVersionEncoder()
+	decoder := CreateVersionDecoder()
+
+	e, d, err := ClassicCodec(encoder, decoder)
+	require.NoError(t, err)
+	assert.Same(t, encoder, e)
+	assert.Same(t, decoder, d)
+}
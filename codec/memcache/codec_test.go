@@ -131,6 +131,7 @@ func Test_MetaSetEncoderResetsCorrectly(t *testing.T) {
 		Opaque:           119,
 		Mode:             Add,
 		BlockTTL:         39,
+		Quiet:            true,
 	}
 	encoder.Reset()
 	isMemcachedCompatibleDefaultFields(t, encoder)
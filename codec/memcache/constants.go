@@ -24,6 +24,7 @@ var (
 	PreventLRUBump        = []byte("u ")
 	Invalidate            = []byte("I ")
 	RemoveValue           = []byte("x ")
+	Quiet                 = []byte("q ")
 )
 
 const (
@@ -53,6 +54,10 @@ var (
 	DecrementMode   = []byte("MD ")
 	NoOpRequest     = []byte("mn\r\n")
 	NoOpResponse    = []byte("MN\r\n")
+	NoOpHeader      = []byte("MN")
+	ErrorHeader     = []byte("ERROR")
+	ClientErrHeader = []byte("CLIENT_ERROR")
+	ServerErrHeader = []byte("SERVER_ERROR")
 )
 
 type RecacheStatus string
@@ -74,14 +79,96 @@ const (
 	Exists                MetadataStatus = "Exists"
 	Stored                MetadataStatus = "Stored"
 	Deleted               MetadataStatus = "Deleted"
+	// NoOp is the status of an "MN" reply, which ends the replies of a pipeline of quiet requests.
+	NoOp MetadataStatus = "NoOp"
+	// ProtocolError is the status of an "ERROR" reply, sent for an unknown command.
+	ProtocolError MetadataStatus = "ProtocolError"
+	// ClientError is the status of a "CLIENT_ERROR <message>" reply, sent for a malformed request.
+	ClientError MetadataStatus = "ClientError"
+	// ServerError is the status of a "SERVER_ERROR <message>" reply, sent when the server failed to serve a request,
+	// e.g. because it's out of memory.
+	ServerError MetadataStatus = "ServerError"
 )
 
+// IsHit reports whether the item was found.
+func (s MetadataStatus) IsHit() bool {
+	return s == CacheHit
+}
+
+// IsMiss reports whether the item wasn't found, "EN" for a get and "NF" for the other operations.
+func (s MetadataStatus) IsMiss() bool {
+	return s == CacheMiss || s == NotFound
+}
+
+// IsStored reports whether the item was written.
+func (s MetadataStatus) IsStored() bool {
+	return s == Stored
+}
+
+// IsNotStored reports whether the item wasn't written because the condition of the request didn't hold.
+func (s MetadataStatus) IsNotStored() bool {
+	return s == NotStored
+}
+
+// IsDeleted reports whether the item was deleted.
+func (s MetadataStatus) IsDeleted() bool {
+	return s == Deleted
+}
+
+// IsCASConflict reports whether the CAS value of the request didn't match the one of the item.
+func (s MetadataStatus) IsCASConflict() bool {
+	return s == Exists
+}
+
+// IsNoOp reports whether the reply was the "MN" ending a pipeline of quiet requests.
+func (s MetadataStatus) IsNoOp() bool {
+	return s == NoOp
+}
+
+// IsError reports whether the server failed the request, or replied with an unknown response code.
+func (s MetadataStatus) IsError() bool {
+	switch s {
+	case ProtocolError, ClientError, ServerError, MetadataStatusInvalid:
+		return true
+	}
+	return false
+}
+
+// hasMetadata reports whether the reply carries flags after its response code, i.e. unless it's an error or "MN".
+// The header line of the others is kept as is in the HdrLine of the decoders.
+func (s MetadataStatus) hasMetadata() bool {
+	return !s.IsError() && !s.IsNoOp()
+}
+
+/*
+	replyStatusFromHeader returns the status of the replies which may be sent for any request:
+
+- "MN", to indicate the end of a pipeline of quiet requests
+- "ERROR", to indicate an unknown command
+- "CLIENT_ERROR <message>", to indicate a malformed request
+- "SERVER_ERROR <message>", to indicate that the server failed to serve the request
+*/
+func replyStatusFromHeader(hdrPrefix []byte) MetadataStatus {
+	switch {
+	case bytes.Equal(hdrPrefix, NoOpHeader):
+		return NoOp
+	case bytes.Equal(hdrPrefix, ErrorHeader):
+		return ProtocolError
+	case bytes.Equal(hdrPrefix, ClientErrHeader):
+		return ClientError
+	case bytes.Equal(hdrPrefix, ServerErrHeader):
+		return ServerError
+	}
+	return MetadataStatusInvalid
+}
+
 /*
 	MetaGetStatusFromHeader returns the status of a meta get operation:
 
 - "VA" (CACHE_HIT), to indicate that the item was found
 - "HD" (CACHE_HIT), to indicate that the item was found, but request did not ask for value
 - "EN" (CACHE_MISS), to indicate that the item was not found
+- the replies which may be sent for any request, see replyStatusFromHeader
 */
 func MetaGetStatusFromHeader(hdrPrefix []byte) MetadataStatus {
 	switch {
@@ -92,7 +179,7 @@ func MetaGetStatusFromHeader(hdrPrefix []byte) MetadataStatus {
 	case bytes.Equal(hdrPrefix, ValueHeader):
 		return CacheHit
 	}
-	return MetadataStatusInvalid
+	return replyStatusFromHeader(hdrPrefix)
 }
 
 /*
@@ -104,6 +191,7 @@ MetaSetStatusFromHeader returns the status of a meta set operation:
     CAS semantics has been modified since you last fetched it.
   - "NF" (NOT_FOUND), to indicate that the item you are trying to store
     with CAS semantics did not exist.
+  - the replies which may be sent for any request, see replyStatusFromHeader.
 */
 func MetaSetStatusFromHeader(hdrPrefix []byte) MetadataStatus {
 	switch {
@@ -116,7 +204,7 @@ func MetaSetStatusFromHeader(hdrPrefix []byte) MetadataStatus {
 	case bytes.Equal(hdrPrefix, NotFoundHeader):
 		return NotFound
 	}
-	return MetadataStatusInvalid
+	return replyStatusFromHeader(hdrPrefix)
 }
 
 /*
@@ -127,6 +215,7 @@ ArithmeticStatusFromHeader returns the status of an arithmetic operation:
     after a miss.
   - "EX" (EXISTS), to indicate that the supplied CAS token does not match the
     stored item.
+  - the replies which may be sent for any request, see replyStatusFromHeader.
 */
 func ArithmeticStatusFromHeader(hdrPrefix []byte) MetadataStatus {
 	switch {
@@ -141,7 +230,7 @@ func ArithmeticStatusFromHeader(hdrPrefix []byte) MetadataStatus {
 	case bytes.Equal(hdrPrefix, NotFoundHeader):
 		return NotFound
 	}
-	return MetadataStatusInvalid
+	return replyStatusFromHeader(hdrPrefix)
 }
 
 /*
@@ -150,6 +239,7 @@ MetaDeleteStatusFromHeader returns the status of a meta delete operation:
   - "NF" (NOT_FOUND), to indicate that the item with this key was not found.
   - "EX" (EXISTS), to indicate that the supplied CAS token does not match the
     stored item.
+  - the replies which may be sent for any request, see replyStatusFromHeader.
 */
 func MetaDeleteStatusFromHeader(hdrPrefix []byte) MetadataStatus {
 	switch {
@@ -162,7 +252,7 @@ func MetaDeleteStatusFromHeader(hdrPrefix []byte) MetadataStatus {
 	case bytes.Equal(hdrPrefix, NotStoredHeader):
 		return NotStored
 	}
-	return MetadataStatusInvalid
+	return replyStatusFromHeader(hdrPrefix)
 }
 
 type IllegaleMemcacheKey struct {
@@ -0,0 +1,113 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec"
+)
+
+func Test_StatusFromHeader(t *testing.T) {
+	targs := []struct {
+		header     string
+		get        MetadataStatus
+		set        MetadataStatus
+		arithmetic MetadataStatus
+		delete     MetadataStatus
+	}{
+		{header: "VA", get: CacheHit, set: MetadataStatusInvalid, arithmetic: Stored, delete: MetadataStatusInvalid},
+		{header: "HD", get: CacheHit, set: Stored, arithmetic: Stored, delete: Deleted},
+		{header: "EN", get: CacheMiss, set: MetadataStatusInvalid, arithmetic: MetadataStatusInvalid, delete: MetadataStatusInvalid},
+		{header: "NF", get: MetadataStatusInvalid, set: NotFound, arithmetic: NotFound, delete: NotFound},
+		{header: "NS", get: MetadataStatusInvalid, set: NotStored, arithmetic: NotStored, delete: NotStored},
+		{header: "EX", get: MetadataStatusInvalid, set: Exists, arithmetic: Exists, delete: Exists},
+		{header: "MN", get: NoOp, set: NoOp, arithmetic: NoOp, delete: NoOp},
+		{header: "ERROR", get: ProtocolError, set: ProtocolError, arithmetic: ProtocolError, delete: ProtocolError},
+		{header: "CLIENT_ERROR", get: ClientError, set: ClientError, arithmetic: ClientError, delete: ClientError},
+		{header: "SERVER_ERROR", get: ServerError, set: ServerError, arithmetic: ServerError, delete: ServerError},
+		{header: "XX", get: MetadataStatusInvalid, set: MetadataStatusInvalid, arithmetic: MetadataStatusInvalid, delete: MetadataStatusInvalid},
+	}
+	for _, targ := range targs {
+		t.Run(targ.header, func(t *testing.T) {
+			assert.Equal(t, targ.get, MetaGetStatusFromHeader([]byte(targ.header)))
+			assert.Equal(t, targ.set, MetaSetStatusFromHeader([]byte(targ.header)))
+			assert.Equal(t, targ.arithmetic, ArithmeticStatusFromHeader([]byte(targ.header)))
+			assert.Equal(t, targ.delete, MetaDeleteStatusFromHeader([]byte(targ.header)))
+		})
+	}
+}
+
+func Test_MetadataStatus_Predicates(t *testing.T) {
+	targs := []struct {
+		status                                                            MetadataStatus
+		hit, miss, stored, notStored, deleted, casConflict, noOp, isError bool
+	}{
+		{status: CacheHit, hit: true},
+		{status: CacheMiss, miss: true},
+		{status: NotFound, miss: true},
+		{status: Stored, stored: true},
+		{status: NotStored, notStored: true},
+		{status: Deleted, deleted: true},
+		{status: Exists, casConflict: true},
+		{status: NoOp, noOp: true},
+		{status: ProtocolError, isError: true},
+		{status: ClientError, isError: true},
+		{status: ServerError, isError: true},
+		{status: MetadataStatusInvalid, isError: true},
+	}
+	for _, targ := range targs {
+		t.Run(string(targ.status), func(t *testing.T) {
+			assert.Equal(t, targ.hit, targ.status.IsHit())
+			assert.Equal(t, targ.miss, targ.status.IsMiss())
+			assert.Equal(t, targ.stored, targ.status.IsStored())
+			assert.Equal(t, targ.notStored, targ.status.IsNotStored())
+			assert.Equal(t, targ.deleted, targ.status.IsDeleted())
+			assert.Equal(t, targ.casConflict, targ.status.IsCASConflict())
+			assert.Equal(t, targ.noOp, targ.status.IsNoOp())
+			assert.Equal(t, targ.isError, targ.status.IsError())
+		})
+	}
+}
+
+func Test_Decoders_ErrorReplies(t *testing.T) {
+	decoders := map[string]func() (codec.LinkDecoder, func() (MetadataStatus, string)){
+		"mg": func() (codec.LinkDecoder, func() (MetadataStatus, string)) {
+			d := &MetaGetDecoder{}
+			return d, func() (MetadataStatus, string) { return d.Status, d.HdrLine }
+		},
+		"ms": func() (codec.LinkDecoder, func() (MetadataStatus, string)) {
+			d := &MetaSetDecoder{}
+			return d, func() (MetadataStatus, string) { return d.Status, d.HdrLine }
+		},
+		"ma": func() (codec.LinkDecoder, func() (MetadataStatus, string)) {
+			d := &MetaArithmeticDecoder{}
+			return d, func() (MetadataStatus, string) { return d.Status, d.HdrLine }
+		},
+		"md": func() (codec.LinkDecoder, func() (MetadataStatus, string)) {
+			d := &MetaDeleteDecoder{}
+			return d, func() (MetadataStatus, string) { return d.Status, d.HdrLine }
+		},
+	}
+	replies := map[string]MetadataStatus{
+		"MN\r\n":                             NoOp,
+		"ERROR\r\n":                          ProtocolError,
+		"CLIENT_ERROR bad data chunk\r\n":    ClientError,
+		"SERVER_ERROR out of memory\r\n":     ServerError,
+		"BUSY too many outstanding keys\r\n": MetadataStatusInvalid,
+	}
+	for name, newDecoder := range decoders {
+		for reply, expected := range replies {
+			t.Run(name+" "+reply, func(t *testing.T) {
+				d, result := newDecoder()
+				require.NoError(t, d.Decode(bufio.NewReader(bytes.NewReader([]byte(reply)))))
+				status, hdrLine := result()
+				assert.Equal(t, expected, status)
+				assert.Equal(t, reply, hdrLine)
+			})
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package memcache
+
+import (
+	"fmt"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// requestKey returns the key sent by an encoder, the validated one taking precedence over the binary one, which takes
+// precedence over key.
+func requestKey(key string, binary []byte, validated Key) string {
+	if !validated.IsZero() {
+		return validated.String()
+	}
+	if binary != nil {
+		return string(binary)
+	}
+	return key
+}
+
+func (e *MetaGetEncoder) Describe() (string, string) {
+	return "mg", requestKey(e.Key, e.BinaryKey, e.ValidatedKey)
+}
+
+func (e *MetaSetEncoder) Describe() (string, string) {
+	return "ms", requestKey(e.Key, e.BinaryKey, e.ValidatedKey)
+}
+
+func (e *MetaDeleteEncoder) Describe() (string, string) {
+	return "md", requestKey(e.Key, e.BinaryKey, e.ValidatedKey)
+}
+
+func (e *MetaArithmeticEncoder) Describe() (string, string) {
+	return "ma", requestKey(e.Key, e.BinaryKey, e.ValidatedKey)
+}
+
+func (e *MetaNoOpEncoder) Describe() (string, string) {
+	return "mn", ""
+}
+
+func (e *VersionEncoder) Describe() (string, string) {
+	return "version", ""
+}
+
+func (e *FlushAllEncoder) Describe() (string, string) {
+	return "flush_all", ""
+}
+
+func (e *StatsEncoder) Describe() (string, string) {
+	return "stats", ""
+}
+
+func (e *LruCrawlerMetadumpEncoder) Describe() (string, string) {
+	return "lru_crawler metadump", ""
+}
+
+func (e *RawEncoder) Describe() (string, string) {
+	return "raw", ""
+}
+
+// Describe reports the operation and key of the first request, along with the number of requests.
+func (e *BulkEncoder[T]) Describe() (string, string) {
+	return describeBulk(len(e.Encoders), func(i int) codec.LinkEncoder { return e.Encoders[i] })
+}
+
+func (e *classicGetEncoder) Describe() (string, string) {
+	if classicTouch(e.meta) {
+		return "touch", e.key
+	}
+	if e.meta.updatesTTL() {
+		return "gat", e.key
+	}
+	return "get", e.key
+}
+
+func (e *classicSetEncoder) Describe() (string, string) {
+	return e.command, e.key
+}
+
+func (e *classicDeleteEncoder) Describe() (string, string) {
+	return "delete", e.key
+}
+
+func (e *classicArithmeticEncoder) Describe() (string, string) {
+	if e.meta.Decrement {
+		return "decr", e.key
+	}
+	return "incr", e.key
+}
+
+func (e *classicBulkEncoder) Describe() (string, string) {
+	return describeBulk(len(e.encoders), func(i int) codec.LinkEncoder { return e.encoders[i] })
+}
+
+func describeBulk(n int, encoder func(i int) codec.LinkEncoder) (string, string) {
+	if n == 0 {
+		return "bulk", ""
+	}
+
+	operation, key := "unknown", ""
+	if describer, ok := encoder(0).(codec.RequestDescriber); ok {
+		operation, key = describer.Describe()
+	}
+	return fmt.Sprintf("bulk %s x%d", operation, n), key
+}
+
+var _ codec.RequestDescriber = (*MetaGetEncoder)(nil)
+var _ codec.RequestDescriber = (*MetaSetEncoder)(nil)
+var _ codec.RequestDescriber = (*MetaDeleteEncoder)(nil)
+var _ codec.RequestDescriber = (*MetaArithmeticEncoder)(nil)
+var _ codec.RequestDescriber = (*MetaNoOpEncoder)(nil)
+var _ codec.RequestDescriber = (*VersionEncoder)(nil)
+var _ codec.RequestDescriber = (*FlushAllEncoder)(nil)
+var _ codec.RequestDescriber = (*StatsEncoder)(nil)
+var _ codec.RequestDescriber = (*LruCrawlerMetadumpEncoder)(nil)
+var _ codec.RequestDescriber = (*RawEncoder)(nil)
+var _ codec.RequestDescriber = (*BulkEncoder[*MetaGetEncoder])(nil)
+var _ codec.RequestDescriber = (*classicBulkEncoder)(nil)
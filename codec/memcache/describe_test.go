@@ -0,0 +1,53 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec"
+)
+
+func Test_Describe(t *testing.T) {
+	get := CreateMetaGetEncoder()
+	get.Reset()
+	get.Key = "foo"
+
+	validated, err := NewKey("bar")
+	require.NoError(t, err)
+	set := CreateMetaSetEncoder()
+	set.Reset()
+	set.Key = "ignored"
+	set.ValidatedKey = validated
+
+	bulk := CreateBulkEncoder[*MetaGetEncoder](2)
+	bulk.Encoders = append(bulk.Encoders, get, get)
+
+	classicDelete, _, err := ClassicCodec(&MetaDeleteEncoder{Key: "baz", TTL: -1}, CreateMetaDeleteDecoder())
+	require.NoError(t, err)
+
+	targs := []struct {
+		name              string
+		encoder           codec.LinkEncoder
+		expectedOperation string
+		expectedKey       string
+	}{
+		{name: "meta get", encoder: get, expectedOperation: "mg", expectedKey: "foo"},
+		{name: "validated key", encoder: set, expectedOperation: "ms", expectedKey: "bar"},
+		{name: "bulk", encoder: bulk, expectedOperation: "bulk mg x2", expectedKey: "foo"},
+		{name: "empty bulk", encoder: CreateBulkEncoder[*MetaGetEncoder](0), expectedOperation: "bulk"},
+		{name: "classic", encoder: classicDelete, expectedOperation: "delete", expectedKey: "baz"},
+		{name: "admin", encoder: CreateVersionEncoder(), expectedOperation: "version"},
+	}
+
+	for _, tt := range targs {
+		t.Run(tt.name, func(t *testing.T) {
+			describer, ok := tt.encoder.(codec.RequestDescriber)
+			require.True(t, ok)
+			operation, key := describer.Describe()
+			assert.Equal(t, tt.expectedOperation, operation)
+			assert.Equal(t, tt.expectedKey, key)
+		})
+	}
+}
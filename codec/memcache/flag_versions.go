@@ -0,0 +1,165 @@
+package memcache
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// ServerVersion is a "major.minor.patch" memcached version.
+type ServerVersion struct {
+	Major, Minor, Patch int
+}
+
+// ParseServerVersion parses the version reported by memcached, e.g. "1.6.21". A missing patch is 0, anything else
+// which isn't a version, e.g. the one reported by a proxy, fails.
+func ParseServerVersion(version string) (ServerVersion, bool) {
+	parts := strings.SplitN(strings.TrimSpace(version), ".", 3)
+	if len(parts) < 2 {
+		return ServerVersion{}, false
+	}
+	if len(parts) == 2 {
+		parts = append(parts, "0")
+	}
+
+	var numbers [3]int
+	for i, part := range parts {
+		// suffixes like "-rc1" don't matter to the flags.
+		part, _, _ = strings.Cut(part, "-")
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return ServerVersion{}, false
+		}
+		numbers[i] = n
+	}
+	return ServerVersion{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, true
+}
+
+// Less reports whether v is older than other.
+func (v ServerVersion) Less(other ServerVersion) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor < other.Minor
+	}
+	return v.Patch < other.Patch
+}
+
+func (v ServerVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// MetaFlag is a flag of a meta command which older memcached versions ignore, or understand differently.
+type MetaFlag struct {
+	Command string // "mg", "ms", "md" or "ma".
+	Flag    byte
+	// Since is the first version which supports the flag.
+	Since ServerVersion
+}
+
+func (f MetaFlag) String() string {
+	return fmt.Sprintf("%s %c (since %s)", f.Command, f.Flag, f.Since)
+}
+
+var (
+	// base64 keys shipped with the meta protocol.
+	base64KeySince = ServerVersion{Major: 1, Minor: 6}
+	// E, setting the CAS value of the item, was added after the other meta flags: older versions ignore it and
+	// generate a CAS value.
+	casOverrideSince = ServerVersion{Major: 1, Minor: 6, Patch: 13}
+)
+
+// VersionGatedRequest is implemented by the requests which can tell the meta flags they use that a memcached version
+// doesn't support.
+type VersionGatedRequest interface {
+	// UnsupportedFlags returns the flags of the request which version doesn't support, nil if it supports them all.
+	UnsupportedFlags(version ServerVersion) []MetaFlag
+}
+
+// flagUse tells whether a request uses a flag supported since a version.
+type flagUse struct {
+	flag  byte
+	since ServerVersion
+	used  bool
+}
+
+// gateFlags returns the flags among used which version doesn't support.
+func gateFlags(version ServerVersion, command string, used ...flagUse) []MetaFlag {
+	var unsupported []MetaFlag
+	for _, u := range used {
+		if u.used && version.Less(u.since) {
+			unsupported = append(unsupported, MetaFlag{Command: command, Flag: u.flag, Since: u.since})
+		}
+	}
+	return unsupported
+}
+
+func base64KeyUse(base64Key bool, binaryKey []byte, key Key) flagUse {
+	return flagUse{flag: 'b', since: base64KeySince, used: base64Key || binaryKey != nil || key.base64}
+}
+
+func (e *MetaGetEncoder) UnsupportedFlags(version ServerVersion) []MetaFlag {
+	return gateFlags(version, "mg",
+		base64KeyUse(e.Base64EncodedKey, e.BinaryKey, e.ValidatedKey),
+		flagUse{flag: CasOverride, since: casOverrideSince, used: e.CasOverride != 0},
+	)
+}
+
+func (e *MetaSetEncoder) UnsupportedFlags(version ServerVersion) []MetaFlag {
+	return gateFlags(version, "ms",
+		base64KeyUse(e.Base64EncodedKey, e.BinaryKey, e.ValidatedKey),
+		flagUse{flag: CasOverride, since: casOverrideSince, used: e.CasOverride != 0},
+	)
+}
+
+func (e *MetaDeleteEncoder) UnsupportedFlags(version ServerVersion) []MetaFlag {
+	return gateFlags(version, "md",
+		base64KeyUse(e.Base64EncodedKey, e.BinaryKey, e.ValidatedKey),
+		flagUse{flag: CasOverride, since: casOverrideSince, used: e.CasOverride != 0},
+	)
+}
+
+func (e *MetaArithmeticEncoder) UnsupportedFlags(version ServerVersion) []MetaFlag {
+	return gateFlags(version, "ma",
+		base64KeyUse(e.Base64EncodedKey, e.BinaryKey, e.ValidatedKey),
+		flagUse{flag: CasOverride, since: casOverrideSince, used: e.CasOverride != 0},
+	)
+}
+
+// UnsupportedFlags returns the flags of the wrapped requests which version doesn't support.
+func (e *BulkEncoder[T]) UnsupportedFlags(version ServerVersion) []MetaFlag {
+	var unsupported []MetaFlag
+	for _, encoder := range e.Encoders {
+		unsupported = append(unsupported, UnsupportedFlags(encoder, version)...)
+	}
+	return unsupported
+}
+
+// UnsupportedFlags returns the flags of the requests of every group which version doesn't support.
+func (e *BarrierEncoder) UnsupportedFlags(version ServerVersion) []MetaFlag {
+	var unsupported []MetaFlag
+	for _, group := range e.Groups {
+		for _, encoder := range group.Encoders {
+			unsupported = append(unsupported, UnsupportedFlags(encoder, version)...)
+		}
+	}
+	return unsupported
+}
+
+// UnsupportedFlags returns the flags of encoder which version doesn't support, nil if it doesn't use meta flags.
+func UnsupportedFlags(encoder codec.LinkEncoder, version ServerVersion) []MetaFlag {
+	if gated, ok := encoder.(VersionGatedRequest); ok {
+		return gated.UnsupportedFlags(version)
+	}
+	return nil
+}
+
+var _ VersionGatedRequest = (*MetaGetEncoder)(nil)
+var _ VersionGatedRequest = (*MetaSetEncoder)(nil)
+var _ VersionGatedRequest = (*MetaDeleteEncoder)(nil)
+var _ VersionGatedRequest = (*MetaArithmeticEncoder)(nil)
+var _ VersionGatedRequest = (*BulkEncoder[*MetaGetEncoder])(nil)
+var _ VersionGatedRequest = (*BarrierEncoder)(nil)
@@ -0,0 +1,69 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseServerVersion(t *testing.T) {
+	targs := []struct {
+		version  string
+		expected ServerVersion
+		ok       bool
+	}{
+		{version: "1.6.21", expected: ServerVersion{Major: 1, Minor: 6, Patch: 21}, ok: true},
+		{version: " 1.6.9\r\n", expected: ServerVersion{Major: 1, Minor: 6, Patch: 9}, ok: true},
+		{version: "1.6", expected: ServerVersion{Major: 1, Minor: 6}, ok: true},
+		{version: "1.6.0-rc1", expected: ServerVersion{Major: 1, Minor: 6}, ok: true},
+		{version: "mcrouter", ok: false},
+		{version: "1.x.2", ok: false},
+		{version: "", ok: false},
+	}
+	for _, targ := range targs {
+		t.Run(targ.version, func(t *testing.T) {
+			version, ok := ParseServerVersion(targ.version)
+			assert.Equal(t, targ.ok, ok)
+			assert.Equal(t, targ.expected, version)
+		})
+	}
+}
+
+func Test_ServerVersion_Less(t *testing.T) {
+	v := ServerVersion{Major: 1, Minor: 6, Patch: 13}
+	assert.True(t, ServerVersion{Major: 1, Minor: 6, Patch: 9}.Less(v))
+	assert.True(t, ServerVersion{Major: 1, Minor: 5, Patch: 22}.Less(v))
+	assert.False(t, v.Less(v))
+	assert.False(t, ServerVersion{Major: 1, Minor: 7}.Less(v))
+	assert.False(t, ServerVersion{Major: 2}.Less(v))
+}
+
+func Test_UnsupportedFlags(t *testing.T) {
+	old := ServerVersion{Major: 1, Minor: 6, Patch: 9}
+	recent := ServerVersion{Major: 1, Minor: 6, Patch: 21}
+	casOverride := func(command string) MetaFlag {
+		return MetaFlag{Command: command, Flag: 'E', Since: ServerVersion{Major: 1, Minor: 6, Patch: 13}}
+	}
+
+	assert.Empty(t, UnsupportedFlags(&MetaGetEncoder{Key: "key", FetchValue: true}, old))
+	assert.Equal(t, []MetaFlag{casOverride("mg")}, UnsupportedFlags(&MetaGetEncoder{Key: "key", CasOverride: 1}, old))
+	assert.Equal(t, []MetaFlag{casOverride("ms")}, UnsupportedFlags(&MetaSetEncoder{Key: "key", CasOverride: 1}, old))
+	assert.Equal(t, []MetaFlag{casOverride("md")}, UnsupportedFlags(&MetaDeleteEncoder{Key: "key", CasOverride: 1}, old))
+	assert.Equal(t, []MetaFlag{casOverride("ma")}, UnsupportedFlags(&MetaArithmeticEncoder{Key: "key", CasOverride: 1}, old))
+	assert.Empty(t, UnsupportedFlags(&MetaSetEncoder{Key: "key", CasOverride: 1}, recent))
+
+	assert.Equal(t,
+		[]MetaFlag{{Command: "mg", Flag: 'b', Since: ServerVersion{Major: 1, Minor: 6}}},
+		UnsupportedFlags(&MetaGetEncoder{BinaryKey: []byte{0}}, ServerVersion{Major: 1, Minor: 5, Patch: 22}),
+	)
+
+	bulk := &BulkEncoder[*MetaSetEncoder]{Encoders: []*MetaSetEncoder{{Key: "a"}, {Key: "b", CasOverride: 1}}}
+	assert.Equal(t, []MetaFlag{casOverride("ms")}, UnsupportedFlags(bulk, old))
+
+	barrier := &BarrierEncoder{Groups: []*BarrierGroup{{}, {}}}
+	barrier.Groups[0].Encoders = append(barrier.Groups[0].Encoders, &MetaDeleteEncoder{Key: "a", CasOverride: 1})
+	barrier.Groups[1].Encoders = append(barrier.Groups[1].Encoders, &MetaGetEncoder{Key: "b", CasOverride: 1})
+	assert.Equal(t, []MetaFlag{casOverride("md"), casOverride("mg")}, UnsupportedFlags(barrier, old))
+
+	assert.Empty(t, UnsupportedFlags(&VersionEncoder{}, old))
+}
@@ -0,0 +1,152 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrFlagFieldConflict is returned when registering a flag field whose name or bits are already taken.
+	ErrFlagFieldConflict = errors.New("flag field conflicts with a registered one")
+	// ErrFlagValueOverflow is returned when a value doesn't fit the bits of its flag field.
+	ErrFlagValueOverflow = errors.New("value overflows the flag field")
+)
+
+// FlagField is a range of bits of the client flags with an agreed meaning, e.g. the compression algorithm of the value.
+// Fields are created by a FlagRegistry, which guarantees they don't overlap.
+type FlagField struct {
+	Name  string
+	Shift uint8
+	Width uint8
+}
+
+func (f FlagField) mask() uint64 {
+	if f.Width >= 64 {
+		return ^uint64(0)
+	}
+	return (uint64(1)<<f.Width - 1) << f.Shift
+}
+
+// Get returns the value of the field in flags.
+func (f FlagField) Get(flags uint64) uint64 {
+	return (flags & f.mask()) >> f.Shift
+}
+
+// IsSet reports whether any bit of the field is set in flags.
+func (f FlagField) IsSet(flags uint64) bool {
+	return flags&f.mask() != 0
+}
+
+// Set returns flags with the field set to value, leaving the other bits untouched.
+func (f FlagField) Set(flags uint64, value uint64) (uint64, error) {
+	if bits.Len64(value) > int(f.Width) {
+		return flags, fmt.Errorf("field=%s width=%d value=%d: %w", f.Name, f.Width, value, ErrFlagValueOverflow)
+	}
+	return flags&^f.mask() | value<<f.Shift, nil
+}
+
+// Clear returns flags with every bit of the field unset.
+func (f FlagField) Clear(flags uint64) uint64 {
+	return flags &^ f.mask()
+}
+
+// FlagRegistry allocates the bits of the client flags to named fields, so that features layered on the flags don't
+// collide. The classic protocol only stores 32 bits of flags, fields beyond are lost with backends not supporting the
+// meta protocol.
+type FlagRegistry struct {
+	mu     sync.RWMutex
+	fields map[string]FlagField // protected by mu
+	used   uint64               // protected by mu
+}
+
+func NewFlagRegistry() *FlagRegistry {
+	return &FlagRegistry{fields: make(map[string]FlagField)}
+}
+
+// Register allocates width bits starting at bit shift to the field name. It fails with ErrFlagFieldConflict if the
+// name or any of the bits is already registered.
+func (r *FlagRegistry) Register(name string, shift uint8, width uint8) (FlagField, error) {
+	if name == "" || width == 0 || int(shift)+int(width) > 64 {
+		return FlagField{}, fmt.Errorf("invalid flag field %q: shift=%d width=%d", name, shift, width)
+	}
+
+	field := FlagField{Name: name, Shift: shift, Width: width}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.fields[name]; ok {
+		return FlagField{}, fmt.Errorf("field=%s: %w", name, ErrFlagFieldConflict)
+	}
+	if r.used&field.mask() != 0 {
+		return FlagField{}, fmt.Errorf("field=%s bits=%d-%d: %w", name, shift, int(shift)+int(width)-1, ErrFlagFieldConflict)
+	}
+
+	r.fields[name] = field
+	r.used |= field.mask()
+	return field, nil
+}
+
+// MustRegister is like Register but panics on conflicts, for fields registered at init time.
+func (r *FlagRegistry) MustRegister(name string, shift uint8, width uint8) FlagField {
+	field, err := r.Register(name, shift, width)
+	if err != nil {
+		panic(err)
+	}
+	return field
+}
+
+// Field returns the field registered under name.
+func (r *FlagRegistry) Field(name string) (FlagField, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	field, ok := r.fields[name]
+	return field, ok
+}
+
+// Fields returns the registered fields ordered by their first bit.
+func (r *FlagRegistry) Fields() []FlagField {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fields := make([]FlagField, 0, len(r.fields))
+	for _, field := range r.fields {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Shift < fields[j].Shift })
+	return fields
+}
+
+// Decode returns the value of every registered field set in flags, along with the bits set outside any field.
+func (r *FlagRegistry) Decode(flags uint64) (map[string]uint64, uint64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	values := make(map[string]uint64)
+	for name, field := range r.fields {
+		if field.IsSet(flags) {
+			values[name] = field.Get(flags)
+		}
+	}
+	return values, flags &^ r.used
+}
+
+// DefaultFlagRegistry holds the well-known fields below, teams layering their own features on the flags register
+// them here too, preferably in the upper bits.
+var DefaultFlagRegistry = NewFlagRegistry()
+
+// Well-known flag fields, in the lower 32 bits so they survive the classic protocol. The meaning of their values is
+// up to the features using them, 0 meaning the feature isn't used.
+var (
+	// CompressionFlag identifies the algorithm the value is compressed with.
+	CompressionFlag = DefaultFlagRegistry.MustRegister("compression", 0, 4)
+	// CodecFlag identifies how the value is serialized, e.g. protobuf or JSON.
+	CodecFlag = DefaultFlagRegistry.MustRegister("codec", 4, 8)
+	// EncryptionFlag identifies the scheme, or key version, the value is encrypted with.
+	EncryptionFlag = DefaultFlagRegistry.MustRegister("encryption", 12, 4)
+	// ChunkedFlag marks values split over several items.
+	ChunkedFlag = DefaultFlagRegistry.MustRegister("chunked", 16, 1)
+)
@@ -0,0 +1,83 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FlagField(t *testing.T) {
+	flags, err := CodecFlag.Set(0, 3)
+	require.NoError(t, err)
+	flags, err = ChunkedFlag.Set(flags, 1)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(3<<4|1<<16), flags)
+	assert.Equal(t, uint64(3), CodecFlag.Get(flags))
+	assert.True(t, ChunkedFlag.IsSet(flags))
+	assert.False(t, CompressionFlag.IsSet(flags))
+
+	flags, err = CodecFlag.Set(flags, 5)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), CodecFlag.Get(flags))
+	assert.Equal(t, uint64(1<<16), CodecFlag.Clear(flags))
+
+	unchanged, err := CompressionFlag.Set(flags, 16)
+	assert.ErrorIs(t, err, ErrFlagValueOverflow)
+	assert.Equal(t, flags, unchanged)
+
+	full := FlagField{Name: "full", Width: 64}
+	assert.Equal(t, ^uint64(0), full.Get(^uint64(0)))
+}
+
+func Test_FlagRegistry(t *testing.T) {
+	registry := NewFlagRegistry()
+	tenant := registry.MustRegister("tenant", 32, 16)
+
+	targs := []struct {
+		name        string
+		shift       uint8
+		width       uint8
+		expectedErr error
+	}{
+		{name: "tenant", shift: 48, width: 1, expectedErr: ErrFlagFieldConflict},
+		{name: "overlap", shift: 40, width: 16, expectedErr: ErrFlagFieldConflict},
+		{name: "", shift: 0, width: 1},
+		{name: "empty", shift: 0, width: 0},
+		{name: "too wide", shift: 60, width: 8},
+	}
+
+	for _, targ := range targs {
+		t.Run(targ.name, func(t *testing.T) {
+			_, err := registry.Register(targ.name, targ.shift, targ.width)
+			require.Error(t, err)
+			if targ.expectedErr != nil {
+				assert.ErrorIs(t, err, targ.expectedErr)
+			}
+		})
+	}
+
+	version, err := registry.Register("version", 48, 8)
+	require.NoError(t, err)
+	assert.Equal(t, []FlagField{tenant, version}, registry.Fields())
+
+	field, ok := registry.Field("tenant")
+	assert.True(t, ok)
+	assert.Equal(t, tenant, field)
+
+	flags, err := tenant.Set(1<<63|1, 7)
+	require.NoError(t, err)
+	values, unregistered := registry.Decode(flags)
+	assert.Equal(t, map[string]uint64{"tenant": 7}, values)
+	assert.Equal(t, uint64(1<<63|1), unregistered)
+}
+
+func Test_DefaultFlagRegistry(t *testing.T) {
+	for _, field := range DefaultFlagRegistry.Fields() {
+		assert.LessOrEqual(t, int(field.Shift)+int(field.Width), 32, field.Name)
+	}
+
+	_, err := DefaultFlagRegistry.Register("compression", 40, 4)
+	assert.ErrorIs(t, err, ErrFlagFieldConflict)
+}
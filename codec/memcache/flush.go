@@ -0,0 +1,69 @@
+package memcache
+
+import (
+	"bytes"
+
+	"github.com/stripe/memlink/codec"
+)
+
+var (
+	FlushAll   = []byte("flush_all")
+	OKResponse = []byte("OK\r\n")
+)
+
+/*
+FlushAllEncoder command format: flush_all\r\n
+
+Every item of the server is invalidated at once, the response is a single OK line.
+*/
+type FlushAllEncoder struct{}
+
+func (e *FlushAllEncoder) Encode(writer codec.Writer) error {
+	b := bytePool.Get()
+	defer bytePool.Put(b)
+	return e.EncodeWithScratch(writer, b)
+}
+
+func (e *FlushAllEncoder) EncodeWithScratch(writer codec.Writer, b *bytes.Buffer) error {
+
+	b.Write(FlushAll)
+	b.Write(CRLF)
+
+	_, err := writer.Write(b.Bytes())
+	return err
+}
+
+func (e *FlushAllEncoder) Reset() {
+}
+
+type FlushAllDecoder struct {
+	// HdrLine is set if the server didn't reply with OK, e.g. "ERROR" when flush_all is disabled.
+	HdrLine string
+}
+
+func (d *FlushAllDecoder) Decode(reader codec.Reader) error {
+	line, err := reader.ReadSlice('\n')
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(line, OKResponse) {
+		d.HdrLine = string(line)
+	}
+	return nil
+}
+
+func (d *FlushAllDecoder) Reset() {
+	d.HdrLine = ""
+}
+
+var _ codec.LinkEncoder = (*FlushAllEncoder)(nil)
+var _ codec.ScratchEncoder = (*FlushAllEncoder)(nil)
+var _ codec.LinkDecoder = (*FlushAllDecoder)(nil)
+
+func CreateFlushAllEncoder() *FlushAllEncoder {
+	return &FlushAllEncoder{}
+}
+
+func CreateFlushAllDecoder() *FlushAllDecoder {
+	return &FlushAllDecoder{}
+}
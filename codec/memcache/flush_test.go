@@ -0,0 +1,31 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlushAllEncode(t *testing.T) {
+	encoder := &FlushAllEncoder{}
+
+	data := &bytes.Buffer{}
+	writer := bufio.NewWriter(data)
+	assert.NoError(t, encoder.Encode(writer))
+
+	assert.NoError(t, writer.Flush())
+	assert.Equal(t, "flush_all\r\n", data.String())
+}
+
+func TestFlushAllDecode(t *testing.T) {
+	decoder := &FlushAllDecoder{}
+	assert.NoError(t, decoder.Decode(bufio.NewReader(strings.NewReader("OK\r\n"))))
+	assert.Empty(t, decoder.HdrLine)
+
+	decoder.Reset()
+	assert.NoError(t, decoder.Decode(bufio.NewReader(strings.NewReader("ERROR\r\n"))))
+	assert.Equal(t, "ERROR\r\n", decoder.HdrLine)
+}
@@ -0,0 +1,100 @@
+package memcache
+
+import (
+	"github.com/stripe/memlink/codec"
+)
+
+// Idempotent reports whether the request only reads the item, or bumps its TTL. Vivifying or recaching gets hand out
+// win tokens, which must not be handed out twice.
+func (e *MetaGetEncoder) Idempotent() bool {
+	return e.BlockTTL < 0 && e.RecacheTTL < 0 && e.CasOverride == 0
+}
+
+// Idempotent reports whether the request is a plain set. Conditional modes, appends and CAS would fail, or apply
+// twice, when retried after being applied.
+func (e *MetaSetEncoder) Idempotent() bool {
+	return e.Mode == "" && e.CasId == 0 && e.CasOverride == 0 && !e.Invalidate
+}
+
+// Idempotent reports whether the request is a plain delete. A retried delete responds NF when the first attempt
+// was applied.
+func (e *MetaDeleteEncoder) Idempotent() bool {
+	return e.CasId == 0 && e.CasOverride == 0 && !e.Invalidate
+}
+
+// Idempotent is always false, every attempt applies the delta.
+func (e *MetaArithmeticEncoder) Idempotent() bool {
+	return false
+}
+
+func (e *MetaNoOpEncoder) Idempotent() bool {
+	return true
+}
+
+func (e *VersionEncoder) Idempotent() bool {
+	return true
+}
+
+func (e *FlushAllEncoder) Idempotent() bool {
+	return true
+}
+
+func (e *StatsEncoder) Idempotent() bool {
+	return true
+}
+
+func (e *LruCrawlerMetadumpEncoder) Idempotent() bool {
+	return true
+}
+
+// Idempotent reports whether every wrapped request is idempotent.
+func (e *BulkEncoder[T]) Idempotent() bool {
+	for _, encoder := range e.Encoders {
+		if !isIdempotent(encoder) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *classicGetEncoder) Idempotent() bool {
+	return e.meta.Idempotent()
+}
+
+func (e *classicSetEncoder) Idempotent() bool {
+	return e.command == "set"
+}
+
+func (e *classicDeleteEncoder) Idempotent() bool {
+	return e.meta.Idempotent()
+}
+
+func (e *classicArithmeticEncoder) Idempotent() bool {
+	return false
+}
+
+func (e *classicBulkEncoder) Idempotent() bool {
+	for _, encoder := range e.encoders {
+		if !isIdempotent(encoder) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdempotent(encoder codec.LinkEncoder) bool {
+	idempotent, ok := encoder.(codec.IdempotentRequest)
+	return ok && idempotent.Idempotent()
+}
+
+var _ codec.IdempotentRequest = (*MetaGetEncoder)(nil)
+var _ codec.IdempotentRequest = (*MetaSetEncoder)(nil)
+var _ codec.IdempotentRequest = (*MetaDeleteEncoder)(nil)
+var _ codec.IdempotentRequest = (*MetaArithmeticEncoder)(nil)
+var _ codec.IdempotentRequest = (*MetaNoOpEncoder)(nil)
+var _ codec.IdempotentRequest = (*VersionEncoder)(nil)
+var _ codec.IdempotentRequest = (*FlushAllEncoder)(nil)
+var _ codec.IdempotentRequest = (*StatsEncoder)(nil)
+var _ codec.IdempotentRequest = (*LruCrawlerMetadumpEncoder)(nil)
+var _ codec.IdempotentRequest = (*BulkEncoder[*MetaGetEncoder])(nil)
+var _ codec.IdempotentRequest = (*classicBulkEncoder)(nil)
@@ -0,0 +1,76 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec"
+)
+
+func Test_Idempotent(t *testing.T) {
+	get := CreateMetaGetEncoder()
+	get.Reset()
+	get.Key = "foo"
+
+	vivify := CreateMetaGetEncoder()
+	vivify.Reset()
+	vivify.Key = "foo"
+	vivify.BlockTTL = 30
+
+	set := CreateMetaSetEncoder()
+	set.Reset()
+	set.Key = "foo"
+
+	add := CreateMetaSetEncoder()
+	add.Reset()
+	add.Key = "foo"
+	add.Mode = Add
+
+	cas := CreateMetaSetEncoder()
+	cas.Reset()
+	cas.Key = "foo"
+	cas.CasId = 42
+
+	incr := CreateArithmeticEncoder()
+	incr.Reset()
+	incr.Key = "foo"
+
+	bulk := CreateBulkEncoder[*MetaGetEncoder](2)
+	bulk.Encoders = append(bulk.Encoders, get, get)
+
+	mixedBulk := CreateBulkEncoder[*MetaGetEncoder](2)
+	mixedBulk.Encoders = append(mixedBulk.Encoders, get, vivify)
+
+	classicAdd, _, err := ClassicCodec(add, CreateMetaSetDecoder())
+	require.NoError(t, err)
+	classicSet, _, err := ClassicCodec(set, CreateMetaSetDecoder())
+	require.NoError(t, err)
+
+	targs := []struct {
+		name       string
+		encoder    codec.IdempotentRequest
+		idempotent bool
+	}{
+		{name: "get", encoder: get, idempotent: true},
+		{name: "vivifying get", encoder: vivify},
+		{name: "set", encoder: set, idempotent: true},
+		{name: "add", encoder: add},
+		{name: "cas", encoder: cas},
+		{name: "delete", encoder: &MetaDeleteEncoder{Key: "foo", TTL: -1}, idempotent: true},
+		{name: "invalidating delete", encoder: &MetaDeleteEncoder{Key: "foo", TTL: -1, Invalidate: true}},
+		{name: "arithmetic", encoder: incr},
+		{name: "version", encoder: &VersionEncoder{}, idempotent: true},
+		{name: "bulk", encoder: bulk, idempotent: true},
+		{name: "mixed bulk", encoder: mixedBulk},
+		{name: "classic set", encoder: classicSet.(codec.IdempotentRequest), idempotent: true},
+		{name: "classic add", encoder: classicAdd.(codec.IdempotentRequest)},
+	}
+
+	for _, targ := range targs {
+		t.Run(targ.name, func(t *testing.T) {
+			assert.Equal(t, targ.idempotent, targ.encoder.Idempotent())
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package memcache
+
+import (
+	"hash/maphash"
+	"sync"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// internerShards is the largest number of shards of an Interner, so that the decoders of concurrent responses seldom
+// wait for each other.
+const internerShards = 32
+
+// Interner returns shared copies of the strings decoded from the responses, i.e. the item keys returned with the k
+// flag and the header lines of the unknown statuses, so that the workloads fetching the same keys over and over don't
+// allocate a new string for each response. It holds up to a bounded number of strings, split across shards which each
+// start over once full.
+type Interner struct {
+	seed   maphash.Seed
+	shards []internerShard
+}
+
+type internerShard struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	strings map[string]string // protected by mu
+	hits    uint64            // protected by mu
+	misses  uint64            // protected by mu
+
+	// keeps the shards on their own cache lines.
+	_ [64]byte
+}
+
+// InternerStats are the counters of an Interner.
+type InternerStats struct {
+	// Hits is the number of strings which were already interned.
+	Hits uint64
+	// Misses is the number of strings allocated, whether they were interned or not.
+	Misses uint64
+	// Entries is the number of strings interned.
+	Entries int
+}
+
+// NewInterner returns an Interner holding up to maxEntries strings. It doesn't hold any if maxEntries isn't positive.
+func NewInterner(maxEntries int) *Interner {
+	shards := min(max(maxEntries, 1), internerShards)
+	i := &Interner{seed: maphash.MakeSeed(), shards: make([]internerShard, shards)}
+	for s := range i.shards {
+		i.shards[s].maxEntries = maxEntries / shards
+		i.shards[s].strings = make(map[string]string)
+	}
+	return i
+}
+
+// Intern returns b as a string, shared with the previous calls with the same bytes while it's interned. A nil Interner
+// allocates the string every time.
+func (i *Interner) Intern(b []byte) string {
+	if i == nil {
+		return string(b)
+	}
+	shard := &i.shards[maphash.Bytes(i.seed, b)%uint64(len(i.shards))]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	// the conversion of the map index doesn't allocate.
+	if s, ok := shard.strings[string(b)]; ok {
+		shard.hits++
+		return s
+	}
+
+	shard.misses++
+	s := string(b)
+	if shard.maxEntries <= 0 {
+		return s
+	}
+	if len(shard.strings) >= shard.maxEntries {
+		// starting over keeps the strings of the current workload without tracking their use.
+		clear(shard.strings)
+	}
+	shard.strings[s] = s
+	return s
+}
+
+// Stats returns the counters of the Interner.
+func (i *Interner) Stats() InternerStats {
+	var stats InternerStats
+	for s := range i.shards {
+		shard := &i.shards[s]
+		shard.mu.Lock()
+		stats.Hits += shard.hits
+		stats.Misses += shard.misses
+		stats.Entries += len(shard.strings)
+		shard.mu.Unlock()
+	}
+	return stats
+}
+
+// stringsInterner is implemented by the decoders of the responses carrying strings worth interning, see InternStrings.
+type stringsInterner interface {
+	internStrings(i *Interner)
+}
+
+// InternStrings makes d, and the decoders it wraps, intern the strings they decode with i. It's a no-op for the
+// decoders of the responses without such strings.
+func InternStrings(d codec.LinkDecoder, i *Interner) {
+	if interner, ok := d.(stringsInterner); ok {
+		interner.internStrings(i)
+	}
+}
+
+func (d *MetaGetDecoder) internStrings(i *Interner) {
+	d.Interner = i
+}
+
+func (d *MetaSetDecoder) internStrings(i *Interner) {
+	d.Interner = i
+}
+
+func (d *MetaDeleteDecoder) internStrings(i *Interner) {
+	d.Interner = i
+}
+
+func (d *MetaArithmeticDecoder) internStrings(i *Interner) {
+	d.Interner = i
+}
+
+func (d *BulkDecoder[T]) internStrings(i *Interner) {
+	for _, decoder := range d.Decoders {
+		InternStrings(decoder, i)
+	}
+}
+
+// the decoders of a QuietBulkDecoder are created while decoding.
+func (d *QuietBulkDecoder[T]) internStrings(i *Interner) {
+	d.interner = i
+}
+
+func (d *BarrierDecoder) internStrings(i *Interner) {
+	for _, group := range d.Groups {
+		for _, decoder := range group.Decoders {
+			InternStrings(decoder, i)
+		}
+	}
+}
+
+var _ stringsInterner = (*MetaGetDecoder)(nil)
+var _ stringsInterner = (*MetaSetDecoder)(nil)
+var _ stringsInterner = (*MetaDeleteDecoder)(nil)
+var _ stringsInterner = (*MetaArithmeticDecoder)(nil)
+var _ stringsInterner = (*BulkDecoder[*MetaGetDecoder])(nil)
+var _ stringsInterner = (*QuietBulkDecoder[*MetaSetDecoder])(nil)
+var _ stringsInterner = (*BarrierDecoder)(nil)
@@ -0,0 +1,100 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterner(t *testing.T) {
+	i := NewInterner(1)
+
+	a := i.Intern([]byte("key-a"))
+	assert.Equal(t, "key-a", a)
+	assert.Same(t, unsafe.StringData(a), unsafe.StringData(i.Intern([]byte("key-a"))))
+	assert.Equal(t, InternerStats{Hits: 1, Misses: 1, Entries: 1}, i.Stats())
+
+	// once full, it starts over.
+	i.Intern([]byte("key-b"))
+	assert.Equal(t, 1, i.Stats().Entries)
+	assert.NotSame(t, unsafe.StringData(a), unsafe.StringData(i.Intern([]byte("key-a"))))
+
+	// without room, nothing is interned.
+	none := NewInterner(0)
+	none.Intern([]byte("key-a"))
+	assert.Equal(t, InternerStats{Misses: 1}, none.Stats())
+
+	// nor without an Interner.
+	var unset *Interner
+	assert.Equal(t, "key-a", unset.Intern([]byte("key-a")))
+}
+
+func TestInterner_Shards(t *testing.T) {
+	i := NewInterner(1000)
+	assert.Len(t, i.shards, internerShards)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 1000; n++ {
+				assert.Equal(t, strconv.Itoa(n%100), i.Intern([]byte(strconv.Itoa(n%100))))
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := i.Stats()
+	assert.Equal(t, uint64(8000), stats.Hits+stats.Misses)
+	assert.LessOrEqual(t, stats.Entries, 1000)
+}
+
+func TestDecodersIntern(t *testing.T) {
+	// a single shard, holding the last string.
+	interner := NewInterner(1)
+
+	decode := func(response string) *MetaGetDecoder {
+		d := CreateMetaGetDecoder()
+		InternStrings(d, interner)
+		require.NoError(t, d.Decode(bufio.NewReader(bytes.NewReader([]byte(response)))))
+		return d
+	}
+	first, second := decode("HD kkey\r\n"), decode("HD kkey\r\n")
+	assert.Equal(t, "key", first.ItemKey)
+	assert.Same(t, unsafe.StringData(first.ItemKey), unsafe.StringData(second.ItemKey))
+
+	first, second = decode("SERVER_ERROR out of memory\r\n"), decode("SERVER_ERROR out of memory\r\n")
+	assert.Equal(t, ServerError, first.Status)
+	assert.Same(t, unsafe.StringData(first.HdrLine), unsafe.StringData(second.HdrLine))
+
+	assert.Equal(t, InternerStats{Hits: 2, Misses: 2, Entries: 1}, interner.Stats())
+}
+
+func TestInternStrings(t *testing.T) {
+	interner := NewInterner(16)
+	response := "HD kkey\r\nMN\r\n"
+
+	bulk := CreateBulkDecoder[*MetaSetDecoder](1)
+	bulk.Decoders = append(bulk.Decoders, CreateMetaSetDecoder())
+	InternStrings(bulk, interner)
+	require.NoError(t, bulk.Decode(bufio.NewReader(bytes.NewReader([]byte(response)))))
+
+	quiet := CreateQuietBulkDecoder(CreateMetaSetDecoder)
+	InternStrings(quiet, interner)
+	require.NoError(t, quiet.Decode(bufio.NewReader(bytes.NewReader([]byte(response)))))
+	require.Len(t, quiet.Decoders, 1)
+	assert.Same(t, unsafe.StringData(bulk.Decoders[0].ItemKey), unsafe.StringData(quiet.Decoders[0].ItemKey))
+
+	// the decoders stop interning once reset.
+	quiet.Reset()
+	require.NoError(t, quiet.Decode(bufio.NewReader(bytes.NewReader([]byte(response)))))
+	assert.Nil(t, quiet.Decoders[0].Interner)
+	assert.Equal(t, InternerStats{Hits: 1, Misses: 1, Entries: 1}, interner.Stats())
+}
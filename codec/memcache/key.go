@@ -0,0 +1,107 @@
+package memcache
+
+import (
+	"bytes"
+	"encoding/base64"
+	"hash/fnv"
+)
+
+// maxKeyLength is the longest key memcached accepts, base64 encoded keys included.
+const maxKeyLength = 250
+
+// Key is a memcached key validated once, so it can be reused across operations without paying for the validation,
+// the base64 encoding and the hashing on every encode. The zero value is not a valid key and is ignored by encoders.
+type Key struct {
+	raw    string
+	wire   string
+	base64 bool
+	hash   uint64
+}
+
+// NewKey validates key, returning an *IllegaleMemcacheKey error if it can't be sent to memcached as is.
+func NewKey(key string) (Key, error) {
+	if err := ValidateKey(key); err != nil {
+		return Key{}, err
+	}
+	if key == "" {
+		return Key{}, &IllegaleMemcacheKey{IllegalKey: key}
+	}
+	return Key{raw: key, wire: key, hash: hashKey(key)}, nil
+}
+
+// NewBinaryKey creates a key from arbitrary bytes, which are sent base64 encoded along with the b flag.
+func NewBinaryKey(key []byte) (Key, error) {
+	wire := base64.StdEncoding.EncodeToString(key)
+	if len(key) == 0 || len(wire) > maxKeyLength {
+		return Key{}, &IllegaleMemcacheKey{IllegalKey: string(key)}
+	}
+	return Key{raw: string(key), wire: wire, base64: true, hash: hashKey(string(key))}, nil
+}
+
+// String returns the key as provided, i.e. not base64 encoded.
+func (k Key) String() string {
+	return k.raw
+}
+
+// Wire returns the key as sent to memcached.
+func (k Key) Wire() string {
+	return k.wire
+}
+
+// Base64 reports whether the key is sent base64 encoded.
+func (k Key) Base64() bool {
+	return k.base64
+}
+
+// Hash returns the FNV-1a hash of the key, computed once.
+func (k Key) Hash() uint64 {
+	return k.hash
+}
+
+// IsZero reports whether k is the zero value.
+func (k Key) IsZero() bool {
+	return k.wire == ""
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// encodeKey writes validated if it's set, binary base64 encoded if it's not nil, and key otherwise, which is validated
+// first. It returns whether the b flag has to be sent along with the key.
+func encodeKey(b *bytes.Buffer, key string, binary []byte, validated Key) (bool, error) {
+	if !validated.IsZero() {
+		b.WriteString(validated.wire)
+		b.WriteByte(Space)
+		return validated.base64, nil
+	}
+	if binary != nil {
+		return true, writeBinaryKey(b, binary)
+	}
+	return false, writeKey(b, key)
+}
+
+// writeBinaryKey base64 encodes key straight into b, without the intermediate string of NewBinaryKey.
+func writeBinaryKey(b *bytes.Buffer, key []byte) error {
+	n := base64.StdEncoding.EncodedLen(len(key))
+	if len(key) == 0 || n > maxKeyLength {
+		return &IllegaleMemcacheKey{IllegalKey: string(key)}
+	}
+
+	b.Grow(n + 1)
+	wire := b.AvailableBuffer()[:n]
+	base64.StdEncoding.Encode(wire, key)
+	b.Write(wire)
+	b.WriteByte(Space)
+	return nil
+}
+
+// itemKeyBytes returns the key returned by memcached, base64 decoded if the b flag was returned along with it.
+func itemKeyBytes(itemKey string, base64Key bool) ([]byte, error) {
+	if !base64Key {
+		return []byte(itemKey), nil
+	}
+	return base64.StdEncoding.DecodeString(itemKey)
+}
@@ -0,0 +1,166 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewKey(t *testing.T) {
+	key, err := NewKey("user:1")
+	require.NoError(t, err)
+	assert.Equal(t, "user:1", key.String())
+	assert.Equal(t, "user:1", key.Wire())
+	assert.False(t, key.Base64())
+	assert.Equal(t, hashKey("user:1"), key.Hash())
+	assert.False(t, key.IsZero())
+
+	for _, illegal := range []string{"", "with space", string(make([]byte, 251))} {
+		_, err := NewKey(illegal)
+		var illegalErr *IllegaleMemcacheKey
+		assert.ErrorAs(t, err, &illegalErr, illegal)
+	}
+}
+
+func Test_NewBinaryKey(t *testing.T) {
+	key, err := NewBinaryKey([]byte("with space"))
+	require.NoError(t, err)
+	assert.Equal(t, "with space", key.String())
+	assert.Equal(t, "d2l0aCBzcGFjZQ==", key.Wire())
+	assert.True(t, key.Base64())
+
+	_, err = NewBinaryKey(nil)
+	assert.Error(t, err)
+	_, err = NewBinaryKey(make([]byte, 200))
+	assert.Error(t, err)
+}
+
+func Test_EncodersUseValidatedKey(t *testing.T) {
+	plain, err := NewKey("foo")
+	require.NoError(t, err)
+	binary, err := NewBinaryKey([]byte("a b"))
+	require.NoError(t, err)
+
+	getEncoder := CreateMetaGetEncoder()
+	getEncoder.Reset()
+	getEncoder.Key = "ignored key"
+	getEncoder.ValidatedKey = plain
+
+	setEncoder := CreateMetaSetEncoder()
+	setEncoder.Reset()
+	setEncoder.ValidatedKey = binary
+	setEncoder.Value = []byte("v")
+
+	deleteEncoder := CreateMetaDeleteEncoder()
+	deleteEncoder.Reset()
+	deleteEncoder.ValidatedKey = binary
+
+	arithEncoder := CreateArithmeticEncoder()
+	arithEncoder.Reset()
+	arithEncoder.ValidatedKey = plain
+
+	data := &bytes.Buffer{}
+	writer := bufio.NewWriter(data)
+	assert.NoError(t, getEncoder.Encode(writer))
+	assert.NoError(t, setEncoder.Encode(writer))
+	assert.NoError(t, deleteEncoder.Encode(writer))
+	assert.NoError(t, arithEncoder.Encode(writer))
+	assert.NoError(t, writer.Flush())
+
+	lines := bytes.Split(data.Bytes(), []byte("\r\n"))
+	assert.Equal(t, "mg foo ", string(lines[0]))
+	assert.Equal(t, "ms YSBi 1 b ", string(lines[1]))
+	assert.Equal(t, "md YSBi b ", string(lines[3]))
+	assert.True(t, bytes.HasPrefix(lines[4], []byte("ma foo ")))
+
+	getEncoder.Reset()
+	assert.True(t, getEncoder.ValidatedKey.IsZero())
+}
+
+func Test_EncodersUseBinaryKey(t *testing.T) {
+	binary := []byte{0xde, 0xad, ' ', 0xbe, 0xef}
+
+	getEncoder := CreateMetaGetEncoder()
+	getEncoder.Reset()
+	getEncoder.Key = "ignored key"
+	getEncoder.BinaryKey = binary
+
+	setEncoder := CreateMetaSetEncoder()
+	setEncoder.Reset()
+	setEncoder.BinaryKey = binary
+	setEncoder.Value = []byte("v")
+
+	deleteEncoder := CreateMetaDeleteEncoder()
+	deleteEncoder.Reset()
+	deleteEncoder.BinaryKey = binary
+
+	arithEncoder := CreateArithmeticEncoder()
+	arithEncoder.Reset()
+	arithEncoder.BinaryKey = binary
+	// the validated key takes precedence over the binary one.
+	arithEncoder.ValidatedKey, _ = NewKey("foo")
+
+	data := &bytes.Buffer{}
+	writer := bufio.NewWriter(data)
+	assert.NoError(t, getEncoder.Encode(writer))
+	assert.NoError(t, setEncoder.Encode(writer))
+	assert.NoError(t, deleteEncoder.Encode(writer))
+	assert.NoError(t, arithEncoder.Encode(writer))
+	assert.NoError(t, writer.Flush())
+
+	lines := bytes.Split(data.Bytes(), []byte("\r\n"))
+	assert.Equal(t, "mg 3q0gvu8= b ", string(lines[0]))
+	assert.Equal(t, "ms 3q0gvu8= 1 b ", string(lines[1]))
+	assert.Equal(t, "md 3q0gvu8= b ", string(lines[3]))
+	assert.True(t, bytes.HasPrefix(lines[4], []byte("ma foo ")))
+	assert.Equal(t, string(binary), getEncoder.RoutingKey())
+
+	for _, illegal := range [][]byte{{}, make([]byte, 200)} {
+		getEncoder.BinaryKey = illegal
+		var illegalErr *IllegaleMemcacheKey
+		assert.ErrorAs(t, getEncoder.Encode(writer), &illegalErr)
+	}
+
+	getEncoder.Reset()
+	assert.Nil(t, getEncoder.BinaryKey)
+}
+
+func Test_BinaryKeyEncodeAllocs(t *testing.T) {
+	encoder := CreateMetaGetEncoder()
+	encoder.Reset()
+	encoder.BinaryKey = bytes.Repeat([]byte{0xff}, 32)
+	encoder.FetchValue = true
+	writer := bufio.NewWriter(&bytes.Buffer{})
+	scratch := &bytes.Buffer{}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		scratch.Reset()
+		_ = encoder.EncodeWithScratch(writer, scratch)
+		_ = writer.Flush()
+	})
+	assert.Zero(t, allocs)
+}
+
+func Test_DecodersReturnBinaryItemKey(t *testing.T) {
+	decoder := CreateMetaGetDecoder()
+	require.NoError(t, decoder.Decode(bufio.NewReader(bytes.NewBufferString("HD k3q0gvu8= b\r\n"))))
+	assert.True(t, decoder.ItemKeyBase64)
+	key, err := decoder.ItemKeyBytes()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xde, 0xad, ' ', 0xbe, 0xef}, key)
+
+	setDecoder := CreateMetaSetDecoder()
+	require.NoError(t, setDecoder.Decode(bufio.NewReader(bytes.NewBufferString("HD b k3q0gvu8=\r\n"))))
+	key, err = setDecoder.ItemKeyBytes()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xde, 0xad, ' ', 0xbe, 0xef}, key)
+
+	deleteDecoder := CreateMetaDeleteDecoder()
+	require.NoError(t, deleteDecoder.Decode(bufio.NewReader(bytes.NewBufferString("HD kfoo\r\n"))))
+	key, err = deleteDecoder.ItemKeyBytes()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("foo"), key)
+}
@@ -0,0 +1,126 @@
+package memcache
+
+import (
+	"strconv"
+
+	"github.com/stripe/memlink/codec"
+)
+
+const (
+	modeLabel      = "mode"
+	sizeLabel      = "size_bucket"
+	batchSizeLabel = "batch_size_bucket"
+)
+
+var (
+	// valueSizeBuckets are the upper bounds, in bytes, of the size_bucket label of the values written. They grow by 4x
+	// up to memcached's default item size limit.
+	valueSizeBuckets = []int{64, 256, 1024, 4 * 1024, 16 * 1024, 64 * 1024, 256 * 1024, 1024 * 1024}
+	// batchSizeBuckets are the upper bounds of the batch_size_bucket label of the bulk requests.
+	batchSizeBuckets = []int{1, 10, 100, 1000}
+)
+
+// bucket returns the smallest of the upper bounds n fits in, +Inf if it doesn't fit in any.
+func bucket(upperBounds []int, n int) string {
+	for _, upperBound := range upperBounds {
+		if n <= upperBound {
+			return strconv.Itoa(upperBound)
+		}
+	}
+	return "+Inf"
+}
+
+func setLabels(mode MetaSetMode, size int) []codec.MetricLabel {
+	if mode == "" {
+		mode = "set"
+	}
+	return []codec.MetricLabel{
+		{Name: modeLabel, Value: string(mode)},
+		{Name: sizeLabel, Value: bucket(valueSizeBuckets, size)},
+	}
+}
+
+func (e *MetaGetEncoder) OperationLabels() (string, []codec.MetricLabel) {
+	return "mg", nil
+}
+
+func (e *MetaSetEncoder) OperationLabels() (string, []codec.MetricLabel) {
+	return "ms", setLabels(e.Mode, len(e.Value))
+}
+
+func (e *MetaDeleteEncoder) OperationLabels() (string, []codec.MetricLabel) {
+	return "md", nil
+}
+
+func (e *MetaArithmeticEncoder) OperationLabels() (string, []codec.MetricLabel) {
+	return "ma", nil
+}
+
+func (e *MetaNoOpEncoder) OperationLabels() (string, []codec.MetricLabel) {
+	return "mn", nil
+}
+
+func (e *VersionEncoder) OperationLabels() (string, []codec.MetricLabel) {
+	return "version", nil
+}
+
+func (e *FlushAllEncoder) OperationLabels() (string, []codec.MetricLabel) {
+	return "flush_all", nil
+}
+
+func (e *StatsEncoder) OperationLabels() (string, []codec.MetricLabel) {
+	return "stats", nil
+}
+
+func (e *LruCrawlerMetadumpEncoder) OperationLabels() (string, []codec.MetricLabel) {
+	return "lru_crawler_metadump", nil
+}
+
+// OperationLabels names the operation after the one of the first request, the batch size being bucketed.
+func (e *BulkEncoder[T]) OperationLabels() (string, []codec.MetricLabel) {
+	return labelBulk(len(e.Encoders), func(i int) codec.LinkEncoder { return e.Encoders[i] })
+}
+
+func (e *classicGetEncoder) OperationLabels() (string, []codec.MetricLabel) {
+	operation, _ := e.Describe()
+	return operation, nil
+}
+
+func (e *classicSetEncoder) OperationLabels() (string, []codec.MetricLabel) {
+	return "set", setLabels(e.meta.Mode, len(e.meta.Value))
+}
+
+func (e *classicDeleteEncoder) OperationLabels() (string, []codec.MetricLabel) {
+	return "delete", nil
+}
+
+func (e *classicArithmeticEncoder) OperationLabels() (string, []codec.MetricLabel) {
+	operation, _ := e.Describe()
+	return operation, nil
+}
+
+func (e *classicBulkEncoder) OperationLabels() (string, []codec.MetricLabel) {
+	return labelBulk(len(e.encoders), func(i int) codec.LinkEncoder { return e.encoders[i] })
+}
+
+func labelBulk(n int, encoder func(i int) codec.LinkEncoder) (string, []codec.MetricLabel) {
+	operation := "unknown"
+	if n > 0 {
+		if labeler, ok := encoder(0).(codec.OperationLabeler); ok {
+			operation, _ = labeler.OperationLabels()
+		}
+	}
+	return "bulk_" + operation, []codec.MetricLabel{{Name: batchSizeLabel, Value: bucket(batchSizeBuckets, n)}}
+}
+
+var _ codec.OperationLabeler = (*MetaGetEncoder)(nil)
+var _ codec.OperationLabeler = (*MetaSetEncoder)(nil)
+var _ codec.OperationLabeler = (*MetaDeleteEncoder)(nil)
+var _ codec.OperationLabeler = (*MetaArithmeticEncoder)(nil)
+var _ codec.OperationLabeler = (*MetaNoOpEncoder)(nil)
+var _ codec.OperationLabeler = (*VersionEncoder)(nil)
+var _ codec.OperationLabeler = (*FlushAllEncoder)(nil)
+var _ codec.OperationLabeler = (*StatsEncoder)(nil)
+var _ codec.OperationLabeler = (*LruCrawlerMetadumpEncoder)(nil)
+var _ codec.OperationLabeler = (*BulkEncoder[*MetaGetEncoder])(nil)
+var _ codec.OperationLabeler = (*classicBulkEncoder)(nil)
@@ -0,0 +1,74 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec"
+)
+
+func Test_OperationLabels(t *testing.T) {
+	set := CreateMetaSetEncoder()
+	set.Reset()
+	set.Key = "foo"
+	set.Value = make([]byte, 100)
+
+	add := CreateMetaSetEncoder()
+	add.Reset()
+	add.Key = "foo"
+	add.Value = make([]byte, 2*1024*1024)
+	add.Mode = Add
+
+	get := CreateMetaGetEncoder()
+	get.Reset()
+	get.Key = "foo"
+	bulk := CreateBulkEncoder[*MetaGetEncoder](11)
+	for i := 0; i < 11; i++ {
+		bulk.Encoders = append(bulk.Encoders, get)
+	}
+
+	classicSet, _, err := ClassicCodec(&MetaSetEncoder{Key: "foo", Value: []byte("1"), TTL: -1, BlockTTL: -1}, CreateMetaSetDecoder())
+	require.NoError(t, err)
+
+	targs := []struct {
+		name              string
+		encoder           codec.LinkEncoder
+		expectedOperation string
+		expectedLabels    []codec.MetricLabel
+	}{
+		{name: "meta get", encoder: get, expectedOperation: "mg"},
+		{
+			name: "meta set", encoder: set, expectedOperation: "ms",
+			expectedLabels: []codec.MetricLabel{{Name: "mode", Value: "set"}, {Name: "size_bucket", Value: "256"}},
+		},
+		{
+			name: "large add", encoder: add, expectedOperation: "ms",
+			expectedLabels: []codec.MetricLabel{{Name: "mode", Value: "add"}, {Name: "size_bucket", Value: "+Inf"}},
+		},
+		{
+			name: "bulk", encoder: bulk, expectedOperation: "bulk_mg",
+			expectedLabels: []codec.MetricLabel{{Name: "batch_size_bucket", Value: "100"}},
+		},
+		{
+			name: "empty bulk", encoder: CreateBulkEncoder[*MetaGetEncoder](0), expectedOperation: "bulk_unknown",
+			expectedLabels: []codec.MetricLabel{{Name: "batch_size_bucket", Value: "1"}},
+		},
+		{
+			name: "classic", encoder: classicSet, expectedOperation: "set",
+			expectedLabels: []codec.MetricLabel{{Name: "mode", Value: "set"}, {Name: "size_bucket", Value: "64"}},
+		},
+		{name: "admin", encoder: CreateStatsEncoder(), expectedOperation: "stats"},
+	}
+
+	for _, tt := range targs {
+		t.Run(tt.name, func(t *testing.T) {
+			labeler, ok := tt.encoder.(codec.OperationLabeler)
+			require.True(t, ok)
+			operation, labels := labeler.OperationLabels()
+			assert.Equal(t, tt.expectedOperation, operation)
+			assert.Equal(t, tt.expectedLabels, labels)
+		})
+	}
+}
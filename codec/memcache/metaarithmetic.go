@@ -1,13 +1,13 @@
 package memcache
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"io"
 	"strconv"
 
 	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/internal/debugcheck"
 )
 
 /*
@@ -32,6 +32,8 @@ The flags used by the 'ma' command are:
 */
 type MetaArithmeticEncoder struct {
 	Key               string
+	ValidatedKey      Key    // takes precedence over Key when set.
+	BinaryKey         []byte // sent base64 encoded with the b flag, takes precedence over Key when not nil.
 	Base64EncodedKey  bool
 	CasId             uint64 // only non-zero value is valid
 	CasOverride       uint64 // only non-zero value is valid
@@ -45,18 +47,24 @@ type MetaArithmeticEncoder struct {
 	FetchCasId        bool
 	FetchValue        bool
 	FetchKey          bool
+	ConvertLongTTLs   bool // sends the TTLs longer than MaxRelativeTTL as the unix timestamp they're relative to.
 }
 
-func (e *MetaArithmeticEncoder) Encode(writer *bufio.Writer) error {
+func (e *MetaArithmeticEncoder) Encode(writer codec.Writer) error {
 	b := bytePool.Get()
 	defer bytePool.Put(b)
+	return e.EncodeWithScratch(writer, b)
+}
+
+func (e *MetaArithmeticEncoder) EncodeWithScratch(writer codec.Writer, b *bytes.Buffer) error {
 	b.Write(MetaArithmetic)
 
-	if keyErr := writeKey(b, e.Key); keyErr != nil {
+	base64Key, keyErr := encodeKey(b, e.Key, e.BinaryKey, e.ValidatedKey)
+	if keyErr != nil {
 		return keyErr
 	}
 
-	if e.Base64EncodedKey {
+	if e.Base64EncodedKey || base64Key {
 		b.Write(Base64EncodedKey)
 	}
 
@@ -93,8 +101,8 @@ func (e *MetaArithmeticEncoder) Encode(writer *bufio.Writer) error {
 	// mg /slo/dykeyspace///dytest4 v t
 	// VA 3 t148
 	// 123
-	writeTTL(b, e.TTL)
-	writeBlockTTL(b, e.BlockTTL)
+	writeTTL(b, e.TTL, e.ConvertLongTTLs)
+	writeBlockTTL(b, e.BlockTTL, e.ConvertLongTTLs)
 	writeInitialValue(b, e.InitialValue)
 	writeDelta(b, e.Delta)
 	writeOpaque(b, e.Opaque)
@@ -106,7 +114,10 @@ func (e *MetaArithmeticEncoder) Encode(writer *bufio.Writer) error {
 }
 
 func (e *MetaArithmeticEncoder) Reset() {
+	debugcheck.Reset(e)
 	e.Key = ""
+	e.ValidatedKey = Key{}
+	e.BinaryKey = nil
 	e.Base64EncodedKey = false
 	e.CasId = 0
 	e.CasOverride = 0
@@ -120,6 +131,7 @@ func (e *MetaArithmeticEncoder) Reset() {
 	e.FetchValue = false
 	e.FetchCasId = false
 	e.FetchKey = false
+	e.ConvertLongTTLs = false
 }
 
 type MetaArithmeticDecoder struct {
@@ -130,11 +142,15 @@ type MetaArithmeticDecoder struct {
 	ValueUInt64         uint64 // just a parsed value from the Value above.
 	CasId               uint64 // only non-zero value is valid.
 	ItemKey             string
+	ItemKeyBase64       bool // the b flag was returned, ItemKey is base64 encoded.
 
 	HdrLine string
+
+	// Interner interns the item key and the header line of the unknown statuses, which are allocated again if nil.
+	Interner *Interner
 }
 
-func (d *MetaArithmeticDecoder) Decode(reader *bufio.Reader) error {
+func (d *MetaArithmeticDecoder) Decode(reader codec.Reader) error {
 	hdrLine, err := reader.ReadSlice('\n')
 	if err != nil {
 		return err
@@ -144,10 +160,10 @@ func (d *MetaArithmeticDecoder) Decode(reader *bufio.Reader) error {
 	for idx, elem := range bytes.Fields(hdrLine) {
 		if idx == 0 {
 			d.Status = ArithmeticStatusFromHeader(elem)
-			if d.Status == MetadataStatusInvalid {
-				// If we get an unknown response code, we can't further parse the header line.
+			if !d.Status.hasMetadata() {
+				// If we get an error, MN or an unknown response code, we can't further parse the header line.
 				// store it for logging and move on.
-				d.HdrLine = string(hdrLine)
+				d.HdrLine = d.Interner.Intern(hdrLine)
 				return nil
 			}
 			continue
@@ -183,7 +199,9 @@ func (d *MetaArithmeticDecoder) Decode(reader *bufio.Reader) error {
 				d.CasId = c
 			}
 		case 'k':
-			d.ItemKey = string(elem[1:])
+			d.ItemKey = d.Interner.Intern(elem[1:])
+		case 'b':
+			d.ItemKeyBase64 = true
 		}
 	}
 
@@ -223,16 +241,26 @@ func (d *MetaArithmeticDecoder) Reset() {
 	d.ValueUInt64 = 0
 	d.CasId = 0
 	d.ItemKey = ""
+	d.ItemKeyBase64 = false
 	d.HdrLine = ""
+	d.Interner = nil
+}
+
+// ItemKeyBytes returns ItemKey, base64 decoded if it was returned along with the b flag.
+func (d *MetaArithmeticDecoder) ItemKeyBytes() ([]byte, error) {
+	return itemKeyBytes(d.ItemKey, d.ItemKeyBase64)
 }
 
 var _ codec.LinkEncoder = (*MetaArithmeticEncoder)(nil)
+var _ codec.ScratchEncoder = (*MetaArithmeticEncoder)(nil)
 var _ codec.LinkDecoder = (*MetaArithmeticDecoder)(nil)
 
 type MetaArithmeticTarget func(decoder *MetaArithmeticDecoder, opaque uint64) error
 
 func CreateArithmeticEncoder() *MetaArithmeticEncoder {
-	return &MetaArithmeticEncoder{}
+	e := &MetaArithmeticEncoder{}
+	debugcheck.Created(e)
+	return e
 }
 
 func CreateArithmeticDecoder() *MetaArithmeticDecoder {
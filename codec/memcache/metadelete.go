@@ -1,12 +1,12 @@
 package memcache
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"strconv"
 
 	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/internal/debugcheck"
 )
 
 /*
@@ -26,6 +26,8 @@ The flags used by the 'md' command are:
 */
 type MetaDeleteEncoder struct {
 	Key              string
+	ValidatedKey     Key    // takes precedence over Key when set.
+	BinaryKey        []byte // sent base64 encoded with the b flag, takes precedence over Key when not nil.
 	Base64EncodedKey bool
 	CasId            uint64 // only non-zero value is valid
 	CasOverride      uint64 // only non-zero value is valid.
@@ -35,18 +37,24 @@ type MetaDeleteEncoder struct {
 	TTL              int32  // negative values are ignored.
 	ClientFlags      uint64 // only non-zero value is valid.
 	RemoveValue      bool
+	ConvertLongTTLs  bool // sends the TTLs longer than MaxRelativeTTL as the unix timestamp they're relative to.
 }
 
-func (e *MetaDeleteEncoder) Encode(writer *bufio.Writer) error {
+func (e *MetaDeleteEncoder) Encode(writer codec.Writer) error {
 	b := bytePool.Get()
 	defer bytePool.Put(b)
+	return e.EncodeWithScratch(writer, b)
+}
+
+func (e *MetaDeleteEncoder) EncodeWithScratch(writer codec.Writer, b *bytes.Buffer) error {
 	b.Write(MetaDelete)
 
-	if keyErr := writeKey(b, e.Key); keyErr != nil {
+	base64Key, keyErr := encodeKey(b, e.Key, e.BinaryKey, e.ValidatedKey)
+	if keyErr != nil {
 		return keyErr
 	}
 
-	if e.Base64EncodedKey {
+	if e.Base64EncodedKey || base64Key {
 		b.Write(Base64EncodedKey)
 	}
 
@@ -64,7 +72,7 @@ func (e *MetaDeleteEncoder) Encode(writer *bufio.Writer) error {
 
 	writeCasId(b, e.CasId)
 	writeCasOverride(b, e.CasOverride)
-	writeTTL(b, e.TTL)
+	writeTTL(b, e.TTL, e.ConvertLongTTLs)
 	writeClientFlags(b, e.ClientFlags)
 	writeOpaque(b, e.Opaque)
 
@@ -78,7 +86,10 @@ func (e *MetaDeleteEncoder) Reset() {
 	if e == nil {
 		return
 	}
+	debugcheck.Reset(e)
 	e.Key = ""
+	e.ValidatedKey = Key{}
+	e.BinaryKey = nil
 	e.Base64EncodedKey = false
 	e.CasId = 0
 	e.CasOverride = 0
@@ -88,17 +99,22 @@ func (e *MetaDeleteEncoder) Reset() {
 	e.TTL = -1
 	e.ClientFlags = 0
 	e.RemoveValue = false
+	e.ConvertLongTTLs = false
 }
 
 type MetaDeleteDecoder struct {
-	Status  MetadataStatus
-	Opaque  uint64
-	ItemKey string
+	Status        MetadataStatus
+	Opaque        uint64
+	ItemKey       string
+	ItemKeyBase64 bool // the b flag was returned, ItemKey is base64 encoded.
 
 	HdrLine string
+
+	// Interner interns the item key and the header line of the unknown statuses, which are allocated again if nil.
+	Interner *Interner
 }
 
-func (d *MetaDeleteDecoder) Decode(reader *bufio.Reader) error {
+func (d *MetaDeleteDecoder) Decode(reader codec.Reader) error {
 	hdrLine, err := reader.ReadSlice('\n')
 	if err != nil {
 		return err
@@ -107,10 +123,10 @@ func (d *MetaDeleteDecoder) Decode(reader *bufio.Reader) error {
 	for idx, elem := range bytes.Fields(hdrLine) {
 		if idx == 0 {
 			d.Status = MetaDeleteStatusFromHeader(elem)
-			if d.Status == MetadataStatusInvalid {
-				// If we get an unknown response code, we can't further parse the header line.
+			if !d.Status.hasMetadata() {
+				// If we get an error, MN or an unknown response code, we can't further parse the header line.
 				// store it for logging and move on.
-				d.HdrLine = string(hdrLine)
+				d.HdrLine = d.Interner.Intern(hdrLine)
 				return nil
 			}
 			continue
@@ -124,7 +140,9 @@ func (d *MetaDeleteDecoder) Decode(reader *bufio.Reader) error {
 				d.Opaque = o
 			}
 		case 'k':
-			d.ItemKey = string(elem[1:])
+			d.ItemKey = d.Interner.Intern(elem[1:])
+		case 'b':
+			d.ItemKeyBase64 = true
 		}
 	}
 
@@ -139,16 +157,26 @@ func (d *MetaDeleteDecoder) Reset() {
 	d.Status = MetadataStatusInvalid
 	d.Opaque = 0
 	d.ItemKey = ""
+	d.ItemKeyBase64 = false
 	d.HdrLine = ""
+	d.Interner = nil
+}
+
+// ItemKeyBytes returns ItemKey, base64 decoded if it was returned along with the b flag.
+func (d *MetaDeleteDecoder) ItemKeyBytes() ([]byte, error) {
+	return itemKeyBytes(d.ItemKey, d.ItemKeyBase64)
 }
 
 var _ codec.LinkEncoder = (*MetaDeleteEncoder)(nil)
+var _ codec.ScratchEncoder = (*MetaDeleteEncoder)(nil)
 var _ codec.LinkDecoder = (*MetaDeleteDecoder)(nil)
 
 type MetaDeleteTarget func(decoder *MetaDeleteDecoder, opaque uint64) error
 
 func CreateMetaDeleteEncoder() *MetaDeleteEncoder {
-	return &MetaDeleteEncoder{}
+	e := &MetaDeleteEncoder{}
+	debugcheck.Created(e)
+	return e
 }
 
 func CreateMetaDeleteDecoder() *MetaDeleteDecoder {
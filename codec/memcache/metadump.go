@@ -0,0 +1,165 @@
+package memcache
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/stripe/memlink/codec"
+)
+
+var (
+	LruCrawlerMetadump = []byte("lru_crawler metadump ")
+	EndResponse        = []byte("END\r\n")
+)
+
+/*
+LruCrawlerMetadumpEncoder command format: lru_crawler metadump <classid,classid,classid|all|hash>\r\n
+
+The crawler walks the LRU of the requested slab classes and dumps the metadata of every item, one per line,
+terminated by an END line. The dump is streamed by the server while the crawl progresses, so it can take a long
+time on large caches and should be issued sparingly.
+*/
+type LruCrawlerMetadumpEncoder struct {
+	Classes string // "all", "hash" or a comma separated list of slab class ids. Defaults to "all".
+}
+
+func (e *LruCrawlerMetadumpEncoder) Encode(writer codec.Writer) error {
+	b := bytePool.Get()
+	defer bytePool.Put(b)
+	return e.EncodeWithScratch(writer, b)
+}
+
+func (e *LruCrawlerMetadumpEncoder) EncodeWithScratch(writer codec.Writer, b *bytes.Buffer) error {
+
+	b.Write(LruCrawlerMetadump)
+	if e.Classes == "" {
+		b.WriteString("all")
+	} else {
+		b.WriteString(e.Classes)
+	}
+	b.Write(CRLF)
+
+	_, err := writer.Write(b.Bytes())
+	return err
+}
+
+func (e *LruCrawlerMetadumpEncoder) Reset() {
+	if e == nil {
+		return
+	}
+	e.Classes = ""
+}
+
+// MetadumpEntry is the metadata of a single item as reported by lru_crawler metadump.
+type MetadumpEntry struct {
+	Key         string // url-decoded key.
+	Expiry      int64  // unix timestamp at which the item expires, -1 if it never expires.
+	LastAccess  int64  // unix timestamp of the last access.
+	CasId       uint64
+	Fetched     bool // whether the item has been fetched before.
+	SlabClass   uint32
+	SizeInBytes uint64
+}
+
+type LruCrawlerMetadumpDecoder struct {
+	Entries []MetadumpEntry
+
+	// OnEntry, when set, is called with every entry as it's read instead of accumulating them in Entries, so that the
+	// dump of a large cache isn't held in memory. It's called on the routine reading the connection, which it holds
+	// back. Once it fails, the remaining entries are read without being passed to it.
+	OnEntry func(entry MetadumpEntry) error
+	// Err is the error OnEntry failed with.
+	Err error
+
+	// HdrLine is set if the server refused to dump, e.g. "BUSY currently processing crawler request".
+	HdrLine string
+}
+
+func (d *LruCrawlerMetadumpDecoder) Decode(reader codec.Reader) error {
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(line, EndResponse) {
+			return nil
+		}
+
+		if !bytes.HasPrefix(line, []byte("key=")) {
+			// BUSY, ERROR and the like are single line responses.
+			d.HdrLine = string(line)
+			return nil
+		}
+
+		entry, pErr := parseMetadumpEntry(line)
+		if pErr != nil {
+			return pErr
+		}
+		switch {
+		case d.OnEntry == nil:
+			d.Entries = append(d.Entries, entry)
+		case d.Err == nil:
+			d.Err = d.OnEntry(entry)
+		}
+	}
+}
+
+func parseMetadumpEntry(line []byte) (MetadumpEntry, error) {
+	entry := MetadumpEntry{}
+	for _, field := range bytes.Fields(line) {
+		name, value, ok := bytes.Cut(field, []byte("="))
+		if !ok {
+			continue
+		}
+
+		var err error
+		switch string(name) {
+		case "key":
+			entry.Key, err = url.QueryUnescape(string(value))
+		case "exp":
+			entry.Expiry, err = strconv.ParseInt(string(value), 10, 64)
+		case "la":
+			entry.LastAccess, err = strconv.ParseInt(string(value), 10, 64)
+		case "cas":
+			entry.CasId, err = strconv.ParseUint(string(value), 10, 64)
+		case "fetch":
+			entry.Fetched = string(value) == "yes"
+		case "cls":
+			var cls uint64
+			cls, err = strconv.ParseUint(string(value), 10, 32)
+			entry.SlabClass = uint32(cls)
+		case "size":
+			entry.SizeInBytes, err = strconv.ParseUint(string(value), 10, 64)
+		}
+
+		if err != nil {
+			return entry, fmt.Errorf("lru_crawler_metadump::decoder - unable to parse field %s: %w", field, err)
+		}
+	}
+	return entry, nil
+}
+
+func (d *LruCrawlerMetadumpDecoder) Reset() {
+	if d == nil {
+		return
+	}
+	d.Entries = d.Entries[:0]
+	d.OnEntry = nil
+	d.Err = nil
+	d.HdrLine = ""
+}
+
+var _ codec.LinkEncoder = (*LruCrawlerMetadumpEncoder)(nil)
+var _ codec.ScratchEncoder = (*LruCrawlerMetadumpEncoder)(nil)
+var _ codec.LinkDecoder = (*LruCrawlerMetadumpDecoder)(nil)
+
+func CreateLruCrawlerMetadumpEncoder() *LruCrawlerMetadumpEncoder {
+	return &LruCrawlerMetadumpEncoder{}
+}
+
+func CreateLruCrawlerMetadumpDecoder() *LruCrawlerMetadumpDecoder {
+	return &LruCrawlerMetadumpDecoder{}
+}
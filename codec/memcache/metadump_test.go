@@ -0,0 +1,96 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LruCrawlerMetadumpEncoder(t *testing.T) {
+	data := &bytes.Buffer{}
+	writer := bufio.NewWriter(data)
+
+	encoder := CreateLruCrawlerMetadumpEncoder()
+	assert.NoError(t, encoder.Encode(writer))
+	encoder.Classes = "1,2"
+	assert.NoError(t, encoder.Encode(writer))
+	assert.NoError(t, writer.Flush())
+	assert.Equal(t, "lru_crawler metadump all\r\nlru_crawler metadump 1,2\r\n", data.String())
+}
+
+func Test_LruCrawlerMetadumpDecoder_HappyPath(t *testing.T) {
+	data := bytes.NewBufferString("key=foo%2Fbar exp=-1 la=1700000000 cas=12 fetch=no cls=1 size=63\r\n" +
+		"key=baz exp=1700000100 la=1700000001 cas=13 fetch=yes cls=2 size=130 unknown=1\r\n" +
+		"END\r\n")
+
+	decoder := CreateLruCrawlerMetadumpDecoder()
+	assert.NoError(t, decoder.Decode(bufio.NewReader(data)))
+	assert.Equal(t, []MetadumpEntry{
+		{Key: "foo/bar", Expiry: -1, LastAccess: 1700000000, CasId: 12, SlabClass: 1, SizeInBytes: 63},
+		{Key: "baz", Expiry: 1700000100, LastAccess: 1700000001, CasId: 13, Fetched: true, SlabClass: 2, SizeInBytes: 130},
+	}, decoder.Entries)
+	assert.Empty(t, decoder.HdrLine)
+
+	decoder.Reset()
+	assert.Empty(t, decoder.Entries)
+}
+
+func Test_LruCrawlerMetadumpDecoder_OnEntry(t *testing.T) {
+	data := bytes.NewBufferString("key=a exp=-1\r\nkey=b exp=-1\r\nkey=c exp=-1\r\nEND\r\n")
+
+	decoder := CreateLruCrawlerMetadumpDecoder()
+	var keys []string
+	decoder.OnEntry = func(entry MetadumpEntry) error {
+		keys = append(keys, entry.Key)
+		if entry.Key == "b" {
+			return assert.AnError
+		}
+		return nil
+	}
+	reader := bufio.NewReader(data)
+	assert.NoError(t, decoder.Decode(reader))
+	// the entries aren't accumulated, nor passed on once it failed, but the whole dump is read.
+	assert.Equal(t, []string{"a", "b"}, keys)
+	assert.Empty(t, decoder.Entries)
+	assert.Equal(t, assert.AnError, decoder.Err)
+	assert.Zero(t, reader.Buffered())
+
+	decoder.Reset()
+	assert.Nil(t, decoder.OnEntry)
+	assert.NoError(t, decoder.Err)
+}
+
+func Test_LruCrawlerMetadumpDecoder_Busy(t *testing.T) {
+	data := bytes.NewBufferString("BUSY currently processing crawler request\r\n")
+
+	decoder := CreateLruCrawlerMetadumpDecoder()
+	assert.NoError(t, decoder.Decode(bufio.NewReader(data)))
+	assert.Empty(t, decoder.Entries)
+	assert.Equal(t, "BUSY currently processing crawler request\r\n", decoder.HdrLine)
+}
+
+func Test_LruCrawlerMetadumpDecoder_ErrorPath(t *testing.T) {
+	targs := []struct {
+		name          string
+		erroneousLine []byte
+	}{
+		{
+			name:          "incorrect expiry",
+			erroneousLine: []byte("key=foo exp=abc\r\nEND\r\n"),
+		},
+		{
+			name:          "missing END",
+			erroneousLine: []byte("key=foo exp=-1\r\n"),
+		},
+	}
+
+	for _, tt := range targs {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := CreateLruCrawlerMetadumpDecoder()
+			err := decoder.Decode(bufio.NewReader(bytes.NewBuffer(tt.erroneousLine)))
+			assert.Error(t, err)
+		})
+	}
+}
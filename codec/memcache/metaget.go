@@ -1,13 +1,14 @@
 package memcache
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"io"
 	"strconv"
+	"time"
 
 	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/internal/debugcheck"
 )
 
 /*
@@ -41,6 +42,8 @@ These extra flags can be added to the response:
 */
 type MetaGetEncoder struct {
 	Key                   string
+	ValidatedKey          Key    // takes precedence over Key when set.
+	BinaryKey             []byte // sent base64 encoded with the b flag, takes precedence over Key when not nil.
 	Base64EncodedKey      bool
 	FetchCasId            bool
 	FetchClientFlags      bool
@@ -56,14 +59,28 @@ type MetaGetEncoder struct {
 	BlockTTL              int32  // negative values are ignored
 	RecacheTTL            int32  // negative values are ignored
 	UpdateTTL             int32  // negative values are ignored
+	// UpdateExpireAt takes precedence over UpdateTTL when not zero, see MetaSetEncoder.ExpireAt.
+	UpdateExpireAt time.Time
+	// TTLBeforeUpdate sends t before T, so that FetchRemainingTTL reports the TTL of the item before UpdateTTL applies.
+	TTLBeforeUpdate bool
+	// ConvertLongTTLs sends the TTLs longer than MaxRelativeTTL as the unix timestamp they're relative to.
+	ConvertLongTTLs bool
+}
+
+// updatesTTL reports whether the request sets the TTL of the item, with UpdateTTL or UpdateExpireAt.
+func (e *MetaGetEncoder) updatesTTL() bool {
+	return e.UpdateTTL >= 0 || !e.UpdateExpireAt.IsZero()
 }
 
 func (e *MetaGetEncoder) Reset() {
 	if e == nil {
 		return
 	}
+	debugcheck.Reset(e)
 
 	e.Key = ""
+	e.ValidatedKey = Key{}
+	e.BinaryKey = nil
 	e.Base64EncodedKey = false
 	e.FetchCasId = false
 	e.FetchClientFlags = false
@@ -79,18 +96,26 @@ func (e *MetaGetEncoder) Reset() {
 	e.BlockTTL = -1
 	e.RecacheTTL = -1
 	e.UpdateTTL = -1
+	e.UpdateExpireAt = time.Time{}
+	e.TTLBeforeUpdate = false
+	e.ConvertLongTTLs = false
 }
 
-func (e *MetaGetEncoder) Encode(writer *bufio.Writer) error {
+func (e *MetaGetEncoder) Encode(writer codec.Writer) error {
 	b := bytePool.Get()
 	defer bytePool.Put(b)
+	return e.EncodeWithScratch(writer, b)
+}
+
+func (e *MetaGetEncoder) EncodeWithScratch(writer codec.Writer, b *bytes.Buffer) error {
 	b.Write(MetaGet)
 
-	if keyErr := writeKey(b, e.Key); keyErr != nil {
+	base64Key, keyErr := encodeKey(b, e.Key, e.BinaryKey, e.ValidatedKey)
+	if keyErr != nil {
 		return keyErr
 	}
 
-	if e.Base64EncodedKey {
+	if e.Base64EncodedKey || base64Key {
 		b.Write(Base64EncodedKey)
 	}
 
@@ -130,10 +155,13 @@ func (e *MetaGetEncoder) Encode(writer *bufio.Writer) error {
 	// HD t145
 	writeCasOverride(b, e.CasOverride)
 	writeRecacheTTL(b, e.RecacheTTL)
-	writeBlockTTL(b, e.BlockTTL)
-	writeTTL(b, e.UpdateTTL)
+	writeBlockTTL(b, e.BlockTTL, e.ConvertLongTTLs)
+	if e.FetchRemainingTTL && e.TTLBeforeUpdate {
+		b.Write(FetchRemainingTTL)
+	}
+	writeExpiry(b, e.UpdateTTL, e.UpdateExpireAt, e.ConvertLongTTLs)
 
-	if e.FetchRemainingTTL {
+	if e.FetchRemainingTTL && !e.TTLBeforeUpdate {
 		b.Write(FetchRemainingTTL)
 	}
 
@@ -163,11 +191,15 @@ type MetaGetDecoder struct {
 	Opaque                       uint64 // only non-zero value is valid.
 	IsItemHitBefore              bool
 	ItemKey                      string
+	ItemKeyBase64                bool // the b flag was returned, ItemKey is base64 encoded.
 	ItemSizeInBytes              uint64
 	TimeSinceLastAccessedSeconds uint32
 	Stale                        bool
 
 	HdrLine string
+
+	// Interner interns the item key and the header line of the unknown statuses, which are allocated again if nil.
+	Interner *Interner
 }
 
 func (d *MetaGetDecoder) Reset() {
@@ -184,17 +216,24 @@ func (d *MetaGetDecoder) Reset() {
 	d.Opaque = 0
 	d.IsItemHitBefore = false
 	d.ItemKey = ""
+	d.ItemKeyBase64 = false
 	d.ItemSizeInBytes = 0
 	d.TimeSinceLastAccessedSeconds = 0
 	d.Stale = false
 	d.HdrLine = ""
+	d.Interner = nil
+}
+
+// ItemKeyBytes returns ItemKey, base64 decoded if it was returned along with the b flag.
+func (d *MetaGetDecoder) ItemKeyBytes() ([]byte, error) {
+	return itemKeyBytes(d.ItemKey, d.ItemKeyBase64)
 }
 
 // Decode method will parse a metaget response output correctly and load the contents of the response in
 // the fields of the object itself.
 // the main concern is how to return the results from the backend to the decoder, without using channels and without using
 // callback functions.
-func (d *MetaGetDecoder) Decode(reader *bufio.Reader) error {
+func (d *MetaGetDecoder) Decode(reader codec.Reader) error {
 	hdrLine, err := reader.ReadSlice('\n')
 	if err != nil {
 		return err
@@ -204,10 +243,10 @@ func (d *MetaGetDecoder) Decode(reader *bufio.Reader) error {
 	for idx, elem := range bytes.Fields(hdrLine) {
 		if idx == 0 {
 			d.Status = MetaGetStatusFromHeader(elem)
-			if d.Status == MetadataStatusInvalid {
-				// If we get an unknown response code, we can't further parse the header line.
+			if !d.Status.hasMetadata() {
+				// If we get an error, MN or an unknown response code, we can't further parse the header line.
 				// store it for logging and move on.
-				d.HdrLine = string(hdrLine)
+				d.HdrLine = d.Interner.Intern(hdrLine)
 				return nil
 			}
 			continue
@@ -225,6 +264,8 @@ func (d *MetaGetDecoder) Decode(reader *bufio.Reader) error {
 
 		if len(elem) == 1 {
 			switch elem[0] {
+			case 'b':
+				d.ItemKeyBase64 = true
 			case 'W':
 				d.Recache = RecacheWon
 			case 'X':
@@ -266,7 +307,7 @@ func (d *MetaGetDecoder) Decode(reader *bufio.Reader) error {
 				d.IsItemHitBefore = true
 			}
 		case 'k':
-			d.ItemKey = string(elem[1:])
+			d.ItemKey = d.Interner.Intern(elem[1:])
 		case 's':
 			if s, pErr := strconv.ParseUint(string(elem[1:]), 10, 64); pErr != nil {
 				return fmt.Errorf("meta_get::decoder - unable to parse item size as an uint64 as the token is %s: %w", elem, pErr)
@@ -301,12 +342,15 @@ func (d *MetaGetDecoder) Decode(reader *bufio.Reader) error {
 }
 
 var _ codec.LinkEncoder = (*MetaGetEncoder)(nil)
+var _ codec.ScratchEncoder = (*MetaGetEncoder)(nil)
 var _ codec.LinkDecoder = (*MetaGetDecoder)(nil)
 
 type MetaGetTarget func(decoder *MetaGetDecoder, opaque uint64) error
 
 func CreateMetaGetEncoder() *MetaGetEncoder {
-	return &MetaGetEncoder{}
+	e := &MetaGetEncoder{}
+	debugcheck.Created(e)
+	return e
 }
 
 func CreateMetaGetDecoder() *MetaGetDecoder {
@@ -1,15 +1,17 @@
 package memcache
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/internal/debugcheck"
 )
 
-// MetaSetMode represents the mode for a meta set operation
+// MetaSetMode represents the mode for a meta set operation. The client package exposes every mode as a method of
+// MemcachedClient, Add, Replace, AppendValue and PrependValue, mapping the status of the response to its typed errors.
 type MetaSetMode string
 
 const (
@@ -47,6 +49,8 @@ The flags used by the 'ms' command are:
 */
 type MetaSetEncoder struct {
 	Key              string
+	ValidatedKey     Key    // takes precedence over Key when set.
+	BinaryKey        []byte // sent base64 encoded with the b flag, takes precedence over Key when not nil.
 	Value            []byte
 	Base64EncodedKey bool
 	FetchCasId       bool
@@ -60,23 +64,35 @@ type MetaSetEncoder struct {
 	Opaque           uint64 // only non-zero value is valid.
 	Mode             MetaSetMode
 	BlockTTL         int32 // negative values are ignored.
+	Quiet            bool  // only failures get a response, see QuietBulkDecoder.
+	// ExpireAt takes precedence over TTL when not zero. It's sent as a TTL while within MaxRelativeTTL, and as a unix
+	// timestamp beyond.
+	ExpireAt time.Time
+	// ConvertLongTTLs sends the TTLs longer than MaxRelativeTTL as the unix timestamp they're relative to, see
+	// ConvertLongTTLs.
+	ConvertLongTTLs bool
 }
 
 // todo(hemal): figure out a way to pre-calculate the request bytes so that the request is not generated
 // when trying to write to a connection
-func (e *MetaSetEncoder) Encode(writer *bufio.Writer) error {
+func (e *MetaSetEncoder) Encode(writer codec.Writer) error {
 	b := bytePool.Get()
 	defer bytePool.Put(b)
+	return e.EncodeWithScratch(writer, b)
+}
+
+func (e *MetaSetEncoder) EncodeWithScratch(writer codec.Writer, b *bytes.Buffer) error {
 	b.Write(MetaSet)
 
-	if keyErr := writeKey(b, e.Key); keyErr != nil {
+	base64Key, keyErr := encodeKey(b, e.Key, e.BinaryKey, e.ValidatedKey)
+	if keyErr != nil {
 		return keyErr
 	}
 
 	b.Write(strconv.AppendInt(b.AvailableBuffer(), int64(len(e.Value)), 10))
 	b.WriteByte(Space)
 
-	if e.Base64EncodedKey {
+	if e.Base64EncodedKey || base64Key {
 		b.Write(Base64EncodedKey)
 	}
 
@@ -96,6 +112,10 @@ func (e *MetaSetEncoder) Encode(writer *bufio.Writer) error {
 		b.Write(FetchItemSize)
 	}
 
+	if e.Quiet {
+		b.Write(Quiet)
+	}
+
 	switch e.Mode {
 	case Add:
 		b.Write(PutIfAbsentMode)
@@ -109,11 +129,11 @@ func (e *MetaSetEncoder) Encode(writer *bufio.Writer) error {
 		// do nothing - defaults to normal set mode
 	}
 
-	writeTTL(b, e.TTL)
+	writeExpiry(b, e.TTL, e.ExpireAt, e.ConvertLongTTLs)
 	writeCasId(b, e.CasId)
 	writeCasOverride(b, e.CasOverride)
 	writeClientFlags(b, e.ClientFlags)
-	writeBlockTTL(b, e.BlockTTL)
+	writeBlockTTL(b, e.BlockTTL, e.ConvertLongTTLs)
 	writeOpaque(b, e.Opaque)
 
 	b.Write(CRLF)
@@ -128,8 +148,11 @@ func (e *MetaSetEncoder) Reset() {
 	if e == nil {
 		return
 	}
+	debugcheck.Reset(e)
 
 	e.Key = ""
+	e.ValidatedKey = Key{}
+	e.BinaryKey = nil
 	e.Value = nil
 	e.Base64EncodedKey = false
 	e.FetchCasId = false
@@ -140,21 +163,28 @@ func (e *MetaSetEncoder) Reset() {
 	e.FetchKey = false
 	e.FetchItemSize = false
 	e.TTL = -1
+	e.ExpireAt = time.Time{}
+	e.ConvertLongTTLs = false
 	e.Opaque = 0
 	e.Mode = ""
 	e.BlockTTL = -1
+	e.Quiet = false
 }
 
 type MetaSetDecoder struct {
-	Status  MetadataStatus
-	Opaque  uint64
-	CasId   uint64
-	ItemKey string
+	Status        MetadataStatus
+	Opaque        uint64
+	CasId         uint64
+	ItemKey       string
+	ItemKeyBase64 bool // the b flag was returned, ItemKey is base64 encoded.
 
 	HdrLine string
+
+	// Interner interns the item key and the header line of the unknown statuses, which are allocated again if nil.
+	Interner *Interner
 }
 
-func (d *MetaSetDecoder) Decode(reader *bufio.Reader) error {
+func (d *MetaSetDecoder) Decode(reader codec.Reader) error {
 	hdrLine, err := reader.ReadSlice('\n')
 	if err != nil {
 		return err
@@ -163,10 +193,10 @@ func (d *MetaSetDecoder) Decode(reader *bufio.Reader) error {
 	for idx, elem := range bytes.Fields(hdrLine) {
 		if idx == 0 {
 			d.Status = MetaSetStatusFromHeader(elem)
-			if d.Status == MetadataStatusInvalid {
-				// If we get an unknown response code, we can't further parse the header line.
+			if !d.Status.hasMetadata() {
+				// If we get an error, MN or an unknown response code, we can't further parse the header line.
 				// store it for logging and move on.
-				d.HdrLine = string(hdrLine)
+				d.HdrLine = d.Interner.Intern(hdrLine)
 				return nil
 			}
 			continue
@@ -186,7 +216,9 @@ func (d *MetaSetDecoder) Decode(reader *bufio.Reader) error {
 				d.CasId = c
 			}
 		case 'k':
-			d.ItemKey = string(elem[1:])
+			d.ItemKey = d.Interner.Intern(elem[1:])
+		case 'b':
+			d.ItemKeyBase64 = true
 		}
 	}
 
@@ -203,16 +235,26 @@ func (d *MetaSetDecoder) Reset() {
 	d.Opaque = 0
 	d.CasId = 0
 	d.ItemKey = ""
+	d.ItemKeyBase64 = false
 	d.HdrLine = ""
+	d.Interner = nil
+}
+
+// ItemKeyBytes returns ItemKey, base64 decoded if it was returned along with the b flag.
+func (d *MetaSetDecoder) ItemKeyBytes() ([]byte, error) {
+	return itemKeyBytes(d.ItemKey, d.ItemKeyBase64)
 }
 
 var _ codec.LinkEncoder = (*MetaSetEncoder)(nil)
+var _ codec.ScratchEncoder = (*MetaSetEncoder)(nil)
 var _ codec.LinkDecoder = (*MetaSetDecoder)(nil)
 
 type MetaSetTarget func(decoder *MetaSetDecoder, opaque uint64) error
 
 func CreateMetaSetEncoder() *MetaSetEncoder {
-	return &MetaSetEncoder{}
+	e := &MetaSetEncoder{}
+	debugcheck.Created(e)
+	return e
 }
 
 func CreateMetaSetDecoder() *MetaSetDecoder {
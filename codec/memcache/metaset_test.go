@@ -117,3 +117,19 @@ func Test_MetaSetDecoder_ErrorPath(t *testing.T) {
 	}
 
 }
+
+func Test_MetaSetEncoder_Quiet(t *testing.T) {
+	data := &bytes.Buffer{}
+	writer := bufio.NewWriter(data)
+
+	encoder := CreateMetaSetEncoder()
+	encoder.Reset()
+	encoder.Key = "key"
+	encoder.Value = []byte("value")
+	encoder.Quiet = true
+	encoder.Opaque = 7
+
+	assert.NoError(t, encoder.Encode(writer))
+	assert.NoError(t, writer.Flush())
+	assert.Equal(t, "ms key 5 q O7 \r\nvalue\r\n", data.String())
+}
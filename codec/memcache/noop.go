@@ -0,0 +1,53 @@
+package memcache
+
+import (
+	"bytes"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// MetaNoOpEncoder sends a lone mn command, which doubles as a probe for meta protocol support.
+type MetaNoOpEncoder struct{}
+
+func (e *MetaNoOpEncoder) Encode(writer codec.Writer) error {
+	_, err := writer.Write(NoOpRequest)
+	return err
+}
+
+func (e *MetaNoOpEncoder) Reset() {
+}
+
+type MetaNoOpDecoder struct {
+	// HdrLine is set if the server didn't reply with MN, e.g. "ERROR" for servers without meta protocol support.
+	HdrLine string
+}
+
+func (d *MetaNoOpDecoder) Decode(reader codec.Reader) error {
+	line, err := reader.ReadSlice('\n')
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(line, NoOpResponse) {
+		d.HdrLine = string(line)
+	}
+	return nil
+}
+
+func (d *MetaNoOpDecoder) Reset() {
+	if d == nil {
+		return
+	}
+	d.HdrLine = ""
+}
+
+var _ codec.LinkEncoder = (*MetaNoOpEncoder)(nil)
+var _ codec.LinkDecoder = (*MetaNoOpDecoder)(nil)
+
+func CreateMetaNoOpEncoder() *MetaNoOpEncoder {
+	return &MetaNoOpEncoder{}
+}
+
+func CreateMetaNoOpDecoder() *MetaNoOpDecoder {
+	return &MetaNoOpDecoder{}
+}
@@ -0,0 +1,32 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MetaNoOpEncoder(t *testing.T) {
+	data := &bytes.Buffer{}
+	writer := bufio.NewWriter(data)
+
+	assert.NoError(t, CreateMetaNoOpEncoder().Encode(writer))
+	assert.NoError(t, writer.Flush())
+	assert.Equal(t, "mn\r\n", data.String())
+}
+
+func Test_MetaNoOpDecoder(t *testing.T) {
+	decoder := CreateMetaNoOpDecoder()
+	assert.NoError(t, decoder.Decode(bufio.NewReader(bytes.NewBufferString("MN\r\n"))))
+	assert.Empty(t, decoder.HdrLine)
+
+	assert.NoError(t, decoder.Decode(bufio.NewReader(bytes.NewBufferString("ERROR\r\n"))))
+	assert.Equal(t, "ERROR\r\n", decoder.HdrLine)
+
+	decoder.Reset()
+	assert.Empty(t, decoder.HdrLine)
+
+	assert.Error(t, decoder.Decode(bufio.NewReader(bytes.NewBufferString("MN"))))
+}
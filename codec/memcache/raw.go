@@ -0,0 +1,92 @@
+package memcache
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/internal/utils"
+)
+
+/*
+RawEncoder sends Request as is, followed by an mn command: the response is whatever the server answers up to the MN
+line, so the commands sent don't need a codec of their own. Request may hold several commands, each ending with \r\n,
+along with their data blocks.
+*/
+type RawEncoder struct {
+	Request []byte
+}
+
+func (e *RawEncoder) Encode(writer codec.Writer) error {
+	if _, err := writer.Write(e.Request); err != nil {
+		return err
+	}
+	_, err := writer.Write(NoOpRequest)
+	return err
+}
+
+// Validate checks that Request is made of complete command lines, the mn command would be read as part of the last one
+// otherwise.
+func (e *RawEncoder) Validate() error {
+	if len(e.Request) == 0 || !bytes.HasSuffix(e.Request, CRLF) {
+		return fmt.Errorf("%w: raw requests must end with \\r\\n", ErrInvalidRequest)
+	}
+	return nil
+}
+
+func (e *RawEncoder) Reset() {
+	if e == nil {
+		return
+	}
+	e.Request = nil
+}
+
+// RawDecoder reads the response of a RawEncoder: the lines and data blocks answered before the MN line, which isn't
+// part of Response.
+type RawDecoder struct {
+	Response []byte
+}
+
+func (d *RawDecoder) Decode(reader codec.Reader) error {
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(line, NoOpResponse) {
+			return nil
+		}
+		d.Response = append(d.Response, line...)
+
+		// the data blocks are skipped as a whole, so that a value holding an MN line isn't mistaken for the end of the
+		// response.
+		size, ok, err := utils.DataBlockSize(line)
+		if err != nil {
+			return fmt.Errorf("raw::decoder - %w", err)
+		}
+		if !ok {
+			continue
+		}
+		if d.Response, err = utils.AppendDataBlock(d.Response, reader, size, 0); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *RawDecoder) Reset() {
+	if d == nil {
+		return
+	}
+	d.Response = d.Response[:0]
+}
+
+var _ codec.LinkEncoder = (*RawEncoder)(nil)
+var _ codec.LinkDecoder = (*RawDecoder)(nil)
+
+func CreateRawEncoder() *RawEncoder {
+	return &RawEncoder{}
+}
+
+func CreateRawDecoder() *RawDecoder {
+	return &RawDecoder{}
+}
@@ -0,0 +1,67 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RawEncoder(t *testing.T) {
+	data := &bytes.Buffer{}
+	writer := bufio.NewWriter(data)
+
+	encoder := CreateRawEncoder()
+	encoder.Request = []byte("ms k 2\r\nhi\r\nverbosity 1\r\n")
+	require.NoError(t, encoder.Validate())
+	assert.NoError(t, encoder.Encode(writer))
+	assert.NoError(t, writer.Flush())
+	assert.Equal(t, "ms k 2\r\nhi\r\nverbosity 1\r\nmn\r\n", data.String())
+
+	for _, request := range []string{"", "verbosity 1", "verbosity 1\n"} {
+		encoder.Request = []byte(request)
+		assert.ErrorIs(t, encoder.Validate(), ErrInvalidRequest, request)
+	}
+
+	encoder.Reset()
+	assert.Nil(t, encoder.Request)
+}
+
+func Test_RawDecoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{name: "status line", input: "OK\r\nMN\r\n", expected: "OK\r\n"},
+		{name: "several lines", input: "HD\r\nERROR\r\nMN\r\n", expected: "HD\r\nERROR\r\n"},
+		{name: "empty response", input: "MN\r\n", expected: ""},
+		{name: "meta value holding MN", input: "VA 4\r\nMN\r\n\r\nMN\r\n", expected: "VA 4\r\nMN\r\n\r\n"},
+		{name: "classic value holding MN", input: "VALUE k 0 4\r\nMN\r\n\r\nEND\r\nMN\r\n", expected: "VALUE k 0 4\r\nMN\r\n\r\nEND\r\n"},
+		{name: "invalid size", input: "VA x\r\nMN\r\n", wantErr: true},
+		{name: "truncated", input: "OK\r\n", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decoder := CreateRawDecoder()
+			err := decoder.Decode(bufio.NewReader(bytes.NewBufferString(test.input)))
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(decoder.Response))
+
+			decoder.Reset()
+			assert.Empty(t, decoder.Response)
+		})
+	}
+}
+
+func Test_RawClassicCodec(t *testing.T) {
+	_, _, err := ClassicCodec(CreateRawEncoder(), CreateRawDecoder())
+	assert.ErrorIs(t, err, ErrUnsupportedByClassicProtocol)
+}
@@ -0,0 +1,48 @@
+package memcache
+
+import (
+	"github.com/stripe/memlink/codec"
+)
+
+func (e *MetaGetEncoder) RoutingKey() string {
+	return requestKey(e.Key, e.BinaryKey, e.ValidatedKey)
+}
+
+func (e *MetaSetEncoder) RoutingKey() string {
+	return requestKey(e.Key, e.BinaryKey, e.ValidatedKey)
+}
+
+func (e *MetaDeleteEncoder) RoutingKey() string {
+	return requestKey(e.Key, e.BinaryKey, e.ValidatedKey)
+}
+
+func (e *MetaArithmeticEncoder) RoutingKey() string {
+	return requestKey(e.Key, e.BinaryKey, e.ValidatedKey)
+}
+
+// RoutingKey is the key of the translated meta request, so that falling back to the classic protocol doesn't move the
+// key to another backend.
+func (e *classicGetEncoder) RoutingKey() string {
+	return e.meta.RoutingKey()
+}
+
+func (e *classicSetEncoder) RoutingKey() string {
+	return e.meta.RoutingKey()
+}
+
+func (e *classicDeleteEncoder) RoutingKey() string {
+	return e.meta.RoutingKey()
+}
+
+func (e *classicArithmeticEncoder) RoutingKey() string {
+	return e.meta.RoutingKey()
+}
+
+var _ codec.RoutingKeyer = (*MetaGetEncoder)(nil)
+var _ codec.RoutingKeyer = (*MetaSetEncoder)(nil)
+var _ codec.RoutingKeyer = (*MetaDeleteEncoder)(nil)
+var _ codec.RoutingKeyer = (*MetaArithmeticEncoder)(nil)
+var _ codec.RoutingKeyer = (*classicGetEncoder)(nil)
+var _ codec.RoutingKeyer = (*classicSetEncoder)(nil)
+var _ codec.RoutingKeyer = (*classicDeleteEncoder)(nil)
+var _ codec.RoutingKeyer = (*classicArithmeticEncoder)(nil)
@@ -0,0 +1,65 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec"
+)
+
+func Test_EncodeWithScratch(t *testing.T) {
+	get := CreateMetaGetEncoder()
+	get.Reset()
+	get.Key = "foo"
+	get.FetchValue = true
+
+	set := CreateMetaSetEncoder()
+	set.Reset()
+	set.Key = "bar"
+	set.Value = []byte("value")
+	set.TTL = 60
+
+	bulk := CreateBulkEncoder[*MetaSetEncoder](2)
+	bulk.Encoders = append(bulk.Encoders, set, set)
+
+	bulkGet := CreateBulkEncoder[*MetaGetEncoder](2)
+	bulkGet.Encoders = append(bulkGet.Encoders, get, get)
+	bulkGetDecoder := CreateBulkDecoder[*MetaGetDecoder](2)
+	bulkGetDecoder.Decoders = append(bulkGetDecoder.Decoders, CreateMetaGetDecoder(), CreateMetaGetDecoder())
+	classicBulk, _, err := ClassicCodec(bulkGet, bulkGetDecoder)
+	require.NoError(t, err)
+
+	targs := []struct {
+		name    string
+		encoder codec.LinkEncoder
+	}{
+		{name: "meta get", encoder: get},
+		{name: "meta set", encoder: set},
+		{name: "bulk", encoder: bulk},
+		{name: "classic bulk", encoder: classicBulk},
+		{name: "admin", encoder: CreateVersionEncoder()},
+		{name: "without scratch support", encoder: CreateMetaNoOpEncoder()},
+	}
+
+	for _, tt := range targs {
+		t.Run(tt.name, func(t *testing.T) {
+			var expected bytes.Buffer
+			w := bufio.NewWriter(&expected)
+			require.NoError(t, tt.encoder.Encode(w))
+			require.NoError(t, w.Flush())
+
+			var actual bytes.Buffer
+			w = bufio.NewWriter(&actual)
+			// leftovers of a previous request don't leak into the next one.
+			scratch := bytes.NewBufferString("garbage")
+			require.NoError(t, codec.Encode(tt.encoder, w, scratch))
+			require.NoError(t, w.Flush())
+
+			assert.Equal(t, expected.String(), actual.String())
+		})
+	}
+}
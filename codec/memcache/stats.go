@@ -0,0 +1,100 @@
+package memcache
+
+import (
+	"bytes"
+
+	"github.com/stripe/memlink/codec"
+)
+
+var (
+	Stats    = []byte("stats")
+	StatLine = []byte("STAT ")
+)
+
+/*
+StatsEncoder command format: stats <group>\r\n
+
+Without a group the general purpose statistics are returned, other groups of interest are "slabs", "items" and
+"settings". Every statistic is returned on its own "STAT <name> <value>" line, the response is terminated by an END
+line.
+*/
+type StatsEncoder struct {
+	Group string // empty for the general purpose statistics.
+}
+
+func (e *StatsEncoder) Encode(writer codec.Writer) error {
+	b := bytePool.Get()
+	defer bytePool.Put(b)
+	return e.EncodeWithScratch(writer, b)
+}
+
+func (e *StatsEncoder) EncodeWithScratch(writer codec.Writer, b *bytes.Buffer) error {
+
+	b.Write(Stats)
+	if e.Group != "" {
+		b.WriteByte(Space)
+		b.WriteString(e.Group)
+	}
+	b.Write(CRLF)
+
+	_, err := writer.Write(b.Bytes())
+	return err
+}
+
+func (e *StatsEncoder) Reset() {
+	if e == nil {
+		return
+	}
+	e.Group = ""
+}
+
+type StatsDecoder struct {
+	Stats map[string]string
+
+	// HdrLine is set if the server didn't reply with statistics, e.g. "ERROR" for an unknown group.
+	HdrLine string
+}
+
+func (d *StatsDecoder) Decode(reader codec.Reader) error {
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(line, EndResponse) {
+			return nil
+		}
+
+		if !bytes.HasPrefix(line, StatLine) {
+			d.HdrLine = string(line)
+			return nil
+		}
+
+		name, value, _ := bytes.Cut(bytes.TrimSpace(line[len(StatLine):]), []byte(" "))
+		if d.Stats == nil {
+			d.Stats = make(map[string]string)
+		}
+		d.Stats[string(name)] = string(value)
+	}
+}
+
+func (d *StatsDecoder) Reset() {
+	if d == nil {
+		return
+	}
+	clear(d.Stats)
+	d.HdrLine = ""
+}
+
+var _ codec.LinkEncoder = (*StatsEncoder)(nil)
+var _ codec.ScratchEncoder = (*StatsEncoder)(nil)
+var _ codec.LinkDecoder = (*StatsDecoder)(nil)
+
+func CreateStatsEncoder() *StatsEncoder {
+	return &StatsEncoder{}
+}
+
+func CreateStatsDecoder() *StatsDecoder {
+	return &StatsDecoder{}
+}
@@ -0,0 +1,55 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_StatsEncoder(t *testing.T) {
+	data := &bytes.Buffer{}
+	writer := bufio.NewWriter(data)
+
+	encoder := CreateStatsEncoder()
+	assert.NoError(t, encoder.Encode(writer))
+	encoder.Group = "slabs"
+	assert.NoError(t, encoder.Encode(writer))
+	assert.NoError(t, writer.Flush())
+	assert.Equal(t, "stats\r\nstats slabs\r\n", data.String())
+
+	encoder.Reset()
+	assert.Empty(t, encoder.Group)
+}
+
+func Test_StatsDecoder_HappyPath(t *testing.T) {
+	data := bytes.NewBufferString("STAT 1:chunk_size 96\r\n" +
+		"STAT 1:mem_requested 1234\r\n" +
+		"STAT version 1.6.21\r\n" +
+		"END\r\n")
+
+	decoder := CreateStatsDecoder()
+	assert.NoError(t, decoder.Decode(bufio.NewReader(data)))
+	assert.Equal(t, map[string]string{"1:chunk_size": "96", "1:mem_requested": "1234", "version": "1.6.21"}, decoder.Stats)
+	assert.Empty(t, decoder.HdrLine)
+
+	decoder.Reset()
+	assert.Empty(t, decoder.Stats)
+}
+
+func Test_StatsDecoder_UnknownGroup(t *testing.T) {
+	data := bytes.NewBufferString("ERROR\r\n")
+
+	decoder := CreateStatsDecoder()
+	assert.NoError(t, decoder.Decode(bufio.NewReader(data)))
+	assert.Empty(t, decoder.Stats)
+	assert.Equal(t, "ERROR\r\n", decoder.HdrLine)
+}
+
+func Test_StatsDecoder_MissingEnd(t *testing.T) {
+	data := bytes.NewBufferString("STAT version 1.6.21\r\n")
+
+	decoder := CreateStatsDecoder()
+	assert.Error(t, decoder.Decode(bufio.NewReader(data)))
+}
@@ -0,0 +1,86 @@
+package memcache
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// datagramReader reads responses held in memory whole, like a transport framing them in datagrams, without bufio.
+type datagramReader struct {
+	buf []byte
+}
+
+func (r *datagramReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *datagramReader) ReadByte() (byte, error) {
+	if len(r.buf) == 0 {
+		return 0, io.EOF
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b, nil
+}
+
+func (r *datagramReader) ReadSlice(delim byte) ([]byte, error) {
+	i := bytes.IndexByte(r.buf, delim)
+	if i < 0 {
+		line := r.buf
+		r.buf = nil
+		return line, io.EOF
+	}
+	line := r.buf[:i+1]
+	r.buf = r.buf[i+1:]
+	return line, nil
+}
+
+func (r *datagramReader) ReadString(delim byte) (string, error) {
+	line, err := r.ReadSlice(delim)
+	return string(line), err
+}
+
+func (r *datagramReader) Peek(n int) ([]byte, error) {
+	if n > len(r.buf) {
+		return r.buf, io.EOF
+	}
+	return r.buf[:n], nil
+}
+
+func (r *datagramReader) Buffered() int {
+	return len(r.buf)
+}
+
+var _ codec.Reader = (*datagramReader)(nil)
+
+func Test_CodecWithoutBufio(t *testing.T) {
+	encoder := CreateMetaGetEncoder()
+	encoder.Reset()
+	encoder.Key = "foo"
+	encoder.FetchValue = true
+	encoder.Opaque = 7
+
+	var request bytes.Buffer
+	require.NoError(t, encoder.Encode(&request))
+	assert.Equal(t, "mg foo v O7 \r\n", request.String())
+
+	decoder := CreateMetaGetDecoder()
+	decoder.Reset()
+	reader := &datagramReader{buf: []byte("VA 3 O7\r\nbar\r\n")}
+	require.NoError(t, decoder.Decode(reader))
+	assert.Equal(t, CacheHit, decoder.Status)
+	assert.Equal(t, []byte("bar"), decoder.Value)
+	assert.Equal(t, uint64(7), decoder.Opaque)
+	assert.Zero(t, reader.Buffered())
+}
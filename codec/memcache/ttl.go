@@ -0,0 +1,86 @@
+package memcache
+
+import (
+	"math"
+	"time"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// MaxRelativeTTL is the longest TTL, in seconds, memcached reads as relative to the current time: 30 days. It reads
+// the longer ones as absolute unix timestamps, so that a TTL of 31 days would be a date in 1970 and the item would
+// expire immediately.
+const MaxRelativeTTL int32 = 30 * 24 * 60 * 60
+
+// longTTLsConverter is implemented by the requests carrying TTLs, see ConvertLongTTLs.
+type longTTLsConverter interface {
+	convertLongTTLs()
+}
+
+// ConvertLongTTLs makes e, and the requests it wraps, send their TTLs longer than MaxRelativeTTL as the unix
+// timestamp they're relative to, capped to the largest one memcached accepts, rather than as is for the callers which
+// set absolute unix timestamps themselves. It's a no-op for the requests without TTLs.
+func ConvertLongTTLs(e codec.LinkEncoder) {
+	if converter, ok := e.(longTTLsConverter); ok {
+		converter.convertLongTTLs()
+	}
+}
+
+func (e *MetaGetEncoder) convertLongTTLs() {
+	e.ConvertLongTTLs = true
+}
+
+func (e *MetaSetEncoder) convertLongTTLs() {
+	e.ConvertLongTTLs = true
+}
+
+func (e *MetaDeleteEncoder) convertLongTTLs() {
+	e.ConvertLongTTLs = true
+}
+
+func (e *MetaArithmeticEncoder) convertLongTTLs() {
+	e.ConvertLongTTLs = true
+}
+
+func (e *BulkEncoder[T]) convertLongTTLs() {
+	for _, encoder := range e.Encoders {
+		ConvertLongTTLs(encoder)
+	}
+}
+
+func (e *BarrierEncoder) convertLongTTLs() {
+	for _, group := range e.Groups {
+		for _, encoder := range group.Encoders {
+			ConvertLongTTLs(encoder)
+		}
+	}
+}
+
+// expiryTTL returns the TTL memcached must read to expire an item at expireAt: the seconds until then up to
+// MaxRelativeTTL, or else the unix timestamp of expireAt, which expires the item immediately if it's in the past.
+func expiryTTL(expireAt time.Time) int32 {
+	remaining := time.Until(expireAt)
+	if remaining > 0 && remaining <= time.Duration(MaxRelativeTTL)*time.Second {
+		// rounded up, so that the item doesn't expire before expireAt.
+		return int32((remaining + time.Second - 1) / time.Second)
+	}
+	// the unix timestamps up to MaxRelativeTTL would be read as relative TTLs.
+	return int32(min(max(expireAt.Unix(), int64(MaxRelativeTTL)+1), math.MaxInt32))
+}
+
+// wireExpiry returns the TTL memcached must read to expire an item at expireAt if it's set, or else in ttl seconds.
+func wireExpiry(ttl int32, expireAt time.Time, convert bool) int32 {
+	if expireAt.IsZero() {
+		return wireTTL(ttl, convert)
+	}
+	return expiryTTL(expireAt)
+}
+
+// wireTTL returns ttl the way memcached must read it: as is up to MaxRelativeTTL, and beyond as an absolute unix
+// timestamp if convert is set, see ConvertLongTTLs.
+func wireTTL(ttl int32, convert bool) int32 {
+	if ttl <= MaxRelativeTTL || !convert {
+		return ttl
+	}
+	return int32(min(time.Now().Unix()+int64(ttl), math.MaxInt32))
+}
@@ -0,0 +1,98 @@
+package memcache
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWireTTL(t *testing.T) {
+	assert.Equal(t, int32(0), wireTTL(0, true))
+	assert.Equal(t, int32(-1), wireTTL(-1, true))
+	assert.Equal(t, int32(3600), wireTTL(3600, true))
+	assert.Equal(t, MaxRelativeTTL, wireTTL(MaxRelativeTTL, true))
+
+	before := time.Now().Unix()
+	absolute := wireTTL(MaxRelativeTTL+1, true)
+	after := time.Now().Unix()
+	assert.GreaterOrEqual(t, int64(absolute), before+int64(MaxRelativeTTL)+1)
+	assert.LessOrEqual(t, int64(absolute), after+int64(MaxRelativeTTL)+1)
+
+	assert.Equal(t, int32(math.MaxInt32), wireTTL(math.MaxInt32, true))
+}
+
+func TestRawTTLs(t *testing.T) {
+	assert.Equal(t, MaxRelativeTTL+1, wireTTL(MaxRelativeTTL+1, false))
+
+	// the encoders send the TTLs as is unless told to convert them.
+	encoder := &MetaSetEncoder{Key: "key", Value: []byte("v"), TTL: 2000000000, BlockTTL: -1}
+	var buffer bytes.Buffer
+	require.NoError(t, encoder.Encode(&buffer))
+	assert.Contains(t, buffer.String(), " T2000000000 ")
+}
+
+func TestEncodersConvertLongTTLs(t *testing.T) {
+	ttl := 2 * MaxRelativeTTL
+	encoder := &MetaSetEncoder{Key: "key", Value: []byte("v"), TTL: ttl, BlockTTL: -1}
+	bulk := &BulkEncoder[*MetaSetEncoder]{Encoders: []*MetaSetEncoder{encoder}}
+	ConvertLongTTLs(bulk)
+	assert.True(t, encoder.ConvertLongTTLs)
+	var buffer bytes.Buffer
+	require.NoError(t, encoder.Encode(&buffer))
+
+	now := time.Now().Unix()
+	line, _, _ := bytes.Cut(buffer.Bytes(), CRLF)
+	var absolute int64
+	for _, field := range bytes.Fields(line) {
+		if field[0] == TTL {
+			var err error
+			absolute, err = strconv.ParseInt(string(field[1:]), 10, 64)
+			require.NoError(t, err)
+		}
+	}
+	assert.InDelta(t, now+int64(ttl), absolute, 2)
+}
+
+func TestExpiryTTL(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, int32(3600), expiryTTL(now.Add(time.Hour-time.Millisecond)))
+	assert.Equal(t, MaxRelativeTTL, expiryTTL(now.Add(time.Duration(MaxRelativeTTL)*time.Second-time.Millisecond)))
+
+	later := now.Add(2 * time.Duration(MaxRelativeTTL) * time.Second)
+	assert.Equal(t, int32(later.Unix()), expiryTTL(later))
+
+	past := now.Add(-time.Minute)
+	assert.Equal(t, int32(past.Unix()), expiryTTL(past))
+	assert.Equal(t, MaxRelativeTTL+1, expiryTTL(time.Unix(60, 0)))
+	assert.Equal(t, int32(math.MaxInt32), expiryTTL(time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestEncodersSendExpireAt(t *testing.T) {
+	later := time.Now().Add(2 * time.Duration(MaxRelativeTTL) * time.Second)
+	expected := "T" + strconv.FormatInt(later.Unix(), 10) + " "
+
+	set := &MetaSetEncoder{Key: "key", Value: []byte("v"), TTL: 60, BlockTTL: -1, ExpireAt: later}
+	var buffer bytes.Buffer
+	require.NoError(t, set.Encode(&buffer))
+	assert.Contains(t, buffer.String(), expected)
+	assert.NotContains(t, buffer.String(), "T60 ")
+
+	get := CreateMetaGetEncoder()
+	get.Reset()
+	get.Key = "key"
+	get.UpdateExpireAt = later
+	buffer.Reset()
+	require.NoError(t, get.Encode(&buffer))
+	assert.Equal(t, "mg key "+expected+"\r\n", buffer.String())
+
+	e, _, err := ClassicCodec(get, CreateMetaGetDecoder())
+	require.NoError(t, err)
+	buffer.Reset()
+	require.NoError(t, e.Encode(&buffer))
+	assert.Equal(t, "touch key "+strconv.FormatInt(later.Unix(), 10)+"\r\n", buffer.String())
+}
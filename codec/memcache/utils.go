@@ -1,15 +1,17 @@
 package memcache
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"strconv"
+	"time"
+
+	"github.com/stripe/memlink/codec"
 )
 
 // Helper method whenever there's need to read and discard 2 bytes worth of data.
 // raises an error if the next 2 bytes aren't \r\n
-func ReadCLRF(reader *bufio.Reader) error {
+func ReadCLRF(reader codec.Reader) error {
 	cr, err := reader.ReadByte()
 	if err != nil {
 		return err
@@ -29,7 +31,7 @@ func ReadCLRF(reader *bufio.Reader) error {
 }
 
 // Read the Meta No Op response including the \r\n response.
-func ReadMNResp(reader *bufio.Reader) error {
+func ReadMNResp(reader codec.Reader) error {
 	// according to the protocol, the meta no-op response should be always the "MN\r\n" bytes
 	// and opaque tokens are not provided here. the next 4 bytes from the reader needs to be exactly the list above.
 	m, err := reader.ReadByte()
@@ -67,6 +69,16 @@ func isLegalMemcacheKey(key string) bool {
 	return true
 }
 
+// ValidateKey returns an *IllegaleMemcacheKey error if key can't be sent to memcached as is. Encoders reject such keys
+// as well, but failing to encode a request resets the underlying connection, so callers batching user provided keys
+// should validate them upfront.
+func ValidateKey(key string) error {
+	if !isLegalMemcacheKey(key) {
+		return &IllegaleMemcacheKey{IllegalKey: key}
+	}
+	return nil
+}
+
 func writeKey(b *bytes.Buffer, key string) error {
 	if !isLegalMemcacheKey(key) {
 		return fmt.Errorf("%q is an invalid key in memcache", key)
@@ -108,18 +120,29 @@ func writeClientFlags(b *bytes.Buffer, clientFlags uint64) {
 	}
 }
 
-func writeTTL(b *bytes.Buffer, ttl int32) {
+func writeTTL(b *bytes.Buffer, ttl int32, convert bool) {
 	if ttl >= 0 {
 		b.WriteByte(TTL)
-		b.Write(strconv.AppendInt(b.AvailableBuffer(), int64(ttl), 10))
+		b.Write(strconv.AppendInt(b.AvailableBuffer(), int64(wireTTL(ttl, convert)), 10))
 		b.WriteByte(Space)
 	}
 }
 
-func writeBlockTTL(b *bytes.Buffer, blockTTL int32) {
+// writeExpiry writes the TTL of an item expiring at expireAt if it's set, or else in ttl seconds.
+func writeExpiry(b *bytes.Buffer, ttl int32, expireAt time.Time, convert bool) {
+	if expireAt.IsZero() {
+		writeTTL(b, ttl, convert)
+		return
+	}
+	b.WriteByte(TTL)
+	b.Write(strconv.AppendInt(b.AvailableBuffer(), int64(expiryTTL(expireAt)), 10))
+	b.WriteByte(Space)
+}
+
+func writeBlockTTL(b *bytes.Buffer, blockTTL int32, convert bool) {
 	if blockTTL >= 0 {
 		b.WriteByte(BlockTTL)
-		b.Write(strconv.AppendInt(b.AvailableBuffer(), int64(blockTTL), 10))
+		b.Write(strconv.AppendInt(b.AvailableBuffer(), int64(wireTTL(blockTTL, convert)), 10))
 		b.WriteByte(Space)
 	}
 }
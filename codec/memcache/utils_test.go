@@ -76,6 +76,15 @@ func TestIsLegalMemcacheKey(t *testing.T) {
 	}
 }
 
+func TestValidateKey(t *testing.T) {
+	assert.NoError(t, ValidateKey("validKey"))
+
+	err := ValidateKey("contain space")
+	var illegalKeyErr *IllegaleMemcacheKey
+	assert.ErrorAs(t, err, &illegalKeyErr)
+	assert.Equal(t, "contain space", illegalKeyErr.IllegalKey)
+}
+
 func TestWriteKey(t *testing.T) {
 	var buffer bytes.Buffer
 	assert.NoError(t, writeKey(&buffer, "testKey"))
@@ -99,14 +108,14 @@ func TestWriteCasId(t *testing.T) {
 
 func TestWriteTTL(t *testing.T) {
 	var buffer bytes.Buffer
-	writeTTL(&buffer, 3600)
+	writeTTL(&buffer, 3600, false)
 	expected := "T3600 "
 	assert.Equal(t, expected, buffer.String())
 }
 
 func TestWriteBlockTTL(t *testing.T) {
 	var buffer bytes.Buffer
-	writeBlockTTL(&buffer, 7200)
+	writeBlockTTL(&buffer, 7200, false)
 	expected := "N7200 "
 	assert.Equal(t, expected, buffer.String())
 }
@@ -0,0 +1,86 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidRequest is returned by the Validate methods of the encoders for a combination of fields memcached would
+// reject, or silently ignore.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// Validate checks that the fields of the encoder can be sent together: TTLBeforeUpdate orders the t and T flags.
+func (e *MetaGetEncoder) Validate() error {
+	if err := validateKeyFields("mg", e.BinaryKey, e.Base64EncodedKey); err != nil {
+		return err
+	}
+	if e.TTLBeforeUpdate && (!e.FetchRemainingTTL || !e.updatesTTL()) {
+		return fmt.Errorf("%w: mg with TTLBeforeUpdate needs both t and T", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// Validate checks that the fields of the encoder can be sent together: memcached only vivifies items on miss in append
+// and prepend modes, and needs a CAS value to compare with to invalidate an item.
+func (e *MetaSetEncoder) Validate() error {
+	if err := validateKeyFields("ms", e.BinaryKey, e.Base64EncodedKey); err != nil {
+		return err
+	}
+	if e.BlockTTL >= 0 && e.Mode != Append && e.Mode != Prepend {
+		return fmt.Errorf("%w: ms with N is only valid in append and prepend modes, got mode %q", ErrInvalidRequest, e.Mode)
+	}
+	if e.Invalidate && e.CasId == 0 {
+		return fmt.Errorf("%w: ms with I needs C, the CAS value to compare", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// Validate checks that the fields of the encoder can be sent together: memcached needs a CAS value to compare with to
+// invalidate an item, and only updates the TTL of an invalidated one.
+func (e *MetaDeleteEncoder) Validate() error {
+	if err := validateKeyFields("md", e.BinaryKey, e.Base64EncodedKey); err != nil {
+		return err
+	}
+	if e.Invalidate && e.CasId == 0 {
+		return fmt.Errorf("%w: md with I needs C, the CAS value to compare", ErrInvalidRequest)
+	}
+	if e.TTL >= 0 && !e.Invalidate {
+		return fmt.Errorf("%w: md with T is only valid along with I", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// Validate checks that the fields of the encoder can be sent together: the initial value is only used by memcached
+// when it creates the item on miss.
+func (e *MetaArithmeticEncoder) Validate() error {
+	if err := validateKeyFields("ma", e.BinaryKey, e.Base64EncodedKey); err != nil {
+		return err
+	}
+	if e.InitialValue != 0 && e.BlockTTL < 0 {
+		return fmt.Errorf("%w: ma with J is only valid along with N", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// Validate checks the encoders of the bulk request which can be validated.
+func (e *BulkEncoder[T]) Validate() error {
+	for i, encoder := range e.Encoders {
+		validator, ok := any(encoder).(interface{ Validate() error })
+		if !ok {
+			continue
+		}
+		if err := validator.Validate(); err != nil {
+			return fmt.Errorf("request %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateKeyFields rejects BinaryKey along with Base64EncodedKey, which flags Key as encoded by the caller: one of the
+// two keys would be ignored.
+func validateKeyFields(command string, binary []byte, base64Key bool) error {
+	if binary != nil && base64Key {
+		return fmt.Errorf("%w: %s with BinaryKey encodes the key itself, Base64EncodedKey is for keys encoded by the caller", ErrInvalidRequest, command)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoder interface{ Validate() error }
+		valid   bool
+	}{
+		{name: "mg", encoder: newGet(func(e *MetaGetEncoder) {}), valid: true},
+		{name: "mg binary key flagged as base64", encoder: newGet(func(e *MetaGetEncoder) {
+			e.BinaryKey = []byte{0xff}
+			e.Base64EncodedKey = true
+		})},
+		{name: "mg ttl before update", encoder: newGet(func(e *MetaGetEncoder) {
+			e.FetchRemainingTTL = true
+			e.UpdateTTL = 60
+			e.TTLBeforeUpdate = true
+		}), valid: true},
+		{name: "mg ttl before update without touch", encoder: newGet(func(e *MetaGetEncoder) {
+			e.FetchRemainingTTL = true
+			e.TTLBeforeUpdate = true
+		})},
+		{name: "ms vivify in append mode", encoder: newSet(func(e *MetaSetEncoder) {
+			e.Mode = Append
+			e.BlockTTL = 60
+		}), valid: true},
+		{name: "ms vivify in set mode", encoder: newSet(func(e *MetaSetEncoder) { e.BlockTTL = 60 })},
+		{name: "ms invalidate with cas", encoder: newSet(func(e *MetaSetEncoder) {
+			e.Invalidate = true
+			e.CasId = 1
+		}), valid: true},
+		{name: "ms invalidate without cas", encoder: newSet(func(e *MetaSetEncoder) { e.Invalidate = true })},
+		{name: "md invalidate with cas and ttl", encoder: newDelete(func(e *MetaDeleteEncoder) {
+			e.Invalidate = true
+			e.CasId = 1
+			e.TTL = 30
+		}), valid: true},
+		{name: "md invalidate without cas", encoder: newDelete(func(e *MetaDeleteEncoder) { e.Invalidate = true })},
+		{name: "md ttl without invalidate", encoder: newDelete(func(e *MetaDeleteEncoder) { e.TTL = 30 })},
+		{name: "ma initial value with auto create", encoder: newArithmetic(func(e *MetaArithmeticEncoder) {
+			e.BlockTTL = 0
+			e.InitialValue = 10
+		}), valid: true},
+		{name: "ma initial value without auto create", encoder: newArithmetic(func(e *MetaArithmeticEncoder) { e.InitialValue = 10 })},
+		{name: "bulk", encoder: &BulkEncoder[*MetaDeleteEncoder]{Encoders: []*MetaDeleteEncoder{
+			newDelete(func(e *MetaDeleteEncoder) {}),
+			newDelete(func(e *MetaDeleteEncoder) { e.TTL = 30 }),
+		}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.encoder.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidRequest)
+			}
+		})
+	}
+}
+
+func newGet(set func(e *MetaGetEncoder)) *MetaGetEncoder {
+	e := CreateMetaGetEncoder()
+	e.Reset()
+	e.Key = "k"
+	set(e)
+	return e
+}
+
+func newSet(set func(e *MetaSetEncoder)) *MetaSetEncoder {
+	e := CreateMetaSetEncoder()
+	e.Reset()
+	e.Key = "k"
+	set(e)
+	return e
+}
+
+func newDelete(set func(e *MetaDeleteEncoder)) *MetaDeleteEncoder {
+	e := CreateMetaDeleteEncoder()
+	e.Reset()
+	e.Key = "k"
+	set(e)
+	return e
+}
+
+func newArithmetic(set func(e *MetaArithmeticEncoder)) *MetaArithmeticEncoder {
+	e := CreateArithmeticEncoder()
+	e.Reset()
+	e.Key = "k"
+	set(e)
+	return e
+}
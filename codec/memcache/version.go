@@ -1,7 +1,7 @@
 package memcache
 
 import (
-	"bufio"
+	"bytes"
 	"errors"
 	"strings"
 
@@ -12,9 +12,13 @@ var errNonVersionResp = errors.New("expected VERSION prefix in response")
 
 type VersionEncoder struct{}
 
-func (e *VersionEncoder) Encode(writer *bufio.Writer) error {
+func (e *VersionEncoder) Encode(writer codec.Writer) error {
 	b := bytePool.Get()
 	defer bytePool.Put(b)
+	return e.EncodeWithScratch(writer, b)
+}
+
+func (e *VersionEncoder) EncodeWithScratch(writer codec.Writer, b *bytes.Buffer) error {
 
 	b.Write(Version)
 	b.Write(CRLF)
@@ -30,7 +34,7 @@ type VersionDecoder struct {
 	HdrLine string
 }
 
-func (d *VersionDecoder) Decode(reader *bufio.Reader) error {
+func (d *VersionDecoder) Decode(reader codec.Reader) error {
 	hdrLine, err := reader.ReadString('\n')
 	if err != nil {
 		return err
@@ -52,6 +56,7 @@ func (d *VersionDecoder) Reset() {
 }
 
 var _ codec.LinkEncoder = (*VersionEncoder)(nil)
+var _ codec.ScratchEncoder = (*VersionEncoder)(nil)
 var _ codec.LinkDecoder = (*VersionDecoder)(nil)
 
 func CreateVersionEncoder() *VersionEncoder {
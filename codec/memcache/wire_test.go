@@ -0,0 +1,155 @@
+package memcache
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the output of the tests")
+
+// wireCases are configured encoders along with a name, whose wire bytes are pinned by testdata/wire.golden.
+var wireCases = []struct {
+	name    string
+	encoder func() codec.LinkEncoder
+}{
+	{"mg value", func() codec.LinkEncoder {
+		return newGet(func(e *MetaGetEncoder) { e.FetchValue = true })
+	}},
+	{"mg every fetch flag", func() codec.LinkEncoder {
+		return newGet(func(e *MetaGetEncoder) {
+			e.FetchCasId = true
+			e.FetchClientFlags = true
+			e.FetchItemHitBefore = true
+			e.FetchKey = true
+			e.FetchLastAccessedTime = true
+			e.FetchItemSizeInBytes = true
+			e.FetchRemainingTTL = true
+			e.PreventLRUBump = true
+			e.FetchValue = true
+			e.Opaque = 7
+		})
+	}},
+	{"mg vivify recache and touch", func() codec.LinkEncoder {
+		return newGet(func(e *MetaGetEncoder) {
+			e.FetchValue = true
+			e.BlockTTL = 30
+			e.RecacheTTL = 10
+			e.UpdateTTL = 60
+		})
+	}},
+	{"mg touch", func() codec.LinkEncoder {
+		return newGet(func(e *MetaGetEncoder) { e.UpdateTTL = 60 })
+	}},
+	{"mg touch reporting the previous ttl", func() codec.LinkEncoder {
+		return newGet(func(e *MetaGetEncoder) {
+			e.FetchValue = true
+			e.FetchRemainingTTL = true
+			e.UpdateTTL = 60
+			e.TTLBeforeUpdate = true
+		})
+	}},
+	{"mg binary key", func() codec.LinkEncoder {
+		return newGet(func(e *MetaGetEncoder) { e.BinaryKey = []byte{0xde, 0xad, 0xbe, 0xef} })
+	}},
+	{"ms", func() codec.LinkEncoder {
+		return newSet(func(e *MetaSetEncoder) {
+			e.Value = []byte("value")
+			e.TTL = 60
+			e.ClientFlags = 42
+		})
+	}},
+	{"ms add with cas", func() codec.LinkEncoder {
+		return newSet(func(e *MetaSetEncoder) {
+			e.Value = []byte("value")
+			e.Mode = Add
+			e.CasId = 5
+			e.FetchCasId = true
+		})
+	}},
+	{"ms append vivify", func() codec.LinkEncoder {
+		return newSet(func(e *MetaSetEncoder) {
+			e.Value = []byte("tail")
+			e.Mode = Append
+			e.BlockTTL = 30
+		})
+	}},
+	{"ms quiet", func() codec.LinkEncoder {
+		return newSet(func(e *MetaSetEncoder) {
+			e.Value = []byte("value")
+			e.Quiet = true
+			e.Opaque = 9
+		})
+	}},
+	{"md", func() codec.LinkEncoder {
+		return newDelete(func(e *MetaDeleteEncoder) {})
+	}},
+	{"md invalidate", func() codec.LinkEncoder {
+		return newDelete(func(e *MetaDeleteEncoder) {
+			e.Invalidate = true
+			e.CasId = 5
+			e.TTL = 30
+		})
+	}},
+	{"ma incr", func() codec.LinkEncoder {
+		return newArithmetic(func(e *MetaArithmeticEncoder) {
+			e.Delta = 3
+			e.FetchValue = true
+		})
+	}},
+	{"ma decr auto create", func() codec.LinkEncoder {
+		return newArithmetic(func(e *MetaArithmeticEncoder) {
+			e.Decrement = true
+			e.Delta = 1
+			e.BlockTTL = 0
+			e.InitialValue = 10
+			e.TTL = 60
+		})
+	}},
+	{"mn", func() codec.LinkEncoder { return CreateMetaNoOpEncoder() }},
+	{"mg bulk", func() codec.LinkEncoder {
+		bulk := CreateBulkEncoder[*MetaGetEncoder](2)
+		bulk.Encoders = append(bulk.Encoders,
+			newGet(func(e *MetaGetEncoder) { e.Opaque = 1 }),
+			newGet(func(e *MetaGetEncoder) {
+				e.Key = "k2"
+				e.Opaque = 2
+			}))
+		return bulk
+	}},
+	{"version", func() codec.LinkEncoder { return CreateVersionEncoder() }},
+	{"stats", func() codec.LinkEncoder { return CreateStatsEncoder() }},
+	{"stats slabs", func() codec.LinkEncoder { return &StatsEncoder{Group: "slabs"} }},
+	{"lru_crawler metadump", func() codec.LinkEncoder { return CreateLruCrawlerMetadumpEncoder() }},
+}
+
+func Test_WireGolden(t *testing.T) {
+	var b strings.Builder
+	for _, tc := range wireCases {
+		wire, err := codec.EncodeToString(tc.encoder())
+		require.NoError(t, err, tc.name)
+		fmt.Fprintf(&b, "%s: %q\n", tc.name, wire)
+	}
+
+	path := filepath.Join("testdata", "wire.golden")
+	if *update {
+		require.NoError(t, os.WriteFile(path, []byte(b.String()), 0o644))
+	}
+	golden, err := os.ReadFile(path)
+	require.NoError(t, err, "run the tests with -update to create the golden file")
+	assert.Equal(t, string(golden), b.String(), "the wire bytes changed, run the tests with -update if it's deliberate")
+}
+
+func Test_EncodeToStringError(t *testing.T) {
+	wire, err := codec.EncodeToString(newGet(func(e *MetaGetEncoder) { e.Key = "with space" }))
+	assert.ErrorContains(t, err, "invalid key")
+	assert.Empty(t, wire)
+}
@@ -0,0 +1,103 @@
+package memcache
+
+import (
+	"encoding/base64"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// WrittenKey is a key a request may change, as the request sends it.
+type WrittenKey struct {
+	// Key is the key sent to memcached, base64 encoded if Base64 is set.
+	Key    string
+	Base64 bool
+	// RoutingKey is the key the request is placed with, see codec.RoutingKeyer.
+	RoutingKey string
+}
+
+// writtenKey returns the key a request sends, from the key fields of its encoder.
+func writtenKey(key string, base64Key bool, binary []byte, validated Key) WrittenKey {
+	switch {
+	case !validated.IsZero():
+		return WrittenKey{Key: validated.wire, Base64: validated.base64, RoutingKey: validated.raw}
+	case binary != nil:
+		return WrittenKey{Key: base64.StdEncoding.EncodeToString(binary), Base64: true, RoutingKey: string(binary)}
+	default:
+		return WrittenKey{Key: key, Base64: base64Key, RoutingKey: key}
+	}
+}
+
+// keysWriter is implemented by the encoders of the requests which may change items, see WrittenKeys.
+type keysWriter interface {
+	appendWrittenKeys(keys []WrittenKey) []WrittenKey
+}
+
+// WrittenKeys returns the keys the request of encoder may change, nil if it only reads items or doesn't target any.
+func WrittenKeys(encoder codec.LinkEncoder) []WrittenKey {
+	return appendWrittenKeys(nil, encoder)
+}
+
+func appendWrittenKeys(keys []WrittenKey, encoder codec.LinkEncoder) []WrittenKey {
+	if writer, ok := encoder.(keysWriter); ok {
+		return writer.appendWrittenKeys(keys)
+	}
+	return keys
+}
+
+func (e *MetaSetEncoder) appendWrittenKeys(keys []WrittenKey) []WrittenKey {
+	return append(keys, writtenKey(e.Key, e.Base64EncodedKey, e.BinaryKey, e.ValidatedKey))
+}
+
+func (e *MetaDeleteEncoder) appendWrittenKeys(keys []WrittenKey) []WrittenKey {
+	return append(keys, writtenKey(e.Key, e.Base64EncodedKey, e.BinaryKey, e.ValidatedKey))
+}
+
+func (e *MetaArithmeticEncoder) appendWrittenKeys(keys []WrittenKey) []WrittenKey {
+	return append(keys, writtenKey(e.Key, e.Base64EncodedKey, e.BinaryKey, e.ValidatedKey))
+}
+
+func (e *BulkEncoder[T]) appendWrittenKeys(keys []WrittenKey) []WrittenKey {
+	for _, encoder := range e.Encoders {
+		keys = appendWrittenKeys(keys, encoder)
+	}
+	return keys
+}
+
+func (e *BarrierEncoder) appendWrittenKeys(keys []WrittenKey) []WrittenKey {
+	for _, group := range e.Groups {
+		for _, encoder := range group.Encoders {
+			keys = appendWrittenKeys(keys, encoder)
+		}
+	}
+	return keys
+}
+
+// the classic encoders write the keys of the meta requests they were translated from.
+func (e *classicSetEncoder) appendWrittenKeys(keys []WrittenKey) []WrittenKey {
+	return e.meta.appendWrittenKeys(keys)
+}
+
+func (e *classicDeleteEncoder) appendWrittenKeys(keys []WrittenKey) []WrittenKey {
+	return e.meta.appendWrittenKeys(keys)
+}
+
+func (e *classicArithmeticEncoder) appendWrittenKeys(keys []WrittenKey) []WrittenKey {
+	return e.meta.appendWrittenKeys(keys)
+}
+
+func (e *classicBulkEncoder) appendWrittenKeys(keys []WrittenKey) []WrittenKey {
+	for _, encoder := range e.encoders {
+		keys = appendWrittenKeys(keys, encoder)
+	}
+	return keys
+}
+
+var _ keysWriter = (*MetaSetEncoder)(nil)
+var _ keysWriter = (*MetaDeleteEncoder)(nil)
+var _ keysWriter = (*MetaArithmeticEncoder)(nil)
+var _ keysWriter = (*BulkEncoder[*MetaSetEncoder])(nil)
+var _ keysWriter = (*BarrierEncoder)(nil)
+var _ keysWriter = (*classicSetEncoder)(nil)
+var _ keysWriter = (*classicDeleteEncoder)(nil)
+var _ keysWriter = (*classicArithmeticEncoder)(nil)
+var _ keysWriter = (*classicBulkEncoder)(nil)
@@ -0,0 +1,55 @@
+package memcache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrittenKeys(t *testing.T) {
+	plain := WrittenKey{Key: "key", RoutingKey: "key"}
+	assert.Equal(t, []WrittenKey{plain}, WrittenKeys(&MetaSetEncoder{Key: "key"}))
+	assert.Equal(t, []WrittenKey{plain}, WrittenKeys(&MetaDeleteEncoder{Key: "key"}))
+	assert.Equal(t, []WrittenKey{plain}, WrittenKeys(&MetaArithmeticEncoder{Key: "key"}))
+	assert.Empty(t, WrittenKeys(&MetaGetEncoder{Key: "key"}))
+	assert.Empty(t, WrittenKeys(&VersionEncoder{}))
+
+	// the keys are the ones sent, placed like the requests.
+	assert.Equal(t,
+		[]WrittenKey{{Key: "a2V5", Base64: true, RoutingKey: "a2V5"}},
+		WrittenKeys(&MetaSetEncoder{Key: "a2V5", Base64EncodedKey: true}),
+	)
+	assert.Equal(t,
+		[]WrittenKey{{Key: "AAE=", Base64: true, RoutingKey: "\x00\x01"}},
+		WrittenKeys(&MetaDeleteEncoder{BinaryKey: []byte{0, 1}}),
+	)
+	validated, err := NewBinaryKey([]byte{0, 1})
+	require.NoError(t, err)
+	assert.Equal(t,
+		[]WrittenKey{{Key: "AAE=", Base64: true, RoutingKey: "\x00\x01"}},
+		WrittenKeys(&MetaSetEncoder{ValidatedKey: validated}),
+	)
+
+	bulk := &BulkEncoder[*MetaSetEncoder]{Encoders: []*MetaSetEncoder{{Key: "a"}, {Key: "b"}}}
+	assert.Equal(t, []WrittenKey{{Key: "a", RoutingKey: "a"}, {Key: "b", RoutingKey: "b"}}, WrittenKeys(bulk))
+
+	barrier := &BarrierEncoder{Groups: []*BarrierGroup{{}, {}}}
+	barrier.Groups[0].Encoders = append(barrier.Groups[0].Encoders, &MetaDeleteEncoder{Key: "a"})
+	barrier.Groups[1].Encoders = append(barrier.Groups[1].Encoders, &MetaGetEncoder{Key: "b"})
+	assert.Equal(t, []WrittenKey{{Key: "a", RoutingKey: "a"}}, WrittenKeys(barrier))
+
+	// the classic requests write the keys of the meta ones they were translated from.
+	deleteEncoder := CreateMetaDeleteEncoder()
+	deleteEncoder.Reset()
+	deleteEncoder.Key = "key"
+	classic, _, err := ClassicCodec(deleteEncoder, CreateMetaDeleteDecoder())
+	require.NoError(t, err)
+	assert.Equal(t, []WrittenKey{plain}, WrittenKeys(classic))
+	getEncoder := CreateMetaGetEncoder()
+	getEncoder.Reset()
+	getEncoder.Key = "key"
+	classic, _, err = ClassicCodec(getEncoder, CreateMetaGetDecoder())
+	require.NoError(t, err)
+	assert.Empty(t, WrittenKeys(classic))
+}
@@ -0,0 +1,43 @@
+package codec
+
+// RoutingHint tells a connection pool where to send a link when the key of its request isn't the right placement, or
+// there isn't a single one, e.g. for pipelines and bulk requests. The zero value lets the pool hash the key of the
+// request, see RoutingKeyer.
+type RoutingHint struct {
+	// Backend is the address of the backend to send the link to.
+	Backend string
+	// HashKey is hashed by the pool to pick the backend, instead of the key of the request.
+	HashKey string
+	// Broadcast, when set, sends the request to every backend, the response of each being decoded into the decoder
+	// it returns for the address of the backend. The decoder of the link isn't used, and the link completes once every
+	// backend answered. The encoder is written by every connection concurrently.
+	Broadcast func(backend string) LinkDecoder
+}
+
+// RouteToBackend sends the link to the backend with the given address.
+func RouteToBackend(addr string) RoutingHint {
+	return RoutingHint{Backend: addr}
+}
+
+// RouteByHashKey places the link as if its request targeted key.
+func RouteByHashKey(key string) RoutingHint {
+	return RoutingHint{HashKey: key}
+}
+
+// RouteBroadcast sends the request of the link to every backend, decoding their responses into the decoders returned
+// by newDecoder.
+func RouteBroadcast(newDecoder func(backend string) LinkDecoder) RoutingHint {
+	return RoutingHint{Broadcast: newDecoder}
+}
+
+// RoutedLink is implemented by links carrying a RoutingHint.
+type RoutedLink interface {
+	Link
+	RoutingHint() RoutingHint
+}
+
+// RoutingKeyer is implemented by encoders whose request targets a single key, which the pool hashes to pick the
+// backend of links without a RoutingHint.
+type RoutingKeyer interface {
+	RoutingKey() string
+}
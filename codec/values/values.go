@@ -0,0 +1,85 @@
+// Package values provides ready-made serializations of the values stored by a client.Cache. Each one identifies its
+// format with the client flags of the items, which are the same for every service using the package, so that a value
+// written by one service is read back by another, or reported as written in another format.
+package values
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/stripe/memlink/client"
+)
+
+// The formats recorded in the client flags of the items.
+const (
+	// FormatJSON is the format of JSON.
+	FormatJSON = client.FormatJSON
+	// FormatMsgpack is the format of Msgpack.
+	FormatMsgpack uint64 = 2
+	// FormatProtobuf is the format of Protobuf.
+	FormatProtobuf uint64 = 3
+)
+
+// ErrNotProtoMessage is returned by Protobuf for the values which aren't protocol buffer messages.
+var ErrNotProtoMessage = errors.New("values: value is not a proto.Message")
+
+// JSON serializes values with encoding/json, it's the client's own JSONMarshaler.
+type JSON = client.JSONMarshaler
+
+// Msgpack serializes values in MessagePack, more compact than JSON and faster to decode. Struct fields are named after
+// their msgpack tag, or their name.
+type Msgpack struct{}
+
+func (Msgpack) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (Msgpack) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+func (Msgpack) Format() uint64 {
+	return FormatMsgpack
+}
+
+// Protobuf serializes protocol buffer messages in their wire format, for a cache of message pointers, e.g. a
+// client.Cache[*pb.User]. Messages are allocated as needed when unmarshaling.
+type Protobuf struct{}
+
+func (Protobuf) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrNotProtoMessage, v)
+	}
+	return proto.Marshal(m)
+}
+
+// Unmarshal decodes data into v, either a message or a pointer to a message pointer, which is set to a new message
+// when nil.
+func (Protobuf) Unmarshal(data []byte, v any) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() || ptr.Elem().Kind() != reflect.Pointer {
+		return fmt.Errorf("%w: %T", ErrNotProtoMessage, v)
+	}
+	elem := ptr.Elem()
+	if elem.IsNil() {
+		elem.Set(reflect.New(elem.Type().Elem()))
+	}
+	m, ok := elem.Interface().(proto.Message)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotProtoMessage, v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+func (Protobuf) Format() uint64 {
+	return FormatProtobuf
+}
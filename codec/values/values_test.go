@@ -0,0 +1,95 @@
+package values
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/stripe/memlink/client"
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+var (
+	_ client.Marshaler = JSON{}
+	_ client.Marshaler = Msgpack{}
+	_ client.Marshaler = Protobuf{}
+)
+
+type user struct {
+	Name  string   `json:"name" msgpack:"name"`
+	Roles []string `json:"roles" msgpack:"roles"`
+}
+
+func TestMarshalers(t *testing.T) {
+	tests := []struct {
+		name      string
+		marshaler client.Marshaler
+		format    uint64
+	}{
+		{name: "json", marshaler: JSON{}, format: FormatJSON},
+		{name: "msgpack", marshaler: Msgpack{}, format: FormatMsgpack},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.format, test.marshaler.Format())
+			data, err := test.marshaler.Marshal(user{Name: "ada", Roles: []string{"admin"}})
+			require.NoError(t, err)
+			var got user
+			require.NoError(t, test.marshaler.Unmarshal(data, &got))
+			assert.Equal(t, user{Name: "ada", Roles: []string{"admin"}}, got)
+		})
+	}
+}
+
+func TestProtobuf(t *testing.T) {
+	ts := timestamppb.New(time.Unix(1700000000, 42))
+	data, err := Protobuf{}.Marshal(ts)
+	require.NoError(t, err)
+
+	// into a message pointer, allocated when nil.
+	var got *timestamppb.Timestamp
+	require.NoError(t, Protobuf{}.Unmarshal(data, &got))
+	assert.True(t, proto.Equal(ts, got))
+	// into a message.
+	msg := &timestamppb.Timestamp{Nanos: 7}
+	require.NoError(t, Protobuf{}.Unmarshal(data, msg))
+	assert.True(t, proto.Equal(ts, msg))
+
+	_, err = Protobuf{}.Marshal(user{})
+	assert.ErrorIs(t, err, ErrNotProtoMessage)
+	var u *user
+	assert.ErrorIs(t, Protobuf{}.Unmarshal(data, &u), ErrNotProtoMessage)
+	assert.ErrorIs(t, Protobuf{}.Unmarshal(data, "not a pointer"), ErrNotProtoMessage)
+}
+
+func TestCaches(t *testing.T) {
+	srv, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv.Close() //nolint: errcheck
+	mc, err := client.NewClient([]string{srv.Addr().String()}, 1)
+	require.NoError(t, err)
+	defer mc.Close() //nolint: errcheck
+	ctx := context.Background()
+
+	timestamps := client.NewCache[*timestamppb.Timestamp](mc, Protobuf{})
+	ts := timestamppb.New(time.Unix(1700000000, 0))
+	require.NoError(t, timestamps.Set(ctx, "ts", ts, 0))
+	got, err := timestamps.Get(ctx, "ts")
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(ts, got))
+
+	require.NoError(t, client.NewCache[user](mc, JSON{}).Set(ctx, "u", user{Name: "ada"}, 0))
+	u, err := client.NewCache[user](mc, JSON{}).Get(ctx, "u")
+	require.NoError(t, err)
+	assert.Equal(t, user{Name: "ada"}, u)
+
+	_, err = client.NewCache[user](mc, Msgpack{}).Get(ctx, "u")
+	assert.ErrorIs(t, err, client.ErrFormatMismatch)
+	_, err = client.NewCache[user](mc, Msgpack{}).GetMulti(ctx, []string{"u", "ts"})
+	assert.ErrorIs(t, err, client.ErrFormatMismatch)
+}
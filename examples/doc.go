@@ -0,0 +1,9 @@
+// Package examples holds runnable examples of the memlink client. Every scenario takes a client and writes what it
+// observes to an io.Writer, so that it can be checked against any server.
+//
+// The Example functions run the scenarios against the in-memory fakeserver and pin their output, they run with the
+// rest of the tests. The integration tests of the testutil module run them against memcached containers and compare
+// their output with the fakeserver one, they need Docker and the memlink_integration build tag:
+//
+//	cd testutil && go test -tags memlink_integration ./...
+package examples
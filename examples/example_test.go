@@ -0,0 +1,104 @@
+package examples_test
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/stripe/memlink/client"
+	"github.com/stripe/memlink/examples"
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+// run runs scenario against a fresh fakeserver, writing its outcome to stdout.
+func run(scenario examples.Scenario) {
+	srv, err := fakeserver.Start()
+	if err != nil {
+		log.Fatalf("failed to start the fake server: %v", err)
+	}
+	defer srv.Close() //nolint: errcheck
+
+	mc, err := client.NewClient([]string{srv.Addr().String()}, 1, client.WithLogger(zap.NewNop()))
+	if err != nil {
+		log.Fatalf("failed to create the client: %v", err)
+	}
+	defer mc.Close() //nolint: errcheck
+
+	if err := scenario(context.Background(), mc, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func ExampleSimple() {
+	run(examples.Simple)
+	// Output:
+	// set greeting
+	// get greeting: "hello" expires=true
+	// delete greeting
+	// get greeting: not found=true
+}
+
+func ExampleBasic() {
+	run(examples.Basic)
+	// Output:
+	// set mykey: Stored
+	// get mykey: CacheHit "hello world" expires=true
+	// incr counter: Stored 10
+	// incr counter: Stored 15
+	// decr counter: Stored 12
+	// delete mykey: Deleted
+	// get mykey: CacheMiss
+}
+
+func ExampleMetadata() {
+	run(examples.Metadata)
+	// Output:
+	// add advanced_key: Stored cas=true
+	// add advanced_key: NotStored cas=false
+	// get advanced_key: CacheHit "advanced value" flags=42 size=14 expires=true cas=true
+}
+
+func ExampleTimeout() {
+	run(examples.Timeout)
+	// Output:
+	// add timeout_test: completed within the deadline
+	// get timeout_test: deadline exceeded=true
+}
+
+func ExampleMulti() {
+	run(examples.Multi)
+	// Output:
+	// set key1: Stored
+	// set key2: Stored
+	// set key3: Stored
+	// set key4: Stored
+	// set key5: Stored
+	// get key1: "value1"
+	// get key2: "value2"
+	// get key3: "value3"
+	// get key4: "value4"
+	// get key5: "value5"
+	// delete key1: Deleted
+	// delete key2: Deleted
+	// delete missing: NotFound
+}
+
+func ExampleBulkGet() {
+	run(examples.BulkGet)
+	// Output:
+	// get bulk_key1: CacheHit "bulk_value1"
+	// get bulk_key2: CacheMiss ""
+	// get bulk_key3: CacheHit "bulk_value3"
+	// get bulk_key4: CacheMiss ""
+	// get bulk_key5: CacheHit "bulk_value5"
+}
+
+func ExampleItemSizeLimit() {
+	run(examples.ItemSizeLimit)
+	// Output:
+	// set 1048448 bytes: rejected=false
+	// set 1048560 bytes: rejected=true
+	// get large: 1048448 bytes
+}
@@ -0,0 +1,271 @@
+package examples
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/stripe/memlink/client"
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// Scenario is a sequence of requests sent with mc, which writes their outcome to w.
+type Scenario func(ctx context.Context, mc client.MemcachedClient, w io.Writer) error
+
+// Scenarios lists every scenario of the package by name.
+var Scenarios = map[string]Scenario{
+	"Simple":        Simple,
+	"Basic":         Basic,
+	"Metadata":      Metadata,
+	"Timeout":       Timeout,
+	"Multi":         Multi,
+	"BulkGet":       BulkGet,
+	"ItemSizeLimit": ItemSizeLimit,
+}
+
+// Simple stores, reads and deletes a key with the convenience API, which manages the encoders and decoders itself.
+func Simple(ctx context.Context, mc client.MemcachedClient, w io.Writer) error {
+	if err := mc.Set(ctx, "greeting", []byte("hello"), 60); err != nil {
+		return fmt.Errorf("failed to set greeting: %w", err)
+	}
+	fmt.Fprintln(w, "set greeting")
+
+	value, item, err := mc.Get(ctx, "greeting")
+	if err != nil {
+		return fmt.Errorf("failed to get greeting: %w", err)
+	}
+	fmt.Fprintf(w, "get greeting: %q expires=%t\n", value, item.TTL > 0)
+
+	if err := mc.Delete(ctx, "greeting"); err != nil {
+		return fmt.Errorf("failed to delete greeting: %w", err)
+	}
+	fmt.Fprintln(w, "delete greeting")
+
+	_, _, err = mc.Get(ctx, "greeting")
+	fmt.Fprintf(w, "get greeting: not found=%t\n", errors.Is(err, client.ErrNotFound))
+	return nil
+}
+
+// Basic sets, reads, increments, decrements and deletes keys with the meta commands.
+func Basic(ctx context.Context, mc client.MemcachedClient, w io.Writer) error {
+	set := memcache.CreateMetaSetEncoder()
+	set.Reset()
+	set.Key = "mykey"
+	set.Value = []byte("hello world")
+	set.TTL = 60
+	setDecoder := memcache.CreateMetaSetDecoder()
+	if err := mc.MetaSet(ctx, set, setDecoder); err != nil {
+		return fmt.Errorf("failed to set mykey: %w", err)
+	}
+	fmt.Fprintf(w, "set mykey: %s\n", setDecoder.Status)
+
+	get := memcache.CreateMetaGetEncoder()
+	get.Reset()
+	get.Key = "mykey"
+	get.FetchValue = true
+	get.FetchRemainingTTL = true
+	getDecoder := memcache.CreateMetaGetDecoder()
+	if err := mc.MetaGet(ctx, get, getDecoder); err != nil {
+		return fmt.Errorf("failed to get mykey: %w", err)
+	}
+	// the remaining TTL may have ticked down already, only whether the item expires is stable.
+	fmt.Fprintf(w, "get mykey: %s %q expires=%t\n", getDecoder.Status, getDecoder.Value, getDecoder.RemainingTTLSeconds > 0)
+
+	incr := memcache.CreateArithmeticEncoder()
+	incr.Reset()
+	incr.Key = "counter"
+	incr.Delta = 5
+	incr.FetchValue = true
+	// the counter is created with the initial value if it doesn't exist yet.
+	incr.BlockTTL = 60
+	incr.InitialValue = 10
+	incrDecoder := memcache.CreateArithmeticDecoder()
+	if err := mc.MetaIncrement(ctx, incr, incrDecoder); err != nil {
+		return fmt.Errorf("failed to increment counter: %w", err)
+	}
+	fmt.Fprintf(w, "incr counter: %s %d\n", incrDecoder.Status, incrDecoder.ValueUInt64)
+
+	incrDecoder = memcache.CreateArithmeticDecoder()
+	if err := mc.MetaIncrement(ctx, incr, incrDecoder); err != nil {
+		return fmt.Errorf("failed to increment counter: %w", err)
+	}
+	fmt.Fprintf(w, "incr counter: %s %d\n", incrDecoder.Status, incrDecoder.ValueUInt64)
+
+	decr := memcache.CreateArithmeticEncoder()
+	decr.Reset()
+	decr.Key = "counter"
+	decr.Delta = 3
+	// MetaDecrement doesn't switch the mode of the encoder, it must be set.
+	decr.Decrement = true
+	decr.FetchValue = true
+	decrDecoder := memcache.CreateArithmeticDecoder()
+	if err := mc.MetaDecrement(ctx, decr, decrDecoder); err != nil {
+		return fmt.Errorf("failed to decrement counter: %w", err)
+	}
+	fmt.Fprintf(w, "decr counter: %s %d\n", decrDecoder.Status, decrDecoder.ValueUInt64)
+
+	del := memcache.CreateMetaDeleteEncoder()
+	del.Reset()
+	del.Key = "mykey"
+	delDecoder := memcache.CreateMetaDeleteDecoder()
+	if err := mc.MetaDelete(ctx, del, delDecoder); err != nil {
+		return fmt.Errorf("failed to delete mykey: %w", err)
+	}
+	fmt.Fprintf(w, "delete mykey: %s\n", delDecoder.Status)
+
+	getDecoder = memcache.CreateMetaGetDecoder()
+	if err := mc.MetaGet(ctx, get, getDecoder); err != nil {
+		return fmt.Errorf("failed to get mykey: %w", err)
+	}
+	fmt.Fprintf(w, "get mykey: %s\n", getDecoder.Status)
+	return nil
+}
+
+// Metadata stores an item with client flags, only if it doesn't exist, and reads it back along with its metadata.
+func Metadata(ctx context.Context, mc client.MemcachedClient, w io.Writer) error {
+	for i := 0; i < 2; i++ {
+		set := memcache.CreateMetaSetEncoder()
+		set.Reset()
+		set.Key = "advanced_key"
+		set.Value = []byte("advanced value")
+		set.TTL = 120
+		set.ClientFlags = 42
+		set.FetchCasId = true
+		set.Mode = memcache.Add
+		setDecoder := memcache.CreateMetaSetDecoder()
+		if err := mc.MetaSet(ctx, set, setDecoder); err != nil {
+			return fmt.Errorf("failed to add advanced_key: %w", err)
+		}
+		// the CAS id is assigned by the server, only its presence is stable.
+		fmt.Fprintf(w, "add advanced_key: %s cas=%t\n", setDecoder.Status, setDecoder.CasId > 0)
+	}
+
+	get := memcache.CreateMetaGetEncoder()
+	get.Reset()
+	get.Key = "advanced_key"
+	get.FetchValue = true
+	get.FetchRemainingTTL = true
+	get.FetchCasId = true
+	get.FetchClientFlags = true
+	get.FetchItemSizeInBytes = true
+	getDecoder := memcache.CreateMetaGetDecoder()
+	if err := mc.MetaGet(ctx, get, getDecoder); err != nil {
+		return fmt.Errorf("failed to get advanced_key: %w", err)
+	}
+	fmt.Fprintf(w, "get advanced_key: %s %q flags=%d size=%d expires=%t cas=%t\n", getDecoder.Status, getDecoder.Value,
+		getDecoder.ClientFlags, getDecoder.ItemSizeInBytes, getDecoder.RemainingTTLSeconds > 0, getDecoder.CasId > 0)
+	return nil
+}
+
+// Timeout bounds a request with a deadline, and shows the error of a request whose deadline already passed.
+func Timeout(ctx context.Context, mc client.MemcachedClient, w io.Writer) error {
+	bounded, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := mc.Add(bounded, client.Item{Key: "timeout_test", Value: []byte("test"), TTL: 30}); err != nil {
+		return fmt.Errorf("failed to add timeout_test: %w", err)
+	}
+	fmt.Fprintln(w, "add timeout_test: completed within the deadline")
+
+	expired, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancel()
+	_, err := mc.GetWithTTL(expired, "timeout_test")
+	fmt.Fprintf(w, "get timeout_test: deadline exceeded=%t\n", errors.Is(err, context.DeadlineExceeded))
+	return nil
+}
+
+// Multi writes, reads and deletes several keys at once.
+func Multi(ctx context.Context, mc client.MemcachedClient, w io.Writer) error {
+	keys := []string{"key1", "key2", "key3", "key4", "key5"}
+	items := make([]client.Item, 0, len(keys))
+	for i, key := range keys {
+		items = append(items, client.Item{Key: key, Value: []byte(fmt.Sprintf("value%d", i+1)), TTL: 60})
+	}
+
+	stored, err := mc.SetMulti(ctx, items)
+	if err != nil {
+		return fmt.Errorf("failed to set the keys: %w", err)
+	}
+	for _, key := range keys {
+		fmt.Fprintf(w, "set %s: %s\n", key, stored[key])
+	}
+
+	values, err := mc.GetMulti(ctx, append(keys, "missing"))
+	if err != nil {
+		return fmt.Errorf("failed to get the keys: %w", err)
+	}
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(w, "get %s: %q\n", key, values[key])
+	}
+
+	deleted, err := mc.DeleteMulti(ctx, append(keys[:2:2], "missing"))
+	if err != nil {
+		return fmt.Errorf("failed to delete the keys: %w", err)
+	}
+	for _, key := range sortedKeys(deleted) {
+		fmt.Fprintf(w, "delete %s: %s\n", key, deleted[key])
+	}
+	return nil
+}
+
+// BulkGet reads several keys with a single BulkGet request, matching the responses to the keys with their opaque
+// values.
+func BulkGet(ctx context.Context, mc client.MemcachedClient, w io.Writer) error {
+	keys := []string{"bulk_key1", "bulk_key2", "bulk_key3", "bulk_key4", "bulk_key5"}
+	// only the odd keys are stored, the other ones are reported as misses.
+	for i := 0; i < len(keys); i += 2 {
+		if err := mc.Add(ctx, client.Item{Key: keys[i], Value: []byte(fmt.Sprintf("bulk_value%d", i+1)), TTL: 60}); err != nil {
+			return fmt.Errorf("failed to add %s: %w", keys[i], err)
+		}
+	}
+
+	const firstOpaque = 1000
+	encoder := memcache.CreateBulkEncoder[*memcache.MetaGetEncoder](uint(len(keys)))
+	decoder := memcache.CreateBulkDecoder[*memcache.MetaGetDecoder](uint(len(keys)))
+	for i, key := range keys {
+		get := memcache.CreateMetaGetEncoder()
+		get.Reset()
+		get.Key = key
+		get.FetchValue = true
+		get.Opaque = firstOpaque + uint64(i)
+		encoder.Encoders = append(encoder.Encoders, get)
+		decoder.Decoders = append(decoder.Decoders, memcache.CreateMetaGetDecoder())
+		decoder.OpaqueToKey[get.Opaque] = key
+	}
+
+	if err := mc.BulkGet(ctx, encoder, decoder); err != nil {
+		return fmt.Errorf("failed to get the keys: %w", err)
+	}
+	for _, d := range decoder.Decoders {
+		fmt.Fprintf(w, "get %s: %s %q\n", decoder.OpaqueToKey[d.Opaque], d.Status, d.Value)
+	}
+	return nil
+}
+
+// ItemSizeLimit stores values around memcached's default item size limit, which bounds the item header and key along
+// with the value: a value just under the limit is rejected by memcached.
+func ItemSizeLimit(ctx context.Context, mc client.MemcachedClient, w io.Writer) error {
+	const itemSizeMax = 1024 * 1024
+	for _, size := range []int{itemSizeMax - 128, itemSizeMax - 16} {
+		err := mc.Set(ctx, "large", make([]byte, size), 60)
+		fmt.Fprintf(w, "set %d bytes: rejected=%t\n", size, err != nil)
+	}
+
+	value, _, err := mc.Get(ctx, "large")
+	if err != nil {
+		return fmt.Errorf("failed to get large: %w", err)
+	}
+	fmt.Fprintf(w, "get large: %d bytes\n", len(value))
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,281 @@
+package conformance
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+	"github.com/stripe/memlink/internal/fakeserver"
+)
+
+// keySeq makes the keys unique across runs, as a real memcached may be shared and keeps items between runs.
+var keySeq atomic.Uint64
+
+func uniqueKey(name string) string {
+	return "memlink:conformance:" + strconv.FormatInt(time.Now().UnixNano(), 36) + ":" + strconv.FormatUint(keySeq.Add(1), 10) + ":" + name
+}
+
+type server struct {
+	conn *net.TCPConn
+	rw   *bufio.ReadWriter
+}
+
+func dial(t *testing.T) *server {
+	addr := os.Getenv("MEMLINK_CONFORMANCE_ADDR")
+	if addr == "" {
+		srv, err := fakeserver.Start()
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = srv.Close() })
+		addr = srv.Addr().String()
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	require.NoError(t, conn.SetDeadline(time.Now().Add(10*time.Second)))
+
+	return &server{conn: conn.(*net.TCPConn), rw: bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))}
+}
+
+// roundTrip sends the request of e, decodes the response with d and returns the bytes which were sent.
+func (s *server) roundTrip(t *testing.T, e codec.LinkEncoder, d codec.LinkDecoder) string {
+	wire := &bytes.Buffer{}
+	w := bufio.NewWriter(wire)
+	require.NoError(t, e.Encode(w))
+	require.NoError(t, w.Flush())
+
+	_, err := s.rw.Write(wire.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, s.rw.Flush())
+	require.NoError(t, d.Decode(s.rw.Reader))
+	return wire.String()
+}
+
+func (s *server) set(t *testing.T, key, value string) {
+	e := memcache.CreateMetaSetEncoder()
+	e.Reset()
+	e.Key = key
+	e.Value = []byte(value)
+	d := memcache.CreateMetaSetDecoder()
+	s.roundTrip(t, e, d)
+	require.Equal(t, memcache.Stored, d.Status)
+}
+
+func (s *server) get(t *testing.T, key string) (string, bool) {
+	e := memcache.CreateMetaGetEncoder()
+	e.Reset()
+	e.Key = key
+	e.FetchValue = true
+	d := memcache.CreateMetaGetDecoder()
+	s.roundTrip(t, e, d)
+	return string(d.Value), d.Status == memcache.CacheHit
+}
+
+func TestMetaSetModes(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          memcache.MetaSetMode
+		vivify        bool
+		existing      bool
+		expectedToken string
+		expected      memcache.MetadataStatus
+		expectedValue string
+	}{
+		{name: "set missing", mode: "", expected: memcache.Stored, expectedValue: "new"},
+		{name: "set existing", mode: "", existing: true, expected: memcache.Stored, expectedValue: "new"},
+		{name: "add missing", mode: memcache.Add, expectedToken: " ME ", expected: memcache.Stored, expectedValue: "new"},
+		{name: "add existing", mode: memcache.Add, existing: true, expectedToken: " ME ", expected: memcache.NotStored, expectedValue: "old"},
+		{name: "replace missing", mode: memcache.Replace, expectedToken: " MR ", expected: memcache.NotStored},
+		{name: "replace existing", mode: memcache.Replace, existing: true, expectedToken: " MR ", expected: memcache.Stored, expectedValue: "new"},
+		{name: "append missing", mode: memcache.Append, expectedToken: " MA ", expected: memcache.NotStored},
+		{name: "append missing with vivify", mode: memcache.Append, vivify: true, expectedToken: " MA ", expected: memcache.Stored, expectedValue: "new"},
+		{name: "append existing", mode: memcache.Append, existing: true, expectedToken: " MA ", expected: memcache.Stored, expectedValue: "oldnew"},
+		{name: "prepend missing", mode: memcache.Prepend, expectedToken: " MP ", expected: memcache.NotStored},
+		{name: "prepend existing", mode: memcache.Prepend, existing: true, expectedToken: " MP ", expected: memcache.Stored, expectedValue: "newold"},
+	}
+
+	s := dial(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key := uniqueKey("ms")
+			if test.existing {
+				s.set(t, key, "old")
+			}
+
+			e := memcache.CreateMetaSetEncoder()
+			e.Reset()
+			e.Key = key
+			e.Value = []byte("new")
+			e.Mode = test.mode
+			if test.vivify {
+				e.BlockTTL = 60
+			}
+			d := memcache.CreateMetaSetDecoder()
+
+			wire := s.roundTrip(t, e, d)
+			if test.expectedToken != "" {
+				assert.Contains(t, wire, test.expectedToken)
+			} else {
+				assert.NotContains(t, wire, " M")
+			}
+			assert.Equal(t, test.expected, d.Status, d.HdrLine)
+
+			value, found := s.get(t, key)
+			assert.Equal(t, test.expectedValue != "", found)
+			assert.Equal(t, test.expectedValue, value)
+		})
+	}
+}
+
+func TestMetaSetCompareAndSwap(t *testing.T) {
+	s := dial(t)
+	key := uniqueKey("cas")
+	s.set(t, key, "old")
+
+	ge := memcache.CreateMetaGetEncoder()
+	ge.Reset()
+	ge.Key = key
+	ge.FetchCasId = true
+	gd := memcache.CreateMetaGetDecoder()
+	s.roundTrip(t, ge, gd)
+	require.NotZero(t, gd.CasId)
+
+	e := memcache.CreateMetaSetEncoder()
+	e.Reset()
+	e.Key = key
+	e.Value = []byte("new")
+	e.CasId = gd.CasId + 1
+	d := memcache.CreateMetaSetDecoder()
+	assert.Contains(t, s.roundTrip(t, e, d), " C"+strconv.FormatUint(gd.CasId+1, 10)+" ")
+	assert.Equal(t, memcache.Exists, d.Status)
+
+	e.CasId = gd.CasId
+	d.Reset()
+	s.roundTrip(t, e, d)
+	assert.Equal(t, memcache.Stored, d.Status)
+}
+
+func TestMetaGet(t *testing.T) {
+	s := dial(t)
+	key := uniqueKey("mg")
+
+	e := memcache.CreateMetaGetEncoder()
+	e.Reset()
+	e.Key = key
+	e.FetchValue = true
+	e.FetchRemainingTTL = true
+	e.Opaque = 1234
+	d := memcache.CreateMetaGetDecoder()
+
+	wire := s.roundTrip(t, e, d)
+	assert.Equal(t, "mg "+key+" t v O1234 \r\n", wire)
+	assert.Equal(t, memcache.CacheMiss, d.Status)
+	assert.Equal(t, uint64(1234), d.Opaque)
+
+	s.set(t, key, "value")
+	d.Reset()
+	s.roundTrip(t, e, d)
+	assert.Equal(t, memcache.CacheHit, d.Status)
+	assert.Equal(t, "value", string(d.Value))
+	assert.Equal(t, int32(-1), d.RemainingTTLSeconds)
+	assert.Equal(t, uint64(1234), d.Opaque)
+}
+
+func TestMetaDelete(t *testing.T) {
+	s := dial(t)
+	key := uniqueKey("md")
+
+	e := memcache.CreateMetaDeleteEncoder()
+	e.Reset()
+	e.Key = key
+	d := memcache.CreateMetaDeleteDecoder()
+	s.roundTrip(t, e, d)
+	assert.Equal(t, memcache.NotFound, d.Status)
+
+	s.set(t, key, "value")
+	d.Reset()
+	s.roundTrip(t, e, d)
+	assert.Equal(t, memcache.Deleted, d.Status)
+
+	_, found := s.get(t, key)
+	assert.False(t, found)
+}
+
+func TestMetaArithmetic(t *testing.T) {
+	s := dial(t)
+	key := uniqueKey("ma")
+
+	e := memcache.CreateArithmeticEncoder()
+	e.Reset()
+	e.Key = key
+	e.Delta = 5
+	e.FetchValue = true
+	d := memcache.CreateArithmeticDecoder()
+	s.roundTrip(t, e, d)
+	assert.Equal(t, memcache.NotFound, d.Status)
+
+	// auto created items are set to the initial value, the delta isn't applied.
+	e.BlockTTL = 0
+	e.InitialValue = 10
+	d.Reset()
+	wire := s.roundTrip(t, e, d)
+	assert.Contains(t, wire, " N0 ")
+	assert.Contains(t, wire, " J10 ")
+	assert.Equal(t, memcache.Stored, d.Status)
+	assert.Equal(t, uint64(10), d.ValueUInt64)
+
+	d.Reset()
+	s.roundTrip(t, e, d)
+	assert.Equal(t, uint64(15), d.ValueUInt64)
+
+	e.Decrement = true
+	d.Reset()
+	assert.Contains(t, s.roundTrip(t, e, d), " MD ")
+	assert.Equal(t, uint64(10), d.ValueUInt64)
+}
+
+func TestQuietModeAndNoOp(t *testing.T) {
+	s := dial(t)
+	stored := uniqueKey("quiet")
+	existing := uniqueKey("quiet")
+	s.set(t, existing, "old")
+
+	// a quiet bulk: the successful set is silent, only the failed add answers, then mn flushes the pipeline.
+	bulkEncoder := memcache.CreateBulkEncoder[*memcache.MetaSetEncoder](2)
+	for i, key := range []string{stored, existing} {
+		e := memcache.CreateMetaSetEncoder()
+		e.Reset()
+		e.Key = key
+		e.Value = []byte("new")
+		e.Mode = memcache.Add
+		e.Quiet = true
+		e.Opaque = uint64(i + 1)
+		bulkEncoder.Encoders = append(bulkEncoder.Encoders, e)
+	}
+	bulkDecoder := memcache.CreateQuietBulkDecoder(memcache.CreateMetaSetDecoder)
+
+	wire := s.roundTrip(t, bulkEncoder, bulkDecoder)
+	assert.Contains(t, wire, " q ")
+	assert.Contains(t, wire, "mn\r\n")
+	require.Len(t, bulkDecoder.Decoders, 1)
+	assert.Equal(t, memcache.NotStored, bulkDecoder.Decoders[0].Status)
+	assert.Equal(t, uint64(2), bulkDecoder.Decoders[0].Opaque)
+}
+
+func TestVersion(t *testing.T) {
+	s := dial(t)
+
+	d := memcache.CreateVersionDecoder()
+	assert.Equal(t, "version\r\n", s.roundTrip(t, memcache.CreateVersionEncoder(), d))
+	assert.Contains(t, d.HdrLine, "VERSION ")
+}
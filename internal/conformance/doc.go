@@ -0,0 +1,8 @@
+// Package conformance holds a protocol conformance test suite running every memcache encoder and decoder against a
+// server and asserting both the bytes put on the wire and the server behavior they trigger.
+//
+// By default the suite runs against the in-memory fakeserver. Set MEMLINK_CONFORMANCE_ADDR to the address of a real
+// memcached (>= 1.6) to validate the codec, and the fakeserver, against the reference implementation:
+//
+//	MEMLINK_CONFORMANCE_ADDR=localhost:11211 go test ./internal/conformance/
+package conformance
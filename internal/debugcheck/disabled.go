@@ -0,0 +1,40 @@
+//go:build !memlink_debug
+
+package debugcheck
+
+import (
+	"time"
+)
+
+// Enabled reports whether the checks are compiled in.
+const Enabled = false
+
+// Created records that obj must be Reset before being sent.
+func Created(any) {}
+
+// Reset records that obj was Reset.
+func Reset(any) {}
+
+// Acquire records that encoder and decoder are used by a pending request, and panics if either of them wasn't Reset
+// after it was created or is used by another pending request.
+func Acquire(_, _ any) {}
+
+// Release records that the request using encoder and decoder is over once done is closed, right away if done is nil
+// or already closed.
+func Release(_, _ any, _ <-chan struct{}) {}
+
+// Put panics if obj, being put back to its pool, is used by a pending request or was already put back since it was
+// last taken.
+func Put(any) {}
+
+// Get records that obj was taken from its pool by the caller of the function calling Get.
+func Get(any) {}
+
+// Abandon records that obj, taken from its pool, is deliberately left to the garbage collector.
+func Abandon(any) {}
+
+// Leaks returns the objects taken from their pool more than threshold ago and not put back, the oldest first. It
+// returns nil unless the checks are compiled in.
+func Leaks(time.Duration) []Leak {
+	return nil
+}
@@ -0,0 +1,8 @@
+// Package debugcheck detects the misuses of encoders and decoders which otherwise corrupt responses silently: putting
+// one back to its pool twice or while its request is pending, sharing one between concurrent requests, or sending an
+// encoder created without being Reset. The checks panic with a message telling the misuse. It also records where the
+// pooled objects are taken from their pool, so that the ones never put back can be reported by Leaks.
+//
+// The checks are only compiled in with the memlink_debug build tag, e.g. `go test -tags memlink_debug ./...`: without
+// it, every function is a no-op.
+package debugcheck
@@ -0,0 +1,72 @@
+//go:build memlink_debug
+
+package debugcheck
+
+import (
+	"time"
+)
+
+// Enabled reports whether the checks are compiled in.
+const Enabled = true
+
+var global = newTracker()
+
+// Created records that obj must be Reset before being sent.
+func Created(obj any) {
+	global.created(obj)
+}
+
+// Reset records that obj was Reset.
+func Reset(obj any) {
+	global.reset(obj)
+}
+
+// Acquire records that encoder and decoder are used by a pending request, and panics if either of them wasn't Reset
+// after it was created or is used by another pending request.
+func Acquire(encoder, decoder any) {
+	global.acquire(encoder, decoder)
+}
+
+// Release records that the request using encoder and decoder is over once done is closed, right away if done is nil
+// or already closed.
+func Release(encoder, decoder any, done <-chan struct{}) {
+	if done == nil || isClosed(done) {
+		global.release(encoder, decoder)
+		return
+	}
+	go func() {
+		<-done
+		global.release(encoder, decoder)
+	}()
+}
+
+func isClosed(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Put panics if obj, being put back to its pool, is used by a pending request or was already put back since it was
+// last taken.
+func Put(obj any) {
+	global.put(obj)
+}
+
+// Get records that obj was taken from its pool by the caller of the function calling Get.
+func Get(obj any) {
+	global.get(obj, 3)
+}
+
+// Abandon records that obj, taken from its pool, is deliberately left to the garbage collector.
+func Abandon(obj any) {
+	global.abandon(obj)
+}
+
+// Leaks returns the objects taken from their pool more than threshold ago and not put back, the oldest first. It
+// returns nil unless the checks are compiled in.
+func Leaks(threshold time.Duration) []Leak {
+	return global.leaks(threshold, time.Now())
+}
@@ -0,0 +1,160 @@
+package debugcheck
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLeakStackDepth bounds the frames recorded when an object is checked out of its pool.
+const maxLeakStackDepth = 32
+
+// Leak is an object checked out of its pool and not put back.
+type Leak struct {
+	// Type is the type of the object, e.g. *memcache.MetaGetEncoder.
+	Type string
+	// CheckedOut is when the object was taken from its pool.
+	CheckedOut time.Time
+	// Stack is the stack trace of the call which took the object from its pool.
+	Stack string
+}
+
+type checkout struct {
+	at  time.Time
+	pcs []uintptr
+}
+
+// tracker records the encoders and decoders waiting for a Reset and the ones used by a pending request. It holds on to
+// them until then, which is fine for the tests it's meant for.
+type tracker struct {
+	mu         sync.Mutex
+	unreset    map[any]struct{} // protected by mu
+	pending    map[any]struct{} // protected by mu
+	checkedOut map[any]checkout // protected by mu
+	// pooled holds the objects put back to their pool and not taken since. sync.Pool may drop them, in which case they
+	// stay there.
+	pooled map[any]struct{} // protected by mu
+}
+
+func newTracker() *tracker {
+	return &tracker{
+		unreset:    make(map[any]struct{}),
+		pending:    make(map[any]struct{}),
+		checkedOut: make(map[any]checkout),
+		pooled:     make(map[any]struct{}),
+	}
+}
+
+func (t *tracker) created(obj any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.unreset[obj] = struct{}{}
+}
+
+func (t *tracker) reset(obj any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.unreset, obj)
+}
+
+// acquire marks the encoder and the decoder of a request as pending until release is called.
+func (t *tracker) acquire(encoder, decoder any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, obj := range []any{encoder, decoder} {
+		if _, ok := t.unreset[obj]; ok {
+			panic(fmt.Sprintf("memlink: %T sent without being Reset after it was created, its zero TTLs would be sent instead of being ignored", obj))
+		}
+		if _, ok := t.pending[obj]; ok {
+			panic(fmt.Sprintf("memlink: %T used by two concurrent requests, their responses would be mixed up", obj))
+		}
+	}
+	for _, obj := range []any{encoder, decoder} {
+		if !stateless(obj) {
+			t.pending[obj] = struct{}{}
+		}
+	}
+}
+
+// stateless reports whether obj points to a zero-size value, e.g. a *memcache.VersionEncoder. Such objects have no
+// state to mix up, and distinct ones may share the same address.
+func stateless(obj any) bool {
+	v := reflect.ValueOf(obj)
+	return v.Kind() == reflect.Pointer && v.Type().Elem().Size() == 0
+}
+
+func (t *tracker) release(encoder, decoder any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.pending, encoder)
+	delete(t.pending, decoder)
+}
+
+func (t *tracker) put(obj any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.pending[obj]; ok {
+		panic(fmt.Sprintf("memlink: %T put back to its pool while its request is pending, the response would be decoded into its next user", obj))
+	}
+	if _, ok := t.pooled[obj]; ok {
+		panic(fmt.Sprintf("memlink: %T put back to its pool twice, two requests would share it and interleave their bytes", obj))
+	}
+	t.pooled[obj] = struct{}{}
+	delete(t.checkedOut, obj)
+}
+
+// get records that obj was taken from its pool, along with the stack of the caller skip frames above.
+func (t *tracker) get(obj any, skip int) {
+	pcs := make([]uintptr, maxLeakStackDepth)
+	pcs = pcs[:runtime.Callers(skip+1, pcs)]
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.pooled, obj)
+	t.checkedOut[obj] = checkout{at: time.Now(), pcs: pcs}
+}
+
+// abandon forgets obj, which was taken from its pool and deliberately left to the garbage collector.
+func (t *tracker) abandon(obj any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.checkedOut, obj)
+}
+
+// leaks returns the objects checked out before now minus threshold, the oldest first.
+func (t *tracker) leaks(threshold time.Duration, now time.Time) []Leak {
+	t.mu.Lock()
+	var leaks []Leak
+	var stacks [][]uintptr
+	for obj, c := range t.checkedOut {
+		if now.Sub(c.at) < threshold {
+			continue
+		}
+		leaks = append(leaks, Leak{Type: fmt.Sprintf("%T", obj), CheckedOut: c.at})
+		stacks = append(stacks, c.pcs)
+	}
+	t.mu.Unlock()
+
+	for i := range leaks {
+		leaks[i].Stack = formatStack(stacks[i])
+	}
+	slices.SortFunc(leaks, func(a, b Leak) int { return a.CheckedOut.Compare(b.CheckedOut) })
+	return leaks
+}
+
+func formatStack(pcs []uintptr) string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			return b.String()
+		}
+	}
+}
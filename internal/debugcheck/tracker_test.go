@@ -0,0 +1,87 @@
+package debugcheck
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type encoder struct{ key string }
+
+type decoder struct{ value string }
+
+func TestTrackerMissingReset(t *testing.T) {
+	tr := newTracker()
+	e, d := &encoder{}, &decoder{}
+	tr.created(e)
+	assert.PanicsWithValue(t, "memlink: *debugcheck.encoder sent without being Reset after it was created, its zero TTLs would be sent instead of being ignored", func() {
+		tr.acquire(e, d)
+	})
+
+	tr.reset(e)
+	assert.NotPanics(t, func() { tr.acquire(e, d) })
+}
+
+func TestTrackerConcurrentRequests(t *testing.T) {
+	tr := newTracker()
+	e1, e2, d := &encoder{}, &encoder{}, &decoder{}
+	tr.acquire(e1, d)
+	assert.PanicsWithValue(t, "memlink: *debugcheck.decoder used by two concurrent requests, their responses would be mixed up", func() {
+		tr.acquire(e2, d)
+	})
+
+	tr.release(e1, d)
+	assert.NotPanics(t, func() { tr.acquire(e2, d) })
+}
+
+func TestTrackerConcurrentStatelessRequests(t *testing.T) {
+	type stateless struct{}
+	tr := newTracker()
+	e := &stateless{}
+	tr.acquire(e, &decoder{})
+	assert.NotPanics(t, func() { tr.acquire(e, &decoder{}) })
+}
+
+func TestTrackerPutWhilePending(t *testing.T) {
+	tr := newTracker()
+	e, d := &encoder{}, &decoder{}
+	tr.acquire(e, d)
+	assert.PanicsWithValue(t, "memlink: *debugcheck.encoder put back to its pool while its request is pending, the response would be decoded into its next user", func() {
+		tr.put(e)
+	})
+
+	tr.release(e, d)
+	assert.NotPanics(t, func() { tr.put(e) })
+}
+
+func TestTrackerLeaks(t *testing.T) {
+	tr := newTracker()
+	leaked, returned, abandoned := &encoder{}, &encoder{}, &decoder{}
+	for _, obj := range []any{leaked, returned, abandoned} {
+		tr.get(obj, 1)
+	}
+	tr.put(returned)
+	tr.abandon(abandoned)
+
+	now := time.Now()
+	assert.Empty(t, tr.leaks(time.Minute, now))
+
+	leaks := tr.leaks(time.Minute, now.Add(time.Hour))
+	require.Len(t, leaks, 1)
+	assert.Equal(t, "*debugcheck.encoder", leaks[0].Type)
+	assert.Contains(t, leaks[0].Stack, "debugcheck.TestTrackerLeaks")
+}
+
+func TestTrackerDoublePut(t *testing.T) {
+	tr := newTracker()
+	e := &encoder{}
+	tr.put(e)
+	assert.PanicsWithValue(t, "memlink: *debugcheck.encoder put back to its pool twice, two requests would share it and interleave their bytes", func() {
+		tr.put(e)
+	})
+
+	tr.get(e, 1)
+	assert.NotPanics(t, func() { tr.put(e) })
+}
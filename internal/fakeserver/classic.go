@@ -0,0 +1,230 @@
+package fakeserver
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// classicGet serves get and gets, and gat and gats when touch is set.
+func (s *Server) classicGet(keys [][]byte, ttl int64, touch bool, w *bufio.Writer) error {
+	if len(keys) == 0 {
+		_, err := w.WriteString("ERROR\r\n")
+		return err
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range keys {
+		key := string(k)
+		it, ok := s.lookup(key, now)
+		if !ok {
+			continue
+		}
+		if touch {
+			it.expireAt = expiry(ttl, now)
+		}
+
+		// the cas token is always sent, clients using get ignore it.
+		fmt.Fprintf(w, "VALUE %s %d %d %d\r\n", key, it.flags, len(it.value), it.cas)
+		_, _ = w.Write(it.value)
+		_, _ = w.WriteString("\r\n")
+	}
+	_, err := w.WriteString("END\r\n")
+	return err
+}
+
+// classicStore serves "<cmd> <key> <flags> <exptime> <bytes> [<cas>] [noreply]".
+func (s *Server) classicStore(cmd string, tokens [][]byte, rw *bufio.ReadWriter) error {
+	minTokens := 4
+	if cmd == "cas" {
+		minTokens = 5
+	}
+	if len(tokens) < minTokens {
+		_, err := rw.WriteString("ERROR\r\n")
+		return err
+	}
+
+	key := string(tokens[0])
+	clientFlags, err := strconv.ParseUint(string(tokens[1]), 10, 32)
+	if err != nil {
+		return err
+	}
+	ttl, err := strconv.ParseInt(string(tokens[2]), 10, 64)
+	if err != nil {
+		return err
+	}
+	size, err := strconv.Atoi(string(tokens[3]))
+	if err != nil {
+		return err
+	}
+	var compareCas uint64
+	if cmd == "cas" {
+		if compareCas, err = strconv.ParseUint(string(tokens[4]), 10, 64); err != nil {
+			return err
+		}
+	}
+	noReply := bytes.Equal(tokens[len(tokens)-1], []byte("noreply"))
+
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(rw.Reader, data); err != nil {
+		return err
+	}
+	if !bytes.HasSuffix(data, []byte("\r\n")) {
+		return errors.New("fakeserver: data block is not terminated by CRLF")
+	}
+	value := append([]byte(nil), data[:size]...)
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.fits(key, size) {
+		if noReply {
+			return nil
+		}
+		_, err := rw.WriteString(tooLargeReply)
+		return err
+	}
+
+	existing, found := s.lookup(key, now)
+	status := "STORED"
+	switch cmd {
+	case "set":
+		s.store(key, &item{value: value, flags: clientFlags}, ttl, now)
+	case "add":
+		if found {
+			status = "NOT_STORED"
+			break
+		}
+		s.store(key, &item{value: value, flags: clientFlags}, ttl, now)
+	case "replace":
+		if !found {
+			status = "NOT_STORED"
+			break
+		}
+		s.store(key, &item{value: value, flags: clientFlags}, ttl, now)
+	case "append", "prepend":
+		if !found {
+			status = "NOT_STORED"
+			break
+		}
+		if cmd == "append" {
+			existing.value = append(existing.value, value...)
+		} else {
+			existing.value = append(value, existing.value...)
+		}
+		s.casSeq++
+		existing.cas = s.casSeq
+	case "cas":
+		switch {
+		case !found:
+			status = "NOT_FOUND"
+		case existing.cas != compareCas:
+			status = "EXISTS"
+		default:
+			s.store(key, &item{value: value, flags: clientFlags}, ttl, now)
+		}
+	}
+
+	if noReply {
+		return nil
+	}
+	_, err = rw.WriteString(status + "\r\n")
+	return err
+}
+
+func (s *Server) classicDelete(tokens [][]byte, w *bufio.Writer) error {
+	if len(tokens) == 0 {
+		_, err := w.WriteString("ERROR\r\n")
+		return err
+	}
+
+	key := string(tokens[0])
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := "NOT_FOUND"
+	if _, found := s.lookup(key, now); found {
+		delete(s.items, key)
+		status = "DELETED"
+	}
+
+	_, err := w.WriteString(status + "\r\n")
+	return err
+}
+
+// classicTouch serves "touch <key> <exptime>".
+func (s *Server) classicTouch(tokens [][]byte, w *bufio.Writer) error {
+	if len(tokens) < 2 {
+		_, err := w.WriteString("ERROR\r\n")
+		return err
+	}
+	ttl, err := strconv.ParseInt(string(tokens[1]), 10, 64)
+	if err != nil {
+		_, err := w.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return err
+	}
+
+	key := string(tokens[0])
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := "NOT_FOUND"
+	if it, found := s.lookup(key, now); found {
+		it.expireAt = expiry(ttl, now)
+		status = "TOUCHED"
+	}
+
+	_, err = w.WriteString(status + "\r\n")
+	return err
+}
+
+func (s *Server) classicArithmetic(decrement bool, tokens [][]byte, w *bufio.Writer) error {
+	if len(tokens) < 2 {
+		_, err := w.WriteString("ERROR\r\n")
+		return err
+	}
+
+	key := string(tokens[0])
+	delta, err := strconv.ParseUint(string(tokens[1]), 10, 64)
+	if err != nil {
+		_, err = w.WriteString("CLIENT_ERROR invalid numeric delta argument\r\n")
+		return err
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, found := s.lookup(key, now)
+	if !found {
+		_, err := w.WriteString("NOT_FOUND\r\n")
+		return err
+	}
+
+	current, err := strconv.ParseUint(string(existing.value), 10, 64)
+	if err != nil {
+		_, err = w.WriteString("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+		return err
+	}
+	if decrement {
+		current -= min(delta, current)
+	} else {
+		current += delta
+	}
+	existing.value = []byte(strconv.FormatUint(current, 10))
+	s.casSeq++
+	existing.cas = s.casSeq
+
+	_, err = w.WriteString(strconv.FormatUint(current, 10) + "\r\n")
+	return err
+}
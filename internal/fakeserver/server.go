@@ -0,0 +1,782 @@
+// Package fakeserver provides a small in-memory server speaking the subset of the memcached meta and classic text
+// protocols used by memlink. It exists so that packages built on top of the connection pool can be unit tested against
+// real sockets without requiring a memcached binary. It is not a faithful memcached implementation and should never
+// be used outside of tests.
+package fakeserver
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	netpkg "github.com/stripe/memlink/internal/net"
+)
+
+// ServerVersion is the version reported in response to the `version` command.
+const ServerVersion = "1.6.21"
+
+// DefaultItemSizeMax is the item_size_max reported by `stats settings` unless overridden with SetStats.
+const DefaultItemSizeMax = 1024 * 1024
+
+// itemHeaderSize is the size of the header memcached stores along with the key and value of an item, CAS value
+// included, on 64-bit platforms.
+const itemHeaderSize = 56
+
+// tooLargeReply is memcached's reply to the sets of items exceeding item_size_max.
+const tooLargeReply = "SERVER_ERROR object too large for cache\r\n"
+
+type item struct {
+	value    []byte
+	flags    uint64
+	cas      uint64
+	expireAt time.Time // zero value means the item never expires
+}
+
+func (i *item) expired(now time.Time) bool {
+	return !i.expireAt.IsZero() && !now.Before(i.expireAt)
+}
+
+func (i *item) remainingTTL(now time.Time) int64 {
+	if i.expireAt.IsZero() {
+		return -1
+	}
+	return int64(i.expireAt.Sub(now).Round(time.Second) / time.Second)
+}
+
+// Server is an in-memory memcached meta protocol server listening on a local TCP port.
+type Server struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	items    map[string]*item             // protected by mu
+	casSeq   uint64                       // protected by mu
+	commands map[string]int               // protected by mu
+	conns    map[net.Conn]struct{}        // protected by mu
+	stats    map[string]map[string]string // protected by mu
+	metaOff  bool                         // protected by mu
+	version  string                       // protected by mu
+	latency  map[string]time.Duration     // protected by mu
+	// compressions are the stream compressions accepted by the `compress` command of memcached proxies.
+	compressions []netpkg.Compression // protected by mu
+	// annotate returns the annotations appended to the header of the mg hits, like some proxies do.
+	annotate func(value []byte) string // protected by mu
+
+	wg sync.WaitGroup
+}
+
+// Start listens on a random local port and serves connections until Close is called.
+func Start() (*Server, error) {
+	return StartAt("127.0.0.1:0")
+}
+
+// StartAt is like Start, but listens on addr, e.g. the address of a closed server to restart it.
+func StartAt(addr string) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener: listener,
+		items:    make(map[string]*item),
+		commands: make(map[string]int),
+		conns:    make(map[net.Conn]struct{}),
+		latency:  make(map[string]time.Duration),
+		version:  ServerVersion,
+		stats: map[string]map[string]string{
+			"settings": {"item_size_max": strconv.Itoa(DefaultItemSizeMax)},
+		},
+	}
+
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close stops accepting connections, closes the open ones and waits for all the serving goroutines to exit.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// Get returns the value stored for key, bypassing the protocol.
+func (s *Server) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.lookup(key, time.Now())
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), it.value...), true
+}
+
+// Set stores value for key with the given ttl in seconds, bypassing the protocol.
+func (s *Server) Set(key string, value []byte, ttlSeconds int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store(key, &item{value: append([]byte(nil), value...)}, ttlSeconds, time.Now())
+}
+
+// CommandCount returns how many times the given command (e.g. "mg") has been received.
+func (s *Server) CommandCount(cmd string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands[cmd]
+}
+
+// SetStats sets the statistics returned by `stats <group>`, the empty group being the general purpose statistics.
+func (s *Server) SetStats(group string, stats map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats[group] = maps.Clone(stats)
+}
+
+// DisableMetaProtocol makes the server answer ERROR to the meta commands, like memcached releases predating them.
+func (s *Server) DisableMetaProtocol() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metaOff = true
+}
+
+// SetVersion makes the server report version in response to the `version` command instead of ServerVersion.
+func (s *Server) SetVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = version
+}
+
+// SetLatency delays the handling of every cmd command (e.g. "mg") by latency, a zero latency removes the delay.
+func (s *Server) SetLatency(cmd string, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency[cmd] = latency
+}
+
+// EnableCompression makes the server accept the given stream compressions, like the memcached proxies supporting
+// them. The server refuses them by default, like memcached.
+func (s *Server) EnableCompression(compressions ...netpkg.Compression) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compressions = append(s.compressions, compressions...)
+}
+
+// Annotate appends the tokens returned by annotate, given the value of the item, to the header of every mg hit, like
+// the proxies annotating the responses. A nil annotate removes the annotations.
+func (s *Server) Annotate(annotate func(value []byte) string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.annotate = annotate
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	for {
+		line, err := rw.ReadSlice('\n')
+		if err != nil {
+			return
+		}
+
+		tokens := bytes.Fields(line)
+		if len(tokens) == 2 && string(tokens[0]) == "compress" {
+			compressed, ok, err := s.compress(conn, netpkg.Compression(tokens[1]), rw)
+			if err != nil {
+				return
+			}
+			if ok {
+				defer compressed.Close() //nolint: errcheck
+				rw = bufio.NewReadWriter(bufio.NewReader(compressed), bufio.NewWriter(compressed))
+			}
+			continue
+		}
+
+		if err := s.handle(tokens, rw); err != nil {
+			return
+		}
+
+		// only flush when there's nothing else pipelined, which is what makes quiet mode observable.
+		if rw.Reader.Buffered() == 0 {
+			if err := rw.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// compress answers the `compress` command, and returns the compressed connection when the compression is accepted.
+func (s *Server) compress(conn net.Conn, compression netpkg.Compression, rw *bufio.ReadWriter) (net.Conn, bool, error) {
+	s.mu.Lock()
+	s.commands["compress"]++
+	accepted := slices.Contains(s.compressions, compression)
+	s.mu.Unlock()
+
+	if !accepted {
+		_, err := rw.WriteString("ERROR\r\n")
+		return nil, false, errors.Join(err, rw.Flush())
+	}
+	if _, err := rw.WriteString("OK\r\n"); err != nil {
+		return nil, false, err
+	}
+	if err := rw.Flush(); err != nil {
+		return nil, false, err
+	}
+	compressed, err := netpkg.NewCompressedConn(conn, compression)
+	return compressed, err == nil, err
+}
+
+func (s *Server) handle(tokens [][]byte, rw *bufio.ReadWriter) error {
+	if len(tokens) == 0 {
+		_, err := rw.WriteString("ERROR\r\n")
+		return err
+	}
+
+	cmd := string(tokens[0])
+	s.mu.Lock()
+	s.commands[cmd]++
+	metaOff := s.metaOff
+	version := s.version
+	latency := s.latency[cmd]
+	s.mu.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	if metaOff && len(cmd) == 2 && cmd[0] == 'm' {
+		_, err := rw.WriteString("ERROR\r\n")
+		return err
+	}
+
+	switch cmd {
+	case "version":
+		_, err := rw.WriteString("VERSION " + version + "\r\n")
+		return err
+	case "mn":
+		_, err := rw.WriteString("MN\r\n")
+		return err
+	case "mg":
+		return s.metaGet(tokens[1:], rw.Writer)
+	case "ms":
+		return s.metaSet(tokens[1:], rw)
+	case "md":
+		return s.metaDelete(tokens[1:], rw.Writer)
+	case "ma":
+		return s.metaArithmetic(tokens[1:], rw.Writer)
+	case "get", "gets":
+		return s.classicGet(tokens[1:], 0, false, rw.Writer)
+	case "gat", "gats":
+		if len(tokens) < 2 {
+			break
+		}
+		ttl, err := strconv.ParseInt(string(tokens[1]), 10, 64)
+		if err != nil {
+			break
+		}
+		return s.classicGet(tokens[2:], ttl, true, rw.Writer)
+	case "set", "add", "replace", "append", "prepend", "cas":
+		return s.classicStore(cmd, tokens[1:], rw)
+	case "delete":
+		return s.classicDelete(tokens[1:], rw.Writer)
+	case "touch":
+		return s.classicTouch(tokens[1:], rw.Writer)
+	case "incr", "decr":
+		return s.classicArithmetic(cmd == "decr", tokens[1:], rw.Writer)
+	case "stats":
+		group := ""
+		if len(tokens) > 1 {
+			group = string(tokens[1])
+		}
+		return s.writeStats(group, rw.Writer)
+	case "flush_all":
+		s.mu.Lock()
+		clear(s.items)
+		s.mu.Unlock()
+		_, err := rw.WriteString("OK\r\n")
+		return err
+	case "lru_crawler":
+		if len(tokens) > 1 && string(tokens[1]) == "metadump" {
+			return s.metadump(rw.Writer)
+		}
+	}
+
+	_, err := rw.WriteString("ERROR\r\n")
+	return err
+}
+
+// flags is a parsed set of meta flags: single letter flags map to their (possibly empty) token.
+type flags map[byte]string
+
+func parseFlags(tokens [][]byte) flags {
+	f := make(flags, len(tokens))
+	for _, t := range tokens {
+		if len(t) == 0 {
+			continue
+		}
+		f[t[0]] = string(t[1:])
+	}
+	return f
+}
+
+// flagIndex returns the index of the first token of flag, -1 if there is none.
+func flagIndex(tokens [][]byte, flag byte) int {
+	return slices.IndexFunc(tokens, func(t []byte) bool { return len(t) > 0 && t[0] == flag })
+}
+
+func (f flags) has(flag byte) bool {
+	_, ok := f[flag]
+	return ok
+}
+
+func (f flags) int(flag byte) (int64, bool, error) {
+	tok, ok := f[flag]
+	if !ok {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseInt(tok, 10, 64)
+	return v, true, err
+}
+
+func (f flags) uint(flag byte) (uint64, bool, error) {
+	tok, ok := f[flag]
+	if !ok {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseUint(tok, 10, 64)
+	return v, true, err
+}
+
+// fits reports whether an item of key and a value of size bytes fits item_size_max along with its header, the
+// terminating zero of its key and the \r\n following its value, like memcached checks. It must be called with mu held.
+func (s *Server) fits(key string, size int) bool {
+	itemSizeMax, err := strconv.Atoi(s.stats["settings"]["item_size_max"])
+	return err != nil || itemHeaderSize+len(key)+1+size+2 <= itemSizeMax
+}
+
+// lookup must be called with mu held.
+func (s *Server) lookup(key string, now time.Time) (*item, bool) {
+	it, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	if it.expired(now) {
+		delete(s.items, key)
+		return nil, false
+	}
+	return it, true
+}
+
+// store must be called with mu held.
+func (s *Server) store(key string, it *item, ttlSeconds int64, now time.Time) {
+	s.casSeq++
+	it.cas = s.casSeq
+	it.expireAt = expiry(ttlSeconds, now)
+	s.items[key] = it
+}
+
+func expiry(ttlSeconds int64, now time.Time) time.Time {
+	switch {
+	case ttlSeconds == 0:
+		return time.Time{}
+	case ttlSeconds < 0:
+		return now
+	case ttlSeconds > 60*60*24*30:
+		// memcached treats anything above 30 days as an absolute unix timestamp.
+		return time.Unix(ttlSeconds, 0)
+	}
+	return now.Add(time.Duration(ttlSeconds) * time.Second)
+}
+
+func writeReturnFlags(w *bufio.Writer, f flags, key string, it *item, now time.Time) {
+	if it != nil {
+		if f.has('t') {
+			fmt.Fprintf(w, " t%d", it.remainingTTL(now))
+		}
+		if f.has('c') {
+			fmt.Fprintf(w, " c%d", it.cas)
+		}
+		if f.has('f') {
+			fmt.Fprintf(w, " f%d", it.flags)
+		}
+		if f.has('s') {
+			fmt.Fprintf(w, " s%d", len(it.value))
+		}
+	}
+	if f.has('k') {
+		fmt.Fprintf(w, " k%s", key)
+	}
+	if o, ok := f['O']; ok {
+		fmt.Fprintf(w, " O%s", o)
+	}
+}
+
+func (s *Server) metaGet(tokens [][]byte, w *bufio.Writer) error {
+	if len(tokens) == 0 {
+		_, err := w.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return err
+	}
+
+	key := string(tokens[0])
+	f := parseFlags(tokens[1:])
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it, ok := s.lookup(key, now)
+	if !ok {
+		if f.has('q') {
+			return nil
+		}
+		_, err := w.WriteString("EN")
+		if err != nil {
+			return err
+		}
+		if o, ok := f['O']; ok {
+			fmt.Fprintf(w, " O%s", o)
+		}
+		_, err = w.WriteString("\r\n")
+		return err
+	}
+
+	ttl, touch, err := f.int('T')
+	if err != nil {
+		return err
+	}
+	// like memcached, t reports the TTL from before T when it comes first.
+	fetchTTL := flagIndex(tokens[1:], 't')
+	ttlBeforeTouch := touch && fetchTTL >= 0 && fetchTTL < flagIndex(tokens[1:], 'T')
+	if touch && !ttlBeforeTouch {
+		it.expireAt = expiry(ttl, now)
+	}
+
+	if f.has('v') {
+		fmt.Fprintf(w, "VA %d", len(it.value))
+	} else {
+		_, _ = w.WriteString("HD")
+	}
+	writeReturnFlags(w, f, key, it, now)
+	if s.annotate != nil {
+		_, _ = w.WriteString(" " + s.annotate(it.value))
+	}
+	_, _ = w.WriteString("\r\n")
+	if ttlBeforeTouch {
+		it.expireAt = expiry(ttl, now)
+	}
+	if f.has('v') {
+		_, _ = w.Write(it.value)
+		_, _ = w.WriteString("\r\n")
+	}
+	return nil
+}
+
+func (s *Server) metaSet(tokens [][]byte, rw *bufio.ReadWriter) error {
+	if len(tokens) < 2 {
+		_, err := rw.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return err
+	}
+
+	key := string(tokens[0])
+	size, err := strconv.Atoi(string(tokens[1]))
+	if err != nil {
+		return err
+	}
+	f := parseFlags(tokens[2:])
+
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(rw.Reader, data); err != nil {
+		return err
+	}
+	if !bytes.HasSuffix(data, []byte("\r\n")) {
+		return errors.New("fakeserver: data block is not terminated by CRLF")
+	}
+	value := data[:size]
+
+	ttl, _, err := f.int('T')
+	if err != nil {
+		return err
+	}
+	clientFlags, _, err := f.uint('F')
+	if err != nil {
+		return err
+	}
+	compareCas, hasCas, err := f.uint('C')
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.fits(key, size) {
+		_, err := rw.WriteString(tooLargeReply)
+		return err
+	}
+
+	existing, found := s.lookup(key, now)
+	status := "HD"
+	switch {
+	case hasCas && !found:
+		status = "NF"
+	case hasCas && existing.cas != compareCas:
+		status = "EX"
+	default:
+		switch f['M'] {
+		case "E", "e":
+			if found {
+				status = "NS"
+				break
+			}
+			s.store(key, &item{value: append([]byte(nil), value...), flags: clientFlags}, ttl, now)
+		case "R", "r":
+			if !found {
+				status = "NS"
+				break
+			}
+			s.store(key, &item{value: append([]byte(nil), value...), flags: clientFlags}, ttl, now)
+		case "A", "a", "P", "p":
+			if !found {
+				vivifyTTL, vivify, err := f.int('N')
+				if err != nil {
+					return err
+				}
+				if !vivify {
+					status = "NS"
+					break
+				}
+				s.store(key, &item{value: append([]byte(nil), value...), flags: clientFlags}, vivifyTTL, now)
+				break
+			}
+			if f['M'] == "A" || f['M'] == "a" {
+				existing.value = append(existing.value, value...)
+			} else {
+				existing.value = append(append([]byte(nil), value...), existing.value...)
+			}
+			s.casSeq++
+			existing.cas = s.casSeq
+		default:
+			s.store(key, &item{value: append([]byte(nil), value...), flags: clientFlags}, ttl, now)
+		}
+	}
+
+	if status == "HD" && f.has('q') {
+		return nil
+	}
+
+	_, _ = rw.WriteString(status)
+	it, _ := s.lookup(key, now)
+	if status != "HD" {
+		it = nil
+	}
+	writeReturnFlags(rw.Writer, f, key, it, now)
+	_, err = rw.WriteString("\r\n")
+	return err
+}
+
+func (s *Server) metaDelete(tokens [][]byte, w *bufio.Writer) error {
+	if len(tokens) == 0 {
+		_, err := w.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return err
+	}
+
+	key := string(tokens[0])
+	f := parseFlags(tokens[1:])
+	compareCas, hasCas, err := f.uint('C')
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, found := s.lookup(key, now)
+	status := "HD"
+	switch {
+	case !found:
+		status = "NF"
+	case hasCas && existing.cas != compareCas:
+		status = "EX"
+	case f.has('x'):
+		existing.value = nil
+	default:
+		delete(s.items, key)
+	}
+
+	// quiet mode hides both the success and the miss for deletes.
+	if (status == "HD" || status == "NF") && f.has('q') {
+		return nil
+	}
+
+	_, _ = w.WriteString(status)
+	writeReturnFlags(w, f, key, nil, now)
+	_, err = w.WriteString("\r\n")
+	return err
+}
+
+func (s *Server) metaArithmetic(tokens [][]byte, w *bufio.Writer) error {
+	if len(tokens) == 0 {
+		_, err := w.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return err
+	}
+
+	key := string(tokens[0])
+	f := parseFlags(tokens[1:])
+
+	delta := uint64(1)
+	if d, ok, err := f.uint('D'); err != nil {
+		return err
+	} else if ok {
+		delta = d
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, found := s.lookup(key, now)
+	if !found {
+		vivifyTTL, vivify, err := f.int('N')
+		if err != nil {
+			return err
+		}
+		if !vivify {
+			if f.has('q') {
+				return nil
+			}
+			_, _ = w.WriteString("NF")
+			writeReturnFlags(w, f, key, nil, now)
+			_, err := w.WriteString("\r\n")
+			return err
+		}
+		initial, _, err := f.uint('J')
+		if err != nil {
+			return err
+		}
+		existing = &item{value: []byte(strconv.FormatUint(initial, 10))}
+		s.store(key, existing, vivifyTTL, now)
+	} else {
+		current, err := strconv.ParseUint(string(existing.value), 10, 64)
+		if err != nil {
+			_, err = w.WriteString("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+			return err
+		}
+		if f['M'] == "D" || f['M'] == "d" || f['M'] == "-" {
+			if delta > current {
+				current = 0
+			} else {
+				current -= delta
+			}
+		} else {
+			current += delta
+		}
+		existing.value = []byte(strconv.FormatUint(current, 10))
+		s.casSeq++
+		existing.cas = s.casSeq
+		if ttl, ok, err := f.int('T'); err != nil {
+			return err
+		} else if ok {
+			existing.expireAt = expiry(ttl, now)
+		}
+	}
+
+	if f.has('v') {
+		fmt.Fprintf(w, "VA %d", len(existing.value))
+	} else {
+		if f.has('q') {
+			return nil
+		}
+		_, _ = w.WriteString("HD")
+	}
+	writeReturnFlags(w, f, key, existing, now)
+	_, _ = w.WriteString("\r\n")
+	if f.has('v') {
+		_, _ = w.Write(existing.value)
+		_, _ = w.WriteString("\r\n")
+	}
+	return nil
+}
+
+func (s *Server) writeStats(group string, w *bufio.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.stats[group]
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "STAT %s %s\r\n", name, stats[name])
+	}
+	_, err := w.WriteString("END\r\n")
+	return err
+}
+
+func (s *Server) metadump(w *bufio.Writer) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.items {
+		it, ok := s.lookup(key, now)
+		if !ok {
+			continue
+		}
+
+		exp := int64(-1)
+		if !it.expireAt.IsZero() {
+			exp = it.expireAt.Unix()
+		}
+		fmt.Fprintf(w, "key=%s exp=%d la=%d cas=%d fetch=no cls=1 size=%d\r\n", url.QueryEscape(key), exp, now.Unix(), it.cas, len(it.value))
+	}
+	_, err := w.WriteString("END\r\n")
+	return err
+}
@@ -0,0 +1,124 @@
+package net
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// AppendAdmin schedules the link on the admin connection of the given backend, which is opened on first use. Admin
+// commands, e.g. stats, lru_crawler or flush_all, can take long to answer and would otherwise hold back the requests
+// pipelined behind them on the data connections. The admin connections aren't part of Stats nor Topology.
+func (t *tcpConnPool) AppendAdmin(be *Backend, link codec.Link) error {
+	conn, err := t.adminConn(be)
+	if err != nil {
+		return fmt.Errorf("backend=%s: %w", be.String(), err)
+	}
+	return conn.Append(link)
+}
+
+// adminConn returns the admin connection of the backend, opening it if there's none yet.
+func (t *tcpConnPool) adminConn(be *Backend) (TCPConn, error) {
+	t.adminMu.Lock()
+	defer t.adminMu.Unlock()
+
+	if conn, ok := t.admin[be.String()]; ok {
+		return conn, nil
+	}
+	if t.adminClosed {
+		return nil, errConnPoolClosed
+	}
+	if !t.hasBackend(be) {
+		return nil, errBackendNotInPool
+	}
+
+	// the connection is dialed without holding mu, which would block the data requests of every backend.
+	conn, err := NewTCPConn(be, t.logger, t.connOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open admin connection: %w", err)
+	}
+	// Remove closes the admin connection of the backend after removing it from cm, a connection opened in between
+	// would be leaked.
+	if !t.hasBackend(be) {
+		_ = conn.Close()
+		return nil, errBackendNotInPool
+	}
+	if t.admin == nil {
+		t.admin = make(map[string]TCPConn)
+	}
+	t.admin[be.String()] = conn
+	return conn, nil
+}
+
+// AppendIsolated schedules the link on a connection to the given backend opened for it alone, and closed once the
+// link completes or ctx is done. It's meant for the requests whose response the pool can't frame, e.g. raw commands: whatever they
+// leave unread is discarded along with their connection instead of being decoded for the next request. Like the admin
+// connections, the isolated ones aren't part of Stats nor Topology.
+func (t *tcpConnPool) AppendIsolated(ctx context.Context, be *Backend, link codec.Link) error {
+	if !t.hasBackend(be) {
+		return fmt.Errorf("backend=%s: %w", be.String(), errBackendNotInPool)
+	}
+	conn, err := NewTCPConn(be, t.logger, t.connOpts...)
+	if err != nil {
+		return fmt.Errorf("backend=%s: failed to open isolated connection: %w", be.String(), err)
+	}
+
+	t.adminMu.Lock()
+	if t.adminClosed {
+		t.adminMu.Unlock()
+		_ = conn.Close()
+		conn.Wait()
+		return errConnPoolClosed
+	}
+	if t.isolated == nil {
+		t.isolated = make(map[TCPConn]struct{})
+	}
+	t.isolated[conn] = struct{}{}
+	t.isolatedConns.Add(1)
+	t.adminMu.Unlock()
+
+	go func() {
+		defer t.isolatedConns.Done()
+		select {
+		case <-link.Done():
+		case <-conn.Done():
+		case <-ctx.Done():
+		}
+		_ = conn.Close()
+		conn.Wait()
+
+		t.adminMu.Lock()
+		delete(t.isolated, conn)
+		t.adminMu.Unlock()
+	}()
+
+	if err := conn.Append(link); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("backend=%s: %w", be.String(), err)
+	}
+	return nil
+}
+
+// closeAdminConn closes the admin connection of the backend, if it was opened.
+func (t *tcpConnPool) closeAdminConn(addr string) error {
+	t.adminMu.Lock()
+	conn, ok := t.admin[addr]
+	delete(t.admin, addr)
+	t.adminMu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	t.mu.Lock()
+	t.retired = append(t.retired, conn)
+	t.mu.Unlock()
+	return conn.Close()
+}
+
+func (t *tcpConnPool) hasBackend(be *Backend) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	_, ok := t.cm[be.String()]
+	return ok
+}
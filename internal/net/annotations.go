@@ -0,0 +1,121 @@
+package net
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+)
+
+// ChecksumPolicy decides how the checksum annotation of the responses is handled in tolerant framing.
+type ChecksumPolicy int
+
+const (
+	// ChecksumIgnore strips the checksum annotation like any other.
+	ChecksumIgnore ChecksumPolicy = iota
+	// ChecksumVerify checks the data block of the responses carrying the checksum annotation against it.
+	ChecksumVerify
+	// ChecksumRequire checks the data block of every response against its checksum annotation, and fails the ones
+	// with a data block but without the annotation.
+	ChecksumRequire
+)
+
+// checksumAnnotation names the annotation carrying the CRC-32C of the data block of a response, in hexadecimal.
+var checksumAnnotation = []byte("crc32c")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrChecksumMismatch is the error of the responses whose data block doesn't match their checksum annotation. The
+// connection is re-established, as its stream can't be trusted anymore.
+var ErrChecksumMismatch = errors.New("response data block does not match its checksum")
+
+// WithTolerantFraming strips the annotations some proxies append to the response headers before they're decoded, so
+// that they aren't mistaken for flags or sizes. An annotation is a `name=value` token trailing the header, whose name
+// is made of at least two lowercase letters, digits, '.', '-' or '_', starting with a letter, which no memcached flag
+// looks like but the k flag of a key of that form, so such keys must not be fetched back in this mode. The `crc32c`
+// annotation carries the CRC-32C of the data block of the response, checked according to checksum.
+func WithTolerantFraming(checksum ChecksumPolicy) ConnOption {
+	return func(c *tcpConn) {
+		c.tolerantFraming = true
+		c.checksumPolicy = checksum
+	}
+}
+
+// stripAnnotations removes the annotations trailing the header of f and checks its data block against its checksum
+// annotation, if policy says so.
+func stripAnnotations(f *frame, policy ChecksumPolicy) error {
+	end := bytes.IndexByte(f.buf, '\n')
+	if end < 0 {
+		return nil
+	}
+	hdrEnd := end
+	if hdrEnd > 0 && f.buf[hdrEnd-1] == '\r' {
+		hdrEnd--
+	}
+
+	hdrLine := f.buf[:hdrEnd]
+	cut := len(hdrLine)
+	var checksum []byte
+	for {
+		start := bytes.LastIndexByte(hdrLine[:cut], ' ') + 1
+		if start == 0 {
+			// the first token is the status of the response.
+			break
+		}
+		name, value, ok := parseAnnotation(hdrLine[start:cut])
+		if !ok {
+			break
+		}
+		if checksum == nil && bytes.Equal(name, checksumAnnotation) {
+			checksum = value
+		}
+		cut = bytes.LastIndexFunc(hdrLine[:start], func(r rune) bool { return r != ' ' }) + 1
+	}
+
+	data := f.buf[end+1:]
+	if policy != ChecksumIgnore && len(data) >= 2 {
+		if err := verifyChecksum(hdrLine[:cut], data[:len(data)-2], checksum, policy); err != nil {
+			return err
+		}
+	}
+
+	if cut == len(hdrLine) {
+		return nil
+	}
+	n := copy(f.buf[cut:], f.buf[hdrEnd:])
+	f.buf = f.buf[:cut+n]
+	return nil
+}
+
+// verifyChecksum checks data, the data block of the response headed by hdrLine, against checksum.
+func verifyChecksum(hdrLine []byte, data []byte, checksum []byte, policy ChecksumPolicy) error {
+	if checksum == nil {
+		if policy == ChecksumRequire {
+			return fmt.Errorf("%w: no checksum annotation in response header %q", ErrChecksumMismatch, hdrLine)
+		}
+		return nil
+	}
+	want, err := strconv.ParseUint(string(checksum), 16, 32)
+	if err != nil {
+		return fmt.Errorf("%w: invalid checksum annotation %q in response header %q", ErrChecksumMismatch, checksum, hdrLine)
+	}
+	if got := crc32.Checksum(data, castagnoli); got != uint32(want) {
+		return fmt.Errorf("%w: response header %q announced %08x, got %08x", ErrChecksumMismatch, hdrLine, want, got)
+	}
+	return nil
+}
+
+// parseAnnotation splits token into the name and value of an annotation, if it's one.
+func parseAnnotation(token []byte) ([]byte, []byte, bool) {
+	name, value, ok := bytes.Cut(token, []byte{'='})
+	if !ok || len(name) < 2 || len(value) == 0 || value[0] == '=' || name[0] < 'a' || name[0] > 'z' {
+		return nil, nil, false
+	}
+	for _, b := range name[1:] {
+		if (b < 'a' || b > 'z') && (b < '0' || b > '9') && b != '.' && b != '-' && b != '_' {
+			return nil, nil, false
+		}
+	}
+	return name, value, true
+}
@@ -0,0 +1,73 @@
+package net
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestStripAnnotations(t *testing.T) {
+	sum := fmt.Sprintf("%08x", crc32.Checksum([]byte("abc"), castagnoli))
+	tests := []struct {
+		name    string
+		policy  ChecksumPolicy
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "no annotation", input: "HD O1 t-1\r\n", want: "HD O1 t-1\r\n"},
+		{name: "header annotations", input: "HD O1 via=proxy-3 zone=us-east-1a\r\n", want: "HD O1\r\n"},
+		{name: "numeric annotation", input: "HD O1 hop=12\r\n", want: "HD O1\r\n"},
+		{name: "status only", input: "EN trace=abc\r\n", want: "EN\r\n"},
+		{name: "value annotations", input: "VA 3 f1 via=proxy\r\nabc\r\n", want: "VA 3 f1\r\nabc\r\n"},
+		{name: "classic value", input: "VALUE key 0 3 via=proxy\r\nabc\r\n", want: "VALUE key 0 3\r\nabc\r\n"},
+		{name: "annotation amid flags", input: "HD via=proxy O1\r\n", want: "HD via=proxy O1\r\n"},
+		{name: "key flag", input: "HD kab=c b\r\n", want: "HD kab=c b\r\n"},
+		{name: "base64 key flag", input: "HD kYWJj= b\r\n", want: "HD kYWJj= b\r\n"},
+		{name: "ignored checksum", input: "VA 3 crc32c=00000000\r\nabc\r\n", want: "VA 3\r\nabc\r\n"},
+		{name: "checksum", policy: ChecksumVerify, input: "VA 3 f1 crc32c=" + sum + " via=proxy\r\nabc\r\n", want: "VA 3 f1\r\nabc\r\n"},
+		{name: "checksum mismatch", policy: ChecksumVerify, input: "VA 3 crc32c=00000000\r\nabc\r\n", wantErr: true},
+		{name: "invalid checksum", policy: ChecksumVerify, input: "VA 3 crc32c=xyz\r\nabc\r\n", wantErr: true},
+		{name: "optional checksum", policy: ChecksumVerify, input: "VA 3\r\nabc\r\n", want: "VA 3\r\nabc\r\n"},
+		{name: "required checksum", policy: ChecksumRequire, input: "VA 3 via=proxy\r\nabc\r\n", wantErr: true},
+		{name: "no data block", policy: ChecksumRequire, input: "HD O1\r\n", want: "HD O1\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := &frame{buf: []byte(test.input)}
+			err := stripAnnotations(f, test.policy)
+			if test.wantErr {
+				assert.ErrorIs(t, err, ErrChecksumMismatch)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, string(f.buf))
+		})
+	}
+}
+
+func TestReadFramesTolerant(t *testing.T) {
+	conn := &tcpConn{
+		be:     &Backend{},
+		rw:     bufio.NewReadWriter(bufio.NewReader(strings.NewReader("HD O1 via=proxy\r\nVA 3 crc32c=00000000\r\nabc\r\n")), nil),
+		logger: zap.NewNop(),
+	}
+	WithTolerantFraming(ChecksumVerify)(conn)
+
+	// the frame failing its checksum stops the reading routine, the ones before it are decoded.
+	frames := newFrameReader(2)
+	conn.readFrames(context.Background(), frames)
+	assert.ErrorIs(t, frames.err, ErrChecksumMismatch)
+	f := <-frames.frames
+	assert.Equal(t, "HD O1\r\n", string(f.buf))
+	_, ok := <-frames.frames
+	assert.False(t, ok)
+	assert.Equal(t, uint64(1), conn.Stats().ChecksumFailures)
+}
@@ -3,22 +3,44 @@ package net
 import (
 	"crypto/tls"
 	"net"
+	"sync/atomic"
+
+	"github.com/stripe/memlink/codec/memcache"
 )
 
 type Backend struct {
 	addr      net.Addr
 	numConns  int
+	weight    int
 	tlsConfig *tls.Config
+
+	capabilities atomic.Pointer[Capabilities]
+	// version is the memcached version parsed from the capabilities, nil if they don't report one.
+	version atomic.Pointer[memcache.ServerVersion]
+	// compression is the stream compression accepted by the backend, compressionRefused whether it refused one.
+	compression        atomic.Pointer[Compression]
+	compressionRefused atomic.Bool
 }
 
 func NewBackend(addr net.Addr, numConns int, tlsConfig *tls.Config) *Backend {
+	return NewWeightedBackend(addr, numConns, 1, tlsConfig)
+}
+
+// NewWeightedBackend creates a backend given weight times as many keys as a backend of weight 1, see WeightedSlots.
+func NewWeightedBackend(addr net.Addr, numConns int, weight int, tlsConfig *tls.Config) *Backend {
 	return &Backend{
 		addr:      addr,
 		numConns:  numConns,
+		weight:    weight,
 		tlsConfig: tlsConfig,
 	}
 }
 
+// Weight returns the weight of the backend, at least 1.
+func (b *Backend) Weight() int {
+	return max(b.weight, 1)
+}
+
 func (b *Backend) String() string {
 
 	if b == nil {
@@ -27,3 +49,49 @@ func (b *Backend) String() string {
 
 	return b.addr.String()
 }
+
+// Capabilities are the features supported by a backend, as detected when connecting to it.
+type Capabilities struct {
+	Version      string
+	MetaProtocol bool
+	Base64Keys   bool
+	MaxItemSize  int // in bytes, 0 if unknown.
+}
+
+// Capabilities returns the capabilities detected for the backend, false if they haven't been detected.
+func (b *Backend) Capabilities() (Capabilities, bool) {
+	caps := b.capabilities.Load()
+	if caps == nil {
+		return Capabilities{}, false
+	}
+	return *caps, true
+}
+
+// SetCapabilities records the capabilities detected for the backend.
+func (b *Backend) SetCapabilities(caps Capabilities) {
+	if version, ok := memcache.ParseServerVersion(caps.Version); ok {
+		b.version.Store(&version)
+	} else {
+		b.version.Store(nil)
+	}
+	b.capabilities.Store(&caps)
+}
+
+// ServerVersion returns the memcached version of the backend, false if its capabilities weren't detected or don't
+// report one, e.g. for a proxy.
+func (b *Backend) ServerVersion() (memcache.ServerVersion, bool) {
+	version := b.version.Load()
+	if version == nil {
+		return memcache.ServerVersion{}, false
+	}
+	return *version, true
+}
+
+// Compression returns the stream compression negotiated with the backend, CompressionNone if none was.
+func (b *Backend) Compression() Compression {
+	compression := b.compression.Load()
+	if compression == nil {
+		return CompressionNone
+	}
+	return *compression
+}
@@ -0,0 +1,152 @@
+package net
+
+import (
+	"sync/atomic"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// CanaryStats counts the requests routed by a CanaryPool, and the ones the candidate HasherFn would have placed on
+// another backend.
+type CanaryStats struct {
+	// Routed is the number of requests with a hash key accepted by a backend.
+	Routed uint64
+	// Diverged is the number of routed requests the candidate places on another backend.
+	Diverged uint64
+	// Reads is the number of routed requests which are reads.
+	Reads uint64
+	// DivergedReads is the number of reads the candidate places on another backend, which would miss right after
+	// switching to it.
+	DivergedReads uint64
+}
+
+// DivergenceRatio returns the fraction of the routed requests the candidate places on another backend.
+func (s CanaryStats) DivergenceRatio() float64 {
+	if s.Routed == 0 {
+		return 0
+	}
+	return float64(s.Diverged) / float64(s.Routed)
+}
+
+// EstimatedMissRatio returns the fraction of the reads the candidate places on another backend, i.e. the expected
+// miss ratio right after switching to it, before the new backends are filled.
+func (s CanaryStats) EstimatedMissRatio() float64 {
+	if s.Reads == 0 {
+		return 0
+	}
+	return float64(s.DivergedReads) / float64(s.Reads)
+}
+
+// CanaryPool routes the requests like the TCPConnPool it wraps, and compares the backend every request was sent to
+// with the one a candidate HasherFn picks for its hash key, so a new routing strategy can be evaluated on live traffic
+// without changing where requests go. Requests without a hash key, or routed to a given backend or broadcast, aren't
+// compared since the HasherFn doesn't place them. Requests carrying several keys are placed by their hash key only.
+type CanaryPool struct {
+	TCPConnPool
+
+	candidate HasherFn
+	isRead    func(codec.LinkEncoder) bool
+	// placement is the one of the backends of the pool, refreshed by Add and Remove.
+	placement atomic.Pointer[canaryPlacement]
+
+	routed        atomic.Uint64
+	diverged      atomic.Uint64
+	reads         atomic.Uint64
+	divergedReads atomic.Uint64
+}
+
+var _ TCPConnPool = (*CanaryPool)(nil)
+
+// NewCanaryPool wraps pool, comparing its placements with the ones of candidate. isRead reports whether a request is
+// a read, whose divergences are counted apart to estimate the misses the candidate would cause.
+func NewCanaryPool(pool TCPConnPool, candidate HasherFn, isRead func(codec.LinkEncoder) bool) *CanaryPool {
+	p := &CanaryPool{
+		TCPConnPool: pool,
+		candidate:   candidate,
+		isRead:      isRead,
+	}
+	p.refreshAddrs()
+	return p
+}
+
+// canaryPlacement holds the addresses of the backends of the pool in placement order, and their WeightedSlots.
+type canaryPlacement struct {
+	addrs []string
+	slots []int
+}
+
+func (p *CanaryPool) refreshAddrs() {
+	backends := p.TCPConnPool.Backends()
+	addrs := make([]string, 0, len(backends))
+	for _, be := range backends {
+		addrs = append(addrs, be.String())
+	}
+	p.placement.Store(&canaryPlacement{addrs: addrs, slots: BackendSlots(backends)})
+}
+
+func (p *CanaryPool) Add(be *Backend) error {
+	defer p.refreshAddrs()
+	return p.TCPConnPool.Add(be)
+}
+
+func (p *CanaryPool) Remove(be *Backend) error {
+	defer p.refreshAddrs()
+	return p.TCPConnPool.Remove(be)
+}
+
+// Append schedules the link on the wrapped pool, then compares the backend which accepted it with the candidate's.
+func (p *CanaryPool) Append(link codec.Link) error {
+	if err := p.TCPConnPool.Append(link); err != nil {
+		return err
+	}
+
+	var hint codec.RoutingHint
+	if routed, ok := link.(codec.RoutedLink); ok {
+		hint = routed.RoutingHint()
+	}
+	if hint.Broadcast != nil || hint.Backend != "" {
+		return nil
+	}
+	recorder, ok := link.(codec.BackendRecorder)
+	if !ok || recorder.Backend() == "" {
+		return nil
+	}
+	hashKey := hint.HashKey
+	if hashKey == "" {
+		if keyer, ok := link.Encoder().(codec.RoutingKeyer); ok {
+			hashKey = keyer.RoutingKey()
+		}
+	}
+	if hashKey == "" {
+		return nil
+	}
+
+	placement := p.placement.Load()
+	if len(placement.addrs) == 0 {
+		return nil
+	}
+	idx, err := PlaceKey(p.candidate, hashKey, len(placement.addrs), placement.slots)
+	diverged := err != nil || placement.addrs[idx] != recorder.Backend()
+
+	p.routed.Add(1)
+	if diverged {
+		p.diverged.Add(1)
+	}
+	if p.isRead != nil && p.isRead(link.Encoder()) {
+		p.reads.Add(1)
+		if diverged {
+			p.divergedReads.Add(1)
+		}
+	}
+	return nil
+}
+
+// CanaryStats returns the counters of the requests compared so far.
+func (p *CanaryPool) CanaryStats() CanaryStats {
+	return CanaryStats{
+		Routed:        p.routed.Load(),
+		Diverged:      p.diverged.Load(),
+		Reads:         p.reads.Load(),
+		DivergedReads: p.divergedReads.Load(),
+	}
+}
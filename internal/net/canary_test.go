@@ -0,0 +1,84 @@
+package net
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// fixedPool is a TCPConnPool sending every request to the backend at index next, recording it on the link like a
+// connection does.
+type fixedPool struct {
+	TCPConnPool
+	backends []*Backend
+	next     int
+}
+
+func (p *fixedPool) Backends() []*Backend {
+	return p.backends
+}
+
+func (p *fixedPool) Add(be *Backend) error {
+	p.backends = append(p.backends, be)
+	return nil
+}
+
+func (p *fixedPool) Append(link codec.Link) error {
+	link.(codec.BackendRecorder).SetBackend(p.backends[p.next].String())
+	return nil
+}
+
+func TestCanaryPool(t *testing.T) {
+	var backends []*Backend
+	for _, port := range []int{11211, 11212} {
+		backends = append(backends, NewBackend(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}, 1, nil))
+	}
+	inner := &fixedPool{backends: backends}
+	// the candidate places "b" on the second backend, every other key on the first one.
+	candidate := func(hashKey string, n int) int {
+		if hashKey == "b" {
+			return n - 1
+		}
+		return 0
+	}
+	isRead := func(e codec.LinkEncoder) bool {
+		return e.(*keyedEchoEncoder).name == "read"
+	}
+	pool := NewCanaryPool(inner, candidate, isRead)
+
+	send := func(name, key string, hint codec.RoutingHint) {
+		link := codec.NewRoutedLink(&keyedEchoEncoder{echoEncoder: echoEncoder{name: name}, key: key}, &echoDecoder{}, hint)
+		require.NoError(t, pool.Append(link))
+	}
+
+	send("read", "a", codec.RoutingHint{})
+	send("read", "b", codec.RoutingHint{})
+	send("write", "b", codec.RoutingHint{})
+	// the hash key of the hint places the request.
+	send("read", "a", codec.RouteByHashKey("b"))
+	// requests without a key, or routed to a backend, aren't placed by the HasherFn.
+	send("read", "", codec.RoutingHint{})
+	send("read", "b", codec.RouteToBackend(backends[0].String()))
+
+	stats := pool.CanaryStats()
+	assert.Equal(t, CanaryStats{Routed: 4, Diverged: 3, Reads: 3, DivergedReads: 2}, stats)
+	assert.InDelta(t, 0.75, stats.DivergenceRatio(), 1e-9)
+	assert.InDelta(t, 2.0/3, stats.EstimatedMissRatio(), 1e-9)
+
+	// the candidate places keys among the backends added since.
+	require.NoError(t, pool.Add(NewBackend(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 11213}, 1, nil)))
+	inner.next = 2
+	send("write", "b", codec.RoutingHint{})
+	stats = pool.CanaryStats()
+	assert.Equal(t, uint64(5), stats.Routed)
+	assert.Equal(t, uint64(3), stats.Diverged)
+}
+
+func TestCanaryStatsEmpty(t *testing.T) {
+	assert.Zero(t, CanaryStats{}.DivergenceRatio())
+	assert.Zero(t, CanaryStats{}.EstimatedMissRatio())
+}
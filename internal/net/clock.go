@@ -0,0 +1,71 @@
+package net
+
+import (
+	"time"
+
+	"github.com/andrew-d/csmrand"
+)
+
+// Clock is the source of time of a connection: the timestamps of its requests, its timers and the delays between its
+// connection attempts. Socket deadlines follow the wall clock whatever the Clock, the kernel enforces them.
+type Clock interface {
+	Now() time.Time
+	// NewTimer creates a timer sending the time on its channel once d elapsed.
+	NewTimer(d time.Duration) Timer
+	// AfterFunc calls f once d elapsed, and returns a timer which cancels the call when stopped.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a timer created by a Clock.
+type Timer interface {
+	C() <-chan time.Time
+	// Stop prevents the timer from firing, and reports whether it did, false if it already fired or was stopped.
+	Stop() bool
+}
+
+// Rand is the source of randomness of the pools and connections, e.g. to pick a backend or to spread the
+// reconnection attempts. *math/rand.Rand implements it, so tests can use a seeded one.
+type Rand interface {
+	Intn(n int) int
+	Int63n(n int64) int64
+}
+
+// SystemClock is the Clock of the time package.
+var SystemClock Clock = systemClock{}
+
+// SystemRand is a Rand seeded from crypto/rand.
+var SystemRand Rand = csmrand.Rand{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{timer: time.NewTimer(d)}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{timer: time.AfterFunc(d, f)}
+}
+
+type systemTimer struct {
+	timer *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t systemTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+// jitter lengthens d by up to a quarter at random, so that the connections lost at once don't retry in lockstep.
+func jitter(rng Rand, d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	return d + time.Duration(rng.Int63n(int64(d)/4+1))
+}
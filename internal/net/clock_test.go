@@ -0,0 +1,69 @@
+package net
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSimClockTimers(t *testing.T) {
+	clock := NewSimClock(time.Unix(0, 0))
+	var fired []string
+	clock.AfterFunc(20*time.Millisecond, func() { fired = append(fired, "second") })
+	clock.AfterFunc(10*time.Millisecond, func() { fired = append(fired, "first") })
+	stopped := clock.AfterFunc(15*time.Millisecond, func() { fired = append(fired, "stopped") })
+	timer := clock.NewTimer(30 * time.Millisecond)
+	assert.Equal(t, 4, clock.Timers())
+
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+	clock.Advance(25 * time.Millisecond)
+	assert.Equal(t, []string{"first", "second"}, fired)
+	assert.Equal(t, 1, clock.Timers())
+
+	select {
+	case <-timer.C():
+		t.Fatal("the timer fired early")
+	default:
+	}
+	clock.Advance(5 * time.Millisecond)
+	require.Len(t, timer.C(), 1)
+	assert.Equal(t, time.Unix(0, 0).Add(30*time.Millisecond), <-timer.C())
+	assert.False(t, timer.Stop())
+
+	// timers without delay fire right away.
+	immediate := clock.NewTimer(0)
+	assert.Len(t, immediate.C(), 1)
+}
+
+func TestJitter(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		d := jitter(rng, 8*time.Millisecond)
+		assert.GreaterOrEqual(t, d, 8*time.Millisecond)
+		assert.LessOrEqual(t, d, 10*time.Millisecond)
+	}
+	assert.Equal(t, time.Duration(0), jitter(rng, 0))
+}
+
+func TestNewRandomHashFn(t *testing.T) {
+	pick := func(seed int64) []int {
+		hashFn := NewRandomHashFn(rand.New(rand.NewSource(seed)))
+		picks := make([]int, 20)
+		for i := range picks {
+			picks[i] = hashFn("key", 4)
+			require.Less(t, picks[i], 4)
+		}
+		return picks
+	}
+	assert.Equal(t, pick(42), pick(42))
+
+	pool, err := NewConnPool(nil, WithConnPoolRand(rand.New(rand.NewSource(42))), WithConnPoolLogger(zap.NewNop()))
+	require.NoError(t, err)
+	defer pool.Close()
+	assert.Equal(t, pick(42)[0], pool.(*tcpConnPool).hashFn("key", 4))
+}
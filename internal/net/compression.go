@@ -0,0 +1,280 @@
+package net
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is an algorithm compressing the whole stream of a connection, supported by some memcached proxies to
+// reduce the bandwidth of cross datacenter traffic.
+type Compression string
+
+const (
+	// CompressionNone leaves the stream uncompressed.
+	CompressionNone Compression = ""
+	// CompressionSnappy frames the stream in the snappy framing format.
+	CompressionSnappy Compression = "snappy"
+	// CompressionZstd compresses the stream as a zstd frame, flushed after every request.
+	CompressionZstd Compression = "zstd"
+)
+
+// compressionChunkSize is the size of the buffers the decompressed stream is read into.
+const compressionChunkSize = 16 * 1024
+
+// WithCompression offers the backend to compress the stream of the connection with compression. The offer is made
+// with a `compress <algorithm>` command right after connecting, the stream being compressed once the backend answers
+// OK. Backends refusing it, e.g. a plain memcached answering ERROR, are remembered and served uncompressed.
+func WithCompression(compression Compression) ConnOption {
+	return func(c *tcpConn) {
+		c.compression = compression
+	}
+}
+
+// negotiateCompression offers the compression of the connection to the backend, and returns the connection wrapped
+// accordingly.
+func (c *tcpConn) negotiateCompression(conn net.Conn) (net.Conn, error) {
+	if c.compression == CompressionNone || c.be.compressionRefused.Load() {
+		return conn, nil
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(dialTimeout)); err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(conn, "compress %s\r\n", c.compression); err != nil {
+		return nil, err
+	}
+	// the backend doesn't write anything past its answer before the stream is compressed, so reading the answer
+	// through a buffer doesn't swallow compressed bytes.
+	answer, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(answer) != "OK" {
+		c.logger.Warn("backend refused the stream compression, falling back to an uncompressed stream",
+			c.logFields...)
+		c.be.compressionRefused.Store(true)
+		return conn, nil
+	}
+
+	c.be.compression.Store(&c.compression)
+	return NewCompressedConn(conn, c.compression)
+}
+
+// compressedConn compresses what's written to a connection and decompresses what's read from it. The decompressor
+// can't recover from a read interrupted mid-stream, so the socket is read by a routine of its own, without deadline,
+// and the read deadlines apply to the wait for its output instead.
+type compressedConn struct {
+	net.Conn
+	writer compressingWriter
+
+	chunks chan compressedChunk
+	closed chan struct{}
+	once   sync.Once
+	// stopped is closed once the routine decompressing the stream exited.
+	stopped chan struct{}
+	// pending is the rest of the last chunk, not read yet.
+	pending []byte
+
+	readDeadline readDeadline
+}
+
+type compressingWriter interface {
+	io.Writer
+	Flush() error
+}
+
+type compressedChunk struct {
+	data []byte
+	err  error
+}
+
+// NewCompressedConn wraps conn, whose whole stream is compressed with compression on both ends. It's exported for
+// the servers of the tests.
+func NewCompressedConn(conn net.Conn, compression Compression) (net.Conn, error) {
+	var writer compressingWriter
+	var reader io.Reader
+	switch compression {
+	case CompressionNone:
+		return conn, nil
+	case CompressionSnappy:
+		writer = s2.NewWriter(conn, s2.WriterSnappyCompat(), s2.WriterConcurrency(1))
+		reader = s2.NewReader(conn)
+	case CompressionZstd:
+		encoder, err := zstd.NewWriter(conn, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		decoder, err := zstd.NewReader(conn, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		writer, reader = encoder, decoder
+	default:
+		return nil, fmt.Errorf("unknown stream compression %q", compression)
+	}
+
+	cc := &compressedConn{
+		Conn:    conn,
+		writer:  writer,
+		chunks:  make(chan compressedChunk, 1),
+		closed:  make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	cc.readDeadline.init()
+	go cc.decompress(reader)
+	return cc, nil
+}
+
+func (cc *compressedConn) decompress(reader io.Reader) {
+	defer close(cc.stopped)
+	if closer, ok := reader.(interface{ Close() }); ok {
+		defer closer.Close()
+	}
+
+	for {
+		buf := make([]byte, compressionChunkSize)
+		n, err := reader.Read(buf)
+		if n > 0 {
+			select {
+			case cc.chunks <- compressedChunk{data: buf[:n]}:
+			case <-cc.closed:
+				return
+			}
+		}
+		if err != nil {
+			select {
+			case cc.chunks <- compressedChunk{err: err}:
+			case <-cc.closed:
+			}
+			return
+		}
+	}
+}
+
+func (cc *compressedConn) Read(p []byte) (int, error) {
+	if len(cc.pending) == 0 {
+		select {
+		case chunk := <-cc.chunks:
+			if chunk.err != nil {
+				// the routine stopped, the error is returned to every later read.
+				cc.chunks <- chunk
+				return 0, chunk.err
+			}
+			cc.pending = chunk.data
+		case <-cc.readDeadline.expired():
+			return 0, os.ErrDeadlineExceeded
+		case <-cc.closed:
+			return 0, net.ErrClosed
+		}
+	}
+
+	n := copy(p, cc.pending)
+	cc.pending = cc.pending[n:]
+	return n, nil
+}
+
+// Write compresses p and flushes it, the bufio.Writer of the connection only writing whole requests.
+func (cc *compressedConn) Write(p []byte) (int, error) {
+	n, err := cc.writer.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, cc.writer.Flush()
+}
+
+// awaitConnRoutines waits for the routines of conn, closed or being closed, to exit.
+func awaitConnRoutines(conn net.Conn) {
+	if cc, ok := conn.(*compressedConn); ok {
+		<-cc.stopped
+	}
+}
+
+func (cc *compressedConn) Close() error {
+	cc.once.Do(func() {
+		close(cc.closed)
+	})
+	return cc.Conn.Close()
+}
+
+func (cc *compressedConn) SetDeadline(t time.Time) error {
+	cc.readDeadline.set(t)
+	return cc.Conn.SetWriteDeadline(t)
+}
+
+func (cc *compressedConn) SetReadDeadline(t time.Time) error {
+	cc.readDeadline.set(t)
+	return nil
+}
+
+// readDeadline is a deadline which can be changed while a read waits for it, like the ones of net.Pipe.
+type readDeadline struct {
+	mu     sync.Mutex
+	timer  *time.Timer   // protected by mu
+	cancel chan struct{} // protected by mu, closed once the deadline is exceeded
+}
+
+func (d *readDeadline) init() {
+	d.cancel = make(chan struct{})
+}
+
+func (d *readDeadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil && !d.timer.Stop() {
+		// the timer fired, wait for it to close cancel before replacing it.
+		<-d.cancel
+	}
+	d.timer = nil
+
+	exceeded := isClosedChan(d.cancel)
+	if t.IsZero() {
+		if exceeded {
+			d.cancel = make(chan struct{})
+		}
+		return
+	}
+
+	if dur := time.Until(t); dur > 0 {
+		if exceeded {
+			d.cancel = make(chan struct{})
+		}
+		cancel := d.cancel
+		d.timer = time.AfterFunc(dur, func() {
+			close(cancel)
+		})
+		return
+	}
+
+	if !exceeded {
+		close(d.cancel)
+	}
+}
+
+func (d *readDeadline) expired() chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cancel
+}
+
+func isClosedChan(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
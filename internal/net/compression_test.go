@@ -0,0 +1,74 @@
+package net
+
+import (
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+func TestCompressedConn(t *testing.T) {
+	for _, compression := range []Compression{CompressionSnappy, CompressionZstd} {
+		t.Run(string(compression), func(t *testing.T) {
+			defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+			client, server := net.Pipe()
+			cc, err := NewCompressedConn(client, compression)
+			require.NoError(t, err)
+			sc, err := NewCompressedConn(server, compression)
+			require.NoError(t, err)
+			defer cc.Close() //nolint: errcheck
+			defer sc.Close() //nolint: errcheck
+
+			go func() {
+				_, _ = cc.Write([]byte("mg key v\r\n"))
+			}()
+			request := make([]byte, len("mg key v\r\n"))
+			_, err = io.ReadFull(sc, request)
+			require.NoError(t, err)
+			assert.Equal(t, "mg key v\r\n", string(request))
+
+			// a read interrupted by its deadline doesn't corrupt the stream.
+			require.NoError(t, cc.SetReadDeadline(time.Now()))
+			_, err = cc.Read(make([]byte, 1))
+			assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+			require.NoError(t, cc.SetReadDeadline(time.Time{}))
+
+			go func() {
+				_, _ = sc.Write([]byte("VA 5\r\nvalue\r\n"))
+			}()
+			response := make([]byte, len("VA 5\r\nvalue\r\n"))
+			_, err = io.ReadFull(cc, response)
+			require.NoError(t, err)
+			assert.Equal(t, "VA 5\r\nvalue\r\n", string(response))
+		})
+	}
+}
+
+func TestReadDeadlineChangedWhileWaiting(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	client, server := net.Pipe()
+	defer server.Close() //nolint: errcheck
+	cc, err := NewCompressedConn(client, CompressionSnappy)
+	require.NoError(t, err)
+	defer cc.Close() //nolint: errcheck
+
+	require.NoError(t, cc.SetReadDeadline(time.Now().Add(time.Hour)))
+	done := make(chan error)
+	go func() {
+		_, err := cc.Read(make([]byte, 1))
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, cc.SetReadDeadline(time.Now()))
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("the read wasn't interrupted by the new deadline")
+	}
+}
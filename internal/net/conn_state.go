@@ -0,0 +1,69 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// errIllegalTransition is returned when a connection is asked to move to a state it can't reach from its current one,
+// e.g. to reconnect once it's closed.
+var errIllegalTransition = errors.New("illegal connection state transition")
+
+// connTransitions lists the states each state can move to. Terminated is final: a closed connection is never
+// re-established, even by a reconnection attempt racing with Close.
+var connTransitions = map[connState][]connState{
+	Unavailable:   {Connected, ConnectFailed, Terminated},
+	Connected:     {Reconnecting, Terminated},
+	Reconnecting:  {Connected, ConnectFailed, Terminated},
+	ConnectFailed: {Connected, Reconnecting, Terminated},
+	Terminated:    {},
+}
+
+// connStateListener is notified of every transition of a connStateMachine.
+type connStateListener func(from, to connState)
+
+// connStateMachine holds the state of a connection and rejects the transitions not listed in connTransitions. It isn't
+// safe for concurrent use: its owner protects it with the lock guarding the resources the state describes, so that
+// both change together.
+type connStateMachine struct {
+	state     connState
+	listeners []connStateListener
+}
+
+func newConnStateMachine(initial connState, listeners ...connStateListener) connStateMachine {
+	return connStateMachine{state: initial, listeners: listeners}
+}
+
+// current returns the state of the machine, Unavailable until the first transition of a zero machine.
+func (m *connStateMachine) current() connState {
+	if m.state == "" {
+		return Unavailable
+	}
+	return m.state
+}
+
+func (m *connStateMachine) is(state connState) bool {
+	return m.current() == state
+}
+
+// transition moves the machine to state and notifies the listeners. Moving to the current state is a no-op.
+func (m *connStateMachine) transition(to connState) error {
+	from := m.current()
+	if from == to {
+		return nil
+	}
+	if !canTransition(from, to) {
+		return fmt.Errorf("%s -> %s: %w", from, to, errIllegalTransition)
+	}
+
+	m.state = to
+	for _, listener := range m.listeners {
+		listener(from, to)
+	}
+	return nil
+}
+
+func canTransition(from, to connState) bool {
+	return slices.Contains(connTransitions[from], to)
+}
@@ -0,0 +1,62 @@
+package net
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnStateMachineTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    connState
+		to      connState
+		wantErr bool
+	}{
+		{name: "connect", from: Unavailable, to: Connected},
+		{name: "initial connection failure", from: Unavailable, to: ConnectFailed},
+		{name: "connection lost", from: Connected, to: Reconnecting},
+		{name: "reconnect", from: Reconnecting, to: Connected},
+		{name: "reconnection failure", from: Reconnecting, to: ConnectFailed},
+		{name: "retry after failure", from: ConnectFailed, to: Reconnecting},
+		{name: "close while connected", from: Connected, to: Terminated},
+		{name: "close while reconnecting", from: Reconnecting, to: Terminated},
+		{name: "same state", from: Connected, to: Connected},
+		{name: "close twice", from: Terminated, to: Terminated},
+		{name: "reconnect once closed", from: Terminated, to: Reconnecting, wantErr: true},
+		{name: "connect once closed", from: Terminated, to: Connected, wantErr: true},
+		{name: "connection failure once closed", from: Terminated, to: ConnectFailed, wantErr: true},
+		{name: "connected to failed", from: Connected, to: ConnectFailed, wantErr: true},
+		{name: "back to unavailable", from: Connected, to: Unavailable, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var events [][2]connState
+			m := newConnStateMachine(test.from, func(from, to connState) {
+				events = append(events, [2]connState{from, to})
+			})
+
+			err := m.transition(test.to)
+			if test.wantErr {
+				assert.ErrorIs(t, err, errIllegalTransition)
+				assert.Equal(t, test.from, m.current())
+				assert.Empty(t, events)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.to, m.current())
+			if test.from == test.to {
+				assert.Empty(t, events)
+			} else {
+				assert.Equal(t, [][2]connState{{test.from, test.to}}, events)
+			}
+		})
+	}
+}
+
+func TestConnStateMachineZeroValue(t *testing.T) {
+	var m connStateMachine
+	assert.True(t, m.is(Unavailable))
+	assert.NoError(t, m.transition(Connected))
+	assert.True(t, m.is(Connected))
+}
@@ -0,0 +1,122 @@
+package net
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// ConnStats are cumulative counters describing how loaded a connection, or a set of connections, is.
+type ConnStats struct {
+	// Appends is the number of links accepted.
+	Appends uint64
+	// BusyAppends is the number of links which were queued behind other in flight links.
+	BusyAppends uint64
+	// Rejected is the number of links refused because the outbound queue was full.
+	Rejected uint64
+	// QueueWait is the total time links spent in the outbound queue before being written to the connection.
+	QueueWait time.Duration
+	// InboundOverflows is the number of links which found the inbound queue full once their request was written.
+	InboundOverflows uint64
+	// UnclaimedResponses is the number of responses dropped because they didn't belong to any pending link.
+	UnclaimedResponses uint64
+	// RetriedZombieLinks is the number of links appended to another connection after theirs was lost.
+	RetriedZombieLinks uint64
+	// InFlightLimitWaits is the number of links whose request waited for responses to drain, because the connection
+	// had as many requests in flight as allowed.
+	InFlightLimitWaits uint64
+	// ThrottleWait is the total time requests waited to be written because of the bandwidth limit.
+	ThrottleWait time.Duration
+	// ResponseTimeouts is the number of links failed, and connections re-established, because no response arrived
+	// within the response timeout.
+	ResponseTimeouts uint64
+	// BatchedDecodes is the number of responses decoded right after the previous one, because they were already read
+	// off the socket and their link was queued.
+	BatchedDecodes uint64
+	// ChecksumFailures is the number of responses failing their checksum annotation, each of which re-established the
+	// connection.
+	ChecksumFailures uint64
+	// OversizedResponses is the number of responses whose data block exceeded the maximum response size, each of which
+	// re-established the connection.
+	OversizedResponses uint64
+}
+
+func (s ConnStats) add(o ConnStats) ConnStats {
+	return ConnStats{
+		Appends:            s.Appends + o.Appends,
+		BusyAppends:        s.BusyAppends + o.BusyAppends,
+		Rejected:           s.Rejected + o.Rejected,
+		QueueWait:          s.QueueWait + o.QueueWait,
+		InboundOverflows:   s.InboundOverflows + o.InboundOverflows,
+		UnclaimedResponses: s.UnclaimedResponses + o.UnclaimedResponses,
+		RetriedZombieLinks: s.RetriedZombieLinks + o.RetriedZombieLinks,
+		InFlightLimitWaits: s.InFlightLimitWaits + o.InFlightLimitWaits,
+		ThrottleWait:       s.ThrottleWait + o.ThrottleWait,
+		ResponseTimeouts:   s.ResponseTimeouts + o.ResponseTimeouts,
+		BatchedDecodes:     s.BatchedDecodes + o.BatchedDecodes,
+		ChecksumFailures:   s.ChecksumFailures + o.ChecksumFailures,
+		OversizedResponses: s.OversizedResponses + o.OversizedResponses,
+	}
+}
+
+func (s ConnStats) sub(o ConnStats) ConnStats {
+	return ConnStats{
+		Appends:            s.Appends - o.Appends,
+		BusyAppends:        s.BusyAppends - o.BusyAppends,
+		Rejected:           s.Rejected - o.Rejected,
+		QueueWait:          s.QueueWait - o.QueueWait,
+		InboundOverflows:   s.InboundOverflows - o.InboundOverflows,
+		UnclaimedResponses: s.UnclaimedResponses - o.UnclaimedResponses,
+		RetriedZombieLinks: s.RetriedZombieLinks - o.RetriedZombieLinks,
+		InFlightLimitWaits: s.InFlightLimitWaits - o.InFlightLimitWaits,
+		ThrottleWait:       s.ThrottleWait - o.ThrottleWait,
+		ResponseTimeouts:   s.ResponseTimeouts - o.ResponseTimeouts,
+		BatchedDecodes:     s.BatchedDecodes - o.BatchedDecodes,
+		ChecksumFailures:   s.ChecksumFailures - o.ChecksumFailures,
+		OversizedResponses: s.OversizedResponses - o.OversizedResponses,
+	}
+}
+
+type connStats struct {
+	appends            atomic.Uint64
+	busyAppends        atomic.Uint64
+	rejected           atomic.Uint64
+	queueWaitNanos     atomic.Int64
+	inboundOverflows   atomic.Uint64
+	unclaimedResponses atomic.Uint64
+	retriedZombieLinks atomic.Uint64
+	inFlightLimitWaits atomic.Uint64
+	throttleWaitNanos  atomic.Int64
+	responseTimeouts   atomic.Uint64
+	batchedDecodes     atomic.Uint64
+	checksumFailures   atomic.Uint64
+	oversizedResponses atomic.Uint64
+}
+
+func (s *connStats) snapshot() ConnStats {
+	return ConnStats{
+		Appends:            s.appends.Load(),
+		BusyAppends:        s.busyAppends.Load(),
+		Rejected:           s.rejected.Load(),
+		QueueWait:          time.Duration(s.queueWaitNanos.Load()),
+		InboundOverflows:   s.inboundOverflows.Load(),
+		UnclaimedResponses: s.unclaimedResponses.Load(),
+		RetriedZombieLinks: s.retriedZombieLinks.Load(),
+		InFlightLimitWaits: s.inFlightLimitWaits.Load(),
+		ThrottleWait:       time.Duration(s.throttleWaitNanos.Load()),
+		ResponseTimeouts:   s.responseTimeouts.Load(),
+		BatchedDecodes:     s.batchedDecodes.Load(),
+		ChecksumFailures:   s.checksumFailures.Load(),
+		OversizedResponses: s.oversizedResponses.Load(),
+	}
+}
+
+// queuedLink remembers when a link was appended to a connection, to measure how long it waited in the outbound
+// queue, and when it was written.
+type queuedLink struct {
+	codec.Link
+	enqueuedAt time.Time
+	// writtenAt is when its request was flushed, only set for the response timeout watchdog.
+	writtenAt time.Time
+}
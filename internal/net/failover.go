@@ -0,0 +1,445 @@
+package net
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// maxFailoverWrittenKeys is the largest number of keys written to the standby cluster which are tracked to be
+// invalidated on the primary one, which is flushed instead past it.
+const maxFailoverWrittenKeys = 1 << 16
+
+// FailoverCluster is a cluster of a FailoverPool.
+type FailoverCluster int
+
+const (
+	// FailoverPrimary is the cluster the requests are sent to while it's healthy.
+	FailoverPrimary FailoverCluster = iota
+	// FailoverStandby is the cluster the requests are sent to while the primary one isn't healthy.
+	FailoverStandby
+)
+
+func (c FailoverCluster) String() string {
+	if c == FailoverStandby {
+		return "standby"
+	}
+	return "primary"
+}
+
+// FailoverConfig decides when a FailoverPool cuts over to its standby cluster, and back.
+type FailoverConfig struct {
+	// CutoverBelow is the fraction of the backends of the primary cluster with an established connection under which
+	// the requests are sent to the standby cluster.
+	CutoverBelow float64
+	// CutBackAt is the fraction of healthy primary backends from which the requests go back to the primary cluster,
+	// all of them if 0. It's at least CutoverBelow, a higher one keeps a primary cluster hovering around the
+	// threshold from bouncing the requests back and forth.
+	CutBackAt float64
+	// CutBackAfter is how long the primary cluster must stay at CutBackAt before the requests go back to it, so that
+	// its backends have the time to settle.
+	CutBackAfter time.Duration
+	// CheckInterval is how often the health of the primary cluster is checked, every second if 0.
+	CheckInterval time.Duration
+	// OnEvent is called, if set, on the routine checking the health of the primary cluster whenever the requests are
+	// sent to another cluster.
+	OnEvent func(FailoverEvent)
+}
+
+// FailoverEvent reports that a FailoverPool sends the requests to another cluster.
+type FailoverEvent struct {
+	// Active is the cluster the requests are sent to from now on.
+	Active FailoverCluster
+	// HealthyFraction is the fraction of the backends of the primary cluster which had an established connection.
+	HealthyFraction float64
+	At              time.Time
+}
+
+// FailoverStats are the counters of a FailoverPool.
+type FailoverStats struct {
+	// Active is the cluster the requests are currently sent to.
+	Active FailoverCluster
+	// Cutovers is the number of times the requests were sent to the standby cluster.
+	Cutovers uint64
+	// Cutbacks is the number of times the requests went back to the primary cluster.
+	Cutbacks uint64
+	// StandbyAppends is the number of requests sent to the standby cluster.
+	StandbyAppends uint64
+	// PostponedCutbacks is the number of times the requests stayed on the standby cluster because the keys written to
+	// it couldn't be invalidated on the primary one.
+	PostponedCutbacks uint64
+}
+
+// FailoverInvalidator removes from the primary cluster of a FailoverPool the keys written to the standby one, whose
+// previous values the primary cluster would serve once the requests go back to it.
+type FailoverInvalidator interface {
+	// Invalidate deletes keys from the primary pool, placing each of them with its RoutingKey.
+	Invalidate(primary TCPConnPool, keys []memcache.WrittenKey) error
+	// Flush invalidates every item of the primary pool, for the writes which couldn't be tracked key by key.
+	Flush(primary TCPConnPool) error
+}
+
+// FailoverPool sends the requests to a primary pool, or to a standby pool while too few backends of the primary one
+// have an established connection. The requests for a given backend, e.g. admin commands, go to the pool the backend
+// belongs to whichever is active, and the backends are added to and removed from the primary pool. Backends and
+// Topology describe the active pool, since it's the one placing the keys. The keys written to the standby pool are
+// invalidated on the primary one before the requests go back to it.
+type FailoverPool struct {
+	primary     TCPConnPool
+	standby     TCPConnPool
+	config      FailoverConfig
+	clock       Clock
+	invalidator FailoverInvalidator
+
+	standbyActive atomic.Bool
+	// switching is held by the appends to the standby pool, and by the cutback while it invalidates the last keys
+	// written to it, so that none of them is missed.
+	switching sync.RWMutex
+
+	writtenMu sync.Mutex
+	// written are the keys written to the standby pool since they were last invalidated on the primary one.
+	written map[memcache.WrittenKey]struct{} // protected by writtenMu
+	// untracked is set once keys were written to the standby pool without being recorded in written, either because
+	// there were too many of them or because their placement isn't known, so the primary pool must be flushed.
+	untracked bool // protected by writtenMu
+	// recoveringSince is when the primary pool was first seen healthy enough to cut back to, zero unless the standby
+	// pool is active. Only check uses it, on the monitoring routine.
+	recoveringSince time.Time
+
+	cutovers       atomic.Uint64
+	cutbacks       atomic.Uint64
+	standbyAppends atomic.Uint64
+	postponed      atomic.Uint64
+
+	stop        chan struct{}
+	monitorDone chan struct{}
+	closeOnce   sync.Once
+	done        chan struct{}
+}
+
+var _ TCPConnPool = (*FailoverPool)(nil)
+
+// NewFailoverPool wraps primary and standby, checking the health of primary every config.CheckInterval of clock
+// until the pool is closed. The keys written to standby are invalidated on primary with invalidator before cutting
+// back to it, they aren't if it's nil.
+func NewFailoverPool(primary, standby TCPConnPool, config FailoverConfig, clock Clock, invalidator FailoverInvalidator) *FailoverPool {
+	if config.CutBackAt <= 0 {
+		config.CutBackAt = 1
+	}
+	config.CutBackAt = max(config.CutBackAt, config.CutoverBelow)
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = time.Second
+	}
+	p := &FailoverPool{
+		primary:     primary,
+		standby:     standby,
+		config:      config,
+		clock:       clock,
+		invalidator: invalidator,
+		stop:        make(chan struct{}),
+		monitorDone: make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go p.monitor()
+	return p
+}
+
+func (p *FailoverPool) monitor() {
+	defer close(p.monitorDone)
+	for {
+		timer := p.clock.NewTimer(p.config.CheckInterval)
+		select {
+		case <-p.stop:
+			timer.Stop()
+			return
+		case <-timer.C():
+			p.check()
+		}
+	}
+}
+
+// check cuts over to the standby pool, or back to the primary one, according to the health of the primary pool.
+func (p *FailoverPool) check() {
+	fraction := healthyFraction(p.primary.Topology())
+	now := p.clock.Now()
+
+	if !p.standbyActive.Load() {
+		if fraction < p.config.CutoverBelow {
+			p.standbyActive.Store(true)
+			p.cutovers.Add(1)
+			p.notify(FailoverStandby, fraction, now)
+		}
+		return
+	}
+
+	if fraction < p.config.CutBackAt {
+		p.recoveringSince = time.Time{}
+		return
+	}
+	if p.recoveringSince.IsZero() {
+		p.recoveringSince = now
+	}
+	if now.Sub(p.recoveringSince) < p.config.CutBackAfter {
+		return
+	}
+	if !p.cutBack() {
+		// the next check tries again, the primary pool having recovered for long enough already.
+		p.postponed.Add(1)
+		return
+	}
+	p.recoveringSince = time.Time{}
+	p.cutbacks.Add(1)
+	p.notify(FailoverPrimary, fraction, now)
+}
+
+// cutBack sends the requests back to the primary pool once the keys written to the standby one are invalidated on
+// it. Most of them are invalidated while the requests still go to the standby pool, the ones written meanwhile with
+// the appends held back. It returns false, the standby pool staying active, if they couldn't be.
+func (p *FailoverPool) cutBack() bool {
+	if !p.invalidateWritten() {
+		return false
+	}
+
+	p.switching.Lock()
+	defer p.switching.Unlock()
+	if !p.invalidateWritten() {
+		return false
+	}
+	p.standbyActive.Store(false)
+	return true
+}
+
+// invalidateWritten invalidates on the primary pool the keys recorded since the last call, keeping them recorded if
+// they couldn't be.
+func (p *FailoverPool) invalidateWritten() bool {
+	if p.invalidator == nil {
+		return true
+	}
+
+	p.writtenMu.Lock()
+	written, untracked := p.written, p.untracked
+	p.written, p.untracked = nil, false
+	p.writtenMu.Unlock()
+
+	var err error
+	switch {
+	case untracked:
+		err = p.invalidator.Flush(p.primary)
+	case len(written) > 0:
+		keys := make([]memcache.WrittenKey, 0, len(written))
+		for key := range written {
+			keys = append(keys, key)
+		}
+		err = p.invalidator.Invalidate(p.primary, keys)
+	}
+	if err == nil {
+		return true
+	}
+
+	p.writtenMu.Lock()
+	defer p.writtenMu.Unlock()
+	p.untracked = p.untracked || untracked
+	for key := range written {
+		p.recordLocked(key)
+	}
+	return false
+}
+
+// record remembers the keys the request of link may write to the standby pool.
+func (p *FailoverPool) record(link codec.Link) {
+	if p.invalidator == nil {
+		return
+	}
+	keys := memcache.WrittenKeys(link.Encoder())
+	if len(keys) == 0 {
+		return
+	}
+	var hint codec.RoutingHint
+	if routed, ok := link.(codec.RoutedLink); ok {
+		hint = routed.RoutingHint()
+	}
+
+	p.writtenMu.Lock()
+	defer p.writtenMu.Unlock()
+	if hint.Backend != "" || hint.Broadcast != nil {
+		// the backends of the standby pool don't tell where the keys are placed on the primary one.
+		p.untracked = true
+	}
+	for _, key := range keys {
+		if hint.HashKey != "" {
+			key.RoutingKey = hint.HashKey
+		}
+		p.recordLocked(key)
+	}
+}
+
+func (p *FailoverPool) recordLocked(key memcache.WrittenKey) {
+	if p.untracked {
+		p.written = nil
+		return
+	}
+	if len(p.written) >= maxFailoverWrittenKeys {
+		p.untracked = true
+		p.written = nil
+		return
+	}
+	if p.written == nil {
+		p.written = make(map[memcache.WrittenKey]struct{})
+	}
+	p.written[key] = struct{}{}
+}
+
+func (p *FailoverPool) notify(active FailoverCluster, fraction float64, now time.Time) {
+	if p.config.OnEvent != nil {
+		p.config.OnEvent(FailoverEvent{Active: active, HealthyFraction: fraction, At: now})
+	}
+}
+
+// healthyFraction returns the fraction of the backends of topology with an established connection, 0 without any.
+func healthyFraction(topology Topology) float64 {
+	if len(topology.Backends) == 0 {
+		return 0
+	}
+	healthy := 0
+	for _, be := range topology.Backends {
+		if be.Healthy() {
+			healthy++
+		}
+	}
+	return float64(healthy) / float64(len(topology.Backends))
+}
+
+// Active returns the cluster the requests are sent to.
+func (p *FailoverPool) Active() FailoverCluster {
+	if p.standbyActive.Load() {
+		return FailoverStandby
+	}
+	return FailoverPrimary
+}
+
+// FailoverStats returns the counters of the pool.
+func (p *FailoverPool) FailoverStats() FailoverStats {
+	return FailoverStats{
+		Active:            p.Active(),
+		Cutovers:          p.cutovers.Load(),
+		Cutbacks:          p.cutbacks.Load(),
+		StandbyAppends:    p.standbyAppends.Load(),
+		PostponedCutbacks: p.postponed.Load(),
+	}
+}
+
+func (p *FailoverPool) active() TCPConnPool {
+	if p.standbyActive.Load() {
+		return p.standby
+	}
+	return p.primary
+}
+
+// owner returns the pool be belongs to, the primary one unless it's a backend of the standby one.
+func (p *FailoverPool) owner(be *Backend) TCPConnPool {
+	if slices.Contains(p.standby.Backends(), be) {
+		return p.standby
+	}
+	return p.primary
+}
+
+func (p *FailoverPool) Append(link codec.Link) error {
+	if p.standbyActive.Load() {
+		p.switching.RLock()
+		defer p.switching.RUnlock()
+		// the cutback may have completed while waiting.
+		if p.standbyActive.Load() {
+			p.standbyAppends.Add(1)
+			p.record(link)
+			return p.standby.Append(link)
+		}
+	}
+	return p.primary.Append(link)
+}
+
+func (p *FailoverPool) Add(be *Backend) error {
+	return p.primary.Add(be)
+}
+
+func (p *FailoverPool) Remove(be *Backend) error {
+	return p.primary.Remove(be)
+}
+
+func (p *FailoverPool) Backends() []*Backend {
+	return p.active().Backends()
+}
+
+func (p *FailoverPool) AppendTo(be *Backend, link codec.Link) error {
+	return p.owner(be).AppendTo(be, link)
+}
+
+func (p *FailoverPool) AppendAdmin(be *Backend, link codec.Link) error {
+	return p.owner(be).AppendAdmin(be, link)
+}
+
+func (p *FailoverPool) AppendIsolated(ctx context.Context, be *Backend, link codec.Link) error {
+	return p.owner(be).AppendIsolated(ctx, be, link)
+}
+
+// Stats returns the counters of the backends of both pools.
+func (p *FailoverPool) Stats() map[string]ConnStats {
+	stats := p.primary.Stats()
+	for addr, s := range p.standby.Stats() {
+		stats[addr] = stats[addr].add(s)
+	}
+	return stats
+}
+
+func (p *FailoverPool) Recommendation() []ConnRecommendation {
+	return append(p.primary.Recommendation(), p.standby.Recommendation()...)
+}
+
+// Handover exports the primary pool, the one a replacement pool should start from.
+func (p *FailoverPool) Handover() Handover {
+	return p.primary.Handover()
+}
+
+func (p *FailoverPool) Topology() Topology {
+	return p.active().Topology()
+}
+
+// WarmUp warms both pools up, so that the standby one is ready to take the requests over.
+func (p *FailoverPool) WarmUp(ctx context.Context, probe ProbeFn) []BackendReadiness {
+	var standby []BackendReadiness
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		standby = p.standby.WarmUp(ctx, probe)
+	}()
+	primary := p.primary.WarmUp(ctx, probe)
+	wg.Wait()
+	return append(primary, standby...)
+}
+
+func (p *FailoverPool) Close() {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		p.primary.Close()
+		p.standby.Close()
+		go func() {
+			<-p.monitorDone
+			p.primary.Wait()
+			p.standby.Wait()
+			close(p.done)
+		}()
+	})
+}
+
+func (p *FailoverPool) Done() <-chan struct{} {
+	return p.done
+}
+
+func (p *FailoverPool) Wait() {
+	<-p.done
+}
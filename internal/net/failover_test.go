@@ -0,0 +1,251 @@
+package net
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+// healthPool is a TCPConnPool whose backends are healthy or not as set, counting the links appended to it.
+type healthPool struct {
+	TCPConnPool
+	backends []*Backend
+
+	mu      sync.Mutex
+	healthy int // protected by mu
+	appends int // protected by mu
+}
+
+func newHealthPool(ports ...int) *healthPool {
+	p := &healthPool{}
+	for _, port := range ports {
+		p.backends = append(p.backends, NewBackend(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}, 1, nil))
+	}
+	p.healthy = len(p.backends)
+	return p
+}
+
+func (p *healthPool) setHealthy(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.healthy = n
+}
+
+func (p *healthPool) appended() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.appends
+}
+
+func (p *healthPool) Backends() []*Backend {
+	return p.backends
+}
+
+func (p *healthPool) Topology() Topology {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var topology Topology
+	for i, be := range p.backends {
+		healthy := 0
+		if i < p.healthy {
+			healthy = 1
+		}
+		topology.Backends = append(topology.Backends, TopologyBackend{Addr: be.String(), NumConns: 1, HealthyConns: healthy})
+	}
+	return topology
+}
+
+func (p *healthPool) Append(codec.Link) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.appends++
+	return nil
+}
+
+func (p *healthPool) AppendTo(*Backend, codec.Link) error {
+	return p.Append(nil)
+}
+
+func (p *healthPool) Close() {}
+
+func (p *healthPool) Wait() {}
+
+func TestFailoverPool(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	primary, standby := newHealthPool(11211, 11212, 11213, 11214), newHealthPool(11311, 11312)
+	clock := NewSimClock(time.Unix(1000, 0))
+	var events []FailoverEvent
+	pool := NewFailoverPool(primary, standby, FailoverConfig{
+		CutoverBelow:  0.5,
+		CutBackAt:     0.75,
+		CutBackAfter:  10 * time.Second,
+		CheckInterval: time.Hour,
+		OnEvent:       func(e FailoverEvent) { events = append(events, e) },
+	}, clock, nil)
+	defer pool.Wait()
+	defer pool.Close()
+
+	send := func() {
+		require.NoError(t, pool.Append(codec.NewGenericLink(&echoEncoder{}, &echoDecoder{})))
+	}
+
+	send()
+	primary.setHealthy(2)
+	pool.check()
+	send()
+	assert.Equal(t, FailoverPrimary, pool.Active())
+	assert.Equal(t, 2, primary.appended())
+
+	// under the threshold, the requests go to the standby cluster.
+	primary.setHealthy(1)
+	pool.check()
+	send()
+	assert.Equal(t, FailoverStandby, pool.Active())
+	assert.Equal(t, 1, standby.appended())
+	assert.Equal(t, standby.Backends(), pool.Backends())
+	// the requests for a backend go to its cluster.
+	require.NoError(t, pool.AppendTo(primary.backends[0], codec.NewGenericLink(&echoEncoder{}, &echoDecoder{})))
+	assert.Equal(t, 3, primary.appended())
+
+	// back over the cutover threshold but under the cutback one, nothing changes.
+	primary.setHealthy(2)
+	pool.check()
+	assert.Equal(t, FailoverStandby, pool.Active())
+
+	// the primary cluster must stay healthy long enough.
+	primary.setHealthy(3)
+	pool.check()
+	clock.Advance(5 * time.Second)
+	primary.setHealthy(2)
+	pool.check()
+	primary.setHealthy(4)
+	pool.check()
+	clock.Advance(9 * time.Second)
+	pool.check()
+	assert.Equal(t, FailoverStandby, pool.Active())
+	clock.Advance(time.Second)
+	pool.check()
+	assert.Equal(t, FailoverPrimary, pool.Active())
+	send()
+	assert.Equal(t, 4, primary.appended())
+
+	assert.Equal(t, []FailoverEvent{
+		{Active: FailoverStandby, HealthyFraction: 0.25, At: time.Unix(1000, 0)},
+		{Active: FailoverPrimary, HealthyFraction: 1, At: time.Unix(1015, 0)},
+	}, events)
+	assert.Equal(t, FailoverStats{Active: FailoverPrimary, Cutovers: 1, Cutbacks: 1, StandbyAppends: 1}, pool.FailoverStats())
+}
+
+func TestFailoverPoolMonitor(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	primary, standby := newHealthPool(11211), newHealthPool(11311)
+	clock := NewSimClock(time.Unix(1000, 0))
+	pool := NewFailoverPool(primary, standby, FailoverConfig{CutoverBelow: 1, CheckInterval: time.Second}, clock, nil)
+
+	primary.setHealthy(0)
+	assert.Eventually(t, func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(time.Second)
+	assert.Eventually(t, func() bool { return pool.Active() == FailoverStandby }, time.Second, time.Millisecond)
+
+	pool.Close()
+	pool.Wait()
+}
+
+// recordingInvalidator records the keys invalidated on the primary pool, failing while err is set.
+type recordingInvalidator struct {
+	err     error
+	keys    []memcache.WrittenKey
+	flushes int
+}
+
+func (i *recordingInvalidator) Invalidate(_ TCPConnPool, keys []memcache.WrittenKey) error {
+	if i.err != nil {
+		return i.err
+	}
+	i.keys = append(i.keys, keys...)
+	return nil
+}
+
+func (i *recordingInvalidator) Flush(TCPConnPool) error {
+	if i.err != nil {
+		return i.err
+	}
+	i.flushes++
+	return nil
+}
+
+func TestFailoverPoolInvalidatesWrittenKeys(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	primary, standby := newHealthPool(11211), newHealthPool(11311)
+	clock := NewSimClock(time.Unix(1000, 0))
+	invalidator := &recordingInvalidator{}
+	pool := NewFailoverPool(primary, standby, FailoverConfig{CutoverBelow: 1, CheckInterval: time.Hour}, clock, invalidator)
+	defer pool.Wait()
+	defer pool.Close()
+
+	send := func(e codec.LinkEncoder, hint codec.RoutingHint) {
+		require.NoError(t, pool.Append(codec.NewRoutedLink(e, &echoDecoder{}, hint)))
+	}
+	cutOverAndBack := func() {
+		primary.setHealthy(0)
+		pool.check()
+		require.Equal(t, FailoverStandby, pool.Active())
+		primary.setHealthy(1)
+	}
+
+	// the keys written to the primary pool don't need to be invalidated.
+	send(&memcache.MetaSetEncoder{Key: "before"}, codec.RoutingHint{})
+
+	cutOverAndBack()
+	send(&memcache.MetaSetEncoder{Key: "a"}, codec.RoutingHint{})
+	send(&memcache.MetaDeleteEncoder{Key: "b"}, codec.RouteByHashKey("shard"))
+	send(&memcache.MetaGetEncoder{Key: "c"}, codec.RoutingHint{})
+
+	// the cutback waits for the keys to be invalidated.
+	invalidator.err = assert.AnError
+	pool.check()
+	assert.Equal(t, FailoverStandby, pool.Active())
+	send(&memcache.MetaSetEncoder{Key: "d"}, codec.RoutingHint{})
+	invalidator.err = nil
+	pool.check()
+	assert.Equal(t, FailoverPrimary, pool.Active())
+	assert.ElementsMatch(t, []memcache.WrittenKey{
+		{Key: "a", RoutingKey: "a"},
+		{Key: "b", RoutingKey: "shard"},
+		{Key: "d", RoutingKey: "d"},
+	}, invalidator.keys)
+	assert.Zero(t, invalidator.flushes)
+
+	// the keys written to a given backend of the standby pool aren't placed on the primary one.
+	invalidator.keys = nil
+	cutOverAndBack()
+	send(&memcache.MetaSetEncoder{Key: "e"}, codec.RouteToBackend(standby.backends[0].String()))
+	pool.check()
+	assert.Equal(t, FailoverPrimary, pool.Active())
+	assert.Empty(t, invalidator.keys)
+	assert.Equal(t, 1, invalidator.flushes)
+
+	// nor too many of them.
+	cutOverAndBack()
+	for i := 0; i <= maxFailoverWrittenKeys; i++ {
+		send(&memcache.MetaSetEncoder{Key: strconv.Itoa(i)}, codec.RoutingHint{})
+	}
+	pool.check()
+	assert.Equal(t, FailoverPrimary, pool.Active())
+	assert.Empty(t, invalidator.keys)
+	assert.Equal(t, 2, invalidator.flushes)
+
+	assert.Equal(t, uint64(1), pool.FailoverStats().PostponedCutbacks)
+}
@@ -0,0 +1,138 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/stripe/memlink/internal/safepool"
+	"github.com/stripe/memlink/internal/utils"
+)
+
+// maxPooledFrameSize bounds the frames returned to the pool, so that a few large values don't pin their buffers.
+const maxPooledFrameSize = 64 * 1024
+
+// frame is a complete response read off the socket: its header line and, for values, the data block announced by
+// the header, both with their trailing \r\n.
+type frame struct {
+	buf []byte
+}
+
+var framePool = safepool.NewPool(func() *frame {
+	return &frame{buf: make([]byte, 0, 1024)}
+})
+
+func releaseFrame(f *frame) {
+	if cap(f.buf) > maxPooledFrameSize {
+		return
+	}
+	f.buf = f.buf[:0]
+	framePool.Put(f)
+}
+
+// Opaque returns the opaque token echoed in the header of a meta response, which tells the request it answers.
+func (f *frame) Opaque() (uint64, bool) {
+	hdrLine := f.buf
+	if i := bytes.IndexByte(hdrLine, '\n'); i >= 0 {
+		hdrLine = hdrLine[:i]
+	}
+	for idx, elem := range bytes.Fields(hdrLine) {
+		if idx == 0 || len(elem) < 2 || elem[0] != 'O' {
+			continue
+		}
+		o, err := strconv.ParseUint(string(elem[1:]), 10, 64)
+		return o, err == nil
+	}
+	return 0, false
+}
+
+// readFrame reads the next response of reader into f. A data block larger than maxSize bytes isn't read, a
+// non-positive maxSize allowing any size up to utils.MaxDataBlockSize.
+func readFrame(reader *bufio.Reader, f *frame, maxSize int) error {
+	for {
+		line, err := reader.ReadSlice('\n')
+		f.buf = append(f.buf, line...)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return err
+		}
+	}
+
+	size, ok, err := utils.DataBlockSize(f.buf)
+	if err != nil || !ok {
+		return err
+	}
+	f.buf, err = utils.AppendDataBlock(f.buf, reader, size, maxSize)
+	var tooLarge *utils.DataBlockTooLargeError
+	if errors.As(err, &tooLarge) {
+		return &ResponseTooLargeError{Header: string(bytes.TrimRight(f.buf, "\r\n")), Size: size, Limit: tooLarge.Limit}
+	}
+	return err
+}
+
+// frameReader exposes the frames read by the reading routine of a session as a stream, for the decoders. The frames
+// are returned to their pool once consumed.
+type frameReader struct {
+	frames chan *frame
+	// stopped is closed along with frames, once the reading routine returned.
+	stopped chan struct{}
+	// err is the error which stopped the reading routine, set before frames is closed.
+	err error
+
+	cur *frame
+	off int
+}
+
+func newFrameReader(size int) *frameReader {
+	return &frameReader{frames: make(chan *frame, max(size, 1)), stopped: make(chan struct{})}
+}
+
+// stop is called by the reading routine when it returns, err being why.
+func (r *frameReader) stop(err error) {
+	r.err = err
+	close(r.frames)
+	close(r.stopped)
+}
+
+func (r *frameReader) Read(p []byte) (int, error) {
+	for r.cur == nil || r.off == len(r.cur.buf) {
+		if r.cur != nil {
+			releaseFrame(r.cur)
+			r.cur = nil
+		}
+		f, ok := <-r.frames
+		if !ok {
+			if r.err == nil {
+				return 0, io.EOF
+			}
+			return 0, r.err
+		}
+		r.cur, r.off = f, 0
+	}
+
+	n := copy(p, r.cur.buf[r.off:])
+	r.off += n
+	return n, nil
+}
+
+// discard returns the frames left once the reading routine stopped to their pool.
+func (r *frameReader) discard() {
+	for f := range r.frames {
+		releaseFrame(f)
+	}
+	if r.cur != nil {
+		releaseFrame(r.cur)
+		r.cur = nil
+	}
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
@@ -0,0 +1,96 @@
+package net
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFrame(t *testing.T) {
+	longHeader := "HD " + strings.Repeat("k", 5000) + "\r\n"
+	tests := []struct {
+		name    string
+		input   string
+		maxSize int
+		want    []string
+		wantErr bool
+	}{
+		{name: "meta header", input: "HD O1\r\nEN\r\n", want: []string{"HD O1\r\n", "EN\r\n"}},
+		{name: "meta value", input: "VA 4 f1\r\na\r\nb\r\nHD\r\n", want: []string{"VA 4 f1\r\na\r\nb\r\n", "HD\r\n"}},
+		{name: "empty meta value", input: "VA 0\r\n\r\n", want: []string{"VA 0\r\n\r\n"}},
+		{name: "classic value", input: "VALUE key 0 3 42\r\nabc\r\nEND\r\n", want: []string{"VALUE key 0 3 42\r\nabc\r\n", "END\r\n"}},
+		{name: "stats", input: "STAT pid 1\r\nEND\r\n", want: []string{"STAT pid 1\r\n", "END\r\n"}},
+		{name: "header longer than the buffer", input: longHeader, want: []string{longHeader}},
+		{name: "invalid size", input: "VA x\r\n", wantErr: true},
+		{name: "truncated value", input: "VA 10\r\nabc", wantErr: true},
+		{name: "value within the limit", input: "VA 3\r\nabc\r\n", maxSize: 3, want: []string{"VA 3\r\nabc\r\n"}},
+		{name: "value over the limit", input: "VA 4\r\nabcd\r\n", maxSize: 3, wantErr: true},
+		{name: "classic value over the limit", input: "VALUE key 0 4\r\nabcd\r\n", maxSize: 3, wantErr: true},
+		{name: "value over the hard limit", input: "VA 2000000000\r\nabcd\r\n", wantErr: true},
+		{name: "limit over the hard limit", input: "VA 2000000000\r\nabcd\r\n", maxSize: 1 << 40, wantErr: true},
+		{name: "overflowing size", input: "VA 99999999999999999999\r\nabcd\r\n", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(test.input))
+			var got []string
+			for {
+				if _, err := reader.Peek(1); err == io.EOF {
+					break
+				}
+				f := &frame{}
+				err := readFrame(reader, f, test.maxSize)
+				if test.wantErr {
+					assert.Error(t, err)
+					return
+				}
+				require.NoError(t, err)
+				got = append(got, string(f.buf))
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestFrameOpaque(t *testing.T) {
+	tests := []struct {
+		header string
+		opaque uint64
+		ok     bool
+	}{
+		{header: "HD O42\r\n", opaque: 42, ok: true},
+		{header: "VA 2 f1 O7\r\nO9\r\n", opaque: 7, ok: true},
+		{header: "VA 2\r\nO9\r\n"},
+		{header: "MN\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			opaque, ok := (&frame{buf: []byte(test.header)}).Opaque()
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.opaque, opaque)
+		})
+	}
+}
+
+func TestFrameReader(t *testing.T) {
+	frames := newFrameReader(3)
+	for _, s := range []string{"VA 3\r\nabc\r\n", "HD O1\r\n"} {
+		frames.frames <- &frame{buf: []byte(s)}
+	}
+	frames.stop(io.ErrUnexpectedEOF)
+
+	// the decoders see the frames as one stream, then the error which stopped the reading routine.
+	reader := bufio.NewReader(frames)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "VA 3\r\n", line)
+	rest, err := io.ReadAll(io.LimitReader(reader, 12))
+	require.NoError(t, err)
+	assert.Equal(t, "abc\r\nHD O1\r\n", string(rest))
+	_, err = reader.ReadByte()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,59 @@
+package net
+
+import (
+	"time"
+)
+
+// Handover is the state of a connection pool exported when it's closed, for the process replacing it in a blue/green
+// deploy to start with the same placement and warm its connections before taking the traffic.
+type Handover struct {
+	// Backends are the backends of the pool in placement order: a key hashed to index i is served by Backends[i].
+	Backends []HandoverBackend
+	// ExportedAt is when the handover was exported.
+	ExportedAt time.Time
+}
+
+// HandoverBackend is the state of a backend of the pool.
+type HandoverBackend struct {
+	Addr     string
+	NumConns int
+	Weight   int
+	// Capabilities are the capabilities detected for the backend, zero if HasCapabilities is false.
+	Capabilities    Capabilities
+	HasCapabilities bool
+	Compression     Compression
+	// Stats are the cumulative counters of the connections to the backend when the handover was exported.
+	Stats ConnStats
+}
+
+// Addresses returns the addresses of the backends, in placement order.
+func (h Handover) Addresses() []string {
+	addrs := make([]string, 0, len(h.Backends))
+	for _, be := range h.Backends {
+		addrs = append(addrs, be.Addr)
+	}
+	return addrs
+}
+
+func (t *tcpConnPool) Handover() Handover {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	h := Handover{Backends: make([]HandoverBackend, 0, len(t.backends)), ExportedAt: time.Now()}
+	for _, be := range t.backends {
+		caps, ok := be.Capabilities()
+		hb := HandoverBackend{
+			Addr:            be.String(),
+			NumConns:        be.numConns,
+			Weight:          be.Weight(),
+			Capabilities:    caps,
+			HasCapabilities: ok,
+			Compression:     be.Compression(),
+		}
+		if cl, ok := t.cm[be.String()]; ok {
+			hb.Stats = cl.Stats()
+		}
+		h.Backends = append(h.Backends, hb)
+	}
+	return h
+}
@@ -0,0 +1,34 @@
+package net
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolHandover(t *testing.T) {
+	be1 := NewBackend(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 11212}, 2, nil)
+	be2 := NewWeightedBackend(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 11211}, 4, 3, nil)
+	caps := Capabilities{Version: "1.6.21", MetaProtocol: true, Base64Keys: true}
+	be2.SetCapabilities(caps)
+	compression := CompressionZstd
+	be2.compression.Store(&compression)
+
+	pool := &tcpConnPool{
+		backends: []*Backend{be1, be2},
+		cm: map[string]TCPConnList{
+			be1.String(): &MockTCPConnList{},
+			be2.String(): &MockTCPConnList{},
+		},
+		hashFn: RandomHashFn,
+	}
+
+	h := pool.Handover()
+	assert.False(t, h.ExportedAt.IsZero())
+	assert.Equal(t, []string{"127.0.0.1:11212", "127.0.0.1:11211"}, h.Addresses())
+	assert.Equal(t, []HandoverBackend{
+		{Addr: "127.0.0.1:11212", NumConns: 2, Weight: 1},
+		{Addr: "127.0.0.1:11211", NumConns: 4, Weight: 3, Capabilities: caps, HasCapabilities: true, Compression: CompressionZstd},
+	}, h.Backends)
+}
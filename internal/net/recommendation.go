@@ -0,0 +1,90 @@
+package net
+
+import "time"
+
+const (
+	// links waiting longer than this on average before being written mean the connections can't keep up.
+	recommendMaxQueueWait = time.Millisecond
+	// below this fraction of links queued behind another one, the connections are mostly idle.
+	recommendMinUtilization = 0.05
+	recommendMaxConns       = 64
+)
+
+// ConnRecommendation is an advisory number of connections for a backend, derived from the load observed since the
+// previous call to Recommendation.
+type ConnRecommendation struct {
+	Backend          *Backend
+	CurrentConns     int
+	RecommendedConns int
+	// AvgQueueWait is the average time a link spent queued before being written to a connection.
+	AvgQueueWait time.Duration
+	// Utilization is the fraction of the links which were queued behind other in flight links.
+	Utilization float64
+	// Rejected is the number of links refused because the connections' queues were full.
+	Rejected uint64
+	Reason   string
+}
+
+// Recommendation suggests, for every backend, how many connections would serve the traffic observed since the
+// previous call (or since the pool was created). It's purely advisory: the pool isn't resized.
+func (t *tcpConnPool) Recommendation() []ConnRecommendation {
+	t.mu.RLock()
+	backends := make([]*Backend, len(t.backends))
+	current := make([]ConnStats, len(t.backends))
+	for i, be := range t.backends {
+		backends[i] = be
+		current[i] = t.cm[be.String()].Stats()
+	}
+	t.mu.RUnlock()
+
+	t.recMu.Lock()
+	defer t.recMu.Unlock()
+
+	if t.lastStats == nil {
+		t.lastStats = make(map[string]ConnStats)
+	}
+
+	recommendations := make([]ConnRecommendation, 0, len(backends))
+	for i, be := range backends {
+		window := current[i].sub(t.lastStats[be.String()])
+		t.lastStats[be.String()] = current[i]
+		recommendations = append(recommendations, recommend(be, window))
+	}
+	return recommendations
+}
+
+func recommend(be *Backend, window ConnStats) ConnRecommendation {
+	// NewTCPConnectionList opens at least one connection.
+	conns := max(1, be.numConns)
+	rec := ConnRecommendation{
+		Backend:          be,
+		CurrentConns:     conns,
+		RecommendedConns: conns,
+		Rejected:         window.Rejected,
+	}
+
+	if window.Appends == 0 && window.Rejected == 0 {
+		rec.Reason = "no traffic"
+		return rec
+	}
+
+	if window.Appends > 0 {
+		rec.AvgQueueWait = window.QueueWait / time.Duration(window.Appends)
+		rec.Utilization = float64(window.BusyAppends) / float64(window.Appends)
+	}
+
+	switch {
+	case window.Rejected > 0:
+		rec.RecommendedConns = min(recommendMaxConns, conns*2)
+		rec.Reason = "outbound queues overflowed"
+	case rec.AvgQueueWait > recommendMaxQueueWait:
+		rec.RecommendedConns = min(recommendMaxConns, conns*2)
+		rec.Reason = "requests wait too long before being written"
+	case rec.Utilization < recommendMinUtilization && conns > 1:
+		rec.RecommendedConns = max(1, conns/2)
+		rec.Reason = "connections are mostly idle"
+	default:
+		rec.Reason = "connections are adequately loaded"
+	}
+	return rec
+}
@@ -0,0 +1,65 @@
+package net
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type statsConnList struct {
+	MockTCPConnList
+	stats ConnStats
+}
+
+func (s *statsConnList) Stats() ConnStats {
+	return s.stats
+}
+
+func TestRecommend(t *testing.T) {
+	be := NewBackend(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 11211}, 4, nil)
+
+	tests := []struct {
+		name     string
+		window   ConnStats
+		expected int
+	}{
+		{name: "no traffic", window: ConnStats{}, expected: 4},
+		{name: "rejections", window: ConnStats{Appends: 10, Rejected: 1}, expected: 8},
+		{name: "long queue wait", window: ConnStats{Appends: 10, BusyAppends: 10, QueueWait: 50 * time.Millisecond}, expected: 8},
+		{name: "mostly idle", window: ConnStats{Appends: 100, BusyAppends: 1}, expected: 2},
+		{name: "adequately loaded", window: ConnStats{Appends: 100, BusyAppends: 50, QueueWait: time.Millisecond}, expected: 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := recommend(be, test.window)
+			assert.Equal(t, 4, rec.CurrentConns)
+			assert.Equal(t, test.expected, rec.RecommendedConns)
+			assert.NotEmpty(t, rec.Reason)
+		})
+	}
+}
+
+func TestRecommendationUsesWindowSinceLastCall(t *testing.T) {
+	be := NewBackend(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 11211}, 2, nil)
+	cl := &statsConnList{stats: ConnStats{Appends: 10, Rejected: 5}}
+	pool := &tcpConnPool{
+		backends: []*Backend{be},
+		cm:       map[string]TCPConnList{be.String(): cl},
+		hashFn:   RandomHashFn,
+	}
+
+	recs := pool.Recommendation()
+	assert.Len(t, recs, 1)
+	assert.Equal(t, 4, recs[0].RecommendedConns)
+	assert.Equal(t, uint64(5), recs[0].Rejected)
+
+	// no new rejections since the previous call.
+	cl.stats = ConnStats{Appends: 110, BusyAppends: 50, Rejected: 5}
+	recs = pool.Recommendation()
+	assert.Equal(t, 2, recs[0].RecommendedConns)
+	assert.Zero(t, recs[0].Rejected)
+	assert.Equal(t, 0.5, recs[0].Utilization)
+}
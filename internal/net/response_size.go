@@ -0,0 +1,59 @@
+package net
+
+import "fmt"
+
+// ResponseTooLargeError completes the link whose response announced a data block larger than the maximum response
+// size, and the links pipelined behind it. The data block isn't read: the connection is closed and re-established
+// instead, as the size may come from a corrupted stream.
+type ResponseTooLargeError struct {
+	Backend string
+	// Header is the response header announcing the data block, without its \r\n.
+	Header string
+	// Size is the size of the data block announced by Header.
+	Size int
+	// Limit is the maximum response size of the connection.
+	Limit int
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("server %s announced a %d bytes value, over the %d bytes limit, in response %q", e.Backend, e.Size, e.Limit, e.Header)
+}
+
+// DefaultMaxResponseSize is the max response size of the connections to the backends whose item size limit wasn't
+// detected: memcached's default item_size_max, plus some slack.
+const DefaultMaxResponseSize = 1024*1024 + 64*1024
+
+// unlimitedResponseSize is the max response size of the connections opted out of it by WithoutMaxResponseSize.
+const unlimitedResponseSize = -1
+
+// WithMaxResponseSize fails the responses whose data block is larger than size bytes with a ResponseTooLargeError,
+// without reading it, and reconnects. It protects the client from allocating whatever size a misconfigured server or a
+// corrupted stream announces. Non-positive sizes keep the default: the item size limit detected for the backend, or
+// DefaultMaxResponseSize.
+func WithMaxResponseSize(size int) ConnOption {
+	return func(c *tcpConn) {
+		if size > 0 {
+			c.maxResponseSize = size
+		}
+	}
+}
+
+// WithoutMaxResponseSize opts out of the max response size, for the backends storing items larger than they report.
+// The data blocks are still bounded by memcached's largest item size limit, 1 GiB.
+func WithoutMaxResponseSize() ConnOption {
+	return func(c *tcpConn) {
+		c.maxResponseSize = unlimitedResponseSize
+	}
+}
+
+// responseSizeLimit returns the size of the largest data block read by the connection, non-positive meaning only the
+// hard limit of the data blocks applies.
+func (c *tcpConn) responseSizeLimit() int {
+	if c.maxResponseSize != 0 {
+		return c.maxResponseSize
+	}
+	if caps, ok := c.be.Capabilities(); ok && caps.MaxItemSize > 0 {
+		return caps.MaxItemSize
+	}
+	return DefaultMaxResponseSize
+}
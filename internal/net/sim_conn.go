@@ -0,0 +1,361 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/stripe/memlink/codec"
+)
+
+var (
+	// errSimConnBroken completes the links of a SimConn broken without an error of its own.
+	errSimConnBroken = errors.New("simulated connection broken")
+	errSimConnClosed = errors.New("simulated connection closed")
+)
+
+// SimClock is a virtual clock, only moved forward by Advance, so that the latencies simulated by SimConn and the
+// timers of the connections using it with WithClock don't depend on the scheduler.
+type SimClock struct {
+	mu     sync.Mutex
+	now    time.Time   // protected by mu
+	timers []*simTimer // protected by mu, the timers not fired nor stopped
+}
+
+func NewSimClock(start time.Time) *SimClock {
+	return &SimClock{now: start}
+}
+
+var _ Clock = (*SimClock)(nil)
+
+func (c *SimClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d and fires the timers due by then, in order. The functions of AfterFunc are
+// called by Advance itself, before it returns.
+func (c *SimClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	var due []*simTimer
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(now) {
+			pending = append(pending, t)
+		} else {
+			due = append(due, t)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].at.Before(due[j].at) })
+	for _, t := range due {
+		t.fire(now)
+	}
+}
+
+// Timers returns the number of timers waiting to fire, e.g. for a test to wait for a connection to arm one before
+// advancing the clock.
+func (c *SimClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func (c *SimClock) NewTimer(d time.Duration) Timer {
+	return c.schedule(d, nil)
+}
+
+func (c *SimClock) AfterFunc(d time.Duration, f func()) Timer {
+	return c.schedule(d, f)
+}
+
+func (c *SimClock) schedule(d time.Duration, f func()) *simTimer {
+	c.mu.Lock()
+	t := &simTimer{clock: c, at: c.now.Add(d), c: make(chan time.Time, 1), f: f}
+	if d > 0 {
+		c.timers = append(c.timers, t)
+		c.mu.Unlock()
+		return t
+	}
+	now := c.now
+	c.mu.Unlock()
+	t.fire(now)
+	return t
+}
+
+type simTimer struct {
+	clock *SimClock
+	at    time.Time
+	c     chan time.Time
+	f     func()
+}
+
+func (t *simTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *simTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, pending := range t.clock.timers {
+		if pending == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *simTimer) fire(now time.Time) {
+	if t.f != nil {
+		t.f()
+		return
+	}
+	t.c <- now
+}
+
+// SimResponder answers the encoded request of a link with the bytes its decoder reads, or fails it with err.
+type SimResponder func(request []byte) (response []byte, err error)
+
+// SimConn is a TCPConn backed by an in-memory queue instead of a socket. Nothing happens in the background: links
+// are encoded when appended and decoded when the test calls Step or Drain, once their latency elapsed on the SimClock.
+// Tests control the ordering of the responses with Reorder and the failures with Break and FailNext, which makes the
+// interleavings of appends, responses and connection losses reproducible.
+type SimConn struct {
+	clock     *SimClock
+	responder SimResponder
+	queueSize int
+
+	mu       sync.Mutex
+	latency  time.Duration // protected by mu
+	broken   bool          // protected by mu
+	closed   bool          // protected by mu
+	failNext []error       // protected by mu
+	pending  []*simRequest // protected by mu
+	requests [][]byte      // protected by mu
+
+	// done is closed by Close, a SimConn runs no routine to wait for.
+	done      chan struct{}
+	closeOnce sync.Once
+
+	stats connStats
+}
+
+type simRequest struct {
+	link       codec.Link
+	request    []byte
+	enqueuedAt time.Time
+	dueAt      time.Time
+}
+
+var _ TCPConn = (*SimConn)(nil)
+
+// NewSimConn creates a connected SimConn answering requests with responder, holding at most queueSize pending links
+// (defaultOutboundQueueSize when non-positive).
+func NewSimConn(clock *SimClock, responder SimResponder, queueSize int) *SimConn {
+	if queueSize <= 0 {
+		queueSize = defaultOutboundQueueSize
+	}
+	return &SimConn{clock: clock, responder: responder, queueSize: queueSize, done: make(chan struct{})}
+}
+
+// SetLatency delays the responses to the links appended from now on by latency on the clock.
+func (s *SimConn) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+func (s *SimConn) Append(link codec.Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || s.broken {
+		return fmt.Errorf("cannot append link, simulated connection is %s", s.stateLocked())
+	}
+	if len(s.pending) >= s.queueSize {
+		s.stats.rejected.Add(1)
+		return errOutboundQueueFull
+	}
+
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	if err := link.Encoder().Encode(w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	now := s.clock.Now()
+	if len(s.pending) > 0 {
+		s.stats.busyAppends.Add(1)
+	}
+	s.stats.appends.Add(1)
+	s.pending = append(s.pending, &simRequest{link: link, request: b.Bytes(), enqueuedAt: now, dueAt: now.Add(s.latency)})
+	s.requests = append(s.requests, b.Bytes())
+	return nil
+}
+
+// Step answers the oldest pending link if its latency elapsed, and reports whether it did.
+func (s *SimConn) Step() bool {
+	s.mu.Lock()
+	if len(s.pending) == 0 || s.pending[0].dueAt.After(s.clock.Now()) {
+		s.mu.Unlock()
+		return false
+	}
+	req := s.pending[0]
+	s.pending = s.pending[1:]
+	var failure error
+	if len(s.failNext) > 0 {
+		failure, s.failNext = s.failNext[0], s.failNext[1:]
+	}
+	s.stats.queueWaitNanos.Add(int64(s.clock.Now().Sub(req.enqueuedAt)))
+	s.mu.Unlock()
+
+	// the link is completed without holding mu, its waiter may append again right away.
+	if failure != nil {
+		req.link.Complete(failure)
+		return true
+	}
+
+	response, err := s.responder(req.request)
+	if err == nil {
+		err = req.link.Decoder().Decode(bufio.NewReader(bytes.NewReader(response)))
+	}
+	req.link.Complete(err)
+	return true
+}
+
+// Drain answers every pending link whose latency elapsed and returns how many were answered.
+func (s *SimConn) Drain() int {
+	n := 0
+	for s.Step() {
+		n++
+	}
+	return n
+}
+
+// Reorder moves the pending links so that they're answered in the given order, order[0] being the index of the link
+// to answer first. Links left out keep their relative order after the ordered ones.
+func (s *SimConn) Reorder(order ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rank := make(map[int]int, len(order))
+	for r, i := range order {
+		rank[i] = r
+	}
+	indexed := make([]int, len(s.pending))
+	for i := range indexed {
+		indexed[i] = i
+	}
+	sort.SliceStable(indexed, func(a, b int) bool {
+		ra, okA := rank[indexed[a]]
+		rb, okB := rank[indexed[b]]
+		switch {
+		case okA && okB:
+			return ra < rb
+		default:
+			return okA && !okB
+		}
+	})
+
+	reordered := make([]*simRequest, len(s.pending))
+	for i, idx := range indexed {
+		reordered[i] = s.pending[idx]
+	}
+	s.pending = reordered
+}
+
+// FailNext completes the next answered links with errs, in order, instead of asking the responder.
+func (s *SimConn) FailNext(errs ...error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failNext = append(s.failNext, errs...)
+}
+
+// Break simulates the loss of the connection: the pending links are completed with zombie link errors wrapping err,
+// and appends fail until Restore is called.
+func (s *SimConn) Break(err error) {
+	if err == nil {
+		err = errSimConnBroken
+	}
+
+	s.mu.Lock()
+	s.broken = true
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	for _, req := range pending {
+		req.link.Complete(fmt.Errorf("%w: %w", errZombieLinkOnDecoder, err))
+	}
+}
+
+// Restore reconnects a broken connection.
+func (s *SimConn) Restore() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.broken = false
+}
+
+// Pending returns the number of links waiting for their response.
+func (s *SimConn) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// Requests returns every request encoded by the connection, in the order the links were appended.
+func (s *SimConn) Requests() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]byte(nil), s.requests...)
+}
+
+func (s *SimConn) Stats() ConnStats {
+	return s.stats.snapshot()
+}
+
+// IsHealthy reports whether the connection is neither broken nor closed.
+func (s *SimConn) IsHealthy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.closed && !s.broken
+}
+
+// Close breaks the connection for good.
+func (s *SimConn) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	s.Break(errSimConnClosed)
+	s.closeOnce.Do(func() { close(s.done) })
+	return nil
+}
+
+func (s *SimConn) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *SimConn) Wait() {
+	<-s.done
+}
+
+func (s *SimConn) stateLocked() string {
+	if s.closed {
+		return "closed"
+	}
+	return "broken"
+}
@@ -0,0 +1,146 @@
+package net
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// echoEncoder writes its name as a request line.
+type echoEncoder struct {
+	name string
+}
+
+func (e *echoEncoder) Reset() {}
+
+func (e *echoEncoder) Encode(w codec.Writer) error {
+	_, err := w.WriteString(e.name + "\r\n")
+	return err
+}
+
+// echoDecoder reads a response line.
+type echoDecoder struct {
+	line string
+}
+
+func (d *echoDecoder) Reset() {}
+
+func (d *echoDecoder) Decode(r codec.Reader) error {
+	line, err := r.ReadString('\n')
+	d.line = strings.TrimSpace(line)
+	return err
+}
+
+func echoResponder(request []byte) ([]byte, error) {
+	return []byte("re:" + string(request)), nil
+}
+
+func newEchoLink(name string) (codec.Link, *echoDecoder) {
+	decoder := &echoDecoder{}
+	return codec.NewGenericLink(&echoEncoder{name: name}, decoder), decoder
+}
+
+func TestSimConnLatency(t *testing.T) {
+	clock := NewSimClock(time.Unix(0, 0))
+	conn := NewSimConn(clock, echoResponder, 0)
+	conn.SetLatency(10 * time.Millisecond)
+
+	first, firstDecoder := newEchoLink("first")
+	second, _ := newEchoLink("second")
+	require.NoError(t, conn.Append(first))
+	clock.Advance(5 * time.Millisecond)
+	require.NoError(t, conn.Append(second))
+
+	assert.False(t, conn.Step())
+	clock.Advance(5 * time.Millisecond)
+	assert.Equal(t, 1, conn.Drain())
+	<-first.Done()
+	assert.NoError(t, first.Err())
+	assert.Equal(t, "re:first", firstDecoder.line)
+	assert.Equal(t, 1, conn.Pending())
+
+	clock.Advance(5 * time.Millisecond)
+	assert.Equal(t, 1, conn.Drain())
+
+	stats := conn.Stats()
+	assert.Equal(t, uint64(2), stats.Appends)
+	assert.Equal(t, uint64(1), stats.BusyAppends)
+	assert.Equal(t, 20*time.Millisecond, stats.QueueWait)
+	assert.Equal(t, [][]byte{[]byte("first\r\n"), []byte("second\r\n")}, conn.Requests())
+}
+
+func TestSimConnReorderAndFailures(t *testing.T) {
+	conn := NewSimConn(NewSimClock(time.Unix(0, 0)), echoResponder, 2)
+
+	a, aDecoder := newEchoLink("a")
+	b, bDecoder := newEchoLink("b")
+	require.NoError(t, conn.Append(a))
+	require.NoError(t, conn.Append(b))
+	c, _ := newEchoLink("c")
+	assert.ErrorIs(t, conn.Append(c), errOutboundQueueFull)
+	assert.Equal(t, uint64(1), conn.Stats().Rejected)
+
+	conn.Reorder(1)
+	conn.FailNext(io.ErrUnexpectedEOF)
+	assert.True(t, conn.Step())
+	<-b.Done()
+	assert.ErrorIs(t, b.Err(), io.ErrUnexpectedEOF)
+	assert.Empty(t, bDecoder.line)
+
+	assert.True(t, conn.Step())
+	<-a.Done()
+	assert.Equal(t, "re:a", aDecoder.line)
+}
+
+func TestSimConnBreak(t *testing.T) {
+	conn := NewSimConn(NewSimClock(time.Unix(0, 0)), echoResponder, 0)
+
+	pending, _ := newEchoLink("pending")
+	require.NoError(t, conn.Append(pending))
+	conn.Break(nil)
+	<-pending.Done()
+	assert.ErrorIs(t, pending.Err(), errZombieLinkOnDecoder)
+
+	rejected, _ := newEchoLink("rejected")
+	assert.Error(t, conn.Append(rejected))
+
+	conn.Restore()
+	accepted, _ := newEchoLink("accepted")
+	require.NoError(t, conn.Append(accepted))
+	assert.Equal(t, 1, conn.Drain())
+
+	require.NoError(t, conn.Close())
+	assert.ErrorContains(t, conn.Append(rejected), "closed")
+}
+
+// TestSimConnListRequeue replays a connection loss while a link waits for its response, which the real connections
+// can only reproduce by sleeping.
+func TestSimConnListRequeue(t *testing.T) {
+	clock := NewSimClock(time.Unix(0, 0))
+	lost, healthy := NewSimConn(clock, echoResponder, 0), NewSimConn(clock, echoResponder, 0)
+	l := &tcpConnList{numConns: 2, conns: []TCPConn{lost, healthy}, be: &Backend{addr: &net.TCPAddr{}}}
+	l.ready.Store(true)
+
+	link, decoder := newEchoLink("retried")
+	require.NoError(t, lost.Append(link))
+	lost.Break(errors.New("connection reset"))
+	<-link.Done()
+	require.ErrorIs(t, link.Err(), errZombieLinkOnDecoder)
+
+	retry, retryDecoder := newEchoLink("retried")
+	require.NoError(t, l.requeue(0, retry))
+	assert.Zero(t, lost.Pending())
+	assert.Equal(t, 1, healthy.Drain())
+	<-retry.Done()
+	assert.NoError(t, retry.Err())
+	assert.Equal(t, "re:retried", retryDecoder.line)
+	assert.Empty(t, decoder.line)
+}
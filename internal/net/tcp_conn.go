@@ -2,11 +2,13 @@ package net
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,18 +24,28 @@ const (
 	// monitor attempts to re-establish the connection to backend if the connection is either in `ConnectFailed` or
 	// `Reconnecting` state this many times. A successful connection establishment should reset the counter.
 	monitorRoutineCycles = 1000
-	// amount of time to sleep before starting another monitor routine.
-	monitorRoutineSleep = 5 * time.Millisecond
+	// amount of time to wait before another round of connection attempts, after one failed. A lost connection is
+	// re-established right away.
+	setupRetryDelay = 5 * time.Millisecond
 	// number of attempts to establish the connection. Main connection monitoring routine will try to establish
 	// this connection several times, so if the backend is down, it's better to call `Close()` on this connection.
 	connAttemptCount = 3
 	// amount of time to wait before trying to establish a connection where it previously failed.
 	reconnectDelay = 1 * time.Millisecond
 
-	queueSize = 1000
+	// defaultOutboundQueueSize is the number of links which can wait for their request to be written.
+	defaultOutboundQueueSize = 1000
+	// the inbound queue defaults to this many times the outbound one, so that a burst of written requests whose
+	// responses are slow to be read doesn't hold the writer back.
+	defaultInboundQueueFactor = 2
 
 	// socketTimeout regardless of a request deadline.
 	socketTimeout = 5 * time.Second
+
+	// maxScratchSize bounds the scratch buffer kept by a connection between requests.
+	maxScratchSize = 64 * 1024
+	// maxDecodeBatch bounds the responses decoded back to back by HandleInbound.
+	maxDecodeBatch = 64
 )
 
 // enum represents state of the connection.
@@ -76,12 +88,34 @@ var (
 	errZombieLinkOnEncoder = errors.New("tcpConn: encoder: link was pending in the encoder channel but conn was closed before processing")
 	errZombieLinkOnDecoder = errors.New("tcpConn: decoder: link was pending in the decoder channel but conn was closed before processing")
 	errOutboundQueueFull   = errors.New("tcpConn: append: outbound channel is full and can't instantly add a new link")
+	errInboundQueueFull    = errors.New("tcpConn: encoder: inbound channel is full, the request was not written")
+)
+
+// InboundOverflowPolicy decides what happens to a link about to be written while the inbound queue, holding the links
+// waiting for their response, is full.
+type InboundOverflowPolicy int
+
+const (
+	// InboundOverflowBlock holds the link, and every link queued behind it, until a response is read.
+	InboundOverflowBlock InboundOverflowPolicy = iota
+	// InboundOverflowFail completes the link with an error without writing its request.
+	InboundOverflowFail
 )
 
 // TCPConn represents a single connection to an address.
 type TCPConn interface {
 	codec.Chain
 
+	// Stats returns the cumulative load counters of the connection.
+	Stats() ConnStats
+	// IsHealthy reports whether the connection is established, i.e. neither reconnecting nor closed.
+	IsHealthy() bool
+	// Done is closed once every routine of the connection exited, i.e. once it's closed, or gave up reconnecting, and
+	// its links were failed.
+	Done() <-chan struct{}
+	// Wait blocks until Done is closed.
+	Wait()
+
 	Close() error
 }
 
@@ -89,9 +123,17 @@ type tcpConn struct {
 	be               *Backend
 	monitorLoopCount int
 
+	// ctx is cancelled by Close, ending the current session and the reconnection attempts.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// done is closed once the manager and the routines of the sessions exited, which routines tracks besides the
+	// manager.
+	done     chan struct{}
+	routines sync.WaitGroup
+
 	mu    sync.RWMutex
 	conn  net.Conn          // protected by mu
-	state connState         // protected by mu
+	state connStateMachine  // protected by mu
 	rw    *bufio.ReadWriter // protected by mu
 
 	// outbound is a channel that handles outbound data processing using codec.Link.
@@ -107,8 +149,43 @@ type tcpConn struct {
 	// processed, maintaining data consistency and integrity.
 	inbound chan codec.Link
 
+	outboundQueueSize int
+	inboundQueueSize  int
+	inboundOverflow   InboundOverflowPolicy
+	maxInFlight       int
+	responseTimeout   time.Duration
+	maxResponseSize   int
+	compression       Compression
+	tolerantFraming   bool
+	checksumPolicy    ChecksumPolicy
+	bandwidth         *byteBucket
+	zombieLinkHook    ZombieLinkHook
+	zombieLinkPolicy  ZombieLinkPolicy
+	requeue           func(link codec.Link) error
+	gate              LinkGate
+	clock             Clock
+	rng               Rand
+
+	stats connStats
+
+	// scratch is the buffer the requests are built in before being written, only used by HandleOutbound.
+	scratch bytes.Buffer
+
 	// deadline optimization: track the current deadline to avoid unnecessary SetDeadline calls
 	currentDeadline time.Time
+	// deadline mirrors currentDeadline, in unix nanoseconds, for the routine reading the frames.
+	deadline atomic.Int64
+
+	// awaiting is the number of links of the session whose request was written but whose response wasn't decoded
+	// yet, and demand is signaled whenever a request is written. They let the routine reading the frames tell an
+	// idle connection from a backend not answering. drained is signaled whenever a response is decoded, for
+	// HandleOutbound to resume once under maxInFlight.
+	awaiting atomic.Int64
+	demand   chan struct{}
+	drained  chan struct{}
+	// lastRead is when the routine reading the frames last saw bytes arrive, in unix nanoseconds, for the response
+	// timeout watchdog. It's only maintained when the connection has a response timeout.
+	lastRead atomic.Int64
 
 	logger    *zap.Logger
 	logFields []zap.Field
@@ -116,19 +193,97 @@ type tcpConn struct {
 
 var _ TCPConn = (*tcpConn)(nil)
 
-func NewTCPConn(be *Backend, logger *zap.Logger) (TCPConn, error) {
+// ConnOption configures a connection.
+type ConnOption func(c *tcpConn)
+
+// WithQueueSizes sets the capacity of the outbound queue, holding the links waiting for their request to be written,
+// and of the inbound queue, holding the links waiting for their response. Non-positive sizes keep the defaults: 1000
+// outbound links and twice the outbound size for inbound links.
+func WithQueueSizes(outbound int, inbound int) ConnOption {
+	return func(c *tcpConn) {
+		c.outboundQueueSize = outbound
+		c.inboundQueueSize = inbound
+	}
+}
+
+// WithInboundOverflowPolicy sets what happens to a link about to be written while the inbound queue is full. It
+// defaults to InboundOverflowBlock.
+func WithInboundOverflowPolicy(policy InboundOverflowPolicy) ConnOption {
+	return func(c *tcpConn) {
+		c.inboundOverflow = policy
+	}
+}
+
+// WithClock sets the clock the connection timestamps its requests with and runs its timers on, the system clock by
+// default. Tests use a SimClock to fire the response timeouts and the reconnection delays without waiting for them.
+func WithClock(clock Clock) ConnOption {
+	return func(c *tcpConn) {
+		c.clock = clock
+	}
+}
+
+// LinkGate checks a link against the backend it's about to be queued for, and returns an error to fail it instead.
+type LinkGate func(be *Backend, link codec.Link) error
+
+// WithLinkGate makes the connection check every link appended to it with gate, before queuing it, e.g. to reject the
+// requests the backend doesn't support. The gate runs on the routine appending the link.
+func WithLinkGate(gate LinkGate) ConnOption {
+	return func(c *tcpConn) {
+		c.gate = gate
+	}
+}
+
+// WithRand sets the source of the jitter of the reconnection delays, crypto/rand by default.
+func WithRand(rng Rand) ConnOption {
+	return func(c *tcpConn) {
+		c.rng = rng
+	}
+}
+
+// WithMaxInFlight bounds the number of requests written to the connection whose response wasn't read yet, i.e. the
+// pipelining depth. Once reached, the requests wait in the outbound queue until responses drain, which bounds the
+// head-of-line blocking behind a slow response and the memory held per connection. Non-positive values don't bound
+// it, besides the inbound queue size.
+func WithMaxInFlight(n int) ConnOption {
+	return func(c *tcpConn) {
+		c.maxInFlight = n
+	}
+}
+
+func NewTCPConn(be *Backend, logger *zap.Logger, opts ...ConnOption) (TCPConn, error) {
 	c := &tcpConn{
 		be:     be,
-		state:  Unavailable,
 		logger: logger,
+		done:   make(chan struct{}),
 		logFields: []zap.Field{
 			zap.String("conn_id", uuid.NewString()),
 			zap.String("backend", be.String()),
 		},
 	}
+	c.state = newConnStateMachine(Unavailable, c.logTransition)
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.outboundQueueSize <= 0 {
+		c.outboundQueueSize = defaultOutboundQueueSize
+	}
+	if c.clock == nil {
+		c.clock = SystemClock
+	}
+	if c.rng == nil {
+		c.rng = SystemRand
+	}
+	if c.inboundQueueSize <= 0 {
+		c.inboundQueueSize = defaultInboundQueueFactor * c.outboundQueueSize
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
 
 	err := c.setup()
 	if err != nil {
+		c.cancel()
+		close(c.done)
 		return nil, err
 	}
 
@@ -146,15 +301,29 @@ func NewTCPConn(be *Backend, logger *zap.Logger) (TCPConn, error) {
 }
 
 func (c *tcpConn) Append(link codec.Link) (err error) {
+	if c.gate != nil {
+		if err := c.gate(c.be, link); err != nil {
+			return err
+		}
+	}
 	if c.mu.TryRLock() {
-		if c.state == Connected {
+		if c.state.is(Connected) {
+			busy := len(c.outbound)+len(c.inbound) > 0
+			if recorder, ok := link.(codec.BackendRecorder); ok {
+				recorder.SetBackend(c.be.String())
+			}
 			select {
-			case c.outbound <- link:
+			case c.outbound <- &queuedLink{Link: link, enqueuedAt: c.clock.Now()}:
+				c.stats.appends.Add(1)
+				if busy {
+					c.stats.busyAppends.Add(1)
+				}
 			default:
+				c.stats.rejected.Add(1)
 				err = errOutboundQueueFull
 			}
 		} else {
-			err = fmt.Errorf("cannot append link, connection to %s is in %s, not connected state", c.be.String(), c.state)
+			err = fmt.Errorf("cannot append link, connection to %s is in %s, not connected state", c.be.String(), c.state.current())
 		}
 		c.mu.RUnlock()
 	} else {
@@ -163,28 +332,199 @@ func (c *tcpConn) Append(link codec.Link) (err error) {
 	return
 }
 
+// HandleInbound decodes the responses of the links in the inbound queue. The responses are read off the socket by a
+// routine of their own, which splits them into frames ahead of the decoders, so that a slow decoder doesn't hold the
+// socket reads.
 func (c *tcpConn) HandleInbound(ctx context.Context) error {
 	c.logger.Debug("HandleInbound is starting", c.logFields...)
 
+	readCtx, stopReading := context.WithCancel(ctx)
+	frames := newFrameReader(c.inboundQueueSize)
+	c.routines.Add(1)
+	go func() {
+		defer c.routines.Done()
+		c.readFrames(readCtx, frames)
+	}()
+	defer func() {
+		stopReading()
+		c.interruptRead()
+		frames.discard()
+	}()
+	reader := bufio.NewReader(frames)
+
 	for {
+		var link codec.Link
+		var ok bool
 		select {
 		case <-ctx.Done():
 			c.logger.Debug("HandleInbound is closing due to ctx.Done()", c.logFields...)
 			return nil
-		case link, ok := <-c.inbound:
-			if !ok {
-				c.logger.Debug("HandleInbound is closing due to inbound channel not being open", c.logFields...)
-				return nil
+		case link, ok = <-c.inbound:
+		case <-frames.stopped:
+			// the links already queued are decoded first, from the frames read before the socket failed.
+			select {
+			case link, ok = <-c.inbound:
+			default:
+				c.logger.Debug("HandleInbound is closing as the socket can't be read anymore", c.logFields...)
+				return frames.err
 			}
+		}
+		if !ok {
+			c.logger.Debug("HandleInbound is closing due to inbound channel not being open", c.logFields...)
+			return nil
+		}
 
-			err := link.Decoder().Decode(c.rw.Reader)
-			if err != nil {
-				link.Complete(fmt.Errorf("HandleInbound: error trying to read response from %s backend: %w", c.be.String(), err))
-				return err
+		if err := c.decode(link, reader); err != nil {
+			return err
+		}
+		if err := c.decodeBuffered(reader, frames); err != nil {
+			return err
+		}
+	}
+}
+
+// decodeBuffered decodes back to back the responses already read off the socket whose links are queued, sparing
+// HandleInbound a select per response under pipelined load. It stops after maxDecodeBatch responses, for HandleInbound
+// to check whether the session ended.
+func (c *tcpConn) decodeBuffered(reader *bufio.Reader, frames *frameReader) error {
+	for i := 0; i < maxDecodeBatch && (reader.Buffered() > 0 || len(frames.frames) > 0); i++ {
+		var link codec.Link
+		select {
+		case link = <-c.inbound:
+		default:
+		}
+		// a closed inbound queue is left to HandleInbound.
+		if link == nil {
+			return nil
+		}
+
+		c.stats.batchedDecodes.Add(1)
+		if err := c.decode(link, reader); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *tcpConn) decode(link codec.Link, reader *bufio.Reader) error {
+	defer func() {
+		c.awaiting.Add(-1)
+		select {
+		case c.drained <- struct{}{}:
+		default:
+		}
+	}()
+
+	watch := c.watchResponse(link)
+	err := c.skipUnclaimed(link, reader)
+	if err == nil {
+		err = link.Decoder().Decode(reader)
+	}
+	if watch.stop() {
+		// the read failed because the watchdog closed the socket.
+		timeoutErr := c.responseTimeoutErr(link)
+		link.Complete(timeoutErr)
+		return timeoutErr
+	}
+	if err != nil {
+		link.Complete(fmt.Errorf("HandleInbound: error trying to read response from %s backend: %w", c.be.String(), err))
+		return err
+	}
+	link.Complete(nil)
+	return nil
+}
+
+// readFrames reads the responses off the socket into frames until ctx is done or the socket fails.
+func (c *tcpConn) readFrames(ctx context.Context, frames *frameReader) {
+	for {
+		_, err := c.rw.Reader.Peek(1)
+		if err == nil && c.responseTimeout > 0 {
+			c.lastRead.Store(c.clock.Now().UnixNano())
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				frames.stop(nil)
+				return
+			}
+			if isTimeout(err) && c.resumeAfterTimeout(ctx) {
+				continue
 			}
-			link.Complete(nil)
+			frames.stop(err)
+			return
 		}
+
+		f := framePool.Get()
+		if err := readFrame(c.rw.Reader, f, c.responseSizeLimit()); err != nil {
+			releaseFrame(f)
+			var tooLarge *ResponseTooLargeError
+			if errors.As(err, &tooLarge) {
+				tooLarge.Backend = c.be.String()
+				c.stats.oversizedResponses.Add(1)
+			}
+			if ctx.Err() != nil {
+				err = nil
+			}
+			frames.stop(err)
+			return
+		}
+		if c.tolerantFraming {
+			if err := stripAnnotations(f, c.checksumPolicy); err != nil {
+				releaseFrame(f)
+				c.stats.checksumFailures.Add(1)
+				frames.stop(err)
+				return
+			}
+		}
+
+		select {
+		case frames.frames <- f:
+		case <-ctx.Done():
+			releaseFrame(f)
+			frames.stop(nil)
+			return
+		}
+	}
+}
+
+// resumeAfterTimeout is called when waiting for a response timed out, and reports whether to keep reading: either a
+// request written meanwhile extended the deadline, or no response is awaited and a request was written since.
+func (c *tcpConn) resumeAfterTimeout(ctx context.Context) bool {
+	if c.deadline.Load() > time.Now().UnixNano() {
+		return true
+	}
+	if c.awaiting.Load() > 0 {
+		return false
+	}
+
+	select {
+	case <-c.demand:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// interruptRead unblocks the routine reading the frames when it's waiting on the socket.
+func (c *tcpConn) interruptRead() {
+	if c.conn != nil {
+		_ = c.conn.SetReadDeadline(time.Now())
+	}
+}
+
+// skipUnclaimed drops the responses which don't belong to the link, when its request can tell, so that they aren't
+// decoded as its response.
+func (c *tcpConn) skipUnclaimed(link codec.Link, reader *bufio.Reader) error {
+	claimer, ok := link.Encoder().(codec.ResponseClaimer)
+	if !ok {
+		return nil
 	}
+
+	skipped, err := claimer.SkipUnclaimed(reader)
+	for _, hdrLine := range skipped {
+		c.stats.unclaimedResponses.Add(1)
+		c.logger.Warn("dropping a response no pending request claims", append(c.logFields, zap.String("header", hdrLine))...)
+	}
+	return err
 }
 
 func (c *tcpConn) HandleOutbound(ctx context.Context) error {
@@ -201,63 +541,153 @@ func (c *tcpConn) HandleOutbound(ctx context.Context) error {
 				return nil
 			}
 
+			if ql, ok := link.(*queuedLink); ok {
+				c.stats.queueWaitNanos.Add(int64(c.clock.Now().Sub(ql.enqueuedAt)))
+			}
+
+			// only this routine publishes to inbound, so there is still room after writing the request when there is
+			// room now. Failing after writing it would desynchronize the responses from the links.
+			if c.inboundOverflow == InboundOverflowFail && len(c.inbound) == cap(c.inbound) {
+				c.stats.inboundOverflows.Add(1)
+				link.Complete(errInboundQueueFull)
+				continue
+			}
+
+			if !c.waitInFlight(ctx) {
+				c.logger.Debug("HandleOutbound is closing due to ctx.Done() while waiting for responses to drain", c.logFields...)
+				link.Complete(c.zombieLinkErr(link, false, c.clock.Now()))
+				return nil
+			}
+
 			if err := c.setDeadlineIfNeeded(); err != nil {
 				link.Complete(fmt.Errorf("HandleOutbound: error setting deadline for %s backend: %w", c.be.String(), err))
 				return err
 			}
 
-			if err := link.Encoder().Encode(c.rw.Writer); err != nil {
+			err := codec.Encode(link.Encoder(), c.rw.Writer, &c.scratch)
+			if c.scratch.Cap() > maxScratchSize {
+				// don't pin the buffer grown by a large value.
+				c.scratch = bytes.Buffer{}
+			}
+			if err != nil {
 				link.Complete(fmt.Errorf("HandleOutbound: error trying to serialize request to a Writer on the %s backend: %w", c.be.String(), err))
 				return err
 			}
 
+			if ql, ok := link.(*queuedLink); ok && c.responseTimeout > 0 {
+				// no response can arrive before the end of the request is flushed.
+				ql.writtenAt = c.clock.Now()
+			}
 			if flushErr := c.rw.Flush(); flushErr != nil {
 				link.Complete(fmt.Errorf("HandleOutbound: error trying to flush request to %s backend: %w", c.be.String(), flushErr))
 				return flushErr
 			}
 
+			c.awaiting.Add(1)
+			select {
+			case c.demand <- struct{}{}:
+			default:
+			}
+
 			// only add the decoder after the message is safely written through the encoder.
 			// we don't need any synchronization primitives as there's just 1 goroutine writing first
 			// to the outbound connection and then to the `c.inbound` channel.
 			select {
+			case c.inbound <- link:
+				continue
+			default:
+			}
+
+			// the responses are read slower than the requests are written, wait for HandleInbound to catch up.
+			c.stats.inboundOverflows.Add(1)
+			select {
 			case c.inbound <- link:
 			case <-ctx.Done():
 				c.logger.Debug("HandleOutbound is closing due to ctx.Done() while attempting to write to inbound", c.logFields...)
+				// the request was written, the link must not be left without a response.
+				c.awaiting.Add(-1)
+				link.Complete(c.zombieLinkErr(link, true, c.clock.Now()))
 				return nil
 			}
 		}
 	}
 }
 
+// waitInFlight waits until fewer than maxInFlight requests are in flight, and reports whether ctx didn't end first.
+func (c *tcpConn) waitInFlight(ctx context.Context) bool {
+	if c.maxInFlight <= 0 || c.awaiting.Load() < int64(c.maxInFlight) {
+		return true
+	}
+
+	c.stats.inFlightLimitWaits.Add(1)
+	for c.awaiting.Load() >= int64(c.maxInFlight) {
+		select {
+		case <-c.drained:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+func (c *tcpConn) Stats() ConnStats {
+	return c.stats.snapshot()
+}
+
+// Close terminates the connection: the current session ends, the links still queued are failed and no reconnection
+// is attempted anymore.
 func (c *tcpConn) Close() error {
 	c.logger.Info("received signal to close connection", c.logFields...)
-	c.transitionState(Terminated)
-	close(c.outbound)
-	return c.closeConn()
+	_ = c.transitionState(Terminated)
+	err := c.closeConn()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	return err
+}
+
+func (c *tcpConn) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *tcpConn) Wait() {
+	<-c.done
 }
 
 func (c *tcpConn) closeConn() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	return c.conn.Close()
+	if err := c.conn.Close(); !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+	return nil
 }
-func (c *tcpConn) transitionState(state connState) {
+
+// transitionState moves the connection to state, unless the transition is illegal, e.g. out of Terminated.
+func (c *tcpConn) transitionState(state connState) error {
 	c.mu.Lock()
-	c.logger.Info(fmt.Sprintf("transitioning the state to %s", state), c.logFields...)
-	c.state = state
-	c.mu.Unlock()
+	defer c.mu.Unlock()
+	return c.state.transition(state)
+}
+
+func (c *tcpConn) logTransition(from, to connState) {
+	c.logger.Info(fmt.Sprintf("transitioning the state from %s to %s", from, to), c.logFields...)
+}
+
+func (c *tcpConn) IsHealthy() bool {
+	return c.isConnected()
 }
 
 func (c *tcpConn) isTerminated() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.state == Terminated
+	return c.state.is(Terminated)
 }
 
 func (c *tcpConn) isConnected() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.state == Connected
+	return c.state.is(Connected)
 }
 
 // setDeadlineIfNeeded sets the connection deadline only if it's not already set
@@ -275,93 +705,164 @@ func (c *tcpConn) setDeadlineIfNeeded() error {
 			return err
 		}
 		c.currentDeadline = targetDeadline
+		c.deadline.Store(targetDeadline.UnixNano())
 	}
 
 	return nil
 }
 
-// manager starts HandleInbound() and HandleOutbound() methods only if there's an active connection. If either
-// of those routines return errors due to connection failures (or without) then manager would reset the connection
-// and restart the routines unless the connection is Terminated().
+// manager runs a session, i.e. HandleInbound() and HandleOutbound(), for as long as the connection is established.
+// A session has its own context, derived from the connection's one: it's cancelled as soon as either routine returns,
+// on an IO error, or when the connection is closed, which also closes the socket so that a routine blocked on it
+// returns promptly. The manager then fails the links left in the queues and reconnects right away, unless the
+// connection is Terminated().
 func (c *tcpConn) manager(started func()) {
-	for ; c.monitorLoopCount < monitorRoutineCycles; c.monitorLoopCount++ {
-		if c.isTerminated() {
-			c.logger.Debug("Manager routine will quit attempting as connection is closed", c.logFields...)
-			return
-		}
+	defer func() {
+		c.routines.Wait()
+		close(c.done)
+	}()
 
+	var setupErr error
+	for ; c.monitorLoopCount < monitorRoutineCycles; c.monitorLoopCount++ {
 		if c.isConnected() {
-			c.logger.Debug("Starting errgroup with HandleInbound and HandleOutbound routines", c.logFields...)
-			eg, _ := utils.NewSyncErrGroup(context.Background())
-			eg.Go(c.HandleInbound)
-			eg.Go(c.HandleOutbound)
-			started()
-			_ = eg.Wait()
+			c.runSession(started)
 		}
 
-		// Once a connection is terminated, the context would be done and we should still clear out the
-		// zombie connection on the list. This results in more mutex accesses than I like, but there doesn't
-		// seem to be a good way around this. Transitioning the state to Reconnecting helps prevent new request
-		// to be enqueued to this connection.
-		if !c.isTerminated() {
-			c.transitionState(Reconnecting)
-		}
+		// Transitioning the state to Reconnecting prevents new requests from being enqueued to this connection
+		// while the zombie links are drained. It's rejected once the connection is terminated, which must stay so.
+		_ = c.transitionState(Reconnecting)
+		c.drainZombieLinks()
 
-		// drain zombie link before resetting the channels.
-		c.mu.Lock()
-		pendingOutboundLinks := len(c.outbound)
-		for i := 0; i < pendingOutboundLinks; i++ {
-			link := <-c.outbound
-			link.Complete(errZombieLinkOnEncoder)
+		if c.ctx.Err() != nil {
+			c.logger.Debug("Manager routine is exiting after cleaning up the zombie links in queue", c.logFields...)
+			return
 		}
 
-		pendingInboundLinks := len(c.inbound)
-		for i := 0; i < pendingInboundLinks; i++ {
-			link := <-c.inbound
-			link.Complete(errZombieLinkOnDecoder)
+		if setupErr != nil && !c.wait(setupRetryDelay) {
+			return
 		}
-		c.mu.Unlock()
+		setupErr = c.setup()
+	}
 
-		if c.isTerminated() {
-			c.logger.Debug("Manager routine is exiting after cleaning up the zombie links in queue", c.logFields...)
-			return
+	c.logger.Error("Monitor loop giving up on trying to connect to backend.", c.logFields...)
+}
+
+// runSession serves the established connection until the session context is cancelled.
+func (c *tcpConn) runSession(started func()) {
+	c.logger.Debug("Starting errgroup with HandleInbound and HandleOutbound routines", c.logFields...)
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+
+	sessionCtx, stopSession := context.WithCancel(c.ctx)
+	defer stopSession()
+	// unblock the routine reading or writing the socket as soon as the session ends.
+	stop := context.AfterFunc(sessionCtx, func() {
+		_ = conn.Close()
+	})
+	defer stop()
+
+	eg, _ := utils.NewSyncErrGroup(sessionCtx)
+	eg.Go(func(ctx context.Context) error {
+		defer stopSession()
+		return c.HandleInbound(ctx)
+	})
+	eg.Go(func(ctx context.Context) error {
+		defer stopSession()
+		return c.HandleOutbound(ctx)
+	})
+	started()
+	_ = eg.Wait()
+	// the session context is cancelled, the socket is being closed, which stops the routine decompressing it.
+	awaitConnRoutines(conn)
+}
+
+// drainZombieLinks fails, or retries elsewhere, the links left in the queues by the last session.
+func (c *tcpConn) drainZombieLinks() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	pendingOutboundLinks := len(c.outbound)
+	for i := 0; i < pendingOutboundLinks; i++ {
+		link := <-c.outbound
+		if c.retryZombieLink(link) {
+			continue
 		}
+		link.Complete(c.zombieLinkErr(link, false, now))
+	}
 
-		time.Sleep(monitorRoutineSleep)
-		_ = c.setup()
+	pendingInboundLinks := len(c.inbound)
+	for i := 0; i < pendingInboundLinks; i++ {
+		link := <-c.inbound
+		if c.retryZombieLink(link) {
+			continue
+		}
+		link.Complete(c.zombieLinkErr(link, true, now))
 	}
+}
 
-	c.logger.Error("Monitor loop giving up on trying to connect to backend.", c.logFields...)
+// wait sleeps for d, lengthened by a random jitter, unless the connection is closed meanwhile, and reports whether it
+// wasn't.
+func (c *tcpConn) wait(d time.Duration) bool {
+	timer := c.clock.NewTimer(jitter(c.rng, d))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
 }
 
 func (c *tcpConn) setup() error {
 	var lastConnErr error
 	for i := 0; i < connAttemptCount; i++ {
 		c.logger.Debug("Trying to establish connection to backend", append(c.logFields, zap.Int("attempt", i))...)
-		conn, err := dial(context.Background(), c.be.addr, c.be.tlsConfig)
+		conn, err := dial(c.ctx, c.be.addr, c.be.tlsConfig)
+		if err == nil {
+			rawConn := conn
+			if conn, err = c.negotiateCompression(rawConn); err != nil {
+				_ = rawConn.Close()
+			}
+		}
 		if err != nil {
 			lastConnErr = err
-			time.Sleep(reconnectDelay)
+			if !c.wait(reconnectDelay) {
+				return err
+			}
 			continue
 		}
 
 		rw := bufio.NewReadWriter(
 			bufio.NewReader(conn),
-			bufio.NewWriter(conn))
+			bufio.NewWriter(c.writer(conn)))
 
 		c.logger.Debug("Successfully established a connection", c.logFields...)
 		c.mu.Lock()
-		c.inbound = make(chan codec.Link, queueSize)
-		c.outbound = make(chan codec.Link, queueSize)
+		// the connection may have been closed while dialing.
+		if err := c.state.transition(Connected); err != nil {
+			c.mu.Unlock()
+			_ = conn.Close()
+			awaitConnRoutines(conn)
+			return err
+		}
+		c.inbound = make(chan codec.Link, c.inboundQueueSize)
+		c.outbound = make(chan codec.Link, c.outboundQueueSize)
 		c.conn = conn
 		c.rw = rw
 		c.currentDeadline = time.Time{}
-		c.state = Connected
+		c.deadline.Store(0)
+		c.awaiting.Store(0)
+		c.lastRead.Store(0)
+		c.demand = make(chan struct{}, 1)
+		c.drained = make(chan struct{}, 1)
 		c.monitorLoopCount = 0
 		c.mu.Unlock()
 		return nil
 	}
 
-	c.transitionState(ConnectFailed)
+	_ = c.transitionState(ConnectFailed)
 	return lastConnErr
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"slices"
 	"sync/atomic"
 
 	"github.com/google/uuid"
@@ -20,6 +21,13 @@ var errBackendUnhealthy = errors.New("connection to backend is unhealthy")
 type TCPConnList interface {
 	codec.Chain
 
+	// Stats returns the load counters summed over every connection of the list.
+	Stats() ConnStats
+	// HealthyConns returns the number of connections of the list which are established.
+	HealthyConns() int
+	// Conns returns a copy of the connections of the list.
+	Conns() []TCPConn
+
 	Close() error
 }
 
@@ -32,6 +40,8 @@ type tcpConnList struct {
 	// In a given tcpConnList, the traffic is currently sent randomly, without alternate load-balancing policies.
 	conns   []TCPConn
 	iterIdx uint64
+	// ready is set once conns is complete, the connections may requeue links as soon as they're created.
+	ready atomic.Bool
 
 	logFields []zapcore.Field
 	logger    *zap.Logger
@@ -62,18 +72,69 @@ func (t *tcpConnList) Append(link codec.Link) error {
 	return fmt.Errorf("backend=%s attempts=%d error=%w", t.be.String(), t.numConns, errBackendUnhealthy)
 }
 
+// requeue appends the zombie link of the connection from to another connection of the list.
+func (t *tcpConnList) requeue(from int, link codec.Link) error {
+	if !t.ready.Load() {
+		return errBackendUnhealthy
+	}
+
+	for i := uint64(1); i < t.numConns; i++ {
+		target := (uint64(from) + i) % t.numConns
+		if err := t.conns[target].Append(link); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("backend=%s error=%w", t.be.String(), errBackendUnhealthy)
+}
+
+func (t *tcpConnList) Stats() ConnStats {
+	stats := ConnStats{}
+	for _, conn := range t.conns {
+		stats = stats.add(conn.Stats())
+	}
+	return stats
+}
+
+func (t *tcpConnList) HealthyConns() int {
+	n := 0
+	for _, conn := range t.conns {
+		if conn.IsHealthy() {
+			n++
+		}
+	}
+	return n
+}
+
+func (t *tcpConnList) Conns() []TCPConn {
+	return slices.Clone(t.conns)
+}
+
 var _ TCPConnList = (*tcpConnList)(nil)
 
 // NewTCPConnectionList establishes connection to the given backend. Backend can contain the optional tlsConfig and the
-// number of connections to create to that backend.
-func NewTCPConnectionList(b *Backend, logger *zap.Logger) (TCPConnList, error) {
+// number of connections to create to that backend, opts configure every connection.
+func NewTCPConnectionList(b *Backend, logger *zap.Logger, opts ...ConnOption) (TCPConnList, error) {
 	// if less than 1 connection is requested, we default to 1
 	numConns := int(math.Max(1, float64(b.numConns)))
 
+	l := &tcpConnList{
+		numConns: uint64(numConns),
+		be:       b,
+		logger:   logger,
+		logFields: []zap.Field{
+			zap.String("list_id", uuid.NewString()),
+			zap.String("backend", b.String()),
+		},
+	}
+
 	connList := make([]TCPConn, 0, numConns)
 
 	for i := 0; i < numConns; i++ {
-		conn, err := NewTCPConn(b, logger)
+		connOpts := append(opts[:len(opts):len(opts)], withRequeue(func(link codec.Link) error {
+			return l.requeue(i, link)
+		}))
+		conn, err := NewTCPConn(b, logger, connOpts...)
 		if err != nil {
 			return nil, err
 		}
@@ -81,16 +142,8 @@ func NewTCPConnectionList(b *Backend, logger *zap.Logger) (TCPConnList, error) {
 		connList = append(connList, conn)
 	}
 
-	l := &tcpConnList{
-		numConns: uint64(numConns),
-		conns:    connList,
-		be:       b,
-		logger:   logger,
-		logFields: []zap.Field{
-			zap.String("list_id", uuid.NewString()),
-			zap.String("backend", b.String()),
-		},
-	}
+	l.conns = connList
+	l.ready.Store(true)
 
 	logger.Debug("Initialized connection list to backend", l.logFields...)
 
@@ -22,6 +22,10 @@ func (m *MockTCPConn) Append(link codec.Link) error {
 	return args.Error(0)
 }
 
+func (m *MockTCPConn) Stats() ConnStats {
+	return ConnStats{}
+}
+
 func (m *MockTCPConn) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -31,6 +35,15 @@ func (m *MockTCPConn) IsHealthy() bool {
 	return true
 }
 
+// Done is closed from the start, a MockTCPConn runs no routine.
+func (m *MockTCPConn) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+func (m *MockTCPConn) Wait() {}
+
 func TestNewTCPConnections(t *testing.T) {
 	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
 	listener, _ := net.Listen("tcp", "localhost:11211")
@@ -145,3 +158,35 @@ func TestCloseConnectionsSuccessfully(t *testing.T) {
 	mockConn1.AssertCalled(t, "Close")
 	mockConn2.AssertCalled(t, "Close")
 }
+
+func TestRequeue(t *testing.T) {
+	link := &LinkMock{}
+	be := &Backend{addr: &net.TCPAddr{}}
+
+	t.Run("skips the source connection", func(t *testing.T) {
+		source, full, healthy := &MockTCPConn{}, &MockTCPConn{}, &MockTCPConn{}
+		full.On("Append", link).Return(errOutboundQueueFull)
+		healthy.On("Append", link).Return(nil)
+		l := &tcpConnList{numConns: 3, conns: []TCPConn{source, full, healthy}, be: be}
+		l.ready.Store(true)
+
+		assert.NoError(t, l.requeue(0, link))
+		source.AssertNotCalled(t, "Append", link)
+		full.AssertCalled(t, "Append", link)
+		healthy.AssertCalled(t, "Append", link)
+	})
+
+	t.Run("fails without another connection", func(t *testing.T) {
+		source := &MockTCPConn{}
+		l := &tcpConnList{numConns: 1, conns: []TCPConn{source}, be: be}
+		l.ready.Store(true)
+
+		assert.ErrorIs(t, l.requeue(0, link), errBackendUnhealthy)
+		source.AssertNotCalled(t, "Append", link)
+	})
+
+	t.Run("fails while the list is built", func(t *testing.T) {
+		l := &tcpConnList{numConns: 2, be: be}
+		assert.ErrorIs(t, l.requeue(0, link), errBackendUnhealthy)
+	})
+}
@@ -1,10 +1,13 @@
 package net
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math/rand/v2"
 	"slices"
 	"sync"
+	"sync/atomic"
 
 	"github.com/andrew-d/csmrand"
 	"github.com/google/uuid"
@@ -15,30 +18,183 @@ import (
 
 var errEmptyConnPool = errors.New("tcpConnPool: empty connection pool")
 var errConnPoolExhausted = errors.New("tcpConnPool: exhausted entire connection pool trying to append link")
+var errBackendNotInPool = errors.New("tcpConnPool: backend is not part of the connection pool")
+var errConnPoolClosed = errors.New("tcpConnPool: connection pool is closed")
 
 // TCPConnPool is the ultimate pool which can submit a request to any target address in the connection pool
 type TCPConnPool interface {
 	Add(be *Backend) error
 	Remove(be *Backend) error
 
+	// Backends returns a copy of the list of backends currently in the pool.
+	Backends() []*Backend
+	// AppendTo schedules the link on the given backend, bypassing the HasherFn. It's meant for requests which need
+	// to reach a specific server, e.g. admin commands or keys whose location is already known.
+	AppendTo(be *Backend, link codec.Link) error
+	// AppendAdmin schedules the link on a dedicated connection to the given backend, opened on first use, so that
+	// long-running admin commands don't block the requests sent on the data connections.
+	AppendAdmin(be *Backend, link codec.Link) error
+	// AppendIsolated schedules the link on a connection to the given backend opened for it alone, and closed once the
+	// link completes or ctx is done, so that a response the link doesn't read entirely can't be decoded for another
+	// request.
+	AppendIsolated(ctx context.Context, be *Backend, link codec.Link) error
+	// Stats returns the cumulative load counters of the connections to every backend, keyed by backend address.
+	Stats() map[string]ConnStats
+	// Recommendation suggests a number of connections per backend based on the load observed since the last call.
+	Recommendation() []ConnRecommendation
+	// Handover exports the placement of the pool and the load of its backends, for a replacement pool to start from.
+	Handover() Handover
+	// Topology returns a snapshot of the backends of the pool, in placement order, and of their connections.
+	Topology() Topology
+	// WarmUp waits for every connection of the pool to be established and to answer probe, until ctx is done.
+	WarmUp(ctx context.Context, probe ProbeFn) []BackendReadiness
+
 	codec.Chain
 	Close()
+	// Done is closed once the pool is closed and the routines of every connection it opened, including the ones of
+	// removed backends, exited.
+	Done() <-chan struct{}
+	// Wait blocks until Done is closed.
+	Wait()
 }
 
 type tcpConnPool struct {
-	mu            sync.RWMutex
-	backends      []*Backend             // protected by mu
-	cm            map[string]TCPConnList // protected by mu
-	maxIdxForHash int                    // protected by mu
-
-	hashFn HasherFn
+	mu       sync.RWMutex
+	backends []*Backend             // protected by mu
+	cm       map[string]TCPConnList // protected by mu
+	// routing is the epoch the requests are routed with, a snapshot of backends and cm replaced under mu whenever they
+	// change so that appending takes no lock.
+	routing atomic.Pointer[poolRouting]
+	// version is incremented whenever a backend is added or removed, i.e. whenever the placement of the keys changes.
+	version uint64 // protected by mu
+	// retired holds the connections of the removed backends, which Done waits for.
+	retired []TCPConn // protected by mu
+
+	hashFn   HasherFn
+	rng      Rand
+	connOpts []ConnOption
+
+	adminMu     sync.Mutex
+	admin       map[string]TCPConn // protected by adminMu
+	adminClosed bool               // protected by adminMu
+	// isolated are the connections opened by AppendIsolated whose link didn't complete yet, and isolatedConns tracks
+	// their routines until the connections are closed.
+	isolated      map[TCPConn]struct{} // protected by adminMu
+	isolatedConns sync.WaitGroup
+
+	recMu     sync.Mutex
+	lastStats map[string]ConnStats // protected by recMu
+
+	doneOnce  sync.Once
+	closeOnce sync.Once
+	done      chan struct{}
 
 	logger    *zap.Logger
 	logFields []zap.Field
 }
 
-func (t *tcpConnPool) beKey(idx int) string {
-	return t.backends[idx].String()
+// routingShards is the number of counters the appends using a routing epoch are spread over, so that concurrent
+// appends seldom update the same one.
+const routingShards = 16
+
+// poolRouting is a routing epoch, an immutable snapshot of the backends of a pool and of their connections. An epoch is
+// retired once replaced, and drained once the appends which started with it returned, so that the connections it
+// holds can be closed.
+type poolRouting struct {
+	// addrs and lists hold the addresses and the connections of every backend, in placement order.
+	addrs  []string
+	lists  []TCPConnList
+	byAddr map[string]TCPConnList
+	// slots are the WeightedSlots of the backends, nil if they all have the same weight.
+	slots []int
+
+	// appending counts the appends using the epoch, each on the shard it acquired the epoch with.
+	appending [routingShards]routingCounter
+	// retired is set once the epoch is replaced, and drained closed once it's retired and no append uses it anymore.
+	retired     atomic.Bool
+	drainedOnce sync.Once
+	drained     chan struct{}
+}
+
+type routingCounter struct {
+	n atomic.Int64
+	// keeps the counters on their own cache lines.
+	_ [56]byte
+}
+
+// drain retires the epoch and waits for the appends using it to return. They don't block, they only queue links. It
+// must not be called with mu held, so that the appends acquiring the epoch under it aren't waited for.
+func (r *poolRouting) drain() {
+	if r == nil {
+		return
+	}
+	r.retired.Store(true)
+	if r.idle() {
+		r.drainedOnce.Do(func() { close(r.drained) })
+	}
+	<-r.drained
+}
+
+// idle reports whether no append uses the epoch. Every append counts itself on a single shard, so a shard found at 0
+// can only be counted again by the appends which will find the epoch retired and release it right away.
+func (r *poolRouting) idle() bool {
+	for i := range r.appending {
+		if r.appending[i].n.Load() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// publishRouting replaces the routing epoch with a snapshot of backends and cm, and returns the retired one, nil if
+// there was none. Once the retired epoch is drained, no append reaches a connection list missing from them. It must be
+// called with mu held, and the retired epoch drained once mu is released.
+func (t *tcpConnPool) publishRouting() *poolRouting {
+	r := &poolRouting{
+		addrs:   make([]string, 0, len(t.backends)),
+		lists:   make([]TCPConnList, 0, len(t.backends)),
+		byAddr:  make(map[string]TCPConnList, len(t.cm)),
+		drained: make(chan struct{}),
+	}
+	for _, be := range t.backends {
+		r.addrs = append(r.addrs, be.String())
+		r.lists = append(r.lists, t.cm[be.String()])
+	}
+	for addr, cl := range t.cm {
+		r.byAddr[addr] = cl
+	}
+	r.slots = BackendSlots(t.backends)
+	return t.routing.Swap(r)
+}
+
+// acquire returns the current routing epoch, which isn't drained before release is called with the returned shard.
+func (t *tcpConnPool) acquire() (*poolRouting, int) {
+	shard := rand.IntN(routingShards)
+	for {
+		r := t.routing.Load()
+		if r == nil {
+			t.mu.Lock()
+			if t.routing.Load() == nil {
+				t.publishRouting()
+			}
+			t.mu.Unlock()
+			continue
+		}
+		r.appending[shard].n.Add(1)
+		// the epoch may have been retired, and found drained, before it was counted.
+		if t.routing.Load() == r {
+			return r, shard
+		}
+		r.release(shard)
+	}
+}
+
+func (r *poolRouting) release(shard int) {
+	r.appending[shard].n.Add(-1)
+	// the last append using a retired epoch wakes up drain.
+	if r.retired.Load() && r.idle() {
+		r.drainedOnce.Do(func() { close(r.drained) })
+	}
 }
 
 func (t *tcpConnPool) Remove(be *Backend) error {
@@ -51,20 +207,23 @@ func (t *tcpConnPool) Remove(be *Backend) error {
 		return fmt.Errorf("%v backend not found in the list of connection", be)
 	}
 
-	cl := t.cm[t.beKey(idx)]
+	cl := t.cm[be.String()]
 	t.backends = slices.Delete(t.backends, idx, idx+1)
 	delete(t.cm, be.addr.String())
-	t.maxIdxForHash--
+	t.version++
+	t.retired = append(t.retired, cl.Conns()...)
+	routing := t.publishRouting()
 	t.mu.Unlock()
+	routing.drain()
 
-	// cl.Close() call will wait for all the pending requests to complete before attempting to close
-	// them, so before we close that we are making sure that there are no new requests issued to the backends.
-	return cl.Close()
+	// cl.Close() call will wait for all the pending requests to complete before attempting to close them, and the
+	// routing epoch holding cl was drained, so no new request reaches it.
+	return errors.Join(cl.Close(), t.closeAdminConn(be.String()))
 }
 
 func (t *tcpConnPool) Add(be *Backend) error {
 	t.logger.Info(fmt.Sprintf("Adding a new connection to %s backend", be.String()), t.logFields...)
-	cl, err := NewTCPConnectionList(be, t.logger)
+	cl, err := NewTCPConnectionList(be, t.logger, t.connOpts...)
 	if err != nil {
 		return err
 	}
@@ -72,8 +231,10 @@ func (t *tcpConnPool) Add(be *Backend) error {
 	t.mu.Lock()
 	t.backends = append(t.backends, be)
 	t.cm[be.String()] = cl
-	t.maxIdxForHash++
+	t.version++
+	routing := t.publishRouting()
 	t.mu.Unlock()
+	routing.drain()
 	return nil
 }
 
@@ -91,17 +252,31 @@ func WithConnPoolHashFn(fn HasherFn) ConnPoolOptions {
 	}
 }
 
+// WithConnPoolRand sets the source of randomness of the pool, crypto/rand by default. Unless a HasherFn is set, the
+// requests are spread across the backends with it, see NewRandomHashFn.
+func WithConnPoolRand(rng Rand) ConnPoolOptions {
+	return func(pool *tcpConnPool) {
+		pool.rng = rng
+	}
+}
+
 func WithConnPoolLogger(logger *zap.Logger) ConnPoolOptions {
 	return func(pool *tcpConnPool) {
 		pool.logger = logger
 	}
 }
 
+// WithConnPoolConnOptions configures every connection opened by the pool.
+func WithConnPoolConnOptions(opts ...ConnOption) ConnPoolOptions {
+	return func(pool *tcpConnPool) {
+		pool.connOpts = append(pool.connOpts, opts...)
+	}
+}
+
 func NewConnPool(backends []*Backend, opts ...ConnPoolOptions) (TCPConnPool, error) {
 	pool := &tcpConnPool{
-		backends:      backends,
-		maxIdxForHash: len(backends),
-		mu:            sync.RWMutex{},
+		backends: backends,
+		mu:       sync.RWMutex{},
 		logFields: []zap.Field{
 			zap.String("pool_id", uuid.NewString()),
 		},
@@ -114,6 +289,9 @@ func NewConnPool(backends []*Backend, opts ...ConnPoolOptions) (TCPConnPool, err
 	if pool.hashFn == nil {
 		// use a random hash
 		pool.hashFn = RandomHashFn
+		if pool.rng != nil {
+			pool.hashFn = NewRandomHashFn(pool.rng)
+		}
 	}
 
 	if pool.logger == nil {
@@ -129,38 +307,84 @@ func NewConnPool(backends []*Backend, opts ...ConnPoolOptions) (TCPConnPool, err
 	pool.cm = make(map[string]TCPConnList, len(backends))
 
 	for _, be := range backends {
-		cl, err := NewTCPConnectionList(be, pool.logger)
+		cl, err := NewTCPConnectionList(be, pool.logger, pool.connOpts...)
 		if err != nil {
 			return nil, err
 		}
 		pool.cm[be.String()] = cl
 	}
+	pool.publishRouting()
 
 	pool.logger.Info(fmt.Sprintf("Initialized connection pool to %v backends", backends), pool.logFields...)
 	return pool, nil
 }
 
+func (t *tcpConnPool) Stats() map[string]ConnStats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	stats := make(map[string]ConnStats, len(t.cm))
+	for addr, cl := range t.cm {
+		stats[addr] = cl.Stats()
+	}
+	return stats
+}
+
 func RandomHashFn(_ string, n int) int {
 	return csmrand.Intn(n)
 }
 
+// NewRandomHashFn returns a HasherFn ignoring the key and picking a backend at random with rng, like RandomHashFn.
+func NewRandomHashFn(rng Rand) HasherFn {
+	return func(_ string, n int) int {
+		return rng.Intn(n)
+	}
+}
+
+// Append schedules the link on the backend its RoutingHint asks for, or else on the one the HasherFn picks for the key
+// of its request. Requests without a single key, e.g. bulk requests, are hashed with an empty key.
 func (t *tcpConnPool) Append(link codec.Link) error {
-	t.mu.RLock()
-	defer t.mu.RUnlock()
+	var hint codec.RoutingHint
+	if routed, ok := link.(codec.RoutedLink); ok {
+		hint = routed.RoutingHint()
+	}
 
-	if len(t.cm) == 0 {
+	r, shard := t.acquire()
+	defer r.release(shard)
+	if hint.Broadcast != nil {
+		return t.broadcast(r, link, hint.Broadcast)
+	}
+	return t.appendRouted(r, link, hint)
+}
+
+func (t *tcpConnPool) appendRouted(r *poolRouting, link codec.Link, hint codec.RoutingHint) error {
+	if len(r.lists) == 0 {
 		return errEmptyConnPool
 	}
 
-	for i := 0; i < t.maxIdxForHash; i++ {
-		// TODO(hemal): need to pass the hashKey somehow through the encoder?
-		idx := t.hashFn("", t.maxIdxForHash)
+	if hint.Backend != "" {
+		cl, ok := r.byAddr[hint.Backend]
+		if !ok {
+			return fmt.Errorf("backend=%s: %w", hint.Backend, errBackendNotInPool)
+		}
+		return cl.Append(link)
+	}
 
-		if idx < 0 || idx >= t.maxIdxForHash {
-			return fmt.Errorf("hasherFn returned an index outside the range of [0, %d). Got: %d", t.maxIdxForHash, idx)
+	hashKey := hint.HashKey
+	if hashKey == "" {
+		if keyer, ok := link.Encoder().(codec.RoutingKeyer); ok {
+			hashKey = keyer.RoutingKey()
 		}
+	}
 
-		err := t.cm[t.beKey(idx)].Append(link)
+	n := len(r.lists)
+	for i := 0; i < n; i++ {
+		idx, err := PlaceKey(t.hashFn, hashKey, n, r.slots)
+		if err != nil {
+			return err
+		}
+
+		err = r.lists[idx].Append(link)
 
 		if !errors.Is(err, errBackendUnhealthy) {
 			// If append is successfull but there's another form of errors, we should break early and return that.
@@ -171,11 +395,100 @@ func (t *tcpConnPool) Append(link codec.Link) error {
 	return errConnPoolExhausted
 }
 
+// broadcast appends a copy of link to every backend of r, sharing its encoder, and completes link once they're all
+// complete.
+func (t *tcpConnPool) broadcast(r *poolRouting, link codec.Link, newDecoder func(backend string) codec.LinkDecoder) error {
+	if len(r.lists) == 0 {
+		return errEmptyConnPool
+	}
+
+	var errs []error
+	copies := make([]codec.Link, 0, len(r.lists))
+	addrs := make([]string, 0, len(r.lists))
+	for i, addr := range r.addrs {
+		copied := codec.NewGenericLink(link.Encoder(), newDecoder(addr))
+		if err := r.lists[i].Append(copied); err != nil {
+			errs = append(errs, fmt.Errorf("backend=%s: %w", addr, err))
+			continue
+		}
+		copies = append(copies, copied)
+		addrs = append(addrs, addr)
+	}
+
+	if len(copies) == 0 {
+		return errors.Join(errs...)
+	}
+	go func() {
+		for i, copied := range copies {
+			<-copied.Done()
+			if err := copied.Err(); err != nil {
+				errs = append(errs, fmt.Errorf("backend=%s: %w", addrs[i], err))
+			}
+		}
+		link.Complete(errors.Join(errs...))
+	}()
+	return nil
+}
+
+func (t *tcpConnPool) Backends() []*Backend {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return slices.Clone(t.backends)
+}
+
+func (t *tcpConnPool) AppendTo(be *Backend, link codec.Link) error {
+	r, shard := t.acquire()
+	defer r.release(shard)
+	cl, ok := r.byAddr[be.String()]
+	if !ok {
+		return fmt.Errorf("backend=%s: %w", be.String(), errBackendNotInPool)
+	}
+	return cl.Append(link)
+}
+
 func (t *tcpConnPool) Close() {
 	t.logger.Warn("Closing connection pool", t.logFields...)
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	conns := slices.Clone(t.retired)
 	for _, cl := range t.cm {
+		conns = append(conns, cl.Conns()...)
 		_ = cl.Close()
 	}
+
+	t.adminMu.Lock()
+	defer t.adminMu.Unlock()
+	for _, conn := range t.admin {
+		conns = append(conns, conn)
+		_ = conn.Close()
+	}
+	clear(t.admin)
+	for conn := range t.isolated {
+		_ = conn.Close()
+	}
+	t.adminClosed = true
+
+	t.closeOnce.Do(func() {
+		done := t.doneChan()
+		go func() {
+			for _, conn := range conns {
+				conn.Wait()
+			}
+			t.isolatedConns.Wait()
+			close(done)
+		}()
+	})
+}
+
+func (t *tcpConnPool) Done() <-chan struct{} {
+	return t.doneChan()
+}
+
+func (t *tcpConnPool) Wait() {
+	<-t.doneChan()
+}
+
+func (t *tcpConnPool) doneChan() chan struct{} {
+	t.doneOnce.Do(func() { t.done = make(chan struct{}) })
+	return t.done
 }
@@ -1,11 +1,18 @@
 package net
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
 	"net"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
 	"go.uber.org/zap"
 
@@ -20,11 +27,23 @@ func (m *MockTCPConnList) IsHealthy() bool {
 	return true
 }
 
+func (m *MockTCPConnList) HealthyConns() int {
+	return 1
+}
+
+func (m *MockTCPConnList) Conns() []TCPConn {
+	return nil
+}
+
 func (m *MockTCPConnList) Append(link codec.Link) error {
 	args := m.Called(link)
 	return args.Error(0)
 }
 
+func (m *MockTCPConnList) Stats() ConnStats {
+	return ConnStats{}
+}
+
 func (m *MockTCPConnList) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -34,8 +53,9 @@ type LinkMock struct {
 	mock.Mock
 }
 
+// Encoder returns no encoder, the pool looks for the key of the request to place the link.
 func (l *LinkMock) Encoder() codec.LinkEncoder {
-	panic("Intentionally not implemented for mocked structs in unit tests")
+	return nil
 }
 
 func (l *LinkMock) Decoder() codec.LinkDecoder {
@@ -113,7 +133,6 @@ func TestAppendWithInvalidHasherIdx(t *testing.T) {
 		hashFn: func(hashKey string, n int) int {
 			return n
 		},
-		maxIdxForHash: 1,
 	}
 
 	link := &LinkMock{}
@@ -137,8 +156,7 @@ func TestAppendingCorrectly(t *testing.T) {
 		cm: map[string]TCPConnList{
 			be.String(): mockTcpConn,
 		},
-		hashFn:        RandomHashFn,
-		maxIdxForHash: 1,
+		hashFn: RandomHashFn,
 	}
 
 	link := &LinkMock{}
@@ -173,6 +191,31 @@ func TestClosingPool(t *testing.T) {
 	mockTcpConn.AssertCalled(t, "Close")
 }
 
+func TestRoutingDrain(t *testing.T) {
+	r := &poolRouting{drained: make(chan struct{})}
+	r.appending[3].n.Add(1)
+	r.appending[7].n.Add(2)
+
+	drained := make(chan struct{})
+	go func() {
+		r.drain()
+		close(drained)
+	}()
+	r.release(7)
+	r.release(3)
+	select {
+	case <-drained:
+		t.Fatal("drain returned while an append uses the epoch")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	r.release(7)
+	<-drained
+	// draining again, or a nil epoch, doesn't block.
+	r.drain()
+	(*poolRouting)(nil).drain()
+}
+
 func TestAddRemoveBackend(t *testing.T) {
 	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
 	listener, _ := net.Listen("tcp", "localhost:11211")
@@ -195,3 +238,342 @@ func TestAddRemoveBackend(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 0, len(pool.backends))
 }
+
+func TestAppendToBackend(t *testing.T) {
+	be1 := NewBackend(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 11211}, 1, nil)
+	be2 := NewBackend(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 11212}, 1, nil)
+
+	cl1 := &MockTCPConnList{}
+	cl2 := &MockTCPConnList{}
+	pool := &tcpConnPool{
+		backends: []*Backend{be1, be2},
+		cm: map[string]TCPConnList{
+			be1.String(): cl1,
+			be2.String(): cl2,
+		},
+		hashFn: RandomHashFn,
+	}
+
+	link := &LinkMock{}
+	cl2.On("Append", link).Return(nil)
+
+	assert.NoError(t, pool.AppendTo(be2, link))
+	cl2.AssertCalled(t, "Append", link)
+	cl1.AssertNotCalled(t, "Append", link)
+
+	unknown := NewBackend(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 11213}, 1, nil)
+	assert.ErrorIs(t, pool.AppendTo(unknown, link), errBackendNotInPool)
+
+	backends := pool.Backends()
+	assert.Equal(t, []*Backend{be1, be2}, backends)
+	backends[0] = nil
+	assert.Equal(t, be1, pool.backends[0])
+}
+
+// keyedEchoEncoder is an echoEncoder targeting a key.
+type keyedEchoEncoder struct {
+	echoEncoder
+	key string
+}
+
+func (e *keyedEchoEncoder) RoutingKey() string {
+	return e.key
+}
+
+// newSimPool creates a pool of two backends served by SimConns, hashing the keys "a" and "b" to the first and second
+// backend respectively.
+func newSimPool() (*tcpConnPool, []*SimConn) {
+	clock := NewSimClock(time.Unix(0, 0))
+	pool := &tcpConnPool{
+		cm: map[string]TCPConnList{},
+		hashFn: func(hashKey string, n int) int {
+			if hashKey == "b" {
+				return 1
+			}
+			return 0
+		},
+	}
+	var conns []*SimConn
+	for i, port := range []int{11211, 11212} {
+		be := NewBackend(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}, 1, nil)
+		conns = append(conns, NewSimConn(clock, echoResponder, 0))
+		pool.backends = append(pool.backends, be)
+		pool.cm[be.String()] = &tcpConnList{numConns: 1, be: be, conns: []TCPConn{conns[i]}}
+	}
+	return pool, conns
+}
+
+func TestAppendRouting(t *testing.T) {
+	tests := []struct {
+		name     string
+		link     codec.Link
+		expected int
+	}{
+		{
+			name:     "key of the request",
+			link:     codec.NewGenericLink(&keyedEchoEncoder{echoEncoder: echoEncoder{name: "x"}, key: "b"}, &echoDecoder{}),
+			expected: 1,
+		},
+		{
+			name:     "no key",
+			link:     codec.NewGenericLink(&echoEncoder{name: "x"}, &echoDecoder{}),
+			expected: 0,
+		},
+		{
+			name:     "hash key hint",
+			link:     codec.NewRoutedLink(&keyedEchoEncoder{echoEncoder: echoEncoder{name: "x"}, key: "a"}, &echoDecoder{}, codec.RouteByHashKey("b")),
+			expected: 1,
+		},
+		{
+			name:     "backend hint",
+			link:     codec.NewRoutedLink(&echoEncoder{name: "x"}, &echoDecoder{}, codec.RouteToBackend("127.0.0.1:11212")),
+			expected: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, conns := newSimPool()
+			require.NoError(t, pool.Append(tt.link))
+			assert.Equal(t, 1, conns[tt.expected].Pending())
+			assert.Equal(t, 0, conns[1-tt.expected].Pending())
+		})
+	}
+}
+
+func TestAppendToUnknownBackendHint(t *testing.T) {
+	pool, _ := newSimPool()
+	link := codec.NewRoutedLink(&echoEncoder{name: "x"}, &echoDecoder{}, codec.RouteToBackend("127.0.0.1:1"))
+	assert.ErrorIs(t, pool.Append(link), errBackendNotInPool)
+}
+
+func TestAppendBroadcast(t *testing.T) {
+	pool, conns := newSimPool()
+	decoders := map[string]*echoDecoder{}
+	var mu sync.Mutex
+	link := codec.NewRoutedLink(&echoEncoder{name: "x"}, nil, codec.RouteBroadcast(func(backend string) codec.LinkDecoder {
+		mu.Lock()
+		defer mu.Unlock()
+		decoders[backend] = &echoDecoder{}
+		return decoders[backend]
+	}))
+
+	require.NoError(t, pool.Append(link))
+	assert.Equal(t, 1, conns[0].Drain())
+	conns[1].FailNext(errors.New("boom"))
+	assert.Equal(t, 1, conns[1].Drain())
+
+	<-link.Done()
+	assert.ErrorContains(t, link.Err(), "backend=127.0.0.1:11212: boom")
+	assert.Equal(t, "re:x", decoders["127.0.0.1:11211"].line)
+}
+
+func TestAppendAfterRemove(t *testing.T) {
+	pool, conns := newSimPool()
+	pool.logger = zap.NewNop()
+	for _, cl := range pool.cm {
+		cl.(*tcpConnList).logger = zap.NewNop()
+	}
+	require.NoError(t, pool.Remove(pool.backends[0]))
+
+	// the key of the request is placed among the remaining backends.
+	link := codec.NewGenericLink(&keyedEchoEncoder{echoEncoder: echoEncoder{name: "x"}, key: "a"}, &echoDecoder{})
+	require.NoError(t, pool.Append(link))
+	assert.Equal(t, 0, conns[0].Pending())
+	assert.Equal(t, 1, conns[1].Pending())
+}
+
+func TestRemoveDrainsAppends(t *testing.T) {
+	removed := NewBackend(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 11211}, 1, nil)
+	remaining := NewBackend(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 11212}, 1, nil)
+	removedList := &MockTCPConnList{}
+	remainingList := &MockTCPConnList{}
+	pool := &tcpConnPool{
+		backends: []*Backend{removed, remaining},
+		cm:       map[string]TCPConnList{removed.String(): removedList, remaining.String(): remainingList},
+		hashFn:   func(string, int) int { return 0 },
+		logger:   zap.NewNop(),
+	}
+
+	inFlight := &LinkMock{}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	removedList.On("Append", inFlight).Run(func(mock.Arguments) {
+		close(started)
+		<-release
+	}).Return(nil)
+	removedList.On("Close").Return(nil)
+	appended := make(chan error, 1)
+	go func() { appended <- pool.Append(inFlight) }()
+	<-started
+
+	removedErr := make(chan error, 1)
+	go func() { removedErr <- pool.Remove(removed) }()
+	// the connections of the backend aren't closed while a request is being appended to them.
+	select {
+	case err := <-removedErr:
+		t.Fatalf("Remove returned before the append in flight: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	removedList.AssertNotCalled(t, "Close")
+	// the pool isn't locked meanwhile.
+	assert.Equal(t, []*Backend{remaining}, pool.Backends())
+
+	close(release)
+	require.NoError(t, <-appended)
+	require.NoError(t, <-removedErr)
+	removedList.AssertCalled(t, "Close")
+
+	// the appends starting afterwards are routed among the remaining backends.
+	link := &LinkMock{}
+	remainingList.On("Append", link).Return(nil)
+	require.NoError(t, pool.Append(link))
+	removedList.AssertNumberOfCalls(t, "Append", 1)
+}
+
+func TestAppendAdmin(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer listener.Close() //nolint: errcheck
+
+	accepted := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	be := NewBackend(listener.Addr(), 1, nil)
+	pool, err := NewConnPool([]*Backend{be}, WithConnPoolLogger(zap.NewNop()))
+	require.NoError(t, err)
+	data := <-accepted
+	defer data.Close() //nolint: errcheck
+
+	// the admin connection is opened on first use, and reused afterwards.
+	first, _ := newEchoLink("first")
+	require.NoError(t, pool.AppendAdmin(be, first))
+	admin := <-accepted
+	defer admin.Close() //nolint: errcheck
+	second, _ := newEchoLink("second")
+	require.NoError(t, pool.AppendAdmin(be, second))
+	assert.Empty(t, accepted)
+
+	unknown := NewBackend(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}, 1, nil)
+	link, _ := newEchoLink("unknown")
+	assert.ErrorIs(t, pool.AppendAdmin(unknown, link), errBackendNotInPool)
+
+	pool.Close()
+	<-first.Done()
+	<-second.Done()
+	link, _ = newEchoLink("closed")
+	assert.ErrorIs(t, pool.AppendAdmin(be, link), errConnPoolClosed)
+}
+
+func TestAppendIsolated(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer listener.Close() //nolint: errcheck
+
+	accepted := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	be := NewBackend(listener.Addr(), 1, nil)
+	pool, err := NewConnPool([]*Backend{be}, WithConnPoolLogger(zap.NewNop()))
+	require.NoError(t, err)
+	data := <-accepted
+	defer data.Close() //nolint: errcheck
+
+	// every link gets a connection of its own, closed once the link completes.
+	for _, name := range []string{"first", "second"} {
+		link, decoder := newEchoLink(name)
+		require.NoError(t, pool.AppendIsolated(context.Background(), be, link))
+		isolated := <-accepted
+		reader := bufio.NewReader(isolated)
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, name+"\r\n", line)
+		_, err = isolated.Write([]byte("re:" + name + "\r\nunread\r\n"))
+		require.NoError(t, err)
+
+		<-link.Done()
+		require.NoError(t, link.Err())
+		assert.Equal(t, "re:"+name, decoder.line)
+		_, err = reader.ReadByte()
+		assert.ErrorIs(t, err, io.EOF)
+		_ = isolated.Close()
+	}
+
+	unknown := NewBackend(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}, 1, nil)
+	link, _ := newEchoLink("unknown")
+	assert.ErrorIs(t, pool.AppendIsolated(context.Background(), unknown, link), errBackendNotInPool)
+
+	// the connection is closed once the context of the link is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	abandoned, _ := newEchoLink("abandoned")
+	require.NoError(t, pool.AppendIsolated(ctx, be, abandoned))
+	isolated := <-accepted
+	cancel()
+	<-abandoned.Done()
+	assert.Error(t, abandoned.Err())
+	_, err = io.ReadAll(isolated)
+	assert.NoError(t, err)
+	_ = isolated.Close()
+
+	// closing the pool closes the isolated connections still waiting for their response.
+	pending, _ := newEchoLink("pending")
+	require.NoError(t, pool.AppendIsolated(context.Background(), be, pending))
+	isolated = <-accepted
+	defer isolated.Close() //nolint: errcheck
+	pool.Close()
+	<-pending.Done()
+	assert.Error(t, pending.Err())
+	pool.Wait()
+
+	link, _ = newEchoLink("closed")
+	assert.Error(t, pool.AppendIsolated(context.Background(), be, link))
+}
+
+func TestConnPoolWait(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	firstListener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer firstListener.Close() //nolint: errcheck
+	secondListener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer secondListener.Close() //nolint: errcheck
+
+	first := NewBackend(firstListener.Addr(), 2, nil)
+	second := NewBackend(secondListener.Addr(), 1, nil)
+	pool, err := NewConnPool([]*Backend{first}, WithConnPoolLogger(zap.NewNop()))
+	require.NoError(t, err)
+	require.NoError(t, pool.Add(second))
+	_, err = pool.(*tcpConnPool).adminConn(first)
+	require.NoError(t, err)
+	_, err = pool.(*tcpConnPool).adminConn(second)
+	require.NoError(t, err)
+
+	// the connections of a removed backend are waited for as well.
+	require.NoError(t, pool.Remove(second))
+	select {
+	case <-pool.Done():
+		t.Fatal("Done is closed before the pool")
+	default:
+	}
+	pool.Close()
+	pool.Wait()
+	<-pool.Done()
+}
@@ -5,17 +5,21 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"io"
 	"net"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
 	"go.uber.org/zap"
 
 	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
 )
 
 type MockLink struct {
@@ -52,7 +56,7 @@ func (m *MockLinkDecoder) Reset() {
 	panic("did not implement method for test")
 }
 
-func (m *MockLinkDecoder) Decode(r *bufio.Reader) error {
+func (m *MockLinkDecoder) Decode(r codec.Reader) error {
 	m.Called(r)
 	return nil
 }
@@ -65,7 +69,7 @@ func (m *MockLinkEncoder) Reset() {
 	panic("did not implement method for test")
 }
 
-func (m *MockLinkEncoder) Encode(w *bufio.Writer) error {
+func (m *MockLinkEncoder) Encode(w codec.Writer) error {
 	args := m.Called(w)
 	return args.Error(0)
 }
@@ -74,7 +78,7 @@ type ErrorfulMockLinkEncoder struct {
 	mock.Mock
 }
 
-func (e *ErrorfulMockLinkEncoder) Encode(w *bufio.Writer) error {
+func (e *ErrorfulMockLinkEncoder) Encode(w codec.Writer) error {
 	return e.Called(w).Error(0)
 }
 
@@ -90,7 +94,7 @@ func (m *DelayedMockLinkEncoder) Reset() {
 	panic("did not implement method for test")
 }
 
-func (m *DelayedMockLinkEncoder) Encode(w *bufio.Writer) error {
+func (m *DelayedMockLinkEncoder) Encode(w codec.Writer) error {
 	m.Called(w)
 	time.Sleep(3 * time.Millisecond)
 	return nil
@@ -100,7 +104,7 @@ type ErrorfulMockLinkDecoder struct {
 	mock.Mock
 }
 
-func (e *ErrorfulMockLinkDecoder) Decode(r *bufio.Reader) error {
+func (e *ErrorfulMockLinkDecoder) Decode(r codec.Reader) error {
 	return e.Called(r).Error(0)
 }
 
@@ -131,7 +135,7 @@ func TestInvalidConnectionStateAppend(t *testing.T) {
 	defer listener.Close() //nolint: errcheck
 	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
 	be := NewBackend(listener.Addr(), 1, nil)
-	conn := &tcpConn{be: be, state: Reconnecting}
+	conn := &tcpConn{be: be, state: newConnStateMachine(Reconnecting)}
 	link := &MockLink{}
 	err := conn.Append(link)
 	assert.Error(t, err)
@@ -150,8 +154,9 @@ func TestHandleInbound(t *testing.T) {
 
 	link := &MockLink{}
 	decoder := &MockLinkDecoder{}
+	link.On("Encoder").Return(&MockLinkEncoder{})
 	link.On("Decoder").Return(decoder)
-	decoder.On("Decode", fakeTC.rw.Reader).Return(nil)
+	decoder.On("Decode", mock.Anything).Return(nil)
 	link.On("Complete", mock.Anything).Return()
 
 	fakeTC.inbound <- link
@@ -203,7 +208,7 @@ func TestClose(t *testing.T) {
 	err := conn.Close()
 
 	assert.NoError(t, err)
-	assert.Equal(t, Terminated, fakeTC.state)
+	assert.Equal(t, Terminated, fakeTC.state.current())
 }
 
 func TestManagerTerminates(t *testing.T) {
@@ -221,7 +226,7 @@ func TestManagerTerminates(t *testing.T) {
 	// state should be eventually terminated. An helper method can be introduced that can do the same 3 steps here but
 	// I am not a huge fan of adding a helper method in the original struct just for a unit test.
 	fakeTC.mu.RLock()
-	assert.Equal(t, Terminated, fakeTC.state)
+	assert.Equal(t, Terminated, fakeTC.state.current())
 	fakeTC.mu.RUnlock()
 }
 
@@ -257,11 +262,15 @@ func TestConcurrentStateManagement(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 	assert.True(t, conn.isConnected())
 	conn.mu.RLock()
-	assert.Equal(t, Connected, conn.state, "connection should be in connected state")
+	assert.Equal(t, Connected, conn.state.current(), "connection should be in connected state")
 	// both the inbound and outbound queues should be empty
 	assert.Equal(t, 0, len(conn.inbound))
 	assert.Equal(t, 0, len(conn.outbound))
 	conn.mu.RUnlock()
+
+	stats := conn.Stats()
+	assert.Equal(t, uint64(numGoroutines), stats.Appends)
+	assert.Zero(t, stats.Rejected)
 }
 
 func TestHandleConcurrency(t *testing.T) {
@@ -419,3 +428,451 @@ func TestSetDeadlineIfNeeded(t *testing.T) {
 	assert.True(t, timeDiff < time.Second && timeDiff > -time.Second,
 		"deadline should be approximately socketTimeout from now, got diff: %v", timeDiff)
 }
+
+func TestQueueSizes(t *testing.T) {
+	listener, _ := net.Listen("tcp", "localhost:0")
+	defer listener.Close() //nolint: errcheck
+
+	tests := []struct {
+		name             string
+		opts             []ConnOption
+		expectedOutbound int
+		expectedInbound  int
+	}{
+		{name: "defaults", expectedOutbound: 1000, expectedInbound: 2000},
+		{name: "inbound follows outbound", opts: []ConnOption{WithQueueSizes(10, 0)}, expectedOutbound: 10, expectedInbound: 20},
+		{name: "independent sizes", opts: []ConnOption{WithQueueSizes(10, 15)}, expectedOutbound: 10, expectedInbound: 15},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			be := &Backend{addr: listener.Addr(), numConns: 1}
+			fakeTC, err := NewTCPConn(be, zap.NewNop(), test.opts...)
+			assert.NoError(t, err)
+			defer fakeTC.Close() //nolint: errcheck
+
+			conn := fakeTC.(*tcpConn)
+			conn.mu.RLock()
+			defer conn.mu.RUnlock()
+			assert.Equal(t, test.expectedOutbound, cap(conn.outbound))
+			assert.Equal(t, test.expectedInbound, cap(conn.inbound))
+		})
+	}
+}
+
+func newOverflowTestConn(t *testing.T, policy InboundOverflowPolicy) (*tcpConn, *bytes.Buffer) {
+	conn1, conn2 := net.Pipe()
+	t.Cleanup(func() {
+		_ = conn1.Close()
+		_ = conn2.Close()
+	})
+
+	written := &bytes.Buffer{}
+	return &tcpConn{
+		be:              &Backend{addr: conn1.RemoteAddr()},
+		conn:            conn1,
+		rw:              bufio.NewReadWriter(bufio.NewReader(conn1), bufio.NewWriter(written)),
+		outbound:        make(chan codec.Link, 1),
+		inbound:         make(chan codec.Link, 1),
+		inboundOverflow: policy,
+		logger:          zap.NewNop(),
+	}, written
+}
+
+type writingEncoder struct{}
+
+func (e *writingEncoder) Encode(w codec.Writer) error {
+	_, err := w.WriteString("mn\r\n")
+	return err
+}
+
+func (e *writingEncoder) Reset() {}
+
+func TestInboundOverflowFail(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	conn, written := newOverflowTestConn(t, InboundOverflowFail)
+	pending := codec.NewGenericLink(&writingEncoder{}, &MockLinkDecoder{})
+	conn.inbound <- pending
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = conn.HandleOutbound(ctx)
+	}()
+
+	link := codec.NewGenericLink(&writingEncoder{}, &MockLinkDecoder{})
+	conn.outbound <- link
+	<-link.Done()
+	cancel()
+	<-done
+
+	assert.ErrorIs(t, link.Err(), errInboundQueueFull)
+	assert.Zero(t, written.Len(), "the request must not be written when its response can't be awaited")
+	assert.Equal(t, uint64(1), conn.Stats().InboundOverflows)
+	assert.Same(t, pending, <-conn.inbound)
+}
+
+func TestInboundOverflowBlock(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	conn, written := newOverflowTestConn(t, InboundOverflowBlock)
+	pending := codec.NewGenericLink(&writingEncoder{}, &MockLinkDecoder{})
+	conn.inbound <- pending
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = conn.HandleOutbound(ctx)
+	}()
+
+	link := codec.NewGenericLink(&writingEncoder{}, &MockLinkDecoder{})
+	conn.outbound <- link
+	assert.Eventually(t, func() bool {
+		return conn.Stats().InboundOverflows == 1
+	}, time.Second, time.Millisecond)
+
+	// reading the pending response makes room for the blocked link.
+	assert.Same(t, pending, <-conn.inbound)
+	assert.Same(t, link, <-conn.inbound)
+	assert.Equal(t, "mn\r\n", written.String())
+}
+
+func TestHandleInboundDropsUnclaimedResponses(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	conn := &tcpConn{
+		be:      &Backend{addr: &net.TCPAddr{}},
+		rw:      bufio.NewReadWriter(bufio.NewReader(strings.NewReader("HD O1\r\nHD O2\r\n")), nil),
+		inbound: make(chan codec.Link, 1),
+		logger:  zap.NewNop(),
+	}
+
+	encoder := memcache.CreateMetaDeleteEncoder()
+	encoder.Reset()
+	encoder.Key = "key"
+	encoder.Opaque = 2
+	decoder := memcache.CreateMetaDeleteDecoder()
+	link := codec.NewGenericLink(encoder, decoder)
+
+	// the link is queued first: the responses are followed by EOF, which ends HandleInbound once no link is queued.
+	conn.inbound <- link
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = conn.HandleInbound(ctx)
+	}()
+
+	<-link.Done()
+	cancel()
+	<-done
+
+	assert.NoError(t, link.Err())
+	assert.Equal(t, memcache.Deleted, decoder.Status)
+	assert.Equal(t, uint64(2), decoder.Opaque)
+	assert.Equal(t, uint64(1), conn.Stats().UnclaimedResponses)
+}
+
+func TestDecodeBuffered(t *testing.T) {
+	conn := &tcpConn{
+		be:      &Backend{addr: &net.TCPAddr{}},
+		inbound: make(chan codec.Link, 4),
+		logger:  zap.NewNop(),
+	}
+	reader := bufio.NewReader(strings.NewReader("HD O1\r\nHD O2\r\n"))
+	_, err := reader.Peek(1)
+	require.NoError(t, err)
+
+	var links []codec.Link
+	var decoders []*memcache.MetaDeleteDecoder
+	for i := 0; i < 3; i++ {
+		decoder := memcache.CreateMetaDeleteDecoder()
+		links = append(links, codec.NewGenericLink(memcache.CreateMetaDeleteEncoder(), decoder))
+		decoders = append(decoders, decoder)
+		conn.inbound <- links[i]
+	}
+
+	// the responses already buffered are decoded, the link whose response isn't is left queued.
+	require.NoError(t, conn.decodeBuffered(reader, newFrameReader(1)))
+	for i, link := range links[:2] {
+		<-link.Done()
+		assert.NoError(t, link.Err())
+		assert.Equal(t, uint64(i+1), decoders[i].Opaque)
+	}
+	assert.Same(t, links[2], <-conn.inbound)
+	assert.Equal(t, uint64(2), conn.Stats().BatchedDecodes)
+
+	// nothing is decoded without a queued link.
+	reader = bufio.NewReader(strings.NewReader("HD\r\n"))
+	_, err = reader.Peek(1)
+	require.NoError(t, err)
+	require.NoError(t, conn.decodeBuffered(reader, newFrameReader(1)))
+	assert.Equal(t, 4, reader.Buffered())
+	assert.Equal(t, uint64(2), conn.Stats().BatchedDecodes)
+}
+
+func TestClosePendingLinkPromptly(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer listener.Close() //nolint: errcheck
+
+	// the server reads the requests but never answers.
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close() //nolint: errcheck
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+
+	conn, err := NewTCPConn(NewBackend(listener.Addr(), 1, nil), zap.NewNop())
+	require.NoError(t, err)
+
+	link, _ := newEchoLink("unanswered")
+	require.NoError(t, conn.Append(link))
+	require.NoError(t, conn.Close())
+
+	select {
+	case <-link.Done():
+		assert.Error(t, link.Err())
+	case <-time.After(time.Second):
+		t.Fatal("the pending link wasn't completed when the connection was closed")
+	}
+}
+
+func TestReconnectAfterLostConnection(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer listener.Close() //nolint: errcheck
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	c, err := NewTCPConn(NewBackend(listener.Addr(), 1, nil), zap.NewNop())
+	require.NoError(t, err)
+	defer c.Close() //nolint: errcheck
+
+	// the server drops the first connection, which the client notices with its next request and reconnects.
+	first := <-accepted
+	require.NoError(t, first.Close())
+	lost, _ := newEchoLink("lost")
+	require.NoError(t, c.Append(lost))
+	<-lost.Done()
+	assert.Error(t, lost.Err())
+	var second net.Conn
+	select {
+	case second = <-accepted:
+		defer second.Close() //nolint: errcheck
+	case <-time.After(time.Second):
+		t.Fatal("the connection wasn't re-established")
+	}
+	go func() {
+		r := bufio.NewReader(second)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			_, _ = second.Write([]byte("re:" + line))
+		}
+	}()
+
+	tc := c.(*tcpConn)
+	require.Eventually(t, tc.isConnected, time.Second, time.Millisecond)
+	link, decoder := newEchoLink("after")
+	require.NoError(t, c.Append(link))
+	<-link.Done()
+	assert.NoError(t, link.Err())
+	assert.Equal(t, "re:after", decoder.line)
+}
+
+func TestReconnectIdleConnection(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer listener.Close() //nolint: errcheck
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	c, err := NewTCPConn(NewBackend(listener.Addr(), 1, nil), zap.NewNop())
+	require.NoError(t, err)
+	defer c.Close() //nolint: errcheck
+
+	// the socket is read even without pending links, so the loss of an idle connection is noticed right away.
+	first := <-accepted
+	require.NoError(t, first.Close())
+	select {
+	case second := <-accepted:
+		require.NoError(t, second.Close())
+	case <-time.After(time.Second):
+		t.Fatal("the idle connection wasn't re-established")
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer listener.Close() //nolint: errcheck
+
+	// the server answers a request only once the test releases it.
+	received := make(chan string, 2)
+	release := make(chan struct{})
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close() //nolint: errcheck
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			received <- strings.TrimSpace(line)
+			<-release
+			_, _ = conn.Write([]byte("re:" + line))
+		}
+	}()
+
+	c, err := NewTCPConn(NewBackend(listener.Addr(), 1, nil), zap.NewNop(), WithMaxInFlight(1))
+	require.NoError(t, err)
+	defer c.Close() //nolint: errcheck
+
+	first, _ := newEchoLink("first")
+	second, _ := newEchoLink("second")
+	require.NoError(t, c.Append(first))
+	require.NoError(t, c.Append(second))
+
+	assert.Equal(t, "first", <-received)
+	select {
+	case line := <-received:
+		t.Fatalf("%s was written while the first request was in flight", line)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release <- struct{}{}
+	<-first.Done()
+	assert.NoError(t, first.Err())
+	assert.Equal(t, "second", <-received)
+	release <- struct{}{}
+	<-second.Done()
+	assert.NoError(t, second.Err())
+	assert.Equal(t, uint64(1), c.Stats().InFlightLimitWaits)
+}
+
+func TestResponseTimeout(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer listener.Close() //nolint: errcheck
+
+	// the first connection swallows the requests, the next ones echo them.
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	clock := NewSimClock(time.Unix(1_700_000_000, 0))
+	c, err := NewTCPConn(NewBackend(listener.Addr(), 1, nil), zap.NewNop(), WithResponseTimeout(time.Minute), WithClock(clock))
+	require.NoError(t, err)
+	defer c.Close() //nolint: errcheck
+
+	first := <-accepted
+	defer first.Close() //nolint: errcheck
+	go func() {
+		_, _ = io.Copy(io.Discard, first)
+	}()
+
+	stalled, _ := newEchoLink("stalled")
+	require.NoError(t, c.Append(stalled))
+	// the watchdog is armed once the request is written, the minute elapses as soon as the clock is advanced.
+	require.Eventually(t, func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(time.Minute)
+	select {
+	case <-stalled.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the link wasn't failed by the response timeout")
+	}
+	var timeoutErr *ResponseTimeoutError
+	require.ErrorAs(t, stalled.Err(), &timeoutErr)
+	assert.Equal(t, time.Minute, timeoutErr.Timeout)
+	assert.Contains(t, stalled.Err().Error(), "did not respond to request unknown")
+	assert.Equal(t, uint64(1), c.Stats().ResponseTimeouts)
+
+	var second net.Conn
+	select {
+	case second = <-accepted:
+		defer second.Close() //nolint: errcheck
+	case <-time.After(time.Second):
+		t.Fatal("the connection wasn't re-established")
+	}
+	go func() {
+		r := bufio.NewReader(second)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			_, _ = second.Write([]byte("re:" + line))
+		}
+	}()
+
+	tc := c.(*tcpConn)
+	require.Eventually(t, tc.isConnected, time.Second, time.Millisecond)
+	link, decoder := newEchoLink("answered")
+	require.NoError(t, c.Append(link))
+	<-link.Done()
+	assert.NoError(t, link.Err())
+	assert.Equal(t, "re:answered", decoder.line)
+	assert.Equal(t, uint64(1), c.Stats().ResponseTimeouts)
+}
+
+func TestConnWait(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer listener.Close() //nolint: errcheck
+
+	be := NewBackend(listener.Addr(), 1, nil)
+	conn, err := NewTCPConn(be, zap.NewNop())
+	require.NoError(t, err)
+
+	select {
+	case <-conn.Done():
+		t.Fatal("Done is closed before the connection")
+	default:
+	}
+	require.NoError(t, conn.Close())
+	conn.Wait()
+	<-conn.Done()
+}
@@ -0,0 +1,94 @@
+package net
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+// WithBandwidthLimit throttles the requests written to the connection to bytesPerSecond, allowing bursts of burst
+// bytes, so that backfills and cache warms can't saturate shared NICs or cross zone links. Requests larger than burst
+// are written in several chunks. The bytes counted are the ones of the requests, before any stream compression.
+// Non-positive rates don't throttle.
+func WithBandwidthLimit(bytesPerSecond int, burst int) ConnOption {
+	return func(c *tcpConn) {
+		if bytesPerSecond <= 0 {
+			c.bandwidth = nil
+			return
+		}
+		c.bandwidth = newByteBucket(float64(bytesPerSecond), max(burst, 1))
+	}
+}
+
+// byteBucket is a token bucket counting bytes. The bucket can go into debt: a write takes its bytes right away and
+// waits for the debt to be repaid, so a write larger than what's available isn't starved by smaller ones. It's only
+// used by the routine writing to the connection, so it isn't protected by a lock.
+type byteBucket struct {
+	rate   float64 // bytes per second
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+func newByteBucket(rate float64, burst int) *byteBucket {
+	return &byteBucket{rate: rate, burst: burst, tokens: float64(burst)}
+}
+
+// take removes n bytes from the bucket and returns how long to wait before writing them.
+func (b *byteBucket) take(n int, now time.Time) time.Duration {
+	if !b.last.IsZero() {
+		b.tokens = min(float64(b.burst), b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// throttledWriter writes to conn at the rate of its bucket.
+type throttledWriter struct {
+	conn   net.Conn
+	bucket *byteBucket
+	clock  Clock
+	// done interrupts the waits once the connection is closed.
+	done  <-chan struct{}
+	stats *connStats
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		chunk := p[written:min(len(p), written+w.bucket.burst)]
+		if wait := w.bucket.take(len(chunk), w.clock.Now()); wait > 0 {
+			w.stats.throttleWaitNanos.Add(int64(wait))
+			timer := w.clock.NewTimer(wait)
+			select {
+			case <-timer.C():
+			case <-w.done:
+				timer.Stop()
+				return written, net.ErrClosed
+			}
+			// the deadline was set before waiting, the write itself deserves as much time as any other.
+			if err := w.conn.SetWriteDeadline(time.Now().Add(socketTimeout)); err != nil {
+				return written, err
+			}
+		}
+
+		n, err := w.conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// writer returns what the requests are written to, throttled when a bandwidth limit is set.
+func (c *tcpConn) writer(conn net.Conn) io.Writer {
+	if c.bandwidth == nil {
+		return conn
+	}
+	return &throttledWriter{conn: conn, bucket: c.bandwidth, clock: c.clock, done: c.ctx.Done(), stats: &c.stats}
+}
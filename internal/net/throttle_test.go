@@ -0,0 +1,86 @@
+package net
+
+import (
+	"io"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func TestByteBucketTake(t *testing.T) {
+	start := time.Unix(0, 0)
+	type take struct {
+		n    int
+		at   time.Duration
+		wait time.Duration
+	}
+	tests := []struct {
+		name  string
+		takes []take
+	}{
+		{name: "within burst", takes: []take{{n: 100}, {n: 100}}},
+		{name: "debt", takes: []take{{n: 100}, {n: 150, wait: 50 * time.Millisecond}, {n: 100, wait: 150 * time.Millisecond}}},
+		{name: "refill", takes: []take{{n: 200}, {n: 100, at: 100 * time.Millisecond}}},
+		{name: "refill capped at burst", takes: []take{{n: 200}, {n: 300, at: time.Hour, wait: 100 * time.Millisecond}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// 1000 bytes per second, bursts of 200 bytes.
+			bucket := newByteBucket(1000, 200)
+			for i, take := range test.takes {
+				assert.Equal(t, take.wait, bucket.take(take.n, start.Add(take.at)), "take %d", i)
+			}
+		})
+	}
+}
+
+func TestThrottledWriter(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	client, server := net.Pipe()
+	defer client.Close() //nolint: errcheck
+	defer server.Close() //nolint: errcheck
+	go func() {
+		_, _ = io.Copy(io.Discard, server)
+	}()
+
+	var stats connStats
+	done := make(chan struct{})
+	clock := NewSimClock(time.Unix(0, 0))
+	w := &throttledWriter{conn: client, bucket: newByteBucket(10_000, 100), clock: clock, done: done, stats: &stats}
+
+	written := make(chan int)
+	go func() {
+		n, err := w.Write(make([]byte, 600))
+		assert.NoError(t, err)
+		written <- n
+	}()
+
+	// the burst is written right away, the rest at 10KB/s, in chunks of 100 bytes waiting 10ms each.
+	waits := 0
+	var n int
+	for n == 0 {
+		select {
+		case n = <-written:
+		default:
+			if clock.Timers() > 0 {
+				clock.Advance(10 * time.Millisecond)
+				waits++
+			} else {
+				runtime.Gosched()
+			}
+		}
+	}
+	assert.Equal(t, 600, n)
+	assert.Equal(t, 5, waits)
+	assert.Equal(t, 50*time.Millisecond, stats.snapshot().ThrottleWait)
+
+	// closing the connection interrupts the wait.
+	close(done)
+	n, err := w.Write(make([]byte, 10_000))
+	assert.ErrorIs(t, err, net.ErrClosed)
+	assert.Less(t, n, 10_000)
+}
@@ -0,0 +1,110 @@
+package net
+
+// Topology is a snapshot of the backends of a connection pool and of their connections, for operators and tests to
+// check that a reconfiguration had the intended result.
+type Topology struct {
+	// Version is incremented whenever a backend is added to or removed from the pool. Two snapshots with the same
+	// version place the keys identically.
+	Version uint64
+	// Backends are the backends of the pool in placement order: a key hashed to index i is served by Backends[i].
+	Backends []TopologyBackend
+}
+
+// TopologyBackend is the state of a backend of the pool.
+type TopologyBackend struct {
+	Addr string
+	// Weight is the share of the keys placed on the backend, in proportion to its weight among the ones of the pool.
+	Weight   float64
+	NumConns int
+	// HealthyConns is the number of connections to the backend which are established.
+	HealthyConns int
+}
+
+// Healthy reports whether at least one connection to the backend is established.
+func (b TopologyBackend) Healthy() bool {
+	return b.HealthyConns > 0
+}
+
+// Backend returns the backend with the given address, false if it isn't part of the topology.
+func (t Topology) Backend(addr string) (TopologyBackend, bool) {
+	for _, be := range t.Backends {
+		if be.Addr == addr {
+			return be, true
+		}
+	}
+	return TopologyBackend{}, false
+}
+
+// TopologyChange is a backend part of two topologies whose state differs between them.
+type TopologyChange struct {
+	Before TopologyBackend
+	After  TopologyBackend
+	// Moved reports whether the backend is at a different index, i.e. serves other keys.
+	Moved bool
+}
+
+// TopologyDiff describes how a topology differs from a previous one.
+type TopologyDiff struct {
+	FromVersion uint64
+	ToVersion   uint64
+	Added       []TopologyBackend
+	Removed     []TopologyBackend
+	Changed     []TopologyChange
+}
+
+// Empty reports whether both topologies are identical.
+func (d TopologyDiff) Empty() bool {
+	return d.FromVersion == d.ToVersion && len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff returns how t differs from previous: the backends added and removed, in placement order, and the ones whose
+// index, weight, number of connections or health changed.
+func (t Topology) Diff(previous Topology) TopologyDiff {
+	diff := TopologyDiff{FromVersion: previous.Version, ToVersion: t.Version}
+
+	before := make(map[string]int, len(previous.Backends))
+	for i, be := range previous.Backends {
+		before[be.Addr] = i
+	}
+	after := make(map[string]bool, len(t.Backends))
+	for i, be := range t.Backends {
+		after[be.Addr] = true
+		j, ok := before[be.Addr]
+		if !ok {
+			diff.Added = append(diff.Added, be)
+			continue
+		}
+		if prev := previous.Backends[j]; prev != be || i != j {
+			diff.Changed = append(diff.Changed, TopologyChange{Before: prev, After: be, Moved: i != j})
+		}
+	}
+	for _, be := range previous.Backends {
+		if !after[be.Addr] {
+			diff.Removed = append(diff.Removed, be)
+		}
+	}
+	return diff
+}
+
+func (t *tcpConnPool) Topology() Topology {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	total := 0
+	for _, be := range t.backends {
+		total += be.Weight()
+	}
+	topology := Topology{Version: t.version, Backends: make([]TopologyBackend, 0, len(t.backends))}
+	for _, be := range t.backends {
+		tb := TopologyBackend{
+			Addr:     be.String(),
+			Weight:   float64(be.Weight()) / float64(total),
+			NumConns: max(1, be.numConns),
+		}
+		if cl, ok := t.cm[be.String()]; ok {
+			tb.HealthyConns = cl.HealthyConns()
+		}
+		topology.Backends = append(topology.Backends, tb)
+	}
+	return topology
+}
@@ -0,0 +1,69 @@
+package net
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"go.uber.org/zap"
+)
+
+func TestTopologyDiff(t *testing.T) {
+	a := TopologyBackend{Addr: "a:11211", Weight: 0.5, NumConns: 2, HealthyConns: 2}
+	b := TopologyBackend{Addr: "b:11211", Weight: 0.5, NumConns: 2, HealthyConns: 2}
+	c := TopologyBackend{Addr: "c:11211", Weight: 0.5, NumConns: 2, HealthyConns: 0}
+	previous := Topology{Version: 3, Backends: []TopologyBackend{a, b}}
+
+	assert.True(t, previous.Diff(previous).Empty())
+
+	current := Topology{Version: 5, Backends: []TopologyBackend{b, c}}
+	diff := current.Diff(previous)
+	assert.False(t, diff.Empty())
+	assert.Equal(t, uint64(3), diff.FromVersion)
+	assert.Equal(t, uint64(5), diff.ToVersion)
+	assert.Equal(t, []TopologyBackend{c}, diff.Added)
+	assert.Equal(t, []TopologyBackend{a}, diff.Removed)
+	assert.Equal(t, []TopologyChange{{Before: b, After: b, Moved: true}}, diff.Changed)
+	assert.False(t, c.Healthy())
+
+	unhealthy := b
+	unhealthy.HealthyConns = 1
+	diff = Topology{Version: 3, Backends: []TopologyBackend{a, unhealthy}}.Diff(previous)
+	assert.Equal(t, []TopologyChange{{Before: b, After: unhealthy}}, diff.Changed)
+}
+
+func TestPoolTopology(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	first, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer first.Close() //nolint: errcheck
+	second, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer second.Close() //nolint: errcheck
+
+	be1 := NewBackend(first.Addr(), 2, nil)
+	pool, err := NewConnPool([]*Backend{be1}, WithConnPoolLogger(zap.NewNop()))
+	require.NoError(t, err)
+	defer pool.Close()
+
+	before := pool.Topology()
+	assert.Equal(t, uint64(0), before.Version)
+	assert.Equal(t, []TopologyBackend{{Addr: first.Addr().String(), Weight: 1, NumConns: 2, HealthyConns: 2}}, before.Backends)
+
+	be2 := NewWeightedBackend(second.Addr(), 1, 3, nil)
+	require.NoError(t, pool.Add(be2))
+	after := pool.Topology()
+	diff := after.Diff(before)
+	assert.Equal(t, uint64(1), diff.ToVersion)
+	assert.Equal(t, []TopologyBackend{{Addr: second.Addr().String(), Weight: 0.75, NumConns: 1, HealthyConns: 1}}, diff.Added)
+	require.Len(t, diff.Changed, 1)
+	assert.Equal(t, 0.25, diff.Changed[0].After.Weight)
+
+	require.NoError(t, pool.Remove(be1))
+	diff = pool.Topology().Diff(after)
+	assert.Equal(t, uint64(2), diff.ToVersion)
+	assert.Equal(t, first.Addr().String(), diff.Removed[0].Addr)
+	assert.True(t, diff.Changed[0].Moved)
+}
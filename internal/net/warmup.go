@@ -0,0 +1,107 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// warmUpPollInterval is how often WarmUp checks whether a connection is established.
+const warmUpPollInterval = 10 * time.Millisecond
+
+// ProbeFn sends a request on conn and waits for its response, it returns an error when the response isn't the
+// expected one or didn't arrive before ctx is done.
+type ProbeFn func(ctx context.Context, conn codec.Chain) error
+
+// ConnReadiness is the outcome of warming up a connection.
+type ConnReadiness struct {
+	// Connected reports whether the connection was established before the deadline.
+	Connected bool
+	// Latency is the time it took to establish the connection and get the probe answered, or to give up.
+	Latency time.Duration
+	// Err is why the connection isn't ready, nil if it is.
+	Err error
+}
+
+// Ready reports whether the connection is established and answered the probe.
+func (r ConnReadiness) Ready() bool {
+	return r.Err == nil
+}
+
+// BackendReadiness is the outcome of warming up the connections to a backend.
+type BackendReadiness struct {
+	Addr  string
+	Conns []ConnReadiness
+}
+
+// Ready reports whether every connection to the backend is ready.
+func (r BackendReadiness) Ready() bool {
+	for _, conn := range r.Conns {
+		if !conn.Ready() {
+			return false
+		}
+	}
+	return true
+}
+
+// WarmUp waits for every connection of the pool to be established and to answer probe, until ctx is done, and returns
+// the readiness of every backend in placement order. The connections are warmed up concurrently.
+func (t *tcpConnPool) WarmUp(ctx context.Context, probe ProbeFn) []BackendReadiness {
+	t.mu.RLock()
+	backends := slices.Clone(t.backends)
+	lists := make([]TCPConnList, len(backends))
+	for i, be := range backends {
+		lists[i] = t.cm[be.String()]
+	}
+	t.mu.RUnlock()
+
+	readiness := make([]BackendReadiness, len(backends))
+	var wg sync.WaitGroup
+	for i, be := range backends {
+		conns := lists[i].Conns()
+		readiness[i] = BackendReadiness{Addr: be.String(), Conns: make([]ConnReadiness, len(conns))}
+		for j, conn := range conns {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				readiness[i].Conns[j] = warmUpConn(ctx, conn, probe)
+			}()
+		}
+	}
+	wg.Wait()
+	return readiness
+}
+
+func warmUpConn(ctx context.Context, conn TCPConn, probe ProbeFn) ConnReadiness {
+	start := time.Now()
+	if err := waitHealthy(ctx, conn); err != nil {
+		return ConnReadiness{Latency: time.Since(start), Err: fmt.Errorf("connection not established: %w", err)}
+	}
+	err := probe(ctx, conn)
+	return ConnReadiness{Connected: true, Latency: time.Since(start), Err: err}
+}
+
+// waitHealthy returns once conn is established, or with the error of ctx once it's done.
+func waitHealthy(ctx context.Context, conn TCPConn) error {
+	if conn.IsHealthy() {
+		return nil
+	}
+
+	ticker := time.NewTicker(warmUpPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if conn.IsHealthy() {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// echoProbe sends an echo request and answers it right away.
+func echoProbe(ctx context.Context, conn codec.Chain) error {
+	link, decoder := newEchoLink("probe")
+	if err := conn.Append(link); err != nil {
+		return err
+	}
+	conn.(*SimConn).Drain()
+	<-link.Done()
+	if err := link.Err(); err != nil {
+		return err
+	}
+	if decoder.line != "re:probe" {
+		return errors.New("unexpected response " + decoder.line)
+	}
+	return nil
+}
+
+func TestWarmUp(t *testing.T) {
+	pool, conns := newSimPool()
+	conns[1].Break(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	readiness := pool.WarmUp(ctx, echoProbe)
+
+	require.Len(t, readiness, 2)
+	assert.Equal(t, "127.0.0.1:11211", readiness[0].Addr)
+	assert.True(t, readiness[0].Ready())
+	assert.True(t, readiness[0].Conns[0].Connected)
+
+	assert.Equal(t, "127.0.0.1:11212", readiness[1].Addr)
+	assert.False(t, readiness[1].Ready())
+	assert.False(t, readiness[1].Conns[0].Connected)
+	assert.ErrorIs(t, readiness[1].Conns[0].Err, context.DeadlineExceeded)
+}
+
+func TestWarmUpWaitsForConnection(t *testing.T) {
+	pool, conns := newSimPool()
+	conns[0].Break(nil)
+	time.AfterFunc(2*warmUpPollInterval, conns[0].Restore)
+
+	readiness := pool.WarmUp(context.Background(), echoProbe)
+	require.Len(t, readiness, 2)
+	assert.True(t, readiness[0].Ready())
+	assert.GreaterOrEqual(t, readiness[0].Conns[0].Latency, 2*warmUpPollInterval)
+	assert.True(t, readiness[1].Ready())
+}
@@ -0,0 +1,96 @@
+package net
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// ResponseTimeoutError completes a link whose response didn't start arriving within the response timeout after its
+// request was flushed. The connection it was sent on is closed and re-established, as the response may still arrive
+// and would be decoded for the next link otherwise.
+type ResponseTimeoutError struct {
+	Backend string
+	// Operation is the name of the request, e.g. "mg", or "unknown" if the encoder doesn't describe it.
+	Operation string
+	// RedactedKey identifies the key of the request by its length and hash, without revealing it.
+	RedactedKey string
+	// Timeout is the response timeout of the connection.
+	Timeout time.Duration
+}
+
+func (e *ResponseTimeoutError) Error() string {
+	return fmt.Sprintf("server %s did not respond to request %s [key=%s] within %s", e.Backend, e.Operation, e.RedactedKey, e.Timeout)
+}
+
+// WithResponseTimeout fails the link whose response is awaited when no byte was read off the connection within timeout
+// after its request was flushed, and reconnects. Unlike the socket deadline, which is extended by every request
+// written, it bounds the wait of each request on its own. Non-positive timeouts disable it.
+func WithResponseTimeout(timeout time.Duration) ConnOption {
+	return func(c *tcpConn) {
+		c.responseTimeout = timeout
+	}
+}
+
+const (
+	watchPending int32 = iota
+	watchStopped
+	watchFired
+)
+
+// responseWatch is the watchdog of the link being decoded.
+type responseWatch struct {
+	state atomic.Int32
+	timer Timer
+}
+
+// stop disarms the watchdog, and reports whether it fired first.
+func (w *responseWatch) stop() bool {
+	if w == nil {
+		return false
+	}
+	if w.state.CompareAndSwap(watchPending, watchStopped) {
+		w.timer.Stop()
+		return false
+	}
+	return w.state.Load() == watchFired
+}
+
+// watchResponse arms the watchdog of link, whose response is about to be decoded. It returns nil when the connection
+// has no response timeout or the link wasn't written by it.
+func (c *tcpConn) watchResponse(link codec.Link) *responseWatch {
+	ql, ok := link.(*queuedLink)
+	if c.responseTimeout <= 0 || !ok || ql.writtenAt.IsZero() {
+		return nil
+	}
+
+	w := &responseWatch{}
+	writtenAt := ql.writtenAt.UnixNano()
+	conn := c.conn
+	w.timer = c.clock.AfterFunc(ql.writtenAt.Add(c.responseTimeout).Sub(c.clock.Now()), func() {
+		// bytes read since the flush belong to this response or to the ones before it, the server is answering.
+		if c.lastRead.Load() >= writtenAt || !w.state.CompareAndSwap(watchPending, watchFired) {
+			return
+		}
+		c.stats.responseTimeouts.Add(1)
+		// the response may still come, closing the socket keeps it from being decoded for the next link.
+		_ = conn.Close()
+	})
+	return w
+}
+
+func (c *tcpConn) responseTimeoutErr(link codec.Link) *ResponseTimeoutError {
+	err := &ResponseTimeoutError{
+		Backend:   c.be.String(),
+		Operation: "unknown",
+		Timeout:   c.responseTimeout,
+	}
+	if describer, ok := link.Encoder().(codec.RequestDescriber); ok {
+		operation, key := describer.Describe()
+		err.Operation = operation
+		err.RedactedKey = RedactKey(key)
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package net
+
+import (
+	"fmt"
+)
+
+// WeightedSlots returns the index of the backend of every slot the HasherFn picks from when the backends have the
+// given weights: each backend takes as many consecutive slots as its weight, at least one. It returns nil when every
+// weight is 1, the HasherFn then picking the backends directly.
+func WeightedSlots(weights []int) []int {
+	total, uniform := 0, true
+	for _, weight := range weights {
+		total += max(weight, 1)
+		uniform = uniform && weight <= 1
+	}
+	if uniform {
+		return nil
+	}
+
+	slots := make([]int, 0, total)
+	for i, weight := range weights {
+		for range max(weight, 1) {
+			slots = append(slots, i)
+		}
+	}
+	return slots
+}
+
+// BackendSlots returns the WeightedSlots of backends.
+func BackendSlots(backends []*Backend) []int {
+	weights := make([]int, 0, len(backends))
+	for _, be := range backends {
+		weights = append(weights, be.Weight())
+	}
+	return WeightedSlots(weights)
+}
+
+// PlaceKey returns the index of the backend hashFn places hashKey on, among n backends with the given WeightedSlots.
+func PlaceKey(hashFn HasherFn, hashKey string, n int, slots []int) (int, error) {
+	if slots != nil {
+		n = len(slots)
+	}
+	idx := hashFn(hashKey, n)
+	if idx < 0 || idx >= n {
+		return 0, fmt.Errorf("hasherFn returned an index outside the range of [0, %d). Got: %d", n, idx)
+	}
+	if slots != nil {
+		return slots[idx], nil
+	}
+	return idx, nil
+}
@@ -0,0 +1,34 @@
+package net
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeightedSlots(t *testing.T) {
+	assert.Nil(t, WeightedSlots([]int{1, 1, 1}))
+	assert.Nil(t, WeightedSlots([]int{0, 1}))
+	assert.Equal(t, []int{0, 1, 1, 1, 2}, WeightedSlots([]int{1, 3, 0}))
+}
+
+func TestPlaceKey(t *testing.T) {
+	pick := func(idx int) HasherFn {
+		return func(string, int) int { return idx }
+	}
+
+	idx, err := PlaceKey(pick(2), "k", 3, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, idx)
+
+	slots := WeightedSlots([]int{1, 3})
+	idx, err = PlaceKey(pick(3), "k", 2, slots)
+	require.NoError(t, err)
+	assert.Equal(t, 1, idx)
+
+	_, err = PlaceKey(pick(4), "k", 2, slots)
+	assert.ErrorContains(t, err, "index outside the range of [0, 4)")
+	_, err = PlaceKey(pick(-1), "k", 2, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,142 @@
+package net
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/stripe/memlink/codec"
+)
+
+// ZombieLinkError completes the links still queued on a connection when it's lost or closed. It describes the request
+// so that the requests dying in queue during reconnects can be quantified.
+type ZombieLinkError struct {
+	Backend string
+	// Operation is the name of the request, e.g. "mg", or "unknown" if the encoder doesn't describe it.
+	Operation string
+	// RedactedKey identifies the key of the request by its length and hash, without revealing it.
+	RedactedKey string
+	// Queued is the time since the link was appended to the connection.
+	Queued time.Duration
+	// Written reports whether the request was written to the connection, in which case it may have been applied
+	// even though its response was never read.
+	Written bool
+
+	err error
+}
+
+func (e *ZombieLinkError) Error() string {
+	return fmt.Sprintf("%s [backend=%s] [operation=%s] [key=%s] [queued=%s]", e.err, e.Backend, e.Operation, e.RedactedKey, e.Queued)
+}
+
+func (e *ZombieLinkError) Unwrap() error {
+	return e.err
+}
+
+// ZombieLinkHook is called for every link completed with a ZombieLinkError.
+type ZombieLinkHook func(err ZombieLinkError)
+
+// WithZombieLinkHook calls hook for every link left in the queues of the connection when it's lost or closed.
+func WithZombieLinkHook(hook ZombieLinkHook) ConnOption {
+	return func(c *tcpConn) {
+		c.zombieLinkHook = hook
+	}
+}
+
+// ZombieLinkPolicy decides what happens to the links still queued on a connection when it's lost.
+type ZombieLinkPolicy int
+
+const (
+	// ZombieLinkFail completes every zombie link with a ZombieLinkError.
+	ZombieLinkFail ZombieLinkPolicy = iota
+	// ZombieLinkRetryIdempotent appends the zombie links whose request is idempotent to another connection to the
+	// same backend, once. The other links, and the ones no connection accepts, are failed as with ZombieLinkFail.
+	ZombieLinkRetryIdempotent
+)
+
+// WithZombieLinkPolicy sets what happens to the links still queued on a connection when it's lost. It defaults to
+// ZombieLinkFail. Retries need a connection list with more than one connection per backend.
+func WithZombieLinkPolicy(policy ZombieLinkPolicy) ConnOption {
+	return func(c *tcpConn) {
+		c.zombieLinkPolicy = policy
+	}
+}
+
+// withRequeue sets how the connection hands its zombie links over to the other connections of its list.
+func withRequeue(requeue func(link codec.Link) error) ConnOption {
+	return func(c *tcpConn) {
+		c.requeue = requeue
+	}
+}
+
+// retriedLink marks a zombie link appended to another connection, so that it isn't retried again.
+type retriedLink struct {
+	codec.Link
+}
+
+// retryZombieLink appends link to another connection when the policy and the request allow it, and reports whether it
+// did. It's called with mu held, links of a closed connection are never retried.
+func (c *tcpConn) retryZombieLink(link codec.Link) bool {
+	if c.zombieLinkPolicy != ZombieLinkRetryIdempotent || c.requeue == nil || c.state.is(Terminated) {
+		return false
+	}
+
+	if ql, ok := link.(*queuedLink); ok {
+		link = ql.Link
+	}
+	if _, ok := link.(*retriedLink); ok {
+		return false
+	}
+	if idempotent, ok := link.Encoder().(codec.IdempotentRequest); !ok || !idempotent.Idempotent() {
+		return false
+	}
+
+	if err := c.requeue(&retriedLink{Link: link}); err != nil {
+		c.logger.Debug("Failed to retry zombie link on another connection", append(c.logFields, zap.Error(err))...)
+		return false
+	}
+	c.stats.retriedZombieLinks.Add(1)
+	return true
+}
+
+// zombieLinkErr describes a link drained from the outbound queue, or from the inbound one when written is set.
+func (c *tcpConn) zombieLinkErr(link codec.Link, written bool, now time.Time) error {
+	zErr := ZombieLinkError{
+		Backend:   c.be.String(),
+		Operation: "unknown",
+		Written:   written,
+		err:       errZombieLinkOnEncoder,
+	}
+	if written {
+		zErr.err = errZombieLinkOnDecoder
+	}
+
+	if ql, ok := link.(*queuedLink); ok {
+		zErr.Queued = now.Sub(ql.enqueuedAt)
+	}
+
+	if describer, ok := link.Encoder().(codec.RequestDescriber); ok {
+		operation, key := describer.Describe()
+		zErr.Operation = operation
+		zErr.RedactedKey = RedactKey(key)
+	}
+
+	if c.zombieLinkHook != nil {
+		c.zombieLinkHook(zErr)
+	}
+	return &zErr
+}
+
+// RedactKey replaces a key by its length and fnv-1a hash, which are enough to correlate log lines without revealing
+// the key.
+func RedactKey(key string) string {
+	if key == "" {
+		return ""
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return fmt.Sprintf("len:%d,fnv:%08x", len(key), h.Sum32())
+}
@@ -0,0 +1,125 @@
+package net
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/stripe/memlink/codec"
+	"github.com/stripe/memlink/codec/memcache"
+)
+
+func TestZombieLinkErr(t *testing.T) {
+	var events []ZombieLinkError
+	conn := &tcpConn{
+		be:     &Backend{addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 11211}},
+		logger: zap.NewNop(),
+	}
+	WithZombieLinkHook(func(err ZombieLinkError) {
+		events = append(events, err)
+	})(conn)
+
+	encoder := memcache.CreateMetaGetEncoder()
+	encoder.Reset()
+	encoder.Key = "user:secret"
+	now := time.Now()
+	link := &queuedLink{
+		Link:       codec.NewGenericLink(encoder, memcache.CreateMetaGetDecoder()),
+		enqueuedAt: now.Add(-time.Second),
+	}
+
+	tests := []struct {
+		name        string
+		written     bool
+		expectedErr error
+	}{
+		{name: "outbound", written: false, expectedErr: errZombieLinkOnEncoder},
+		{name: "inbound", written: true, expectedErr: errZombieLinkOnDecoder},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := conn.zombieLinkErr(link, test.written, now)
+			assert.ErrorIs(t, err, test.expectedErr)
+			assert.NotContains(t, err.Error(), "secret")
+
+			var zErr *ZombieLinkError
+			assert.True(t, errors.As(err, &zErr))
+			assert.Equal(t, "127.0.0.1:11211", zErr.Backend)
+			assert.Equal(t, "mg", zErr.Operation)
+			assert.Equal(t, RedactKey("user:secret"), zErr.RedactedKey)
+			assert.Equal(t, time.Second, zErr.Queued)
+			assert.Equal(t, test.written, zErr.Written)
+			assert.Equal(t, *zErr, events[len(events)-1])
+		})
+	}
+}
+
+func TestZombieLinkErrWithoutDescription(t *testing.T) {
+	conn := &tcpConn{be: &Backend{addr: &net.TCPAddr{}}, logger: zap.NewNop()}
+	link := codec.NewGenericLink(&MockLinkEncoder{}, &MockLinkDecoder{})
+
+	var zErr *ZombieLinkError
+	assert.True(t, errors.As(conn.zombieLinkErr(link, false, time.Now()), &zErr))
+	assert.Equal(t, "unknown", zErr.Operation)
+	assert.Empty(t, zErr.RedactedKey)
+	assert.Zero(t, zErr.Queued)
+}
+
+func TestRedactKey(t *testing.T) {
+	assert.Empty(t, RedactKey(""))
+	assert.Equal(t, RedactKey("abc"), RedactKey("abc"))
+	assert.NotEqual(t, RedactKey("abc"), RedactKey("abd"))
+	assert.Contains(t, RedactKey("abc"), "len:3")
+}
+
+func TestRetryZombieLink(t *testing.T) {
+	get := memcache.CreateMetaGetEncoder()
+	get.Reset()
+	get.Key = "foo"
+	incr := memcache.CreateArithmeticEncoder()
+	incr.Reset()
+	incr.Key = "foo"
+	getLink := codec.NewGenericLink(get, memcache.CreateMetaGetDecoder())
+
+	tests := []struct {
+		name       string
+		policy     ZombieLinkPolicy
+		state      connState
+		link       codec.Link
+		requeueErr error
+		retried    bool
+	}{
+		{name: "fail policy", policy: ZombieLinkFail, link: getLink},
+		{name: "idempotent", policy: ZombieLinkRetryIdempotent, link: &queuedLink{Link: getLink}, retried: true},
+		{name: "not idempotent", policy: ZombieLinkRetryIdempotent, link: codec.NewGenericLink(incr, memcache.CreateArithmeticDecoder())},
+		{name: "not describing idempotency", policy: ZombieLinkRetryIdempotent, link: codec.NewGenericLink(&MockLinkEncoder{}, &MockLinkDecoder{})},
+		{name: "already retried", policy: ZombieLinkRetryIdempotent, link: &queuedLink{Link: &retriedLink{Link: getLink}}},
+		{name: "closed connection", policy: ZombieLinkRetryIdempotent, state: Terminated, link: getLink},
+		{name: "no other connection", policy: ZombieLinkRetryIdempotent, link: getLink, requeueErr: errBackendUnhealthy},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requeued []codec.Link
+			conn := &tcpConn{be: &Backend{addr: &net.TCPAddr{}}, state: newConnStateMachine(test.state), logger: zap.NewNop()}
+			WithZombieLinkPolicy(test.policy)(conn)
+			withRequeue(func(link codec.Link) error {
+				requeued = append(requeued, link)
+				return test.requeueErr
+			})(conn)
+
+			assert.Equal(t, test.retried, conn.retryZombieLink(test.link))
+			if test.retried {
+				assert.Equal(t, []codec.Link{&retriedLink{Link: getLink}}, requeued)
+				assert.Equal(t, uint64(1), conn.Stats().RetriedZombieLinks)
+			} else {
+				assert.Zero(t, conn.Stats().RetriedZombieLinks)
+			}
+		})
+	}
+}
@@ -6,26 +6,45 @@ import (
 
 // Pool is a generic, safe wrapper around sync.Pool.
 type Pool[T any] struct {
-	p sync.Pool
+	p     sync.Pool
+	newFn func() T
 }
 
-// NewPool returns a safe wrapper around sync.Pool for a given type.
+// NewPool returns a safe wrapper around sync.Pool for a given type. newFn may be nil, in which case Get returns the
+// zero value of T when the pool is empty, like sync.Pool without New returns nil.
 func NewPool[T any](newFn func() T) *Pool[T] {
-	return &Pool[T]{
-		p: sync.Pool{
-			New: func() interface{} {
-				return newFn()
-			},
-		},
+	pool := &Pool[T]{newFn: newFn}
+	if newFn != nil {
+		pool.p.New = func() interface{} {
+			return newFn()
+		}
 	}
+	return pool
 }
 
 // Get returns an item of type T.
 func (p *Pool[T]) Get() T {
-	return p.p.Get().(T)
+	item, ok := p.p.Get().(T)
+	if !ok {
+		var zero T
+		return zero
+	}
+	return item
 }
 
 // Put returns an item of type T to the pool for reuse.
 func (p *Pool[T]) Put(item T) {
 	p.p.Put(item)
 }
+
+// Warm allocates n items with newFn and puts them in the pool, so that the first burst of traffic doesn't allocate
+// them. It's best-effort: like any item of a sync.Pool, they may be released by the garbage collector before being
+// used. Warm panics if the pool was created without a newFn.
+func (p *Pool[T]) Warm(n int) {
+	if p.newFn == nil {
+		panic("safepool: Warm needs a pool created with a newFn to allocate the items")
+	}
+	for i := 0; i < n; i++ {
+		p.p.Put(p.newFn())
+	}
+}
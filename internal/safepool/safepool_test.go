@@ -0,0 +1,36 @@
+package safepool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPoolWithoutNewFn(t *testing.T) {
+	p := NewPool[*int](nil)
+	require.NotNil(t, p)
+	assert.Nil(t, p.Get())
+
+	item := new(int)
+	p.Put(item)
+	// the pool may drop items at any time, e.g. under the race detector.
+	got := p.Get()
+	assert.True(t, got == nil || got == item)
+
+	assert.PanicsWithValue(t, "safepool: Warm needs a pool created with a newFn to allocate the items", func() {
+		p.Warm(1)
+	})
+}
+
+func TestPoolWarm(t *testing.T) {
+	allocated := 0
+	p := NewPool(func() *int {
+		allocated++
+		return new(int)
+	})
+
+	p.Warm(3)
+	assert.Equal(t, 3, allocated)
+	require.NotNil(t, p.Get())
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+)
+
+// MaxDataBlockSize bounds the data blocks read whatever the limit of the reader: memcached's item_size_max can't
+// exceed 1 GiB, a larger size comes from a corrupted stream.
+const MaxDataBlockSize = 1 << 30
+
+var (
+	// meta and classic headers of the responses followed by a data block.
+	metaValueHeader    = []byte("VA")
+	classicValueHeader = []byte("VALUE")
+)
+
+// DataBlockTooLargeError is returned by AppendDataBlock for a data block larger than its limit, which isn't read.
+type DataBlockTooLargeError struct {
+	Size  int
+	Limit int
+}
+
+func (e *DataBlockTooLargeError) Error() string {
+	return fmt.Sprintf("%d bytes data block over the %d bytes limit", e.Size, e.Limit)
+}
+
+// DataBlockSize returns the size of the data block following hdrLine, a meta or classic response header, not counting
+// its \r\n, if there is one.
+func DataBlockSize(hdrLine []byte) (int, bool, error) {
+	fields := bytes.Fields(hdrLine)
+	var sizeField []byte
+	switch {
+	case len(fields) > 1 && bytes.Equal(fields[0], metaValueHeader):
+		sizeField = fields[1]
+	case len(fields) > 3 && bytes.Equal(fields[0], classicValueHeader):
+		sizeField = fields[3]
+	default:
+		return 0, false, nil
+	}
+
+	size, err := strconv.Atoi(string(sizeField))
+	if err != nil || size < 0 {
+		return 0, false, fmt.Errorf("invalid data block size in response header %q", hdrLine)
+	}
+	return size, true, nil
+}
+
+// AppendDataBlock reads the data block of size bytes announced by a header, along with its \r\n, from reader and
+// appends it to buf. A data block larger than limit, or MaxDataBlockSize if limit is non-positive or larger, isn't
+// read and fails with a *DataBlockTooLargeError.
+func AppendDataBlock(buf []byte, reader io.Reader, size int, limit int) ([]byte, error) {
+	if limit <= 0 || limit > MaxDataBlockSize {
+		limit = MaxDataBlockSize
+	}
+	if size > limit {
+		return buf, &DataBlockTooLargeError{Size: size, Limit: limit}
+	}
+	start := len(buf)
+	buf = slices.Grow(buf, size+2)[:start+size+2]
+	_, err := io.ReadFull(reader, buf[start:])
+	return buf, err
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataBlockSize(t *testing.T) {
+	tests := []struct {
+		header  string
+		size    int
+		ok      bool
+		wantErr bool
+	}{
+		{header: "VA 4 f1\r\n", size: 4, ok: true},
+		{header: "VALUE key 0 3 42\r\n", size: 3, ok: true},
+		{header: "HD O1\r\n"},
+		{header: "VALUE key\r\n"},
+		{header: "VA x\r\n", wantErr: true},
+		{header: "VA -1\r\n", wantErr: true},
+		{header: "VA 99999999999999999999\r\n", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			size, ok, err := DataBlockSize([]byte(test.header))
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.size, size)
+		})
+	}
+}
+
+func TestAppendDataBlock(t *testing.T) {
+	buf, err := AppendDataBlock([]byte("VA 3\r\n"), strings.NewReader("abc\r\nHD\r\n"), 3, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "VA 3\r\nabc\r\n", string(buf))
+
+	_, err = AppendDataBlock(nil, strings.NewReader("abc"), 3, 0)
+	assert.Error(t, err)
+
+	var tooLarge *DataBlockTooLargeError
+	_, err = AppendDataBlock(nil, strings.NewReader("abcd\r\n"), 4, 3)
+	require.True(t, errors.As(err, &tooLarge))
+	assert.Equal(t, DataBlockTooLargeError{Size: 4, Limit: 3}, *tooLarge)
+
+	// the hard limit applies to the readers without one, or a larger one.
+	for _, limit := range []int{0, MaxDataBlockSize + 1} {
+		_, err = AppendDataBlock(nil, strings.NewReader(""), MaxDataBlockSize+1, limit)
+		require.True(t, errors.As(err, &tooLarge))
+		assert.Equal(t, MaxDataBlockSize, tooLarge.Limit)
+	}
+}
@@ -0,0 +1,117 @@
+// Package placement computes the backends a client places keys on, so that the cache misses caused by a change of its
+// backends, of their weights or of its HasherFn can be quantified before rolling the change out:
+//
+//	before := placement.Config{Backends: []placement.Backend{{Addr: "a:11211"}, {Addr: "b:11211"}}, HashFn: hashFn}
+//	after := placement.Config{Backends: []placement.Backend{{Addr: "a:11211"}, {Addr: "b:11211", Weight: 2}}, HashFn: hashFn}
+//	diff, err := placement.Compare(sampledKeys, before, after)
+//
+// The keys are placed like the pool of the client does, so Place and Compare don't dial any backend.
+package placement
+
+import (
+	"errors"
+	"fmt"
+
+	netpkg "github.com/stripe/memlink/internal/net"
+)
+
+var (
+	// ErrNoBackend is returned when a Config has no backend to place the keys on.
+	ErrNoBackend = errors.New("placement: no backend")
+	// ErrNoHashFn is returned when a Config has no HasherFn: a client without one places the keys at random.
+	ErrNoHashFn = errors.New("placement: no HasherFn, the keys would be placed at random")
+)
+
+// HasherFn returns the index, in [0, n), of the slot of key, like the HasherFn of client.WithKeyHasher.
+type HasherFn = netpkg.HasherFn
+
+// Backend is a backend of a Config, like a client.BackendConfig.
+type Backend struct {
+	Addr string
+	// Weight is the share of the keys placed on the backend relative to the other ones, at least 1.
+	Weight int
+}
+
+// Config describes how a client places the keys: its backends in placement order, i.e. in the order given to
+// client.NewClientFromBackends, and its HasherFn, which is required.
+type Config struct {
+	Backends []Backend
+	HashFn   HasherFn
+}
+
+// Placement is the backend a key is placed on under a Config.
+type Placement struct {
+	Key     string
+	Backend string
+}
+
+// Place returns the backend of each key, in the same order as the keys.
+func (c Config) Place(keys []string) ([]Placement, error) {
+	if len(c.Backends) == 0 {
+		return nil, ErrNoBackend
+	}
+	if c.HashFn == nil {
+		return nil, ErrNoHashFn
+	}
+
+	weights := make([]int, 0, len(c.Backends))
+	for _, be := range c.Backends {
+		weights = append(weights, be.Weight)
+	}
+	slots := netpkg.WeightedSlots(weights)
+
+	placements := make([]Placement, 0, len(keys))
+	for _, key := range keys {
+		idx, err := netpkg.PlaceKey(c.HashFn, key, len(c.Backends), slots)
+		if err != nil {
+			return nil, fmt.Errorf("key=%q: %w", key, err)
+		}
+		placements = append(placements, Placement{Key: key, Backend: c.Backends[idx].Addr})
+	}
+	return placements, nil
+}
+
+// Move is a key placed on another backend by the new Config.
+type Move struct {
+	Key  string
+	From string
+	To   string
+}
+
+// Diff summarizes the keys placed on other backends by a new Config, each of them being a cache miss right after the
+// change.
+type Diff struct {
+	Total int
+	Moves []Move
+}
+
+// MovedRatio returns the fraction of the keys placed on another backend, i.e. the expected miss ratio right after the
+// change.
+func (d Diff) MovedRatio() float64 {
+	if d.Total == 0 {
+		return 0
+	}
+	return float64(len(d.Moves)) / float64(d.Total)
+}
+
+// Compare places keys under both configs and reports the keys placed on another backend after the change.
+func Compare(keys []string, before, after Config) (Diff, error) {
+	beforePlacements, err := before.Place(keys)
+	if err != nil {
+		return Diff{}, fmt.Errorf("unable to compute placement for the previous config: %w", err)
+	}
+
+	afterPlacements, err := after.Place(keys)
+	if err != nil {
+		return Diff{}, fmt.Errorf("unable to compute placement for the new config: %w", err)
+	}
+
+	diff := Diff{Total: len(keys)}
+	for i := range keys {
+		from, to := beforePlacements[i].Backend, afterPlacements[i].Backend
+		if from != to {
+			diff.Moves = append(diff.Moves, Move{Key: keys[i], From: from, To: to})
+		}
+	}
+	return diff, nil
+}
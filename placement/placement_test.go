@@ -0,0 +1,159 @@
+package placement_test
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/client"
+	"github.com/stripe/memlink/internal/fakeserver"
+	"github.com/stripe/memlink/placement"
+)
+
+func fnvHashFn(hashKey string, n int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(hashKey))
+	return int(h.Sum32() % uint32(n))
+}
+
+func testKeys(n int) []string {
+	keys := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		keys = append(keys, fmt.Sprintf("key-%d", i))
+	}
+	return keys
+}
+
+func TestPlacementIsDeterministic(t *testing.T) {
+	cfg := placement.Config{
+		Backends: []placement.Backend{{Addr: "a:11211"}, {Addr: "b:11211"}, {Addr: "c:11211"}},
+		HashFn:   fnvHashFn,
+	}
+	keys := []string{"a", "b", "c", "d"}
+
+	first, err := cfg.Place(keys)
+	require.NoError(t, err)
+	second, err := cfg.Place(keys)
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	for i, p := range first {
+		assert.Equal(t, keys[i], p.Key)
+		assert.Equal(t, cfg.Backends[fnvHashFn(keys[i], 3)].Addr, p.Backend)
+	}
+}
+
+func TestPlacementWithoutBackends(t *testing.T) {
+	_, err := placement.Config{HashFn: fnvHashFn}.Place([]string{"a"})
+	assert.ErrorIs(t, err, placement.ErrNoBackend)
+}
+
+func TestPlacementWithoutHashFn(t *testing.T) {
+	_, err := placement.Config{Backends: []placement.Backend{{Addr: "a:11211"}}}.Place([]string{"a"})
+	assert.ErrorIs(t, err, placement.ErrNoHashFn)
+}
+
+func TestPlacementWithInvalidHasherIdx(t *testing.T) {
+	cfg := placement.Config{
+		Backends: []placement.Backend{{Addr: "a:11211"}},
+		HashFn: func(_ string, n int) int {
+			return n
+		},
+	}
+	_, err := cfg.Place([]string{"a"})
+	assert.ErrorContains(t, err, "index outside the range")
+}
+
+func TestPlacementWeights(t *testing.T) {
+	cfg := placement.Config{
+		Backends: []placement.Backend{{Addr: "a:11211"}, {Addr: "b:11211", Weight: 3}},
+		HashFn:   fnvHashFn,
+	}
+	placements, err := cfg.Place(testKeys(4000))
+	require.NoError(t, err)
+
+	counts := make(map[string]int)
+	for _, p := range placements {
+		counts[p.Backend]++
+	}
+	assert.InDelta(t, 1000, counts["a:11211"], 150)
+	assert.InDelta(t, 3000, counts["b:11211"], 150)
+}
+
+// the keys are placed like the client places them.
+func TestPlacementMatchesClient(t *testing.T) {
+	srv1, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv1.Close() //nolint: errcheck
+	srv2, err := fakeserver.Start()
+	require.NoError(t, err)
+	defer srv2.Close() //nolint: errcheck
+
+	mc, err := client.NewClientFromBackends([]client.BackendConfig{
+		{Addr: srv1.Addr().String()},
+		{Addr: srv2.Addr().String(), Weight: 2},
+	}, client.WithKeyHasher(fnvHashFn))
+	require.NoError(t, err)
+	defer mc.Close() //nolint: errcheck
+
+	cfg := placement.Config{
+		Backends: []placement.Backend{{Addr: srv1.Addr().String()}, {Addr: srv2.Addr().String(), Weight: 2}},
+		HashFn:   fnvHashFn,
+	}
+	keys := testKeys(100)
+	placements, err := cfg.Place(keys)
+	require.NoError(t, err)
+
+	for _, p := range placements {
+		require.NoError(t, mc.Set(context.Background(), p.Key, []byte("v"), 0))
+		_, onSrv1 := srv1.Get(p.Key)
+		_, onSrv2 := srv2.Get(p.Key)
+		if p.Backend == srv1.Addr().String() {
+			assert.True(t, onSrv1 && !onSrv2, p.Key)
+		} else {
+			assert.True(t, onSrv2 && !onSrv1, p.Key)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	keys := testKeys(1000)
+	before := placement.Config{
+		Backends: []placement.Backend{{Addr: "a:11211"}, {Addr: "b:11211"}, {Addr: "c:11211"}},
+		HashFn:   fnvHashFn,
+	}
+
+	// the same backends: nothing moves.
+	diff, err := placement.Compare(keys, before, before)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, diff.Total)
+	assert.Empty(t, diff.Moves)
+	assert.Zero(t, diff.MovedRatio())
+
+	// adding a backend with modulo hashing moves most keys.
+	after := placement.Config{Backends: append(before.Backends, placement.Backend{Addr: "d:11211"}), HashFn: fnvHashFn}
+	diff, err = placement.Compare(keys, before, after)
+	require.NoError(t, err)
+	assert.Greater(t, diff.MovedRatio(), 0.5)
+	for _, m := range diff.Moves {
+		assert.NotEqual(t, m.From, m.To)
+	}
+
+	// so does changing a weight.
+	reweighted := placement.Config{Backends: append([]placement.Backend(nil), before.Backends...), HashFn: fnvHashFn}
+	reweighted.Backends[2].Weight = 2
+	diff, err = placement.Compare(keys, before, reweighted)
+	require.NoError(t, err)
+	assert.NotEmpty(t, diff.Moves)
+
+	_, err = placement.Compare(keys, before, placement.Config{Backends: before.Backends})
+	assert.ErrorIs(t, err, placement.ErrNoHashFn)
+}
+
+func TestMovedRatioWithoutKeys(t *testing.T) {
+	assert.Zero(t, placement.Diff{}.MovedRatio())
+}
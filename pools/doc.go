@@ -0,0 +1,10 @@
+// Package pools provides pools of reusable encoders and decoders, so that sending a request doesn't allocate them:
+//
+//	var getEncoders = pools.NewResettablePool(memcache.CreateMetaGetEncoder)
+//	var getDecoders = pools.NewResettablePool(memcache.CreateMetaGetDecoder)
+//
+//	encoder, decoder := getEncoders.Get(), getDecoders.Get()
+//	defer pools.Release(ctx, getEncoders, encoder, getDecoders, decoder)
+//
+// Building with the memlink_debug tag detects the misuses of pooled objects, e.g. putting one back twice.
+package pools
@@ -0,0 +1,99 @@
+package pools
+
+import (
+	"context"
+	"sync"
+
+	"github.com/stripe/memlink/internal"
+	"github.com/stripe/memlink/internal/debugcheck"
+)
+
+// Resettable is implemented by the objects held in a ResettablePool, e.g. the memcache encoders and decoders.
+type Resettable = internal.Resettable
+
+// ResettablePool is a type-safe sync.Pool of Resettable objects, which are reset when they're taken out of it.
+type ResettablePool[T Resettable] struct {
+	p     sync.Pool
+	newFn func() T
+}
+
+func NewResettablePool[T Resettable](newFn func() T) *ResettablePool[T] {
+	return &ResettablePool[T]{
+		p: sync.Pool{
+			New: func() interface{} {
+				return newFn()
+			},
+		},
+		newFn: newFn,
+	}
+}
+
+// Warm allocates n items and puts them in the pool, so that the first burst of traffic doesn't allocate them. It's
+// best-effort: like any sync.Pool, the pool may drop its items on garbage collection.
+func (p *ResettablePool[T]) Warm(n int) {
+	for i := 0; i < n; i++ {
+		p.p.Put(p.newFn())
+	}
+}
+
+func (p *ResettablePool[T]) Get() T {
+	i := p.p.Get().(T)
+	i.Reset()
+	debugcheck.Get(i)
+	return i
+}
+
+func (p *ResettablePool[T]) Put(item T) {
+	debugcheck.Put(item)
+	p.p.Put(item)
+}
+
+// PutAllOption configures PutAll.
+type PutAllOption func(*putAllOptions)
+
+type putAllOptions struct {
+	reset bool
+}
+
+// WithReset resets the items as they're put back, so that they drop their references, e.g. to the values of the
+// requests, without waiting to be taken again.
+func WithReset() PutAllOption {
+	return func(o *putAllOptions) {
+		o.reset = true
+	}
+}
+
+// PutAll puts the items back to the pool and zeroes their slots in items, so that the slice doesn't keep pointers to
+// objects the pool may hand to someone else. It returns items truncated to zero length, to be assigned back to the
+// slice it came from:
+//
+//	bulk.Encoders = pool.PutAll(bulk.Encoders)
+func (p *ResettablePool[T]) PutAll(items []T, opts ...PutAllOption) []T {
+	var o putAllOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	for _, i := range items {
+		if o.reset {
+			i.Reset()
+		}
+		debugcheck.Put(i)
+		p.p.Put(i)
+	}
+	clear(items)
+	return items[:0]
+}
+
+// Release returns the encoder and decoder of a request to their pools. If ctx is done, the request may have been
+// abandoned while its link was still queued on a connection, so they're left to the garbage collector instead of
+// being handed out to another request.
+func Release[E, D Resettable](ctx context.Context, encoderPool *ResettablePool[E], encoder E, decoderPool *ResettablePool[D], decoder D) {
+	if ctx.Err() != nil {
+		debugcheck.Abandon(encoder)
+		debugcheck.Abandon(decoder)
+		return
+	}
+	encoderPool.Put(encoder)
+	decoderPool.Put(decoder)
+}
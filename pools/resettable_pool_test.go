@@ -48,3 +48,33 @@ func Test_ResettablePool_PutAndGet(t *testing.T) {
 
 	assert.True(t, reusedItem.resetCalled)
 }
+
+func Test_ResettablePool_PutAll(t *testing.T) {
+	pool := NewResettablePool(func() *MockResettable {
+		return &MockResettable{}
+	})
+
+	items := []*MockResettable{{}, {}}
+	kept := items
+	items = pool.PutAll(items)
+	assert.Empty(t, items)
+	assert.Equal(t, 2, cap(items))
+	assert.Equal(t, []*MockResettable{nil, nil}, kept)
+
+	first, second := &MockResettable{}, &MockResettable{}
+	pool.PutAll([]*MockResettable{first, second}, WithReset())
+	assert.True(t, first.resetCalled)
+	assert.True(t, second.resetCalled)
+}
+
+func Test_ResettablePool_Warm(t *testing.T) {
+	allocated := 0
+	pool := NewResettablePool(func() *MockResettable {
+		allocated++
+		return &MockResettable{}
+	})
+
+	pool.Warm(3)
+	assert.Equal(t, 3, allocated)
+	assert.True(t, pool.Get().resetCalled)
+}
@@ -0,0 +1,40 @@
+//go:build memlink_integration
+
+package testutil_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/client"
+	"github.com/stripe/memlink/examples"
+	"github.com/stripe/memlink/internal/fakeserver"
+	"github.com/stripe/memlink/testutil"
+)
+
+// TestScenariosAgainstMemcached runs every scenario against memcached and the fakeserver, which must behave the same.
+func TestScenariosAgainstMemcached(t *testing.T) {
+	for name, scenario := range examples.Scenarios {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			srv, err := fakeserver.Start()
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = srv.Close() })
+			fake, err := client.NewClient([]string{srv.Addr().String()}, 1)
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = fake.Close() })
+
+			var expected bytes.Buffer
+			require.NoError(t, scenario(ctx, fake, &expected))
+
+			var actual bytes.Buffer
+			require.NoError(t, scenario(ctx, testutil.NewClusterClient(t, 2), &actual))
+			assert.Equal(t, expected.String(), actual.String())
+		})
+	}
+}
@@ -0,0 +1,160 @@
+// Package testutil starts memcached in containers for integration tests, using testcontainers-go. Docker, or another
+// provider supported by testcontainers-go, must be available. It's the module github.com/stripe/memlink/testutil, so
+// that the dependencies of testcontainers-go are only added to the modules of the tests using it.
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/stripe/memlink/client"
+)
+
+// DefaultImage is the memcached image started when no other is set with WithImage.
+const DefaultImage = "memcached:1.6-alpine"
+
+const memcachedPort = "11211/tcp"
+
+type config struct {
+	image         string
+	args          []string
+	connsPerNode  int
+	clientOpts    []client.ClientOption
+	skipUnhealthy bool
+}
+
+// Option configures the containers and the client started by the helpers.
+type Option func(c *config)
+
+// WithImage starts image instead of DefaultImage.
+func WithImage(image string) Option {
+	return func(c *config) {
+		c.image = image
+	}
+}
+
+// WithArgs passes args to memcached, e.g. "-m", "128" or "-I", "2m".
+func WithArgs(args ...string) Option {
+	return func(c *config) {
+		c.args = append(c.args, args...)
+	}
+}
+
+// WithConnsPerNode sets the number of connections the client opens to every node, 1 by default.
+func WithConnsPerNode(n int) Option {
+	return func(c *config) {
+		c.connsPerNode = n
+	}
+}
+
+// WithClientOptions configures the client returned by NewClient and NewClusterClient.
+func WithClientOptions(opts ...client.ClientOption) Option {
+	return func(c *config) {
+		c.clientOpts = append(c.clientOpts, opts...)
+	}
+}
+
+// SkipWithoutDocker skips the test, instead of failing it, when no container provider is running, e.g. on developer
+// machines without Docker.
+func SkipWithoutDocker() Option {
+	return func(c *config) {
+		c.skipUnhealthy = true
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{image: DefaultImage, connsPerNode: 1}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// StartMemcached starts a memcached container, terminated when the test ends, and returns its host:port address.
+func StartMemcached(t *testing.T, opts ...Option) string {
+	t.Helper()
+	return startNodes(t, 1, newConfig(opts))[0]
+}
+
+// NewClient starts a memcached container and returns a client connected to it. Both are torn down when the test
+// ends.
+func NewClient(t *testing.T, opts ...Option) client.MemcachedClient {
+	t.Helper()
+	return NewClusterClient(t, 1, opts...)
+}
+
+// NewClusterClient starts n memcached containers and returns a client connected to all of them. Everything is torn
+// down when the test ends.
+func NewClusterClient(t *testing.T, n int, opts ...Option) client.MemcachedClient {
+	t.Helper()
+	c := newConfig(opts)
+	addresses := startNodes(t, n, c)
+
+	mc, err := client.NewClient(addresses, c.connsPerNode, c.clientOpts...)
+	if err != nil {
+		t.Fatalf("failed to create memcached client: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = mc.Close()
+	})
+	return mc
+}
+
+func startNodes(t *testing.T, n int, c *config) []string {
+	t.Helper()
+	if err := providerHealth(); err != nil {
+		if c.skipUnhealthy {
+			t.Skipf("no container provider is running: %v", err)
+		}
+		t.Fatalf("no container provider is running: %v", err)
+	}
+
+	ctx := context.Background()
+	addresses := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+			ContainerRequest: testcontainers.ContainerRequest{
+				Image:        c.image,
+				Cmd:          c.args,
+				ExposedPorts: []string{memcachedPort},
+				WaitingFor:   wait.ForListeningPort(memcachedPort),
+			},
+			Started: true,
+		})
+		testcontainers.CleanupContainer(t, container)
+		if err != nil {
+			t.Fatalf("failed to start memcached container %d/%d: %v", i+1, n, err)
+		}
+
+		host, err := container.Host(ctx)
+		if err != nil {
+			t.Fatalf("failed to get the host of memcached container %d/%d: %v", i+1, n, err)
+		}
+		port, err := container.MappedPort(ctx, memcachedPort)
+		if err != nil {
+			t.Fatalf("failed to get the port of memcached container %d/%d: %v", i+1, n, err)
+		}
+		addresses = append(addresses, host+":"+port.Port())
+	}
+	return addresses
+}
+
+// providerHealth checks the container provider is running. testcontainers-go panics when it can't find a Docker host,
+// which is turned into an error.
+func providerHealth() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	provider, err := testcontainers.ProviderDocker.GetProvider()
+	if err != nil {
+		return err
+	}
+	return provider.Health(context.Background())
+}
@@ -0,0 +1,40 @@
+package testutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/memlink/client"
+)
+
+func TestNewClient(t *testing.T) {
+	mc := NewClient(t, SkipWithoutDocker())
+	ctx := context.Background()
+
+	require.NoError(t, mc.Add(ctx, client.Item{Key: "key", Value: []byte("value")}))
+	result, err := mc.GetWithTTL(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), result.Value)
+}
+
+func TestNewClusterClient(t *testing.T) {
+	mc := NewClusterClient(t, 2, SkipWithoutDocker(), WithArgs("-m", "16"))
+
+	assert.Len(t, mc.BackendCapabilities(), 2)
+	stats, err := mc.ServerStats(context.Background(), "settings")
+	require.NoError(t, err)
+	for backend, settings := range stats {
+		assert.Equal(t, "16777216", settings["maxbytes"], backend)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	c := newConfig([]Option{WithImage("memcached:1.5"), WithArgs("-m", "64"), WithArgs("-I", "2m"), WithConnsPerNode(2)})
+	assert.Equal(t, "memcached:1.5", c.image)
+	assert.Equal(t, []string{"-m", "64", "-I", "2m"}, c.args)
+	assert.Equal(t, 2, c.connsPerNode)
+	assert.False(t, c.skipUnhealthy)
+}